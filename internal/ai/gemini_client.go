@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -67,6 +68,20 @@ type GenerateRequest struct {
 type GenerateResponse struct {
 	Schema      core.LabelSchema `json:"schema"`
 	RawResponse string           `json:"raw_response,omitempty"`
+	Usage       *Usage           `json:"usage,omitempty"`
+}
+
+// Usage reports the tokens a generation consumed, as counted by Gemini.
+type Usage struct {
+	PromptTokens    int `json:"prompt_tokens"`
+	CandidateTokens int `json:"candidate_tokens"`
+	TotalTokens     int `json:"total_tokens"`
+}
+
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
 }
 
 type GeminiAPIRequest struct {
@@ -102,7 +117,8 @@ type GeminiAPIResponse struct {
 			} `json:"parts"`
 		} `json:"content"`
 	} `json:"candidates"`
-	Error *struct {
+	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+	Error         *struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
 		Status  string `json:"status"`
@@ -139,7 +155,111 @@ func (c *GeminiClient) SetModel(model string) {
 	}
 }
 
-func (c *GeminiClient) GenerateLabel(ctx context.Context, req *GenerateRequest) (*core.LabelSchema, error) {
+// streamTimeout bounds a streamed generation. The client's own timeout is not
+// used for streams because it would cut off long responses mid-body.
+const streamTimeout = 5 * time.Minute
+
+func (c *GeminiClient) GenerateLabel(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	resp, err := c.send(ctx, req, "generateContent", c.httpClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var geminiResp GeminiAPIResponse
+	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if err := geminiResp.err(); err != nil {
+		return nil, err
+	}
+
+	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
+		return nil, fmt.Errorf("no response from gemini")
+	}
+
+	return c.buildResponse(geminiResp.Candidates[0].Content.Parts[0].Text, geminiResp.UsageMetadata)
+}
+
+// GenerateLabelStream generates a label through the streamGenerateContent
+// endpoint, calling onChunk with each piece of text as it arrives. The
+// returned response is the same as GenerateLabel's once the stream ends.
+func (c *GeminiClient) GenerateLabelStream(ctx context.Context, req *GenerateRequest, onChunk func(text string)) (*GenerateResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, streamTimeout)
+	defer cancel()
+
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+
+	resp, err := c.send(ctx, req, "streamGenerateContent", &streamClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return c.readStream(resp.Body, onChunk)
+}
+
+// readStream consumes a server-sent event stream of partial responses. Text
+// is concatenated across chunks; usage is taken from the last chunk that
+// carries it.
+func (c *GeminiClient) readStream(body io.Reader, onChunk func(text string)) (*GenerateResponse, error) {
+	var text strings.Builder
+	var usage *UsageMetadata
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+
+		var chunk GeminiAPIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if err := chunk.err(); err != nil {
+			return nil, err
+		}
+		if chunk.UsageMetadata != nil {
+			usage = chunk.UsageMetadata
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				continue
+			}
+			text.WriteString(part.Text)
+			if onChunk != nil {
+				onChunk(part.Text)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no response from gemini")
+	}
+
+	return c.buildResponse(text.String(), usage)
+}
+
+func (c *GeminiClient) send(ctx context.Context, req *GenerateRequest, method string, client *http.Client) (*http.Response, error) {
 	if c.apiKey == "" {
 		return nil, fmt.Errorf("gemini api key not configured")
 	}
@@ -154,7 +274,10 @@ func (c *GeminiClient) GenerateLabel(ctx context.Context, req *GenerateRequest)
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", c.baseURL, c.model, c.apiKey)
+	url := fmt.Sprintf("%s/models/%s:%s?key=%s", c.baseURL, c.model, method, c.apiKey)
+	if method == "streamGenerateContent" {
+		url += "&alt=sse"
+	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
@@ -162,41 +285,39 @@ func (c *GeminiClient) GenerateLabel(ctx context.Context, req *GenerateRequest)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
+	return resp, nil
+}
 
-	respBody, err := io.ReadAll(resp.Body)
+func (c *GeminiClient) buildResponse(text string, usage *UsageMetadata) (*GenerateResponse, error) {
+	schema, err := c.parseResponse([]byte(text))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var geminiResp GeminiAPIResponse
-	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse label schema: %w", err)
 	}
 
-	if geminiResp.Error != nil {
-		return nil, &GeminiError{
-			Code:    geminiResp.Error.Code,
-			Message: geminiResp.Error.Message,
-			Status:  geminiResp.Error.Status,
+	resp := &GenerateResponse{Schema: *schema}
+	if usage != nil {
+		resp.Usage = &Usage{
+			PromptTokens:    usage.PromptTokenCount,
+			CandidateTokens: usage.CandidatesTokenCount,
+			TotalTokens:     usage.TotalTokenCount,
 		}
 	}
+	return resp, nil
+}
 
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no response from gemini")
+func (r *GeminiAPIResponse) err() error {
+	if r.Error == nil {
+		return nil
 	}
-
-	text := geminiResp.Candidates[0].Content.Parts[0].Text
-	schema, err := c.parseResponse([]byte(text))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse label schema: %w", err)
+	return &GeminiError{
+		Code:    r.Error.Code,
+		Message: r.Error.Message,
+		Status:  r.Error.Status,
 	}
-
-	return schema, nil
 }
 
 func (c *GeminiClient) TestConnection(ctx context.Context) error {
@@ -334,6 +455,9 @@ func (c *GeminiClient) validateSchema(schema *core.LabelSchema) error {
 	if len(schema.Elements) == 0 {
 		return fmt.Errorf("schema must have at least one element")
 	}
+	if schema.Direction != 0 && schema.Direction != 1 {
+		return fmt.Errorf("direction must be 0 or 1")
+	}
 
 	validTypes := map[string]bool{
 		"text":      true,
@@ -353,6 +477,12 @@ func (c *GeminiClient) validateSchema(schema *core.LabelSchema) error {
 		if !validTypes[elem.Type] {
 			return fmt.Errorf("element[%d]: invalid type '%s'", i, elem.Type)
 		}
+		if elem.ShowIf != "" {
+			name, _ := core.ConditionVariable(elem.ShowIf)
+			if _, declared := schema.Variables[name]; !declared {
+				return fmt.Errorf("element[%d]: show_if references undeclared variable '%s'", i, name)
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testLabelJSON = `{"name":"Shelf","width_mm":50,"height_mm":30,"elements":[{"type":"text","x":10,"y":10,"font":"3","content":"{{name}}"}],"variables":{"name":{"type":"string"}}}`
+
+// newTestClient returns a client that sends its requests to handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *GeminiClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := NewGeminiClient()
+	c.SetAPIKey("test-key")
+	c.baseURL = server.URL
+	return c
+}
+
+// sseChunk encodes one streamed response carrying text and, when
+// usage is set, usage metadata.
+func sseChunk(text string, usage *UsageMetadata) string {
+	chunk := map[string]any{
+		"candidates": []any{map[string]any{
+			"content": map[string]any{"parts": []any{map[string]any{"text": text}}},
+		}},
+	}
+	if usage != nil {
+		chunk["usageMetadata"] = usage
+	}
+	data, _ := json.Marshal(chunk)
+	return fmt.Sprintf("data: %s\r\n\r\n", data)
+}
+
+func TestGenerateLabelStream(t *testing.T) {
+	var path, query string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		w.Header().Set("Content-Type", "text/event-stream")
+		third := len(testLabelJSON) / 3
+		fmt.Fprint(w, sseChunk("```json\n"+testLabelJSON[:third], nil))
+		fmt.Fprint(w, sseChunk(testLabelJSON[third:2*third], &UsageMetadata{PromptTokenCount: 120}))
+		fmt.Fprint(w, sseChunk(testLabelJSON[2*third:]+"\n```", &UsageMetadata{
+			PromptTokenCount: 120, CandidatesTokenCount: 80, TotalTokenCount: 200,
+		}))
+	})
+
+	var chunks []string
+	resp, err := c.GenerateLabelStream(context.Background(), &GenerateRequest{Description: "shelf label"}, func(text string) {
+		chunks = append(chunks, text)
+	})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if !strings.HasSuffix(path, ":streamGenerateContent") || !strings.Contains(query, "alt=sse") {
+		t.Errorf("request went to %s?%s, want the streaming endpoint with alt=sse", path, query)
+	}
+	if len(chunks) != 3 {
+		t.Errorf("got %d chunks, want 3", len(chunks))
+	}
+	if resp.Schema.Name != "Shelf" || len(resp.Schema.Elements) != 1 {
+		t.Errorf("schema is %+v, want the streamed label", resp.Schema)
+	}
+	if want := (Usage{PromptTokens: 120, CandidateTokens: 80, TotalTokens: 200}); resp.Usage == nil || *resp.Usage != want {
+		t.Errorf("usage is %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func TestGenerateLabelStreamError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `data: {"error":{"code":429,"message":"quota exceeded","status":"RESOURCE_EXHAUSTED"}}`+"\r\n\r\n")
+	})
+
+	_, err := c.GenerateLabelStream(context.Background(), &GenerateRequest{Description: "shelf label"}, nil)
+	if gerr, ok := err.(*GeminiError); !ok || gerr.Code != 429 {
+		t.Errorf("generate returned %v, want a GeminiError with code 429", err)
+	}
+}
+
+func TestGenerateLabelReportsUsage(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []any{map[string]any{
+				"content": map[string]any{"parts": []any{map[string]any{"text": testLabelJSON}}},
+			}},
+			"usageMetadata": UsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 5, TotalTokenCount: 15},
+		})
+	})
+
+	resp, err := c.GenerateLabel(context.Background(), &GenerateRequest{Description: "shelf label"})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if want := (Usage{PromptTokens: 10, CandidateTokens: 5, TotalTokens: 15}); resp.Usage == nil || *resp.Usage != want {
+		t.Errorf("usage is %+v, want %+v", resp.Usage, want)
+	}
+}
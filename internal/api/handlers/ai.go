@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/orrn/spool/internal/ai"
+	"github.com/orrn/spool/internal/api/middleware"
 	"github.com/orrn/spool/internal/core"
 	"github.com/orrn/spool/internal/db"
 	"github.com/orrn/spool/internal/utils"
@@ -26,11 +27,13 @@ type GenerateTemplateRequest struct {
 	WidthMM     float64 `json:"width_mm,omitempty"`
 	HeightMM    float64 `json:"height_mm,omitempty"`
 	DPI         int     `json:"dpi,omitempty"`
+	Stream      bool    `json:"stream,omitempty"`
 }
 
 type GenerateTemplateResponse struct {
 	Schema      GenerateTemplateSchema `json:"schema"`
 	RawResponse string                 `json:"raw_response,omitempty"`
+	Usage       *ai.Usage              `json:"usage,omitempty"`
 }
 
 type GenerateTemplateSchema struct {
@@ -39,6 +42,8 @@ type GenerateTemplateSchema struct {
 	HeightMM  float64                         `json:"height_mm"`
 	GapMM     float64                         `json:"gap_mm"`
 	DPI       int                             `json:"dpi"`
+	Direction int                             `json:"direction,omitempty"`
+	Mirror    bool                            `json:"mirror,omitempty"`
 	Elements  []map[string]interface{}        `json:"elements"`
 	Variables map[string]VariableDefResponse `json:"variables"`
 }
@@ -101,7 +106,12 @@ func (h *AIHandler) GenerateTemplate(c *gin.Context) {
 		DPI:         req.DPI,
 	}
 
-	schema, err := h.geminiClient.GenerateLabel(c.Request.Context(), genReq)
+	if req.Stream {
+		h.streamGenerate(c, genReq)
+		return
+	}
+
+	result, err := h.geminiClient.GenerateLabel(c.Request.Context(), genReq)
 	if err != nil {
 		if apiErr, ok := err.(*ai.GeminiError); ok {
 			switch apiErr.Status {
@@ -122,11 +132,36 @@ func (h *AIHandler) GenerateTemplate(c *gin.Context) {
 		return
 	}
 
-	response := h.convertSchema(schema)
+	response := h.convertSchema(&result.Schema)
+	response.Usage = result.Usage
 
 	c.JSON(http.StatusOK, response)
 }
 
+// streamGenerate relays generation progress as server-sent events: a "chunk"
+// event per piece of model output, then a single "result" or "error" event.
+func (h *AIHandler) streamGenerate(c *gin.Context, genReq *ai.GenerateRequest) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	result, err := h.geminiClient.GenerateLabelStream(c.Request.Context(), genReq, func(text string) {
+		c.SSEvent("chunk", gin.H{"text": text})
+		c.Writer.Flush()
+	})
+	if err != nil {
+		c.SSEvent("error", gin.H{"error": fmt.Sprintf("Failed to generate template: %v", err)})
+		c.Writer.Flush()
+		return
+	}
+
+	response := h.convertSchema(&result.Schema)
+	response.Usage = result.Usage
+	c.SSEvent("result", response)
+	c.Writer.Flush()
+}
+
 func (h *AIHandler) TestConnection(c *gin.Context) {
 	if !h.geminiClient.IsConfigured() {
 		c.JSON(http.StatusOK, TestConnectionResponse{
@@ -233,6 +268,9 @@ func (h *AIHandler) convertSchema(schema *core.LabelSchema) GenerateTemplateResp
 			"x":    elem.X,
 			"y":    elem.Y,
 		}
+		if elem.ShowIf != "" {
+			elements[i]["show_if"] = elem.ShowIf
+		}
 		if elem.Content != "" {
 			elements[i]["content"] = elem.Content
 		}
@@ -266,6 +304,9 @@ func (h *AIHandler) convertSchema(schema *core.LabelSchema) GenerateTemplateResp
 		if elem.CellWidth != 0 {
 			elements[i]["cell_width"] = elem.CellWidth
 		}
+		if elem.Mask != nil {
+			elements[i]["mask"] = *elem.Mask
+		}
 		if elem.XEnd != 0 {
 			elements[i]["x_end"] = elem.XEnd
 		}
@@ -291,9 +332,24 @@ func (h *AIHandler) convertSchema(schema *core.LabelSchema) GenerateTemplateResp
 		if elem.Width != 0 {
 			elements[i]["width"] = elem.Width
 		}
+		if elem.Overflow != "" {
+			elements[i]["overflow"] = elem.Overflow
+		}
 		if elem.ImagePath != "" {
 			elements[i]["image_path"] = elem.ImagePath
 		}
+		if elem.ImageData != "" {
+			elements[i]["image_data"] = elem.ImageData
+		}
+		if elem.Embed {
+			elements[i]["embed"] = true
+		}
+		if elem.Threshold != 0 {
+			elements[i]["threshold"] = elem.Threshold
+		}
+		if elem.Dither {
+			elements[i]["dither"] = true
+		}
 	}
 
 	variables := make(map[string]VariableDefResponse)
@@ -312,6 +368,8 @@ func (h *AIHandler) convertSchema(schema *core.LabelSchema) GenerateTemplateResp
 			HeightMM:  schema.HeightMM,
 			GapMM:     schema.GapMM,
 			DPI:       schema.DPI,
+			Direction: schema.Direction,
+			Mirror:    schema.Mirror,
 			Elements:  elements,
 			Variables: variables,
 		},
@@ -321,10 +379,10 @@ func (h *AIHandler) convertSchema(schema *core.LabelSchema) GenerateTemplateResp
 func RegisterAIRoutes(router *gin.RouterGroup, handler *AIHandler) {
 	ai := router.Group("/ai")
 	{
-		ai.POST("/generate", handler.GenerateTemplate)
+		ai.POST("/generate", middleware.RequireRole(middleware.RoleOperator), handler.GenerateTemplate)
 		ai.GET("/test", handler.TestConnection)
 		ai.GET("/config", handler.GetConfig)
-		ai.POST("/api-key", handler.SetAPIKey)
-		ai.DELETE("/api-key", handler.DeleteAPIKey)
+		ai.POST("/api-key", middleware.RequireAdmin(), handler.SetAPIKey)
+		ai.DELETE("/api-key", middleware.RequireAdmin(), handler.DeleteAPIKey)
 	}
 }
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/db"
+)
+
+// CreateAPIKeyRequest may scope the key to a namespace, limiting it to the
+// jobs and templates tagged with it.
+type CreateAPIKeyRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Namespace string `json:"namespace" binding:"max=64"`
+}
+
+type APIKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"key_prefix"`
+	Namespace  string     `json:"namespace,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyResponse is the only place a key is ever returned in full;
+// only its hash is stored.
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// APIKeyHandler manages API keys for non-interactive clients. Its routes
+// are restricted to logged-in sessions, so a key cannot mint or revoke
+// other keys.
+type APIKeyHandler struct {
+	db *sql.DB
+}
+
+func NewAPIKeyHandler(database *sql.DB) *APIKeyHandler {
+	return &APIKeyHandler{db: database}
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := db.APIKeys.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve API keys",
+		})
+		return
+	}
+
+	responses := make([]APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		responses = append(responses, apiKeyToResponse(k))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	_, err := db.APIKeys.GetAPIKeyByName(c.Request.Context(), req.Name)
+	if err == nil {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "duplicate_name",
+			Message: "API key with this name already exists",
+		})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to check API key name",
+		})
+		return
+	}
+
+	key, prefix, hash := middleware.GenerateAPIKey()
+	apiKey := &db.APIKey{
+		Name:      req.Name,
+		KeyPrefix: prefix,
+		KeyHash:   hash,
+		Namespace: req.Namespace,
+	}
+	if err := db.APIKeys.CreateAPIKey(c.Request.Context(), apiKey); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to create API key",
+		})
+		return
+	}
+
+	created, err := db.APIKeys.GetAPIKeyByID(c.Request.Context(), apiKey.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve created API key",
+		})
+		return
+	}
+
+	recordAudit(c, "create", "api_key", created.ID, gin.H{"name": created.Name, "key_prefix": created.KeyPrefix, "namespace": created.Namespace})
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		APIKeyResponse: apiKeyToResponse(created),
+		Key:            key,
+	})
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid API key ID",
+		})
+		return
+	}
+
+	k, err := db.APIKeys.GetAPIKeyByID(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "API key not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve API key",
+		})
+		return
+	}
+
+	if k.RevokedAt == nil {
+		if err := db.APIKeys.RevokeAPIKey(c.Request.Context(), id); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to revoke API key",
+			})
+			return
+		}
+		recordAudit(c, "revoke", "api_key", id, gin.H{"name": k.Name, "key_prefix": k.KeyPrefix})
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func apiKeyToResponse(k *db.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		KeyPrefix:  k.KeyPrefix,
+		Namespace:  k.Namespace,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+func RegisterAPIKeyRoutes(r *gin.RouterGroup, h *APIKeyHandler) {
+	keys := r.Group("/api-keys", middleware.RequireAdmin())
+	keys.GET("", h.ListAPIKeys)
+	keys.POST("", h.CreateAPIKey)
+	keys.DELETE("/:id", h.RevokeAPIKey)
+}
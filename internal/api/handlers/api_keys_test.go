@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/db"
+)
+
+// newAPIKeyRouter serves the API key routes and a protected ping route
+// behind the auth middleware, and returns a session cookie for the admin
+// created by first-run setup.
+func newAPIKeyRouter(t *testing.T) (*gin.Engine, *http.Cookie) {
+	t.Helper()
+
+	auth, err := middleware.NewAuthMiddleware(db.GetDB())
+	if err != nil {
+		t.Fatalf("create auth middleware: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Settings.DeleteSetting(context.Background(), "admin_password")
+	})
+
+	router := gin.New()
+	router.POST("/api/auth/setup", auth.SetupHandler)
+	protected := router.Group("/api", auth.RequireAuth())
+	RegisterAPIKeyRoutes(protected, NewAPIKeyHandler(db.GetDB()))
+	protected.GET("/ping", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	w := serveJSON(router, http.MethodPost, "/api/auth/setup", map[string]any{"password": "secret-password"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("setup admin: %d %s", w.Code, w.Body)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Value != "" {
+			return router, cookie
+		}
+	}
+	t.Fatal("setup did not set a session cookie")
+	return nil, nil
+}
+
+func withAPIKey(req *http.Request, key string) *http.Request {
+	req.Header.Set(middleware.APIKeyHeader, key)
+	return req
+}
+
+func TestAPIKeyAuthenticatesUntilRevoked(t *testing.T) {
+	setupTestDB(t)
+	router, session := newAPIKeyRouter(t)
+
+	req := newJSONRequest(http.MethodPost, "/api/api-keys", map[string]any{"name": "warehouse"})
+	req.AddCookie(session)
+	w := serve(router, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create key: %d %s", w.Code, w.Body)
+	}
+	var created CreateAPIKeyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode key: %v", err)
+	}
+
+	if w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, "/api/ping", nil), created.Key)); w.Code != http.StatusNoContent {
+		t.Fatalf("ping with valid key: %d %s, want 204", w.Code, w.Body)
+	}
+
+	// A key cannot manage other keys.
+	if w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, "/api/api-keys", nil), created.Key)); w.Code != http.StatusForbidden {
+		t.Errorf("list keys with a key: %d %s, want 403", w.Code, w.Body)
+	}
+
+	req = newJSONRequest(http.MethodDelete, fmt.Sprintf("/api/api-keys/%d", created.ID), nil)
+	req.AddCookie(session)
+	if w := serve(router, req); w.Code != http.StatusOK && w.Code != http.StatusNoContent {
+		t.Fatalf("revoke key: %d %s", w.Code, w.Body)
+	}
+
+	if w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, "/api/ping", nil), created.Key)); w.Code != http.StatusUnauthorized {
+		t.Errorf("ping with revoked key: %d %s, want 401", w.Code, w.Body)
+	}
+}
+
+func TestUnknownAPIKeyIsRejected(t *testing.T) {
+	setupTestDB(t)
+	router, _ := newAPIKeyRouter(t)
+
+	key, _, _ := middleware.GenerateAPIKey()
+	if w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, "/api/ping", nil), key)); w.Code != http.StatusUnauthorized {
+		t.Errorf("ping with unknown key: %d %s, want 401", w.Code, w.Body)
+	}
+}
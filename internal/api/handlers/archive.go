@@ -10,7 +10,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"orrn-spool/internal/archive"
+	"github.com/orrn/spool/internal/archive"
+	"github.com/orrn/spool/internal/api/middleware"
 )
 
 type ArchiveHandler struct {
@@ -175,12 +176,12 @@ func (h *ArchiveHandler) GetArchiveSettings(c *gin.Context) {
 	})
 }
 
-type UpdateArchiveSettingsRequest struct {
+type UpdateArchiverSettingsRequest struct {
 	ArchiveDays int `json:"archive_days" binding:"required,min=1,max=365"`
 }
 
 func (h *ArchiveHandler) UpdateArchiveSettings(c *gin.Context) {
-	var req UpdateArchiveSettingsRequest
+	var req UpdateArchiverSettingsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -281,15 +282,16 @@ func (h *ArchiveHandler) DownloadArchivePath(c *gin.Context) {
 }
 
 func (h *ArchiveHandler) RegisterRoutes(r *gin.RouterGroup) {
+	admin := middleware.RequireRole(middleware.RoleAdmin)
 	r.GET("/archives", h.ListArchives)
 	r.GET("/archives/stats", h.GetArchiveStats)
 	r.GET("/archives/:filename", h.GetArchiveInfo)
 	r.GET("/archives/:filename/download", h.DownloadArchive)
 	r.GET("/archives/:filename/raw", h.DownloadArchivePath)
-	r.DELETE("/archives/:filename", h.DeleteArchive)
-	r.POST("/archives/run", h.TriggerArchive)
-	r.POST("/archives/restore", h.RestoreJob)
+	r.DELETE("/archives/:filename", admin, h.DeleteArchive)
+	r.POST("/archives/run", admin, h.TriggerArchive)
+	r.POST("/archives/restore", admin, h.RestoreJob)
 	r.GET("/settings/archival", h.GetArchiveSettings)
-	r.PUT("/settings/archival", h.UpdateArchiveSettings)
-	r.PUT("/settings/archival/passphrase", h.SetPassphrase)
+	r.PUT("/settings/archival", admin, h.UpdateArchiveSettings)
+	r.PUT("/settings/archival/passphrase", admin, h.SetPassphrase)
 }
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/db"
+)
+
+// ListAuditQuery pages through the audit log either by offset or by the
+// cursor returned in the previous page's X-Next-Cursor header. A cursor
+// does not shift when new entries are written between pages.
+type ListAuditQuery struct {
+	Action     string `form:"action"`
+	EntityType string `form:"entity_type"`
+	EntityID   int64  `form:"entity_id"`
+	Limit      int    `form:"limit" binding:"max=500"`
+	Offset     int    `form:"offset"`
+	Cursor     string `form:"cursor"`
+}
+
+// auditCursorHeader carries the cursor for the next page of audit entries.
+// It is only set when the page is full.
+const auditCursorHeader = "X-Next-Cursor"
+
+type AuditLogResponse struct {
+	ID         int64           `json:"id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int64           `json:"entity_id"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	IPAddress  string          `json:"ip_address"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditHandler struct {
+	db *sql.DB
+}
+
+func NewAuditHandler(database *sql.DB) *AuditHandler {
+	return &AuditHandler{db: database}
+}
+
+// recordAudit writes an audit entry for a mutating request. Failures are
+// logged rather than surfaced so auditing never blocks the action itself.
+func recordAudit(c *gin.Context, action, entityType string, entityID int64, details interface{}) {
+	var detailsJSON string
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			log.Printf("audit: failed to serialize details for %s %s %d: %v", action, entityType, entityID, err)
+		} else {
+			detailsJSON = string(data)
+		}
+	}
+
+	entry := &db.AuditLog{
+		Action:      action,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		DetailsJSON: detailsJSON,
+		IPAddress:   c.ClientIP(),
+	}
+	if err := db.Audit.CreateAuditLog(c.Request.Context(), entry); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	var query ListAuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = 100
+	}
+
+	filter := db.AuditFilter{
+		Action:     query.Action,
+		EntityType: query.EntityType,
+		EntityID:   query.EntityID,
+	}
+	if query.Cursor != "" {
+		if query.Offset != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor and offset cannot be combined"})
+			return
+		}
+		cursor, err := decodeAuditCursor(query.Cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		filter.Before = cursor
+	}
+
+	logs, err := db.Audit.ListAuditLogs(c.Request.Context(), filter, query.Limit, query.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list audit logs"})
+		return
+	}
+
+	responses := make([]AuditLogResponse, 0, len(logs))
+	for _, l := range logs {
+		resp := AuditLogResponse{
+			ID:         l.ID,
+			Action:     l.Action,
+			EntityType: l.EntityType,
+			EntityID:   l.EntityID,
+			IPAddress:  l.IPAddress,
+			CreatedAt:  l.CreatedAt,
+		}
+		if l.DetailsJSON != "" {
+			resp.Details = json.RawMessage(l.DetailsJSON)
+		}
+		responses = append(responses, resp)
+	}
+
+	if len(logs) == query.Limit {
+		last := logs[len(logs)-1]
+		c.Header(auditCursorHeader, encodeAuditCursor(db.AuditCursor{CreatedAt: last.CreatedAt, ID: last.ID}))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+func encodeAuditCursor(cursor db.AuditCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", cursor.CreatedAt.Unix(), cursor.ID)))
+}
+
+func decodeAuditCursor(token string) (*db.AuditCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var unix, id int64
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &unix, &id); err != nil {
+		return nil, err
+	}
+	return &db.AuditCursor{CreatedAt: time.Unix(unix, 0), ID: id}, nil
+}
+
+func RegisterAuditRoutes(r *gin.RouterGroup, h *AuditHandler) {
+	r.GET("/audit", h.ListAuditLogs)
+}
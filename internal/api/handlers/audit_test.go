@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/orrn/spool/internal/db"
+)
+
+func TestDeletePrinterWritesAuditEntry(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "dock")
+	router := newPrinterRouter(t, database)
+	RegisterAuditRoutes(router.Group("/api"), NewAuditHandler(database))
+
+	w := serveJSON(router, http.MethodDelete, fmt.Sprintf("/api/printers/%d", printerID), nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("delete printer: %d %s", w.Code, w.Body)
+	}
+
+	w = serveJSON(router, http.MethodGet, "/api/audit?entity_type=printer", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list audit: %d %s", w.Code, w.Body)
+	}
+	var entries []AuditLogResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("decode audit: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%d audit entries, want 1: %s", len(entries), w.Body)
+	}
+	entry := entries[0]
+	if entry.Action != "delete" || entry.EntityID != printerID {
+		t.Errorf("audit entry is %s printer %d, want delete printer %d", entry.Action, entry.EntityID, printerID)
+	}
+	var details map[string]string
+	if err := json.Unmarshal(entry.Details, &details); err != nil || details["name"] != "dock" {
+		t.Errorf("audit details are %s, want the deleted printer's name", entry.Details)
+	}
+}
+
+func TestAuditCursorPagingIsStableUnderInserts(t *testing.T) {
+	database := setupTestDB(t)
+	router := newTestRouter()
+	RegisterAuditRoutes(router.Group("/api"), NewAuditHandler(database))
+
+	// Two entries share a timestamp, so the cursor must fall back to the id
+	// to order them.
+	for i, createdAt := range []string{
+		"2026-01-01 10:00:00", "2026-01-01 10:00:05", "2026-01-01 10:00:05", "2026-01-01 10:00:09", "2026-01-01 10:00:12",
+	} {
+		if _, err := database.Exec(`INSERT INTO audit_log (action, entity_type, entity_id, details_json, ip_address, created_at)
+			VALUES ('update', 'printer', ?, '', '', ?)`, i+1, createdAt); err != nil {
+			t.Fatalf("insert audit entry: %v", err)
+		}
+	}
+
+	var seen []int64
+	path := "/api/audit?limit=2&entity_type=printer"
+	for page := 0; page < 5; page++ {
+		w := serveJSON(router, http.MethodGet, path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list audit page %d: %d %s", page, w.Code, w.Body)
+		}
+		var entries []AuditLogResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+			t.Fatalf("decode audit page %d: %v", page, err)
+		}
+		for _, e := range entries {
+			seen = append(seen, e.EntityID)
+		}
+
+		// Entries written between pages are newer than the cursor and must
+		// not push older entries onto the next page.
+		if err := db.Audit.CreateAuditLog(context.Background(), &db.AuditLog{Action: "create", EntityType: "printer", EntityID: 100 + int64(page)}); err != nil {
+			t.Fatalf("insert audit entry between pages: %v", err)
+		}
+
+		cursor := w.Header().Get(auditCursorHeader)
+		if cursor == "" {
+			break
+		}
+		path = "/api/audit?limit=2&entity_type=printer&cursor=" + cursor
+	}
+
+	if fmt.Sprint(seen) != "[5 4 3 2 1]" {
+		t.Errorf("paged through entries %v, want [5 4 3 2 1]", seen)
+	}
+
+	if w := serveJSON(router, http.MethodGet, "/api/audit?cursor=not-a-cursor", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("list audit with a bad cursor: %d %s, want 400", w.Code, w.Body)
+	}
+}
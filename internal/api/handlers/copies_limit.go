@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/core"
+)
+
+// CopiesOverrideHeader lets an admin submit a job above the configured
+// max_copies_per_job. Operators, including API keys, get 403 for sending it.
+const CopiesOverrideHeader = "X-Copies-Override"
+
+// rejectTooManyCopies writes an error and returns true when copies exceeds
+// the queue's per-job limit and the caller may not override it.
+func rejectTooManyCopies(c *gin.Context, queue *core.Queue, copies int) bool {
+	status, message := checkCopiesLimit(c, queue, copies)
+	if status == 0 {
+		return false
+	}
+	c.JSON(status, gin.H{"error": message})
+	return true
+}
+
+// checkCopiesLimit returns the status and message to reject copies with,
+// or a zero status when the caller may submit them.
+func checkCopiesLimit(c *gin.Context, queue *core.Queue, copies int) (int, string) {
+	err := queue.CheckCopies(copies)
+	if err == nil {
+		return 0, ""
+	}
+	override, _ := strconv.ParseBool(c.GetHeader(CopiesOverrideHeader))
+	if override && middleware.IsAdmin(c) {
+		return 0, ""
+	}
+	if override {
+		return http.StatusForbidden, "only admins can override the copies limit"
+	}
+	return http.StatusBadRequest, err.Error()
+}
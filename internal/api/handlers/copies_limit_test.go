@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+)
+
+func TestCopiesLimit(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+
+	queue := core.NewQueue(database, nil, nil, nil, &config.QueueConfig{WorkerCount: 1, MaxCopiesPerJob: 10})
+	generator := core.NewTSPL2Generator()
+	router := newTestRouter()
+	api := router.Group("/api")
+	NewJobHandler(database, queue, generator).RegisterRoutes(api)
+	RegisterTemplateRoutes(api, NewTemplateHandler(database, generator, queue))
+	printers := NewPrinterHandler(database, startPrinterManager(t, database))
+	printers.SetQueue(queue)
+	RegisterPrinterRoutes(api, printers)
+
+	submit := func(path string, copies int, caller string, override bool) int {
+		t.Helper()
+		req := newJSONRequest(http.MethodPost, path, map[string]any{
+			"printer_id": printerID, "template_id": templateID,
+			"variables": map[string]string{"name": "A"}, "copies": copies,
+			"tspl": "PRINT 1\n",
+		})
+		switch caller {
+		case "":
+		case middleware.RoleOperator:
+			req.Header.Set(testRoleHeader, caller)
+		default:
+			req.Header.Set(testAPIKeyHeader, caller)
+		}
+		if override {
+			req.Header.Set(CopiesOverrideHeader, "true")
+		}
+		return serve(router, req).Code
+	}
+
+	printPath := fmt.Sprintf("/api/templates/%d/print", templateID)
+	rawPath := fmt.Sprintf("/api/printers/%d/raw", printerID)
+	tests := []struct {
+		name     string
+		path     string
+		copies   int
+		caller   string
+		override bool
+		want     int
+	}{
+		{"job under cap", "/api/jobs", 10, "", false, http.StatusCreated},
+		{"job over cap", "/api/jobs", 11, "", false, http.StatusBadRequest},
+		{"job admin override", "/api/jobs", 100000, "", true, http.StatusCreated},
+		{"job api key override", "/api/jobs", 11, "erp", true, http.StatusForbidden},
+		{"job api key over cap", "/api/jobs", 11, "erp", false, http.StatusBadRequest},
+		{"print under cap", printPath, 10, "", false, http.StatusAccepted},
+		{"print over cap", printPath, 11, "", false, http.StatusBadRequest},
+		{"print admin override", printPath, 100000, "", true, http.StatusAccepted},
+		{"print operator override", printPath, 11, middleware.RoleOperator, true, http.StatusForbidden},
+		{"raw under cap", rawPath, 10, "", false, http.StatusAccepted},
+		{"raw over cap", rawPath, 11, "", false, http.StatusBadRequest},
+		{"raw admin override", rawPath, 100000, "", true, http.StatusAccepted},
+		{"raw api key override", rawPath, 11, "erp", true, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := submit(tt.path, tt.copies, tt.caller, tt.override); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	var count int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM print_jobs WHERE copies > 10`).Scan(&count); err != nil {
+		t.Fatalf("count jobs: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("%d jobs above the cap were queued, want the 3 overridden ones", count)
+	}
+}
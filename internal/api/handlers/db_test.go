@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/db"
+)
+
+var migrateOnce sync.Once
+
+// testAPIKeyHeader and testRoleHeader set the caller's API key name and
+// role in tests that stand in for the auth middleware.
+const (
+	testAPIKeyHeader = "X-Test-Key"
+	testRoleHeader   = "X-Test-Role"
+)
+
+// newTestRouter returns a router whose requests are authenticated the way
+// RequireAuth would: with the API key named in testAPIKeyHeader, the role
+// in testRoleHeader, or else as the shared admin password's session.
+func newTestRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("authenticated", true)
+		key, role := c.GetHeader(testAPIKeyHeader), c.GetHeader(testRoleHeader)
+		if key != "" {
+			c.Set("api_key", key)
+		}
+		if key == "" && role == "" {
+			role = middleware.RoleAdmin
+		}
+		if role != "" {
+			c.Set("role", role)
+		}
+	})
+	return router
+}
+
+// setupTestDB opens the package's shared in-memory database with every
+// migration applied, and empties the tables tests write to so each test
+// starts from a clean slate.
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	if err := db.Init(db.Config{Path: ":memory:"}); err != nil {
+		t.Fatalf("init database: %v", err)
+	}
+	database := db.GetDB()
+
+	var migrateErr error
+	migrateOnce.Do(func() {
+		paths, err := filepath.Glob("../../db/migrations/*.sql")
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			migration, err := os.ReadFile(path)
+			if err != nil {
+				migrateErr = err
+				return
+			}
+			if _, err := database.Exec(string(migration)); err != nil {
+				migrateErr = err
+				return
+			}
+		}
+	})
+	if migrateErr != nil {
+		t.Fatalf("apply migrations: %v", migrateErr)
+	}
+
+	for _, table := range []string{"print_jobs", "dead_letter_jobs", "label_templates", "printers", "audit_log", "webhooks", "api_keys", "users", "printer_profiles"} {
+		if _, err := database.Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("empty %s: %v", table, err)
+		}
+	}
+	return database
+}
+
+// insertTestPrinter adds an online printer and returns its ID.
+func insertTestPrinter(t *testing.T, database *sql.DB, name string) int64 {
+	t.Helper()
+
+	result, err := database.Exec("INSERT INTO printers (name, ip_address, status, label_width_mm, label_height_mm) VALUES (?, ?, 'online', 50, 30)", name, name+".test")
+	if err != nil {
+		t.Fatalf("insert printer: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+// insertTestTemplate adds a template with the given schema and returns its
+// ID.
+func insertTestTemplate(t *testing.T, database *sql.DB, name, schemaJSON string) int64 {
+	t.Helper()
+
+	result, err := database.Exec(`INSERT INTO label_templates (name, description, schema_json, width_mm, height_mm)
+		VALUES (?, '', ?, 50, 30)`, name, schemaJSON)
+	if err != nil {
+		t.Fatalf("insert template: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+// insertTestJob adds a job for printerID in the given status and returns
+// its ID.
+func insertTestJob(t *testing.T, database *sql.DB, printerID int64, status string) int64 {
+	t.Helper()
+
+	result, err := database.Exec(`INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, submitted_by)
+		VALUES (?, 0, '{}', 'PRINT 1', ?, 'test')`, printerID, status)
+	if err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+// newJSONRequest builds a request with body encoded as JSON, or without a
+// body when it is nil.
+func newJSONRequest(method, path string, body any) *http.Request {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func serve(router http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// serveJSON sends a request built by newJSONRequest and returns the
+// recorded response.
+func serveJSON(router http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	return serve(router, newJSONRequest(method, path, body))
+}
+
+// testLabelSchema is a one-line label with a single optional variable.
+const testLabelSchema = `{"width_mm":50,"height_mm":30,"elements":[{"type":"text","x":10,"y":10,"font":"3","content":"{{name}}"}],"variables":{"name":{"type":"string"}}}`
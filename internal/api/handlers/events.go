@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/core"
+)
+
+type RecentEventsQuery struct {
+	Limit int `form:"limit" binding:"min=0,max=500"`
+}
+
+type EventResponse struct {
+	Seq       int64     `json:"seq"`
+	Type      string    `json:"type"`
+	JobID     int64     `json:"job_id,omitempty"`
+	PrinterID int64     `json:"printer_id,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type EventsHandler struct {
+	events *core.EventLog
+}
+
+func NewEventsHandler(events *core.EventLog) *EventsHandler {
+	return &EventsHandler{events: events}
+}
+
+// RecentEvents returns the most recent job and printer events, newest first.
+// The buffer is in-memory only and starts empty after a restart.
+func (h *EventsHandler) RecentEvents(c *gin.Context) {
+	var query RecentEventsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+
+	events := h.events.Recent(query.Limit)
+	response := make([]EventResponse, 0, len(events))
+	for _, e := range events {
+		response = append(response, EventResponse{
+			Seq:       e.Seq,
+			Type:      e.Type,
+			JobID:     e.JobID,
+			PrinterID: e.PrinterID,
+			Status:    e.Status,
+			Message:   e.Message,
+			Timestamp: e.Timestamp,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func RegisterEventRoutes(r *gin.RouterGroup, h *EventsHandler) {
+	r.GET("/events/recent", h.RecentEvents)
+}
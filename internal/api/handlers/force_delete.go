@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ForceDeleteQuery lets a printer or template be deleted while jobs are
+// still waiting for it; see clearBlockingJobs.
+type ForceDeleteQuery struct {
+	Force bool `form:"force"`
+}
+
+// clearBlockingJobs clears the jobs that would block deleting a printer or
+// template: pending jobs are cancelled and processing jobs are marked
+// failed with reason. column is the print_jobs column naming the deleted
+// entity, printer_id or template_id.
+func clearBlockingJobs(ctx context.Context, database *sql.DB, column string, id int64, reason string) (cancelled, failed int64, err error) {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE print_jobs SET status = 'cancelled', completed_at = CURRENT_TIMESTAMP
+		WHERE %s = ? AND status = 'pending'`, column), id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if cancelled, err = result.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+
+	result, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE print_jobs SET status = 'failed', error_message = ?, completed_at = CURRENT_TIMESTAMP
+		WHERE %s = ? AND status = 'processing'`, column), reason, id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if failed, err = result.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+
+	return cancelled, failed, tx.Commit()
+}
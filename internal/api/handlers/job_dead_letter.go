@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/core"
+)
+
+// DeadLetterJobResponse describes a job that exhausted its retries and was
+// moved to the dead-letter list. JobID is the ID it had as a print job.
+type DeadLetterJobResponse struct {
+	ID            int64     `json:"id"`
+	JobID         int64     `json:"job_id"`
+	PrinterID     int64     `json:"printer_id"`
+	TemplateID    int64     `json:"template_id,omitempty"`
+	VariablesJSON string    `json:"variables_json,omitempty"`
+	Priority      int       `json:"priority"`
+	Copies        int       `json:"copies"`
+	RetryCount    int       `json:"retry_count"`
+	SubmittedBy   string    `json:"submitted_by"`
+	RequestID     string    `json:"request_id,omitempty"`
+	Namespace     string    `json:"namespace,omitempty"`
+	FailureReason string    `json:"failure_reason"`
+	CreatedAt     time.Time `json:"created_at"`
+	FailedAt      time.Time `json:"failed_at"`
+}
+
+type ListDeadLetterQuery struct {
+	Namespace string `form:"namespace"`
+	Limit     int    `form:"limit"`
+	Offset    int    `form:"offset"`
+}
+
+// ListDeadLetterJobs lists dead-letter jobs, most recently failed first.
+func (h *JobHandler) ListDeadLetterJobs(c *gin.Context) {
+	var query ListDeadLetterQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	if query.Limit > 100 {
+		query.Limit = 100
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	jobs, total, err := h.queue.ListDeadLetterJobs(namespaceFilter(c, query.Namespace), query.Limit, query.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list dead-letter jobs"})
+		return
+	}
+
+	response := make([]DeadLetterJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		response = append(response, DeadLetterJobResponse{
+			ID:            job.ID,
+			JobID:         job.JobID,
+			PrinterID:     job.PrinterID,
+			TemplateID:    job.TemplateID,
+			VariablesJSON: job.VariablesJSON,
+			Priority:      job.Priority,
+			Copies:        job.Copies,
+			RetryCount:    job.RetryCount,
+			SubmittedBy:   job.SubmittedBy,
+			RequestID:     job.RequestID,
+			Namespace:     job.Namespace,
+			FailureReason: job.FailureReason,
+			CreatedAt:     job.CreatedAt,
+			FailedAt:      job.FailedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":     response,
+		"limit":    query.Limit,
+		"offset":   query.Offset,
+		"count":    len(response),
+		"total":    total,
+		"has_more": query.Offset+len(response) < total,
+	})
+}
+
+// RequeueDeadLetterJob puts a dead-letter job back in the queue as a new
+// pending job and removes it from the dead-letter list.
+func (h *JobHandler) RequeueDeadLetterJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dead-letter job id"})
+		return
+	}
+
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	if !h.deadLetterVisible(c, id) {
+		return
+	}
+
+	if rejectOverQuota(c, h.quota, 1) {
+		return
+	}
+
+	jobID, err := h.queue.RequeueDeadLetterJob(c.Request.Context(), id)
+	if err != nil {
+		refundQuota(c, h.quota, 1)
+		if errors.Is(err, core.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(c, "requeue", "job", jobID, gin.H{"dead_letter_id": id})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "dead-letter job requeued",
+		"job_id":  jobID,
+	})
+}
+
+// DeleteDeadLetterJob discards a dead-letter job.
+func (h *JobHandler) DeleteDeadLetterJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dead-letter job id"})
+		return
+	}
+
+	if !h.deadLetterVisible(c, id) {
+		return
+	}
+
+	if err := h.queue.DeleteDeadLetterJob(id); err != nil {
+		if errors.Is(err, core.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete dead-letter job"})
+		return
+	}
+
+	recordAudit(c, "delete", "dead_letter_job", id, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "dead-letter job deleted"})
+}
+
+// deadLetterVisible responds with 404 and returns false when the dead-letter
+// job does not exist or is outside the caller's namespace.
+func (h *JobHandler) deadLetterVisible(c *gin.Context, id int64) bool {
+	job, err := h.queue.GetDeadLetterJob(id)
+	if err == nil && !namespaceVisible(c, job.Namespace) {
+		err = core.ErrDeadLetterNotFound
+	}
+	if errors.Is(err, core.ErrDeadLetterNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get dead-letter job"})
+		return false
+	}
+	return true
+}
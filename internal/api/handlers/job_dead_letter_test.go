@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/orrn/spool/internal/core"
+)
+
+// insertTestDeadLetterJob adds a dead-letter entry for printerID and
+// returns its ID.
+func insertTestDeadLetterJob(t *testing.T, database *sql.DB, printerID int64) int64 {
+	t.Helper()
+
+	result, err := database.Exec(`INSERT INTO dead_letter_jobs (job_id, printer_id, template_id, tspl_content, copies, retry_count, submitted_by, failure_reason, created_at)
+		VALUES (99, ?, 0, 'PRINT 1', 2, 3, 'test', 'connection failed', CURRENT_TIMESTAMP)`, printerID)
+	if err != nil {
+		t.Fatalf("insert dead-letter job: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+func TestRequeueDeadLetterJob(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	deadID := insertTestDeadLetterJob(t, database, printerID)
+
+	queue := core.NewQueue(database, acceptingPrinterManager{}, nil, nil, nil)
+	router := newTestRouter()
+	NewJobHandler(database, queue, core.NewTSPL2Generator()).RegisterRoutes(router.Group("/api"))
+
+	w := serveJSON(router, http.MethodGet, "/api/jobs/dead-letter", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list dead-letter jobs: %d %s", w.Code, w.Body)
+	}
+	var list struct {
+		Jobs  []DeadLetterJobResponse `json:"jobs"`
+		Total int                     `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if list.Total != 1 || len(list.Jobs) != 1 || list.Jobs[0].ID != deadID || list.Jobs[0].FailureReason != "connection failed" {
+		t.Fatalf("dead-letter list = %+v, want entry %d", list, deadID)
+	}
+
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/jobs/dead-letter/%d/requeue", deadID), nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("requeue: %d %s", w.Code, w.Body)
+	}
+	var requeued struct {
+		JobID int64 `json:"job_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &requeued); err != nil {
+		t.Fatalf("decode requeue: %v", err)
+	}
+
+	var status string
+	var copies int
+	if err := database.QueryRow("SELECT status, copies FROM print_jobs WHERE id = ?", requeued.JobID).Scan(&status, &copies); err != nil {
+		t.Fatalf("read requeued job: %v", err)
+	}
+	if status != string(core.JobStatusPending) || copies != 2 {
+		t.Errorf("requeued job is %s with %d copies, want pending with 2", status, copies)
+	}
+
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/jobs/dead-letter/%d/requeue", deadID), nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second requeue: %d, want 404", w.Code)
+	}
+}
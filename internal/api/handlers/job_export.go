@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/db"
+)
+
+// jobExportFlushRows is how many CSV rows are buffered before they are
+// flushed to the client.
+const jobExportFlushRows = 100
+
+// ExportJobsQuery takes the same filters as ListJobsQuery. Every matching
+// job is exported, so there is no limit or offset.
+type ExportJobsQuery struct {
+	Format      string `form:"format"`
+	PrinterID   int64  `form:"printer_id"`
+	TemplateID  int64  `form:"template_id"`
+	SubmittedBy string `form:"submitted_by"`
+	Namespace   string `form:"namespace"`
+	Status      string `form:"status"`
+	FromDate    string `form:"from_date"`
+	ToDate      string `form:"to_date"`
+	SortBy      string `form:"sort_by" binding:"omitempty,oneof=created_at completed_at priority status id"`
+	SortDir     string `form:"sort_dir" binding:"omitempty,oneof=asc desc ASC DESC"`
+}
+
+var jobExportHeader = []string{
+	"id", "printer", "template", "status", "copies", "submitted_by",
+	"created_at", "started_at", "completed_at", "duration_ms",
+}
+
+// ExportJobs streams the filtered job history as a CSV download. Rows are
+// written as they are read, so large exports are never buffered in full.
+func (h *JobHandler) ExportJobs(c *gin.Context) {
+	var query ExportJobsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Format == "" {
+		query.Format = "csv"
+	}
+	if query.Format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'csv'"})
+		return
+	}
+
+	filter := db.JobFilter{
+		PrinterID:   query.PrinterID,
+		TemplateID:  query.TemplateID,
+		SubmittedBy: query.SubmittedBy,
+		Namespace:   namespaceFilter(c, query.Namespace),
+		Status:      query.Status,
+		OrderBy:     query.SortBy,
+		OrderDir:    query.SortDir,
+	}
+	applyJobDateRange(&filter, query.FromDate, query.ToDate)
+
+	// Names are loaded up front because the job rows hold the database
+	// connection while they stream.
+	ctx := c.Request.Context()
+	printerNames := make(map[int64]string)
+	printers, err := db.Printers.ListPrinters(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list printers"})
+		return
+	}
+	for _, p := range printers {
+		printerNames[p.ID] = p.Name
+	}
+	templateNames := make(map[int64]string)
+	templates, err := db.Templates.ListTemplates(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list templates"})
+		return
+	}
+	for _, t := range templates {
+		templateNames[t.ID] = t.Name
+	}
+
+	w := csv.NewWriter(c.Writer)
+	started := false
+	start := func() error {
+		started = true
+		filename := fmt.Sprintf("jobs-%s.csv", time.Now().Format("20060102"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+		c.Status(http.StatusOK)
+		return w.Write(jobExportHeader)
+	}
+
+	rows := 0
+	err = db.Jobs.EachJob(ctx, filter, func(job *db.PrintJob) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := w.Write(h.jobExportRow(job, printerNames, templateNames)); err != nil {
+			return err
+		}
+		rows++
+		if rows%jobExportFlushRows == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+		return w.Error()
+	})
+	if err != nil {
+		if !started {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list jobs"})
+			return
+		}
+		// The status line has already been sent, so the download is cut
+		// short rather than replaced with an error.
+		log.Printf("job export: %v", err)
+		return
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			log.Printf("job export: %v", err)
+			return
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("job export: %v", err)
+	}
+}
+
+func (h *JobHandler) jobExportRow(job *db.PrintJob, printerNames, templateNames map[int64]string) []string {
+	var duration string
+	if job.StartedAt != nil && job.CompletedAt != nil {
+		duration = strconv.FormatInt(job.CompletedAt.Sub(*job.StartedAt).Milliseconds(), 10)
+	}
+
+	return []string{
+		strconv.FormatInt(job.ID, 10),
+		printerNames[job.PrinterID],
+		templateNames[job.TemplateID],
+		h.jobToResponse(job).Status,
+		strconv.Itoa(job.Copies),
+		job.SubmittedBy,
+		formatExportTime(&job.CreatedAt),
+		formatExportTime(job.StartedAt),
+		formatExportTime(job.CompletedAt),
+		duration,
+	}
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
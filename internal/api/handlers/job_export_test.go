@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/core"
+)
+
+func TestExportJobsWritesHeaderAndRows(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "Shipping")
+	templateID := insertTestTemplate(t, database, "Address", testLabelSchema)
+
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	started := created.Add(2 * time.Second)
+	completed := started.Add(1500 * time.Millisecond)
+	if _, err := database.Exec(`INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, copies, submitted_by, created_at, started_at, completed_at)
+		VALUES (?, ?, '{}', '', 'completed', 2, 'alice', ?, ?, ?)`, printerID, templateID, created, started, completed); err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+	insertTestJob(t, database, printerID, "failed")
+	router, _ := newJobRouter(t, database, nil)
+
+	w := serveJSON(router, http.MethodGet, "/api/jobs/export?status=completed", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type is %q, want text/csv", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "attachment") {
+		t.Errorf("Content-Disposition is %q, want an attachment", cd)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want the header and the completed job: %q", len(records), records)
+	}
+	if got, want := strings.Join(records[0], ","), strings.Join(jobExportHeader, ","); got != want {
+		t.Errorf("header is %q, want %q", got, want)
+	}
+	want := []string{
+		records[1][0], "Shipping", "Address", "completed", "2", "alice",
+		"2024-03-01T09:00:00Z", "2024-03-01T09:00:02Z", "2024-03-01T09:00:03Z", "1500",
+	}
+	if got := strings.Join(records[1], ","); got != strings.Join(want, ",") {
+		t.Errorf("row is %q, want %q", got, strings.Join(want, ","))
+	}
+}
+
+func TestExportJobsRejectsUnknownFormat(t *testing.T) {
+	router, _ := newJobRouter(t, setupTestDB(t), nil)
+
+	if w := serveJSON(router, http.MethodGet, "/api/jobs/export?format=xlsx", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("export as xlsx: %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestJobListsRejectUnknownSortOrder(t *testing.T) {
+	database := setupTestDB(t)
+	insertTestJob(t, database, insertTestPrinter(t, database, "printer"), string(core.JobStatusCompleted))
+	router, _ := newJobRouter(t, database, nil)
+
+	for _, path := range []string{"/api/jobs", "/api/jobs/export"} {
+		for _, query := range []string{
+			"sort_by=" + url.QueryEscape("(SELECT 1)"),
+			"sort_by=name",
+			"sort_dir=" + url.QueryEscape("DESC; DROP TABLE print_jobs"),
+		} {
+			if w := serveJSON(router, http.MethodGet, path+"?"+query, nil); w.Code != http.StatusBadRequest {
+				t.Errorf("GET %s?%s: %d %s, want 400", path, query, w.Code, w.Body)
+			}
+		}
+		if w := serveJSON(router, http.MethodGet, path+"?sort_by=completed_at&sort_dir=ASC", nil); w.Code != http.StatusOK {
+			t.Errorf("GET %s sorted by completed_at: %d %s, want 200", path, w.Code, w.Body)
+		}
+	}
+}
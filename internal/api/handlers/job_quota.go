@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/api/middleware"
+)
+
+// quotaExceededMessage is the error every route that enqueues jobs returns
+// once the caller's API key has used its submission quota.
+const quotaExceededMessage = "job submission quota exceeded"
+
+// errQuotaExceeded is returned from admission checks run inside the queue
+// when the caller's API key has no room for another job.
+var errQuotaExceeded = errors.New(quotaExceededMessage)
+
+// rejectOverQuota writes a 429 and returns true when the caller's API key
+// has no room in quota for n more jobs.
+func rejectOverQuota(c *gin.Context, quota *middleware.JobQuota, n int) bool {
+	if !overQuota(c, quota, n) {
+		return false
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": quotaExceededMessage})
+	return true
+}
+
+// overQuota counts n job submissions against the caller's API key and
+// reports whether they did not fit, setting Retry-After when they did not.
+// Requests without an API key, or with no quota configured, are not
+// counted.
+func overQuota(c *gin.Context, quota *middleware.JobQuota, n int) bool {
+	if quota == nil {
+		return false
+	}
+	apiKey := c.GetString("api_key")
+	if apiKey == "" {
+		return false
+	}
+	ok, retryAfter := quota.AllowN(apiKey, n)
+	if ok {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	return true
+}
+
+// refundQuota returns n submissions counted by rejectOverQuota or overQuota
+// to the caller's API key, for requests that did not create the jobs they
+// were counted for.
+func refundQuota(c *gin.Context, quota *middleware.JobQuota, n int) {
+	if quota == nil || n <= 0 {
+		return
+	}
+	if apiKey := c.GetString("api_key"); apiKey != "" {
+		quota.Refund(apiKey, n)
+	}
+}
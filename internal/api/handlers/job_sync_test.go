@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/orrn/spool/internal/core"
+)
+
+// offlinePrinterManager fails every print as the real manager does when it
+// cannot reach the printer.
+type offlinePrinterManager struct {
+	acceptingPrinterManager
+}
+
+func (offlinePrinterManager) Print(ctx context.Context, printerID int64, tsplContent string, copies int) error {
+	return core.ErrPrinterOffline
+}
+
+func TestSyncPrint(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		pm         core.PrinterManagerInterface
+		wantCode   int
+		wantStatus core.JobStatus
+	}{
+		{"printed", acceptingPrinterManager{}, http.StatusOK, core.JobStatusCompleted},
+		{"printer offline", offlinePrinterManager{}, http.StatusServiceUnavailable, core.JobStatusFailed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			database := setupTestDB(t)
+			printerID := insertTestPrinter(t, database, "printer")
+			templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+			queue := core.NewQueue(database, tt.pm, nil, nil, nil)
+			router := newTestRouter()
+			NewJobHandler(database, queue, core.NewTSPL2Generator()).RegisterRoutes(router.Group("/api"))
+
+			w := serveJSON(router, http.MethodPost, "/api/jobs/sync", map[string]any{
+				"printer_id": printerID, "template_id": templateID,
+				"variables": map[string]string{"name": "WIDGET"},
+			})
+			if w.Code != tt.wantCode {
+				t.Fatalf("sync print: %d %s, want %d", w.Code, w.Body, tt.wantCode)
+			}
+			var resp struct {
+				ID     int64          `json:"id"`
+				Status core.JobStatus `json:"status"`
+				Error  string         `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("response status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == core.JobStatusFailed && resp.Error == "" {
+				t.Error("failed sync print did not report the printer error")
+			}
+
+			var stored core.JobStatus
+			if err := database.QueryRow("SELECT status FROM print_jobs WHERE id = ?", resp.ID).Scan(&stored); err != nil {
+				t.Fatalf("read job %d: %v", resp.ID, err)
+			}
+			if stored != tt.wantStatus {
+				t.Errorf("stored job status = %q, want %q", stored, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/db"
+)
+
+// GetJobThumbnail returns the PNG thumbnail of the label a job printed,
+// stored after the job completed with queue.thumbnails enabled. Jobs
+// without a stored thumbnail respond with 404.
+func (h *JobHandler) GetJobThumbnail(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	job, err := db.Jobs.GetJobByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, job.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	thumbnail, err := db.Jobs.GetJobThumbnail(c.Request.Context(), job.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job has no thumbnail"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job thumbnail"})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", thumbnail)
+}
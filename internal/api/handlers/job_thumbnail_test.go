@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+)
+
+// acceptingPrinterManager reports every print as successful.
+type acceptingPrinterManager struct{}
+
+func (acceptingPrinterManager) Print(ctx context.Context, printerID int64, tsplContent string, copies int) error {
+	return nil
+}
+
+func (acceptingPrinterManager) SendCommand(ctx context.Context, printerID int64, tspl string) error {
+	return nil
+}
+
+func (acceptingPrinterManager) GetPrinter(printerID int64) (*core.Printer, error) {
+	return &core.Printer{ID: printerID, Status: "online"}, nil
+}
+
+func (acceptingPrinterManager) IncrementPrintCount(printerID int64, count int) error {
+	return nil
+}
+
+// newThumbnailQueue starts a queue that prints every job successfully, with
+// thumbnails on or off, and returns it with a router serving the job routes.
+func newThumbnailQueue(t *testing.T, database *sql.DB, thumbnails bool) (*core.Queue, *gin.Engine) {
+	t.Helper()
+
+	queue := core.NewQueue(database, acceptingPrinterManager{}, nil, nil, &config.QueueConfig{
+		WorkerCount:   1,
+		Thumbnails:    thumbnails,
+		ThumbnailSize: 100,
+	})
+	if err := queue.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	t.Cleanup(queue.Stop)
+
+	router := newTestRouter()
+	NewJobHandler(database, queue, core.NewTSPL2Generator()).RegisterRoutes(router.Group("/api"))
+	return queue, router
+}
+
+// enqueueLabel queues a 50x30 mm label with a frame and returns its job ID.
+func enqueueLabel(t *testing.T, queue *core.Queue, printerID int64) int64 {
+	t.Helper()
+
+	jobID, err := queue.Enqueue(&core.Job{
+		PrinterID:   printerID,
+		TSPLContent: "SIZE 50 mm, 30 mm\r\nCLS\r\nBOX 10,10,380,220,4\r\nPRINT 1\r\n",
+		Copies:      1,
+	})
+	if err != nil {
+		t.Fatalf("enqueue job: %v", err)
+	}
+	return jobID
+}
+
+// waitForCompletion waits until the job has completed.
+func waitForCompletion(t *testing.T, database *sql.DB, jobID int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var status string
+		if err := database.QueryRow("SELECT status FROM print_jobs WHERE id = ?", jobID).Scan(&status); err != nil {
+			t.Fatalf("get job status: %v", err)
+		}
+		if status == string(core.JobStatusCompleted) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s, want completed", jobID, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGetJobThumbnail(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	queue, router := newThumbnailQueue(t, database, true)
+	jobID := enqueueLabel(t, queue, printerID)
+	waitForCompletion(t, database, jobID)
+
+	// Thumbnails are stored in the background after the job completes.
+	path := fmt.Sprintf("/api/jobs/%d/thumbnail", jobID)
+	var w *httptest.ResponseRecorder
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		w = serveJSON(router, http.MethodGet, path, nil)
+		if w.Code != http.StatusNotFound || time.Now().After(deadline) {
+			break
+		}
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("get thumbnail: %d %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("content type %q, want image/png", ct)
+	}
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+
+	// The 50x30 mm label is 399x239 dots at 203 DPI.
+	if got, want := img.Bounds().Size(), image.Pt(100, 59); got != want {
+		t.Errorf("thumbnail is %v, want %v", got, want)
+	}
+	if !hasInk(img) {
+		t.Error("thumbnail is blank, want the label's frame")
+	}
+
+	missing := fmt.Sprintf("/api/jobs/%d/thumbnail", jobID+1000)
+	if w := serveJSON(router, http.MethodGet, missing, nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET %s: %d, want 404", missing, w.Code)
+	}
+}
+
+func TestJobThumbnailNotStored(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	queue, router := newThumbnailQueue(t, database, false)
+	jobID := enqueueLabel(t, queue, printerID)
+	waitForCompletion(t, database, jobID)
+
+	path := fmt.Sprintf("/api/jobs/%d/thumbnail", jobID)
+	if w := serveJSON(router, http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET %s with thumbnails off: %d, want 404", path, w.Code)
+	}
+}
+
+// hasInk reports whether any pixel of img is darker than mid grey.
+func hasInk(img image.Image) bool {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r < 0x8000 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/db"
+)
+
+// GetJobTSPL downloads the TSPL a job generated, as stored when it was
+// sent, for audit. Jobs whose TSPL was never stored or has been cleared
+// by database.tspl_retention_days respond with 404.
+func (h *JobHandler) GetJobTSPL(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	job, err := db.Jobs.GetJobByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, job.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	if job.TSPLContent == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job has no stored TSPL"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="job-%d.tspl"`, job.ID))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(job.TSPLContent))
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+)
+
+func TestGetJobTSPL(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	router, _ := newJobRouter(t, database, nil)
+
+	tspl := "SIZE 50 mm, 30 mm\nCLS\nTEXT 10,10,\"3\",0,1,1,\"Ünïcode\"\nPRINT 1\n"
+	jobID := insertTestJob(t, database, printerID, "completed")
+	if _, err := database.Exec("UPDATE print_jobs SET tspl_content = ? WHERE id = ?", tspl, jobID); err != nil {
+		t.Fatalf("store tspl: %v", err)
+	}
+
+	w := serveJSON(router, http.MethodGet, fmt.Sprintf("/api/jobs/%d/tspl", jobID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get tspl: %d %s", w.Code, w.Body)
+	}
+	if got := w.Body.String(); got != tspl {
+		t.Errorf("tspl = %q, want %q", got, tspl)
+	}
+	if got, want := w.Header().Get("Content-Disposition"), fmt.Sprintf(`attachment; filename="job-%d.tspl"`, jobID); got != want {
+		t.Errorf("Content-Disposition = %q, want %q", got, want)
+	}
+
+	w = serveJSON(router, http.MethodGet, "/api/jobs/999999/tspl", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("tspl of an unknown job: %d %s, want 404", w.Code, w.Body)
+	}
+}
+
+func TestPurgeTSPLClearsOnlyOldFinishedJobs(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	router, _ := newJobRouter(t, database, nil)
+
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+
+	finishedJob := func(status, age string, templateID int64) int64 {
+		id := insertTestJob(t, database, printerID, status)
+		if _, err := database.Exec("UPDATE print_jobs SET completed_at = datetime('now', ?), template_id = ? WHERE id = ?", age, templateID, id); err != nil {
+			t.Fatalf("age job: %v", err)
+		}
+		return id
+	}
+	oldCompleted := finishedJob("completed", "-40 days", templateID)
+	oldCancelled := finishedJob("cancelled", "-40 days", templateID)
+	oldFailed := finishedJob("failed", "-40 days", templateID)
+	recentCompleted := finishedJob("completed", "-5 days", templateID)
+	// A raw TSPL job has no template to regenerate its TSPL from.
+	oldRaw := finishedJob("completed", "-40 days", 0)
+
+	// Off by default.
+	if purged, err := core.NewRetentionWorker(&config.DatabaseConfig{}).PurgeTSPL(context.Background()); err != nil || purged != 0 {
+		t.Fatalf("purge without tspl_retention_days cleared %d jobs (err %v), want none", purged, err)
+	}
+
+	worker := core.NewRetentionWorker(&config.DatabaseConfig{TSPLRetentionDays: 30})
+	purged, err := worker.PurgeTSPL(context.Background())
+	if err != nil {
+		t.Fatalf("purge tspl: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("purge cleared %d jobs, want 2", purged)
+	}
+
+	for id, kept := range map[int64]bool{
+		oldCompleted:    false,
+		oldCancelled:    false,
+		oldFailed:       true,
+		recentCompleted: true,
+		oldRaw:          true,
+	} {
+		var tspl string
+		if err := database.QueryRow("SELECT tspl_content FROM print_jobs WHERE id = ?", id).Scan(&tspl); err != nil {
+			t.Fatalf("job %d was deleted by the purge: %v", id, err)
+		}
+		if kept != (tspl != "") {
+			t.Errorf("job %d has tspl %q, want kept %v", id, tspl, kept)
+		}
+		w := serveJSON(router, http.MethodGet, fmt.Sprintf("/api/jobs/%d/tspl", id), nil)
+		if want := map[bool]int{true: http.StatusOK, false: http.StatusNotFound}[kept]; w.Code != want {
+			t.Errorf("tspl of job %d: %d, want %d", id, w.Code, want)
+		}
+	}
+}
@@ -1,25 +1,53 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
-	"orrn-spool/internal/core"
-	"orrn-spool/internal/db"
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/logging"
 )
 
+// CreateJobRequest targets either a specific printer or, via PrinterGroup,
+// whichever printer in that group the queue selects.
 type CreateJobRequest struct {
-	PrinterID  int64             `json:"printer_id" binding:"required"`
-	TemplateID int64             `json:"template_id" binding:"required"`
-	Variables  map[string]string `json:"variables" binding:"required"`
-	Copies     int               `json:"copies"`
-	Priority   int               `json:"priority"`
+	PrinterID    int64             `json:"printer_id"`
+	PrinterGroup string            `json:"printer_group"`
+	TemplateID   int64             `json:"template_id" binding:"required"`
+	Variables    map[string]string `json:"variables" binding:"required"`
+	Copies       int               `json:"copies"`
+	Priority     *int              `json:"priority"`
+	RunAt        *time.Time        `json:"run_at"`
+}
+
+type CancelJobsRequest struct {
+	PrinterID int64  `json:"printer_id"`
+	Status    string `json:"status" binding:"omitempty,oneof=pending paused"`
+}
+
+// RetryFailedJobsRequest selects the failed jobs to requeue: those for
+// PrinterID, or every printer when it is zero, that failed between From and
+// To.
+type RetryFailedJobsRequest struct {
+	PrinterID int64      `json:"printer_id"`
+	From      *time.Time `json:"from"`
+	To        *time.Time `json:"to"`
+}
+
+type VerifyScanRequest struct {
+	ScannedValue string `json:"scanned_value" binding:"required"`
+	Variable     string `json:"variable"`
 }
 
 type JobResponse struct {
@@ -28,6 +56,7 @@ type JobResponse struct {
 	PrinterName  string            `json:"printer_name,omitempty"`
 	TemplateID   int64             `json:"template_id"`
 	TemplateName string            `json:"template_name,omitempty"`
+	Raw          bool              `json:"raw,omitempty"`
 	Variables    map[string]string `json:"variables"`
 	TSPLContent  string            `json:"tspl_content,omitempty"`
 	Status       string            `json:"status"`
@@ -36,21 +65,32 @@ type JobResponse struct {
 	ErrorMessage string            `json:"error_message,omitempty"`
 	Copies       int               `json:"copies"`
 	SubmittedBy  string            `json:"submitted_by"`
+	Namespace    string            `json:"namespace,omitempty"`
 	CreatedAt    time.Time         `json:"created_at"`
 	StartedAt    *time.Time        `json:"started_at,omitempty"`
 	CompletedAt  *time.Time        `json:"completed_at,omitempty"`
+	ScheduledAt  *time.Time        `json:"scheduled_at,omitempty"`
+	Verification string            `json:"verification_status,omitempty"`
+	ScannedValue string            `json:"scanned_value,omitempty"`
+	VerifiedAt   *time.Time        `json:"verified_at,omitempty"`
 	Duration     *int64            `json:"duration_ms,omitempty"`
+	// QueuePosition is set by GetJob for pending jobs: 1 when the job is
+	// next for its printer.
+	QueuePosition *int `json:"queue_position,omitempty"`
 }
 
 type ListJobsQuery struct {
-	PrinterID int64  `form:"printer_id"`
-	Status    string `form:"status"`
-	FromDate  string `form:"from_date"`
-	ToDate    string `form:"to_date"`
-	Limit     int    `form:"limit" binding:"max=100"`
-	Offset    int    `form:"offset"`
-	SortBy    string `form:"sort_by"`
-	SortDir   string `form:"sort_dir"`
+	PrinterID   int64  `form:"printer_id"`
+	TemplateID  int64  `form:"template_id"`
+	SubmittedBy string `form:"submitted_by"`
+	Namespace   string `form:"namespace"`
+	Status      string `form:"status"`
+	FromDate    string `form:"from_date"`
+	ToDate      string `form:"to_date"`
+	Limit       int    `form:"limit" binding:"max=100"`
+	Offset      int    `form:"offset"`
+	SortBy      string `form:"sort_by" binding:"omitempty,oneof=created_at completed_at priority status id"`
+	SortDir     string `form:"sort_dir" binding:"omitempty,oneof=asc desc ASC DESC"`
 }
 
 type QueueResponse struct {
@@ -88,6 +128,7 @@ type JobHandler struct {
 	db            *sql.DB
 	queue         *core.Queue
 	tsplGenerator *core.TSPL2Generator
+	quota         *middleware.JobQuota
 }
 
 func NewJobHandler(database *sql.DB, queue *core.Queue, tsplGenerator *core.TSPL2Generator) *JobHandler {
@@ -98,6 +139,13 @@ func NewJobHandler(database *sql.DB, queue *core.Queue, tsplGenerator *core.TSPL
 	}
 }
 
+// SetQuota enables per-API-key submission limits on job creation, legacy
+// prints, reprints and dead-letter requeues. Requests that are not
+// authenticated with an API key are not counted.
+func (h *JobHandler) SetQuota(quota *middleware.JobQuota) {
+	h.quota = quota
+}
+
 func (h *JobHandler) CreateJob(c *gin.Context) {
 	var req CreateJobRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -105,50 +153,227 @@ func (h *JobHandler) CreateJob(c *gin.Context) {
 		return
 	}
 
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	prepared, ok := h.prepareJob(c, &req, false)
+	if !ok {
+		return
+	}
+	job := prepared.job
+	if req.RunAt != nil && req.RunAt.After(time.Now()) {
+		scheduledAt := req.RunAt.UTC()
+		job.ScheduledAt = &scheduledAt
+	}
+
+	jobID, err := h.queue.Enqueue(job)
+	if err != nil {
+		refundQuota(c, h.quota, 1)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enqueue job"})
+		return
+	}
+
+	response := gin.H{
+		"id":         jobID,
+		"printer_id": job.PrinterID,
+		"message":    "job submitted successfully",
+	}
+	if job.ScheduledAt != nil {
+		response["scheduled_at"] = job.ScheduledAt
+		response["message"] = "job scheduled successfully"
+	}
+	if prepared.sizeWarning != "" {
+		response["warning"] = prepared.sizeWarning
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// SyncPrint prints a job straight away instead of queueing it and responds
+// once the printer has printed or refused it, for integrations that must
+// know the label came out. The job is still recorded in the history.
+func (h *JobHandler) SyncPrint(c *gin.Context) {
+	var req CreateJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RunAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "run_at cannot be used with synchronous printing"})
+		return
+	}
+
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	// The printer's recorded status may be stale, so an offline printer is
+	// tried anyway and the caller gets the printer's own error.
+	prepared, ok := h.prepareJob(c, &req, true)
+	if !ok {
+		return
+	}
+	job := prepared.job
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.queue.SyncPrintTimeout())
+	defer cancel()
+
+	jobID, err := h.queue.PrintNow(ctx, job)
+	if err != nil {
+		if jobID == 0 {
+			refundQuota(c, h.quota, 1)
+		}
+		status := http.StatusBadGateway
+		switch {
+		case jobID == 0 && errors.Is(err, core.ErrPrinterPaused):
+			status = http.StatusConflict
+		case jobID == 0:
+			status = http.StatusInternalServerError
+		case errors.Is(err, context.DeadlineExceeded):
+			status = http.StatusGatewayTimeout
+		case errors.Is(err, core.ErrPrinterOffline), errors.Is(err, core.ErrConnectionFailed), errors.Is(err, core.ErrTimeout):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, core.ErrPrinterBusy), errors.Is(err, core.ErrPrinterCannotPrint):
+			status = http.StatusConflict
+		}
+		response := gin.H{"error": err.Error()}
+		if jobID != 0 {
+			response["id"] = jobID
+			response["status"] = core.JobStatusFailed
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	response := gin.H{
+		"id":         jobID,
+		"printer_id": job.PrinterID,
+		"status":     core.JobStatusCompleted,
+		"message":    "job printed",
+	}
+	if prepared.sizeWarning != "" {
+		response["warning"] = prepared.sizeWarning
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// preparedJob is a validated job request ready to submit. The job carries
+// the TSPL generated while validating it, so the worker does not generate
+// it again.
+type preparedJob struct {
+	job         *core.Job
+	sizeWarning string
+}
+
+// prepareJob does the checks CreateJob and SyncPrint share: it resolves the
+// printer, validates the template and variables, generates the TSPL and
+// applies the submission quota, which the caller refunds if the job is then
+// not created. It writes an error response and returns
+// false when the job cannot be submitted. Printers recorded as offline are
+// rejected unless allowOffline is set.
+func (h *JobHandler) prepareJob(c *gin.Context, req *CreateJobRequest, allowOffline bool) (*preparedJob, bool) {
 	if req.Copies <= 0 {
 		req.Copies = 1
 	}
+	if rejectTooManyCopies(c, h.queue, req.Copies) {
+		return nil, false
+	}
+
+	if (req.PrinterID == 0) == (req.PrinterGroup == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of printer_id or printer_group is required"})
+		return nil, false
+	}
+	if req.PrinterGroup != "" {
+		printerID, err := h.queue.SelectGroupPrinter(req.PrinterGroup)
+		switch {
+		case err == core.ErrPrinterGroupEmpty:
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no printers in group %q", req.PrinterGroup)})
+			return nil, false
+		case err == core.ErrNoPrinterAvailable:
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("no online printer in group %q", req.PrinterGroup)})
+			return nil, false
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to select printer"})
+			return nil, false
+		}
+		req.PrinterID = printerID
+	}
 
 	printer, err := db.Printers.GetPrinterByID(c.Request.Context(), req.PrinterID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "printer not found"})
-			return
+			return nil, false
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get printer"})
-		return
+		return nil, false
 	}
 
-	if printer.Status == "paused" || printer.Status == "offline" {
+	if printer.Status == "paused" || (printer.Status == "offline" && !allowOffline) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("printer is %s", printer.Status)})
-		return
+		return nil, false
 	}
 
 	template, err := db.Templates.GetTemplateByID(c.Request.Context(), req.TemplateID)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
-			return
+			return nil, false
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get template"})
-		return
+		return nil, false
+	}
+
+	sizeWarning, err := h.queue.CheckLabelSize(template.WidthMM, template.HeightMM, printer.LabelWidthMM, printer.LabelHeightMM)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
 	}
 
 	schema, err := h.tsplGenerator.ParseSchema(template.SchemaJSON)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid template schema"})
-		return
+		return nil, false
 	}
 
+	if err := h.tsplGenerator.CheckLockedVariables(schema, req.Variables); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	req.Variables = h.tsplGenerator.ApplyServerVariables(schema, req.Variables)
+
 	if err := h.tsplGenerator.ValidateVariables(schema, req.Variables); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return nil, false
+	}
+
+	tsplContent, err := h.tsplGenerator.Generate(schema, req.Variables)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to generate TSPL: %v", err)})
+		return nil, false
+	}
+	if err := h.queue.CheckTSPLSize(tsplContent); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return nil, false
 	}
 
 	variablesJSON, err := json.Marshal(req.Variables)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to serialize variables"})
-		return
+		return nil, false
+	}
+
+	if rejectOverQuota(c, h.quota, 1) {
+		return nil, false
+	}
+
+	priority := h.queue.DefaultPriority(core.JobSourceAPI)
+	if req.Priority != nil {
+		priority = *req.Priority
 	}
 
 	clientIP := c.ClientIP()
@@ -157,22 +382,16 @@ func (h *JobHandler) CreateJob(c *gin.Context) {
 		PrinterID:     req.PrinterID,
 		TemplateID:    req.TemplateID,
 		VariablesJSON: string(variablesJSON),
-		Priority:      req.Priority,
+		TSPLContent:   tsplContent,
+		Priority:      priority,
 		Copies:        req.Copies,
 		SubmittedBy:   clientIP,
+		RequestID:     logging.RequestID(c.Request.Context()),
+		Namespace:     template.Namespace,
 		Status:        core.JobStatusPending,
 	}
 
-	jobID, err := h.queue.Enqueue(job)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enqueue job"})
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{
-		"id":      jobID,
-		"message": "job submitted successfully",
-	})
+	return &preparedJob{job: job, sizeWarning: sizeWarning}, true
 }
 
 func (h *JobHandler) ListJobs(c *gin.Context) {
@@ -190,27 +409,18 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 	}
 
 	filter := db.JobFilter{
-		PrinterID: query.PrinterID,
-		Status:    query.Status,
-		Limit:     query.Limit,
-		Offset:    query.Offset,
-		OrderBy:   query.SortBy,
-		OrderDir:  query.SortDir,
+		PrinterID:   query.PrinterID,
+		TemplateID:  query.TemplateID,
+		SubmittedBy: query.SubmittedBy,
+		Namespace:   namespaceFilter(c, query.Namespace),
+		Status:      query.Status,
+		Limit:       query.Limit,
+		Offset:      query.Offset,
+		OrderBy:     query.SortBy,
+		OrderDir:    query.SortDir,
 	}
 
-	if query.FromDate != "" {
-		t, err := time.Parse("2006-01-02", query.FromDate)
-		if err == nil {
-			filter.FromDate = &t
-		}
-	}
-	if query.ToDate != "" {
-		t, err := time.Parse("2006-01-02", query.ToDate)
-		if err == nil {
-			endOfDay := t.Add(24*time.Hour - time.Second)
-			filter.ToDate = &endOfDay
-		}
-	}
+	applyJobDateRange(&filter, query.FromDate, query.ToDate)
 
 	jobs, err := db.Jobs.ListJobs(c.Request.Context(), filter)
 	if err != nil {
@@ -218,17 +428,38 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 		return
 	}
 
+	total, err := db.Jobs.CountJobs(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count jobs"})
+		return
+	}
+
+	responses := h.jobsToResponses(c.Request.Context(), jobs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":     responses,
+		"limit":    query.Limit,
+		"offset":   query.Offset,
+		"count":    len(responses),
+		"total":    total,
+		"has_more": int64(query.Offset+len(responses)) < total,
+	})
+}
+
+// jobsToResponses converts jobs to responses named after their printer and
+// template, looking each name up once.
+func (h *JobHandler) jobsToResponses(ctx context.Context, jobs []*db.PrintJob) []JobResponse {
 	printerNames := make(map[int64]string)
 	templateNames := make(map[int64]string)
 
 	for _, job := range jobs {
 		if _, ok := printerNames[job.PrinterID]; !ok {
-			if p, err := db.Printers.GetPrinterByID(c.Request.Context(), job.PrinterID); err == nil {
+			if p, err := db.Printers.GetPrinterByID(ctx, job.PrinterID); err == nil {
 				printerNames[job.PrinterID] = p.Name
 			}
 		}
 		if _, ok := templateNames[job.TemplateID]; !ok {
-			if t, err := db.Templates.GetTemplateByID(c.Request.Context(), job.TemplateID); err == nil {
+			if t, err := db.Templates.GetTemplateByID(ctx, job.TemplateID); err == nil {
 				templateNames[job.TemplateID] = t.Name
 			}
 		}
@@ -241,13 +472,25 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 		resp.TemplateName = templateNames[job.TemplateID]
 		responses = append(responses, resp)
 	}
+	return responses
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"jobs":   responses,
-		"limit":  query.Limit,
-		"offset": query.Offset,
-		"count":  len(responses),
-	})
+// applyJobDateRange restricts filter to jobs created between the from and to
+// dates (YYYY-MM-DD, inclusive). Unparseable dates are ignored.
+func applyJobDateRange(filter *db.JobFilter, from, to string) {
+	if from != "" {
+		t, err := time.Parse("2006-01-02", from)
+		if err == nil {
+			filter.FromDate = &t
+		}
+	}
+	if to != "" {
+		t, err := time.Parse("2006-01-02", to)
+		if err == nil {
+			endOfDay := t.Add(24*time.Hour - time.Second)
+			filter.ToDate = &endOfDay
+		}
+	}
 }
 
 func (h *JobHandler) GetJob(c *gin.Context) {
@@ -259,6 +502,9 @@ func (h *JobHandler) GetJob(c *gin.Context) {
 	}
 
 	job, err := db.Jobs.GetJobByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, job.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
@@ -282,6 +528,12 @@ func (h *JobHandler) GetJob(c *gin.Context) {
 		resp.Duration = &duration
 	}
 
+	if resp.Status == "pending" {
+		if position, err := h.queue.QueuePosition(id); err == nil && position > 0 {
+			resp.QueuePosition = &position
+		}
+	}
+
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -294,6 +546,9 @@ func (h *JobHandler) DeleteJob(c *gin.Context) {
 	}
 
 	job, err := db.Jobs.GetJobByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, job.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
@@ -313,6 +568,8 @@ func (h *JobHandler) DeleteJob(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "delete", "job", id, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "job deleted"})
 }
 
@@ -324,14 +581,193 @@ func (h *JobHandler) CancelJob(c *gin.Context) {
 		return
 	}
 
+	if !jobVisible(c, id) {
+		return
+	}
+
 	if err := h.queue.CancelJob(id); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, "cancel", "job", id, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "job cancelled"})
 }
 
+func (h *JobHandler) CancelJobs(c *gin.Context) {
+	var req CancelJobsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.PrinterID == 0 && req.Status == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "printer_id or status is required"})
+		return
+	}
+
+	cancelled, err := h.queue.CancelJobs(req.PrinterID, core.JobStatus(req.Status), namespaceFilter(c, ""))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel jobs"})
+		return
+	}
+
+	recordAudit(c, "bulk_cancel", "job", 0, gin.H{
+		"printer_id": req.PrinterID,
+		"status":     req.Status,
+		"cancelled":  cancelled,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"cancelled": cancelled,
+		"message":   fmt.Sprintf("%d jobs cancelled", cancelled),
+	})
+}
+
+// RetryFailedJobs requeues the failed jobs matching the request, typically
+// those that failed while a printer was down. Like the printer-scoped
+// retry-failed, retry counts are reset and permanent failures are skipped.
+func (h *JobHandler) RetryFailedJobs(c *gin.Context) {
+	var req RetryFailedJobsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.From != nil && req.To != nil && req.To.Before(*req.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	if req.PrinterID != 0 {
+		if _, err := db.Printers.GetPrinterByID(c.Request.Context(), req.PrinterID); err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "printer not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get printer"})
+			return
+		}
+	}
+
+	requeued, err := h.queue.RetryFailed(req.PrinterID, namespaceFilter(c, ""), req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retry jobs"})
+		return
+	}
+
+	recordAudit(c, "bulk_retry", "job", 0, gin.H{
+		"printer_id": req.PrinterID,
+		"from":       req.From,
+		"to":         req.To,
+		"requeued":   requeued,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"requeued": requeued,
+		"message":  fmt.Sprintf("%d jobs queued for retry", requeued),
+	})
+}
+
+// VerifyScan compares a value scanned from the printed label against the
+// content the job was expected to encode and records the outcome. By default
+// the first barcode-like element of the template is used; a specific
+// variable can be named instead.
+func (h *JobHandler) VerifyScan(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	var req VerifyScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := db.Jobs.GetJobByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, job.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	if job.Status != string(core.JobStatusCompleted) {
+		c.JSON(http.StatusConflict, gin.H{"error": "only completed jobs can be verified"})
+		return
+	}
+
+	variables := make(map[string]string)
+	if job.VariablesJSON != "" {
+		if err := json.Unmarshal([]byte(job.VariablesJSON), &variables); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid job variables"})
+			return
+		}
+	}
+
+	var expected string
+	if req.Variable != "" {
+		value, ok := variables[req.Variable]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variable '%s' not found on job", req.Variable)})
+			return
+		}
+		expected = value
+	} else {
+		template, err := db.Templates.GetTemplateByID(c.Request.Context(), job.TemplateID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get template"})
+			return
+		}
+		schema, err := h.tsplGenerator.ParseSchema(template.SchemaJSON)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid template schema"})
+			return
+		}
+		content, ok, err := h.tsplGenerator.ScanContent(schema, variables)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute expected content: " + err.Error()})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "template has no scannable element"})
+			return
+		}
+		expected = content
+	}
+
+	matched := strings.TrimSpace(req.ScannedValue) == expected
+	coreJob := &core.Job{ID: job.ID, PrinterID: job.PrinterID}
+	if err := h.queue.RecordScan(coreJob, req.ScannedValue, matched); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := "verified"
+	if !matched {
+		status = "mismatch"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":                  job.ID,
+		"verification_status": status,
+		"expected":            expected,
+		"scanned_value":       req.ScannedValue,
+	})
+}
+
 func (h *JobHandler) RetryJob(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -340,11 +776,21 @@ func (h *JobHandler) RetryJob(c *gin.Context) {
 		return
 	}
 
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	if !jobVisible(c, id) {
+		return
+	}
+
 	if err := h.queue.RetryJob(id); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, "retry", "job", id, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "job queued for retry"})
 }
 
@@ -356,12 +802,27 @@ func (h *JobHandler) ReprintJob(c *gin.Context) {
 		return
 	}
 
-	newJobID, err := h.queue.ReprintJob(id)
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	if !jobVisible(c, id) {
+		return
+	}
+
+	if rejectOverQuota(c, h.quota, 1) {
+		return
+	}
+
+	newJobID, err := h.queue.ReprintJob(c.Request.Context(), id)
 	if err != nil {
+		refundQuota(c, h.quota, 1)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, "reprint", "job", id, gin.H{"new_job_id": newJobID})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "job reprinted",
 		"new_job_id": newJobID,
@@ -376,6 +837,10 @@ func (h *JobHandler) PauseJob(c *gin.Context) {
 		return
 	}
 
+	if !jobVisible(c, id) {
+		return
+	}
+
 	if err := h.queue.PauseJob(id); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -392,6 +857,10 @@ func (h *JobHandler) ResumeJob(c *gin.Context) {
 		return
 	}
 
+	if !jobVisible(c, id) {
+		return
+	}
+
 	if err := h.queue.ResumeJob(id); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -400,6 +869,69 @@ func (h *JobHandler) ResumeJob(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "job resumed"})
 }
 
+// PromoteJob moves a pending job to the front of the queue.
+func (h *JobHandler) PromoteJob(c *gin.Context) {
+	h.reorderJob(c, "promote", h.queue.PromoteJob)
+}
+
+// DemoteJob moves a pending job to the back of the queue.
+func (h *JobHandler) DemoteJob(c *gin.Context) {
+	h.reorderJob(c, "demote", h.queue.DemoteJob)
+}
+
+func (h *JobHandler) reorderJob(c *gin.Context, action string, reorder func(int64) error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	if !jobVisible(c, id) {
+		return
+	}
+
+	if err := reorder(id); err != nil {
+		if err == core.ErrJobNotPending {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.queue.GetJob(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	recordAudit(c, action, "job", id, gin.H{"priority": job.Priority})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "job " + action + "d",
+		"priority": job.Priority,
+	})
+}
+
+// jobVisible responds with 404 and returns false when the job does not exist
+// or is outside the caller's namespace.
+func jobVisible(c *gin.Context, id int64) bool {
+	job, err := db.Jobs.GetJobByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, job.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return false
+	}
+	return true
+}
+
 func (h *JobHandler) GetQueue(c *gin.Context) {
 	stats := h.queue.GetStats()
 
@@ -415,6 +947,41 @@ func (h *JobHandler) GetQueue(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+type UpcomingJobsQuery struct {
+	Namespace string `form:"namespace"`
+	Limit     int    `form:"limit"`
+}
+
+// GetUpcomingJobs lists the pending jobs that are due in the order the
+// queue will dispatch them, so the first is the next to print. Scheduled
+// jobs appear once their time has come.
+func (h *JobHandler) GetUpcomingJobs(c *gin.Context) {
+	var query UpcomingJobsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	if query.Limit > 100 {
+		query.Limit = 100
+	}
+
+	jobs, err := db.Jobs.ListUpcomingJobs(c.Request.Context(), namespaceFilter(c, query.Namespace), query.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list upcoming jobs"})
+		return
+	}
+
+	responses := h.jobsToResponses(c.Request.Context(), jobs)
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  responses,
+		"limit": query.Limit,
+		"count": len(responses),
+	})
+}
+
 func (h *JobHandler) GetJobStats(c *gin.Context) {
 	ctx := c.Request.Context()
 	now := time.Now()
@@ -512,7 +1079,14 @@ func (h *JobHandler) LegacyPrintHandler(c *gin.Context) {
 		return
 	}
 
+	if rejectIfMaintenance(c) {
+		return
+	}
+
 	template, err := db.Templates.GetTemplateByName(c.Request.Context(), layout)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("template '%s' not found", layout)})
@@ -532,7 +1106,13 @@ func (h *JobHandler) LegacyPrintHandler(c *gin.Context) {
 		return
 	}
 
+	if err := h.tsplGenerator.CheckLockedVariables(schema, variables); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	variables = h.tsplGenerator.MergeVariablesWithDefaults(schema, variables)
+	variables = h.tsplGenerator.ApplyServerVariables(schema, variables)
 
 	if err := h.tsplGenerator.ValidateVariables(schema, variables); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -572,23 +1152,50 @@ func (h *JobHandler) LegacyPrintHandler(c *gin.Context) {
 		TemplateID:    template.ID,
 		VariablesJSON: string(variablesJSON),
 		Copies:        1,
+		Priority:      h.queue.DefaultPriority(core.JobSourceLegacy),
 		SubmittedBy:   clientIP,
+		RequestID:     logging.RequestID(c.Request.Context()),
+		Namespace:     template.Namespace,
 		Status:        core.JobStatusPending,
 	}
 
-	jobID, err := h.queue.Enqueue(job)
+	// The quota is charged only once the queue knows the request is not a
+	// repeat, so scanner double-fires neither use it up nor get 429.
+	jobID, duplicate, err := h.queue.EnqueueLegacy(layout, uid, job, func() error {
+		if overQuota(c, h.quota, 1) {
+			return errQuotaExceeded
+		}
+		return nil
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": quotaExceededMessage})
+		return
+	}
 	if err != nil {
+		refundQuota(c, h.quota, 1)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit job"})
 		return
 	}
 
+	message := "print job submitted"
+	if duplicate {
+		message = "duplicate request, returning the recent job"
+		for _, p := range printers {
+			if p.ID == job.PrinterID {
+				printer = p
+				break
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"job_id":      jobID,
-		"printer":     printer.Name,
-		"template":    template.Name,
-		"uid":         uid,
-		"status":      "queued",
-		"message":     "print job submitted",
+		"job_id":    jobID,
+		"printer":   printer.Name,
+		"template":  template.Name,
+		"uid":       uid,
+		"status":    "queued",
+		"duplicate": duplicate,
+		"message":   message,
 	})
 }
 
@@ -601,38 +1208,66 @@ func (h *JobHandler) jobToResponse(job *db.PrintJob) JobResponse {
 		variables = make(map[string]string)
 	}
 
+	status := job.Status
+	if status == "pending" && job.ScheduledAt != nil && job.ScheduledAt.After(time.Now()) {
+		status = "scheduled"
+	}
+
 	return JobResponse{
 		ID:           job.ID,
 		PrinterID:    job.PrinterID,
 		TemplateID:   job.TemplateID,
+		Raw:          job.TemplateID == 0,
 		Variables:    variables,
 		TSPLContent:  job.TSPLContent,
-		Status:       job.Status,
+		Status:       status,
 		Priority:     job.Priority,
 		RetryCount:   job.RetryCount,
 		ErrorMessage: job.ErrorMessage,
 		Copies:       job.Copies,
 		SubmittedBy:  job.SubmittedBy,
+		Namespace:    job.Namespace,
 		CreatedAt:    job.CreatedAt,
 		StartedAt:    job.StartedAt,
 		CompletedAt:  job.CompletedAt,
+		ScheduledAt:  job.ScheduledAt,
+		Verification: job.VerificationStatus,
+		ScannedValue: job.ScannedValue,
+		VerifiedAt:   job.VerifiedAt,
 	}
 }
 
 func (h *JobHandler) RegisterRoutes(r *gin.RouterGroup) {
+	operator := middleware.RequireRole(middleware.RoleOperator)
 	r.GET("/jobs", h.ListJobs)
-	r.POST("/jobs", h.CreateJob)
+	r.POST("/jobs", operator, h.CreateJob)
+	r.POST("/jobs/sync", operator, h.SyncPrint)
 	r.GET("/jobs/queue", h.GetQueue)
+	r.GET("/jobs/queue/upcoming", h.GetUpcomingJobs)
 	r.GET("/jobs/stats", h.GetJobStats)
+	r.GET("/jobs/export", h.ExportJobs)
+	r.POST("/jobs/cancel", operator, h.CancelJobs)
+	r.POST("/jobs/retry-failed", operator, h.RetryFailedJobs)
+	r.GET("/jobs/dead-letter", h.ListDeadLetterJobs)
+	r.POST("/jobs/dead-letter/:id/requeue", operator, h.RequeueDeadLetterJob)
+	r.DELETE("/jobs/dead-letter/:id", operator, h.DeleteDeadLetterJob)
 	r.GET("/jobs/:id", h.GetJob)
-	r.DELETE("/jobs/:id", h.DeleteJob)
-	r.POST("/jobs/:id/cancel", h.CancelJob)
-	r.POST("/jobs/:id/retry", h.RetryJob)
-	r.POST("/jobs/:id/reprint", h.ReprintJob)
-	r.POST("/jobs/:id/pause", h.PauseJob)
-	r.POST("/jobs/:id/resume", h.ResumeJob)
+	r.GET("/jobs/:id/thumbnail", h.GetJobThumbnail)
+	r.GET("/jobs/:id/tspl", h.GetJobTSPL)
+	r.DELETE("/jobs/:id", operator, h.DeleteJob)
+	r.POST("/jobs/:id/cancel", operator, h.CancelJob)
+	r.POST("/jobs/:id/retry", operator, h.RetryJob)
+	r.POST("/jobs/:id/verify-scan", operator, h.VerifyScan)
+	r.POST("/jobs/:id/reprint", operator, h.ReprintJob)
+	r.POST("/jobs/:id/pause", operator, h.PauseJob)
+	r.POST("/jobs/:id/resume", operator, h.ResumeJob)
+	r.POST("/jobs/:id/promote", operator, h.PromoteJob)
+	r.POST("/jobs/:id/demote", operator, h.DemoteJob)
 }
 
-func (h *JobHandler) RegisterLegacyRoutes(r *gin.Engine) {
-	r.GET("/print/:layout/:uid", h.LegacyPrintHandler)
+// RegisterLegacyRoutes registers the scanner print route on r, which must
+// sit behind RequireAuth: the route prints as an operator and is scoped to
+// the caller's API key namespace and quota.
+func (h *JobHandler) RegisterLegacyRoutes(r *gin.RouterGroup) {
+	r.GET("/print/:layout/:uid", middleware.RequireRole(middleware.RoleOperator), h.LegacyPrintHandler)
 }
@@ -0,0 +1,778 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+)
+
+// newJobRouter serves the job routes, including the legacy print route,
+// from a queue with cfg that is never started, so submitted jobs stay where
+// the handler put them. A nil cfg uses the queue defaults.
+func newJobRouter(t *testing.T, database *sql.DB, cfg *config.QueueConfig) (*gin.Engine, *core.Queue) {
+	t.Helper()
+
+	queue := core.NewQueue(database, nil, nil, nil, cfg)
+	h := NewJobHandler(database, queue, core.NewTSPL2Generator())
+	router := newTestRouter()
+	h.RegisterRoutes(router.Group("/api"))
+	h.RegisterLegacyRoutes(&router.RouterGroup)
+	return router, queue
+}
+
+// listJobs returns the jobs GET path lists.
+func listJobs(t *testing.T, router *gin.Engine, path string) []JobResponse {
+	t.Helper()
+
+	w := serveJSON(router, http.MethodGet, path, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", path, w.Code, w.Body)
+	}
+	var resp struct {
+		Jobs []JobResponse `json:"jobs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return resp.Jobs
+}
+
+func TestCreateJobWithRunAtIsScheduled(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	router, _ := newJobRouter(t, database, nil)
+
+	submit := func(body map[string]any) int64 {
+		t.Helper()
+		body["printer_id"] = printerID
+		body["template_id"] = templateID
+		body["variables"] = map[string]string{"name": "WIDGET"}
+		w := serveJSON(router, http.MethodPost, "/api/jobs", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create job: %d %s", w.Code, w.Body)
+		}
+		var resp struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode job: %v", err)
+		}
+		return resp.ID
+	}
+	scheduled := submit(map[string]any{"run_at": time.Now().Add(time.Hour)})
+	immediate := submit(map[string]any{})
+
+	jobs := listJobs(t, router, "/api/jobs?status=scheduled")
+	if len(jobs) != 1 || jobs[0].ID != scheduled {
+		t.Fatalf("scheduled jobs are %+v, want only job %d", jobs, scheduled)
+	}
+	if jobs[0].Status != "scheduled" || jobs[0].ScheduledAt == nil {
+		t.Errorf("scheduled job has status %q and scheduled_at %v, want status scheduled with a time", jobs[0].Status, jobs[0].ScheduledAt)
+	}
+
+	jobs = listJobs(t, router, "/api/jobs?status=pending")
+	if len(jobs) != 1 || jobs[0].ID != immediate {
+		t.Errorf("pending jobs are %+v, want only job %d", jobs, immediate)
+	}
+}
+
+// TestCreateJobStoresGeneratedTSPL checks that a job is enqueued with the
+// TSPL generated while validating it, so the worker need not generate it
+// again.
+func TestCreateJobStoresGeneratedTSPL(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	router, _ := newJobRouter(t, database, nil)
+
+	w := serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+		"printer_id": printerID, "template_id": templateID, "variables": map[string]string{"name": "WIDGET"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create job: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+
+	var tspl string
+	if err := database.QueryRow("SELECT COALESCE(tspl_content, '') FROM print_jobs WHERE id = ?", resp.ID).Scan(&tspl); err != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	if !strings.Contains(tspl, `"WIDGET"`) || !strings.Contains(tspl, "PRINT") {
+		t.Errorf("pending job has TSPL %q, want the label generated for WIDGET", tspl)
+	}
+}
+
+func TestCancelJobsByStatus(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	for _, status := range []string{"pending", "pending", "paused"} {
+		insertTestJob(t, database, printerID, status)
+	}
+	router, _ := newJobRouter(t, database, nil)
+
+	w := serveJSON(router, http.MethodPost, "/api/jobs/cancel", map[string]any{})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("cancel without a filter: %d %s, want 400", w.Code, w.Body)
+	}
+	w = serveJSON(router, http.MethodPost, "/api/jobs/cancel", map[string]any{"status": "processing"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("cancel processing jobs: %d %s, want 400", w.Code, w.Body)
+	}
+
+	w = serveJSON(router, http.MethodPost, "/api/jobs/cancel", map[string]any{"printer_id": printerID, "status": "pending"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel pending jobs: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Cancelled int64 `json:"cancelled"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Cancelled != 2 {
+		t.Errorf("cancelled %d jobs, want 2", resp.Cancelled)
+	}
+	if jobs := listJobs(t, router, "/api/jobs?status=paused"); len(jobs) != 1 {
+		t.Errorf("%d paused jobs left, want 1", len(jobs))
+	}
+}
+
+func TestCreateJobRejectsLockedVariables(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "priced", `{"width_mm":50,"height_mm":30,"elements":[
+		{"type":"text","x":10,"y":10,"content":"{{name}}"},
+		{"type":"text","x":10,"y":40,"content":"{{price}}"}],
+		"variables":{"name":{"type":"string"},"price":{"type":"string","default":"9.99","locked":true,"source":"default"}}}`)
+	router, _ := newJobRouter(t, database, nil)
+
+	w := serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+		"printer_id": printerID, "template_id": templateID,
+		"variables": map[string]string{"name": "WIDGET", "price": "0.01"},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("job setting a locked variable: %d %s, want 400", w.Code, w.Body)
+	}
+
+	w = serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+		"printer_id": printerID, "template_id": templateID,
+		"variables": map[string]string{"name": "WIDGET"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("job with only open variables: %d %s", w.Code, w.Body)
+	}
+	jobs := listJobs(t, router, "/api/jobs")
+	if len(jobs) != 1 {
+		t.Fatalf("%d jobs listed, want 1", len(jobs))
+	}
+	if got := jobs[0].Variables; got["name"] != "WIDGET" || got["price"] != "9.99" {
+		t.Errorf("job variables are %v, want name WIDGET and the server's price 9.99", got)
+	}
+}
+
+func TestCreateJobRejectsOversizedTSPL(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	router, _ := newJobRouter(t, database, &config.QueueConfig{MaxRetries: 3, WorkerCount: 1, MaxTSPLBytes: 200})
+
+	for _, tt := range []struct {
+		name string
+		size int
+		want int
+	}{
+		{"short", 10, http.StatusCreated},
+		{"long", 200, http.StatusRequestEntityTooLarge},
+	} {
+		w := serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+			"printer_id": printerID, "template_id": templateID,
+			"variables": map[string]string{"name": strings.Repeat("A", tt.size)},
+		})
+		if w.Code != tt.want {
+			t.Errorf("%s name: %d %s, want %d", tt.name, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+func TestVerifyScan(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "carton", `{"width_mm":50,"height_mm":30,"elements":[
+		{"type":"text","x":10,"y":10,"content":"{{name}}"},
+		{"type":"barcode","x":10,"y":40,"symbology":"128","content":"LOT-{{lot}}"}],
+		"variables":{"name":{"type":"string"},"lot":{"type":"string"}}}`)
+	router, _ := newJobRouter(t, database, nil)
+
+	insertJob := func(status string) int64 {
+		t.Helper()
+		result, err := database.Exec(`INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, submitted_by)
+			VALUES (?, ?, '{"name":"WIDGET","lot":"42"}', 'PRINT 1', ?, 'test')`, printerID, templateID, status)
+		if err != nil {
+			t.Fatalf("insert job: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return id
+	}
+	verify := func(id int64, body map[string]any) (int, string) {
+		t.Helper()
+		w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/jobs/%d/verify-scan", id), body)
+		var resp struct {
+			Status string `json:"verification_status"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Status
+	}
+
+	matching := insertJob("completed")
+	if code, status := verify(matching, map[string]any{"scanned_value": "LOT-42"}); code != http.StatusOK || status != "verified" {
+		t.Errorf("matching scan: %d %q, want 200 verified", code, status)
+	}
+	mismatched := insertJob("completed")
+	if code, status := verify(mismatched, map[string]any{"scanned_value": "LOT-41"}); code != http.StatusOK || status != "mismatch" {
+		t.Errorf("mismatched scan: %d %q, want 200 mismatch", code, status)
+	}
+	byVariable := insertJob("completed")
+	if code, status := verify(byVariable, map[string]any{"scanned_value": "WIDGET", "variable": "name"}); code != http.StatusOK || status != "verified" {
+		t.Errorf("scan of a named variable: %d %q, want 200 verified", code, status)
+	}
+	if code, _ := verify(insertJob("pending"), map[string]any{"scanned_value": "LOT-42"}); code != http.StatusConflict {
+		t.Errorf("scan of a pending job: %d, want 409", code)
+	}
+
+	for id, want := range map[int64]string{matching: "verified", mismatched: "mismatch"} {
+		var status, scanned string
+		if err := database.QueryRow("SELECT verification_status, scanned_value FROM print_jobs WHERE id = ?", id).Scan(&status, &scanned); err != nil {
+			t.Fatalf("read job %d: %v", id, err)
+		}
+		if status != want {
+			t.Errorf("job %d verification is %q, want %q", id, status, want)
+		}
+	}
+}
+
+func TestLegacyJobsOutrankBatchJobs(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "shelf", `{"width_mm":50,"height_mm":30,"elements":[{"type":"text","x":10,"y":10,"content":"{{uid}}"}],"variables":{"uid":{"type":"string"}}}`)
+	router, queue := newJobRouter(t, database, &config.QueueConfig{
+		MaxRetries: 3, WorkerCount: 1, SourcePriorities: map[string]int{"legacy": 10},
+	})
+
+	for i := 0; i < 3; i++ {
+		w := serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+			"printer_id": printerID, "template_id": templateID, "variables": map[string]string{"uid": "batch"},
+		})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create batch job: %d %s", w.Code, w.Body)
+		}
+	}
+	w := serveJSON(router, http.MethodGet, "/print/shelf/SCAN-1", nil)
+	if w.Code >= 300 {
+		t.Fatalf("legacy print: %d %s", w.Code, w.Body)
+	}
+
+	job, err := queue.Dequeue()
+	if err != nil || job == nil {
+		t.Fatalf("dequeue returned %v, %v", job, err)
+	}
+	if job.Priority != 10 || !strings.Contains(job.VariablesJSON, "SCAN-1") {
+		t.Errorf("first job dispatched has priority %d and variables %s, want the legacy scan at priority 10", job.Priority, job.VariablesJSON)
+	}
+
+	// An explicit priority still wins over the source default.
+	w = serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+		"printer_id": printerID, "template_id": templateID, "variables": map[string]string{"uid": "rush"}, "priority": 20,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create rush job: %d %s", w.Code, w.Body)
+	}
+	if job, err := queue.Dequeue(); err != nil || job == nil || job.Priority != 20 {
+		t.Errorf("next job dispatched is %+v, %v, want the rush job at priority 20", job, err)
+	}
+}
+
+func TestLegacyPrintDedupsRapidRepeats(t *testing.T) {
+	database := setupTestDB(t)
+	insertTestPrinter(t, database, "printer")
+	insertTestTemplate(t, database, "shelf", `{"width_mm":50,"height_mm":30,"elements":[{"type":"text","x":10,"y":10,"content":"{{uid}}"}],"variables":{"uid":{"type":"string"}}}`)
+	router, queue := newJobRouter(t, database, &config.QueueConfig{MaxRetries: 3, WorkerCount: 1, LegacyDedupWindow: 2 * time.Second})
+	now := time.Now()
+	queue.SetClock(func() time.Time { return now })
+
+	scan := func() (jobID int64, duplicate bool) {
+		t.Helper()
+		w := serveJSON(router, http.MethodGet, "/print/shelf/SCAN-1", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("legacy print: %d %s", w.Code, w.Body)
+		}
+		var resp struct {
+			JobID     int64 `json:"job_id"`
+			Duplicate bool  `json:"duplicate"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return resp.JobID, resp.Duplicate
+	}
+
+	first, _ := scan()
+	now = now.Add(20 * time.Millisecond)
+	if id, duplicate := scan(); id != first || !duplicate {
+		t.Errorf("rapid repeat returned job %d (duplicate %v), want job %d", id, duplicate, first)
+	}
+
+	now = now.Add(3 * time.Second)
+	if id, duplicate := scan(); id == first || duplicate {
+		t.Errorf("repeat after the window returned job %d (duplicate %v), want a new job", id, duplicate)
+	}
+}
+
+func TestListJobsTotalIsStableAcrossPages(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	other := insertTestPrinter(t, database, "other")
+	for i := 0; i < 5; i++ {
+		insertTestJob(t, database, printerID, "completed")
+	}
+	insertTestJob(t, database, other, "completed")
+	router, _ := newJobRouter(t, database, nil)
+
+	seen := make(map[int64]bool)
+	for _, page := range []struct {
+		offset  int
+		count   int
+		hasMore bool
+	}{
+		{0, 2, true},
+		{2, 2, true},
+		{4, 1, false},
+	} {
+		path := fmt.Sprintf("/api/jobs?printer_id=%d&limit=2&offset=%d", printerID, page.offset)
+		w := serveJSON(router, http.MethodGet, path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", path, w.Code, w.Body)
+		}
+		var resp struct {
+			Jobs    []JobResponse `json:"jobs"`
+			Count   int           `json:"count"`
+			Total   int64         `json:"total"`
+			HasMore bool          `json:"has_more"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+		if resp.Total != 5 || resp.Count != page.count || resp.HasMore != page.hasMore {
+			t.Errorf("offset %d: total %d, count %d, has_more %v, want 5, %d, %v",
+				page.offset, resp.Total, resp.Count, resp.HasMore, page.count, page.hasMore)
+		}
+		for _, job := range resp.Jobs {
+			seen[job.ID] = true
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("pages listed %d distinct jobs, want 5", len(seen))
+	}
+}
+
+func TestListJobsBySubmitterAndTemplate(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	labelID := insertTestTemplate(t, database, "label", testLabelSchema)
+	otherTemplateID := insertTestTemplate(t, database, "other", testLabelSchema)
+
+	insertJob := func(templateID int64, submittedBy string) int64 {
+		t.Helper()
+		id := insertTestJob(t, database, printerID, "completed")
+		if _, err := database.Exec("UPDATE print_jobs SET template_id = ?, submitted_by = ? WHERE id = ?", templateID, submittedBy, id); err != nil {
+			t.Fatalf("update job: %v", err)
+		}
+		return id
+	}
+	scannerLabel := insertJob(labelID, "10.0.0.5")
+	scannerOther := insertJob(otherTemplateID, "10.0.0.5")
+	desktopLabel := insertJob(labelID, "10.0.0.9")
+	router, _ := newJobRouter(t, database, nil)
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"submitted_by=10.0.0.5", []int64{scannerLabel, scannerOther}},
+		{fmt.Sprintf("template_id=%d", labelID), []int64{scannerLabel, desktopLabel}},
+		{fmt.Sprintf("submitted_by=10.0.0.9&template_id=%d", otherTemplateID), nil},
+	}
+	for _, tt := range tests {
+		var got []int64
+		for _, job := range listJobs(t, router, "/api/jobs?sort_by=id&sort_dir=asc&"+tt.query) {
+			got = append(got, job.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("jobs with %s: %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCreateJobForPrinterGroup(t *testing.T) {
+	database := setupTestDB(t)
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	grouped := func(name, group string) int64 {
+		id := insertTestPrinter(t, database, name)
+		if _, err := database.Exec("UPDATE printers SET printer_group = ? WHERE id = ?", group, id); err != nil {
+			t.Fatalf("set group: %v", err)
+		}
+		return id
+	}
+	busyA := grouped("a-1", "zone-a")
+	idleA := grouped("a-2", "zone-a")
+	grouped("b-1", "zone-b")
+	// a-1 already has work queued, so the group's next job goes to a-2.
+	insertTestJob(t, database, busyA, "pending")
+	router, _ := newJobRouter(t, database, nil)
+
+	w := serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+		"printer_group": "zone-a", "template_id": templateID, "variables": map[string]string{},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create job: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		ID        int64 `json:"id"`
+		PrinterID int64 `json:"printer_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.PrinterID != idleA {
+		t.Errorf("job went to printer %d, want the idle zone-a printer %d", resp.PrinterID, idleA)
+	}
+	var stored int64
+	if err := database.QueryRow("SELECT printer_id FROM print_jobs WHERE id = ?", resp.ID).Scan(&stored); err != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	if stored != idleA {
+		t.Errorf("stored job has printer %d, want %d", stored, idleA)
+	}
+
+	for _, tt := range []struct {
+		body map[string]any
+		want int
+	}{
+		{map[string]any{"printer_group": "zone-c"}, http.StatusNotFound},
+		{map[string]any{"printer_group": "zone-a", "printer_id": idleA}, http.StatusBadRequest},
+		{map[string]any{}, http.StatusBadRequest},
+	} {
+		tt.body["template_id"] = templateID
+		tt.body["variables"] = map[string]string{}
+		if w := serveJSON(router, http.MethodPost, "/api/jobs", tt.body); w.Code != tt.want {
+			t.Errorf("create job with %v: %d %s, want %d", tt.body, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+func TestPromoteJob(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	router, queue := newJobRouter(t, database, nil)
+
+	enqueue := func(priority int, status core.JobStatus) int64 {
+		t.Helper()
+		id, err := queue.Enqueue(&core.Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1, Priority: priority, Status: status})
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		return id
+	}
+	enqueue(5, core.JobStatusPending)
+	late := enqueue(0, core.JobStatusPending)
+	done := enqueue(0, core.JobStatusCompleted)
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/jobs/%d/promote", late), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("promote: %d %s", w.Code, w.Body)
+	}
+	job, err := queue.Dequeue()
+	if err != nil || job == nil || job.ID != late {
+		t.Errorf("dequeued %+v (%v), want the promoted job %d first", job, err, late)
+	}
+
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/jobs/%d/demote", done), nil)
+	if w.Code != http.StatusConflict {
+		t.Errorf("demote completed job: %d %s, want 409", w.Code, w.Body)
+	}
+}
+
+func TestCreateJobRejectsUnknownDirective(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "broken", `{"width_mm":50,"height_mm":30,"elements":[
+		{"type":"text","x":10,"y":10,"content":"{{sku|shout}}"}],"variables":{"sku":{"type":"string"}}}`)
+	router, _ := newJobRouter(t, database, nil)
+
+	w := serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+		"printer_id": printerID, "template_id": templateID,
+		"variables": map[string]string{"sku": "ab-1"},
+	})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unknown directive 'shout'") {
+		t.Errorf("job with an unknown directive: %d %s, want 400 naming the directive", w.Code, w.Body)
+	}
+}
+
+func TestCreateJobChecksLabelSize(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+
+	tests := []struct {
+		name        string
+		widthMM     float64
+		mode        string
+		wantCode    int
+		wantWarning bool
+	}{
+		{"matching", 50, core.LabelSizeCheckStrict, http.StatusCreated, false},
+		{"within tolerance", 50.5, core.LabelSizeCheckStrict, http.StatusCreated, false},
+		{"beyond tolerance warns", 100, core.LabelSizeCheckWarn, http.StatusCreated, true},
+		{"beyond tolerance strict", 100, core.LabelSizeCheckStrict, http.StatusBadRequest, false},
+		{"beyond tolerance off", 100, core.LabelSizeCheckOff, http.StatusCreated, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := database.Exec("UPDATE label_templates SET width_mm = ? WHERE id = ?", tt.widthMM, templateID); err != nil {
+				t.Fatalf("resize template: %v", err)
+			}
+			router, _ := newJobRouter(t, database, &config.QueueConfig{
+				MaxRetries: 3, WorkerCount: 1, LabelSizeCheck: tt.mode, LabelSizeToleranceMM: 1,
+			})
+
+			w := serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+				"printer_id": printerID, "template_id": templateID,
+				"variables": map[string]string{"name": "WIDGET"},
+			})
+			if w.Code != tt.wantCode {
+				t.Fatalf("create job: %d %s, want %d", w.Code, w.Body, tt.wantCode)
+			}
+			var resp struct {
+				Warning string `json:"warning"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if (resp.Warning != "") != tt.wantWarning {
+				t.Errorf("warning is %q, want one: %v", resp.Warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestUpcomingJobsMatchDispatchOrder(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	router, queue := newJobRouter(t, database, nil)
+
+	now := time.Now().UTC()
+	for _, job := range []struct {
+		priority int
+		age      time.Duration
+		status   string
+		runIn    time.Duration
+	}{
+		{0, 3 * time.Hour, "pending", 0},
+		{5, time.Minute, "pending", 0},
+		{5, time.Hour, "pending", 0},
+		{0, time.Minute, "pending", 0},
+		{10, 2 * time.Hour, "completed", 0},
+		{10, time.Minute, "pending", time.Hour},
+		{-1, 5 * time.Hour, "pending", 0},
+	} {
+		var scheduledAt any
+		if job.runIn > 0 {
+			scheduledAt = now.Add(job.runIn)
+		}
+		if _, err := database.Exec(`INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, priority, submitted_by, created_at, scheduled_at)
+			VALUES (?, 0, '{}', 'PRINT 1', ?, ?, 'test', ?, ?)`, printerID, job.status, job.priority, now.Add(-job.age), scheduledAt); err != nil {
+			t.Fatalf("insert job: %v", err)
+		}
+	}
+
+	upcoming := func(query string) []int64 {
+		t.Helper()
+		var ids []int64
+		for _, job := range listJobs(t, router, "/api/jobs/queue/upcoming"+query) {
+			ids = append(ids, job.ID)
+			if job.PrinterName != "printer" {
+				t.Errorf("job %d has printer name %q, want printer", job.ID, job.PrinterName)
+			}
+		}
+		return ids
+	}
+	all, firstTwo := upcoming(""), upcoming("?limit=2")
+
+	var dispatched []int64
+	for {
+		job, err := queue.Dequeue()
+		if err != nil {
+			t.Fatalf("dequeue: %v", err)
+		}
+		if job == nil {
+			break
+		}
+		dispatched = append(dispatched, job.ID)
+	}
+	if len(dispatched) != 5 || fmt.Sprint(all) != fmt.Sprint(dispatched) {
+		t.Fatalf("upcoming jobs %v, want the 5 due pending jobs in dispatch order %v", all, dispatched)
+	}
+	if fmt.Sprint(firstTwo) != fmt.Sprint(dispatched[:2]) {
+		t.Errorf("upcoming jobs with limit 2 %v, want %v", firstTwo, dispatched[:2])
+	}
+
+}
+
+func TestGetJobQueuePosition(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	otherPrinterID := insertTestPrinter(t, database, "other")
+	router, _ := newJobRouter(t, database, nil)
+
+	done := insertTestJob(t, database, printerID, "completed")
+	first := insertTestJob(t, database, printerID, "pending")
+	other := insertTestJob(t, database, otherPrinterID, "pending")
+	second := insertTestJob(t, database, printerID, "pending")
+	third := insertTestJob(t, database, printerID, "pending")
+
+	for id, want := range map[int64]int{first: 1, second: 2, third: 3, other: 1, done: 0} {
+		w := serveJSON(router, http.MethodGet, fmt.Sprintf("/api/jobs/%d", id), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("get job %d: %d %s", id, w.Code, w.Body)
+		}
+		var job JobResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("decode job %d: %v", id, err)
+		}
+		got := 0
+		if job.QueuePosition != nil {
+			got = *job.QueuePosition
+		}
+		if got != want {
+			t.Errorf("job %d (%s) has queue_position %v, want %d", id, job.Status, job.QueuePosition, want)
+		}
+	}
+}
+
+func TestRetryFailedJobsMatchesFilter(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	otherPrinterID := insertTestPrinter(t, database, "other")
+	router, _ := newJobRouter(t, database, nil)
+
+	failedAt := func(printerID int64, created, failed time.Time, message string) int64 {
+		t.Helper()
+		id := insertTestJob(t, database, printerID, "failed")
+		// completed_at is stored with its zone, as failJob writes it.
+		if _, err := database.Exec(`UPDATE print_jobs SET created_at = ?, completed_at = ?, error_message = ?, retry_count = 3 WHERE id = ?`,
+			created.UTC().Format("2006-01-02 15:04:05"), failed.In(time.FixedZone("UTC+2", 2*60*60)), message, id); err != nil {
+			t.Fatalf("age job: %v", err)
+		}
+		return id
+	}
+	now := time.Now()
+	duringOutage := failedAt(printerID, now.Add(-time.Hour), now.Add(-time.Hour), "connection refused")
+	beforeOutage := failedAt(printerID, now.Add(-48*time.Hour), now.Add(-48*time.Hour), "connection refused")
+	// Queued before the outage but failed during it.
+	queuedEarly := failedAt(printerID, now.Add(-48*time.Hour), now.Add(-time.Hour), "connection refused")
+	// Queued during the outage but failed after it.
+	failedLate := failedAt(printerID, now.Add(-time.Hour), now.Add(time.Hour), "connection refused")
+	otherPrinter := failedAt(otherPrinterID, now.Add(-time.Hour), now.Add(-time.Hour), "connection refused")
+	brokenTemplate := failedAt(printerID, now.Add(-time.Hour), now.Add(-time.Hour), "TSPL generation failed: unknown variable")
+	completed := insertTestJob(t, database, printerID, "completed")
+
+	w := serveJSON(router, http.MethodPost, "/api/jobs/retry-failed", map[string]any{
+		"printer_id": printerID, "from": now.Add(-2 * time.Hour), "to": now,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("retry failed: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Requeued int `json:"requeued"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Requeued != 2 {
+		t.Errorf("requeued %d jobs, want 2", resp.Requeued)
+	}
+
+	want := map[int64]string{
+		duringOutage:   "pending",
+		queuedEarly:    "pending",
+		failedLate:     "failed",
+		beforeOutage:   "failed",
+		otherPrinter:   "failed",
+		brokenTemplate: "failed",
+		completed:      "completed",
+	}
+	for id, status := range want {
+		var got string
+		var retries int
+		if err := database.QueryRow(`SELECT status, retry_count FROM print_jobs WHERE id = ?`, id).Scan(&got, &retries); err != nil {
+			t.Fatalf("read job %d: %v", id, err)
+		}
+		if got != status {
+			t.Errorf("job %d is %s, want %s", id, got, status)
+		}
+		if status == "pending" && retries != 0 {
+			t.Errorf("retried job %d has retry_count %d, want 0", id, retries)
+		}
+	}
+
+	for _, tt := range []struct {
+		body map[string]any
+		want int
+	}{
+		{map[string]any{"from": now, "to": now.Add(-time.Hour)}, http.StatusBadRequest},
+		{map[string]any{"printer_id": 999}, http.StatusNotFound},
+	} {
+		if w := serveJSON(router, http.MethodPost, "/api/jobs/retry-failed", tt.body); w.Code != tt.want {
+			t.Errorf("retry failed with %v: %d %s, want %d", tt.body, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+func TestCreateJobRejectsMistypedVariable(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "counted", `{"width_mm":50,"height_mm":30,
+		"elements":[{"type":"text","x":10,"y":10,"content":"{{qty}}"}],
+		"variables":{"qty":{"type":"number","required":true}}}`)
+	router, _ := newJobRouter(t, database, nil)
+
+	w := serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+		"printer_id": printerID, "template_id": templateID,
+		"variables": map[string]string{"qty": "a dozen"},
+	})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "'qty' must be a number") {
+		t.Errorf("job with a non-numeric qty: %d %s, want 400 naming qty", w.Code, w.Body)
+	}
+
+	w = serveJSON(router, http.MethodPost, "/api/jobs", map[string]any{
+		"printer_id": printerID, "template_id": templateID,
+		"variables": map[string]string{"qty": "12"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Errorf("job with a numeric qty: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/db"
+)
+
+const (
+	settingsKeyMaintenanceEnabled = "maintenance_enabled"
+	settingsKeyMaintenanceUntil   = "maintenance_until"
+	settingsKeyMaintenanceMessage = "maintenance_message"
+
+	defaultMaintenanceMessage = "Printing is paused for scheduled maintenance"
+)
+
+type MaintenanceState struct {
+	Enabled bool       `json:"enabled"`
+	Until   *time.Time `json:"until,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type UpdateMaintenanceRequest struct {
+	Enabled bool       `json:"enabled"`
+	Until   *time.Time `json:"until"`
+	Message string     `json:"message"`
+}
+
+type MaintenanceErrorResponse struct {
+	Error   string     `json:"error"`
+	Message string     `json:"message"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// loadMaintenance reads the maintenance window from settings. A window whose
+// end time has passed is reported as disabled.
+func loadMaintenance(ctx context.Context) MaintenanceState {
+	var state MaintenanceState
+
+	setting, err := db.Settings.GetSetting(ctx, settingsKeyMaintenanceEnabled)
+	if err != nil || setting.Value != "true" {
+		return state
+	}
+	state.Enabled = true
+
+	if setting, err := db.Settings.GetSetting(ctx, settingsKeyMaintenanceUntil); err == nil && setting.Value != "" {
+		if until, err := time.Parse(time.RFC3339, setting.Value); err == nil {
+			if !until.After(time.Now()) {
+				return MaintenanceState{}
+			}
+			state.Until = &until
+		}
+	}
+
+	if setting, err := db.Settings.GetSetting(ctx, settingsKeyMaintenanceMessage); err == nil {
+		state.Message = setting.Value
+	}
+
+	return state
+}
+
+// rejectIfMaintenance responds with 503 and returns true when a maintenance
+// window is active. Every path that creates print jobs calls it first.
+func rejectIfMaintenance(c *gin.Context) bool {
+	state := loadMaintenance(c.Request.Context())
+	if !state.Enabled {
+		return false
+	}
+
+	message := state.Message
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	if state.Until != nil {
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(*state.Until).Seconds())+1))
+	}
+
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, MaintenanceErrorResponse{
+		Error:   "maintenance",
+		Message: message,
+		Until:   state.Until,
+	})
+	return true
+}
+
+func (h *SettingsHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, loadMaintenance(c.Request.Context()))
+}
+
+func (h *SettingsHandler) UpdateMaintenance(c *gin.Context) {
+	var req UpdateMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Enabled && req.Until != nil && !req.Until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: "until must be in the future",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	enabled := "false"
+	if req.Enabled {
+		enabled = "true"
+	}
+	until := ""
+	if req.Until != nil {
+		until = req.Until.UTC().Format(time.RFC3339)
+	}
+
+	for _, kv := range [][2]string{
+		{settingsKeyMaintenanceEnabled, enabled},
+		{settingsKeyMaintenanceUntil, until},
+		{settingsKeyMaintenanceMessage, req.Message},
+	} {
+		if err := db.Settings.SetSetting(ctx, kv[0], kv[1], false); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to update maintenance settings",
+			})
+			return
+		}
+	}
+
+	recordAudit(c, "update", "maintenance", 0, req)
+
+	c.JSON(http.StatusOK, loadMaintenance(ctx))
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+)
+
+// setMaintenance switches the maintenance window on or off until the test
+// ends.
+func setMaintenance(t *testing.T, enabled bool) {
+	t.Helper()
+
+	ctx := context.Background()
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	if err := db.Settings.SetSetting(ctx, settingsKeyMaintenanceEnabled, value, false); err != nil {
+		t.Fatalf("set maintenance: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Settings.SetSetting(ctx, settingsKeyMaintenanceEnabled, "false", false)
+	})
+}
+
+func countJobs(t *testing.T, database *sql.DB) int {
+	t.Helper()
+
+	var n int
+	if err := database.QueryRow("SELECT COUNT(*) FROM print_jobs").Scan(&n); err != nil {
+		t.Fatalf("count jobs: %v", err)
+	}
+	return n
+}
+
+// newMaintenanceRouter serves every route that submits print jobs, with a
+// queue that is never started.
+func newMaintenanceRouter(t *testing.T, database *sql.DB) *gin.Engine {
+	t.Helper()
+
+	router, queue := newJobRouter(t, database, nil)
+	templates := NewTemplateHandler(database, core.NewTSPL2Generator(), queue)
+	router.POST("/api/templates/:id/print", templates.PrintTemplate)
+	return router
+}
+
+// maintenanceRequest is a request that submits a print job.
+type maintenanceRequest struct {
+	name   string
+	method string
+	path   string
+	body   any
+	// accepted is the status returned outside maintenance, or 0 for
+	// requests only checked for rejection.
+	accepted int
+}
+
+func maintenanceRequests(printerID, templateID, jobID int64) []maintenanceRequest {
+	return []maintenanceRequest{
+		{"create", http.MethodPost, "/api/jobs",
+			map[string]any{"printer_id": printerID, "template_id": templateID, "variables": map[string]string{"name": "A"}}, http.StatusCreated},
+		{"template print", http.MethodPost, fmt.Sprintf("/api/templates/%d/print", templateID),
+			map[string]any{"printer_id": printerID, "variables": map[string]string{"name": "A"}}, http.StatusAccepted},
+		{"legacy", http.MethodGet, "/print/Address/123", nil, http.StatusOK},
+		{"retry", http.MethodPost, fmt.Sprintf("/api/jobs/%d/retry", jobID), nil, 0},
+		{"reprint", http.MethodPost, fmt.Sprintf("/api/jobs/%d/reprint", jobID), nil, 0},
+	}
+}
+
+func TestMaintenanceRejectsJobs(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "Shipping")
+	templateID := insertTestTemplate(t, database, "Address", testLabelSchema)
+	jobID := insertTestJob(t, database, printerID, "failed")
+	router := newMaintenanceRouter(t, database)
+	setMaintenance(t, true)
+
+	for _, req := range maintenanceRequests(printerID, templateID, jobID) {
+		before := countJobs(t, database)
+		w := serveJSON(router, req.method, req.path, req.body)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status %d, want 503: %s", req.name, w.Code, w.Body.String())
+		}
+		if after := countJobs(t, database); after != before {
+			t.Errorf("%s: %d jobs created during maintenance", req.name, after-before)
+		}
+	}
+
+	// Reads stay available.
+	if w := serveJSON(router, http.MethodGet, "/api/jobs", nil); w.Code != http.StatusOK {
+		t.Errorf("list jobs during maintenance: %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestMaintenanceOffAcceptsJobs(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "Shipping")
+	templateID := insertTestTemplate(t, database, "Address", testLabelSchema)
+	router := newMaintenanceRouter(t, database)
+	setMaintenance(t, false)
+
+	for _, req := range maintenanceRequests(printerID, templateID, 0) {
+		if req.accepted == 0 {
+			continue
+		}
+		before := countJobs(t, database)
+		w := serveJSON(router, req.method, req.path, req.body)
+		if w.Code != req.accepted {
+			t.Errorf("%s: status %d, want %d: %s", req.name, w.Code, req.accepted, w.Body.String())
+		}
+		if after := countJobs(t, database); after != before+1 {
+			t.Errorf("%s: %d jobs created, want 1", req.name, after-before)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/api/middleware"
+)
+
+// namespaceVisible reports whether the caller may see a row tagged with
+// namespace. Callers without a namespace see every row.
+func namespaceVisible(c *gin.Context, namespace string) bool {
+	scope := middleware.CallerNamespace(c)
+	return scope == "" || scope == namespace
+}
+
+// namespaceFilter returns the namespace a listing is restricted to: the
+// caller's own, or for unscoped callers the one they asked for, which may
+// be "" for no restriction.
+func namespaceFilter(c *gin.Context, requested string) string {
+	if scope := middleware.CallerNamespace(c); scope != "" {
+		return scope
+	}
+	return requested
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+)
+
+// createNamespacedKey stores an API key scoped to namespace and returns the
+// key itself.
+func createNamespacedKey(t *testing.T, name, namespace string) string {
+	t.Helper()
+
+	key, prefix, hash := middleware.GenerateAPIKey()
+	if err := db.APIKeys.CreateAPIKey(context.Background(), &db.APIKey{Name: name, KeyPrefix: prefix, KeyHash: hash, Namespace: namespace}); err != nil {
+		t.Fatalf("create api key: %v", err)
+	}
+	return key
+}
+
+// insertNamespacedTemplate adds a template tagged with namespace and a
+// completed job printed from it, and returns both IDs.
+func insertNamespacedTemplate(t *testing.T, database *sql.DB, printerID int64, name, namespace string) (templateID, jobID int64) {
+	t.Helper()
+
+	templateID = insertTestTemplate(t, database, name, testLabelSchema)
+	if _, err := database.Exec("UPDATE label_templates SET namespace = ? WHERE id = ?", namespace, templateID); err != nil {
+		t.Fatalf("tag template: %v", err)
+	}
+	result, err := database.Exec(`INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, submitted_by, namespace)
+		VALUES (?, ?, '{}', 'PRINT 1', 'completed', 'test', ?)`, printerID, templateID, namespace)
+	if err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+	jobID, _ = result.LastInsertId()
+	return templateID, jobID
+}
+
+// newNamespaceRouter serves the job and template routes behind API key
+// authentication, with a queue that has no workers running.
+func newNamespaceRouter(t *testing.T, database *sql.DB) *gin.Engine {
+	t.Helper()
+
+	auth, err := middleware.NewAuthMiddleware(database)
+	if err != nil {
+		t.Fatalf("create auth middleware: %v", err)
+	}
+	router := gin.New()
+	protected := router.Group("/api", auth.RequireAuth())
+	queue := core.NewQueue(database, acceptingPrinterManager{}, nil, nil, nil)
+	NewJobHandler(database, queue, core.NewTSPL2Generator()).RegisterRoutes(protected)
+	RegisterTemplateRoutes(protected, NewTemplateHandler(database, core.NewTSPL2Generator(), queue))
+	return router
+}
+
+func TestNamespacedKeyOnlySeesItsNamespace(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	teamATemplate, teamAJob := insertNamespacedTemplate(t, database, printerID, "team-a-label", "team-a")
+	teamBTemplate, teamBJob := insertNamespacedTemplate(t, database, printerID, "team-b-label", "team-b")
+
+	router := newNamespaceRouter(t, database)
+
+	teamAKey := createNamespacedKey(t, "team-a", "team-a")
+	unscopedKey := createNamespacedKey(t, "ops", "")
+
+	listIDs := func(t *testing.T, key, path string) []int64 {
+		t.Helper()
+		w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, path, nil), key))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", path, w.Code, w.Body)
+		}
+		var rows []struct {
+			ID int64 `json:"id"`
+		}
+		if strings.HasPrefix(path, "/api/jobs") {
+			var list struct {
+				Jobs []struct {
+					ID int64 `json:"id"`
+				} `json:"jobs"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+				t.Fatalf("decode %s: %v", path, err)
+			}
+			rows = list.Jobs
+		} else if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+		ids := make([]int64, 0, len(rows))
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return ids
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		path string
+		want []int64
+	}{
+		{"scoped jobs", teamAKey, "/api/jobs", []int64{teamAJob}},
+		{"scoped templates", teamAKey, "/api/templates", []int64{teamATemplate}},
+		{"scoped key ignores namespace param", teamAKey, "/api/jobs?namespace=team-b", []int64{teamAJob}},
+		{"unscoped jobs", unscopedKey, "/api/jobs", []int64{teamAJob, teamBJob}},
+		{"unscoped templates", unscopedKey, "/api/templates", []int64{teamATemplate, teamBTemplate}},
+		{"unscoped filtered templates", unscopedKey, "/api/templates?namespace=team-b", []int64{teamBTemplate}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, tt.key, tt.path); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GET %s returned %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{fmt.Sprintf("/api/jobs/%d", teamBJob), fmt.Sprintf("/api/templates/%d", teamBTemplate)} {
+		if w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, path, nil), teamAKey)); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s with another namespace's key: %d, want 404", path, w.Code)
+		}
+	}
+	if w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, fmt.Sprintf("/api/jobs/%d", teamAJob), nil), teamAKey)); w.Code != http.StatusOK {
+		t.Errorf("GET own job: %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestNamespacedKeyCannotChangeAnotherNamespace(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	teamBTemplate, teamBJob := insertNamespacedTemplate(t, database, printerID, "team-b-label", "team-b")
+	router := newNamespaceRouter(t, database)
+	teamAKey := createNamespacedKey(t, "team-a", "team-a")
+
+	setStatus := func(t *testing.T, status string) {
+		t.Helper()
+		if _, err := database.Exec("UPDATE print_jobs SET status = ? WHERE id = ?", status, teamBJob); err != nil {
+			t.Fatalf("set job status: %v", err)
+		}
+	}
+
+	tests := []struct {
+		status string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"completed", http.MethodDelete, fmt.Sprintf("/api/jobs/%d", teamBJob), nil},
+		{"pending", http.MethodPost, fmt.Sprintf("/api/jobs/%d/cancel", teamBJob), nil},
+		{"failed", http.MethodPost, fmt.Sprintf("/api/jobs/%d/retry", teamBJob), nil},
+		{"completed", http.MethodPost, fmt.Sprintf("/api/jobs/%d/reprint", teamBJob), nil},
+		{"pending", http.MethodPost, fmt.Sprintf("/api/jobs/%d/pause", teamBJob), nil},
+		{"paused", http.MethodPost, fmt.Sprintf("/api/jobs/%d/resume", teamBJob), nil},
+		{"pending", http.MethodPost, fmt.Sprintf("/api/jobs/%d/promote", teamBJob), nil},
+		{"pending", http.MethodPost, fmt.Sprintf("/api/jobs/%d/demote", teamBJob), nil},
+		{"completed", http.MethodPost, fmt.Sprintf("/api/jobs/%d/verify-scan", teamBJob), gin.H{"scanned_value": "x"}},
+		{"pending", http.MethodPut, fmt.Sprintf("/api/templates/%d", teamBTemplate), gin.H{"name": "renamed", "schema": json.RawMessage(testLabelSchema)}},
+		{"pending", http.MethodPatch, fmt.Sprintf("/api/templates/%d", teamBTemplate), gin.H{"name": "renamed"}},
+		{"pending", http.MethodPost, fmt.Sprintf("/api/templates/%d/refresh-pending", teamBTemplate), nil},
+		{"pending", http.MethodDelete, fmt.Sprintf("/api/templates/%d", teamBTemplate), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			setStatus(t, tt.status)
+			w := serve(router, withAPIKey(newJSONRequest(tt.method, tt.path, tt.body), teamAKey))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s %s with another namespace's key: %d %s, want 404", tt.method, tt.path, w.Code, w.Body)
+			}
+
+			var status string
+			if err := database.QueryRow("SELECT status FROM print_jobs WHERE id = ?", teamBJob).Scan(&status); err != nil {
+				t.Fatalf("job is gone: %v", err)
+			}
+			if status != tt.status {
+				t.Errorf("job status changed from %s to %s", tt.status, status)
+			}
+			var name string
+			if err := database.QueryRow("SELECT name FROM label_templates WHERE id = ?", teamBTemplate).Scan(&name); err != nil {
+				t.Fatalf("template is gone: %v", err)
+			}
+			if name != "team-b-label" {
+				t.Errorf("template renamed to %s", name)
+			}
+		})
+	}
+}
+
+func TestNamespacedKeyBulkOperationsStayInNamespace(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	_, teamAJob := insertNamespacedTemplate(t, database, printerID, "team-a-label", "team-a")
+	_, teamBJob := insertNamespacedTemplate(t, database, printerID, "team-b-label", "team-b")
+	router := newNamespaceRouter(t, database)
+	teamAKey := createNamespacedKey(t, "team-a", "team-a")
+
+	statuses := func(t *testing.T) (string, string) {
+		t.Helper()
+		var a, b string
+		if err := database.QueryRow("SELECT status FROM print_jobs WHERE id = ?", teamAJob).Scan(&a); err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		if err := database.QueryRow("SELECT status FROM print_jobs WHERE id = ?", teamBJob).Scan(&b); err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		return a, b
+	}
+
+	tests := []struct {
+		from, to string
+		path     string
+		body     interface{}
+	}{
+		{"pending", "cancelled", "/api/jobs/cancel", gin.H{"status": "pending"}},
+		{"failed", "pending", "/api/jobs/retry-failed", gin.H{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if _, err := database.Exec("UPDATE print_jobs SET status = ?", tt.from); err != nil {
+				t.Fatalf("set job status: %v", err)
+			}
+			if w := serve(router, withAPIKey(newJSONRequest(http.MethodPost, tt.path, tt.body), teamAKey)); w.Code != http.StatusOK {
+				t.Fatalf("POST %s: %d %s", tt.path, w.Code, w.Body)
+			}
+			if a, b := statuses(t); a != tt.to || b != tt.from {
+				t.Errorf("after POST %s team-a job is %s and team-b job %s, want %s and %s", tt.path, a, b, tt.to, tt.from)
+			}
+		})
+	}
+}
+
+func TestNamespacedKeyTemplateCopiesStayInNamespace(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	teamATemplate, _ := insertNamespacedTemplate(t, database, printerID, "team-a-label", "team-a")
+	teamBTemplate, _ := insertNamespacedTemplate(t, database, printerID, "team-b-label", "team-b")
+	router := newNamespaceRouter(t, database)
+	teamAKey := createNamespacedKey(t, "team-a", "team-a")
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, fmt.Sprintf("/api/templates/%d/export", teamBTemplate)},
+		{http.MethodPost, fmt.Sprintf("/api/templates/%d/clone", teamBTemplate)},
+		{http.MethodPost, fmt.Sprintf("/api/templates/%d/preview", teamBTemplate)},
+		{http.MethodGet, fmt.Sprintf("/api/templates/%d/preview.png", teamBTemplate)},
+		{http.MethodPost, fmt.Sprintf("/api/templates/%d/validate", teamBTemplate)},
+	} {
+		w := serve(router, withAPIKey(newJSONRequest(route.method, route.path, gin.H{}), teamAKey))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s with another namespace's key: %d %s, want 404", route.method, route.path, w.Code, w.Body)
+		}
+	}
+
+	w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, "/api/templates/export", nil), teamAKey))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export all: %d %s", w.Code, w.Body)
+	}
+	var bundles []TemplateBundle
+	if err := json.Unmarshal(w.Body.Bytes(), &bundles); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if len(bundles) != 1 || bundles[0].Name != "team-a-label" {
+		t.Errorf("export all returned %+v, want only team-a-label", bundles)
+	}
+
+	namespaceOf := func(t *testing.T, body []byte, path string) string {
+		t.Helper()
+		var created struct {
+			ID       int64 `json:"id"`
+			Imported []struct {
+				ID int64 `json:"id"`
+			} `json:"imported"`
+		}
+		if err := json.Unmarshal(body, &created); err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+		id := created.ID
+		if len(created.Imported) == 1 {
+			id = created.Imported[0].ID
+		}
+		template, err := db.Templates.GetTemplateByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("get template created by %s: %v", path, err)
+		}
+		return template.Namespace
+	}
+
+	bundles[0].Name = "imported"
+	for _, route := range []struct {
+		path string
+		body interface{}
+	}{
+		{"/api/templates/import", bundles[0]},
+		{fmt.Sprintf("/api/templates/%d/clone", teamATemplate), gin.H{}},
+	} {
+		w := serve(router, withAPIKey(newJSONRequest(http.MethodPost, route.path, route.body), teamAKey))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST %s: %d %s", route.path, w.Code, w.Body)
+		}
+		if ns := namespaceOf(t, w.Body.Bytes(), route.path); ns != "team-a" {
+			t.Errorf("template created by POST %s is in namespace %q, want team-a", route.path, ns)
+		}
+	}
+}
+
+func TestNamespacedKeyLegacyPrintStaysInNamespace(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	insertNamespacedTemplate(t, database, printerID, "team-a-label", "team-a")
+	insertNamespacedTemplate(t, database, printerID, "team-b-label", "team-b")
+	teamAKey := createNamespacedKey(t, "team-a", "team-a")
+
+	auth, err := middleware.NewAuthMiddleware(database)
+	if err != nil {
+		t.Fatalf("create auth middleware: %v", err)
+	}
+	router := gin.New()
+	queue := core.NewQueue(database, acceptingPrinterManager{}, nil, nil, nil)
+	NewJobHandler(database, queue, core.NewTSPL2Generator()).RegisterLegacyRoutes(router.Group("/", auth.RequireAuth()))
+
+	if w := serve(router, newJSONRequest(http.MethodGet, "/print/team-a-label/SCAN-1", nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("legacy print without an API key: %d %s, want 401", w.Code, w.Body)
+	}
+
+	w := serve(router, withAPIKey(newJSONRequest(http.MethodGet, "/print/team-b-label/SCAN-1", nil), teamAKey))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("legacy print of another namespace's template: %d %s, want 404", w.Code, w.Body)
+	}
+
+	w = serve(router, withAPIKey(newJSONRequest(http.MethodGet, "/print/team-a-label/SCAN-1", nil), teamAKey))
+	if w.Code != http.StatusOK {
+		t.Fatalf("legacy print of own template: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		JobID int64 `json:"job_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	var namespace string
+	if err := database.QueryRow("SELECT namespace FROM print_jobs WHERE id = ?", resp.JobID).Scan(&namespace); err != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	if namespace != "team-a" {
+		t.Errorf("legacy job has namespace %q, want team-a", namespace)
+	}
+}
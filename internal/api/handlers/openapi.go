@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The OpenAPI spec describes the main template, printer, job and webhook
+// routes for client generators. The routes are listed by hand in
+// openAPIOperations; their request and response schemas are built from the
+// handler types, so a field added to a type is documented with it.
+
+const openAPIVersion = "1.0.0"
+
+// openAPIOperation documents one route. Request and Response are zero
+// values of the body types, or nil for none; List marks a response that is
+// an array of Response.
+type openAPIOperation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Request  any
+	Response any
+	List     bool
+	Status   int
+}
+
+// jobListResponse is the page GET /api/jobs returns.
+type jobListResponse struct {
+	Jobs    []JobResponse `json:"jobs"`
+	Limit   int           `json:"limit"`
+	Offset  int           `json:"offset"`
+	Count   int           `json:"count"`
+	Total   int64         `json:"total"`
+	HasMore bool          `json:"has_more"`
+}
+
+// jobCreatedResponse is what POST /api/jobs returns.
+type jobCreatedResponse struct {
+	ID          int64      `json:"id"`
+	PrinterID   int64      `json:"printer_id"`
+	Message     string     `json:"message"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	Warning     string     `json:"warning,omitempty"`
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+var openAPIOperations = []openAPIOperation{
+	{http.MethodGet, "/api/templates", "templates", "List templates", nil, TemplateListResponse{}, true, http.StatusOK},
+	{http.MethodPost, "/api/templates", "templates", "Create a template", CreateTemplateRequest{}, TemplateResponse{}, false, http.StatusCreated},
+	{http.MethodPost, "/api/templates/validate", "templates", "Validate a label schema", LabelSchemaJSON{}, ValidateResponse{}, false, http.StatusOK},
+	{http.MethodGet, "/api/templates/:id", "templates", "Get a template", nil, TemplateResponse{}, false, http.StatusOK},
+	{http.MethodPut, "/api/templates/:id", "templates", "Update a template", UpdateTemplateRequest{}, TemplateResponse{}, false, http.StatusOK},
+	{http.MethodDelete, "/api/templates/:id", "templates", "Delete a template", nil, messageResponse{}, false, http.StatusOK},
+	{http.MethodPost, "/api/templates/:id/preview", "templates", "Generate a template's TSPL", PreviewRequest{}, PreviewResponse{}, false, http.StatusOK},
+	{http.MethodPost, "/api/templates/:id/print", "templates", "Print a template", QuickPrintRequest{}, QuickPrintResponse{}, false, http.StatusAccepted},
+
+	{http.MethodGet, "/api/printers", "printers", "List printers", nil, PrinterResponse{}, true, http.StatusOK},
+	{http.MethodPost, "/api/printers", "printers", "Add a printer", CreatePrinterRequest{}, PrinterResponse{}, false, http.StatusCreated},
+	{http.MethodGet, "/api/printers/:id", "printers", "Get a printer", nil, PrinterResponse{}, false, http.StatusOK},
+	{http.MethodPut, "/api/printers/:id", "printers", "Update a printer", UpdatePrinterRequest{}, PrinterResponse{}, false, http.StatusOK},
+	{http.MethodDelete, "/api/printers/:id", "printers", "Delete a printer", nil, nil, false, http.StatusNoContent},
+	{http.MethodGet, "/api/printers/:id/status", "printers", "Query a printer's status", nil, PrinterStatusResponse{}, false, http.StatusOK},
+	{http.MethodPost, "/api/printers/:id/raw", "printers", "Queue raw TSPL for a printer", RawPrintRequest{}, RawPrintResponse{}, false, http.StatusAccepted},
+	{http.MethodPost, "/api/printers/:id/calibrate", "printers", "Calibrate a printer's media sensor", CalibratePrinterRequest{}, nil, false, http.StatusOK},
+
+	{http.MethodGet, "/api/jobs", "jobs", "List jobs", nil, jobListResponse{}, false, http.StatusOK},
+	{http.MethodPost, "/api/jobs", "jobs", "Submit a print job", CreateJobRequest{}, jobCreatedResponse{}, false, http.StatusCreated},
+	{http.MethodGet, "/api/jobs/queue", "jobs", "Get queue statistics", nil, QueueResponse{}, false, http.StatusOK},
+	{http.MethodGet, "/api/jobs/:id", "jobs", "Get a job", nil, JobResponse{}, false, http.StatusOK},
+	{http.MethodDelete, "/api/jobs/:id", "jobs", "Delete a job", nil, messageResponse{}, false, http.StatusOK},
+	{http.MethodPost, "/api/jobs/:id/cancel", "jobs", "Cancel a job", nil, messageResponse{}, false, http.StatusOK},
+	{http.MethodPost, "/api/jobs/:id/retry", "jobs", "Retry a failed job", nil, messageResponse{}, false, http.StatusOK},
+
+	{http.MethodGet, "/api/webhooks", "webhooks", "List webhooks", nil, WebhookResponse{}, true, http.StatusOK},
+	{http.MethodPost, "/api/webhooks", "webhooks", "Create a webhook", CreateWebhookRequest{}, WebhookResponse{}, false, http.StatusCreated},
+	{http.MethodGet, "/api/webhooks/:id", "webhooks", "Get a webhook", nil, WebhookResponse{}, false, http.StatusOK},
+	{http.MethodPut, "/api/webhooks/:id", "webhooks", "Update a webhook", UpdateWebhookRequest{}, WebhookResponse{}, false, http.StatusOK},
+	{http.MethodDelete, "/api/webhooks/:id", "webhooks", "Delete a webhook", nil, nil, false, http.StatusNoContent},
+	{http.MethodPost, "/api/webhooks/:id/test", "webhooks", "Send a test event", nil, TestWebhookResponse{}, false, http.StatusOK},
+}
+
+var openAPIPathParam = regexp.MustCompile(`:([a-z_]+)`)
+
+// openAPISpec is built once, on first request.
+var openAPISpec = sync.OnceValue(func() gin.H {
+	return buildOpenAPISpec(openAPIOperations)
+})
+
+func buildOpenAPISpec(operations []openAPIOperation) gin.H {
+	schemas := gin.H{}
+	errorRef := openAPISchema(reflect.TypeOf(ErrorResponse{}), schemas)
+
+	paths := gin.H{}
+	for _, op := range operations {
+		path := openAPIPathParam.ReplaceAllString(op.Path, "{$1}")
+		item, ok := paths[path].(gin.H)
+		if !ok {
+			item = gin.H{}
+			paths[path] = item
+		}
+
+		operation := gin.H{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": openAPIOperationID(op),
+		}
+
+		var params []gin.H
+		for _, match := range openAPIPathParam.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, gin.H{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   gin.H{"type": "integer", "format": "int64"},
+			})
+		}
+		if params != nil {
+			operation["parameters"] = params
+		}
+
+		if op.Request != nil {
+			operation["requestBody"] = gin.H{
+				"required": true,
+				"content": gin.H{
+					"application/json": gin.H{"schema": openAPISchema(reflect.TypeOf(op.Request), schemas)},
+				},
+			}
+		}
+
+		success := gin.H{"description": http.StatusText(op.Status)}
+		if op.Response != nil {
+			schema := openAPISchema(reflect.TypeOf(op.Response), schemas)
+			if op.List {
+				schema = gin.H{"type": "array", "items": schema}
+			}
+			success["content"] = gin.H{"application/json": gin.H{"schema": schema}}
+		}
+		operation["responses"] = gin.H{
+			strconv.Itoa(op.Status): success,
+			"default": gin.H{
+				"description": "Error",
+				"content":     gin.H{"application/json": gin.H{"schema": errorRef}},
+			},
+		}
+
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":   "Spool API",
+			"version": openAPIVersion,
+		},
+		"paths": paths,
+		"components": gin.H{
+			"schemas": schemas,
+			"securitySchemes": gin.H{
+				"bearerAuth": gin.H{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     gin.H{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []gin.H{{"bearerAuth": []string{}}, {"apiKey": []string{}}},
+	}
+}
+
+// openAPIOperationID names an operation after its summary, for generated
+// client method names: "Get a printer's status" becomes getPrinterStatus.
+func openAPIOperationID(op openAPIOperation) string {
+	var sb strings.Builder
+	for _, word := range strings.Fields(strings.ReplaceAll(op.Summary, "'s", "")) {
+		switch {
+		case word == "a" || word == "an":
+		case sb.Len() == 0:
+			sb.WriteString(strings.ToLower(word))
+		default:
+			sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return sb.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// openAPISchema returns the schema for t. Named structs are added to
+// schemas and referenced, so each appears once in the spec.
+func openAPISchema(t reflect.Type, schemas gin.H) gin.H {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return gin.H{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		// Unexported types documenting gin.H responses are named like
+		// the rest in the spec.
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, ok := schemas[name]; !ok {
+			// Reserve the name first, for types that refer to themselves.
+			schemas[name] = gin.H{}
+			schemas[name] = openAPIObject(t, schemas)
+		}
+		return gin.H{"$ref": "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return gin.H{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return gin.H{"type": "object", "additionalProperties": openAPISchema(t.Elem(), schemas)}
+	case reflect.String:
+		return gin.H{"type": "string"}
+	case reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return gin.H{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return gin.H{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number"}
+	default:
+		return gin.H{}
+	}
+}
+
+// openAPIObject describes a struct's JSON fields. Fields bound as required
+// are listed as required, and oneof bindings become enums.
+func openAPIObject(t reflect.Type, schemas gin.H) gin.H {
+	properties := gin.H{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := openAPISchema(f.Type, schemas)
+		for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+			switch {
+			case rule == "required":
+				required = append(required, name)
+			case strings.HasPrefix(rule, "oneof="):
+				schema["enum"] = openAPIEnum(schema, strings.Fields(strings.TrimPrefix(rule, "oneof=")))
+			}
+		}
+		if f.Type.Kind() == reflect.Pointer && f.Type.Elem() != timeType && f.Type.Elem().Kind() != reflect.Struct {
+			schema["nullable"] = true
+		}
+		properties[name] = schema
+	}
+
+	object := gin.H{"type": "object", "properties": properties}
+	if required != nil {
+		object["required"] = required
+	}
+	return object
+}
+
+// openAPIEnum converts oneof values to the schema's type.
+func openAPIEnum(schema gin.H, values []string) []any {
+	enum := make([]any, 0, len(values))
+	for _, v := range values {
+		if schema["type"] == "integer" {
+			if n, err := strconv.Atoi(v); err == nil {
+				enum = append(enum, n)
+				continue
+			}
+		}
+		enum = append(enum, v)
+	}
+	return enum
+}
+
+// GetOpenAPISpec serves the OpenAPI 3 spec for the API.
+func GetOpenAPISpec(c *gin.Context) {
+	c.JSON(http.StatusOK, openAPISpec())
+}
+
+func RegisterOpenAPIRoutes(router *gin.Engine) {
+	router.GET("/openapi.json", GetOpenAPISpec)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterOpenAPIRoutes(router)
+
+	w := serveJSON(router, http.MethodGet, "/openapi.json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get spec: %d %s", w.Code, w.Body)
+	}
+
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") || spec.Info.Title == "" || spec.Info.Version == "" {
+		t.Errorf("spec declares openapi %q, title %q, version %q; want an OpenAPI 3 document with info", spec.OpenAPI, spec.Info.Title, spec.Info.Version)
+	}
+
+	jobs, ok := spec.Paths["/api/jobs"]
+	if !ok {
+		t.Fatalf("spec does not list /api/jobs; paths are %v", mapKeys(spec.Paths))
+	}
+	for _, method := range []string{"get", "post"} {
+		if _, ok := jobs[method]; !ok {
+			t.Errorf("/api/jobs has no %s operation", method)
+		}
+	}
+	if _, ok := spec.Paths["/api/printers/{id}"]; !ok {
+		t.Errorf("path parameters are not in OpenAPI form; paths are %v", mapKeys(spec.Paths))
+	}
+
+	// Every reference resolves to a schema in the document.
+	for _, ref := range regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(w.Body.String(), -1) {
+		if _, ok := spec.Components.Schemas[ref[1]]; !ok {
+			t.Errorf("reference to undefined schema %s", ref[1])
+		}
+	}
+
+	var job struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(spec.Components.Schemas["CreateJobRequest"], &job); err != nil {
+		t.Fatalf("decode CreateJobRequest schema: %v", err)
+	}
+	if _, ok := job.Properties["template_id"]; !ok || !contains(job.Required, "template_id") {
+		t.Errorf("CreateJobRequest schema is %+v, want template_id as a required property", job)
+	}
+}
+
+// TestOpenAPIOperationsAreRoutes checks the documented operations against
+// the routes the handlers register, so the spec cannot list a route that
+// has been moved or removed.
+func TestOpenAPIOperationsAreRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api")
+	RegisterTemplateRoutes(api, &TemplateHandler{})
+	RegisterPrinterRoutes(api, &PrinterHandler{})
+	(&JobHandler{}).RegisterRoutes(api)
+	RegisterWebhookRoutes(api, &WebhookHandler{})
+
+	routes := make(map[string]bool)
+	for _, r := range router.Routes() {
+		routes[r.Method+" "+r.Path] = true
+	}
+	ids := make(map[string]bool)
+	for _, op := range openAPIOperations {
+		if !routes[op.Method+" "+op.Path] {
+			t.Errorf("documented operation %s %s is not a registered route", op.Method, op.Path)
+		}
+		id := openAPIOperationID(op)
+		if ids[id] {
+			t.Errorf("operation ID %s is used twice", id)
+		}
+		ids[id] = true
+	}
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
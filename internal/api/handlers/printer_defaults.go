@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/db"
+)
+
+const (
+	settingsKeyPrinterDefaultDPI           = "printer_default_dpi"
+	settingsKeyPrinterDefaultPort          = "printer_default_port"
+	settingsKeyPrinterDefaultLabelWidthMM  = "printer_default_label_width_mm"
+	settingsKeyPrinterDefaultLabelHeightMM = "printer_default_label_height_mm"
+
+	builtinPrinterDPI  = 203
+	builtinPrinterPort = 9100
+)
+
+// PrinterDefaults are used by CreatePrinter for fields a new printer leaves
+// out. A label size of 0 means there is no default, so the size must be
+// given or read from the printer.
+type PrinterDefaults struct {
+	DPI           int     `json:"dpi"`
+	Port          int     `json:"port"`
+	LabelWidthMM  float64 `json:"label_width_mm"`
+	LabelHeightMM float64 `json:"label_height_mm"`
+}
+
+type UpdatePrinterDefaultsRequest struct {
+	DPI           int     `json:"dpi" binding:"min=0,max=1200"`
+	Port          int     `json:"port" binding:"min=0,max=65535"`
+	LabelWidthMM  float64 `json:"label_width_mm" binding:"min=0"`
+	LabelHeightMM float64 `json:"label_height_mm" binding:"min=0"`
+}
+
+// loadPrinterDefaults reads the printer defaults from settings. DPI and
+// port fall back to 203 and 9100 when unset.
+func loadPrinterDefaults(ctx context.Context) PrinterDefaults {
+	defaults := PrinterDefaults{DPI: builtinPrinterDPI, Port: builtinPrinterPort}
+
+	if setting, err := db.Settings.GetSetting(ctx, settingsKeyPrinterDefaultDPI); err == nil {
+		if dpi, err := strconv.Atoi(setting.Value); err == nil && dpi > 0 {
+			defaults.DPI = dpi
+		}
+	}
+	if setting, err := db.Settings.GetSetting(ctx, settingsKeyPrinterDefaultPort); err == nil {
+		if port, err := strconv.Atoi(setting.Value); err == nil && port > 0 {
+			defaults.Port = port
+		}
+	}
+	if setting, err := db.Settings.GetSetting(ctx, settingsKeyPrinterDefaultLabelWidthMM); err == nil {
+		if width, err := strconv.ParseFloat(setting.Value, 64); err == nil && width > 0 {
+			defaults.LabelWidthMM = width
+		}
+	}
+	if setting, err := db.Settings.GetSetting(ctx, settingsKeyPrinterDefaultLabelHeightMM); err == nil {
+		if height, err := strconv.ParseFloat(setting.Value, 64); err == nil && height > 0 {
+			defaults.LabelHeightMM = height
+		}
+	}
+
+	return defaults
+}
+
+func (h *SettingsHandler) GetPrinterDefaults(c *gin.Context) {
+	c.JSON(http.StatusOK, loadPrinterDefaults(c.Request.Context()))
+}
+
+// UpdatePrinterDefaults replaces the printer defaults. A field set to 0
+// clears its default.
+func (h *SettingsHandler) UpdatePrinterDefaults(c *gin.Context) {
+	var req UpdatePrinterDefaultsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	for _, kv := range [][2]string{
+		{settingsKeyPrinterDefaultDPI, strconv.Itoa(req.DPI)},
+		{settingsKeyPrinterDefaultPort, strconv.Itoa(req.Port)},
+		{settingsKeyPrinterDefaultLabelWidthMM, strconv.FormatFloat(req.LabelWidthMM, 'f', -1, 64)},
+		{settingsKeyPrinterDefaultLabelHeightMM, strconv.FormatFloat(req.LabelHeightMM, 'f', -1, 64)},
+	} {
+		if err := db.Settings.SetSetting(ctx, kv[0], kv[1], false); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to update printer defaults",
+			})
+			return
+		}
+	}
+
+	recordAudit(c, "update", "printer_defaults", 0, req)
+
+	c.JSON(http.StatusOK, loadPrinterDefaults(ctx))
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+)
+
+func TestCreatePrinterUsesConfiguredDefaults(t *testing.T) {
+	database := setupTestDB(t)
+	settings := newSettingsRouter(t, &config.Config{})
+	h := NewPrinterHandler(database, startPrinterManager(t, database))
+	h.probe = func(string, int) (*core.ConnectionTest, error) {
+		return nil, core.ErrConnectionFailed
+	}
+	printers := newTestRouter()
+	RegisterPrinterRoutes(printers.Group("/api"), h)
+
+	create := func(body map[string]any) (PrinterResponse, int) {
+		t.Helper()
+		w := serveJSON(printers, http.MethodPost, "/api/printers", body)
+		var printer PrinterResponse
+		if w.Code == http.StatusCreated {
+			if err := json.Unmarshal(w.Body.Bytes(), &printer); err != nil {
+				t.Fatalf("decode printer: %v", err)
+			}
+		}
+		return printer, w.Code
+	}
+
+	// Without configured defaults DPI and port are the built-in ones and an
+	// unreadable label size must be given.
+	printer, code := create(map[string]any{"name": "builtin", "ip_address": "10.0.0.1", "label_width_mm": 50, "label_height_mm": 30})
+	if code != http.StatusCreated || printer.DPI != 203 || printer.Port != 9100 {
+		t.Errorf("printer without defaults is %d dpi on port %d (status %d), want 203 and 9100", printer.DPI, printer.Port, code)
+	}
+	if _, code := create(map[string]any{"name": "no-size", "ip_address": "10.0.0.2"}); code != http.StatusBadRequest {
+		t.Errorf("printer without a label size or default: status %d, want 400", code)
+	}
+
+	w := serveJSON(settings, http.MethodPut, "/api/settings/printer-defaults", map[string]any{
+		"dpi": 300, "port": 6101, "label_width_mm": 100, "label_height_mm": 150,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update printer defaults: %d %s", w.Code, w.Body)
+	}
+
+	printer, code = create(map[string]any{"name": "defaulted", "ip_address": "10.0.0.3"})
+	if code != http.StatusCreated {
+		t.Fatalf("create printer with defaults: status %d", code)
+	}
+	if printer.DPI != 300 || printer.Port != 6101 || printer.LabelWidthMM != 100 || printer.LabelHeightMM != 150 {
+		t.Errorf("printer is %d dpi on port %d with %v x %v labels, want the configured defaults", printer.DPI, printer.Port, printer.LabelWidthMM, printer.LabelHeightMM)
+	}
+
+	printer, code = create(map[string]any{"name": "explicit", "ip_address": "10.0.0.4", "dpi": 600, "label_width_mm": 40})
+	if code != http.StatusCreated || printer.DPI != 600 || printer.LabelWidthMM != 40 || printer.LabelHeightMM != 150 {
+		t.Errorf("explicit printer is %d dpi with %v x %v labels (status %d), want 600 dpi and 40 x 150", printer.DPI, printer.LabelWidthMM, printer.LabelHeightMM, code)
+	}
+
+	w = serveJSON(settings, http.MethodGet, "/api/settings", nil)
+	var resp SettingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode settings: %v", err)
+	}
+	if resp.PrinterDefaults.DPI != 300 || resp.PrinterDefaults.LabelHeightMM != 150 {
+		t.Errorf("settings report printer defaults %+v, want the configured ones", resp.PrinterDefaults)
+	}
+
+	w = serveJSON(settings, http.MethodPut, "/api/settings/printer-defaults", map[string]any{"port": 70000})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("update with an invalid port: %d %s, want 400", w.Code, w.Body)
+	}
+}
@@ -1,15 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/api/middleware"
 	"github.com/orrn/spool/internal/core"
 	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/logging"
 )
 
 type ErrorResponse struct {
@@ -18,40 +25,86 @@ type ErrorResponse struct {
 }
 
 type CreatePrinterRequest struct {
-	Name          string  `json:"name" binding:"required"`
-	IPAddress     string  `json:"ip_address" binding:"required,ip_addr"`
-	Port          int     `json:"port"`
-	DPI           int     `json:"dpi"`
-	LabelWidthMM  float64 `json:"label_width_mm" binding:"required,gt=0"`
-	LabelHeightMM float64 `json:"label_height_mm" binding:"required,gt=0"`
-	GapMM         float64 `json:"gap_mm"`
+	Name              string  `json:"name" binding:"required"`
+	IPAddress         string  `json:"ip_address" binding:"omitempty,ip_addr"`
+	Port              int     `json:"port"`
+	DPI               int     `json:"dpi"`
+	LabelWidthMM      float64 `json:"label_width_mm" binding:"omitempty,gt=0"`
+	LabelHeightMM     float64 `json:"label_height_mm" binding:"omitempty,gt=0"`
+	GapMM             float64 `json:"gap_mm"`
+	DefaultTemplateID *int64  `json:"default_template_id"`
+	LineEnding        string  `json:"line_ending" binding:"omitempty,oneof=lf crlf"`
+	Encoding          string  `json:"encoding"`
+	FallbackPrinterID *int64  `json:"fallback_printer_id"`
+	Group             string  `json:"group" binding:"max=64"`
+	// The label size may be left out, in which case it is read from the
+	// printer; see probeLabelSize.
+	// FeedOnError is sent after a failed print to realign the labels:
+	// none (the default), formfeed, or a calibration for gap, black_mark
+	// or auto media.
+	FeedOnError string `json:"feed_on_error" binding:"omitempty,oneof=none formfeed gap black_mark auto"`
+	// InitCommands are sent once on each new connection, for example
+	// GAPDETECT. Empty uses the configured default.
+	InitCommands []string `json:"init_commands"`
+	// StatusLength is how many bytes the printer answers a status query
+	// with: 1, 4 (the default) or 8.
+	StatusLength int `json:"status_length" binding:"omitempty,oneof=1 4 8"`
+	// Type is network (the default) or file. A file printer takes
+	// spool_dir, the directory its prints are written to, instead of
+	// ip_address.
+	Type     string `json:"type" binding:"omitempty,oneof=network file"`
+	SpoolDir string `json:"spool_dir"`
 }
 
 type UpdatePrinterRequest struct {
-	Name          string  `json:"name"`
-	IPAddress     string  `json:"ip_address" binding:"omitempty,ip_addr"`
-	Port          int     `json:"port"`
-	DPI           int     `json:"dpi"`
-	LabelWidthMM  float64 `json:"label_width_mm" binding:"omitempty,gt=0"`
-	LabelHeightMM float64 `json:"label_height_mm" binding:"omitempty,gt=0"`
-	GapMM         float64 `json:"gap_mm"`
+	Name              string  `json:"name"`
+	IPAddress         string  `json:"ip_address" binding:"omitempty,ip_addr"`
+	Port              int     `json:"port"`
+	DPI               int     `json:"dpi"`
+	LabelWidthMM      float64 `json:"label_width_mm" binding:"omitempty,gt=0"`
+	LabelHeightMM     float64 `json:"label_height_mm" binding:"omitempty,gt=0"`
+	GapMM             float64 `json:"gap_mm"`
+	DefaultTemplateID *int64  `json:"default_template_id"`
+	LineEnding        string  `json:"line_ending" binding:"omitempty,oneof=lf crlf"`
+	Encoding          string  `json:"encoding"`
+	FallbackPrinterID *int64  `json:"fallback_printer_id"`
+	Group             *string `json:"group" binding:"omitempty,max=64"`
+	// FeedOnError replaces the printer's feed-on-error option.
+	FeedOnError string `json:"feed_on_error" binding:"omitempty,oneof=none formfeed gap black_mark auto"`
+	// InitCommands replaces the printer's init commands; an empty list
+	// clears them.
+	InitCommands *[]string `json:"init_commands"`
+	// StatusLength replaces the printer's status response length.
+	StatusLength int `json:"status_length" binding:"omitempty,oneof=1 4 8"`
+	// SpoolDir replaces a file printer's spool directory.
+	SpoolDir string `json:"spool_dir"`
 }
 
 type PrinterResponse struct {
-	ID            int64      `json:"id"`
-	Name          string     `json:"name"`
-	IPAddress     string     `json:"ip_address"`
-	Port          int        `json:"port"`
-	DPI           int        `json:"dpi"`
-	LabelWidthMM  float64    `json:"label_width_mm"`
-	LabelHeightMM float64    `json:"label_height_mm"`
-	GapMM         float64    `json:"gap_mm"`
-	Status        string     `json:"status"`
-	CanPrint      bool       `json:"can_print"`
-	LastSeenAt    *time.Time `json:"last_seen_at,omitempty"`
-	TotalPrints   int64      `json:"total_prints"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	ID                int64      `json:"id"`
+	Name              string     `json:"name"`
+	IPAddress         string     `json:"ip_address"`
+	Port              int        `json:"port"`
+	DPI               int        `json:"dpi"`
+	LabelWidthMM      float64    `json:"label_width_mm"`
+	LabelHeightMM     float64    `json:"label_height_mm"`
+	GapMM             float64    `json:"gap_mm"`
+	Status            string     `json:"status"`
+	CanPrint          bool       `json:"can_print"`
+	LastSeenAt        *time.Time `json:"last_seen_at,omitempty"`
+	TotalPrints       int64      `json:"total_prints"`
+	DefaultTemplateID *int64     `json:"default_template_id,omitempty"`
+	LineEnding        string     `json:"line_ending"`
+	Encoding          string     `json:"encoding"`
+	FallbackPrinterID *int64     `json:"fallback_printer_id,omitempty"`
+	Group             string     `json:"group,omitempty"`
+	FeedOnError       string     `json:"feed_on_error"`
+	InitCommands      []string   `json:"init_commands,omitempty"`
+	StatusLength      int        `json:"status_length"`
+	Type              string     `json:"type"`
+	SpoolDir          string     `json:"spool_dir,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
 }
 
 type PrinterStatusResponse struct {
@@ -64,6 +117,84 @@ type PrinterStatusResponse struct {
 	IsOnline     bool      `json:"is_online"`
 	CanPrint     bool      `json:"can_print"`
 	LastChecked  time.Time `json:"last_checked"`
+
+	// Reported only by printers with extended status.
+	HeadTemperatureC *int   `json:"head_temperature_c,omitempty"`
+	MediaDetail      string `json:"media_detail,omitempty"`
+}
+
+// PrinterRawStatusResponse is the status response exactly as the printer
+// sent it, as hex, alongside how the bytes were decoded. A byte that decodes
+// as "unknown" is one the status tables do not cover.
+type PrinterRawStatusResponse struct {
+	ID           int64     `json:"id"`
+	Raw          string    `json:"raw"`
+	PrinterState string    `json:"printer_state"`
+	Warning      string    `json:"warning"`
+	Error        string    `json:"error"`
+	MediaError   string    `json:"media_error"`
+	LastChecked  time.Time `json:"last_checked"`
+
+	HeadTemperatureC *int   `json:"head_temperature_c,omitempty"`
+	MediaDetail      string `json:"media_detail,omitempty"`
+}
+
+type PrinterInfoResponse struct {
+	ID        int64     `json:"id"`
+	Model     string    `json:"model"`
+	Firmware  string    `json:"firmware"`
+	Mileage   string    `json:"mileage"`
+	Codepage  string    `json:"codepage"`
+	Complete  bool      `json:"complete"`
+	QueriedAt time.Time `json:"queried_at"`
+}
+
+// PrinterConfigResponse is the media and print settings stored on a
+// printer. Settings the printer did not report are omitted.
+type PrinterConfigResponse struct {
+	ID            int64     `json:"id"`
+	LabelWidthMM  *float64  `json:"label_width_mm,omitempty"`
+	LabelHeightMM *float64  `json:"label_height_mm,omitempty"`
+	GapMM         *float64  `json:"gap_mm,omitempty"`
+	Speed         *float64  `json:"speed,omitempty"`
+	Density       *int      `json:"density,omitempty"`
+	QueriedAt     time.Time `json:"queried_at"`
+}
+
+type PrinterConnectionTestRequest struct {
+	IPAddress string `json:"ip_address" binding:"required,ip_addr"`
+	Port      int    `json:"port" binding:"omitempty,min=1,max=65535"`
+}
+
+// PrinterConnectionTestResponse reports whether an unsaved printer address is
+// reachable. Fields the printer did not report are omitted.
+type PrinterConnectionTestResponse struct {
+	Reachable     bool     `json:"reachable"`
+	Online        bool     `json:"online"`
+	Status        string   `json:"status"`
+	PrinterState  string   `json:"printer_state,omitempty"`
+	Warning       string   `json:"warning,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	MediaError    string   `json:"media_error,omitempty"`
+	Model         string   `json:"model,omitempty"`
+	Firmware      string   `json:"firmware,omitempty"`
+	LabelWidthMM  *float64 `json:"label_width_mm,omitempty"`
+	LabelHeightMM *float64 `json:"label_height_mm,omitempty"`
+	Message       string   `json:"message,omitempty"`
+}
+
+// Bulk status refreshes check this many printers at once and give up on
+// printers that have not answered within printerRefreshTimeout.
+const (
+	printerRefreshParallelism = 8
+	printerRefreshTimeout     = 15 * time.Second
+)
+
+type PrinterRefreshResult struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
 }
 
 type TestPrintRequest struct {
@@ -71,6 +202,33 @@ type TestPrintRequest struct {
 	Variables  map[string]string `json:"variables"`
 }
 
+type RawPrintRequest struct {
+	TSPL   string `json:"tspl" binding:"required"`
+	Copies int    `json:"copies" binding:"min=0"`
+}
+
+type RawPrintResponse struct {
+	JobID int64 `json:"job_id"`
+}
+
+type ReprintRecentRequest struct {
+	Count         int  `json:"count" binding:"required,min=1,max=100"`
+	IncludeFailed bool `json:"include_failed"`
+}
+
+type ReprintedJob struct {
+	OriginalJobID int64 `json:"original_job_id"`
+	NewJobID      int64 `json:"new_job_id"`
+}
+
+type ReprintRecentResponse struct {
+	Reprinted []ReprintedJob `json:"reprinted"`
+}
+
+type CalibratePrinterRequest struct {
+	MediaType string `json:"media_type" binding:"required,oneof=gap black_mark auto"`
+}
+
 type PrinterCountersResponse struct {
 	PrinterID int64          `json:"printer_id"`
 	Total     int64          `json:"total"`
@@ -83,18 +241,52 @@ type CounterEntry struct {
 	Count int64  `json:"count"`
 }
 
+// PrinterHourlyCountersResponse is the ?granularity=hour form of the printer
+// counters, one entry per hour with prints between From and To.
+type PrinterHourlyCountersResponse struct {
+	PrinterID   int64                `json:"printer_id"`
+	Granularity string               `json:"granularity"`
+	From        time.Time            `json:"from"`
+	To          time.Time            `json:"to"`
+	Total       int64                `json:"total"`
+	ByHour      []HourlyCounterEntry `json:"by_hour"`
+}
+
+type HourlyCounterEntry struct {
+	Hour  time.Time `json:"hour"`
+	Count int64     `json:"count"`
+}
+
 type PrinterHandler struct {
 	db             *sql.DB
 	printerManager *core.PrinterManager
+	queue          *core.Queue
+	// probe tests a printer address before it is saved. Tests replace it
+	// to stand in for a printer at an address CreatePrinter accepts.
+	probe func(ipAddress string, port int) (*core.ConnectionTest, error)
+	quota *middleware.JobQuota
 }
 
 func NewPrinterHandler(database *sql.DB, printerManager *core.PrinterManager) *PrinterHandler {
 	return &PrinterHandler{
 		db:             database,
 		printerManager: printerManager,
+		probe:          printerManager.TestConnection,
 	}
 }
 
+// SetQueue enables endpoints that submit jobs, such as raw printing.
+func (h *PrinterHandler) SetQueue(queue *core.Queue) {
+	h.queue = queue
+}
+
+// SetQuota enables per-API-key submission limits on raw prints and
+// reprints. Requests that are not authenticated with an API key are not
+// counted.
+func (h *PrinterHandler) SetQuota(quota *middleware.JobQuota) {
+	h.quota = quota
+}
+
 func (h *PrinterHandler) ListPrinters(c *gin.Context) {
 	printers, err := db.Printers.ListPrinters(c.Request.Context())
 	if err != nil {
@@ -105,8 +297,13 @@ func (h *PrinterHandler) ListPrinters(c *gin.Context) {
 		return
 	}
 
+	group, filterGroup := c.GetQuery("group")
+
 	responses := make([]PrinterResponse, 0, len(printers))
 	for _, p := range printers {
+		if filterGroup && p.Group != group {
+			continue
+		}
 		responses = append(responses, h.printerToResponse(p))
 	}
 
@@ -123,6 +320,29 @@ func (h *PrinterHandler) CreatePrinter(c *gin.Context) {
 		return
 	}
 
+	printerType := req.Type
+	if printerType == "" {
+		printerType = core.PrinterTypeNetwork
+	}
+	if msg := h.validatePrinterTarget(printerType, req.IPAddress, req.SpoolDir); msg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+		return
+	}
+	spoolDir := ""
+	if printerType == core.PrinterTypeFile {
+		spoolDir = filepath.Clean(req.SpoolDir)
+		req.IPAddress = core.FilePrinterAddress(spoolDir)
+	} else if err := h.printerManager.ValidateAddress(req.IPAddress); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	var existingName int
 	err := h.db.QueryRowContext(c.Request.Context(),
 		"SELECT 1 FROM printers WHERE name = ?", req.Name).Scan(&existingName)
@@ -134,25 +354,84 @@ func (h *PrinterHandler) CreatePrinter(c *gin.Context) {
 		return
 	}
 
+	defaults := loadPrinterDefaults(c.Request.Context())
+
 	port := req.Port
 	if port == 0 {
-		port = 9100
+		port = defaults.Port
 	}
 
 	dpi := req.DPI
 	if dpi == 0 {
-		dpi = 203
+		dpi = defaults.DPI
+	}
+
+	if req.LabelWidthMM == 0 || req.LabelHeightMM == 0 {
+		if !h.probeLabelSize(c, &req, port, defaults) {
+			return
+		}
+	}
+
+	defaultTemplateID, ok := h.resolveDefaultTemplate(c, req.DefaultTemplateID)
+	if !ok {
+		return
+	}
+
+	fallbackPrinterID, ok := h.resolveFallbackPrinter(c, 0, req.FallbackPrinterID)
+	if !ok {
+		return
+	}
+
+	lineEnding := req.LineEnding
+	if lineEnding == "" {
+		lineEnding = core.LineEndingLF
+	}
+	encoding := req.Encoding
+	if encoding == "" {
+		encoding = "UTF-8"
+	}
+	feedOnError := req.FeedOnError
+	if feedOnError == "" {
+		feedOnError = core.FeedOnErrorNone
+	}
+	statusLength := req.StatusLength
+	if statusLength == 0 {
+		statusLength = core.StatusLengthStandard
+	}
+	if err := core.ValidatePrinterOutput(lineEnding, encoding); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := core.ValidateInitCommands(req.InitCommands); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
 	}
 
 	printer := &db.Printer{
-		Name:          req.Name,
-		IPAddress:     req.IPAddress,
-		Port:          port,
-		DPI:           dpi,
-		LabelWidthMM:  req.LabelWidthMM,
-		LabelHeightMM: req.LabelHeightMM,
-		GapMM:         req.GapMM,
-		Status:        "unknown",
+		Name:              req.Name,
+		IPAddress:         req.IPAddress,
+		Port:              port,
+		DPI:               dpi,
+		LabelWidthMM:      req.LabelWidthMM,
+		LabelHeightMM:     req.LabelHeightMM,
+		GapMM:             req.GapMM,
+		Status:            "unknown",
+		DefaultTemplateID: defaultTemplateID,
+		LineEnding:        lineEnding,
+		Encoding:          encoding,
+		FallbackPrinterID: fallbackPrinterID,
+		Group:             strings.TrimSpace(req.Group),
+		FeedOnError:       feedOnError,
+		InitCommands:      req.InitCommands,
+		StatusLength:      statusLength,
+		Type:              printerType,
+		SpoolDir:          spoolDir,
 	}
 
 	err = db.Printers.CreatePrinter(c.Request.Context(), printer)
@@ -164,19 +443,7 @@ func (h *PrinterHandler) CreatePrinter(c *gin.Context) {
 		return
 	}
 
-	corePrinter := &core.Printer{
-		ID:            printer.ID,
-		Name:          printer.Name,
-		IPAddress:     printer.IPAddress,
-		Port:          printer.Port,
-		DPI:           printer.DPI,
-		LabelWidthMM:  printer.LabelWidthMM,
-		LabelHeightMM: printer.LabelHeightMM,
-		GapMM:         printer.GapMM,
-		Status:        printer.Status,
-		LastSeenAt:    printer.LastSeenAt,
-		TotalPrints:   printer.TotalPrints,
-	}
+	corePrinter := toCorePrinter(printer)
 
 	if err := h.printerManager.AddPrinter(corePrinter); err != nil {
 		if err == core.ErrPrinterAlreadyExists {
@@ -188,9 +455,50 @@ func (h *PrinterHandler) CreatePrinter(c *gin.Context) {
 		}
 	}
 
+	recordAudit(c, "create", "printer", printer.ID, req)
+
 	c.JSON(http.StatusCreated, h.printerToResponse(printer))
 }
 
+// probeLabelSize fills in the label width or height left out of req with
+// the size the printer reports, or failing that the configured default. It
+// writes an error and returns false when neither supplies the missing
+// dimension.
+func (h *PrinterHandler) probeLabelSize(c *gin.Context, req *CreatePrinterRequest, port int, defaults PrinterDefaults) bool {
+	var result *core.ConnectionTest
+	err := core.ErrNotNetworkPrinter
+	if req.Type != core.PrinterTypeFile {
+		result, err = h.probe(req.IPAddress, port)
+	}
+	if err == nil {
+		if req.LabelWidthMM == 0 && result.LabelWidthMM != nil {
+			req.LabelWidthMM = *result.LabelWidthMM
+		}
+		if req.LabelHeightMM == 0 && result.LabelHeightMM != nil {
+			req.LabelHeightMM = *result.LabelHeightMM
+		}
+	}
+	if req.LabelWidthMM == 0 {
+		req.LabelWidthMM = defaults.LabelWidthMM
+	}
+	if req.LabelHeightMM == 0 {
+		req.LabelHeightMM = defaults.LabelHeightMM
+	}
+	if req.LabelWidthMM != 0 && req.LabelHeightMM != 0 {
+		return true
+	}
+
+	message := "label_width_mm and label_height_mm are required: the printer did not report its label size and no default is set"
+	if err != nil {
+		message = fmt.Sprintf("label_width_mm and label_height_mm are required: no default is set and they could not be read from the printer: %v", err)
+	}
+	c.JSON(http.StatusBadRequest, ErrorResponse{
+		Error:   "validation_error",
+		Message: message,
+	})
+	return false
+}
+
 func (h *PrinterHandler) GetPrinter(c *gin.Context) {
 	id, err := h.parsePrinterID(c)
 	if err != nil {
@@ -268,7 +576,32 @@ func (h *PrinterHandler) UpdatePrinter(c *gin.Context) {
 		}
 		printer.Name = req.Name
 	}
+	if req.IPAddress != "" && printer.Type == core.PrinterTypeFile {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: "file printers take spool_dir instead of ip_address",
+		})
+		return
+	}
+	if req.SpoolDir != "" {
+		if msg := h.validatePrinterTarget(printer.Type, "", req.SpoolDir); msg != "" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "validation_error",
+				Message: msg,
+			})
+			return
+		}
+		printer.SpoolDir = filepath.Clean(req.SpoolDir)
+		printer.IPAddress = core.FilePrinterAddress(printer.SpoolDir)
+	}
 	if req.IPAddress != "" {
+		if err := h.printerManager.ValidateAddress(req.IPAddress); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+			return
+		}
 		printer.IPAddress = req.IPAddress
 	}
 	if req.Port != 0 {
@@ -286,6 +619,52 @@ func (h *PrinterHandler) UpdatePrinter(c *gin.Context) {
 	if req.GapMM != 0 {
 		printer.GapMM = req.GapMM
 	}
+	if req.DefaultTemplateID != nil {
+		defaultTemplateID, ok := h.resolveDefaultTemplate(c, req.DefaultTemplateID)
+		if !ok {
+			return
+		}
+		printer.DefaultTemplateID = defaultTemplateID
+	}
+	if req.LineEnding != "" {
+		printer.LineEnding = req.LineEnding
+	}
+	if req.Encoding != "" {
+		if err := core.ValidatePrinterOutput(printer.LineEnding, req.Encoding); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+			return
+		}
+		printer.Encoding = req.Encoding
+	}
+	if req.FallbackPrinterID != nil {
+		fallbackPrinterID, ok := h.resolveFallbackPrinter(c, id, req.FallbackPrinterID)
+		if !ok {
+			return
+		}
+		printer.FallbackPrinterID = fallbackPrinterID
+	}
+	if req.Group != nil {
+		printer.Group = strings.TrimSpace(*req.Group)
+	}
+	if req.FeedOnError != "" {
+		printer.FeedOnError = req.FeedOnError
+	}
+	if req.InitCommands != nil {
+		if err := core.ValidateInitCommands(*req.InitCommands); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+			return
+		}
+		printer.InitCommands = *req.InitCommands
+	}
+	if req.StatusLength != 0 {
+		printer.StatusLength = req.StatusLength
+	}
 
 	err = db.Printers.UpdatePrinter(c.Request.Context(), printer)
 	if err != nil {
@@ -296,19 +675,7 @@ func (h *PrinterHandler) UpdatePrinter(c *gin.Context) {
 		return
 	}
 
-	corePrinter := &core.Printer{
-		ID:            printer.ID,
-		Name:          printer.Name,
-		IPAddress:     printer.IPAddress,
-		Port:          printer.Port,
-		DPI:           printer.DPI,
-		LabelWidthMM:  printer.LabelWidthMM,
-		LabelHeightMM: printer.LabelHeightMM,
-		GapMM:         printer.GapMM,
-		Status:        printer.Status,
-		LastSeenAt:    printer.LastSeenAt,
-		TotalPrints:   printer.TotalPrints,
-	}
+	corePrinter := toCorePrinter(printer)
 
 	if err := h.printerManager.UpdatePrinter(corePrinter); err != nil {
 		if err == core.ErrPrinterNotFound {
@@ -320,6 +687,8 @@ func (h *PrinterHandler) UpdatePrinter(c *gin.Context) {
 		}
 	}
 
+	recordAudit(c, "update", "printer", printer.ID, req)
+
 	c.JSON(http.StatusOK, h.printerToResponse(printer))
 }
 
@@ -333,26 +702,16 @@ func (h *PrinterHandler) DeletePrinter(c *gin.Context) {
 		return
 	}
 
-	var pendingCount int
-	err = h.db.QueryRowContext(c.Request.Context(),
-		"SELECT COUNT(*) FROM print_jobs WHERE printer_id = ? AND status IN ('pending', 'processing')", id).Scan(&pendingCount)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to check for pending jobs",
-		})
-		return
-	}
-
-	if pendingCount > 0 {
-		c.JSON(http.StatusConflict, ErrorResponse{
-			Error:   "has_pending_jobs",
-			Message: fmt.Sprintf("Cannot delete printer with %d pending jobs", pendingCount),
+	var query ForceDeleteQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	_, err = db.Printers.GetPrinterByID(c.Request.Context(), id)
+	printer, err := db.Printers.GetPrinterByID(c.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, ErrorResponse{
@@ -368,6 +727,37 @@ func (h *PrinterHandler) DeletePrinter(c *gin.Context) {
 		return
 	}
 
+	var cancelled, failed int64
+	if query.Force {
+		cancelled, failed, err = clearBlockingJobs(c.Request.Context(), h.db, "printer_id", id, "printer deleted")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to cancel pending jobs",
+			})
+			return
+		}
+	}
+
+	var pendingCount int
+	err = h.db.QueryRowContext(c.Request.Context(),
+		"SELECT COUNT(*) FROM print_jobs WHERE printer_id = ? AND status IN ('pending', 'processing')", id).Scan(&pendingCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to check for pending jobs",
+		})
+		return
+	}
+
+	if pendingCount > 0 {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "has_pending_jobs",
+			Message: fmt.Sprintf("Cannot delete printer with %d pending jobs", pendingCount),
+		})
+		return
+	}
+
 	err = db.Printers.DeletePrinter(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -382,6 +772,14 @@ func (h *PrinterHandler) DeletePrinter(c *gin.Context) {
 		}
 	}
 
+	details := gin.H{"name": printer.Name, "ip_address": printer.IPAddress}
+	if query.Force {
+		details["force"] = true
+		details["cancelled_jobs"] = cancelled
+		details["failed_jobs"] = failed
+	}
+	recordAudit(c, "delete", "printer", id, details)
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -395,7 +793,7 @@ func (h *PrinterHandler) GetPrinterStatus(c *gin.Context) {
 		return
 	}
 
-	status, err := h.printerManager.CheckStatus(id)
+	status, err := h.printerManager.CheckStatus(c.Request.Context(), id)
 	if err != nil {
 		if err == core.ErrPrinterNotFound {
 			c.JSON(http.StatusNotFound, ErrorResponse{
@@ -451,10 +849,15 @@ func (h *PrinterHandler) GetPrinterStatus(c *gin.Context) {
 		IsOnline:     status.IsOnline,
 		CanPrint:     status.CanPrint,
 		LastChecked:  status.LastChecked,
+
+		HeadTemperatureC: status.HeadTemperatureC,
+		MediaDetail:      status.MediaDetail,
 	})
 }
 
-func (h *PrinterHandler) TestPrinter(c *gin.Context) {
+// GetPrinterRawStatus queries a printer's status and returns the raw
+// response bytes, for diagnosing states the status tables do not know.
+func (h *PrinterHandler) GetPrinterRawStatus(c *gin.Context) {
 	id, err := h.parsePrinterID(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -464,46 +867,279 @@ func (h *PrinterHandler) TestPrinter(c *gin.Context) {
 		return
 	}
 
-	var req TestPrintRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-	}
-
-	printer, err := db.Printers.GetPrinterByID(c.Request.Context(), id)
+	status, err := h.printerManager.CheckStatus(c.Request.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		switch {
+		case err == core.ErrPrinterNotFound:
 			c.JSON(http.StatusNotFound, ErrorResponse{
 				Error:   "not_found",
 				Message: "Printer not found",
 			})
-			return
+		case errors.Is(err, core.ErrInvalidStatus):
+			c.JSON(http.StatusBadGateway, ErrorResponse{
+				Error:   "invalid_status",
+				Message: "Printer sent a short status response",
+			})
+		case errors.Is(err, core.ErrPrinterOffline), errors.Is(err, core.ErrConnectionFailed):
+			c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "printer_offline",
+				Message: "Printer is not reachable",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "status_failed",
+				Message: err.Error(),
+			})
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to retrieve printer",
-		})
 		return
 	}
 
-	var tsplContent string
+	c.JSON(http.StatusOK, PrinterRawStatusResponse{
+		ID:           id,
+		Raw:          hex.EncodeToString(status.RawStatus),
+		PrinterState: status.PrinterState,
+		Warning:      status.Warning,
+		Error:        status.Error,
+		MediaError:   status.MediaError,
+		LastChecked:  status.LastChecked,
 
-	if req.TemplateID != 0 {
-		template, err := db.Templates.GetTemplateByID(c.Request.Context(), req.TemplateID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusBadRequest, ErrorResponse{
-					Error:   "template_not_found",
-					Message: "Specified template not found",
-				})
-				return
-			}
+		HeadTemperatureC: status.HeadTemperatureC,
+		MediaDetail:      status.MediaDetail,
+	})
+}
+
+func (h *PrinterHandler) GetPrinterInfo(c *gin.Context) {
+	id, err := h.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	info, err := h.printerManager.GetPrinterInfo(id)
+	if err != nil {
+		switch {
+		case err == core.ErrPrinterNotFound:
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+		case err == core.ErrNotNetworkPrinter:
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "not_network_printer",
+				Message: "File printers cannot be queried",
+			})
+		case errors.Is(err, core.ErrPrinterOffline), errors.Is(err, core.ErrConnectionFailed):
+			c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "printer_offline",
+				Message: "Printer is not reachable",
+			})
+		default:
 			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to retrieve template",
+				Error:   "info_failed",
+				Message: err.Error(),
 			})
-			return
 		}
+		return
+	}
 
-		generator := core.NewTSPL2Generator()
+	c.JSON(http.StatusOK, PrinterInfoResponse{
+		ID:        id,
+		Model:     info.Model,
+		Firmware:  info.Firmware,
+		Mileage:   info.Mileage,
+		Codepage:  info.Codepage,
+		Complete:  info.Complete,
+		QueriedAt: info.QueriedAt,
+	})
+}
+
+// GetPrinterConfig reads the label size, gap, speed and density stored on
+// the printer itself, which may differ from what spool has recorded.
+func (h *PrinterHandler) GetPrinterConfig(c *gin.Context) {
+	id, err := h.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	cfg, err := h.printerManager.GetPrinterConfig(id)
+	if err != nil {
+		switch {
+		case err == core.ErrPrinterNotFound:
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+		case err == core.ErrNotNetworkPrinter:
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "not_network_printer",
+				Message: "File printers cannot be queried",
+			})
+		case errors.Is(err, core.ErrPrinterOffline), errors.Is(err, core.ErrConnectionFailed):
+			c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "printer_offline",
+				Message: "Printer is not reachable",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "config_failed",
+				Message: err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, PrinterConfigResponse{
+		ID:            id,
+		LabelWidthMM:  cfg.LabelWidthMM,
+		LabelHeightMM: cfg.LabelHeightMM,
+		GapMM:         cfg.GapMM,
+		Speed:         cfg.Speed,
+		Density:       cfg.Density,
+		QueriedAt:     cfg.QueriedAt,
+	})
+}
+
+// TestConnection probes a printer address before it is saved. Nothing is
+// written to the database and the printer is not added to the manager.
+func (h *PrinterHandler) TestConnection(c *gin.Context) {
+	var req PrinterConnectionTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.printerManager.ValidateAddress(req.IPAddress); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.probe(req.IPAddress, req.Port)
+	if err != nil {
+		status := "offline"
+		if errors.Is(err, core.ErrTimeout) {
+			status = "timeout"
+		}
+		c.JSON(http.StatusOK, PrinterConnectionTestResponse{
+			Status:  status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp := PrinterConnectionTestResponse{
+		Reachable:     true,
+		Status:        result.StatusString,
+		Model:         result.Model,
+		Firmware:      result.Firmware,
+		LabelWidthMM:  result.LabelWidthMM,
+		LabelHeightMM: result.LabelHeightMM,
+	}
+	if result.Status == nil {
+		resp.Message = "Printer accepted the connection but did not answer the status query"
+	} else {
+		resp.Online = result.Status.IsOnline
+		resp.PrinterState = result.Status.PrinterState
+		resp.Warning = result.Status.Warning
+		resp.Error = result.Status.Error
+		resp.MediaError = result.Status.MediaError
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RefreshPrinters checks every printer's status now rather than waiting for
+// the next health check, and reports the outcome for each printer.
+func (h *PrinterHandler) RefreshPrinters(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), printerRefreshTimeout)
+	defer cancel()
+
+	refreshed := h.printerManager.RefreshStatuses(ctx, printerRefreshParallelism)
+	results := make([]PrinterRefreshResult, 0, len(refreshed))
+	for _, r := range refreshed {
+		result := PrinterRefreshResult{ID: r.PrinterID, Name: r.Name, Status: r.Status}
+		if r.Err != nil {
+			result.Message = r.Err.Error()
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (h *PrinterHandler) TestPrinter(c *gin.Context) {
+	id, err := h.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	var req TestPrintRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+	}
+
+	printer, err := db.Printers.GetPrinterByID(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve printer",
+		})
+		return
+	}
+
+	var tsplContent string
+
+	templateID := req.TemplateID
+	usingDefault := false
+	if templateID == 0 && printer.DefaultTemplateID != nil {
+		templateID = *printer.DefaultTemplateID
+		usingDefault = true
+	}
+
+	if templateID != 0 {
+		template, err := db.Templates.GetTemplateByID(c.Request.Context(), templateID)
+		// The printer's default is shared; a named template must be the caller's.
+		if err == nil && !usingDefault && !namespaceVisible(c, template.Namespace) {
+			err = sql.ErrNoRows
+		}
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Error:   "template_not_found",
+					Message: "Specified template not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to retrieve template",
+			})
+			return
+		}
+
+		generator := core.NewTSPL2Generator()
 		schema, err := generator.ParseSchema(template.SchemaJSON)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -517,7 +1153,11 @@ func (h *PrinterHandler) TestPrinter(c *gin.Context) {
 			req.Variables = make(map[string]string)
 		}
 
-		tsplContent, err = generator.Generate(schema, req.Variables)
+		if usingDefault && len(req.Variables) == 0 {
+			tsplContent, err = generator.GeneratePreview(schema)
+		} else {
+			tsplContent, err = generator.Generate(schema, generator.ApplyServerVariables(schema, req.Variables))
+		}
 		if err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse{
 				Error:   "generation_error",
@@ -529,7 +1169,7 @@ func (h *PrinterHandler) TestPrinter(c *gin.Context) {
 		tsplContent = h.generateTestLabel(printer)
 	}
 
-	err = h.printerManager.Print(id, tsplContent, 1)
+	err = h.printerManager.Print(c.Request.Context(), id, tsplContent, 1)
 	if err != nil {
 		switch err {
 		case core.ErrPrinterNotFound:
@@ -562,6 +1202,243 @@ func (h *PrinterHandler) TestPrinter(c *gin.Context) {
 	})
 }
 
+// RawPrint queues hand-written TSPL for a printer. The job carries no
+// template, so the queue sends the content exactly as submitted.
+func (h *PrinterHandler) RawPrint(c *gin.Context) {
+	id, err := h.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	var req RawPrintRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if h.queue == nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "queue_unavailable",
+			Message: "Job queue is not configured",
+		})
+		return
+	}
+
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	printer, err := db.Printers.GetPrinterByID(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve printer",
+		})
+		return
+	}
+
+	if printer.Status == "paused" || printer.Status == "offline" {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "printer_unavailable",
+			Message: fmt.Sprintf("Printer is %s", printer.Status),
+		})
+		return
+	}
+
+	copies := req.Copies
+	if copies < 1 {
+		copies = 1
+	}
+	if status, message := checkCopiesLimit(c, h.queue, copies); status != 0 {
+		c.JSON(status, ErrorResponse{
+			Error:   "too_many_copies",
+			Message: message,
+		})
+		return
+	}
+
+	if overQuota(c, h.quota, 1) {
+		c.JSON(http.StatusTooManyRequests, ErrorResponse{
+			Error:   "quota_exceeded",
+			Message: quotaExceededMessage,
+		})
+		return
+	}
+
+	job := &core.Job{
+		PrinterID:   id,
+		TSPLContent: req.TSPL,
+		Copies:      copies,
+		SubmittedBy: c.ClientIP(),
+		RequestID:   logging.RequestID(c.Request.Context()),
+		Namespace:   middleware.CallerNamespace(c),
+		Status:      core.JobStatusPending,
+	}
+
+	jobID, err := h.queue.Enqueue(job)
+	if err != nil {
+		refundQuota(c, h.quota, 1)
+		if errors.Is(err, core.ErrTSPLTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{
+				Error:   "tspl_too_large",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "queue_error",
+			Message: "Failed to enqueue job",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, RawPrintResponse{JobID: jobID})
+}
+
+// RetryFailedJobs requeues every transient failure for a printer, typically
+// after a jam or media problem has been cleared.
+func (h *PrinterHandler) RetryFailedJobs(c *gin.Context) {
+	id, err := h.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	if h.queue == nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "queue_unavailable",
+			Message: "Job queue is not configured",
+		})
+		return
+	}
+
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	if _, err := db.Printers.GetPrinterByID(c.Request.Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve printer",
+		})
+		return
+	}
+
+	requeued, err := h.queue.RetryAllFailed(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "queue_error",
+			Message: "Failed to requeue failed jobs",
+		})
+		return
+	}
+
+	recordAudit(c, "retry_failed", "printer", id, gin.H{"requeued": requeued})
+
+	c.JSON(http.StatusOK, gin.H{"requeued": requeued})
+}
+
+// ReprintRecent reprints the last few completed jobs for a printer, oldest
+// first, to replace labels lost to a ribbon or media jam.
+func (h *PrinterHandler) ReprintRecent(c *gin.Context) {
+	id, err := h.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	var req ReprintRecentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if h.queue == nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "queue_unavailable",
+			Message: "Job queue is not configured",
+		})
+		return
+	}
+
+	if rejectIfMaintenance(c) {
+		return
+	}
+
+	if _, err := db.Printers.GetPrinterByID(c.Request.Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve printer",
+		})
+		return
+	}
+
+	if overQuota(c, h.quota, req.Count) {
+		c.JSON(http.StatusTooManyRequests, ErrorResponse{
+			Error:   "quota_exceeded",
+			Message: quotaExceededMessage,
+		})
+		return
+	}
+
+	reprints, err := h.queue.ReprintRecent(c.Request.Context(), id, req.Count, req.IncludeFailed)
+	refundQuota(c, h.quota, req.Count-len(reprints))
+	resp := ReprintRecentResponse{Reprinted: make([]ReprintedJob, 0, len(reprints))}
+	for _, r := range reprints {
+		resp.Reprinted = append(resp.Reprinted, ReprintedJob{OriginalJobID: r.OriginalJobID, NewJobID: r.NewJobID})
+	}
+	if len(resp.Reprinted) > 0 {
+		recordAudit(c, "reprint_recent", "printer", id, resp)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":     "queue_error",
+			"message":   "Failed to reprint recent jobs",
+			"reprinted": resp.Reprinted,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (h *PrinterHandler) PausePrinter(c *gin.Context) {
 	id, err := h.parsePrinterID(c)
 	if err != nil {
@@ -626,6 +1503,110 @@ func (h *PrinterHandler) ResumePrinter(c *gin.Context) {
 	})
 }
 
+// CalibratePrinter recalibrates a printer's media sensor for the loaded
+// media and responds once the printer has finished feeding.
+func (h *PrinterHandler) CalibratePrinter(c *gin.Context) {
+	id, err := h.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	var req CalibratePrinterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	command, _ := core.CalibrationCommand(req.MediaType)
+	status, err := h.printerManager.Calibrate(c.Request.Context(), id, req.MediaType)
+	if err != nil {
+		switch {
+		case err == core.ErrPrinterNotFound:
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+		case err == core.ErrNotNetworkPrinter:
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "not_network_printer",
+				Message: "File printers cannot be calibrated",
+			})
+		case errors.Is(err, core.ErrCalibrationFailed):
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "calibration_failed",
+				Message: err.Error(),
+			})
+		case errors.Is(err, core.ErrTimeout):
+			c.JSON(http.StatusGatewayTimeout, ErrorResponse{
+				Error:   "calibration_timeout",
+				Message: "Printer did not finish calibrating in time",
+			})
+		case errors.Is(err, core.ErrPrinterOffline), errors.Is(err, core.ErrConnectionFailed):
+			c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "printer_offline",
+				Message: "Printer is not reachable",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "calibration_error",
+				Message: err.Error(),
+			})
+		}
+		return
+	}
+
+	recordAudit(c, "calibrate", "printer", id, gin.H{"media_type": req.MediaType})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"media_type":    req.MediaType,
+		"command":       command,
+		"printer_state": status.PrinterState,
+	})
+}
+
+// RecountPrinterPrints corrects a printer's total_prints, which can drift
+// when an increment fails, by recomputing it from the print counters.
+func (h *PrinterHandler) RecountPrinterPrints(c *gin.Context) {
+	id, err := h.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	previous, total, err := h.printerManager.RecountTotalPrints(id)
+	if err != nil {
+		if err == core.ErrPrinterNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "recount_error",
+			Message: "Failed to recount prints",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":                    id,
+		"previous_total_prints": previous,
+		"total_prints":          total,
+	})
+}
+
 func (h *PrinterHandler) GetPrinterCounters(c *gin.Context) {
 	id, err := h.parsePrinterID(c)
 	if err != nil {
@@ -652,6 +1633,19 @@ func (h *PrinterHandler) GetPrinterCounters(c *gin.Context) {
 		return
 	}
 
+	switch granularity := c.DefaultQuery("granularity", "day"); granularity {
+	case "day":
+	case "hour":
+		h.getHourlyCounters(c, id)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_granularity",
+			Message: fmt.Sprintf("granularity must be day or hour, got %q", granularity),
+		})
+		return
+	}
+
 	now := time.Now()
 	thirtyDaysAgo := now.AddDate(0, 0, -30)
 
@@ -689,6 +1683,63 @@ func (h *PrinterHandler) GetPrinterCounters(c *gin.Context) {
 	})
 }
 
+// getHourlyCounters writes the printer's hourly counts between the RFC 3339
+// from and to query parameters, the last 24 hours by default.
+func (h *PrinterHandler) getHourlyCounters(c *gin.Context, id int64) {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_range",
+				Message: fmt.Sprintf("%s must be an RFC 3339 time", param.name),
+			})
+			return
+		}
+		*param.dst = t.Local()
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_range",
+			Message: "to must not be before from",
+		})
+		return
+	}
+
+	counters, err := db.Counters.GetHourlyCounters(c.Request.Context(), id, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve counters",
+		})
+		return
+	}
+
+	var total int64
+	byHour := make([]HourlyCounterEntry, 0, len(counters))
+	for _, counter := range counters {
+		total += counter.Count
+		byHour = append(byHour, HourlyCounterEntry{Hour: counter.Hour, Count: counter.Count})
+	}
+
+	c.JSON(http.StatusOK, PrinterHourlyCountersResponse{
+		PrinterID:   id,
+		Granularity: "hour",
+		From:        from,
+		To:          to,
+		Total:       total,
+		ByHour:      byHour,
+	})
+}
+
 func (h *PrinterHandler) parsePrinterID(c *gin.Context) (int64, error) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -704,21 +1755,142 @@ func (h *PrinterHandler) parsePrinterID(c *gin.Context) (int64, error) {
 func (h *PrinterHandler) printerToResponse(p *db.Printer) PrinterResponse {
 	canPrint := p.Status == "online" || p.Status == "idle" || p.Status == "standby"
 	return PrinterResponse{
-		ID:            p.ID,
-		Name:          p.Name,
-		IPAddress:     p.IPAddress,
-		Port:          p.Port,
-		DPI:           p.DPI,
-		LabelWidthMM:  p.LabelWidthMM,
-		LabelHeightMM: p.LabelHeightMM,
-		GapMM:         p.GapMM,
-		Status:        p.Status,
-		CanPrint:      canPrint,
-		LastSeenAt:    p.LastSeenAt,
-		TotalPrints:   p.TotalPrints,
-		CreatedAt:     p.CreatedAt,
-		UpdatedAt:     p.UpdatedAt,
+		ID:                p.ID,
+		Name:              p.Name,
+		IPAddress:         p.IPAddress,
+		Port:              p.Port,
+		DPI:               p.DPI,
+		LabelWidthMM:      p.LabelWidthMM,
+		LabelHeightMM:     p.LabelHeightMM,
+		GapMM:             p.GapMM,
+		Status:            p.Status,
+		CanPrint:          canPrint,
+		LastSeenAt:        p.LastSeenAt,
+		TotalPrints:       p.TotalPrints,
+		DefaultTemplateID: p.DefaultTemplateID,
+		LineEnding:        p.LineEnding,
+		Encoding:          p.Encoding,
+		FallbackPrinterID: p.FallbackPrinterID,
+		Group:             p.Group,
+		FeedOnError:       p.FeedOnError,
+		InitCommands:      p.InitCommands,
+		StatusLength:      p.StatusLength,
+		Type:              p.Type,
+		SpoolDir:          p.SpoolDir,
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+	}
+}
+
+// resolveFallbackPrinter checks that a requested fallback printer exists and
+// is not the printer itself. A zero ID clears the fallback. It writes the
+// error response itself and returns false when the request should stop.
+func (h *PrinterHandler) resolveFallbackPrinter(c *gin.Context, printerID int64, id *int64) (*int64, bool) {
+	if id == nil || *id == 0 {
+		return nil, true
+	}
+
+	if *id == printerID {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: "A printer cannot be its own fallback",
+		})
+		return nil, false
+	}
+
+	_, err := db.Printers.GetPrinterByID(c.Request.Context(), *id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "fallback_not_found",
+				Message: "Fallback printer not found",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve fallback printer",
+		})
+		return nil, false
+	}
+
+	return id, true
+}
+
+// validatePrinterTarget checks that a printer of printerType is given
+// where to print: an address for a network printer, a spool directory under
+// the spool root for a file printer. It returns a message describing the
+// problem, or "".
+func (h *PrinterHandler) validatePrinterTarget(printerType, ipAddress, spoolDir string) string {
+	if printerType == core.PrinterTypeFile {
+		if ipAddress != "" {
+			return "file printers take spool_dir instead of ip_address"
+		}
+		if err := h.printerManager.ValidateSpoolDir(spoolDir); err != nil {
+			return err.Error()
+		}
+		return ""
+	}
+	if spoolDir != "" {
+		return "spool_dir is only used by file printers"
+	}
+	if ipAddress == "" {
+		return "ip_address is required"
+	}
+	return ""
+}
+
+func toCorePrinter(p *db.Printer) *core.Printer {
+	return &core.Printer{
+		ID:                p.ID,
+		Name:              p.Name,
+		IPAddress:         p.IPAddress,
+		Port:              p.Port,
+		DPI:               p.DPI,
+		LabelWidthMM:      p.LabelWidthMM,
+		LabelHeightMM:     p.LabelHeightMM,
+		GapMM:             p.GapMM,
+		Status:            p.Status,
+		LastSeenAt:        p.LastSeenAt,
+		TotalPrints:       p.TotalPrints,
+		DefaultTemplateID: p.DefaultTemplateID,
+		LineEnding:        p.LineEnding,
+		Encoding:          p.Encoding,
+		FallbackPrinterID: p.FallbackPrinterID,
+		Group:             p.Group,
+		FeedOnError:       p.FeedOnError,
+		InitCommands:      p.InitCommands,
+		StatusLength:      p.StatusLength,
+		Type:              p.Type,
+		SpoolDir:          p.SpoolDir,
+	}
+}
+
+// resolveDefaultTemplate checks that a requested default template exists.
+// A zero ID clears the default. It writes the error response itself and
+// returns false when the request should stop.
+func (h *PrinterHandler) resolveDefaultTemplate(c *gin.Context, id *int64) (*int64, bool) {
+	if id == nil || *id == 0 {
+		return nil, true
 	}
+
+	_, err := db.Templates.GetTemplateByID(c.Request.Context(), *id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "template_not_found",
+				Message: "Default template not found",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve template",
+		})
+		return nil, false
+	}
+
+	return id, true
 }
 
 func (h *PrinterHandler) generateTestLabel(p *db.Printer) string {
@@ -734,12 +1906,36 @@ func (h *PrinterHandler) generateTestLabel(p *db.Printer) string {
 	centerY := height / 2
 
 	return fmt.Sprintf(`SIZE %d dot,%d dot
-GAP %.0f mm,0 mm
+GAP %s mm,0 mm
 DIRECTION 0
 CLS
 TEXT %d,%d,"3",0,2,2,"TEST LABEL"
 TEXT %d,%d,"3",0,1,1,"Printer: %s"
-BARCODE %d,%d,"128",60,0,2,2,2,"%s"
+BARCODE %d,%d,"128",60,1,0,2,2,"%s"
 PRINT 1
-`, width, height, p.GapMM, centerX-80, centerY-40, centerX-100, centerY+20, p.Name, centerX-100, centerY+60, p.IPAddress)
+`, width, height, core.FormatMM(p.GapMM), centerX-80, centerY-40, centerX-100, centerY+20, p.Name, centerX-100, centerY+60, p.IPAddress)
+}
+
+func RegisterPrinterRoutes(r *gin.RouterGroup, h *PrinterHandler) {
+	operator := middleware.RequireRole(middleware.RoleOperator)
+	r.GET("/printers", h.ListPrinters)
+	r.POST("/printers", operator, h.CreatePrinter)
+	r.POST("/printers/test-connection", operator, h.TestConnection)
+	r.POST("/printers/refresh", operator, h.RefreshPrinters)
+	r.GET("/printers/:id", h.GetPrinter)
+	r.PUT("/printers/:id", operator, h.UpdatePrinter)
+	r.DELETE("/printers/:id", operator, h.DeletePrinter)
+	r.GET("/printers/:id/status", h.GetPrinterStatus)
+	r.GET("/printers/:id/status/raw", h.GetPrinterRawStatus)
+	r.GET("/printers/:id/info", h.GetPrinterInfo)
+	r.GET("/printers/:id/config", h.GetPrinterConfig)
+	r.POST("/printers/:id/test", operator, h.TestPrinter)
+	r.POST("/printers/:id/raw", operator, h.RawPrint)
+	r.POST("/printers/:id/retry-failed", operator, h.RetryFailedJobs)
+	r.POST("/printers/:id/reprint-recent", operator, h.ReprintRecent)
+	r.POST("/printers/:id/pause", operator, h.PausePrinter)
+	r.POST("/printers/:id/resume", operator, h.ResumePrinter)
+	r.POST("/printers/:id/calibrate", operator, h.CalibratePrinter)
+	r.GET("/printers/:id/counters", h.GetPrinterCounters)
+	r.POST("/printers/:id/recount", operator, h.RecountPrinterPrints)
 }
@@ -0,0 +1,809 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+)
+
+// fakePrinter listens like a networked label printer: it answers status
+// queries as a ready printer and records everything else it is sent.
+// Commands in replies are answered with their reply instead of recorded.
+type fakePrinter struct {
+	ln      net.Listener
+	replies map[string]string
+
+	mu       sync.Mutex
+	received bytes.Buffer
+}
+
+func startFakePrinter(t *testing.T) *fakePrinter {
+	t.Helper()
+
+	return startReplyingFakePrinter(t, nil)
+}
+
+// startReplyingFakePrinter starts a fake printer that answers the commands
+// in replies.
+func startReplyingFakePrinter(t *testing.T, replies map[string]string) *fakePrinter {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakePrinter{ln: ln, replies: replies}
+	var wg sync.WaitGroup
+	var conns []net.Conn
+	var connsMu sync.Mutex
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			connsMu.Lock()
+			conns = append(conns, conn)
+			connsMu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f.serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		connsMu.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		connsMu.Unlock()
+		wg.Wait()
+	})
+	return f
+}
+
+func (f *fakePrinter) serve(conn net.Conn) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		data := string(buf[:n])
+		if reply, ok := f.replies[data]; ok {
+			if _, err := conn.Write([]byte(reply)); err != nil {
+				return
+			}
+			continue
+		}
+		queries := strings.Count(data, "\x1b!?")
+		data = strings.ReplaceAll(data, "\x1b!?", "")
+
+		f.mu.Lock()
+		f.received.WriteString(data)
+		f.mu.Unlock()
+		for i := 0; i < queries; i++ {
+			if _, err := conn.Write([]byte("@@@@")); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// addr returns the host and port the printer listens on.
+func (f *fakePrinter) addr() (string, int) {
+	addr := f.ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// waitFor waits until the printer has received text and returns all it has
+// received.
+func (f *fakePrinter) waitFor(t *testing.T, text string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f.mu.Lock()
+		got := f.received.String()
+		f.mu.Unlock()
+		if strings.Contains(got, text) || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// insertFakePrinter adds a printer that points at fake and returns its ID.
+func insertFakePrinter(t *testing.T, database *sql.DB, fake *fakePrinter, name string) int64 {
+	t.Helper()
+
+	host, port := fake.addr()
+	result, err := database.Exec(`INSERT INTO printers (name, ip_address, port, status, label_width_mm, label_height_mm)
+		VALUES (?, ?, ?, 'online', 50, 30)`, name, host, port)
+	if err != nil {
+		t.Fatalf("insert printer: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+// startPrinterManager starts a printer manager for the printers in
+// database.
+func startPrinterManager(t *testing.T, database *sql.DB) *core.PrinterManager {
+	t.Helper()
+
+	pm := core.NewPrinterManager(database, &config.PrintersConfig{
+		HealthCheckInterval: time.Hour,
+		ConnectionTimeout:   2 * time.Second,
+	}, nil)
+	pm.Start()
+	t.Cleanup(pm.Stop)
+	return pm
+}
+
+// queuePrinters lets the queue print through a real printer manager.
+// PrinterManager.Print already counts the prints, so IncrementPrintCount
+// has nothing left to do.
+type queuePrinters struct {
+	*core.PrinterManager
+}
+
+func (queuePrinters) IncrementPrintCount(int64, int) error { return nil }
+
+// newPrinterRouter starts a printer manager for the printers in database
+// and serves the printer routes from it.
+func newPrinterRouter(t *testing.T, database *sql.DB) *gin.Engine {
+	t.Helper()
+
+	h := NewPrinterHandler(database, startPrinterManager(t, database))
+	router := newTestRouter()
+	router.POST("/api/printers", h.CreatePrinter)
+	router.POST("/api/printers/refresh", h.RefreshPrinters)
+	router.PUT("/api/printers/:id", h.UpdatePrinter)
+	router.POST("/api/printers/:id/test", h.TestPrinter)
+	router.DELETE("/api/printers/:id", h.DeletePrinter)
+	return router
+}
+
+func TestTestPrinterUsesDefaultTemplate(t *testing.T) {
+	database := setupTestDB(t)
+	fake := startFakePrinter(t)
+	printerID := insertFakePrinter(t, database, fake, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	if _, err := database.Exec("UPDATE printers SET default_template_id = ? WHERE id = ?", templateID, printerID); err != nil {
+		t.Fatalf("set default template: %v", err)
+	}
+	router := newPrinterRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/test", printerID), map[string]any{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("test print: %d %s", w.Code, w.Body)
+	}
+	// Without variables the template prints with sample values.
+	got := fake.waitFor(t, `"Sample name"`)
+	if !strings.Contains(got, `TEXT 10,10,"3",0,1,1,"Sample name"`) {
+		t.Errorf("printer received %q, want the default template", got)
+	}
+	if strings.Contains(got, "TEST LABEL") {
+		t.Errorf("printer received the built-in test label, want the default template")
+	}
+
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/test", printerID), map[string]any{
+		"variables": map[string]string{"name": "WIDGET"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("test print with variables: %d %s", w.Code, w.Body)
+	}
+	if got := fake.waitFor(t, `"WIDGET"`); !strings.Contains(got, `"WIDGET"`) {
+		t.Errorf("printer received %q, want the default template with the given variables", got)
+	}
+}
+
+func TestTestPrinterWithoutDefaultTemplate(t *testing.T) {
+	database := setupTestDB(t)
+	fake := startFakePrinter(t)
+	printerID := insertFakePrinter(t, database, fake, "printer")
+	router := newPrinterRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/test", printerID), map[string]any{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("test print: %d %s", w.Code, w.Body)
+	}
+	if got := fake.waitFor(t, "TEST LABEL"); !strings.Contains(got, `"TEST LABEL"`) {
+		t.Errorf("printer received %q, want the built-in test label", got)
+	}
+}
+
+func TestUpdatePrinterRejectsUnknownDefaultTemplate(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	router := newPrinterRouter(t, database)
+
+	w := serveJSON(router, http.MethodPut, fmt.Sprintf("/api/printers/%d", printerID), map[string]any{
+		"default_template_id": 9999,
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("update with unknown template: %d %s, want 400", w.Code, w.Body)
+	}
+
+	var defaultTemplateID sql.NullInt64
+	if err := database.QueryRow("SELECT default_template_id FROM printers WHERE id = ?", printerID).Scan(&defaultTemplateID); err != nil {
+		t.Fatalf("read printer: %v", err)
+	}
+	if defaultTemplateID.Valid {
+		t.Errorf("default_template_id is %d, want it unset", defaultTemplateID.Int64)
+	}
+}
+
+func TestRawPrintSendsExactTSPL(t *testing.T) {
+	database := setupTestDB(t)
+	fake := startFakePrinter(t)
+	printerID := insertFakePrinter(t, database, fake, "printer")
+	pm := startPrinterManager(t, database)
+	queue := core.NewQueue(database, queuePrinters{pm}, nil, nil, nil)
+	if err := queue.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	t.Cleanup(queue.Stop)
+
+	h := NewPrinterHandler(database, pm)
+	h.SetQueue(queue)
+	router := newTestRouter()
+	RegisterPrinterRoutes(router.Group("/api"), h)
+	jobs, _ := newJobRouter(t, database, nil)
+
+	tspl := "SIZE 50 mm, 30 mm\nCLS\nTEXT 5,5,\"0\",0,1,1,\"HAND WRITTEN\"\nPRINT 1\n"
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/raw", printerID), map[string]any{"tspl": tspl})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("raw print: %d %s", w.Code, w.Body)
+	}
+	if got := fake.waitFor(t, "PRINT 1"); got != tspl {
+		t.Errorf("printer received %q, want exactly %q", got, tspl)
+	}
+
+	listed := listJobs(t, jobs, "/api/jobs")
+	if len(listed) != 1 || !listed[0].Raw || listed[0].TemplateID != 0 {
+		t.Errorf("job history is %+v, want one raw job", listed)
+	}
+}
+
+func TestCRLFPrinterReceivesCRLFThroughout(t *testing.T) {
+	database := setupTestDB(t)
+	fake := startFakePrinter(t)
+	printerID := insertFakePrinter(t, database, fake, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	if _, err := database.Exec("UPDATE printers SET line_ending = 'crlf', encoding = '1252', default_template_id = ? WHERE id = ?",
+		templateID, printerID); err != nil {
+		t.Fatalf("configure printer: %v", err)
+	}
+	router := newPrinterRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/test", printerID), map[string]any{
+		"variables": map[string]string{"name": "Café"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("test print: %d %s", w.Code, w.Body)
+	}
+	got := fake.waitFor(t, "PRINT")
+	if !strings.Contains(got, "\"Caf\xe9\"") {
+		t.Errorf("printer received %q, want the text encoded as Windows-1252", got)
+	}
+	if strings.Count(got, "\n") == 0 || strings.Count(got, "\n") != strings.Count(got, "\r\n") {
+		t.Errorf("printer received %q, want every line terminated with CRLF", got)
+	}
+}
+
+func TestUpdatePrinterRejectsUnknownEncoding(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	router := newPrinterRouter(t, database)
+
+	w := serveJSON(router, http.MethodPut, fmt.Sprintf("/api/printers/%d", printerID), map[string]any{
+		"encoding": "EBCDIC",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("update with unknown encoding: %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestTestConnectionDoesNotAddPrinter(t *testing.T) {
+	database := setupTestDB(t)
+	h := NewPrinterHandler(database, startPrinterManager(t, database))
+	router := newTestRouter()
+	RegisterPrinterRoutes(router.Group("/api"), h)
+
+	// A private address with nothing behind it.
+	w := serveJSON(router, http.MethodPost, "/api/printers/test-connection", map[string]any{
+		"ip_address": "10.255.255.1", "port": 9100,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("test connection: %d %s", w.Code, w.Body)
+	}
+	var resp PrinterConnectionTestResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Reachable || resp.Status != "offline" || resp.Message == "" {
+		t.Errorf("unreachable printer reported as %+v, want unreachable and offline with a message", resp)
+	}
+
+	w = serveJSON(router, http.MethodPost, "/api/printers/test-connection", map[string]any{"ip_address": "printer.local"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("test connection to a host name: %d %s, want 400", w.Code, w.Body)
+	}
+
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM printers").Scan(&count); err != nil {
+		t.Fatalf("count printers: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d printers stored, want none", count)
+	}
+}
+
+func TestRefreshPrintersReportsEachPrinter(t *testing.T) {
+	database := setupTestDB(t)
+	reachable := insertFakePrinter(t, database, startFakePrinter(t), "dock")
+
+	// Nothing listens on a port that was just released. Printer addresses
+	// are unique, so it gets another loopback address than the fake.
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	result, err := database.Exec(`INSERT INTO printers (name, ip_address, port, status, label_width_mm, label_height_mm)
+		VALUES ('unplugged', '127.0.0.2', ?, 'online', 50, 30)`, port)
+	if err != nil {
+		t.Fatalf("insert printer: %v", err)
+	}
+	unreachable, _ := result.LastInsertId()
+	router := newPrinterRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, "/api/printers/refresh", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: %d %s", w.Code, w.Body)
+	}
+	var results []PrinterRefreshResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("decode results: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want one per printer: %+v", len(results), results)
+	}
+
+	byID := make(map[int64]PrinterRefreshResult)
+	for _, r := range results {
+		byID[r.ID] = r
+	}
+	if r := byID[reachable]; r.Status != "online" || r.Message != "" {
+		t.Errorf("reachable printer refreshed as %+v, want online", r)
+	}
+	if r := byID[unreachable]; r.Status != "offline" || r.Message == "" {
+		t.Errorf("unreachable printer refreshed as %+v, want offline with a message", r)
+	}
+
+	var stored string
+	if err := database.QueryRow("SELECT status FROM printers WHERE id = ?", unreachable).Scan(&stored); err != nil {
+		t.Fatalf("read status: %v", err)
+	}
+	if stored != "offline" {
+		t.Errorf("unreachable printer stored as %q, want offline", stored)
+	}
+}
+
+func TestReprintRecentEndpoint(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	first := insertTestJob(t, database, printerID, "completed")
+	insertTestJob(t, database, printerID, "failed")
+	second := insertTestJob(t, database, printerID, "completed")
+
+	h := NewPrinterHandler(database, core.NewPrinterManager(database, &config.PrintersConfig{}, nil))
+	h.SetQueue(core.NewQueue(database, nil, nil, nil, nil))
+	router := newTestRouter()
+	RegisterPrinterRoutes(router.Group("/api"), h)
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/reprint-recent", printerID), map[string]any{"count": 5})
+	if w.Code != http.StatusOK {
+		t.Fatalf("reprint recent: %d %s", w.Code, w.Body)
+	}
+	var resp ReprintRecentResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Reprinted) != 2 || resp.Reprinted[0].OriginalJobID != first || resp.Reprinted[1].OriginalJobID != second {
+		t.Errorf("reprinted %+v, want jobs %d and %d in order", resp.Reprinted, first, second)
+	}
+
+	if w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/reprint-recent", printerID), map[string]any{"count": 0}); w.Code != http.StatusBadRequest {
+		t.Errorf("reprint zero jobs: %d %s, want 400", w.Code, w.Body)
+	}
+	if w := serveJSON(router, http.MethodPost, "/api/printers/9999/reprint-recent", map[string]any{"count": 1}); w.Code != http.StatusNotFound {
+		t.Errorf("reprint on an unknown printer: %d %s, want 404", w.Code, w.Body)
+	}
+}
+
+func TestCreatePrinterWithPublicAddress(t *testing.T) {
+	for _, allowPublic := range []bool{false, true} {
+		database := setupTestDB(t)
+		pm := core.NewPrinterManager(database, &config.PrintersConfig{AllowPublicIPs: allowPublic}, nil)
+		router := newTestRouter()
+		RegisterPrinterRoutes(router.Group("/api"), NewPrinterHandler(database, pm))
+
+		w := serveJSON(router, http.MethodPost, "/api/printers", map[string]any{
+			"name": "remote", "ip_address": "203.0.113.10", "label_width_mm": 50, "label_height_mm": 30,
+		})
+		want := http.StatusBadRequest
+		if allowPublic {
+			want = http.StatusCreated
+		}
+		if w.Code != want {
+			t.Errorf("create with a public address and allow_public_ips=%v: %d %s, want %d", allowPublic, w.Code, w.Body, want)
+		}
+
+		w = serveJSON(router, http.MethodPost, "/api/printers", map[string]any{
+			"name": "loopback", "ip_address": "127.0.0.1", "label_width_mm": 50, "label_height_mm": 30,
+		})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("create with a loopback address and allow_public_ips=%v: %d %s, want 400", allowPublic, w.Code, w.Body)
+		}
+	}
+}
+
+func TestDeletePrinterWithPendingJobs(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "decommissioned")
+	pending := insertTestJob(t, database, printerID, "pending")
+	processing := insertTestJob(t, database, printerID, "processing")
+	completed := insertTestJob(t, database, printerID, "completed")
+	router := newPrinterRouter(t, database)
+	path := fmt.Sprintf("/api/printers/%d", printerID)
+
+	w := serveJSON(router, http.MethodDelete, path, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("delete with pending jobs: %d %s, want 409", w.Code, w.Body)
+	}
+
+	w = serveJSON(router, http.MethodDelete, path+"?force=true", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("forced delete: %d %s, want 204", w.Code, w.Body)
+	}
+	for id, want := range map[int64]string{pending: "cancelled", processing: "failed", completed: "completed"} {
+		var status string
+		if err := database.QueryRow("SELECT status FROM print_jobs WHERE id = ?", id).Scan(&status); err != nil {
+			t.Fatalf("job %d: %v", id, err)
+		}
+		if status != want {
+			t.Errorf("job %d is %s after forced delete, want %s", id, status, want)
+		}
+	}
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM printers WHERE id = ?", printerID).Scan(&count); err != nil || count != 0 {
+		t.Errorf("printer still exists after forced delete (%d, %v)", count, err)
+	}
+}
+
+func TestCreatePrinterReadsLabelSizeFromPrinter(t *testing.T) {
+	database := setupTestDB(t)
+	h := NewPrinterHandler(database, startPrinterManager(t, database))
+	reported := map[string]*core.ConnectionTest{}
+	h.probe = func(ipAddress string, port int) (*core.ConnectionTest, error) {
+		if result, ok := reported[ipAddress]; ok {
+			return result, nil
+		}
+		return nil, core.ErrConnectionFailed
+	}
+	router := newTestRouter()
+	RegisterPrinterRoutes(router.Group("/api"), h)
+
+	width, height := 101.6, 50.8
+	reported["10.0.0.10"] = &core.ConnectionTest{LabelWidthMM: &width, LabelHeightMM: &height}
+	reported["10.0.0.11"] = &core.ConnectionTest{LabelHeightMM: &height}
+	reported["10.0.0.12"] = &core.ConnectionTest{}
+
+	tests := []struct {
+		name       string
+		body       map[string]any
+		wantCode   int
+		wantWidth  float64
+		wantHeight float64
+	}{
+		{"both read", map[string]any{"name": "a", "ip_address": "10.0.0.10"}, http.StatusCreated, 101.6, 50.8},
+		{"height read", map[string]any{"name": "b", "ip_address": "10.0.0.11", "label_width_mm": 60}, http.StatusCreated, 60, 50.8},
+		{"not reported", map[string]any{"name": "c", "ip_address": "10.0.0.12"}, http.StatusBadRequest, 0, 0},
+		{"unreachable", map[string]any{"name": "d", "ip_address": "10.0.0.13"}, http.StatusBadRequest, 0, 0},
+		{"given", map[string]any{"name": "e", "ip_address": "10.0.0.13", "label_width_mm": 50, "label_height_mm": 30}, http.StatusCreated, 50, 30},
+	}
+	for _, tt := range tests {
+		w := serveJSON(router, http.MethodPost, "/api/printers", tt.body)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: create printer: %d %s, want %d", tt.name, w.Code, w.Body, tt.wantCode)
+			continue
+		}
+		if w.Code != http.StatusCreated {
+			continue
+		}
+		var printer PrinterResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &printer); err != nil {
+			t.Fatalf("decode printer: %v", err)
+		}
+		if printer.LabelWidthMM != tt.wantWidth || printer.LabelHeightMM != tt.wantHeight {
+			t.Errorf("%s: label size is %v x %v, want %v x %v", tt.name, printer.LabelWidthMM, printer.LabelHeightMM, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func TestCreateFilePrinter(t *testing.T) {
+	database := setupTestDB(t)
+	root := t.TempDir()
+	pm := core.NewPrinterManager(database, &config.PrintersConfig{HealthCheckInterval: time.Hour, SpoolRoot: root}, nil)
+	pm.Start()
+	t.Cleanup(pm.Stop)
+	h := NewPrinterHandler(database, pm)
+	router := newTestRouter()
+	router.POST("/api/printers", h.CreatePrinter)
+	router.PUT("/api/printers/:id", h.UpdatePrinter)
+	spoolDir := filepath.Join(root, "labels")
+
+	for _, body := range []map[string]any{
+		{"name": "no-dir", "type": "file", "label_width_mm": 50, "label_height_mm": 30},
+		{"name": "relative", "type": "file", "spool_dir": "labels", "label_width_mm": 50, "label_height_mm": 30},
+		{"name": "outside-root", "type": "file", "spool_dir": t.TempDir(), "label_width_mm": 50, "label_height_mm": 30},
+		{"name": "climbs-out", "type": "file", "spool_dir": root + "/../etc", "label_width_mm": 50, "label_height_mm": 30},
+		{"name": "with-ip", "type": "file", "spool_dir": spoolDir, "ip_address": "192.168.1.50", "label_width_mm": 50, "label_height_mm": 30},
+		{"name": "network-dir", "spool_dir": spoolDir, "ip_address": "192.168.1.50", "label_width_mm": 50, "label_height_mm": 30},
+	} {
+		if w := serveJSON(router, http.MethodPost, "/api/printers", body); w.Code != http.StatusBadRequest {
+			t.Errorf("create %s: %d %s, want 400", body["name"], w.Code, w.Body)
+		}
+	}
+
+	w := serveJSON(router, http.MethodPost, "/api/printers", map[string]any{
+		"name": "virtual", "type": "file", "spool_dir": spoolDir, "label_width_mm": 50, "label_height_mm": 30,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create file printer: %d %s", w.Code, w.Body)
+	}
+	var printer PrinterResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &printer); err != nil {
+		t.Fatalf("decode printer: %v", err)
+	}
+	if printer.Type != core.PrinterTypeFile || printer.SpoolDir != spoolDir || printer.IPAddress != core.FilePrinterAddress(spoolDir) {
+		t.Errorf("created %s printer at %s writing to %q, want a file printer writing to %s", printer.Type, printer.IPAddress, printer.SpoolDir, spoolDir)
+	}
+
+	w = serveJSON(router, http.MethodPut, fmt.Sprintf("/api/printers/%d", printer.ID), map[string]any{"spool_dir": "/etc"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("move spool_dir out of the spool root: %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestGetPrinterConfig(t *testing.T) {
+	database := setupTestDB(t)
+	fake := startReplyingFakePrinter(t, map[string]string{
+		`OUT "",GETSETTING$("CONFIG","TSPL","PAPER WIDTH")` + "\r\n": "4.00\r\n",
+		`OUT "",GETSETTING$("CONFIG","TSPL","PAPER SIZE")` + "\r\n":  "2.00\r\n",
+		`OUT "",GETSETTING$("CONFIG","TSPL","GAP SIZE")` + "\r\n":    "0\r\n",
+		`OUT "",GETSETTING$("CONFIG","TSPL","SPEED")` + "\r\n":       "4.0\r\n",
+		`OUT "",GETSETTING$("CONFIG","TSPL","DENSITY")` + "\r\n":     "8\r\n",
+	})
+	id := insertFakePrinter(t, database, fake, "printer")
+	router := newTestRouter()
+	RegisterPrinterRoutes(router.Group("/api"), NewPrinterHandler(database, startPrinterManager(t, database)))
+
+	w := serveJSON(router, http.MethodGet, fmt.Sprintf("/api/printers/%d/config", id), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get config: %d %s", w.Code, w.Body)
+	}
+	var cfg PrinterConfigResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &cfg); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if cfg.LabelWidthMM == nil || *cfg.LabelWidthMM != 101.6 || cfg.LabelHeightMM == nil || *cfg.LabelHeightMM != 50.8 {
+		t.Errorf("label size is %v x %v, want 101.6 x 50.8", cfg.LabelWidthMM, cfg.LabelHeightMM)
+	}
+	if cfg.GapMM == nil || *cfg.GapMM != 0 || cfg.Speed == nil || *cfg.Speed != 4 || cfg.Density == nil || *cfg.Density != 8 {
+		t.Errorf("config is gap %v, speed %v, density %v, want 0, 4 and 8", cfg.GapMM, cfg.Speed, cfg.Density)
+	}
+
+	w = serveJSON(router, http.MethodGet, "/api/printers/999/config", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("config of an unknown printer: %d %s, want 404", w.Code, w.Body)
+	}
+}
+
+func TestGetPrinterRawStatus(t *testing.T) {
+	database := setupTestDB(t)
+	// A state byte the firmware tables do not know, with low paper.
+	fake := startReplyingFakePrinter(t, map[string]string{"\x1b!?": "\x7fA@@"})
+	id := insertFakePrinter(t, database, fake, "printer")
+	router := newTestRouter()
+	RegisterPrinterRoutes(router.Group("/api"), NewPrinterHandler(database, startPrinterManager(t, database)))
+
+	w := serveJSON(router, http.MethodGet, fmt.Sprintf("/api/printers/%d/status/raw", id), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get raw status: %d %s", w.Code, w.Body)
+	}
+	var status PrinterRawStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode raw status: %v", err)
+	}
+	if status.Raw != "7f414040" {
+		t.Errorf("raw = %q, want 7f414040", status.Raw)
+	}
+	if status.PrinterState != "unknown" || status.Warning != "paper_low" || status.Error != "none" || status.MediaError != "none" {
+		t.Errorf("decoded as %+v, want unknown state with paper_low", status)
+	}
+
+	w = serveJSON(router, http.MethodGet, "/api/printers/999/status/raw", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("raw status of an unknown printer: %d %s, want 404", w.Code, w.Body)
+	}
+}
+
+func TestCalibratePrinter(t *testing.T) {
+	for mediaType, command := range map[string]string{
+		"gap":        "GAPDETECT",
+		"black_mark": "BLINEDETECT",
+		"auto":       "AUTODETECT",
+	} {
+		t.Run(mediaType, func(t *testing.T) {
+			database := setupTestDB(t)
+			fake := startFakePrinter(t)
+			id := insertFakePrinter(t, database, fake, "printer")
+			router := newTestRouter()
+			RegisterPrinterRoutes(router.Group("/api"), NewPrinterHandler(database, startPrinterManager(t, database)))
+
+			w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/calibrate", id), map[string]any{"media_type": mediaType})
+			if w.Code != http.StatusOK {
+				t.Fatalf("calibrate: %d %s", w.Code, w.Body)
+			}
+			if got := fake.waitFor(t, command); got != command+"\n" {
+				t.Errorf("printer received %q, want %q", got, command+"\n")
+			}
+		})
+	}
+}
+
+func TestCalibratePrinterRejectsBadRequests(t *testing.T) {
+	database := setupTestDB(t)
+	router := newTestRouter()
+	RegisterPrinterRoutes(router.Group("/api"), NewPrinterHandler(database, startPrinterManager(t, database)))
+
+	w := serveJSON(router, http.MethodPost, "/api/printers/999/calibrate", map[string]any{"media_type": "gap"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("calibrate an unknown printer: %d %s, want 404", w.Code, w.Body)
+	}
+	w = serveJSON(router, http.MethodPost, "/api/printers/999/calibrate", map[string]any{"media_type": "continuous"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("calibrate for an unknown media type: %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestGetPrinterCountersHourly(t *testing.T) {
+	database := setupTestDB(t)
+	id := insertTestPrinter(t, database, "printer")
+	queue := core.NewQueue(database, acceptingPrinterManager{}, nil, nil, nil)
+	for _, copies := range []int{2, 3} {
+		if _, err := queue.PrintNow(context.Background(), &core.Job{PrinterID: id, TSPLContent: "PRINT 1", Copies: copies}); err != nil {
+			t.Fatalf("print: %v", err)
+		}
+	}
+
+	now := time.Now()
+	for _, row := range []struct {
+		hour  time.Time
+		count int
+	}{
+		{now.Add(-3 * time.Hour), 7},
+		{now.Add(-48 * time.Hour), 11},
+	} {
+		if _, err := database.Exec("INSERT INTO print_counters_hourly (printer_id, hour, count) VALUES (?, ?, ?)",
+			id, row.hour.Format(db.HourLayout), row.count); err != nil {
+			t.Fatalf("insert hourly counter: %v", err)
+		}
+	}
+
+	router := newTestRouter()
+	RegisterPrinterRoutes(router.Group("/api"), NewPrinterHandler(database, nil))
+	path := fmt.Sprintf("/api/printers/%d/counters", id)
+
+	w := serveJSON(router, http.MethodGet, path+"?granularity=hour", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("hourly counters: %d %s", w.Code, w.Body)
+	}
+	var hourly PrinterHourlyCountersResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &hourly); err != nil {
+		t.Fatalf("decode hourly counters: %v", err)
+	}
+	if hourly.Total != 12 || len(hourly.ByHour) != 2 {
+		t.Fatalf("last 24 hours = %+v, want 12 prints in 2 hours", hourly)
+	}
+	if got := hourly.ByHour[1]; got.Count != 5 || got.Hour.Format(db.HourLayout) != now.Format(db.HourLayout) {
+		t.Errorf("current hour = %+v, want the two jobs' 5 copies", got)
+	}
+
+	from := now.Add(-72 * time.Hour).Format(time.RFC3339)
+	to := now.Add(-2 * time.Hour).Format(time.RFC3339)
+	w = serveJSON(router, http.MethodGet, path+"?granularity=hour&from="+from+"&to="+to, nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &hourly); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("hourly counters in range: %d %s", w.Code, w.Body)
+	}
+	if hourly.Total != 18 || len(hourly.ByHour) != 2 || hourly.ByHour[0].Count != 11 {
+		t.Errorf("range %s to %s = %+v, want 11 then 7", from, to, hourly)
+	}
+
+	w = serveJSON(router, http.MethodGet, path, nil)
+	var daily PrinterCountersResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &daily); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("daily counters: %d %s", w.Code, w.Body)
+	}
+	if daily.Today != 5 {
+		t.Errorf("daily counters today = %d, want 5", daily.Today)
+	}
+
+	for _, query := range []string{"?granularity=minute", "?granularity=hour&from=yesterday", "?granularity=hour&from=" + to + "&to=" + from} {
+		if w := serveJSON(router, http.MethodGet, path+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: %d %s, want 400", query, w.Code, w.Body)
+		}
+	}
+}
+
+func TestRecountPrinterPrints(t *testing.T) {
+	database := setupTestDB(t)
+	id := insertTestPrinter(t, database, "printer")
+	if _, err := database.Exec("INSERT INTO print_counters (printer_id, date, count) VALUES (?, '2026-10-16', 9)", id); err != nil {
+		t.Fatalf("insert counter: %v", err)
+	}
+	database.Exec("UPDATE printers SET total_prints = 6 WHERE id = ?", id)
+
+	router := newTestRouter()
+	pm := core.NewPrinterManager(database, &config.PrintersConfig{}, nil)
+	RegisterPrinterRoutes(router.Group("/api"), NewPrinterHandler(database, pm))
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/recount", id), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("recount: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Previous int64 `json:"previous_total_prints"`
+		Total    int64 `json:"total_prints"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode recount: %v", err)
+	}
+	if resp.Previous != 6 || resp.Total != 9 {
+		t.Errorf("recount went from %d to %d, want 6 to 9", resp.Previous, resp.Total)
+	}
+	var stored int64
+	database.QueryRow("SELECT total_prints FROM printers WHERE id = ?", id).Scan(&stored)
+	if stored != 9 {
+		t.Errorf("stored total_prints is %d, want 9", stored)
+	}
+
+	w = serveJSON(router, http.MethodPost, "/api/printers/999/recount", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("recount of an unknown printer: %d %s, want 404", w.Code, w.Body)
+	}
+}
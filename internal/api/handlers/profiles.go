@@ -0,0 +1,569 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+)
+
+type CreateProfileRequest struct {
+	Name              string  `json:"name" binding:"required"`
+	Description       string  `json:"description"`
+	FromPrinterID     int64   `json:"from_printer_id"`
+	DPI               int     `json:"dpi"`
+	LabelWidthMM      float64 `json:"label_width_mm" binding:"omitempty,gt=0"`
+	LabelHeightMM     float64 `json:"label_height_mm" binding:"omitempty,gt=0"`
+	GapMM             float64 `json:"gap_mm"`
+	DefaultTemplateID *int64  `json:"default_template_id"`
+	LineEnding        string  `json:"line_ending" binding:"omitempty,oneof=lf crlf"`
+	Encoding          string  `json:"encoding"`
+	FallbackPrinterID *int64  `json:"fallback_printer_id"`
+	Group             string  `json:"group" binding:"max=64"`
+	// The settings below, like those above, replace the ones captured
+	// from from_printer_id when given.
+	FeedOnError  string   `json:"feed_on_error" binding:"omitempty,oneof=none formfeed gap black_mark auto"`
+	InitCommands []string `json:"init_commands"`
+	StatusLength int      `json:"status_length" binding:"omitempty,oneof=1 4 8"`
+}
+
+type UpdateProfileRequest struct {
+	Name              string  `json:"name"`
+	Description       string  `json:"description"`
+	DPI               int     `json:"dpi"`
+	LabelWidthMM      float64 `json:"label_width_mm" binding:"omitempty,gt=0"`
+	LabelHeightMM     float64 `json:"label_height_mm" binding:"omitempty,gt=0"`
+	GapMM             float64 `json:"gap_mm"`
+	DefaultTemplateID *int64  `json:"default_template_id"`
+	LineEnding        string  `json:"line_ending" binding:"omitempty,oneof=lf crlf"`
+	Encoding          string  `json:"encoding"`
+	FallbackPrinterID *int64  `json:"fallback_printer_id"`
+	Group             *string `json:"group" binding:"omitempty,max=64"`
+	FeedOnError       string  `json:"feed_on_error" binding:"omitempty,oneof=none formfeed gap black_mark auto"`
+	// InitCommands replaces the profile's init commands; an empty list
+	// clears them.
+	InitCommands *[]string `json:"init_commands"`
+	StatusLength int       `json:"status_length" binding:"omitempty,oneof=1 4 8"`
+}
+
+type ApplyProfileRequest struct {
+	ProfileID int64 `json:"profile_id" binding:"required"`
+}
+
+type ProfileResponse struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	DPI               int       `json:"dpi"`
+	LabelWidthMM      float64   `json:"label_width_mm"`
+	LabelHeightMM     float64   `json:"label_height_mm"`
+	GapMM             float64   `json:"gap_mm"`
+	DefaultTemplateID *int64    `json:"default_template_id,omitempty"`
+	LineEnding        string    `json:"line_ending"`
+	Encoding          string    `json:"encoding"`
+	FallbackPrinterID *int64    `json:"fallback_printer_id,omitempty"`
+	Group             string    `json:"group,omitempty"`
+	FeedOnError       string    `json:"feed_on_error"`
+	InitCommands      []string  `json:"init_commands,omitempty"`
+	StatusLength      int       `json:"status_length"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// ProfileHandler manages reusable printer profiles. A profile captures a
+// printer's configuration, apart from how to reach it, so it can be applied
+// to other printers.
+type ProfileHandler struct {
+	db             *sql.DB
+	printerManager *core.PrinterManager
+	printers       *PrinterHandler
+}
+
+func NewProfileHandler(database *sql.DB, printerManager *core.PrinterManager) *ProfileHandler {
+	return &ProfileHandler{
+		db:             database,
+		printerManager: printerManager,
+		printers:       NewPrinterHandler(database, printerManager),
+	}
+}
+
+func (h *ProfileHandler) ListProfiles(c *gin.Context) {
+	profiles, err := db.PrinterProfiles.ListProfiles(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve printer profiles",
+		})
+		return
+	}
+
+	responses := make([]ProfileResponse, 0, len(profiles))
+	for _, p := range profiles {
+		responses = append(responses, profileToResponse(p))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+func (h *ProfileHandler) CreateProfile(c *gin.Context) {
+	var req CreateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	_, err := db.PrinterProfiles.GetProfileByName(c.Request.Context(), req.Name)
+	if err == nil {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "duplicate_name",
+			Message: "Printer profile with this name already exists",
+		})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to check profile name",
+		})
+		return
+	}
+
+	profile := &db.PrinterProfile{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	if req.FromPrinterID != 0 {
+		printer, err := db.Printers.GetPrinterByID(c.Request.Context(), req.FromPrinterID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, ErrorResponse{
+					Error:   "not_found",
+					Message: "Source printer not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to retrieve printer",
+			})
+			return
+		}
+		profile.DPI = printer.DPI
+		profile.LabelWidthMM = printer.LabelWidthMM
+		profile.LabelHeightMM = printer.LabelHeightMM
+		profile.GapMM = printer.GapMM
+		profile.DefaultTemplateID = printer.DefaultTemplateID
+		profile.LineEnding = printer.LineEnding
+		profile.Encoding = printer.Encoding
+		profile.FallbackPrinterID = printer.FallbackPrinterID
+		profile.Group = printer.Group
+		profile.FeedOnError = printer.FeedOnError
+		profile.InitCommands = printer.InitCommands
+		profile.StatusLength = printer.StatusLength
+	}
+
+	if req.DPI != 0 {
+		profile.DPI = req.DPI
+	}
+	if req.LabelWidthMM != 0 {
+		profile.LabelWidthMM = req.LabelWidthMM
+	}
+	if req.LabelHeightMM != 0 {
+		profile.LabelHeightMM = req.LabelHeightMM
+	}
+	if req.GapMM != 0 {
+		profile.GapMM = req.GapMM
+	}
+	if req.DefaultTemplateID != nil {
+		defaultTemplateID, ok := h.printers.resolveDefaultTemplate(c, req.DefaultTemplateID)
+		if !ok {
+			return
+		}
+		profile.DefaultTemplateID = defaultTemplateID
+	}
+	if req.LineEnding != "" {
+		profile.LineEnding = req.LineEnding
+	}
+	if req.Encoding != "" {
+		profile.Encoding = req.Encoding
+	}
+	if req.FallbackPrinterID != nil {
+		fallbackPrinterID, ok := h.printers.resolveFallbackPrinter(c, 0, req.FallbackPrinterID)
+		if !ok {
+			return
+		}
+		profile.FallbackPrinterID = fallbackPrinterID
+	}
+	if req.Group != "" {
+		profile.Group = strings.TrimSpace(req.Group)
+	}
+	if req.FeedOnError != "" {
+		profile.FeedOnError = req.FeedOnError
+	}
+	if req.InitCommands != nil {
+		profile.InitCommands = req.InitCommands
+	}
+	if req.StatusLength != 0 {
+		profile.StatusLength = req.StatusLength
+	}
+
+	if profile.DPI == 0 {
+		profile.DPI = 203
+	}
+	if profile.LineEnding == "" {
+		profile.LineEnding = core.LineEndingLF
+	}
+	if profile.Encoding == "" {
+		profile.Encoding = "UTF-8"
+	}
+	if profile.FeedOnError == "" {
+		profile.FeedOnError = core.FeedOnErrorNone
+	}
+	if profile.StatusLength == 0 {
+		profile.StatusLength = core.StatusLengthStandard
+	}
+	if err := validateProfileSettings(profile); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if profile.LabelWidthMM <= 0 || profile.LabelHeightMM <= 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: "label_width_mm and label_height_mm are required when no source printer is given",
+		})
+		return
+	}
+
+	if err := db.PrinterProfiles.CreateProfile(c.Request.Context(), profile); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to create printer profile",
+		})
+		return
+	}
+
+	created, err := db.PrinterProfiles.GetProfileByID(c.Request.Context(), profile.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve created profile",
+		})
+		return
+	}
+
+	recordAudit(c, "create", "printer_profile", created.ID, req)
+
+	c.JSON(http.StatusCreated, profileToResponse(created))
+}
+
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	profile, ok := h.loadProfile(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, profileToResponse(profile))
+}
+
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	profile, ok := h.loadProfile(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Name != "" && req.Name != profile.Name {
+		existing, err := db.PrinterProfiles.GetProfileByName(c.Request.Context(), req.Name)
+		if err == nil && existing.ID != profile.ID {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "duplicate_name",
+				Message: "Printer profile with this name already exists",
+			})
+			return
+		}
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to check profile name",
+			})
+			return
+		}
+		profile.Name = req.Name
+	}
+	if req.Description != "" {
+		profile.Description = req.Description
+	}
+	if req.DPI != 0 {
+		profile.DPI = req.DPI
+	}
+	if req.LabelWidthMM != 0 {
+		profile.LabelWidthMM = req.LabelWidthMM
+	}
+	if req.LabelHeightMM != 0 {
+		profile.LabelHeightMM = req.LabelHeightMM
+	}
+	if req.GapMM != 0 {
+		profile.GapMM = req.GapMM
+	}
+	if req.DefaultTemplateID != nil {
+		defaultTemplateID, ok := h.printers.resolveDefaultTemplate(c, req.DefaultTemplateID)
+		if !ok {
+			return
+		}
+		profile.DefaultTemplateID = defaultTemplateID
+	}
+	if req.LineEnding != "" {
+		profile.LineEnding = req.LineEnding
+	}
+	if req.Encoding != "" {
+		profile.Encoding = req.Encoding
+	}
+	if req.FallbackPrinterID != nil {
+		fallbackPrinterID, ok := h.printers.resolveFallbackPrinter(c, 0, req.FallbackPrinterID)
+		if !ok {
+			return
+		}
+		profile.FallbackPrinterID = fallbackPrinterID
+	}
+	if req.Group != nil {
+		profile.Group = strings.TrimSpace(*req.Group)
+	}
+	if req.FeedOnError != "" {
+		profile.FeedOnError = req.FeedOnError
+	}
+	if req.InitCommands != nil {
+		profile.InitCommands = *req.InitCommands
+	}
+	if req.StatusLength != 0 {
+		profile.StatusLength = req.StatusLength
+	}
+	if err := validateProfileSettings(profile); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := db.PrinterProfiles.UpdateProfile(c.Request.Context(), profile); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to update printer profile",
+		})
+		return
+	}
+
+	recordAudit(c, "update", "printer_profile", profile.ID, req)
+
+	c.JSON(http.StatusOK, profileToResponse(profile))
+}
+
+func (h *ProfileHandler) DeleteProfile(c *gin.Context) {
+	profile, ok := h.loadProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := db.PrinterProfiles.DeleteProfile(c.Request.Context(), profile.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to delete printer profile",
+		})
+		return
+	}
+
+	recordAudit(c, "delete", "printer_profile", profile.ID, gin.H{"name": profile.Name})
+
+	c.Status(http.StatusNoContent)
+}
+
+// ApplyProfile copies a profile's configuration onto a printer. Connection
+// settings (name, type, address, port, spool directory) are left untouched.
+// A profile whose fallback is the printer itself cannot be applied to it.
+func (h *ProfileHandler) ApplyProfile(c *gin.Context) {
+	id, err := h.printers.parsePrinterID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid printer ID",
+		})
+		return
+	}
+
+	var req ApplyProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	profile, err := db.PrinterProfiles.GetProfileByID(c.Request.Context(), req.ProfileID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer profile not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve printer profile",
+		})
+		return
+	}
+
+	printer, err := db.Printers.GetPrinterByID(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve printer",
+		})
+		return
+	}
+
+	fallbackPrinterID, ok := h.printers.resolveFallbackPrinter(c, id, profile.FallbackPrinterID)
+	if !ok {
+		return
+	}
+
+	printer.DPI = profile.DPI
+	printer.LabelWidthMM = profile.LabelWidthMM
+	printer.LabelHeightMM = profile.LabelHeightMM
+	printer.GapMM = profile.GapMM
+	printer.DefaultTemplateID = profile.DefaultTemplateID
+	printer.LineEnding = profile.LineEnding
+	printer.Encoding = profile.Encoding
+	printer.FallbackPrinterID = fallbackPrinterID
+	printer.Group = profile.Group
+	printer.FeedOnError = profile.FeedOnError
+	printer.InitCommands = profile.InitCommands
+	printer.StatusLength = profile.StatusLength
+
+	if err := db.Printers.UpdatePrinter(c.Request.Context(), printer); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to update printer",
+		})
+		return
+	}
+
+	if err := h.printerManager.UpdatePrinter(toCorePrinter(printer)); err != nil {
+		if err == core.ErrPrinterNotFound {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer not found in manager",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "manager_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	recordAudit(c, "apply_profile", "printer", printer.ID, gin.H{"profile_id": profile.ID, "profile_name": profile.Name})
+
+	c.JSON(http.StatusOK, h.printers.printerToResponse(printer))
+}
+
+func (h *ProfileHandler) loadProfile(c *gin.Context) (*db.PrinterProfile, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid profile ID",
+		})
+		return nil, false
+	}
+
+	profile, err := db.PrinterProfiles.GetProfileByID(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Printer profile not found",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve printer profile",
+		})
+		return nil, false
+	}
+
+	return profile, true
+}
+
+// validateProfileSettings checks the output and init command settings a
+// profile would give a printer.
+func validateProfileSettings(p *db.PrinterProfile) error {
+	if err := core.ValidatePrinterOutput(p.LineEnding, p.Encoding); err != nil {
+		return err
+	}
+	return core.ValidateInitCommands(p.InitCommands)
+}
+
+func profileToResponse(p *db.PrinterProfile) ProfileResponse {
+	return ProfileResponse{
+		ID:                p.ID,
+		Name:              p.Name,
+		Description:       p.Description,
+		DPI:               p.DPI,
+		LabelWidthMM:      p.LabelWidthMM,
+		LabelHeightMM:     p.LabelHeightMM,
+		GapMM:             p.GapMM,
+		DefaultTemplateID: p.DefaultTemplateID,
+		LineEnding:        p.LineEnding,
+		Encoding:          p.Encoding,
+		FallbackPrinterID: p.FallbackPrinterID,
+		Group:             p.Group,
+		FeedOnError:       p.FeedOnError,
+		InitCommands:      p.InitCommands,
+		StatusLength:      p.StatusLength,
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+	}
+}
+
+func RegisterProfileRoutes(r *gin.RouterGroup, h *ProfileHandler) {
+	operator := middleware.RequireRole(middleware.RoleOperator)
+	r.GET("/printer-profiles", h.ListProfiles)
+	r.POST("/printer-profiles", operator, h.CreateProfile)
+	r.GET("/printer-profiles/:id", h.GetProfile)
+	r.PUT("/printer-profiles/:id", operator, h.UpdateProfile)
+	r.DELETE("/printer-profiles/:id", operator, h.DeleteProfile)
+	r.POST("/printers/:id/apply-profile", operator, h.ApplyProfile)
+}
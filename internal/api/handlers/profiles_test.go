@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+)
+
+// newProfileRouter serves the profile routes with a printer manager that
+// holds the printers already in database.
+func newProfileRouter(t *testing.T, database *sql.DB) *gin.Engine {
+	t.Helper()
+
+	pm := core.NewPrinterManager(database, &config.PrintersConfig{HealthCheckInterval: time.Hour}, nil)
+	pm.Start()
+	t.Cleanup(pm.Stop)
+
+	router := newTestRouter()
+	RegisterProfileRoutes(router.Group("/api"), NewProfileHandler(database, pm))
+	return router
+}
+
+func TestApplyProfileToSeveralPrinters(t *testing.T) {
+	database := setupTestDB(t)
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	golden := insertTestPrinter(t, database, "golden")
+	if _, err := database.Exec(`UPDATE printers SET dpi = 300, label_width_mm = 100, label_height_mm = 150, gap_mm = 3,
+		default_template_id = ? WHERE id = ?`, templateID, golden); err != nil {
+		t.Fatalf("configure golden printer: %v", err)
+	}
+	targets := []int64{
+		insertTestPrinter(t, database, "dock-1"),
+		insertTestPrinter(t, database, "dock-2"),
+	}
+	router := newProfileRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, "/api/printer-profiles", map[string]any{
+		"name": "shipping", "from_printer_id": golden,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create profile: %d %s", w.Code, w.Body)
+	}
+	var profile ProfileResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+		t.Fatalf("decode profile: %v", err)
+	}
+
+	for _, id := range targets {
+		w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/apply-profile", id), map[string]any{
+			"profile_id": profile.ID,
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("apply profile to printer %d: %d %s", id, w.Code, w.Body)
+		}
+	}
+
+	for i, id := range targets {
+		var (
+			name, ip           string
+			dpi                int
+			width, height, gap float64
+			defaultTemplateID  sql.NullInt64
+		)
+		if err := database.QueryRow(`SELECT name, ip_address, dpi, label_width_mm, label_height_mm, gap_mm, default_template_id
+			FROM printers WHERE id = ?`, id).Scan(&name, &ip, &dpi, &width, &height, &gap, &defaultTemplateID); err != nil {
+			t.Fatalf("read printer %d: %v", id, err)
+		}
+		if dpi != 300 || width != 100 || height != 150 || gap != 3 {
+			t.Errorf("printer %d has %d dpi, %gx%g mm, gap %g, want the profile's 300 dpi, 100x150 mm, gap 3", id, dpi, width, height, gap)
+		}
+		if defaultTemplateID.Int64 != templateID {
+			t.Errorf("printer %d default template is %d, want %d", id, defaultTemplateID.Int64, templateID)
+		}
+		// Connection settings stay with the printer.
+		if want := fmt.Sprintf("dock-%d", i+1); name != want || ip != want+".test" {
+			t.Errorf("printer %d is %s at %s, want %s at %s.test", id, name, ip, want, want)
+		}
+	}
+}
+
+func TestApplyUnknownProfile(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	router := newProfileRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/apply-profile", printerID), map[string]any{
+		"profile_id": 9999,
+	})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("apply unknown profile: %d %s, want 404", w.Code, w.Body)
+	}
+}
+
+func TestProfileRoundTripsPrinterSettings(t *testing.T) {
+	database := setupTestDB(t)
+	backup := insertTestPrinter(t, database, "backup")
+	golden := insertTestPrinter(t, database, "golden")
+	if _, err := database.Exec(`UPDATE printers SET line_ending = 'crlf', encoding = '1252', fallback_printer_id = ?,
+		printer_group = 'dock', feed_on_error = 'gap', init_commands = ?, status_length = 1 WHERE id = ?`,
+		backup, "GAPDETECT\nSET TEAR ON", golden); err != nil {
+		t.Fatalf("configure golden printer: %v", err)
+	}
+	target := insertTestPrinter(t, database, "dock-1")
+	router := newProfileRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, "/api/printer-profiles", map[string]any{
+		"name": "dock", "from_printer_id": golden,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create profile: %d %s", w.Code, w.Body)
+	}
+	var created ProfileResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode profile: %v", err)
+	}
+
+	w = serveJSON(router, http.MethodGet, fmt.Sprintf("/api/printer-profiles/%d", created.ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get profile: %d %s", w.Code, w.Body)
+	}
+	var profile ProfileResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+		t.Fatalf("decode profile: %v", err)
+	}
+	if profile.LineEnding != "crlf" || profile.Encoding != "1252" || profile.Group != "dock" ||
+		profile.FeedOnError != "gap" || profile.StatusLength != 1 ||
+		profile.FallbackPrinterID == nil || *profile.FallbackPrinterID != backup ||
+		strings.Join(profile.InitCommands, "|") != "GAPDETECT|SET TEAR ON" {
+		t.Errorf("profile captured %+v, want the golden printer's settings", profile)
+	}
+
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/apply-profile", target), map[string]any{
+		"profile_id": profile.ID,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("apply profile: %d %s", w.Code, w.Body)
+	}
+
+	var (
+		lineEnding, encoding, group, feedOnError, initCommands string
+		fallback                                               sql.NullInt64
+		statusLength                                           int
+	)
+	if err := database.QueryRow(`SELECT line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length
+		FROM printers WHERE id = ?`, target).Scan(&lineEnding, &encoding, &fallback, &group, &feedOnError, &initCommands, &statusLength); err != nil {
+		t.Fatalf("read printer: %v", err)
+	}
+	if lineEnding != "crlf" || encoding != "1252" || fallback.Int64 != backup || group != "dock" ||
+		feedOnError != "gap" || initCommands != "GAPDETECT\nSET TEAR ON" || statusLength != 1 {
+		t.Errorf("printer has line ending %s, encoding %s, fallback %d, group %s, feed on error %s, init commands %q, status length %d; want the golden printer's",
+			lineEnding, encoding, fallback.Int64, group, feedOnError, initCommands, statusLength)
+	}
+
+	// The fallback printer cannot be given itself as a fallback.
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/apply-profile", backup), map[string]any{
+		"profile_id": profile.ID,
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("apply profile to its own fallback: %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestApplyProfileReportsManagerFailure(t *testing.T) {
+	database := setupTestDB(t)
+	golden := insertTestPrinter(t, database, "golden")
+	if _, err := database.Exec("UPDATE printers SET dpi = 300 WHERE id = ?", golden); err != nil {
+		t.Fatalf("configure golden printer: %v", err)
+	}
+	target := insertTestPrinter(t, database, "dock-1")
+	router := newProfileRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, "/api/printer-profiles", map[string]any{
+		"name": "shipping", "from_printer_id": golden,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create profile: %d %s", w.Code, w.Body)
+	}
+	var profile ProfileResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+		t.Fatalf("decode profile: %v", err)
+	}
+
+	// The handler saves the printer before the manager does, so refusing
+	// updates of a printer that already has the profile's DPI fails only
+	// the manager's write.
+	if _, err := database.Exec(`CREATE TRIGGER fail_manager_update BEFORE UPDATE OF dpi ON printers
+		WHEN OLD.dpi = 300 AND NEW.dpi = 300
+		BEGIN SELECT RAISE(ABORT, 'printer is read-only'); END`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	t.Cleanup(func() { database.Exec("DROP TRIGGER fail_manager_update") })
+
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/printers/%d/apply-profile", target), map[string]any{
+		"profile_id": profile.ID,
+	})
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "read-only") {
+		t.Errorf("apply profile with a failing manager: %d %s, want 500 with the manager's error", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+)
+
+type QueueSettingsResponse struct {
+	WorkerCount     int    `json:"worker_count"`
+	MaxRetries      int    `json:"max_retries"`
+	RetryDelay      string `json:"retry_delay"`
+	MaxRetryBackoff string `json:"max_retry_backoff"`
+}
+
+// UpdateQueueSettingsRequest changes only the fields that are present.
+// Durations use Go syntax, such as "10s" or "5m".
+type UpdateQueueSettingsRequest struct {
+	WorkerCount     *int    `json:"worker_count" binding:"omitempty,min=1,max=64"`
+	MaxRetries      *int    `json:"max_retries" binding:"omitempty,min=0,max=100"`
+	RetryDelay      *string `json:"retry_delay"`
+	MaxRetryBackoff *string `json:"max_retry_backoff"`
+}
+
+func queueSettingsResponse(q *core.Queue) QueueSettingsResponse {
+	policy := q.RetryPolicy()
+	return QueueSettingsResponse{
+		WorkerCount:     q.WorkerCount(),
+		MaxRetries:      policy.MaxRetries,
+		RetryDelay:      policy.RetryDelay.String(),
+		MaxRetryBackoff: policy.MaxRetryBackoff.String(),
+	}
+}
+
+func (h *SettingsHandler) requireQueue(c *gin.Context) bool {
+	if h.queue == nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "queue_unavailable",
+			Message: "Job queue is not configured",
+		})
+		return false
+	}
+	return true
+}
+
+func (h *SettingsHandler) GetQueueSettings(c *gin.Context) {
+	if !h.requireQueue(c) {
+		return
+	}
+	c.JSON(http.StatusOK, queueSettingsResponse(h.queue))
+}
+
+// UpdateQueueSettings resizes the worker pool and changes the retry policy
+// without a restart. The values are stored in settings and reapplied when
+// the queue next starts.
+func (h *SettingsHandler) UpdateQueueSettings(c *gin.Context) {
+	if !h.requireQueue(c) {
+		return
+	}
+
+	var req UpdateQueueSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	workers := h.queue.WorkerCount()
+	if req.WorkerCount != nil {
+		workers = *req.WorkerCount
+	}
+	policy := h.queue.RetryPolicy()
+	if req.MaxRetries != nil {
+		policy.MaxRetries = *req.MaxRetries
+	}
+	for _, f := range []struct {
+		name  string
+		value *string
+		dest  *time.Duration
+	}{
+		{"retry_delay", req.RetryDelay, &policy.RetryDelay},
+		{"max_retry_backoff", req.MaxRetryBackoff, &policy.MaxRetryBackoff},
+	} {
+		if f.value == nil {
+			continue
+		}
+		d, err := time.ParseDuration(*f.value)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "validation_error",
+				Message: f.name + " must be a positive duration such as \"10s\"",
+			})
+			return
+		}
+		*f.dest = d
+	}
+
+	ctx := c.Request.Context()
+	for _, kv := range [][2]string{
+		{core.SettingQueueWorkerCount, strconv.Itoa(workers)},
+		{core.SettingQueueMaxRetries, strconv.Itoa(policy.MaxRetries)},
+		{core.SettingQueueRetryDelay, policy.RetryDelay.String()},
+		{core.SettingQueueMaxRetryBackoff, policy.MaxRetryBackoff.String()},
+	} {
+		if err := db.Settings.SetSetting(ctx, kv[0], kv[1], false); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to update queue settings",
+			})
+			return
+		}
+	}
+
+	if err := h.queue.SetRetryPolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := h.queue.SetWorkerCount(workers); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp := queueSettingsResponse(h.queue)
+	recordAudit(c, "update", "queue_settings", 0, resp)
+
+	c.JSON(http.StatusOK, resp)
+}
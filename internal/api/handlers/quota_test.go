@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+)
+
+func TestQuotaThrottlesKeyOverLimit(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "Shipping")
+	templateID := insertTestTemplate(t, database, "Address", testLabelSchema)
+
+	quota := middleware.NewJobQuota(config.QuotaConfig{
+		Window:        time.Hour,
+		JobsPerWindow: 2,
+		Keys:          map[string]int{"erp": 3},
+	})
+	jobs := NewJobHandler(database, core.NewQueue(database, nil, nil, nil, nil), core.NewTSPL2Generator())
+	jobs.SetQuota(quota)
+
+	router := newTestRouter()
+	api := router.Group("/api")
+	jobs.RegisterRoutes(api)
+
+	submit := func(key string) int {
+		req := newJSONRequest(http.MethodPost, "/api/jobs", map[string]any{
+			"printer_id": printerID, "template_id": templateID, "variables": map[string]string{"name": "A"},
+		})
+		if key != "" {
+			req.Header.Set(testAPIKeyHeader, key)
+		}
+		w := serve(router, req)
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("key %q: 429 without Retry-After", key)
+		}
+		return w.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := submit("limited"); code != http.StatusCreated {
+			t.Fatalf("job %d within quota: status %d, want 201", i+1, code)
+		}
+	}
+	if code := submit("limited"); code != http.StatusTooManyRequests {
+		t.Errorf("job over quota: status %d, want 429", code)
+	}
+
+	// Other keys, a key with its own limit and unauthenticated requests
+	// are counted separately.
+	if code := submit("other"); code != http.StatusCreated {
+		t.Errorf("another key: status %d, want 201", code)
+	}
+	for i := 0; i < 3; i++ {
+		if code := submit("erp"); code != http.StatusCreated {
+			t.Errorf("erp job %d within its override: status %d, want 201", i+1, code)
+		}
+	}
+	if code := submit("erp"); code != http.StatusTooManyRequests {
+		t.Errorf("erp job over its override: status %d, want 429", code)
+	}
+	if code := submit(""); code != http.StatusCreated {
+		t.Errorf("no key: status %d, want 201", code)
+	}
+}
+
+// TestQuotaCoversEveryEnqueueRoute checks that each route that queues jobs
+// counts against the caller's quota, not just POST /api/jobs.
+func TestQuotaCoversEveryEnqueueRoute(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "Shipping")
+	templateID := insertTestTemplate(t, database, "Address", testLabelSchema)
+	jobID := insertTestJob(t, database, printerID, string(core.JobStatusCompleted))
+	deadID := insertTestDeadLetterJob(t, database, printerID)
+
+	quota := middleware.NewJobQuota(config.QuotaConfig{Window: time.Hour, JobsPerWindow: 1})
+	queue := core.NewQueue(database, acceptingPrinterManager{}, nil, nil, nil)
+	generator := core.NewTSPL2Generator()
+
+	jobs := NewJobHandler(database, queue, generator)
+	jobs.SetQuota(quota)
+	templates := NewTemplateHandler(database, generator, queue)
+	templates.SetQuota(quota)
+	printers := &PrinterHandler{db: database}
+	printers.SetQueue(queue)
+	printers.SetQuota(quota)
+
+	router := newTestRouter()
+	api := router.Group("/api")
+	jobs.RegisterRoutes(api)
+	jobs.RegisterLegacyRoutes(&router.RouterGroup)
+	RegisterTemplateRoutes(api, templates)
+	RegisterPrinterRoutes(api, printers)
+
+	submit := func(key, method, path string, body any) int {
+		req := newJSONRequest(method, path, body)
+		req.Header.Set(testAPIKeyHeader, key)
+		w := serve(router, req)
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s %s: 429 without Retry-After", method, path)
+		}
+		return w.Code
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+	}{
+		{"template print", http.MethodPost, fmt.Sprintf("/api/templates/%d/print", templateID),
+			map[string]any{"printer_id": printerID, "variables": map[string]string{"name": "A"}}},
+		{"legacy print", http.MethodGet, "/print/Address/A1", nil},
+		{"raw print", http.MethodPost, fmt.Sprintf("/api/printers/%d/raw", printerID), map[string]any{"tspl": "PRINT 1\n"}},
+		{"reprint", http.MethodPost, fmt.Sprintf("/api/jobs/%d/reprint", jobID), nil},
+		{"reprint recent", http.MethodPost, fmt.Sprintf("/api/printers/%d/reprint-recent", printerID), map[string]any{"count": 1}},
+		{"dead-letter requeue", http.MethodPost, fmt.Sprintf("/api/jobs/dead-letter/%d/requeue", deadID), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tt.name
+			code := submit(key, http.MethodPost, "/api/jobs", map[string]any{
+				"printer_id": printerID, "template_id": templateID, "variables": map[string]string{"name": "A"},
+			})
+			if code != http.StatusCreated {
+				t.Fatalf("job within quota: status %d, want 201", code)
+			}
+			if code := submit(key, tt.method, tt.path, tt.body); code != http.StatusTooManyRequests {
+				t.Errorf("%s over quota: status %d, want 429", tt.name, code)
+			}
+			if code := submit(key+" unused", tt.method, tt.path, tt.body); code >= 300 {
+				t.Errorf("%s with a key under quota: status %d, want success", tt.name, code)
+			}
+		})
+	}
+
+	// Reprinting more jobs than the key has left is refused outright.
+	if code := submit("reprint two", http.MethodPost, fmt.Sprintf("/api/printers/%d/reprint-recent", printerID), map[string]any{"count": 2}); code != http.StatusTooManyRequests {
+		t.Errorf("reprint of 2 jobs with a quota of 1: status %d, want 429", code)
+	}
+}
+
+// TestQuotaCountsOnlyCreatedJobs checks that requests which do not create a
+// job, such as a scanner's repeat of a legacy print or a raw print the queue
+// refuses, leave the caller's quota untouched.
+func TestQuotaCountsOnlyCreatedJobs(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "Shipping")
+	insertTestTemplate(t, database, "Address", testLabelSchema)
+
+	quota := middleware.NewJobQuota(config.QuotaConfig{Window: time.Hour, JobsPerWindow: 1})
+	queue := core.NewQueue(database, acceptingPrinterManager{}, nil, nil, &config.QueueConfig{
+		WorkerCount: 1, LegacyDedupWindow: time.Minute, MaxTSPLBytes: 64,
+	})
+	jobs := NewJobHandler(database, queue, core.NewTSPL2Generator())
+	jobs.SetQuota(quota)
+	printers := &PrinterHandler{db: database}
+	printers.SetQueue(queue)
+	printers.SetQuota(quota)
+
+	router := newTestRouter()
+	jobs.RegisterLegacyRoutes(&router.RouterGroup)
+	RegisterPrinterRoutes(router.Group("/api"), printers)
+
+	oversized := newJSONRequest(http.MethodPost, fmt.Sprintf("/api/printers/%d/raw", printerID), map[string]any{
+		"tspl": strings.Repeat("TEXT 10,10,\"3\",0,1,1,\"A\"\n", 10) + "PRINT 1\n",
+	})
+	oversized.Header.Set(testAPIKeyHeader, "scanner")
+	if w := serve(router, oversized); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized raw print: %d %s, want 413", w.Code, w.Body)
+	}
+	if usage := quota.Usage("scanner"); usage != 0 {
+		t.Errorf("usage %d after a refused raw print, want 0", usage)
+	}
+
+	for i, wantDuplicate := range []bool{false, true} {
+		req := newJSONRequest(http.MethodGet, "/print/Address/A1", nil)
+		req.Header.Set(testAPIKeyHeader, "scanner")
+		w := serve(router, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("legacy print %d: %d %s, want 200", i+1, w.Code, w.Body)
+		}
+		var resp struct {
+			Duplicate bool `json:"duplicate"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Duplicate != wantDuplicate {
+			t.Errorf("legacy print %d: duplicate %v, want %v", i+1, resp.Duplicate, wantDuplicate)
+		}
+	}
+	if usage := quota.Usage("scanner"); usage != 1 {
+		t.Errorf("usage %d after a legacy print and its repeat, want 1", usage)
+	}
+
+	req := newJSONRequest(http.MethodGet, "/print/Address/A2", nil)
+	req.Header.Set(testAPIKeyHeader, "scanner")
+	if w := serve(router, req); w.Code != http.StatusTooManyRequests {
+		t.Errorf("new legacy print over quota: %d %s, want 429", w.Code, w.Body)
+	}
+}
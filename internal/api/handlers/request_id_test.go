@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/logging"
+)
+
+// logBuffer collects log output written from several goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines decodes every JSON log line written so far.
+func (b *logBuffer) lines(t *testing.T) []map[string]any {
+	t.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var lines []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("decode log line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// captureLogs makes the default logger write JSON lines to the returned
+// buffer for the rest of the test.
+func captureLogs(t *testing.T) *logBuffer {
+	t.Helper()
+
+	logs := &logBuffer{}
+	previous := slog.Default()
+	slog.SetDefault(logging.New(config.LoggingConfig{Level: "debug", Format: "json"}, logs))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return logs
+}
+
+func TestRequestIDFollowsJobIntoLogs(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	logs := captureLogs(t)
+
+	queue := core.NewQueue(database, acceptingPrinterManager{}, nil, nil, &config.QueueConfig{WorkerCount: 1})
+	if err := queue.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	t.Cleanup(queue.Stop)
+	router := newTestRouter()
+	router.Use(middleware.RequestID())
+	NewJobHandler(database, queue, core.NewTSPL2Generator()).RegisterRoutes(router.Group("/api"))
+
+	req := newJSONRequest(http.MethodPost, "/api/jobs", map[string]any{
+		"printer_id": printerID, "template_id": templateID, "variables": map[string]string{"name": "WIDGET"},
+	})
+	req.Header.Set(middleware.RequestIDHeader, "order-1234")
+	w := serve(router, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create job: %d %s", w.Code, w.Body)
+	}
+	var job JobResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	waitForCompletion(t, database, job.ID)
+
+	seen := make(map[string]bool)
+	for _, line := range logs.lines(t) {
+		if id, ok := line["job_id"].(float64); !ok || int64(id) != job.ID {
+			continue
+		}
+		msg, _ := line["msg"].(string)
+		seen[msg] = true
+		if line[logging.RequestIDKey] != "order-1234" {
+			t.Errorf("%s log line has request ID %v, want order-1234", msg, line[logging.RequestIDKey])
+		}
+	}
+	for _, msg := range []string{"job_enqueued", "job_started", "job_completed"} {
+		if !seen[msg] {
+			t.Errorf("no %s log line for job %d", msg, job.ID)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/orrn/spool/internal/core"
+)
+
+// SchemaValidationQuery is accepted by endpoints that validate a label
+// schema. Strict additionally rejects element fields the element type does
+// not use.
+type SchemaValidationQuery struct {
+	Strict bool `form:"strict"`
+}
+
+// The element schemas below list the fields each element type accepts and
+// their JSON types. Fields tagged schema:"required" must be present.
+
+type textElementSchema struct {
+	Type     string `json:"type"`
+	ShowIf   string `json:"show_if"`
+	X        int    `json:"x" schema:"required"`
+	Y        int    `json:"y" schema:"required"`
+	Content  string `json:"content" schema:"required"`
+	Font     string `json:"font"`
+	Rotation int    `json:"rotation"`
+	XScale   int    `json:"x_scale"`
+	YScale   int    `json:"y_scale"`
+}
+
+type barcodeElementSchema struct {
+	Type      string `json:"type"`
+	ShowIf    string `json:"show_if"`
+	X         int    `json:"x" schema:"required"`
+	Y         int    `json:"y" schema:"required"`
+	Content   string `json:"content" schema:"required"`
+	Symbology string `json:"symbology"`
+	Height    int    `json:"height"`
+	Rotation  int    `json:"rotation"`
+	Narrow    int    `json:"narrow"`
+	Wide      int    `json:"wide"`
+	// HumanReadable is 0-3: none, or text aligned left, centre or right.
+	HumanReadable int `json:"human_readable"`
+}
+
+type qrcodeElementSchema struct {
+	Type      string `json:"type"`
+	ShowIf    string `json:"show_if"`
+	X         int    `json:"x" schema:"required"`
+	Y         int    `json:"y" schema:"required"`
+	Content   string `json:"content" schema:"required"`
+	Level     string `json:"level"`
+	CellWidth int    `json:"cell_width"`
+	Rotation  int    `json:"rotation"`
+	Mask      *int   `json:"mask"`
+}
+
+type pdf417ElementSchema struct {
+	Type       string `json:"type"`
+	ShowIf     string `json:"show_if"`
+	X          int    `json:"x" schema:"required"`
+	Y          int    `json:"y" schema:"required"`
+	Content    string `json:"content" schema:"required"`
+	Columns    int    `json:"columns"`
+	Rows       int    `json:"rows"`
+	Security   int    `json:"security"`
+	ModuleSize int    `json:"module_size"`
+	Rotation   int    `json:"rotation"`
+}
+
+type datamatrixElementSchema struct {
+	Type       string `json:"type"`
+	ShowIf     string `json:"show_if"`
+	X          int    `json:"x" schema:"required"`
+	Y          int    `json:"y" schema:"required"`
+	Content    string `json:"content" schema:"required"`
+	ModuleSize int    `json:"module_size"`
+	Rotation   int    `json:"rotation"`
+	Encoding   string `json:"encoding"`
+}
+
+type boxElementSchema struct {
+	Type      string `json:"type"`
+	ShowIf    string `json:"show_if"`
+	X         int    `json:"x" schema:"required"`
+	Y         int    `json:"y" schema:"required"`
+	XEnd      int    `json:"x_end" schema:"required"`
+	YEnd      int    `json:"y_end" schema:"required"`
+	Thickness int    `json:"thickness"`
+}
+
+type lineElementSchema struct {
+	Type      string `json:"type"`
+	ShowIf    string `json:"show_if"`
+	X1        int    `json:"x1" schema:"required"`
+	Y1        int    `json:"y1" schema:"required"`
+	X2        int    `json:"x2" schema:"required"`
+	Y2        int    `json:"y2" schema:"required"`
+	Thickness int    `json:"thickness"`
+}
+
+type circleElementSchema struct {
+	Type      string `json:"type"`
+	ShowIf    string `json:"show_if"`
+	X         int    `json:"x" schema:"required"`
+	Y         int    `json:"y" schema:"required"`
+	Radius    int    `json:"radius" schema:"required"`
+	Thickness int    `json:"thickness"`
+}
+
+type ellipseElementSchema struct {
+	Type      string `json:"type"`
+	ShowIf    string `json:"show_if"`
+	X         int    `json:"x" schema:"required"`
+	Y         int    `json:"y" schema:"required"`
+	XRadius   int    `json:"x_radius" schema:"required"`
+	YRadius   int    `json:"y_radius" schema:"required"`
+	Thickness int    `json:"thickness"`
+}
+
+type blockElementSchema struct {
+	Type     string `json:"type"`
+	ShowIf   string `json:"show_if"`
+	X        int    `json:"x" schema:"required"`
+	Y        int    `json:"y" schema:"required"`
+	Width    int    `json:"width" schema:"required"`
+	Height   int    `json:"height" schema:"required"`
+	Content  string `json:"content" schema:"required"`
+	Font     string `json:"font"`
+	Rotation int    `json:"rotation"`
+	XScale   int    `json:"x_scale"`
+	YScale   int    `json:"y_scale"`
+	Spacing  int    `json:"spacing"`
+	Overflow string `json:"overflow"`
+}
+
+type imageElementSchema struct {
+	Type      string `json:"type"`
+	ShowIf    string `json:"show_if"`
+	X         int    `json:"x" schema:"required"`
+	Y         int    `json:"y" schema:"required"`
+	ImagePath string `json:"image_path"`
+	ImageData string `json:"image_data"`
+	Embed     bool   `json:"embed"`
+	Threshold int    `json:"threshold"`
+	Dither    bool   `json:"dither"`
+}
+
+type elementField struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+var elementSchemas = map[string][]elementField{
+	"text":       schemaFields(textElementSchema{}),
+	"barcode":    schemaFields(barcodeElementSchema{}),
+	"qrcode":     schemaFields(qrcodeElementSchema{}),
+	"pdf417":     schemaFields(pdf417ElementSchema{}),
+	"datamatrix": schemaFields(datamatrixElementSchema{}),
+	"box":        schemaFields(boxElementSchema{}),
+	"line":       schemaFields(lineElementSchema{}),
+	"circle":     schemaFields(circleElementSchema{}),
+	"ellipse":    schemaFields(ellipseElementSchema{}),
+	"block":      schemaFields(blockElementSchema{}),
+	"image":      schemaFields(imageElementSchema{}),
+}
+
+func schemaFields(v interface{}) []elementField {
+	t := reflect.TypeOf(v)
+	fields := make([]elementField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fields = append(fields, elementField{
+			name:     f.Tag.Get("json"),
+			typ:      f.Type,
+			required: f.Tag.Get("schema") == "required",
+		})
+	}
+	return fields
+}
+
+// ValidateSchemaStrict checks a label schema before it is saved. Each
+// element is checked against the typed schema for its element type, so a
+// field of the wrong JSON type (x as a string, thickness as a fraction) is
+// reported by name. With rejectUnknown, fields the element type does not
+// use are errors too.
+func ValidateSchemaStrict(schema *LabelSchemaJSON, rejectUnknown bool) []string {
+	var errs []string
+
+	if schema.WidthMM <= 0 {
+		errs = append(errs, "width_mm must be greater than 0")
+	}
+	if schema.HeightMM <= 0 {
+		errs = append(errs, "height_mm must be greater than 0")
+	}
+	if len(schema.Elements) == 0 {
+		errs = append(errs, "schema must have at least one element")
+	}
+	if schema.Direction != 0 && schema.Direction != 1 {
+		errs = append(errs, fmt.Sprintf("direction must be 0 or 1, got %d", schema.Direction))
+	}
+	if err := core.ValidateLabelMedia(schema.MediaType, schema.GapMM, schema.BlineMM, schema.OffsetMM); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := core.ValidatePostCommands(schema.PostCommands); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if schema.Speed != 0 && (schema.Speed < core.MinPrintSpeed || schema.Speed > core.MaxPrintSpeed) {
+		errs = append(errs, fmt.Sprintf("speed must be between %d and %d, got %d", core.MinPrintSpeed, core.MaxPrintSpeed, schema.Speed))
+	}
+	if d := schema.Density; d != nil && (*d < core.MinPrintDensity || *d > core.MaxPrintDensity) {
+		errs = append(errs, fmt.Sprintf("density must be between %d and %d, got %d", core.MinPrintDensity, core.MaxPrintDensity, *d))
+	}
+
+	for i, elem := range schema.Elements {
+		errs = append(errs, validateElementStrict(elem, i, rejectUnknown)...)
+		if cond, ok := elem["show_if"].(string); ok && cond != "" {
+			name, _ := core.ConditionVariable(cond)
+			if _, declared := schema.Variables[name]; !declared {
+				errs = append(errs, fmt.Sprintf("element[%d]: show_if references undeclared variable '%s'", i, name))
+			}
+		}
+	}
+
+	varNames := make([]string, 0, len(schema.Variables))
+	for name := range schema.Variables {
+		varNames = append(varNames, name)
+	}
+	sort.Strings(varNames)
+	for _, varName := range varNames {
+		varDef := schema.Variables[varName]
+		if varDef.Type == "" {
+			errs = append(errs, fmt.Sprintf("variable '%s' missing type", varName))
+		}
+		switch varDef.Source {
+		case "", core.VariableSourceDefault, core.VariableSourceDate, core.VariableSourceDateTime:
+		default:
+			errs = append(errs, fmt.Sprintf("variable '%s' has unknown source '%s'", varName, varDef.Source))
+		}
+		if varDef.Source != "" && !varDef.Locked {
+			errs = append(errs, fmt.Sprintf("variable '%s' has a source but is not locked", varName))
+		}
+		if varDef.Required && varDef.Default != "" {
+			errs = append(errs, fmt.Sprintf("variable '%s' is required but has a default value", varName))
+		}
+		if varDef.Pattern != "" {
+			if err := core.ValidateVariablePattern(varDef.Pattern); err != nil {
+				errs = append(errs, fmt.Sprintf("variable '%s' has an invalid pattern: %v", varName, err))
+				continue
+			}
+		}
+		def := core.VariableDef{Type: varDef.Type, Format: varDef.Format, Pattern: varDef.Pattern}
+		for _, value := range []string{varDef.Default, varDef.Sample} {
+			if value == "" {
+				continue
+			}
+			if err := core.CheckVariableValue(varName, def, value); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateElementStrict(elem map[string]interface{}, index int, rejectUnknown bool) []string {
+	prefix := fmt.Sprintf("element[%d]", index)
+
+	elemType, ok := elem["type"].(string)
+	if !ok {
+		return []string{fmt.Sprintf("%s: missing or invalid 'type' field", prefix)}
+	}
+	fields, ok := elementSchemas[elemType]
+	if !ok {
+		return []string{fmt.Sprintf("%s: unknown element type '%s'", prefix, elemType)}
+	}
+
+	var errs []string
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.name] = true
+
+		value, present := elem[f.name]
+		if !present || value == nil {
+			if f.required {
+				errs = append(errs, fmt.Sprintf("%s: %s element missing '%s'", prefix, elemType, f.name))
+			}
+			continue
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s element field '%s' is not valid JSON", prefix, elemType, f.name))
+			continue
+		}
+		if err := json.Unmarshal(raw, reflect.New(f.typ).Interface()); err != nil {
+			got := "an invalid value"
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				got = typeErr.Value
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s element field '%s' must be %s, got %s",
+				prefix, elemType, f.name, describeSchemaType(f.typ), got))
+		}
+	}
+
+	if elemType == "image" {
+		path, _ := elem["image_path"].(string)
+		data, _ := elem["image_data"].(string)
+		if path == "" && data == "" {
+			errs = append(errs, fmt.Sprintf("%s: image element missing 'image_path' or 'image_data'", prefix))
+		}
+	}
+
+	if rejectUnknown {
+		var unknown []string
+		for name := range elem {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		for _, name := range unknown {
+			errs = append(errs, fmt.Sprintf("%s: %s element has unknown field '%s'", prefix, elemType, name))
+		}
+	}
+
+	return errs
+}
+
+func describeSchemaType(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	default:
+		return strings.ToLower(t.Kind().String())
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// strictSchema decodes a schema with a single element given as JSON.
+func strictSchema(t *testing.T, element string) *LabelSchemaJSON {
+	t.Helper()
+
+	var schema LabelSchemaJSON
+	if err := json.Unmarshal([]byte(`{"width_mm":50,"height_mm":30,"elements":[`+element+`]}`), &schema); err != nil {
+		t.Fatalf("decode schema: %v", err)
+	}
+	return &schema
+}
+
+func TestValidateSchemaStrictReportsMistypedField(t *testing.T) {
+	tests := []struct {
+		element string
+		want    string
+	}{
+		{`{"type":"text","x":"10","y":10,"content":"A"}`, "text element field 'x' must be an integer, got string"},
+		{`{"type":"barcode","x":0,"y":0,"content":"1","height":"tall"}`, "barcode element field 'height' must be an integer, got string"},
+		{`{"type":"qrcode","x":0,"y":0,"content":"A","level":7}`, "qrcode element field 'level' must be a string, got number"},
+		{`{"type":"pdf417","x":0,"y":0,"content":"A","columns":2.5}`, "pdf417 element field 'columns' must be an integer, got number 2.5"},
+		{`{"type":"datamatrix","x":0,"y":0,"content":"A","module_size":true}`, "datamatrix element field 'module_size' must be an integer, got bool"},
+		{`{"type":"box","x":0,"y":0,"x_end":10,"y_end":10,"thickness":1.5}`, "box element field 'thickness' must be an integer, got number 1.5"},
+		{`{"type":"line","x1":0,"y1":0,"x2":"10","y2":0}`, "line element field 'x2' must be an integer, got string"},
+		{`{"type":"circle","x":0,"y":0,"radius":[5]}`, "circle element field 'radius' must be an integer, got array"},
+		{`{"type":"ellipse","x":0,"y":0,"x_radius":5,"y_radius":{}}`, "ellipse element field 'y_radius' must be an integer, got object"},
+		{`{"type":"block","x":0,"y":0,"width":100,"height":50,"content":"A","overflow":1}`, "block element field 'overflow' must be a string, got number"},
+		{`{"type":"image","x":0,"y":0,"image_path":"logo.png","embed":"yes"}`, "image element field 'embed' must be a boolean, got string"},
+	}
+	for _, tt := range tests {
+		errs := ValidateSchemaStrict(strictSchema(t, tt.element), false)
+		if len(errs) != 1 || !strings.Contains(errs[0], tt.want) {
+			t.Errorf("%s: errors are %q, want one containing %q", tt.element, errs, tt.want)
+		}
+	}
+}
+
+func TestValidateSchemaStrictUnknownFields(t *testing.T) {
+	schema := strictSchema(t, `{"type":"text","x":0,"y":0,"content":"A","colour":"red"}`)
+
+	if errs := ValidateSchemaStrict(schema, false); len(errs) != 0 {
+		t.Errorf("lenient validation returned %q, want no errors", errs)
+	}
+	errs := ValidateSchemaStrict(schema, true)
+	if len(errs) != 1 || !strings.Contains(errs[0], "unknown field 'colour'") {
+		t.Errorf("strict validation returned %q, want the unknown field reported", errs)
+	}
+}
+
+func TestCreateTemplateRejectsMistypedSchema(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+
+	w := serveJSON(router, http.MethodPost, "/api/templates", map[string]any{
+		"name": "mistyped",
+		"schema": map[string]any{
+			"width_mm": 50, "height_mm": 30,
+			"elements": []map[string]any{{"type": "text", "x": "10", "y": 10, "content": "A"}},
+		},
+	})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "field 'x' must be an integer") {
+		t.Errorf("create with mistyped x: %d %s, want 400 naming the field", w.Code, w.Body)
+	}
+
+	w = serveJSON(router, http.MethodPost, "/api/templates?strict=true", map[string]any{
+		"name": "unknown-field",
+		"schema": map[string]any{
+			"width_mm": 50, "height_mm": 30,
+			"elements": []map[string]any{{"type": "text", "x": 10, "y": 10, "content": "A", "colour": "red"}},
+		},
+	})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unknown field 'colour'") {
+		t.Errorf("strict create with unknown field: %d %s, want 400 naming the field", w.Code, w.Body)
+	}
+}
+
+func TestValidateSchemaStrictDirection(t *testing.T) {
+	schema := strictSchema(t, `{"type":"text","x":0,"y":0,"content":"A"}`)
+
+	for _, direction := range []int{0, 1} {
+		schema.Direction = direction
+		if errs := ValidateSchemaStrict(schema, true); len(errs) != 0 {
+			t.Errorf("direction %d: errors are %q, want none", direction, errs)
+		}
+	}
+	schema.Direction = 2
+	if errs := ValidateSchemaStrict(schema, false); len(errs) != 1 || !strings.Contains(errs[0], "direction must be 0 or 1") {
+		t.Errorf("direction 2: errors are %q, want the direction rejected", errs)
+	}
+}
+
+func TestValidateSchemaStrictShowIf(t *testing.T) {
+	schema := strictSchema(t, `{"type":"text","x":0,"y":0,"content":"FRAGILE","show_if":"!fragile"}`)
+
+	if errs := ValidateSchemaStrict(schema, true); len(errs) != 1 || !strings.Contains(errs[0], "show_if references undeclared variable 'fragile'") {
+		t.Errorf("undeclared condition variable: errors are %q, want it reported", errs)
+	}
+	schema.Variables = map[string]VariableDefJSON{"fragile": {Type: "string"}}
+	if errs := ValidateSchemaStrict(schema, true); len(errs) != 0 {
+		t.Errorf("declared condition variable: errors are %q, want none", errs)
+	}
+}
+
+func TestValidateSchemaStrictPostCommands(t *testing.T) {
+	schema := strictSchema(t, `{"type":"text","x":0,"y":0,"content":"A"}`)
+
+	schema.PostCommands = []string{"SET CUTTER 1", "CUT"}
+	if errs := ValidateSchemaStrict(schema, true); len(errs) != 0 {
+		t.Errorf("allowed post commands: errors are %q, want none", errs)
+	}
+	schema.PostCommands = []string{"CUT", "KILL \"*\""}
+	errs := ValidateSchemaStrict(schema, true)
+	if len(errs) != 1 || !strings.Contains(errs[0], "post_commands[1]") {
+		t.Errorf("disallowed post command: errors are %q, want post_commands[1] reported", errs)
+	}
+}
+
+func TestValidateSchemaStrictSpeedAndDensity(t *testing.T) {
+	schema := strictSchema(t, `{"type":"text","x":0,"y":0,"content":"A"}`)
+
+	density := 10
+	schema.Speed, schema.Density = 4, &density
+	if errs := ValidateSchemaStrict(schema, true); len(errs) != 0 {
+		t.Errorf("speed 4, density 10: errors are %q, want none", errs)
+	}
+
+	density = 20
+	schema.Speed = 15
+	errs := ValidateSchemaStrict(schema, true)
+	if len(errs) != 2 || !strings.Contains(errs[0], "speed must be between 1 and 12") || !strings.Contains(errs[1], "density must be between 0 and 15") {
+		t.Errorf("speed 15, density 20: errors are %q, want both reported", errs)
+	}
+}
+
+func TestValidateSchemaStrictMedia(t *testing.T) {
+	schema := strictSchema(t, `{"type":"text","x":0,"y":0,"content":"A"}`)
+
+	schema.MediaType, schema.BlineMM, schema.OffsetMM = "bline", 3, 1
+	if errs := ValidateSchemaStrict(schema, true); len(errs) != 0 {
+		t.Errorf("bline media with bline_mm: errors are %q, want none", errs)
+	}
+
+	schema.GapMM = 2
+	errs := ValidateSchemaStrict(schema, true)
+	if len(errs) != 1 || !strings.Contains(errs[0], "gap_mm only applies to gap media") {
+		t.Errorf("bline media with gap_mm: errors are %q, want the gap reported", errs)
+	}
+}
+
+func TestValidateSchemaStrictVariableTypes(t *testing.T) {
+	schema := strictSchema(t, `{"type":"text","x":0,"y":0,"content":"{{qty}} {{lot}}"}`)
+
+	schema.Variables = map[string]VariableDefJSON{
+		"qty":  {Type: "number", Default: "1", Sample: "12"},
+		"lot":  {Type: "string", Pattern: `LOT-\d+`, Sample: "LOT-7"},
+		"best": {Type: "date", Format: "02/01/2006", Sample: "31/12/2026"},
+	}
+	if errs := ValidateSchemaStrict(schema, true); len(errs) != 0 {
+		t.Errorf("valid variables: errors are %q, want none", errs)
+	}
+
+	schema.Variables = map[string]VariableDefJSON{
+		"qty":  {Type: "number", Default: "one"},
+		"lot":  {Type: "string", Pattern: `LOT-(`},
+		"best": {Type: "date", Format: "02/01/2006", Sample: "2026-12-31"},
+	}
+	errs := ValidateSchemaStrict(schema, true)
+	if len(errs) != 3 || !strings.Contains(errs[0], "'best' must be a date") ||
+		!strings.Contains(errs[1], "'lot' has an invalid pattern") || !strings.Contains(errs[2], "'qty' must be a number") {
+		t.Errorf("invalid variables: errors are %q, want the sample, pattern and default reported", errs)
+	}
+}
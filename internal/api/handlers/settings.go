@@ -8,8 +8,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
-	"orrn-spool/internal/config"
-	"orrn-spool/internal/db"
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
 )
 
 const (
@@ -23,13 +25,18 @@ const (
 type SettingsHandler struct {
 	db     *sql.DB
 	config *config.Config
+	queue  *core.Queue
 }
 
 type SettingsResponse struct {
-	ArchiveDays    int    `json:"archive_days"`
-	ArchiveEnabled bool   `json:"archive_enabled"`
-	AIEnabled      bool   `json:"ai_enabled"`
-	AIModel        string `json:"ai_model"`
+	ArchiveDays      int    `json:"archive_days"`
+	ArchiveEnabled   bool   `json:"archive_enabled"`
+	RetentionDays    int    `json:"retention_days"`
+	RetentionEnabled bool   `json:"retention_enabled"`
+	AIEnabled        bool   `json:"ai_enabled"`
+	AIModel          string `json:"ai_model"`
+
+	PrinterDefaults PrinterDefaults `json:"printer_defaults"`
 }
 
 type ChangePasswordRequest struct {
@@ -51,6 +58,11 @@ type ServerConfigResponse struct {
 	LogFormat           string `json:"log_format"`
 }
 
+type UpdateRetentionSettingsRequest struct {
+	RetentionDays    int  `json:"retention_days" binding:"min=0,max=3650"`
+	RetentionEnabled bool `json:"retention_enabled"`
+}
+
 type UpdateArchiveSettingsRequest struct {
 	ArchiveDays    int  `json:"archive_days" binding:"min=0"`
 	ArchiveEnabled bool `json:"archive_enabled"`
@@ -63,13 +75,21 @@ func NewSettingsHandler(database *sql.DB, cfg *config.Config) *SettingsHandler {
 	}
 }
 
+// SetQueue enables the endpoints that tune the job queue at runtime.
+func (h *SettingsHandler) SetQueue(queue *core.Queue) {
+	h.queue = queue
+}
+
 func (h *SettingsHandler) GetSettings(c *gin.Context) {
 	ctx := c.Request.Context()
 	resp := SettingsResponse{
-		ArchiveDays:    h.config.Database.ArchiveDays,
-		ArchiveEnabled: true,
-		AIEnabled:      false,
-		AIModel:        "",
+		ArchiveDays:      h.config.Database.ArchiveDays,
+		ArchiveEnabled:   true,
+		RetentionDays:    h.config.Database.RetentionDays,
+		RetentionEnabled: h.config.Database.RetentionEnabled,
+		AIEnabled:        false,
+		AIModel:          "",
+		PrinterDefaults:  loadPrinterDefaults(ctx),
 	}
 
 	if setting, err := db.Settings.GetSetting(ctx, settingsKeyArchiveDays); err == nil {
@@ -82,6 +102,16 @@ func (h *SettingsHandler) GetSettings(c *gin.Context) {
 		resp.ArchiveEnabled = setting.Value == "true"
 	}
 
+	if setting, err := db.Settings.GetSetting(ctx, core.SettingRetentionDays); err == nil {
+		if days, err := strconv.Atoi(setting.Value); err == nil && days > 0 {
+			resp.RetentionDays = days
+		}
+	}
+
+	if setting, err := db.Settings.GetSetting(ctx, core.SettingRetentionEnabled); err == nil {
+		resp.RetentionEnabled = setting.Value == "true"
+	}
+
 	if setting, err := db.Settings.GetSetting(ctx, settingsKeyAIEnabled); err == nil {
 		resp.AIEnabled = setting.Value == "true"
 	}
@@ -214,9 +244,66 @@ func (h *SettingsHandler) UpdateArchiveSettings(c *gin.Context) {
 	})
 }
 
+// UpdateRetentionSettings controls the cleanup worker that deletes old
+// completed and cancelled jobs. A zero retention_days keeps the configured
+// default.
+func (h *SettingsHandler) UpdateRetentionSettings(c *gin.Context) {
+	var req UpdateRetentionSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	retentionDays := req.RetentionDays
+	if retentionDays <= 0 {
+		retentionDays = h.config.Database.RetentionDays
+	}
+
+	if err := db.Settings.SetSetting(ctx, core.SettingRetentionDays, strconv.Itoa(retentionDays), false); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to update retention days",
+		})
+		return
+	}
+
+	if err := db.Settings.SetSetting(ctx, core.SettingRetentionEnabled, strconv.FormatBool(req.RetentionEnabled), false); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to update retention enabled setting",
+		})
+		return
+	}
+
+	recordAudit(c, "update", "retention", 0, gin.H{
+		"retention_days":    retentionDays,
+		"retention_enabled": req.RetentionEnabled,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":           true,
+		"message":           "Retention settings updated",
+		"retention_days":    retentionDays,
+		"retention_enabled": req.RetentionEnabled,
+	})
+}
+
 func RegisterSettingsRoutes(r *gin.RouterGroup, h *SettingsHandler) {
+	admin := middleware.RequireRole(middleware.RoleAdmin)
 	r.GET("/settings", h.GetSettings)
-	r.PUT("/settings/password", h.ChangePassword)
+	r.PUT("/settings/password", admin, h.ChangePassword)
 	r.GET("/settings/server", h.GetServerConfig)
-	r.PUT("/settings/archive", h.UpdateArchiveSettings)
+	r.PUT("/settings/archive", admin, h.UpdateArchiveSettings)
+	r.PUT("/settings/retention", admin, h.UpdateRetentionSettings)
+	r.GET("/settings/queue", h.GetQueueSettings)
+	r.PUT("/settings/queue", admin, h.UpdateQueueSettings)
+	r.GET("/settings/maintenance", h.GetMaintenance)
+	r.PUT("/settings/maintenance", admin, h.UpdateMaintenance)
+	r.GET("/settings/printer-defaults", h.GetPrinterDefaults)
+	r.PUT("/settings/printer-defaults", admin, h.UpdatePrinterDefaults)
 }
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+)
+
+// newSettingsRouter serves the settings routes with cfg as the loaded
+// configuration.
+func newSettingsRouter(t *testing.T, cfg *config.Config) *gin.Engine {
+	t.Helper()
+
+	router := newTestRouter()
+	RegisterSettingsRoutes(router.Group("/api"), NewSettingsHandler(db.GetDB(), cfg))
+	t.Cleanup(func() {
+		ctx := context.Background()
+		db.Settings.DeleteSetting(ctx, core.SettingRetentionDays)
+		db.Settings.DeleteSetting(ctx, core.SettingRetentionEnabled)
+		for _, key := range []string{settingsKeyPrinterDefaultDPI, settingsKeyPrinterDefaultPort, settingsKeyPrinterDefaultLabelWidthMM, settingsKeyPrinterDefaultLabelHeightMM} {
+			db.Settings.DeleteSetting(ctx, key)
+		}
+	})
+	return router
+}
+
+func TestRetentionDeletesOnlyOldFinishedJobs(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	cfg := &config.Config{Database: config.DatabaseConfig{RetentionDays: 90}}
+	router := newSettingsRouter(t, cfg)
+
+	finishedJob := func(status, age string) int64 {
+		id := insertTestJob(t, database, printerID, status)
+		if _, err := database.Exec("UPDATE print_jobs SET completed_at = datetime('now', ?) WHERE id = ?", age, id); err != nil {
+			t.Fatalf("age job: %v", err)
+		}
+		return id
+	}
+	oldCompleted := finishedJob("completed", "-100 days")
+	oldCancelled := finishedJob("cancelled", "-100 days")
+	recentCompleted := finishedJob("completed", "-10 days")
+	oldFailed := finishedJob("failed", "-100 days")
+	pending := insertTestJob(t, database, printerID, "pending")
+	processing := insertTestJob(t, database, printerID, "processing")
+
+	worker := core.NewRetentionWorker(&cfg.Database)
+
+	// Retention is off until it is enabled.
+	if deleted, err := worker.RunOnce(context.Background()); err != nil || deleted != 0 {
+		t.Fatalf("disabled retention deleted %d jobs (err %v), want none", deleted, err)
+	}
+
+	w := serveJSON(router, http.MethodPut, "/api/settings/retention", map[string]any{
+		"retention_enabled": true,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable retention: %d %s", w.Code, w.Body)
+	}
+
+	deleted, err := worker.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("run retention: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("retention deleted %d jobs, want 2", deleted)
+	}
+
+	for id, want := range map[int64]bool{
+		oldCompleted:    false,
+		oldCancelled:    false,
+		recentCompleted: true,
+		oldFailed:       true,
+		pending:         true,
+		processing:      true,
+	} {
+		var count int
+		if err := database.QueryRow("SELECT COUNT(*) FROM print_jobs WHERE id = ?", id).Scan(&count); err != nil {
+			t.Fatalf("count job %d: %v", id, err)
+		}
+		if got := count == 1; got != want {
+			t.Errorf("job %d kept = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestRetentionSettingsUseStoredDays(t *testing.T) {
+	setupTestDB(t)
+	cfg := &config.Config{Database: config.DatabaseConfig{RetentionDays: 90}}
+	router := newSettingsRouter(t, cfg)
+
+	w := serveJSON(router, http.MethodPut, "/api/settings/retention", map[string]any{
+		"retention_enabled": true, "retention_days": 7,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update retention: %d %s", w.Code, w.Body)
+	}
+
+	enabled, days := core.NewRetentionWorker(&cfg.Database).Policy(context.Background())
+	if !enabled || days != 7 {
+		t.Errorf("policy is enabled=%v days=%d, want enabled with 7 days", enabled, days)
+	}
+}
+
+func TestUpdateQueueSettings(t *testing.T) {
+	database := setupTestDB(t)
+	cfg := &config.Config{Queue: config.QueueConfig{WorkerCount: 2, MaxRetries: 3, RetryDelay: 10 * time.Second, MaxRetryBackoff: 5 * time.Minute}}
+	queue := core.NewQueue(database, nil, nil, nil, &cfg.Queue)
+	h := NewSettingsHandler(database, cfg)
+	h.SetQueue(queue)
+	router := newTestRouter()
+	RegisterSettingsRoutes(router.Group("/api"), h)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		for _, key := range []string{core.SettingQueueWorkerCount, core.SettingQueueMaxRetries, core.SettingQueueRetryDelay, core.SettingQueueMaxRetryBackoff} {
+			db.Settings.DeleteSetting(ctx, key)
+		}
+	})
+
+	w := serveJSON(router, http.MethodPut, "/api/settings/queue", map[string]any{
+		"worker_count": 4, "retry_delay": "30s",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update queue settings: %d %s", w.Code, w.Body)
+	}
+	var resp QueueSettingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode settings: %v", err)
+	}
+	want := QueueSettingsResponse{WorkerCount: 4, MaxRetries: 3, RetryDelay: "30s", MaxRetryBackoff: "5m0s"}
+	if resp != want {
+		t.Errorf("settings are %+v, want %+v", resp, want)
+	}
+	if n := queue.WorkerCount(); n != 4 {
+		t.Errorf("queue runs %d workers, want 4", n)
+	}
+	if stored, err := db.Settings.GetSetting(context.Background(), core.SettingQueueWorkerCount); err != nil || stored == nil || stored.Value != "4" {
+		t.Errorf("stored worker count is %+v (err %v), want 4", stored, err)
+	}
+
+	for _, body := range []map[string]any{
+		{"worker_count": 0},
+		{"retry_delay": "soon"},
+		{"max_retry_backoff": "-1s"},
+	} {
+		if w := serveJSON(router, http.MethodPut, "/api/settings/queue", body); w.Code != http.StatusBadRequest {
+			t.Errorf("update with %v: %d %s, want 400", body, w.Code, w.Body)
+		}
+	}
+}
+
+func TestQueueSettingsWithoutQueue(t *testing.T) {
+	setupTestDB(t)
+	router := newSettingsRouter(t, &config.Config{})
+
+	if w := serveJSON(router, http.MethodGet, "/api/settings/queue", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("get queue settings without a queue: %d %s, want 503", w.Code, w.Body)
+	}
+}
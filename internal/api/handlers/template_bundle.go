@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/db"
+)
+
+// TemplateBundleVersion is the bundle format version written by export and
+// accepted by import.
+const TemplateBundleVersion = 1
+
+const (
+	importConflictError  = "error"
+	importConflictRename = "rename"
+)
+
+var errImportNameConflict = errors.New("template name already exists")
+
+// TemplateBundle is a self-contained template export that can be imported
+// into another spool instance.
+type TemplateBundle struct {
+	Version     int             `json:"version"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Schema      LabelSchemaJSON `json:"schema"`
+}
+
+type ImportedTemplate struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	RenamedFrom string `json:"renamed_from,omitempty"`
+}
+
+type ImportTemplatesResponse struct {
+	Imported []ImportedTemplate `json:"imported"`
+}
+
+type ImportBundleError struct {
+	Index  int      `json:"index"`
+	Name   string   `json:"name"`
+	Errors []string `json:"errors"`
+}
+
+func templateToBundle(t *db.LabelTemplate) (*TemplateBundle, error) {
+	var schema LabelSchemaJSON
+	if err := json.Unmarshal([]byte(t.SchemaJSON), &schema); err != nil {
+		return nil, err
+	}
+
+	return &TemplateBundle{
+		Version:     TemplateBundleVersion,
+		Name:        t.Name,
+		Description: t.Description,
+		Schema:      schema,
+	}, nil
+}
+
+func (h *TemplateHandler) ExportTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+		return
+	}
+
+	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get template"})
+		return
+	}
+
+	bundle, err := templateToBundle(template)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+func (h *TemplateHandler) ExportAllTemplates(c *gin.Context) {
+	var templates []*db.LabelTemplate
+	var err error
+	if namespace := namespaceFilter(c, c.Query("namespace")); namespace != "" {
+		templates, err = db.Templates.ListTemplatesByNamespace(c.Request.Context(), namespace)
+	} else {
+		templates, err = db.Templates.ListTemplates(c.Request.Context())
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list templates"})
+		return
+	}
+
+	bundles := make([]*TemplateBundle, 0, len(templates))
+	for _, t := range templates {
+		bundle, err := templateToBundle(t)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to process template %d", t.ID)})
+			return
+		}
+		bundles = append(bundles, bundle)
+	}
+
+	c.JSON(http.StatusOK, bundles)
+}
+
+// ImportTemplates creates templates from one bundle or an array of bundles.
+// Every bundle is validated before anything is created, so a bad bundle
+// leaves the database untouched. Name conflicts fail the import unless
+// on_conflict=rename is given, in which case a numbered suffix is added.
+// Bundles carry no namespace; a namespaced caller's imports land in its own.
+func (h *TemplateHandler) ImportTemplates(c *gin.Context) {
+	onConflict := c.DefaultQuery("on_conflict", importConflictError)
+	if onConflict != importConflictError && onConflict != importConflictRename {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "on_conflict must be 'error' or 'rename'"})
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+
+	var bundles []TemplateBundle
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &bundles)
+	} else {
+		var bundle TemplateBundle
+		err = json.Unmarshal(trimmed, &bundle)
+		bundles = []TemplateBundle{bundle}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bundle: " + err.Error()})
+		return
+	}
+	if len(bundles) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no templates to import"})
+		return
+	}
+
+	var bundleErrors []ImportBundleError
+	for i := range bundles {
+		if errs := validateBundle(&bundles[i]); len(errs) > 0 {
+			bundleErrors = append(bundleErrors, ImportBundleError{Index: i, Name: bundles[i].Name, Errors: errs})
+		}
+	}
+	if len(bundleErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template bundles", "bundles": bundleErrors})
+		return
+	}
+
+	ctx := c.Request.Context()
+	names := make([]string, len(bundles))
+	taken := make(map[string]bool)
+	for i, bundle := range bundles {
+		name, err := resolveImportName(ctx, bundle.Name, onConflict, taken)
+		if err == errImportNameConflict {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("template '%s' already exists", bundle.Name)})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check template name"})
+			return
+		}
+		names[i] = name
+		taken[name] = true
+	}
+
+	resp := ImportTemplatesResponse{Imported: make([]ImportedTemplate, 0, len(bundles))}
+	for i, bundle := range bundles {
+		schemaBytes, err := json.Marshal(bundle.Schema)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to encode schema"})
+			return
+		}
+
+		template := &db.LabelTemplate{
+			Name:        names[i],
+			Description: bundle.Description,
+			Namespace:   namespaceFilter(c, ""),
+			SchemaJSON:  string(schemaBytes),
+			WidthMM:     bundle.Schema.WidthMM,
+			HeightMM:    bundle.Schema.HeightMM,
+		}
+		if err := db.Templates.CreateTemplate(ctx, template); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":    fmt.Sprintf("failed to create template '%s'", names[i]),
+				"imported": resp.Imported,
+			})
+			return
+		}
+
+		imported := ImportedTemplate{ID: template.ID, Name: names[i]}
+		if names[i] != bundle.Name {
+			imported.RenamedFrom = bundle.Name
+		}
+		resp.Imported = append(resp.Imported, imported)
+
+		recordAudit(c, "import", "template", template.ID, imported)
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func validateBundle(bundle *TemplateBundle) []string {
+	var errs []string
+	if bundle.Version != TemplateBundleVersion {
+		errs = append(errs, fmt.Sprintf("unsupported bundle version %d", bundle.Version))
+	}
+	if bundle.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	return append(errs, ValidateSchemaStrict(&bundle.Schema, false)...)
+}
+
+// resolveImportName returns the name an imported template will be created
+// under. taken holds names claimed by earlier bundles in the same import.
+func resolveImportName(ctx context.Context, name, onConflict string, taken map[string]bool) (string, error) {
+	candidate := name
+	for n := 2; ; n++ {
+		exists := taken[candidate]
+		if !exists {
+			_, err := db.Templates.GetTemplateByName(ctx, candidate)
+			if err == nil {
+				exists = true
+			} else if err != sql.ErrNoRows {
+				return "", err
+			}
+		}
+		if !exists {
+			return candidate, nil
+		}
+		if onConflict != importConflictRename {
+			return "", errImportNameConflict
+		}
+		candidate = fmt.Sprintf("%s (%d)", name, n)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestTemplateBundleRoundTrip(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+	original := createTemplate(t, router, map[string]any{
+		"name":        "shelf",
+		"description": "shelf edge label",
+		"schema": map[string]any{
+			"width_mm": 50, "height_mm": 30, "codepage": "1252",
+			"elements": []map[string]any{
+				{"type": "text", "x": 10, "y": 10, "content": "{{name}}"},
+				{"type": "barcode", "x": 10, "y": 60, "content": "{{sku}}"},
+			},
+			"variables": map[string]any{
+				"name": map[string]any{"type": "string", "required": true},
+				"sku":  map[string]any{"type": "string", "default": "000"},
+			},
+		},
+	})
+
+	w := serveJSON(router, http.MethodGet, fmt.Sprintf("/api/templates/%d/export", original.ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", w.Code, w.Body)
+	}
+	var bundle TemplateBundle
+	if err := json.Unmarshal(w.Body.Bytes(), &bundle); err != nil {
+		t.Fatalf("decode bundle: %v", err)
+	}
+	if bundle.Version != TemplateBundleVersion || bundle.Name != "shelf" || bundle.Description != "shelf edge label" {
+		t.Errorf("bundle is version %d %q %q, want version %d of the shelf template", bundle.Version, bundle.Name, bundle.Description, TemplateBundleVersion)
+	}
+
+	// The name is taken until the import asks for a rename.
+	if w := serveJSON(router, http.MethodPost, "/api/templates/import", bundle); w.Code != http.StatusConflict {
+		t.Fatalf("import over an existing name: %d %s, want 409", w.Code, w.Body)
+	}
+	w = serveJSON(router, http.MethodPost, "/api/templates/import?on_conflict=rename", bundle)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("import: %d %s", w.Code, w.Body)
+	}
+	var resp ImportTemplatesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode import: %v", err)
+	}
+	if len(resp.Imported) != 1 || resp.Imported[0].Name != "shelf (2)" || resp.Imported[0].RenamedFrom != "shelf" {
+		t.Fatalf("imported %+v, want shelf renamed to shelf (2)", resp.Imported)
+	}
+
+	w = serveJSON(router, http.MethodGet, fmt.Sprintf("/api/templates/%d", resp.Imported[0].ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get imported template: %d %s", w.Code, w.Body)
+	}
+	var imported TemplateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &imported); err != nil {
+		t.Fatalf("decode template: %v", err)
+	}
+	if imported.Description != original.Description || !reflect.DeepEqual(imported.Schema, original.Schema) {
+		t.Errorf("imported template is %+v, want the same schema and description as %+v", imported, original)
+	}
+}
+
+func TestImportTemplatesValidatesEveryBundle(t *testing.T) {
+	database := setupTestDB(t)
+	router := newTemplateRouter(t, database)
+	valid := map[string]any{
+		"version": TemplateBundleVersion, "name": "good",
+		"schema": map[string]any{
+			"width_mm": 50, "height_mm": 30,
+			"elements": []map[string]any{{"type": "text", "x": 10, "y": 10, "content": "ok"}},
+		},
+	}
+	invalid := map[string]any{
+		"version": TemplateBundleVersion, "name": "bad",
+		"schema": map[string]any{"width_mm": 0, "height_mm": 30},
+	}
+
+	w := serveJSON(router, http.MethodPost, "/api/templates/import", []any{valid, invalid})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("import with an invalid bundle: %d %s, want 400", w.Code, w.Body)
+	}
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM label_templates").Scan(&count); err != nil {
+		t.Fatalf("count templates: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d templates created, want none when any bundle is invalid", count)
+	}
+
+	w = serveJSON(router, http.MethodPost, "/api/templates/import", []any{valid})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("import: %d %s", w.Code, w.Body)
+	}
+	w = serveJSON(router, http.MethodGet, "/api/templates/export", nil)
+	var bundles []TemplateBundle
+	if err := json.Unmarshal(w.Body.Bytes(), &bundles); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if len(bundles) != 1 || bundles[0].Name != "good" {
+		t.Errorf("exported %+v, want the one imported template", bundles)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/db"
+)
+
+type CloneTemplateRequest struct {
+	Name string `json:"name"`
+}
+
+// CloneTemplate copies a template's schema and description into a new
+// template. Without a name in the body the copy is called "<name> (copy)",
+// with a numbered suffix if that is taken too; an explicit name that is
+// taken is a conflict. The copy stays in the source's namespace.
+func (h *TemplateHandler) CloneTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+		return
+	}
+
+	var req CloneTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	source, err := db.Templates.GetTemplateByID(ctx, id)
+	if err == nil && !namespaceVisible(c, source.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get template"})
+		return
+	}
+
+	name, onConflict := req.Name, importConflictError
+	if name == "" {
+		name, onConflict = source.Name+" (copy)", importConflictRename
+	}
+	name, err = resolveImportName(ctx, name, onConflict, nil)
+	if err == errImportNameConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "template with this name already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check template name"})
+		return
+	}
+
+	template := &db.LabelTemplate{
+		Name:        name,
+		Description: source.Description,
+		Namespace:   namespaceFilter(c, source.Namespace),
+		SchemaJSON:  source.SchemaJSON,
+		WidthMM:     source.WidthMM,
+		HeightMM:    source.HeightMM,
+	}
+	if err := db.Templates.CreateTemplate(ctx, template); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create template '%s'", name)})
+		return
+	}
+
+	created, err := db.Templates.GetTemplateByID(ctx, template.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch created template"})
+		return
+	}
+
+	response, err := h.templateToResponse(created)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process template"})
+		return
+	}
+
+	recordAudit(c, "clone", "template", created.ID, gin.H{"name": created.Name, "source_id": source.ID})
+
+	c.JSON(http.StatusCreated, response)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+)
+
+type LintTemplateResponse struct {
+	Findings []core.LintFinding `json:"findings"`
+}
+
+// LintTemplate reports layout problems in a template: overlapping elements,
+// elements that print nothing and negative coordinates. Elements are sized
+// with the same sample values a preview uses.
+func (h *TemplateHandler) LintTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+		return
+	}
+
+	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get template"})
+		return
+	}
+
+	schema, err := h.tsplGenerator.ParseSchema(template.SchemaJSON)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template schema"})
+		return
+	}
+
+	variables := h.tsplGenerator.ApplyServerVariables(schema, h.tsplGenerator.SampleVariables(schema))
+	findings := h.tsplGenerator.Lint(schema, variables)
+	if findings == nil {
+		findings = []core.LintFinding{}
+	}
+
+	c.JSON(http.StatusOK, LintTemplateResponse{Findings: findings})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/db"
+)
+
+// PatchTemplateRequest changes only the fields that are present, so an
+// empty description clears it while an absent one is left alone.
+type PatchTemplateRequest struct {
+	Name        *string          `json:"name"`
+	Description *string          `json:"description"`
+	Namespace   *string          `json:"namespace" binding:"omitempty,max=64"`
+	Schema      *LabelSchemaJSON `json:"schema"`
+}
+
+// PatchTemplate applies exactly the fields given in the request to a
+// template. Unlike UpdateTemplate, it can clear the description and
+// rename a template without resending its schema.
+func (h *TemplateHandler) PatchTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+		return
+	}
+
+	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get template"})
+		return
+	}
+
+	var req PatchTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+		if !renameTemplate(c, template, *req.Name) {
+			return
+		}
+	}
+
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+
+	if req.Namespace != nil && !moveTemplateNamespace(c, template, *req.Namespace) {
+		return
+	}
+
+	if req.Schema != nil && !applyTemplateSchema(c, template, req.Schema) {
+		return
+	}
+
+	if err := db.Templates.UpdateTemplate(c.Request.Context(), template); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update template"})
+		return
+	}
+
+	updated, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch updated template"})
+		return
+	}
+
+	response, err := h.templateToResponse(updated)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process template"})
+		return
+	}
+
+	recordAudit(c, "update", "template", id, req)
+
+	c.JSON(http.StatusOK, response)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,13 +11,18 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/orrn/spool/internal/api/middleware"
 	"github.com/orrn/spool/internal/core"
 	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/logging"
 )
 
+// CreateTemplateRequest may tag the template with a namespace. Callers
+// scoped to a namespace always create templates in their own.
 type CreateTemplateRequest struct {
 	Name        string          `json:"name" binding:"required"`
 	Description string          `json:"description"`
+	Namespace   string          `json:"namespace" binding:"max=64"`
 	Schema      LabelSchemaJSON `json:"schema" binding:"required"`
 }
 
@@ -26,19 +32,42 @@ type LabelSchemaJSON struct {
 	HeightMM  float64                  `json:"height_mm" binding:"required,gt=0"`
 	GapMM     float64                  `json:"gap_mm"`
 	DPI       int                      `json:"dpi"`
+	Codepage  string                   `json:"codepage,omitempty"`
+	Direction int                      `json:"direction,omitempty"`
+	Mirror    bool                     `json:"mirror,omitempty"`
 	Elements  []map[string]interface{} `json:"elements" binding:"required"`
 	Variables map[string]VariableDefJSON `json:"variables"`
+
+	MediaType string  `json:"media_type,omitempty"`
+	BlineMM   float64 `json:"bline_mm,omitempty"`
+	OffsetMM  float64 `json:"offset_mm,omitempty"`
+
+	PostCommands []string `json:"post_commands,omitempty"`
+
+	Speed   int  `json:"speed,omitempty"`
+	Density *int `json:"density,omitempty"`
+
+	FlipVertical   bool `json:"flip_vertical,omitempty"`
+	FlipHorizontal bool `json:"flip_horizontal,omitempty"`
 }
 
 type VariableDefJSON struct {
 	Type     string `json:"type"`
 	Required bool   `json:"required"`
 	Default  string `json:"default"`
+	Locked   bool   `json:"locked,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Sample   string `json:"sample,omitempty"`
+	Format   string `json:"format,omitempty"`
+	Pattern  string `json:"pattern,omitempty"`
 }
 
+// UpdateTemplateRequest moves the template to Namespace when it is set,
+// which only callers without a namespace of their own may do.
 type UpdateTemplateRequest struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
+	Namespace   *string         `json:"namespace" binding:"omitempty,max=64"`
 	Schema      LabelSchemaJSON `json:"schema"`
 }
 
@@ -46,6 +75,7 @@ type TemplateResponse struct {
 	ID          int64            `json:"id"`
 	Name        string           `json:"name"`
 	Description string           `json:"description"`
+	Namespace   string           `json:"namespace,omitempty"`
 	Schema      LabelSchemaJSON  `json:"schema"`
 	WidthMM     float64          `json:"width_mm"`
 	HeightMM    float64          `json:"height_mm"`
@@ -57,6 +87,7 @@ type TemplateListResponse struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Namespace   string    `json:"namespace,omitempty"`
 	WidthMM     float64   `json:"width_mm"`
 	HeightMM    float64   `json:"height_mm"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -67,6 +98,12 @@ type PreviewRequest struct {
 	Variables map[string]string `json:"variables"`
 }
 
+// PreviewQuery with Sample set fills variables the request leaves empty
+// with realistic sample values instead of leaving them blank.
+type PreviewQuery struct {
+	Sample bool `form:"sample"`
+}
+
 type PreviewResponse struct {
 	TSPLContent string            `json:"tspl_content"`
 	Variables   map[string]string `json:"variables_used"`
@@ -88,10 +125,17 @@ type QuickPrintResponse struct {
 	JobID int64 `json:"job_id"`
 }
 
+type RefreshPendingResponse struct {
+	Refreshed int              `json:"refreshed"`
+	Skipped   int              `json:"skipped"`
+	Errors    map[int64]string `json:"errors,omitempty"`
+}
+
 type TemplateHandler struct {
 	db            *sql.DB
 	tsplGenerator *core.TSPL2Generator
 	queue         *core.Queue
+	quota         *middleware.JobQuota
 }
 
 func NewTemplateHandler(database *sql.DB, generator *core.TSPL2Generator, queue *core.Queue) *TemplateHandler {
@@ -102,8 +146,22 @@ func NewTemplateHandler(database *sql.DB, generator *core.TSPL2Generator, queue
 	}
 }
 
+// SetQuota enables per-API-key submission limits on template prints.
+// Requests that are not authenticated with an API key are not counted.
+func (h *TemplateHandler) SetQuota(quota *middleware.JobQuota) {
+	h.quota = quota
+}
+
+// ListTemplates lists the templates in the caller's namespace, or for
+// callers without one every template unless ?namespace= narrows it down.
 func (h *TemplateHandler) ListTemplates(c *gin.Context) {
-	templates, err := db.Templates.ListTemplates(c.Request.Context())
+	var templates []*db.LabelTemplate
+	var err error
+	if namespace := namespaceFilter(c, c.Query("namespace")); namespace != "" {
+		templates, err = db.Templates.ListTemplatesByNamespace(c.Request.Context(), namespace)
+	} else {
+		templates, err = db.Templates.ListTemplates(c.Request.Context())
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list templates"})
 		return
@@ -115,6 +173,7 @@ func (h *TemplateHandler) ListTemplates(c *gin.Context) {
 			ID:          t.ID,
 			Name:        t.Name,
 			Description: t.Description,
+			Namespace:   t.Namespace,
 			WidthMM:     t.WidthMM,
 			HeightMM:    t.HeightMM,
 			CreatedAt:   t.CreatedAt,
@@ -136,6 +195,16 @@ func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
 		return
 	}
 
+	var query SchemaValidationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errs := ValidateSchemaStrict(&req.Schema, query.Strict); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template schema", "errors": errs})
+		return
+	}
+
 	_, err := db.Templates.GetTemplateByName(c.Request.Context(), req.Name)
 	if err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "template with this name already exists"})
@@ -158,6 +227,7 @@ func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
 		SchemaJSON:  string(schemaBytes),
 		WidthMM:     req.Schema.WidthMM,
 		HeightMM:    req.Schema.HeightMM,
+		Namespace:   namespaceFilter(c, req.Namespace),
 	}
 
 	if err := db.Templates.CreateTemplate(c.Request.Context(), template); err != nil {
@@ -177,6 +247,8 @@ func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "create", "template", created.ID, gin.H{"name": created.Name, "description": created.Description})
+
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -188,6 +260,9 @@ func (h *TemplateHandler) GetTemplate(c *gin.Context) {
 	}
 
 	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
 		return
@@ -214,6 +289,9 @@ func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
 	}
 
 	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
 		return
@@ -229,34 +307,20 @@ func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
 		return
 	}
 
-	if req.Name != "" && req.Name != template.Name {
-		existing, err := db.Templates.GetTemplateByName(c.Request.Context(), req.Name)
-		if err == nil && existing.ID != id {
-			c.JSON(http.StatusConflict, gin.H{"error": "template with this name already exists"})
-			return
-		}
-		if err != nil && err != sql.ErrNoRows {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check template name"})
-			return
-		}
-		template.Name = req.Name
+	if req.Name != "" && !renameTemplate(c, template, req.Name) {
+		return
 	}
 
 	if req.Description != "" {
 		template.Description = req.Description
 	}
 
-	var schema LabelSchemaJSON
-	if req.Schema.WidthMM > 0 {
-		schema = req.Schema
-		template.WidthMM = req.Schema.WidthMM
-		template.HeightMM = req.Schema.HeightMM
-		schemaBytes, err := json.Marshal(schema)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to encode schema"})
-			return
-		}
-		template.SchemaJSON = string(schemaBytes)
+	if req.Namespace != nil && !moveTemplateNamespace(c, template, *req.Namespace) {
+		return
+	}
+
+	if req.Schema.WidthMM > 0 && !applyTemplateSchema(c, template, &req.Schema) {
+		return
 	}
 
 	if err := db.Templates.UpdateTemplate(c.Request.Context(), template); err != nil {
@@ -276,9 +340,70 @@ func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "update", "template", id, req)
+
 	c.JSON(http.StatusOK, response)
 }
 
+// renameTemplate gives template a new name unless another template has it.
+// It writes the error response itself and returns false when the request
+// should stop.
+func renameTemplate(c *gin.Context, template *db.LabelTemplate, name string) bool {
+	if name == template.Name {
+		return true
+	}
+	existing, err := db.Templates.GetTemplateByName(c.Request.Context(), name)
+	if err == nil && existing.ID != template.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "template with this name already exists"})
+		return false
+	}
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check template name"})
+		return false
+	}
+	template.Name = name
+	return true
+}
+
+// moveTemplateNamespace moves template to namespace, which only callers
+// without a namespace of their own may do. It writes the error response
+// itself and returns false when the request should stop.
+func moveTemplateNamespace(c *gin.Context, template *db.LabelTemplate, namespace string) bool {
+	if namespace == template.Namespace {
+		return true
+	}
+	if middleware.CallerNamespace(c) != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot move a template to another namespace"})
+		return false
+	}
+	template.Namespace = namespace
+	return true
+}
+
+// applyTemplateSchema validates schema and stores it, with its label size,
+// on template. It writes the error response itself and returns false when
+// the request should stop.
+func applyTemplateSchema(c *gin.Context, template *db.LabelTemplate, schema *LabelSchemaJSON) bool {
+	var query SchemaValidationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if errs := ValidateSchemaStrict(schema, query.Strict); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template schema", "errors": errs})
+		return false
+	}
+	schemaBytes, err := json.Marshal(schema)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to encode schema"})
+		return false
+	}
+	template.SchemaJSON = string(schemaBytes)
+	template.WidthMM = schema.WidthMM
+	template.HeightMM = schema.HeightMM
+	return true
+}
+
 func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -286,7 +411,10 @@ func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
 		return
 	}
 
-	_, err = db.Templates.GetTemplateByID(c.Request.Context(), id)
+	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
 		return
@@ -296,6 +424,21 @@ func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
 		return
 	}
 
+	var query ForceDeleteQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var cancelled, failed int64
+	if query.Force {
+		cancelled, failed, err = clearBlockingJobs(c.Request.Context(), h.db, "template_id", id, "template deleted")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel pending jobs"})
+			return
+		}
+	}
+
 	var pendingCount int
 	err = h.db.QueryRowContext(c.Request.Context(),
 		"SELECT COUNT(*) FROM print_jobs WHERE template_id = ? AND status IN ('pending', 'processing')", id).Scan(&pendingCount)
@@ -313,7 +456,23 @@ func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "template deleted"})
+	if !query.Force {
+		recordAudit(c, "delete", "template", id, gin.H{"name": template.Name})
+		c.JSON(http.StatusOK, gin.H{"message": "template deleted"})
+		return
+	}
+
+	recordAudit(c, "delete", "template", id, gin.H{
+		"name":           template.Name,
+		"force":          true,
+		"cancelled_jobs": cancelled,
+		"failed_jobs":    failed,
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "template deleted",
+		"cancelled_jobs": cancelled,
+		"failed_jobs":    failed,
+	})
 }
 
 func (h *TemplateHandler) PreviewTemplate(c *gin.Context) {
@@ -324,6 +483,9 @@ func (h *TemplateHandler) PreviewTemplate(c *gin.Context) {
 	}
 
 	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
 		return
@@ -338,6 +500,12 @@ func (h *TemplateHandler) PreviewTemplate(c *gin.Context) {
 		req.Variables = make(map[string]string)
 	}
 
+	var query PreviewQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	schema, err := h.tsplGenerator.ParseSchema(template.SchemaJSON)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template schema"})
@@ -345,6 +513,14 @@ func (h *TemplateHandler) PreviewTemplate(c *gin.Context) {
 	}
 
 	variables := h.tsplGenerator.MergeVariablesWithDefaults(schema, req.Variables)
+	if query.Sample {
+		for name, value := range h.tsplGenerator.SampleVariables(schema) {
+			if variables[name] == "" {
+				variables[name] = value
+			}
+		}
+	}
+	variables = h.tsplGenerator.ApplyServerVariables(schema, variables)
 
 	tsplContent, err := h.tsplGenerator.Generate(schema, variables)
 	if err != nil {
@@ -358,6 +534,47 @@ func (h *TemplateHandler) PreviewTemplate(c *gin.Context) {
 	})
 }
 
+// PreviewTemplatePNG renders the template to a PNG at the schema DPI.
+// Variables are passed as query parameters, e.g. ?variables[sku]=123, and
+// merged with defaults like PreviewTemplate.
+func (h *TemplateHandler) PreviewTemplatePNG(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+		return
+	}
+
+	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get template"})
+		return
+	}
+
+	schema, err := h.tsplGenerator.ParseSchema(template.SchemaJSON)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template schema"})
+		return
+	}
+
+	variables := h.tsplGenerator.MergeVariablesWithDefaults(schema, c.QueryMap("variables"))
+	variables = h.tsplGenerator.ApplyServerVariables(schema, variables)
+
+	png, err := h.tsplGenerator.RenderPNG(schema, variables)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to render preview: %v", err)})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
+
 func (h *TemplateHandler) ValidateTemplate(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -365,7 +582,16 @@ func (h *TemplateHandler) ValidateTemplate(c *gin.Context) {
 		return
 	}
 
+	var query SchemaValidationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
 		return
@@ -384,14 +610,58 @@ func (h *TemplateHandler) ValidateTemplate(c *gin.Context) {
 		return
 	}
 
-	errors := validateSchema(&schema)
-	warnings := validateSchemaWarnings(&schema)
+	c.JSON(http.StatusOK, h.validateSchema(&schema, query.Strict))
+}
+
+// ValidateSchema checks a schema sent in the request body without saving
+// it, so an editor can validate a template before it exists.
+func (h *TemplateHandler) ValidateSchema(c *gin.Context) {
+	var query SchemaValidationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var schema LabelSchemaJSON
+	if err := json.NewDecoder(c.Request.Body).Decode(&schema); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schema JSON format"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.validateSchema(&schema, query.Strict))
+}
+
+// validateSchema runs the checks made when a schema is saved and, if they
+// pass, generates TSPL from it with sample values to catch errors that only
+// show up then, such as an unsupported codepage.
+func (h *TemplateHandler) validateSchema(schema *LabelSchemaJSON, strict bool) ValidateResponse {
+	errors := ValidateSchemaStrict(schema, strict)
+	if len(errors) == 0 {
+		if err := h.generateDryRun(schema); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
 
-	c.JSON(http.StatusOK, ValidateResponse{
+	return ValidateResponse{
 		Valid:    len(errors) == 0,
 		Errors:   errors,
-		Warnings: warnings,
-	})
+		Warnings: validateSchemaWarnings(schema),
+	}
+}
+
+func (h *TemplateHandler) generateDryRun(schema *LabelSchemaJSON) error {
+	schemaBytes, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("failed to encode schema: %w", err)
+	}
+	parsed, err := h.tsplGenerator.ParseSchema(string(schemaBytes))
+	if err != nil {
+		return err
+	}
+	if _, err := h.tsplGenerator.GeneratePreview(parsed); err != nil {
+		return fmt.Errorf("failed to generate TSPL: %w", err)
+	}
+	return nil
 }
 
 func (h *TemplateHandler) PrintTemplate(c *gin.Context) {
@@ -401,7 +671,14 @@ func (h *TemplateHandler) PrintTemplate(c *gin.Context) {
 		return
 	}
 
+	if rejectIfMaintenance(c) {
+		return
+	}
+
 	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
 		return
@@ -416,6 +693,9 @@ func (h *TemplateHandler) PrintTemplate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if rejectTooManyCopies(c, h.queue, req.Copies) {
+		return
+	}
 
 	_, err = db.Printers.GetPrinterByID(c.Request.Context(), req.PrinterID)
 	if err == sql.ErrNoRows {
@@ -433,6 +713,12 @@ func (h *TemplateHandler) PrintTemplate(c *gin.Context) {
 		return
 	}
 
+	if err := h.tsplGenerator.CheckLockedVariables(schema, req.Variables); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Variables = h.tsplGenerator.ApplyServerVariables(schema, req.Variables)
+
 	if err := h.tsplGenerator.ValidateVariables(schema, req.Variables); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -450,17 +736,29 @@ func (h *TemplateHandler) PrintTemplate(c *gin.Context) {
 		copies = 1
 	}
 
+	if rejectOverQuota(c, h.quota, 1) {
+		return
+	}
+
 	job := &core.Job{
 		PrinterID:     req.PrinterID,
 		TemplateID:    id,
 		VariablesJSON: string(variablesJSON),
 		TSPLContent:   tsplContent,
 		Copies:        copies,
+		Priority:      h.queue.DefaultPriority(core.JobSourceQuickPrint),
+		RequestID:     logging.RequestID(c.Request.Context()),
+		Namespace:     template.Namespace,
 		Status:        core.JobStatusPending,
 	}
 
 	jobID, err := h.queue.Enqueue(job)
 	if err != nil {
+		refundQuota(c, h.quota, 1)
+		if errors.Is(err, core.ErrTSPLTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enqueue job"})
 		return
 	}
@@ -468,6 +766,76 @@ func (h *TemplateHandler) PrintTemplate(c *gin.Context) {
 	c.JSON(http.StatusAccepted, QuickPrintResponse{JobID: jobID})
 }
 
+// RefreshPendingJobs regenerates the TSPL baked into waiting jobs for a
+// template so they print with its current schema. Jobs that are processing or
+// finished are never touched.
+func (h *TemplateHandler) RefreshPendingJobs(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+		return
+	}
+
+	template, err := db.Templates.GetTemplateByID(c.Request.Context(), id)
+	if err == nil && !namespaceVisible(c, template.Namespace) {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get template"})
+		return
+	}
+
+	schema, err := h.tsplGenerator.ParseSchema(template.SchemaJSON)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template schema"})
+		return
+	}
+
+	jobs, err := db.Jobs.GetPendingJobsByTemplate(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get pending jobs"})
+		return
+	}
+
+	resp := RefreshPendingResponse{Errors: make(map[int64]string)}
+	for _, job := range jobs {
+		variables := make(map[string]string)
+		if job.VariablesJSON != "" {
+			if err := json.Unmarshal([]byte(job.VariablesJSON), &variables); err != nil {
+				resp.Errors[job.ID] = "invalid stored variables"
+				continue
+			}
+		}
+
+		tsplContent, err := h.tsplGenerator.Generate(schema, variables)
+		if err != nil {
+			resp.Errors[job.ID] = err.Error()
+			continue
+		}
+		if err := h.queue.CheckTSPLSize(tsplContent); err != nil {
+			resp.Errors[job.ID] = err.Error()
+			continue
+		}
+
+		updated, err := db.Jobs.UpdatePendingJobTSPL(c.Request.Context(), job.ID, tsplContent)
+		if err != nil {
+			resp.Errors[job.ID] = "failed to update job"
+			continue
+		}
+		if !updated {
+			resp.Skipped++
+			continue
+		}
+		resp.Refreshed++
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (h *TemplateHandler) templateToResponse(t *db.LabelTemplate) (*TemplateResponse, error) {
 	var schema LabelSchemaJSON
 	if err := json.Unmarshal([]byte(t.SchemaJSON), &schema); err != nil {
@@ -478,6 +846,7 @@ func (h *TemplateHandler) templateToResponse(t *db.LabelTemplate) (*TemplateResp
 		ID:          t.ID,
 		Name:        t.Name,
 		Description: t.Description,
+		Namespace:   t.Namespace,
 		Schema:      schema,
 		WidthMM:     t.WidthMM,
 		HeightMM:    t.HeightMM,
@@ -486,43 +855,14 @@ func (h *TemplateHandler) templateToResponse(t *db.LabelTemplate) (*TemplateResp
 	}, nil
 }
 
-func validateSchema(schema *LabelSchemaJSON) []string {
-	var errors []string
-
-	if schema.WidthMM <= 0 {
-		errors = append(errors, "width_mm must be greater than 0")
-	}
-	if schema.HeightMM <= 0 {
-		errors = append(errors, "height_mm must be greater than 0")
-	}
-	if len(schema.Elements) == 0 {
-		errors = append(errors, "schema must have at least one element")
-	}
-
-	for i, elem := range schema.Elements {
-		elemErrors := validateElement(elem, i)
-		errors = append(errors, elemErrors...)
-	}
-
-	for varName, varDef := range schema.Variables {
-		if varDef.Type == "" {
-			errors = append(errors, fmt.Sprintf("variable '%s' missing type", varName))
-		}
-		if varDef.Required && varDef.Default != "" {
-			errors = append(errors, fmt.Sprintf("variable '%s' is required but has a default value", varName))
-		}
-	}
-
-	return errors
-}
-
 func validateSchemaWarnings(schema *LabelSchemaJSON) []string {
 	var warnings []string
 
 	if schema.DPI == 0 {
 		warnings = append(warnings, "DPI not specified, will default to 203")
 	}
-	if schema.GapMM == 0 {
+	isGap := schema.MediaType == "" || schema.MediaType == core.LabelMediaGap
+	if isGap && schema.GapMM == 0 {
 		warnings = append(warnings, "gap_mm not specified, may cause alignment issues")
 	}
 
@@ -535,169 +875,26 @@ func validateSchemaWarnings(schema *LabelSchemaJSON) []string {
 	return warnings
 }
 
-func validateElement(elem map[string]interface{}, index int) []string {
-	var errors []string
-	prefix := fmt.Sprintf("element[%d]", index)
-
-	elemType, ok := elem["type"].(string)
-	if !ok {
-		return []string{fmt.Sprintf("%s: missing or invalid 'type' field", prefix)}
-	}
-
-	switch elemType {
-	case "text":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: text element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: text element missing 'y'", prefix))
-		}
-		if _, ok := elem["content"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: text element missing 'content'", prefix))
-		}
-
-	case "barcode":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: barcode element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: barcode element missing 'y'", prefix))
-		}
-		if _, ok := elem["content"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: barcode element missing 'content'", prefix))
-		}
-
-	case "qrcode":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: qrcode element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: qrcode element missing 'y'", prefix))
-		}
-		if _, ok := elem["content"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: qrcode element missing 'content'", prefix))
-		}
-
-	case "pdf417":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: pdf417 element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: pdf417 element missing 'y'", prefix))
-		}
-		if _, ok := elem["content"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: pdf417 element missing 'content'", prefix))
-		}
-
-	case "datamatrix":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: datamatrix element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: datamatrix element missing 'y'", prefix))
-		}
-		if _, ok := elem["content"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: datamatrix element missing 'content'", prefix))
-		}
-
-	case "box":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: box element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: box element missing 'y'", prefix))
-		}
-		if _, ok := elem["x_end"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: box element missing 'x_end'", prefix))
-		}
-		if _, ok := elem["y_end"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: box element missing 'y_end'", prefix))
-		}
-
-	case "line":
-		if _, ok := elem["x1"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: line element missing 'x1'", prefix))
-		}
-		if _, ok := elem["y1"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: line element missing 'y1'", prefix))
-		}
-		if _, ok := elem["x2"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: line element missing 'x2'", prefix))
-		}
-		if _, ok := elem["y2"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: line element missing 'y2'", prefix))
-		}
-
-	case "circle":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: circle element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: circle element missing 'y'", prefix))
-		}
-		if _, ok := elem["radius"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: circle element missing 'radius'", prefix))
-		}
-
-	case "ellipse":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: ellipse element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: ellipse element missing 'y'", prefix))
-		}
-		if _, ok := elem["x_radius"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: ellipse element missing 'x_radius'", prefix))
-		}
-		if _, ok := elem["y_radius"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: ellipse element missing 'y_radius'", prefix))
-		}
-
-	case "block":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: block element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: block element missing 'y'", prefix))
-		}
-		if _, ok := elem["width"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: block element missing 'width'", prefix))
-		}
-		if _, ok := elem["height"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: block element missing 'height'", prefix))
-		}
-		if _, ok := elem["content"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: block element missing 'content'", prefix))
-		}
-
-	case "image":
-		if _, ok := elem["x"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: image element missing 'x'", prefix))
-		}
-		if _, ok := elem["y"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: image element missing 'y'", prefix))
-		}
-		if _, ok := elem["image_path"]; !ok {
-			errors = append(errors, fmt.Sprintf("%s: image element missing 'image_path'", prefix))
-		}
-
-	default:
-		errors = append(errors, fmt.Sprintf("%s: unknown element type '%s'", prefix, elemType))
-	}
-
-	return errors
-}
-
 func RegisterTemplateRoutes(router *gin.RouterGroup, handler *TemplateHandler) {
+	operator := middleware.RequireRole(middleware.RoleOperator)
 	templates := router.Group("/templates")
 	{
 		templates.GET("", handler.ListTemplates)
-		templates.POST("", handler.CreateTemplate)
+		templates.POST("", operator, handler.CreateTemplate)
+		templates.GET("/export", handler.ExportAllTemplates)
+		templates.POST("/import", operator, handler.ImportTemplates)
+		templates.POST("/validate", handler.ValidateSchema)
 		templates.GET("/:id", handler.GetTemplate)
-		templates.PUT("/:id", handler.UpdateTemplate)
-		templates.DELETE("/:id", handler.DeleteTemplate)
+		templates.PUT("/:id", operator, handler.UpdateTemplate)
+		templates.PATCH("/:id", operator, handler.PatchTemplate)
+		templates.DELETE("/:id", operator, handler.DeleteTemplate)
+		templates.GET("/:id/export", handler.ExportTemplate)
+		templates.POST("/:id/clone", operator, handler.CloneTemplate)
 		templates.POST("/:id/preview", handler.PreviewTemplate)
+		templates.GET("/:id/preview.png", handler.PreviewTemplatePNG)
 		templates.POST("/:id/validate", handler.ValidateTemplate)
-		templates.POST("/:id/print", handler.PrintTemplate)
+		templates.POST("/:id/lint", handler.LintTemplate)
+		templates.POST("/:id/print", operator, handler.PrintTemplate)
+		templates.POST("/:id/refresh-pending", operator, handler.RefreshPendingJobs)
 	}
 }
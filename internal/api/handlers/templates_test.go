@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/core"
+)
+
+// newTemplateRouter serves the template routes with a queue that is never
+// started.
+func newTemplateRouter(t *testing.T, database *sql.DB) *gin.Engine {
+	t.Helper()
+
+	generator := core.NewTSPL2Generator()
+	queue := core.NewQueue(database, nil, nil, nil, nil)
+	router := newTestRouter()
+	RegisterTemplateRoutes(router.Group("/api"), NewTemplateHandler(database, generator, queue))
+	return router
+}
+
+// createTemplate creates a template through the API and returns it.
+func createTemplate(t *testing.T, router *gin.Engine, body map[string]any) TemplateResponse {
+	t.Helper()
+
+	w := serveJSON(router, http.MethodPost, "/api/templates", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create template: %d %s", w.Code, w.Body)
+	}
+	var template TemplateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &template); err != nil {
+		t.Fatalf("decode template: %v", err)
+	}
+	return template
+}
+
+func TestTemplateKeepsCodepage(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+
+	template := createTemplate(t, router, map[string]any{
+		"name": "german",
+		"schema": map[string]any{
+			"width_mm": 50, "height_mm": 30, "codepage": "1252",
+			"elements": []map[string]any{{"type": "text", "x": 10, "y": 10, "content": "Größe"}},
+		},
+	})
+	if template.Schema.Codepage != "1252" {
+		t.Errorf("created template has codepage %q, want 1252", template.Schema.Codepage)
+	}
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/templates/%d/preview", template.ID), map[string]any{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("preview: %d %s", w.Code, w.Body)
+	}
+	var preview PreviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("decode preview: %v", err)
+	}
+	if !strings.Contains(preview.TSPLContent, "CLS\nCODEPAGE 1252\n") {
+		t.Errorf("preview TSPL is %q, want CODEPAGE 1252 after CLS", preview.TSPLContent)
+	}
+}
+
+func TestRefreshPendingJobsUsesCurrentSchema(t *testing.T) {
+	database := setupTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	templateID := insertTestTemplate(t, database, "label", testLabelSchema)
+	router := newTemplateRouter(t, database)
+
+	insertJob := func(status string) int64 {
+		t.Helper()
+		result, err := database.Exec(`INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, submitted_by)
+			VALUES (?, ?, '{"name":"WIDGET"}', 'OLD TSPL', ?, 'test')`, printerID, templateID, status)
+		if err != nil {
+			t.Fatalf("insert job: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return id
+	}
+	pending := insertJob("pending")
+	paused := insertJob("paused")
+	processing := insertJob("processing")
+	completed := insertJob("completed")
+
+	fixed := strings.Replace(testLabelSchema, `"x":10,"y":10`, `"x":20,"y":40`, 1)
+	if _, err := database.Exec("UPDATE label_templates SET schema_json = ? WHERE id = ?", fixed, templateID); err != nil {
+		t.Fatalf("update template: %v", err)
+	}
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/templates/%d/refresh-pending", templateID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh pending: %d %s", w.Code, w.Body)
+	}
+	var resp RefreshPendingResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Refreshed != 2 || len(resp.Errors) != 0 {
+		t.Errorf("refreshed %d jobs with errors %v, want 2 and none", resp.Refreshed, resp.Errors)
+	}
+
+	for id, want := range map[int64]string{
+		pending:    `TEXT 20,40,"3",0,1,1,"WIDGET"`,
+		paused:     `TEXT 20,40,"3",0,1,1,"WIDGET"`,
+		processing: "OLD TSPL",
+		completed:  "OLD TSPL",
+	} {
+		var tspl string
+		if err := database.QueryRow("SELECT tspl_content FROM print_jobs WHERE id = ?", id).Scan(&tspl); err != nil {
+			t.Fatalf("read job %d: %v", id, err)
+		}
+		if !strings.Contains(tspl, want) {
+			t.Errorf("job %d TSPL is %q, want it to contain %q", id, tspl, want)
+		}
+	}
+}
+
+func TestPreviewTemplatePNG(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+
+	// Two by one inches at 300 DPI.
+	template := createTemplate(t, router, map[string]any{
+		"name": "png",
+		"schema": map[string]any{
+			"width_mm": 50.8, "height_mm": 25.4, "dpi": 300,
+			"elements": []map[string]any{
+				{"type": "text", "x": 10, "y": 10, "content": "{{name}}"},
+				{"type": "box", "x": 0, "y": 0, "x_end": 599, "y_end": 299, "thickness": 2},
+			},
+			"variables": map[string]any{"name": map[string]any{"type": "string", "default": "DEFAULT"}},
+		},
+	})
+
+	render := func(query string) *image.Gray {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/templates/%d/preview.png%s", template.ID, query), nil)
+		w := serve(router, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("preview.png%s: %d %s", query, w.Code, w.Body)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+			t.Errorf("content type is %q, want image/png", ct)
+		}
+		img, err := png.Decode(w.Body)
+		if err != nil {
+			t.Fatalf("decode PNG: %v", err)
+		}
+		if got, want := img.Bounds(), image.Rect(0, 0, 600, 300); got != want {
+			t.Fatalf("preview is %v, want %v", got, want)
+		}
+		return img.(*image.Gray)
+	}
+
+	img := render("")
+	if img.GrayAt(0, 0).Y != 0 || img.GrayAt(300, 150).Y != 0xFF {
+		t.Error("preview does not show the box outline on a white label")
+	}
+	if bytes.Equal(img.Pix, render("?variables[name]=OTHER").Pix) {
+		t.Error("preview ignores the variables in the query")
+	}
+}
+
+func TestPreviewTemplatePNGUnknownTemplate(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+
+	w := serve(router, httptest.NewRequest(http.MethodGet, "/api/templates/9999/preview.png", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("preview unknown template: %d, want 404", w.Code)
+	}
+}
+
+func TestPreviewFormatsVariables(t *testing.T) {
+	database := setupTestDB(t)
+	templateID := insertTestTemplate(t, database, "formatted", `{"width_mm":50,"height_mm":30,"elements":[
+		{"type":"text","x":10,"y":10,"content":"{{sku|upper}} x{{qty|pad:3}}"}],
+		"variables":{"sku":{"type":"string"},"qty":{"type":"string"}}}`)
+	router := newTemplateRouter(t, database)
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/templates/%d/preview", templateID), map[string]any{
+		"variables": map[string]string{"sku": "ab-1", "qty": "7"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("preview: %d %s", w.Code, w.Body)
+	}
+	var preview PreviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("decode preview: %v", err)
+	}
+	if !strings.Contains(preview.TSPLContent, `"AB-1 x007"`) {
+		t.Errorf("preview TSPL is %q, want the formatted text", preview.TSPLContent)
+	}
+}
+
+func TestCloneTemplateTwice(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+	source := createTemplate(t, router, map[string]any{
+		"name":        "shipping",
+		"description": "outbound parcels",
+		"schema":      json.RawMessage(testLabelSchema),
+	})
+
+	clone := func() TemplateResponse {
+		t.Helper()
+		w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/templates/%d/clone", source.ID), nil)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("clone template: %d %s", w.Code, w.Body)
+		}
+		var template TemplateResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &template); err != nil {
+			t.Fatalf("decode template: %v", err)
+		}
+		return template
+	}
+	first, second := clone(), clone()
+
+	if first.Name != "shipping (copy)" || second.Name != "shipping (copy) (2)" {
+		t.Errorf("clones are named %q and %q, want \"shipping (copy)\" and \"shipping (copy) (2)\"", first.Name, second.Name)
+	}
+	for _, clone := range []TemplateResponse{first, second} {
+		if clone.ID == source.ID {
+			t.Errorf("clone %q reuses the source's ID %d", clone.Name, source.ID)
+		}
+		if clone.Description != source.Description || len(clone.Schema.Elements) != len(source.Schema.Elements) {
+			t.Errorf("clone %q does not carry the source's description and schema", clone.Name)
+		}
+	}
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/templates/%d/clone", source.ID), map[string]any{"name": "shipping"})
+	if w.Code != http.StatusConflict {
+		t.Errorf("clone under a taken name: %d %s, want 409", w.Code, w.Body)
+	}
+}
+
+func TestPreviewTemplateWithSampleData(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+	template := createTemplate(t, router, map[string]any{
+		"name": "retail",
+		"schema": map[string]any{
+			"width_mm": 50, "height_mm": 30,
+			"elements": []map[string]any{
+				{"type": "text", "x": 10, "y": 10, "content": "{{brand}}"},
+				{"type": "barcode", "x": 10, "y": 50, "barcode_type": "EAN13", "content": "{{ean}}"},
+			},
+			"variables": map[string]any{
+				"brand": map[string]any{"type": "string", "sample": "ACME"},
+				"ean":   map[string]any{"type": "barcode"},
+			},
+		},
+	})
+
+	preview := func(query string) PreviewResponse {
+		t.Helper()
+		w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/templates/%d/preview%s", template.ID, query), map[string]any{})
+		if w.Code != http.StatusOK {
+			t.Fatalf("preview%s: %d %s", query, w.Code, w.Body)
+		}
+		var resp PreviewResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode preview: %v", err)
+		}
+		return resp
+	}
+
+	plain := preview("")
+	if plain.Variables["brand"] != "" || plain.Variables["ean"] != "" {
+		t.Errorf("plain preview filled variables %v, want them left empty", plain.Variables)
+	}
+
+	sampled := preview("?sample=true")
+	if sampled.Variables["brand"] != "ACME" {
+		t.Errorf("sample preview used brand %q, want the variable's sample hint", sampled.Variables["brand"])
+	}
+	ean := sampled.Variables["ean"]
+	if len(ean) != 13 || int(ean[12]-'0') != core.EAN13CheckDigit(ean[:12]) {
+		t.Errorf("sample preview used EAN %q, want a valid EAN-13", ean)
+	}
+	if !strings.Contains(sampled.TSPLContent, ean) {
+		t.Errorf("sample EAN missing from the preview:\n%s", sampled.TSPLContent)
+	}
+}
+
+func TestLintTemplateReportsOverlap(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+	template := createTemplate(t, router, map[string]any{
+		"name": "stacked",
+		"schema": map[string]any{
+			"width_mm": 50, "height_mm": 30,
+			"elements": []map[string]any{
+				{"type": "box", "x": 10, "y": 10, "x_end": 100, "y_end": 100},
+				{"type": "box", "x": 50, "y": 50, "x_end": 150, "y_end": 150},
+			},
+		},
+	})
+
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/api/templates/%d/lint", template.ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("lint: %d %s", w.Code, w.Body)
+	}
+	var resp LintTemplateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode lint: %v", err)
+	}
+	if len(resp.Findings) != 1 || resp.Findings[0].Code != core.LintOverlap || fmt.Sprint(resp.Findings[0].Elements) != "[0 1]" {
+		t.Errorf("findings = %+v, want one overlap of elements 0 and 1", resp.Findings)
+	}
+}
+
+func TestPatchTemplate(t *testing.T) {
+	router := newTemplateRouter(t, setupTestDB(t))
+	schema := map[string]any{
+		"width_mm": 50, "height_mm": 30,
+		"elements": []map[string]any{{"type": "text", "x": 10, "y": 10, "content": "SKU"}},
+	}
+	createTemplate(t, router, map[string]any{"name": "taken", "schema": schema})
+
+	patch := func(t *testing.T, id int64, body map[string]any) TemplateResponse {
+		t.Helper()
+		w := serveJSON(router, http.MethodPatch, fmt.Sprintf("/api/templates/%d", id), body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("patch %v: %d %s", body, w.Code, w.Body)
+		}
+		var template TemplateResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &template); err != nil {
+			t.Fatalf("decode template: %v", err)
+		}
+		return template
+	}
+
+	t.Run("description only", func(t *testing.T) {
+		created := createTemplate(t, router, map[string]any{"name": "described", "description": "shelf label", "schema": schema})
+		got := patch(t, created.ID, map[string]any{"description": ""})
+		if got.Description != "" || got.Name != "described" || got.WidthMM != 50 {
+			t.Errorf("after clearing the description: %+v, want only the description cleared", got)
+		}
+	})
+
+	t.Run("name only", func(t *testing.T) {
+		created := createTemplate(t, router, map[string]any{"name": "old", "description": "kept", "schema": schema})
+		got := patch(t, created.ID, map[string]any{"name": "new"})
+		if got.Name != "new" || got.Description != "kept" || len(got.Schema.Elements) != 1 {
+			t.Errorf("after renaming: %+v, want only the name changed", got)
+		}
+		w := serveJSON(router, http.MethodPatch, fmt.Sprintf("/api/templates/%d", created.ID), map[string]any{"name": "taken"})
+		if w.Code != http.StatusConflict {
+			t.Errorf("rename to a taken name: %d, want 409", w.Code)
+		}
+	})
+
+	t.Run("schema only", func(t *testing.T) {
+		created := createTemplate(t, router, map[string]any{"name": "resized", "description": "kept", "schema": schema})
+		got := patch(t, created.ID, map[string]any{"schema": map[string]any{
+			"width_mm": 25, "height_mm": 15,
+			"elements": []map[string]any{{"type": "text", "x": 5, "y": 5, "content": "S"}},
+		}})
+		if got.WidthMM != 25 || got.HeightMM != 15 || got.Name != "resized" || got.Description != "kept" {
+			t.Errorf("after a schema patch: %+v, want a 25x15 label with name and description kept", got)
+		}
+	})
+}
+
+func TestValidateInlineSchema(t *testing.T) {
+	db := setupTestDB(t)
+	router := newTemplateRouter(t, db)
+
+	tests := []struct {
+		name      string
+		schema    map[string]any
+		wantValid bool
+		wantError string
+	}{
+		{
+			name: "valid",
+			schema: map[string]any{
+				"width_mm": 50, "height_mm": 30,
+				"elements":  []map[string]any{{"type": "text", "x": 10, "y": 10, "content": "{{sku}}"}},
+				"variables": map[string]any{"sku": map[string]any{"type": "string", "required": true}},
+			},
+			wantValid: true,
+		},
+		{
+			name: "mistyped field",
+			schema: map[string]any{
+				"width_mm": 50, "height_mm": 30,
+				"elements": []map[string]any{{"type": "text", "x": "10", "y": 10, "content": "A"}},
+			},
+			wantError: "field 'x' must be an integer",
+		},
+		{
+			name: "fails to generate",
+			schema: map[string]any{
+				"width_mm": 50, "height_mm": 30, "codepage": "EBCDIC",
+				"elements": []map[string]any{{"type": "text", "x": 10, "y": 10, "content": "A"}},
+			},
+			wantError: "unsupported codepage",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveJSON(router, http.MethodPost, "/api/templates/validate", tt.schema)
+			if w.Code != http.StatusOK {
+				t.Fatalf("validate: %d %s", w.Code, w.Body)
+			}
+			var resp ValidateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode validate: %v", err)
+			}
+			if resp.Valid != tt.wantValid || (tt.wantError != "" && !strings.Contains(strings.Join(resp.Errors, "; "), tt.wantError)) {
+				t.Errorf("response = %+v, want valid %v with an error containing %q", resp, tt.wantValid, tt.wantError)
+			}
+		})
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM label_templates").Scan(&count); err != nil {
+		t.Fatalf("count templates: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("validation saved %d templates, want none", count)
+	}
+}
+
+func TestDeleteTemplateWithPendingJobs(t *testing.T) {
+	database := setupTestDB(t)
+	router := newTemplateRouter(t, database)
+	templateID := insertTestTemplate(t, database, "retired", testLabelSchema)
+	printerID := insertTestPrinter(t, database, "desk")
+	pending := insertTestJob(t, database, printerID, "pending")
+	processing := insertTestJob(t, database, printerID, "processing")
+	if _, err := database.Exec("UPDATE print_jobs SET template_id = ?", templateID); err != nil {
+		t.Fatalf("set job template: %v", err)
+	}
+	path := fmt.Sprintf("/api/templates/%d", templateID)
+
+	w := serveJSON(router, http.MethodDelete, path, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("delete with pending jobs: %d %s, want 409", w.Code, w.Body)
+	}
+
+	w = serveJSON(router, http.MethodDelete, path+"?force=true", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"cancelled_jobs":1`) || !strings.Contains(w.Body.String(), `"failed_jobs":1`) {
+		t.Fatalf("forced delete: %d %s, want 200 with one job cancelled and one failed", w.Code, w.Body)
+	}
+	for id, want := range map[int64]string{pending: "cancelled", processing: "failed"} {
+		var status string
+		if err := database.QueryRow("SELECT status FROM print_jobs WHERE id = ?", id).Scan(&status); err != nil {
+			t.Fatalf("job %d: %v", id, err)
+		}
+		if status != want {
+			t.Errorf("job %d is %s after forced delete, want %s", id, status, want)
+		}
+	}
+	if w := serveJSON(router, http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("get deleted template: %d, want 404", w.Code)
+	}
+}
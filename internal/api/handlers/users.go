@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,max=64"`
+	Password string `json:"password" binding:"required,min=6"`
+	Role     string `json:"role" binding:"required,oneof=admin operator viewer"`
+}
+
+// UpdateUserRequest changes a user's role, password, or both.
+type UpdateUserRequest struct {
+	Password *string `json:"password" binding:"omitempty,min=6"`
+	Role     *string `json:"role" binding:"omitempty,oneof=admin operator viewer"`
+}
+
+type UserResponse struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserHandler manages named logins. The shared admin password keeps working
+// alongside them, so an install can move to named users gradually.
+type UserHandler struct {
+	db *sql.DB
+}
+
+func NewUserHandler(database *sql.DB) *UserHandler {
+	return &UserHandler{db: database}
+}
+
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	users, err := db.Users.ListUsers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve users",
+		})
+		return
+	}
+
+	responses := make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		responses = append(responses, userToResponse(u))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	_, err := db.Users.GetUserByUsername(c.Request.Context(), req.Username)
+	if err == nil {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "duplicate_username",
+			Message: "User with this username already exists",
+		})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to check username",
+		})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "hash_error",
+			Message: "Failed to hash password",
+		})
+		return
+	}
+
+	user := &db.User{Username: req.Username, PasswordHash: string(hash), Role: req.Role}
+	if err := db.Users.CreateUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to create user",
+		})
+		return
+	}
+
+	created, err := db.Users.GetUserByID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve created user",
+		})
+		return
+	}
+
+	recordAudit(c, "create", "user", created.ID, gin.H{"username": created.Username, "role": created.Role})
+
+	c.JSON(http.StatusCreated, userToResponse(created))
+}
+
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	details := gin.H{"username": user.Username}
+	if req.Role != nil && *req.Role != user.Role {
+		if user.Role == middleware.RoleAdmin && !h.canRemoveAdmin(c) {
+			return
+		}
+		details["old_role"] = user.Role
+		details["role"] = *req.Role
+		user.Role = *req.Role
+	}
+	if req.Password != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "hash_error",
+				Message: "Failed to hash password",
+			})
+			return
+		}
+		user.PasswordHash = string(hash)
+		details["password_changed"] = true
+	}
+
+	if err := db.Users.UpdateUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to update user",
+		})
+		return
+	}
+
+	updated, err := db.Users.GetUserByID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve updated user",
+		})
+		return
+	}
+
+	recordAudit(c, "update", "user", updated.ID, details)
+
+	c.JSON(http.StatusOK, userToResponse(updated))
+}
+
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Role == middleware.RoleAdmin && !h.canRemoveAdmin(c) {
+		return
+	}
+
+	if err := db.Users.DeleteUser(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to delete user",
+		})
+		return
+	}
+
+	recordAudit(c, "delete", "user", user.ID, gin.H{"username": user.Username, "role": user.Role})
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) loadUser(c *gin.Context) (*db.User, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid user ID",
+		})
+		return nil, false
+	}
+
+	user, err := db.Users.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "User not found",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve user",
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+// canRemoveAdmin reports whether an admin user may be deleted or demoted.
+// The last admin user may only go when the shared admin password is set,
+// so there is always some way to log in as admin.
+func (h *UserHandler) canRemoveAdmin(c *gin.Context) bool {
+	admins, err := db.Users.CountUsersByRole(c.Request.Context(), middleware.RoleAdmin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to count admin users",
+		})
+		return false
+	}
+	if admins > 1 {
+		return true
+	}
+
+	_, err = db.Settings.GetSetting(c.Request.Context(), settingsKeyPassword)
+	if err == nil {
+		return true
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to check admin password",
+		})
+		return false
+	}
+	c.JSON(http.StatusConflict, ErrorResponse{
+		Error:   "last_admin",
+		Message: "Cannot remove the last admin user while no admin password is set",
+	})
+	return false
+}
+
+func userToResponse(u *db.User) UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		Username:  u.Username,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+func RegisterUserRoutes(r *gin.RouterGroup, h *UserHandler) {
+	users := r.Group("/users", middleware.RequireAdmin())
+	users.GET("", h.ListUsers)
+	users.POST("", h.CreateUser)
+	users.PUT("/:id", h.UpdateUser)
+	users.DELETE("/:id", h.DeleteUser)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/db"
+)
+
+// newUserRouter serves the login, user and printer routes behind the auth
+// middleware, and returns a session cookie for the admin created by
+// first-run setup.
+func newUserRouter(t *testing.T) (*gin.Engine, *http.Cookie) {
+	t.Helper()
+
+	database := db.GetDB()
+	auth, err := middleware.NewAuthMiddleware(database)
+	if err != nil {
+		t.Fatalf("create auth middleware: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Settings.DeleteSetting(context.Background(), "admin_password")
+	})
+
+	router := gin.New()
+	router.POST("/api/auth/setup", auth.SetupHandler)
+	router.POST("/api/auth/login", auth.LoginHandler)
+	protected := router.Group("/api", auth.RequireAuth())
+	RegisterUserRoutes(protected, NewUserHandler(database))
+	RegisterPrinterRoutes(protected, NewPrinterHandler(database, startPrinterManager(t, database)))
+
+	w := serveJSON(router, http.MethodPost, "/api/auth/setup", map[string]any{"password": "secret-password"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("setup admin: %d %s", w.Code, w.Body)
+	}
+	return router, sessionCookie(t, w.Result().Cookies())
+}
+
+func sessionCookie(t *testing.T, cookies []*http.Cookie) *http.Cookie {
+	t.Helper()
+	for _, cookie := range cookies {
+		if cookie.Value != "" {
+			return cookie
+		}
+	}
+	t.Fatal("response did not set a session cookie")
+	return nil
+}
+
+// loginAs creates a user with role and logs in as them.
+func loginAs(t *testing.T, router *gin.Engine, admin *http.Cookie, username, role string) *http.Cookie {
+	t.Helper()
+
+	req := newJSONRequest(http.MethodPost, "/api/users", map[string]any{
+		"username": username, "password": "user-password", "role": role,
+	})
+	req.AddCookie(admin)
+	if w := serve(router, req); w.Code != http.StatusCreated {
+		t.Fatalf("create %s user: %d %s", role, w.Code, w.Body)
+	}
+
+	w := serveJSON(router, http.MethodPost, "/api/auth/login", map[string]any{"username": username, "password": "user-password"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("log in as %s: %d %s", username, w.Code, w.Body)
+	}
+	return sessionCookie(t, w.Result().Cookies())
+}
+
+func TestRolesGateCreatingPrinters(t *testing.T) {
+	setupTestDB(t)
+	router, setupAdmin := newUserRouter(t)
+
+	createPrinter := func(session *http.Cookie, name string) int {
+		req := newJSONRequest(http.MethodPost, "/api/printers", map[string]any{
+			"name": name, "ip_address": "192.168.1.50", "label_width_mm": 50, "label_height_mm": 30,
+		})
+		req.AddCookie(session)
+		return serve(router, req).Code
+	}
+
+	viewer := loginAs(t, router, setupAdmin, "vera", middleware.RoleViewer)
+	if code := createPrinter(viewer, "viewer-printer"); code != http.StatusForbidden {
+		t.Errorf("create printer as viewer: %d, want 403", code)
+	}
+
+	req := newJSONRequest(http.MethodPost, "/api/printers/test-connection", map[string]any{"ip_address": "10.255.255.1"})
+	req.AddCookie(viewer)
+	if w := serve(router, req); w.Code != http.StatusForbidden {
+		t.Errorf("test connection as viewer: %d %s, want 403", w.Code, w.Body)
+	}
+
+	admin := loginAs(t, router, setupAdmin, "ada", middleware.RoleAdmin)
+	if code := createPrinter(admin, "admin-printer"); code != http.StatusCreated {
+		t.Errorf("create printer as admin: %d, want 201", code)
+	}
+
+	// Viewers can still read, but cannot manage users.
+	req = newJSONRequest(http.MethodGet, "/api/printers", nil)
+	req.AddCookie(viewer)
+	if w := serve(router, req); w.Code != http.StatusOK {
+		t.Errorf("list printers as viewer: %d %s, want 200", w.Code, w.Body)
+	}
+	req = newJSONRequest(http.MethodGet, "/api/users", nil)
+	req.AddCookie(viewer)
+	if w := serve(router, req); w.Code != http.StatusForbidden {
+		t.Errorf("list users as viewer: %d %s, want 403", w.Code, w.Body)
+	}
+}
+
+func TestLoginRejectsWrongUserPassword(t *testing.T) {
+	setupTestDB(t)
+	router, admin := newUserRouter(t)
+	loginAs(t, router, admin, "olga", middleware.RoleOperator)
+
+	w := serveJSON(router, http.MethodPost, "/api/auth/login", map[string]any{"username": "olga", "password": "secret-password"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("log in with the admin password as a named user: %d %s, want 401", w.Code, w.Body)
+	}
+
+	// The shared admin password still logs in without a username.
+	w = serveJSON(router, http.MethodPost, "/api/auth/login", map[string]any{"password": "secret-password"})
+	if w.Code != http.StatusOK {
+		t.Errorf("log in with the admin password: %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestSessionsActWithUsersCurrentRole(t *testing.T) {
+	setupTestDB(t)
+	router, admin := newUserRouter(t)
+	operator := loginAs(t, router, admin, "otto", middleware.RoleOperator)
+	user, err := db.Users.GetUserByUsername(context.Background(), "otto")
+	if err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	userPath := "/api/users/" + strconv.FormatInt(user.ID, 10)
+
+	createPrinter := func(name string) *httptest.ResponseRecorder {
+		req := newJSONRequest(http.MethodPost, "/api/printers", map[string]any{
+			"name": name, "ip_address": "192.168.1.50", "label_width_mm": 50, "label_height_mm": 30,
+		})
+		req.AddCookie(operator)
+		return serve(router, req)
+	}
+	if w := createPrinter("before-demotion"); w.Code != http.StatusCreated {
+		t.Fatalf("create printer as operator: %d %s", w.Code, w.Body)
+	}
+
+	req := newJSONRequest(http.MethodPut, userPath, map[string]any{"role": middleware.RoleViewer})
+	req.AddCookie(admin)
+	if w := serve(router, req); w.Code != http.StatusOK {
+		t.Fatalf("demote user: %d %s", w.Code, w.Body)
+	}
+	if w := createPrinter("after-demotion"); w.Code != http.StatusForbidden {
+		t.Errorf("create printer with a demoted user's session: %d %s, want 403", w.Code, w.Body)
+	}
+
+	req = newJSONRequest(http.MethodDelete, userPath, nil)
+	req.AddCookie(admin)
+	if w := serve(router, req); w.Code != http.StatusNoContent {
+		t.Fatalf("delete user: %d %s", w.Code, w.Body)
+	}
+	req = newJSONRequest(http.MethodGet, "/api/printers", nil)
+	req.AddCookie(operator)
+	if w := serve(router, req); w.Code != http.StatusUnauthorized {
+		t.Errorf("list printers with a deleted user's session: %d %s, want 401", w.Code, w.Body)
+	}
+}
@@ -2,10 +2,7 @@ package handlers
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -13,8 +10,9 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"orrn-spool/internal/db"
-	"orrn-spool/internal/webhook"
+	"github.com/orrn/spool/internal/api/middleware"
+	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/webhook"
 )
 
 type WebhookHandler struct {
@@ -24,27 +22,55 @@ type WebhookHandler struct {
 }
 
 type CreateWebhookRequest struct {
-	Name   string   `json:"name" binding:"required"`
-	URL    string   `json:"url" binding:"required,url"`
-	Secret string   `json:"secret"`
-	Events []string `json:"events" binding:"required"`
+	Name        string   `json:"name" binding:"required"`
+	URL         string   `json:"url" binding:"required,url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events" binding:"required"`
+	PrinterIDs  []int64  `json:"printer_ids"`
+	TemplateIDs []int64  `json:"template_ids"`
+
+	Headers webhook.WebhookHeaders `json:"headers"`
+
+	// Transitions limits printer_status_changed events to the listed
+	// changes, written "from->to" with either side optional.
+	Transitions []string `json:"transitions"`
 }
 
+// UpdateWebhookRequest replaces a filter list or the headers when they are
+// present; an empty list or object removes them.
 type UpdateWebhookRequest struct {
-	Name    string   `json:"name"`
-	URL     string   `json:"url" binding:"omitempty,url"`
-	Secret  string   `json:"secret"`
-	Events  []string `json:"events"`
-	Enabled *bool    `json:"enabled"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url" binding:"omitempty,url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events"`
+	Enabled     *bool    `json:"enabled"`
+	PrinterIDs  *[]int64 `json:"printer_ids"`
+	TemplateIDs *[]int64 `json:"template_ids"`
+
+	Headers *webhook.WebhookHeaders `json:"headers"`
+
+	Transitions *[]string `json:"transitions"`
 }
 
 type WebhookResponse struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	URL       string    `json:"url"`
-	Events    []string  `json:"events"`
-	Enabled   bool      `json:"enabled"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	PrinterIDs  []int64   `json:"printer_ids"`
+	TemplateIDs []int64   `json:"template_ids"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// HeaderNames lists the configured headers; their values may hold
+	// credentials and are not returned.
+	HeaderNames []string `json:"header_names"`
+
+	Transitions []string `json:"transitions"`
+}
+
+type WebhookDeliveriesQuery struct {
+	Limit int `form:"limit"`
 }
 
 type TestWebhookResponse struct {
@@ -117,12 +143,47 @@ func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
 		return
 	}
 
+	filters := webhook.WebhookFilters{PrinterIDs: req.PrinterIDs, TemplateIDs: req.TemplateIDs, Transitions: req.Transitions}
+	if msg := validateWebhookFilters(filters); msg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+		return
+	}
+	filtersJSON, err := filters.Encode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "json_error",
+			Message: "Failed to serialize filters",
+		})
+		return
+	}
+
+	if err := req.Headers.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+	headersJSON, err := req.Headers.Encode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "json_error",
+			Message: "Failed to serialize headers",
+		})
+		return
+	}
+
 	w := &db.Webhook{
-		Name:       req.Name,
-		URL:        req.URL,
-		Secret:     req.Secret,
-		EventsJSON: string(eventsJSON),
-		Enabled:    true,
+		Name:        req.Name,
+		URL:         req.URL,
+		Secret:      req.Secret,
+		EventsJSON:  string(eventsJSON),
+		FiltersJSON: filtersJSON,
+		HeadersJSON: headersJSON,
+		Enabled:     true,
 	}
 
 	if err := db.Webhooks.CreateWebhook(c.Request.Context(), w); err != nil {
@@ -133,6 +194,15 @@ func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "create", "webhook", w.ID, gin.H{
+		"name":         w.Name,
+		"url":          w.URL,
+		"events":       req.Events,
+		"printer_ids":  req.PrinterIDs,
+		"template_ids": req.TemplateIDs,
+		"headers":      req.Headers.Names(),
+	})
+
 	c.JSON(http.StatusCreated, h.webhookToResponse(w))
 }
 
@@ -232,6 +302,59 @@ func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
 	if req.Enabled != nil {
 		w.Enabled = *req.Enabled
 	}
+	if req.PrinterIDs != nil || req.TemplateIDs != nil || req.Transitions != nil {
+		filters, err := webhook.ParseFilters(w.FiltersJSON)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "json_error",
+				Message: "Failed to read existing filters",
+			})
+			return
+		}
+		if req.PrinterIDs != nil {
+			filters.PrinterIDs = *req.PrinterIDs
+		}
+		if req.TemplateIDs != nil {
+			filters.TemplateIDs = *req.TemplateIDs
+		}
+		if req.Transitions != nil {
+			filters.Transitions = *req.Transitions
+		}
+		if msg := validateWebhookFilters(filters); msg != "" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "validation_error",
+				Message: msg,
+			})
+			return
+		}
+		filtersJSON, err := filters.Encode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "json_error",
+				Message: "Failed to serialize filters",
+			})
+			return
+		}
+		w.FiltersJSON = filtersJSON
+	}
+	if req.Headers != nil {
+		if err := req.Headers.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+			return
+		}
+		headersJSON, err := req.Headers.Encode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "json_error",
+				Message: "Failed to serialize headers",
+			})
+			return
+		}
+		w.HeadersJSON = headersJSON
+	}
 
 	if err := db.Webhooks.UpdateWebhook(c.Request.Context(), w); err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -241,6 +364,17 @@ func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "update", "webhook", w.ID, gin.H{
+		"name":            req.Name,
+		"url":             req.URL,
+		"events":          req.Events,
+		"enabled":         req.Enabled,
+		"printer_ids":     req.PrinterIDs,
+		"template_ids":    req.TemplateIDs,
+		"secret_changed":  req.Secret != "",
+		"headers_changed": req.Headers != nil,
+	})
+
 	c.JSON(http.StatusOK, h.webhookToResponse(w))
 }
 
@@ -254,7 +388,7 @@ func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
 		return
 	}
 
-	_, err = db.Webhooks.GetWebhookByID(c.Request.Context(), id)
+	w, err := db.Webhooks.GetWebhookByID(c.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, ErrorResponse{
@@ -278,6 +412,8 @@ func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "delete", "webhook", id, gin.H{"name": w.Name, "url": w.URL})
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -332,14 +468,20 @@ func (h *WebhookHandler) TestWebhook(c *gin.Context) {
 		return
 	}
 
+	headers, err := webhook.ParseHeaders(w.HeadersJSON)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, TestWebhookResponse{
+			Success: false,
+			Message: "Failed to read webhook headers",
+		})
+		return
+	}
+	headers.Apply(req)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Webhook-Event", "test")
 	req.Header.Set("X-Webhook-Test", "true")
 
-	if w.Secret != "" {
-		signature := computeSignature(payloadBytes, w.Secret)
-		req.Header.Set("X-Webhook-Signature", signature)
-	}
+	webhook.SignRequest(req, w.Secret, time.Now(), payloadBytes)
 
 	resp, err := h.httpClient.Do(req)
 	if err != nil {
@@ -365,6 +507,63 @@ func (h *WebhookHandler) TestWebhook(c *gin.Context) {
 	})
 }
 
+// ListWebhookDeliveries returns a webhook's recent delivery attempts,
+// newest first, so failed deliveries can be diagnosed.
+func (h *WebhookHandler) ListWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid webhook ID",
+		})
+		return
+	}
+
+	var query WebhookDeliveriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	if query.Limit > 200 {
+		query.Limit = 200
+	}
+
+	if _, err := db.Webhooks.GetWebhookByID(c.Request.Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Webhook not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve webhook",
+		})
+		return
+	}
+
+	deliveries, err := db.Webhooks.ListWebhookDeliveries(c.Request.Context(), id, query.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve webhook deliveries",
+		})
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*db.WebhookDelivery{}
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
 func (h *WebhookHandler) webhookToResponse(w *db.Webhook) WebhookResponse {
 	var events []string
 	if w.EventsJSON != "" {
@@ -374,14 +573,50 @@ func (h *WebhookHandler) webhookToResponse(w *db.Webhook) WebhookResponse {
 		events = []string{}
 	}
 
+	filters, _ := webhook.ParseFilters(w.FiltersJSON)
+	if filters.PrinterIDs == nil {
+		filters.PrinterIDs = []int64{}
+	}
+	if filters.TemplateIDs == nil {
+		filters.TemplateIDs = []int64{}
+	}
+	if filters.Transitions == nil {
+		filters.Transitions = []string{}
+	}
+
+	headers, _ := webhook.ParseHeaders(w.HeadersJSON)
+
 	return WebhookResponse{
-		ID:        w.ID,
-		Name:      w.Name,
-		URL:       w.URL,
-		Events:    events,
-		Enabled:   w.Enabled,
-		CreatedAt: w.CreatedAt,
+		ID:          w.ID,
+		Name:        w.Name,
+		URL:         w.URL,
+		Events:      events,
+		PrinterIDs:  filters.PrinterIDs,
+		TemplateIDs: filters.TemplateIDs,
+		HeaderNames: headers.Names(),
+		Transitions: filters.Transitions,
+		Enabled:     w.Enabled,
+		CreatedAt:   w.CreatedAt,
+	}
+}
+
+func validateWebhookFilters(f webhook.WebhookFilters) string {
+	for _, id := range f.PrinterIDs {
+		if id <= 0 {
+			return fmt.Sprintf("Invalid printer ID in filter: %d", id)
+		}
+	}
+	for _, id := range f.TemplateIDs {
+		if id <= 0 {
+			return fmt.Sprintf("Invalid template ID in filter: %d", id)
+		}
 	}
+	for _, t := range f.Transitions {
+		if err := webhook.ValidateTransition(t); err != nil {
+			return "Invalid transition in filter: " + err.Error()
+		}
+	}
+	return ""
 }
 
 func isValidEvent(event string) bool {
@@ -389,23 +624,22 @@ func isValidEvent(event string) bool {
 		string(webhook.EventJobStarted):           true,
 		string(webhook.EventJobCompleted):         true,
 		string(webhook.EventJobFailed):            true,
+		string(webhook.EventJobFailover):          true,
+		string(webhook.EventJobVerified):          true,
+		string(webhook.EventJobScanMismatch):      true,
 		string(webhook.EventPrinterStatusChanged): true,
 		string(webhook.EventQueueStatus):          true,
 	}
 	return validEvents[event]
 }
 
-func computeSignature(payload []byte, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(payload)
-	return hex.EncodeToString(h.Sum(nil))
-}
-
 func RegisterWebhookRoutes(r *gin.RouterGroup, h *WebhookHandler) {
+	operator := middleware.RequireRole(middleware.RoleOperator)
 	r.GET("/webhooks", h.ListWebhooks)
-	r.POST("/webhooks", h.CreateWebhook)
+	r.POST("/webhooks", operator, h.CreateWebhook)
 	r.GET("/webhooks/:id", h.GetWebhook)
-	r.PUT("/webhooks/:id", h.UpdateWebhook)
-	r.DELETE("/webhooks/:id", h.DeleteWebhook)
-	r.POST("/webhooks/:id/test", h.TestWebhook)
+	r.PUT("/webhooks/:id", operator, h.UpdateWebhook)
+	r.DELETE("/webhooks/:id", operator, h.DeleteWebhook)
+	r.POST("/webhooks/:id/test", operator, h.TestWebhook)
+	r.GET("/webhooks/:id/deliveries", h.ListWebhookDeliveries)
 }
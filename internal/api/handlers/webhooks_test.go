@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/webhook"
+)
+
+// newWebhookRouter serves the webhook routes without a sender.
+func newWebhookRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	router := newTestRouter()
+	RegisterWebhookRoutes(router.Group("/api"), NewWebhookHandler(db.GetDB(), nil))
+	return router
+}
+
+// decodeWebhook decodes a webhook response, failing the test unless the
+// request returned want.
+func decodeWebhook(t *testing.T, code, want int, body []byte) WebhookResponse {
+	t.Helper()
+
+	if code != want {
+		t.Fatalf("got %d %s, want %d", code, body, want)
+	}
+	var resp WebhookResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode webhook: %v", err)
+	}
+	return resp
+}
+
+func TestWebhookFilters(t *testing.T) {
+	setupTestDB(t)
+	router := newWebhookRouter(t)
+
+	w := serveJSON(router, http.MethodPost, "/api/webhooks", map[string]any{
+		"name": "zone-a", "url": "https://example.com/hook", "events": []string{"job_completed"},
+		"printer_ids": []int64{1, 2}, "template_ids": []int64{7},
+	})
+	created := decodeWebhook(t, w.Code, http.StatusCreated, w.Body.Bytes())
+	if len(created.PrinterIDs) != 2 || len(created.TemplateIDs) != 1 {
+		t.Errorf("created webhook has filters %v and %v, want printers 1, 2 and template 7", created.PrinterIDs, created.TemplateIDs)
+	}
+
+	// Clearing the printer filter leaves the template filter alone.
+	path := fmt.Sprintf("/api/webhooks/%d", created.ID)
+	w = serveJSON(router, http.MethodPut, path, map[string]any{"printer_ids": []int64{}})
+	decodeWebhook(t, w.Code, http.StatusOK, w.Body.Bytes())
+
+	w = serveJSON(router, http.MethodGet, path, nil)
+	got := decodeWebhook(t, w.Code, http.StatusOK, w.Body.Bytes())
+	if len(got.PrinterIDs) != 0 || len(got.TemplateIDs) != 1 || got.TemplateIDs[0] != 7 {
+		t.Errorf("updated webhook has filters %v and %v, want no printer filter and template 7", got.PrinterIDs, got.TemplateIDs)
+	}
+}
+
+func TestCreateWebhookRejectsInvalidFilter(t *testing.T) {
+	setupTestDB(t)
+	router := newWebhookRouter(t)
+
+	w := serveJSON(router, http.MethodPost, "/api/webhooks", map[string]any{
+		"name": "bad", "url": "https://example.com/hook", "events": []string{"job_completed"},
+		"printer_ids": []int64{0},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("create with printer ID 0: %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	database := setupTestDB(t)
+	router := newWebhookRouter(t)
+
+	w := serveJSON(router, http.MethodPost, "/api/webhooks", map[string]any{
+		"name": "flaky", "url": "https://example.com/hook", "events": []string{"job_failed"},
+	})
+	created := decodeWebhook(t, w.Code, http.StatusCreated, w.Body.Bytes())
+	for attempt, status := range []int{500, 502, 200} {
+		errMsg := ""
+		if status >= 400 {
+			errMsg = fmt.Sprintf("http error: %d", status)
+		}
+		if _, err := database.Exec(db.InsertWebhookDelivery, created.ID, "job_failed", attempt+1, status, errMsg); err != nil {
+			t.Fatalf("insert delivery: %v", err)
+		}
+	}
+
+	w = serveJSON(router, http.MethodGet, fmt.Sprintf("/api/webhooks/%d/deliveries?limit=2", created.ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list deliveries: %d %s", w.Code, w.Body)
+	}
+	var deliveries []db.WebhookDelivery
+	if err := json.Unmarshal(w.Body.Bytes(), &deliveries); err != nil {
+		t.Fatalf("decode deliveries: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].Attempt != 3 || deliveries[0].StatusCode != 200 ||
+		deliveries[1].Attempt != 2 || deliveries[1].Error != "http error: 502" {
+		t.Errorf("deliveries = %+v, want attempts 3 and 2, newest first", deliveries)
+	}
+
+	w = serveJSON(router, http.MethodGet, "/api/webhooks/999/deliveries", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("deliveries of unknown webhook: %d %s, want 404", w.Code, w.Body)
+	}
+}
+
+func TestTestWebhookSendsConfiguredHeaders(t *testing.T) {
+	setupTestDB(t)
+	router := newWebhookRouter(t)
+	got := make(chan http.Header, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got <- req.Header.Clone()
+	}))
+	defer receiver.Close()
+
+	w := serveJSON(router, http.MethodPost, "/api/webhooks", map[string]any{
+		"name": "authed", "url": receiver.URL, "events": []string{"job_completed"},
+		"headers": map[string]string{"Authorization": "Bearer s3cret", "X-Webhook-Event": "spoofed"},
+	})
+	created := decodeWebhook(t, w.Code, http.StatusCreated, w.Body.Bytes())
+	if fmt.Sprint(created.HeaderNames) != "[Authorization X-Webhook-Event]" {
+		t.Errorf("header_names = %v, want the configured names", created.HeaderNames)
+	}
+	if strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("response %s exposes a header value", w.Body)
+	}
+
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/webhooks/%d/test", created.ID), nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"success":true`) {
+		t.Fatalf("test webhook: %d %s", w.Code, w.Body)
+	}
+	header := <-got
+	if header.Get("Authorization") != "Bearer s3cret" || header.Get("X-Webhook-Event") != "test" {
+		t.Errorf("test request headers %v, want Authorization set and X-Webhook-Event kept as test", header)
+	}
+
+	w = serveJSON(router, http.MethodPut, fmt.Sprintf("/api/webhooks/%d", created.ID), map[string]any{
+		"headers": map[string]string{"Bad Name": "x"},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("update with invalid header name: %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestTestWebhookSignsTimestampAndBody(t *testing.T) {
+	setupTestDB(t)
+	router := newWebhookRouter(t)
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		got <- request{req.Header.Clone(), body}
+	}))
+	defer receiver.Close()
+
+	w := serveJSON(router, http.MethodPost, "/api/webhooks", map[string]any{
+		"name": "signed", "url": receiver.URL, "secret": "s3cret", "events": []string{"job_completed"},
+	})
+	created := decodeWebhook(t, w.Code, http.StatusCreated, w.Body.Bytes())
+
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/api/webhooks/%d/test", created.ID), nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"success":true`) {
+		t.Fatalf("test webhook: %d %s", w.Code, w.Body)
+	}
+	r := <-got
+	if v := r.header.Get(webhook.SignatureVersionHeader); v != "v1=sha256" {
+		t.Errorf("%s = %q, want v1=sha256", webhook.SignatureVersionHeader, v)
+	}
+
+	signature := r.header.Get(webhook.SignatureHeader)
+	ts, v1, ok := strings.Cut(strings.TrimPrefix(signature, "t="), ",v1=")
+	if !ok {
+		t.Fatalf("signature %q is not t=...,v1=...", signature)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(ts + "." + string(r.body)))
+	if want := hex.EncodeToString(mac.Sum(nil)); v1 != want {
+		t.Errorf("v1 = %s, want the HMAC of %q", v1, ts+"."+string(r.body))
+	}
+}
@@ -163,7 +163,7 @@ func (h *WebUIHandler) getPrinterStatuses(c *gin.Context) []PrinterWithStatus {
 		}
 
 		if h.printerManager != nil {
-			status, err := h.printerManager.CheckStatus(p.ID)
+			status, err := h.printerManager.CheckStatus(c.Request.Context(), p.ID)
 			if err == nil {
 				if status.Warning != "" && status.Warning != "none" {
 					ps.Warning = status.Warning
@@ -263,7 +263,7 @@ func (h *WebUIHandler) GetPrinterStatusCard(c *gin.Context) {
 	}
 
 	if h.printerManager != nil {
-		status, err := h.printerManager.CheckStatus(printer.ID)
+		status, err := h.printerManager.CheckStatus(c.Request.Context(), printer.ID)
 		if err == nil {
 			if status.Warning != "" && status.Warning != "none" {
 				ps.Warning = status.Warning
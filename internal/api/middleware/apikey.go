@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/utils"
+)
+
+// APIKeyHeader carries an API key on requests from non-interactive clients.
+const APIKeyHeader = "X-API-Key"
+
+const (
+	apiKeyPrefix    = "spk_"
+	apiKeyPrefixLen = len(apiKeyPrefix) + 8
+)
+
+// GenerateAPIKey returns a new random API key along with the short prefix
+// shown in listings and the hash that is stored in its place.
+func GenerateAPIKey() (key, prefix, hash string) {
+	key = apiKeyPrefix + hex.EncodeToString(utils.GenerateRandomKey())
+	return key, key[:apiKeyPrefixLen], HashAPIKey(key)
+}
+
+// HashAPIKey returns the hex SHA-256 of key. Keys are 256 bits of random
+// data, so a fast unsalted hash is enough and lets keys be looked up
+// directly by hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIKey resolves key to an unrevoked API key and marks the
+// request as authenticated by it. The key name is stored under "api_key",
+// which is what job quotas are counted against, and its namespace under
+// "namespace".
+func (a *AuthMiddleware) authenticateAPIKey(c *gin.Context, key string) error {
+	apiKey, err := db.APIKeys.GetActiveAPIKeyByHash(c.Request.Context(), HashAPIKey(key))
+	if err != nil {
+		return err
+	}
+
+	if err := db.APIKeys.TouchAPIKey(c.Request.Context(), apiKey.ID); err != nil {
+		log.Printf("auth: failed to record use of api key %d: %v", apiKey.ID, err)
+	}
+
+	c.Set("authenticated", true)
+	c.Set("api_key", apiKey.Name)
+	c.Set("api_key_id", apiKey.ID)
+	c.Set("namespace", apiKey.Namespace)
+	return nil
+}
+
+// CallerNamespace returns the namespace the request is scoped to: that of
+// the API key it was authenticated with, or "" for logged-in sessions and
+// keys without a namespace, which see every namespace.
+func CallerNamespace(c *gin.Context) string {
+	return c.GetString("namespace")
+}
+
+// IsAdmin reports whether the request comes from a logged-in session with
+// the admin role rather than an API key.
+func IsAdmin(c *gin.Context) bool {
+	return c.GetString("api_key") == "" && CallerRole(c) == RoleAdmin
+}
+
+// RequireAdmin rejects requests authenticated with an API key or from a
+// user without the admin role, so routes behind it can only be used from an
+// admin's session. It must run after RequireAuth.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("api_key") != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys cannot access this endpoint"})
+			return
+		}
+		if !IsAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This action requires the admin role"})
+			return
+		}
+		c.Next()
+	}
+}
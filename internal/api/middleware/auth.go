@@ -23,9 +23,23 @@ const (
 	settingsKeyJWTSecret = "jwt_secret"
 )
 
+// Claims identify a session. Sessions of named users carry the username as
+// the subject along with the user's ID and their role at login, though
+// requests act with the role the user has now; sessions from the shared
+// admin password have neither and act as admin.
 type Claims struct {
 	jwt.RegisteredClaims
-	Authenticated bool `json:"authenticated"`
+	Authenticated bool   `json:"authenticated"`
+	UserID        int64  `json:"uid,omitempty"`
+	Role          string `json:"role,omitempty"`
+}
+
+// role returns the role the session acts with.
+func (c *Claims) role() string {
+	if c.Role == "" {
+		return RoleAdmin
+	}
+	return c.Role
 }
 
 type AuthMiddleware struct {
@@ -33,13 +47,17 @@ type AuthMiddleware struct {
 	secret []byte
 }
 
+// LoginRequest logs in as a named user, or with the shared admin password
+// when Username is empty or "admin" and no user has that name.
 type LoginRequest struct {
+	Username string `json:"username"`
 	Password string `json:"password" binding:"required"`
 }
 
 type LoginResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
+	Role    string `json:"role,omitempty"`
 }
 
 type ChangePasswordRequest struct {
@@ -52,8 +70,10 @@ type SetupRequest struct {
 }
 
 type StatusResponse struct {
-	Authenticated bool `json:"authenticated"`
-	SetupRequired bool `json:"setup_required"`
+	Authenticated bool   `json:"authenticated"`
+	SetupRequired bool   `json:"setup_required"`
+	Username      string `json:"username,omitempty"`
+	Role          string `json:"role,omitempty"`
 }
 
 func NewAuthMiddleware(database *sql.DB) (*AuthMiddleware, error) {
@@ -85,13 +105,21 @@ func (a *AuthMiddleware) getOrCreateSecret() ([]byte, error) {
 	return hex.DecodeString(setting.Value)
 }
 
+// isSetupRequired reports whether there is no way to log in as admin yet:
+// neither the shared admin password nor an admin user exists.
 func (a *AuthMiddleware) isSetupRequired() bool {
 	ctx := context.Background()
 	_, err := db.Settings.GetSetting(ctx, settingsKeyPassword)
-	return errors.Is(err, sql.ErrNoRows)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	admins, err := db.Users.CountUsersByRole(ctx, RoleAdmin)
+	return err == nil && admins == 0
 }
 
-func (a *AuthMiddleware) generateToken() (string, error) {
+// generateToken issues a session token for user, or for the shared admin
+// password when user is nil.
+func (a *AuthMiddleware) generateToken(user *db.User) (string, error) {
 	now := time.Now()
 	claims := &Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -100,6 +128,12 @@ func (a *AuthMiddleware) generateToken() (string, error) {
 			Issuer:    "spool",
 		},
 		Authenticated: true,
+		Role:          RoleAdmin,
+	}
+	if user != nil {
+		claims.Subject = user.Username
+		claims.UserID = user.ID
+		claims.Role = user.Role
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -161,25 +195,55 @@ func (a *AuthMiddleware) LoginHandler(c *gin.Context) {
 	}
 
 	ctx := context.Background()
-	setting, err := db.Settings.GetSetting(ctx, settingsKeyPassword)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, LoginResponse{Success: false, Message: "Server error"})
-		return
+	var user *db.User
+	if req.Username != "" {
+		u, err := db.Users.GetUserByUsername(ctx, req.Username)
+		switch {
+		case err == nil:
+			user = u
+		case !errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusInternalServerError, LoginResponse{Success: false, Message: "Server error"})
+			return
+		case req.Username != RoleAdmin:
+			c.JSON(http.StatusUnauthorized, LoginResponse{Success: false, Message: "Invalid username or password"})
+			return
+		}
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(setting.Value), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, LoginResponse{Success: false, Message: "Invalid password"})
-		return
+	if user != nil {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+			c.JSON(http.StatusUnauthorized, LoginResponse{Success: false, Message: "Invalid username or password"})
+			return
+		}
+	} else {
+		setting, err := db.Settings.GetSetting(ctx, settingsKeyPassword)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusUnauthorized, LoginResponse{Success: false, Message: "Invalid username or password"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, LoginResponse{Success: false, Message: "Server error"})
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(setting.Value), []byte(req.Password)); err != nil {
+			c.JSON(http.StatusUnauthorized, LoginResponse{Success: false, Message: "Invalid password"})
+			return
+		}
 	}
 
-	token, err := a.generateToken()
+	token, err := a.generateToken(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, LoginResponse{Success: false, Message: "Failed to generate token"})
 		return
 	}
 
+	role := RoleAdmin
+	if user != nil {
+		role = user.Role
+	}
 	a.setAuthCookie(c, token)
-	c.JSON(http.StatusOK, LoginResponse{Success: true})
+	c.JSON(http.StatusOK, LoginResponse{Success: true, Role: role})
 }
 
 func (a *AuthMiddleware) LogoutHandler(c *gin.Context) {
@@ -200,9 +264,21 @@ func (a *AuthMiddleware) StatusHandler(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, StatusResponse{Authenticated: claims.Authenticated, SetupRequired: false})
+	if err := setSessionUser(c, claims); err != nil {
+		c.JSON(http.StatusOK, StatusResponse{Authenticated: false, SetupRequired: a.isSetupRequired()})
+		return
+	}
+
+	c.JSON(http.StatusOK, StatusResponse{
+		Authenticated: claims.Authenticated,
+		SetupRequired: false,
+		Username:      c.GetString("username"),
+		Role:          c.GetString("role"),
+	})
 }
 
+// ChangePasswordHandler changes the password of the logged-in user, or the
+// shared admin password for sessions that logged in with it.
 func (a *AuthMiddleware) ChangePasswordHandler(c *gin.Context) {
 	var req ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -210,6 +286,13 @@ func (a *AuthMiddleware) ChangePasswordHandler(c *gin.Context) {
 		return
 	}
 
+	if token := a.getTokenFromRequest(c); token != "" {
+		if claims, err := a.validateToken(token); err == nil && claims.UserID != 0 {
+			a.changeUserPassword(c, claims.UserID, req)
+			return
+		}
+	}
+
 	ctx := context.Background()
 	setting, err := db.Settings.GetSetting(ctx, settingsKeyPassword)
 	if err != nil {
@@ -233,7 +316,46 @@ func (a *AuthMiddleware) ChangePasswordHandler(c *gin.Context) {
 		return
 	}
 
-	token, err := a.generateToken()
+	token, err := a.generateToken(nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	a.setAuthCookie(c, token)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Password changed"})
+}
+
+func (a *AuthMiddleware) changeUserPassword(c *gin.Context, userID int64, req ChangePasswordRequest) {
+	ctx := context.Background()
+	user, err := db.Users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user.PasswordHash = string(hashedPassword)
+	if err := db.Users.UpdateUser(ctx, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	token, err := a.generateToken(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -268,7 +390,7 @@ func (a *AuthMiddleware) SetupHandler(c *gin.Context) {
 		return
 	}
 
-	token, err := a.generateToken()
+	token, err := a.generateToken(nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -280,6 +402,19 @@ func (a *AuthMiddleware) SetupHandler(c *gin.Context) {
 
 func (a *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			if err := a.authenticateAPIKey(c, key); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API key"})
+				} else {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+				}
+				return
+			}
+			c.Next()
+			return
+		}
+
 		token := a.getTokenFromRequest(c)
 		if token == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
@@ -297,14 +432,51 @@ func (a *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		if err := setSessionUser(c, claims); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+			} else {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			}
+			return
+		}
 		c.Set("authenticated", true)
 		c.Set("claims", claims)
 		c.Next()
 	}
 }
 
+// setSessionUser records who a session belongs to: their role under
+// "role", and for named users their username under "username" and ID under
+// "user_id". Sessions from the shared admin password act as admin. A named
+// user's role is read from the users table rather than the token, so
+// demoting or deleting a user takes effect on their next request; it
+// returns sql.ErrNoRows when the user has been deleted.
+func setSessionUser(c *gin.Context, claims *Claims) error {
+	if claims.UserID == 0 {
+		c.Set("role", claims.role())
+		return nil
+	}
+	user, err := db.Users.GetUserByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		return err
+	}
+	c.Set("role", user.Role)
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	return nil
+}
+
 func (a *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			if err := a.authenticateAPIKey(c, key); err != nil {
+				c.Set("authenticated", false)
+			}
+			c.Next()
+			return
+		}
+
 		token := a.getTokenFromRequest(c)
 		if token == "" {
 			c.Set("authenticated", false)
@@ -319,6 +491,11 @@ func (a *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			return
 		}
 
+		if err := setSessionUser(c, claims); err != nil {
+			c.Set("authenticated", false)
+			c.Next()
+			return
+		}
 		c.Set("authenticated", claims.Authenticated)
 		c.Set("claims", claims)
 		c.Next()
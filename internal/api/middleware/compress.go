@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCompressMinSize is the response size below which Compress sends
+// the body uncompressed.
+const DefaultCompressMinSize = 1024
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Compress gzips (or, failing that, deflates) responses for clients that
+// accept it. Bodies are buffered until they reach minSize bytes, so small
+// responses go out unchanged. Server-sent event streams, file downloads
+// (anything with a Content-Disposition header) and responses that already
+// set a Content-Encoding are passed through untouched.
+func Compress(minSize int) gin.HandlerFunc {
+	if minSize <= 0 {
+		minSize = DefaultCompressMinSize
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == "HEAD" {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		c.Header("Vary", "Accept-Encoding")
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip. Codings listed with q=0 are refused.
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			accepted[coding] = true
+		}
+	}
+
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// compressWriter buffers the start of a response to decide whether it is
+// worth compressing, then either compresses the rest or passes it through.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding    string
+	minSize     int
+	buf         bytes.Buffer
+	cw          compressor
+	passthrough bool
+	checked     bool
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.checked {
+		w.checked = true
+		h := w.Header()
+		if h.Get("Content-Encoding") != "" || h.Get("Content-Disposition") != "" ||
+			strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+			w.passthrough = true
+		}
+	}
+
+	switch {
+	case w.passthrough:
+		return w.ResponseWriter.Write(data)
+	case w.cw != nil:
+		return w.cw.Write(data)
+	}
+
+	w.buf.Write(data)
+	if w.buf.Len() >= w.minSize {
+		if err := w.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) startCompression() error {
+	h := w.Header()
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+
+	// The HTTP "deflate" coding is the zlib format, not raw DEFLATE.
+	if w.encoding == "gzip" {
+		w.cw = gzip.NewWriter(w.ResponseWriter)
+	} else {
+		w.cw = zlib.NewWriter(w.ResponseWriter)
+	}
+
+	_, err := w.cw.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Flush sends whatever has been written so far. A response flushed before
+// reaching minSize is treated as a stream and is not compressed.
+func (w *compressWriter) Flush() {
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	} else if !w.passthrough {
+		w.passthrough = true
+		w.checked = true
+		w.flushBuffer()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) flushBuffer() {
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+func (w *compressWriter) finish() {
+	if w.cw != nil {
+		_ = w.cw.Close()
+		return
+	}
+	w.flushBuffer()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.buf.Len() > 0 || w.cw != nil
+}
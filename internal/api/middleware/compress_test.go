@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCompressRouter serves a large JSON list, a small JSON body, an event
+// stream and a download behind Compress.
+func newCompressRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Compress(0))
+
+	jobs := make([]gin.H, 200)
+	for i := range jobs {
+		jobs[i] = gin.H{"id": i, "status": "completed", "submitted_by": "warehouse"}
+	}
+	router.GET("/jobs", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"jobs": jobs}) })
+	router.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, "data: %s\n\n", strings.Repeat("x", 2048))
+	})
+	router.GET("/download", func(c *gin.Context) {
+		c.Header("Content-Disposition", `attachment; filename="jobs.csv"`)
+		c.String(http.StatusOK, strings.Repeat("a,b\n", 1024))
+	})
+	return router
+}
+
+func get(router http.Handler, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompressLargeResponse(t *testing.T) {
+	router := newCompressRouter()
+	plain := get(router, "/jobs", "")
+	if plain.Header().Get("Content-Encoding") != "" || !strings.HasPrefix(plain.Body.String(), `{"jobs":[`) {
+		t.Fatalf("response without Accept-Encoding is %q encoded, want plain JSON", plain.Header().Get("Content-Encoding"))
+	}
+
+	tests := []struct {
+		accept, encoding string
+		reader           func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"deflate, gzip;q=0.5", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"gzip;q=0, deflate", "deflate", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+	}
+	for _, tt := range tests {
+		w := get(router, "/jobs", tt.accept)
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("Accept-Encoding %q: encoded as %q, want %q", tt.accept, got, tt.encoding)
+			continue
+		}
+		if w.Body.Len() >= plain.Body.Len() {
+			t.Errorf("Accept-Encoding %q: %d bytes, want fewer than the %d plain bytes", tt.accept, w.Body.Len(), plain.Body.Len())
+		}
+		r, err := tt.reader(w.Body)
+		if err != nil {
+			t.Fatalf("Accept-Encoding %q: open body: %v", tt.accept, err)
+		}
+		body, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Accept-Encoding %q: read body: %v", tt.accept, err)
+		}
+		if string(body) != plain.Body.String() {
+			t.Errorf("Accept-Encoding %q: body decompresses to something else than the plain response", tt.accept)
+		}
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	router := newCompressRouter()
+	for _, path := range []string{"/small", "/stream", "/download"} {
+		w := get(router, path, "gzip")
+		if got := w.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s is %q encoded, want it sent plain", path, got)
+		}
+		if plain := get(router, path, ""); w.Body.String() != plain.Body.String() {
+			t.Errorf("%s body differs from the uncompressed response", path)
+		}
+	}
+	if w := get(router, "/jobs", "br"); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("response for Accept-Encoding br is %q encoded, want plain", w.Header().Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+// JobQuota tracks job submissions per API key over a rolling window.
+type JobQuota struct {
+	mu           sync.Mutex
+	window       time.Duration
+	defaultLimit int
+	limits       map[string]int
+	submissions  map[string][]time.Time
+	now          func() time.Time
+}
+
+func NewJobQuota(cfg config.QuotaConfig) *JobQuota {
+	window := cfg.Window
+	if window <= 0 {
+		window = time.Hour
+	}
+
+	limits := make(map[string]int, len(cfg.Keys))
+	for key, limit := range cfg.Keys {
+		limits[key] = limit
+	}
+
+	return &JobQuota{
+		window:       window,
+		defaultLimit: cfg.JobsPerWindow,
+		limits:       limits,
+		submissions:  make(map[string][]time.Time),
+		now:          time.Now,
+	}
+}
+
+// SetLimit overrides the quota for a single key. A limit of 0 removes the cap.
+func (q *JobQuota) SetLimit(key string, limit int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limits[key] = limit
+}
+
+func (q *JobQuota) limitFor(key string) int {
+	if limit, ok := q.limits[key]; ok {
+		return limit
+	}
+	return q.defaultLimit
+}
+
+// Allow records a submission for key and reports whether it fits within the
+// key's quota. When it does not, the returned duration is how long until the
+// oldest submission leaves the window.
+func (q *JobQuota) Allow(key string) (bool, time.Duration) {
+	return q.AllowN(key, 1)
+}
+
+// AllowN is Allow for n submissions at once, which are recorded only if
+// all of them fit. When they do not, the returned duration is how long
+// until enough earlier submissions leave the window, or the whole window if
+// n is more than the limit.
+func (q *JobQuota) AllowN(key string, n int) (bool, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	limit := q.limitFor(key)
+	if limit == 0 {
+		return true, 0
+	}
+
+	now := q.now()
+	cutoff := now.Add(-q.window)
+
+	recent := q.submissions[key]
+	i := 0
+	for i < len(recent) && !recent[i].After(cutoff) {
+		i++
+	}
+	recent = recent[i:]
+
+	if len(recent)+n > limit {
+		q.submissions[key] = recent
+		if n > limit {
+			return false, q.window
+		}
+		return false, recent[len(recent)+n-limit-1].Add(q.window).Sub(now)
+	}
+
+	for j := 0; j < n; j++ {
+		recent = append(recent, now)
+	}
+	q.submissions[key] = recent
+	return true, 0
+}
+
+// Refund removes n of key's most recent submissions, for submissions
+// Allow or AllowN counted that did not go on to create jobs.
+func (q *JobQuota) Refund(key string, n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	recent := q.submissions[key]
+	if n > len(recent) {
+		n = len(recent)
+	}
+	q.submissions[key] = recent[:len(recent)-n]
+}
+
+// Usage returns the number of submissions for key within the current window.
+func (q *JobQuota) Usage(key string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cutoff := q.now().Add(-q.window)
+	count := 0
+	for _, t := range q.submissions[key] {
+		if t.After(cutoff) {
+			count++
+		}
+	}
+	return count
+}
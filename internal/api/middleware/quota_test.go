@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+func TestJobQuotaRollingWindow(t *testing.T) {
+	quota := NewJobQuota(config.QuotaConfig{Window: time.Hour, JobsPerWindow: 2})
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	quota.now = func() time.Time { return now }
+
+	quota.Allow("key")
+	now = now.Add(30 * time.Minute)
+	quota.Allow("key")
+
+	now = now.Add(10 * time.Minute)
+	ok, retryAfter := quota.Allow("key")
+	if ok {
+		t.Fatal("third job within the hour allowed, want it throttled")
+	}
+	if retryAfter != 20*time.Minute {
+		t.Errorf("retry after %s, want 20m until the first job leaves the window", retryAfter)
+	}
+
+	// The first job has left the window, freeing one slot.
+	now = now.Add(20 * time.Minute)
+	if ok, _ := quota.Allow("key"); !ok {
+		t.Error("job after the first left the window throttled, want it allowed")
+	}
+	if usage := quota.Usage("key"); usage != 2 {
+		t.Errorf("usage %d, want 2", usage)
+	}
+}
+
+func TestJobQuotaAllowN(t *testing.T) {
+	quota := NewJobQuota(config.QuotaConfig{Window: time.Hour, JobsPerWindow: 5})
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	quota.now = func() time.Time { return now }
+
+	quota.AllowN("key", 2)
+	now = now.Add(30 * time.Minute)
+	quota.AllowN("key", 2)
+
+	// Four used, so three more need both of the first two to leave.
+	ok, retryAfter := quota.AllowN("key", 3)
+	if ok {
+		t.Fatal("three jobs with one slot left allowed, want them throttled")
+	}
+	if retryAfter != 30*time.Minute {
+		t.Errorf("retry after %s, want 30m until the first two jobs leave the window", retryAfter)
+	}
+	if usage := quota.Usage("key"); usage != 4 {
+		t.Errorf("usage %d after a refused batch, want 4", usage)
+	}
+	if ok, _ := quota.AllowN("key", 6); ok {
+		t.Error("more jobs than the limit allowed")
+	}
+	if ok, _ := quota.AllowN("key", 1); !ok {
+		t.Error("job into the last slot throttled, want it allowed")
+	}
+}
+
+func TestJobQuotaRefund(t *testing.T) {
+	quota := NewJobQuota(config.QuotaConfig{Window: time.Hour, JobsPerWindow: 2})
+
+	quota.AllowN("key", 2)
+	quota.Refund("key", 1)
+	if usage := quota.Usage("key"); usage != 1 {
+		t.Errorf("usage %d after refunding one of two jobs, want 1", usage)
+	}
+	if ok, _ := quota.Allow("key"); !ok {
+		t.Error("job into the refunded slot throttled, want it allowed")
+	}
+
+	quota.Refund("key", 5)
+	if usage := quota.Usage("key"); usage != 0 {
+		t.Errorf("usage %d after refunding more than was used, want 0", usage)
+	}
+}
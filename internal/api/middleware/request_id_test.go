@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/orrn/spool/internal/logging"
+)
+
+func TestRequestIDKeepsValidClientID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var seen string
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		seen = logging.RequestID(c.Request.Context())
+	})
+
+	tests := []struct {
+		sent string
+		keep bool
+	}{
+		{"order-1234.a_b", true},
+		{"", false},
+		{"bad id\nwith newline", false},
+		{string(make([]byte, maxRequestIDLength+1)), false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.sent != "" {
+			req.Header.Set(RequestIDHeader, tt.sent)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		echoed := w.Header().Get(RequestIDHeader)
+		if echoed == "" || echoed != seen {
+			t.Errorf("sent %q: response has ID %q and handler saw %q, want the same non-empty ID", tt.sent, echoed, seen)
+		}
+		if got := echoed == tt.sent; got != tt.keep {
+			t.Errorf("sent %q: got ID %q, want client ID kept = %v", tt.sent, echoed, tt.keep)
+		}
+	}
+}
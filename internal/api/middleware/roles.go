@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles a user can have, from most to least privileged. Admins manage
+// users, keys and settings; operators manage printers, templates and jobs;
+// viewers can only read.
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ValidRole reports whether role is one a user can be given.
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// CallerRole returns the role the request acts with, as set by the auth
+// middleware: sessions act with their user's current role, sessions from
+// the shared admin password as admin, and API keys as operator. Requests
+// the auth middleware did not authenticate have no role.
+func CallerRole(c *gin.Context) string {
+	if authenticated, ok := c.Get("authenticated"); ok && authenticated == false {
+		return ""
+	}
+	if role := c.GetString("role"); role != "" {
+		return role
+	}
+	if c.GetString("api_key") != "" {
+		return RoleOperator
+	}
+	return ""
+}
+
+// RequireRole rejects requests whose role ranks below role. It must run
+// after RequireAuth.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if roleRanks[CallerRole(c)] < roleRanks[role] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This action requires the " + role + " role"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -14,6 +14,7 @@ type Config struct {
 	Database DatabaseConfig `yaml:"database"`
 	Printers PrintersConfig `yaml:"printers"`
 	Queue    QueueConfig    `yaml:"queue"`
+	Quotas   QuotaConfig    `yaml:"quotas"`
 	Logging  LoggingConfig  `yaml:"logging"`
 }
 
@@ -24,21 +25,103 @@ type ServerConfig struct {
 }
 
 type DatabaseConfig struct {
-	Path        string `yaml:"path"`
-	ArchivePath string `yaml:"archive_path"`
-	ArchiveDays int    `yaml:"archive_days"`
+	Path              string        `yaml:"path"`
+	ArchivePath       string        `yaml:"archive_path"`
+	ArchiveDays       int           `yaml:"archive_days"`
+	RetentionEnabled  bool          `yaml:"retention_enabled"`
+	RetentionDays     int           `yaml:"retention_days"`
+	RetentionInterval time.Duration `yaml:"retention_interval"`
+	// TSPLRetentionDays clears the stored TSPL of completed and cancelled
+	// jobs this many days after they finish, keeping the jobs themselves.
+	// 0 keeps it for as long as the job.
+	TSPLRetentionDays int `yaml:"tspl_retention_days"`
+
+	// MaxOpenConns is how many connections the database pool may open;
+	// reads run on them in parallel while writes take turns. BusyTimeout is
+	// how long a write waits for another to finish before failing.
+	MaxOpenConns int           `yaml:"max_open_conns"`
+	BusyTimeout  time.Duration `yaml:"busy_timeout"`
 }
 
 type PrintersConfig struct {
 	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
 	ConnectionTimeout   time.Duration `yaml:"connection_timeout"`
 	StatusPollInterval  time.Duration `yaml:"status_poll_interval"`
+	// DedicatedStatusConnection makes status checks dial a short-lived
+	// connection of their own instead of sharing the cached print connection.
+	DedicatedStatusConnection bool `yaml:"dedicated_status_connection"`
+	// ReconnectDelay is the wait after a failed dial before the printer is
+	// dialed again, doubling with each further failure up to
+	// MaxReconnectBackoff.
+	ReconnectDelay      time.Duration `yaml:"reconnect_delay"`
+	MaxReconnectBackoff time.Duration `yaml:"max_reconnect_backoff"`
+	// DialRetries is how many more times a refused or failed dial is
+	// retried, after a short pause, before the connection attempt fails.
+	// All attempts share ConnectionTimeout.
+	DialRetries int `yaml:"dial_retries"`
+	// StatusDebounce collapses status changes within this window into a
+	// single webhook and event. 0 publishes every change immediately.
+	StatusDebounce time.Duration `yaml:"status_debounce"`
+	// AllowPublicIPs permits printer addresses outside the private ranges.
+	// Loopback and multicast addresses are rejected either way.
+	AllowPublicIPs bool `yaml:"allow_public_ips"`
+	// SpoolRoot is the directory file printers' spool directories must
+	// lie under. Empty disables file printers.
+	SpoolRoot string `yaml:"spool_root"`
+	// InitCommands are sent once on each new connection to a printer that
+	// has no init_commands of its own, for example GAPDETECT.
+	InitCommands []string `yaml:"init_commands"`
+	// RecountInterval is how often every printer's total_prints is reset
+	// to the sum of its daily print counters. 0 disables the recount.
+	RecountInterval time.Duration `yaml:"recount_interval"`
 }
 
 type QueueConfig struct {
-	MaxRetries   int           `yaml:"max_retries"`
-	RetryDelay   time.Duration `yaml:"retry_delay"`
-	WorkerCount  int           `yaml:"worker_count"`
+	MaxRetries      int           `yaml:"max_retries"`
+	RetryDelay      time.Duration `yaml:"retry_delay"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+	WorkerCount     int           `yaml:"worker_count"`
+	MaxTSPLBytes    int           `yaml:"max_tspl_bytes"`
+	// BusyRetryDelay is how long a job waits before trying again when its
+	// printer is busy feeding. BusyTimeout bounds the total wait, after
+	// which the busy printer is treated as an ordinary failure.
+	BusyRetryDelay time.Duration `yaml:"busy_retry_delay"`
+	BusyTimeout    time.Duration `yaml:"busy_timeout"`
+	// SourcePriorities sets the priority given to jobs that don't specify
+	// one, keyed by submission source ("api", "legacy", "quick_print").
+	SourcePriorities map[string]int `yaml:"source_priorities"`
+	// Thumbnails stores a small PNG of the label each completed job
+	// printed, drawn from its TSPL in the background, for the job history.
+	// ThumbnailSize is its longer side in pixels.
+	Thumbnails    bool `yaml:"thumbnails"`
+	ThumbnailSize int  `yaml:"thumbnail_size"`
+	// DeadLetter moves jobs that exhausted their retries out of print_jobs
+	// into dead_letter_jobs, where they can be reviewed and requeued.
+	DeadLetter bool `yaml:"dead_letter"`
+	// LabelSizeCheck compares a template's size with its printer's labels
+	// when a job is submitted: "warn" (the default) accepts a mismatch with
+	// a warning, "strict" rejects it and "off" skips the check. A
+	// difference up to LabelSizeToleranceMM is not a mismatch.
+	LabelSizeCheck       string  `yaml:"label_size_check"`
+	LabelSizeToleranceMM float64 `yaml:"label_size_tolerance_mm"`
+	// MaxCopiesPerJob caps the copies a single job may request, so one
+	// call cannot tie up a printer. 0 means unlimited.
+	MaxCopiesPerJob int `yaml:"max_copies_per_job"`
+	// SyncPrintTimeout bounds a synchronous print (POST /jobs/sync),
+	// including any wait for the printer to finish a queued job.
+	SyncPrintTimeout time.Duration `yaml:"sync_print_timeout"`
+	// LegacyDedupWindow is how long a print from the legacy endpoint for a
+	// layout and uid answers repeats with the same job, for scanners that
+	// fire twice. 0 enqueues every request.
+	LegacyDedupWindow time.Duration `yaml:"legacy_dedup_window"`
+}
+
+// QuotaConfig limits how many jobs a single API key may submit within a
+// rolling window. A limit of 0 means unlimited.
+type QuotaConfig struct {
+	Window        time.Duration  `yaml:"window"`
+	JobsPerWindow int            `yaml:"jobs_per_window"`
+	Keys          map[string]int `yaml:"keys"`
 }
 
 type LoggingConfig struct {
@@ -54,19 +137,42 @@ func defaults() *Config {
 			WriteTimeout: 30 * time.Second,
 		},
 		Database: DatabaseConfig{
-			Path:        "./data/spool.db",
-			ArchivePath: "./data/archives",
-			ArchiveDays: 30,
+			Path:              "./data/spool.db",
+			ArchivePath:       "./data/archives",
+			ArchiveDays:       30,
+			RetentionDays:     90,
+			RetentionInterval: time.Hour,
+			MaxOpenConns:      4,
+			BusyTimeout:       5 * time.Second,
 		},
 		Printers: PrintersConfig{
 			HealthCheckInterval: 30 * time.Second,
 			ConnectionTimeout:   10 * time.Second,
 			StatusPollInterval:  5 * time.Second,
+			ReconnectDelay:      time.Second,
+			MaxReconnectBackoff: time.Minute,
+			DialRetries:         2,
+			StatusDebounce:      10 * time.Second,
+			SpoolRoot:           "./data/spool",
 		},
 		Queue: QueueConfig{
-			MaxRetries:  3,
-			RetryDelay:  10 * time.Second,
-			WorkerCount: 2,
+			MaxRetries:      3,
+			RetryDelay:      10 * time.Second,
+			MaxRetryBackoff: 5 * time.Minute,
+			WorkerCount:     2,
+			MaxTSPLBytes:    1 << 20,
+			SourcePriorities: map[string]int{
+				"legacy": 10,
+			},
+			ThumbnailSize:        200,
+			LabelSizeCheck:       "warn",
+			LabelSizeToleranceMM: 1,
+			MaxCopiesPerJob:      1000,
+			SyncPrintTimeout:     30 * time.Second,
+			LegacyDedupWindow:    2 * time.Second,
+		},
+		Quotas: QuotaConfig{
+			Window: time.Hour,
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -138,6 +244,26 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("archive days must be non-negative")
 	}
 
+	if c.Database.RetentionDays < 0 {
+		return fmt.Errorf("retention days must be non-negative")
+	}
+
+	if c.Database.TSPLRetentionDays < 0 {
+		return fmt.Errorf("tspl retention days must be non-negative")
+	}
+
+	if c.Database.RetentionInterval < 0 {
+		return fmt.Errorf("retention interval must be non-negative")
+	}
+
+	if c.Database.MaxOpenConns < 0 {
+		return fmt.Errorf("database max open conns must be non-negative")
+	}
+
+	if c.Database.BusyTimeout < 0 {
+		return fmt.Errorf("database busy timeout must be non-negative")
+	}
+
 	if c.Printers.HealthCheckInterval < 0 {
 		return fmt.Errorf("health check interval must be non-negative")
 	}
@@ -150,6 +276,22 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("status poll interval must be non-negative")
 	}
 
+	if c.Printers.ReconnectDelay < 0 || c.Printers.MaxReconnectBackoff < 0 {
+		return fmt.Errorf("reconnect delay and backoff must be non-negative")
+	}
+
+	if c.Printers.DialRetries < 0 {
+		return fmt.Errorf("dial retries must be non-negative")
+	}
+
+	if c.Printers.RecountInterval < 0 {
+		return fmt.Errorf("recount interval must be non-negative")
+	}
+
+	if c.Printers.StatusDebounce < 0 {
+		return fmt.Errorf("status debounce must be non-negative")
+	}
+
 	if c.Queue.MaxRetries < 0 {
 		return fmt.Errorf("max retries must be non-negative")
 	}
@@ -158,10 +300,58 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("retry delay must be non-negative")
 	}
 
+	if c.Queue.MaxRetryBackoff < 0 {
+		return fmt.Errorf("max retry backoff must be non-negative")
+	}
+
 	if c.Queue.WorkerCount < 1 {
 		return fmt.Errorf("worker count must be at least 1")
 	}
 
+	if c.Queue.MaxTSPLBytes < 0 {
+		return fmt.Errorf("max tspl bytes must be non-negative")
+	}
+
+	if c.Queue.ThumbnailSize < 0 {
+		return fmt.Errorf("thumbnail size must be non-negative")
+	}
+
+	switch c.Queue.LabelSizeCheck {
+	case "", "off", "warn", "strict":
+	default:
+		return fmt.Errorf("label size check must be off, warn or strict, got %q", c.Queue.LabelSizeCheck)
+	}
+
+	if c.Queue.LabelSizeToleranceMM < 0 {
+		return fmt.Errorf("label size tolerance must be non-negative")
+	}
+
+	if c.Queue.MaxCopiesPerJob < 0 {
+		return fmt.Errorf("max copies per job must be non-negative")
+	}
+
+	if c.Queue.SyncPrintTimeout < 0 {
+		return fmt.Errorf("sync print timeout must be non-negative")
+	}
+
+	if c.Queue.LegacyDedupWindow < 0 {
+		return fmt.Errorf("legacy dedup window must be non-negative")
+	}
+
+	if c.Quotas.Window <= 0 {
+		return fmt.Errorf("quota window must be positive")
+	}
+
+	if c.Quotas.JobsPerWindow < 0 {
+		return fmt.Errorf("quota jobs per window must be non-negative")
+	}
+
+	for key, limit := range c.Quotas.Keys {
+		if limit < 0 {
+			return fmt.Errorf("quota for key %s must be non-negative", key)
+		}
+	}
+
 	validLevels := map[string]bool{
 		"debug": true,
 		"info":  true,
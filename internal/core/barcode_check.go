@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// retailBodyDigits is the number of digits before the check digit for the
+// EAN and UPC symbologies. Their content is checked before printing since a
+// wrong length or check digit prints a barcode no scanner reads.
+var retailBodyDigits = map[string]int{
+	"EAN13": 12,
+	"EAN8":  7,
+	"UPCA":  11,
+}
+
+// checkDigit returns the GS1 check digit for body: digits are weighted 3
+// and 1 alternately, starting with 3 from the rightmost.
+func checkDigit(body string) int {
+	sum := 0
+	for i := len(body) - 1; i >= 0; i -= 2 {
+		sum += 3 * int(body[i]-'0')
+	}
+	for i := len(body) - 2; i >= 0; i -= 2 {
+		sum += int(body[i] - '0')
+	}
+	return (10 - sum%10) % 10
+}
+
+// checkRetailBarcode validates barcode content for the EAN and UPC
+// symbologies. Content without a check digit has it appended, and content
+// with one must carry the right digit. Other symbologies pass unchanged.
+func checkRetailBarcode(symbology, content string) (string, error) {
+	body, ok := retailBodyDigits[strings.ToUpper(symbology)]
+	if !ok {
+		return content, nil
+	}
+
+	for _, r := range content {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("%s barcode content %q must contain only digits", symbology, content)
+		}
+	}
+
+	switch len(content) {
+	case body:
+		return content + strconv.Itoa(checkDigit(content)), nil
+	case body + 1:
+		want := checkDigit(content[:body])
+		if got := int(content[body] - '0'); got != want {
+			return "", fmt.Errorf("%s barcode content %q has check digit %d, want %d", symbology, content, got, want)
+		}
+		return content, nil
+	default:
+		return "", fmt.Errorf("%s barcode content %q has %d digits, want %d, or %d with the check digit", symbology, content, len(content), body, body+1)
+	}
+}
+
+// retailSample returns a valid sample value for a retail symbology, or ""
+// for other symbologies.
+func retailSample(symbology string) string {
+	body, ok := retailBodyDigits[strings.ToUpper(symbology)]
+	if !ok {
+		return ""
+	}
+	digits := sampleEAN13Base[:body]
+	return digits + strconv.Itoa(checkDigit(digits))
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRetailBarcodeContent(t *testing.T) {
+	tests := []struct {
+		name      string
+		symbology string
+		content   string
+		want      string
+		wantErr   string
+	}{
+		{"valid UPC-A", "UPCA", "036000291452", "036000291452", ""},
+		{"UPC-A check digit computed", "UPCA", "03600029145", "036000291452", ""},
+		{"EAN-13 check digit computed", "EAN13", "400638133393", "4006381333931", ""},
+		{"EAN-8 check digit computed", "EAN8", "9638507", "96385074", ""},
+		{"wrong check digit", "EAN13", "4006381333932", "", "has check digit 2, want 1"},
+		{"too short", "UPCA", "0360002914", "", "has 10 digits, want 11, or 12 with the check digit"},
+		{"too long", "EAN8", "963850741", "", "has 9 digits"},
+		{"not digits", "EAN13", "40063813339A", "", "must contain only digits"},
+		{"other symbology untouched", "128", "ABC-123", "ABC-123", ""},
+	}
+	for _, tt := range tests {
+		out, err := generateOne(t, LabelElement{Type: "barcode", Symbology: tt.symbology, Content: tt.content}, nil)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want one containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !strings.Contains(out, `,"`+tt.want+`"`) {
+			t.Errorf("%s: output %q does not print %q", tt.name, out, tt.want)
+		}
+	}
+}
+
+func TestSampleVariablesFitRetailSymbology(t *testing.T) {
+	schema := &LabelSchema{
+		Elements: []LabelElement{
+			{Type: "barcode", Symbology: "UPCA", Content: "{{upc}}"},
+			{Type: "barcode", Symbology: "EAN8", Content: "{{short}}"},
+		},
+		Variables: map[string]VariableDef{
+			"upc":   {Type: "barcode"},
+			"short": {Type: "barcode"},
+		},
+	}
+	samples := NewTSPL2Generator().SampleVariables(schema)
+	for name, symbology := range map[string]string{"upc": "UPCA", "short": "EAN8"} {
+		if _, err := checkRetailBarcode(symbology, samples[name]); err != nil || len(samples[name]) != retailBodyDigits[symbology]+1 {
+			t.Errorf("sample for %s is %q (%v), want a valid %s code", name, samples[name], err, symbology)
+		}
+	}
+}
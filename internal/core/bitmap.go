@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+)
+
+const defaultBitmapThreshold = 128
+
+// loadElementImage decodes the image for an embedded image element, from
+// image_data (plain base64 or a data URI) when present, otherwise from the
+// file at image_path on the server.
+func loadElementImage(elem *LabelElement) (image.Image, error) {
+	var raw []byte
+	if elem.ImageData != "" {
+		data := elem.ImageData
+		if i := strings.Index(data, ","); i >= 0 && strings.HasPrefix(data, "data:") {
+			data = data[i+1:]
+		}
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image_data: %w", err)
+		}
+		raw = decoded
+	} else {
+		data, err := os.ReadFile(elem.ImagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		raw = data
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// imageToBitmap converts img to TSPL BITMAP rows of widthBytes bytes each,
+// most significant bit first. A 0 bit prints a dot, so dark pixels are
+// cleared and light or transparent pixels are set. With dither the
+// quantization error is diffused Floyd-Steinberg style instead of a hard
+// threshold.
+func imageToBitmap(img image.Image, threshold int, dither bool) (widthBytes, height int, data []byte) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	widthBytes = (width + 7) / 8
+	data = make([]byte, widthBytes*height)
+	for i := range data {
+		data[i] = 0xFF
+	}
+
+	levels := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			gray := 255.0
+			if c.A >= 128 {
+				gray = 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			}
+			levels[y*width+x] = gray
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			old := levels[y*width+x]
+			black := old < float64(threshold)
+			if black {
+				data[y*widthBytes+x/8] &^= 0x80 >> uint(x%8)
+			}
+			if !dither {
+				continue
+			}
+
+			quantized := 255.0
+			if black {
+				quantized = 0
+			}
+			diff := old - quantized
+			if x+1 < width {
+				levels[y*width+x+1] += diff * 7 / 16
+			}
+			if y+1 < height {
+				if x > 0 {
+					levels[(y+1)*width+x-1] += diff * 3 / 16
+				}
+				levels[(y+1)*width+x] += diff * 5 / 16
+				if x+1 < width {
+					levels[(y+1)*width+x+1] += diff * 1 / 16
+				}
+			}
+		}
+	}
+
+	return widthBytes, height, data
+}
+
+// generateBitmap embeds the element's image as a BITMAP command so it does
+// not need to be stored on the printer first. The command's data is binary
+// and is followed directly by a newline.
+func (g *TSPL2Generator) generateBitmap(elem *LabelElement) (string, error) {
+	threshold := elem.Threshold
+	if threshold == 0 {
+		threshold = defaultBitmapThreshold
+	}
+	if threshold < 1 || threshold > 255 {
+		return "", fmt.Errorf("invalid image threshold %d (valid: 1-255)", threshold)
+	}
+
+	img, err := loadElementImage(elem)
+	if err != nil {
+		return "", err
+	}
+
+	widthBytes, height, data := imageToBitmap(img, threshold, elem.Dither)
+	return fmt.Sprintf("BITMAP %d,%d,%d,%d,0,%s", elem.X, elem.Y, widthBytes, height, data), nil
+}
+
+// splitBitmapCommand reports, for TSPL starting with a BITMAP command, how
+// many bytes the command header and its binary data occupy. ok is false for
+// anything else.
+func splitBitmapCommand(tspl string) (n int, ok bool) {
+	if !strings.HasPrefix(tspl, "BITMAP ") {
+		return 0, false
+	}
+
+	var x, y, widthBytes, height, mode int
+	header := tspl
+	commas := 0
+	for i := 0; i < len(header); i++ {
+		if header[i] == ',' {
+			commas++
+			if commas == 5 {
+				header = header[:i+1]
+				break
+			}
+		}
+		if header[i] == '\n' {
+			return 0, false
+		}
+	}
+	if commas != 5 {
+		return 0, false
+	}
+	if _, err := fmt.Sscanf(header, "BITMAP %d,%d,%d,%d,%d,", &x, &y, &widthBytes, &height, &mode); err != nil {
+		return 0, false
+	}
+
+	n = len(header) + widthBytes*height
+	if n > len(tspl) {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Overflow policies for block elements whose content does not fit the box.
+const (
+	OverflowClip     = "clip"
+	OverflowEllipsis = "ellipsis"
+	OverflowShrink   = "shrink"
+	OverflowError    = "error"
+)
+
+const ellipsis = "..."
+
+// bitmapFontSizes holds the cell size in dots of the printer's resident
+// bitmap fonts, before x/y multiplication.
+var bitmapFontSizes = map[string][2]int{
+	"1": {8, 12},
+	"2": {12, 20},
+	"3": {16, 24},
+	"4": {24, 32},
+	"5": {32, 48},
+	"6": {14, 19},
+	"7": {21, 27},
+	"8": {14, 25},
+}
+
+// blockMetrics estimates the character cell of a block's font. Bitmap fonts
+// scale by integer multipliers; TrueType fonts take their scales in points,
+// so their cell is derived from the label DPI with an average glyph width of
+// 60% of the height.
+func blockMetrics(font string, xScale, yScale, dpi int) (charWidth, lineHeight int) {
+	if size, ok := bitmapFontSizes[font]; ok {
+		return size[0] * xScale, size[1] * yScale
+	}
+	if dpi == 0 {
+		dpi = 203
+	}
+	return xScale * dpi * 6 / 720, yScale * dpi / 72
+}
+
+// blockLineCount counts the lines content wraps to at cols characters per
+// line, breaking on spaces and splitting words longer than a line.
+func blockLineCount(content string, cols int) int {
+	lines := 0
+	for _, para := range strings.Split(content, "\n") {
+		lines++
+		col := 0
+		for _, word := range strings.Fields(para) {
+			n := utf8.RuneCountInString(word)
+			if col > 0 && col+1+n <= cols {
+				col += 1 + n
+				continue
+			}
+			if col > 0 {
+				lines++
+			}
+			for n > cols {
+				n -= cols
+				lines++
+			}
+			col = n
+		}
+	}
+	return lines
+}
+
+func blockFits(content string, elem *LabelElement, font string, xScale, yScale, dpi int) bool {
+	charWidth, lineHeight := blockMetrics(font, xScale, yScale, dpi)
+	if charWidth <= 0 || lineHeight <= 0 {
+		return true
+	}
+	cols := elem.Width / charWidth
+	rows := (elem.Height + elem.Spacing) / (lineHeight + elem.Spacing)
+	if cols < 1 || rows < 1 {
+		return content == ""
+	}
+	return blockLineCount(content, cols) <= rows
+}
+
+// truncateToFit returns the longest prefix of content that fits with an
+// ellipsis appended.
+func truncateToFit(content string, fits func(string) bool) string {
+	runes := []rune(content)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if fits(strings.TrimRight(string(runes[:mid]), " ") + ellipsis) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if lo == 0 && !fits(ellipsis) {
+		return ""
+	}
+	return strings.TrimRight(string(runes[:lo]), " ") + ellipsis
+}
+
+// applyBlockOverflow enforces the element's overflow policy on resolved
+// content and returns the content and scales to print with. Blocks without a
+// width and height, or with the default clip policy, are left to the printer.
+func applyBlockOverflow(elem *LabelElement, content, font string, xScale, yScale, dpi int) (string, int, int, error) {
+	policy := elem.Overflow
+	switch policy {
+	case "", OverflowClip, OverflowEllipsis, OverflowShrink, OverflowError:
+	default:
+		return "", 0, 0, fmt.Errorf("invalid block overflow %q (valid: clip, ellipsis, shrink, error)", policy)
+	}
+
+	if policy == "" || policy == OverflowClip || elem.Width <= 0 || elem.Height <= 0 ||
+		blockFits(content, elem, font, xScale, yScale, dpi) {
+		return content, xScale, yScale, nil
+	}
+
+	switch policy {
+	case OverflowEllipsis:
+		fits := func(s string) bool { return blockFits(s, elem, font, xScale, yScale, dpi) }
+		content = truncateToFit(content, fits)
+	case OverflowShrink:
+		for xScale > 1 || yScale > 1 {
+			if xScale > 1 {
+				xScale--
+			}
+			if yScale > 1 {
+				yScale--
+			}
+			if blockFits(content, elem, font, xScale, yScale, dpi) {
+				break
+			}
+		}
+	case OverflowError:
+		return "", 0, 0, fmt.Errorf("block content does not fit in %dx%d dots", elem.Width, elem.Height)
+	}
+	return content, xScale, yScale, nil
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/orrn/spool/internal/logging"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead-letter job not found")
+
+// DeadLetterJob is a snapshot of a job that exhausted its retries, taken
+// when it was moved out of print_jobs. JobID is the ID the job had there.
+type DeadLetterJob struct {
+	ID            int64
+	JobID         int64
+	PrinterID     int64
+	TemplateID    int64
+	VariablesJSON string
+	TSPLContent   string
+	Priority      int
+	Copies        int
+	RetryCount    int
+	SubmittedBy   string
+	RequestID     string
+	Namespace     string
+	FailureReason string
+	CreatedAt     time.Time
+	FailedAt      time.Time
+}
+
+const deadLetterColumns = `id, job_id, COALESCE(printer_id, 0), COALESCE(template_id, 0), COALESCE(variables_json, ''), COALESCE(tspl_content, ''),
+	priority, copies, retry_count, COALESCE(submitted_by, ''), COALESCE(request_id, ''), namespace, COALESCE(failure_reason, ''), created_at, failed_at`
+
+// deadLetter moves a job that exhausted its retries to dead_letter_jobs.
+// The job_failed event is published as for any failed job. If the move
+// fails the job is left in print_jobs as failed instead.
+func (q *Queue) deadLetter(job *Job, errMsg string) {
+	if err := q.moveToDeadLetter(job.ID, errMsg); err != nil {
+		jobLogger(job).Error("failed to move job to dead-letter", "error", err)
+		q.failJob(job, errMsg)
+		return
+	}
+
+	jobLogger(job).Warn("job_dead_lettered", "error", errMsg)
+	q.emit("job_failed", job, JobStatusFailed, errMsg)
+}
+
+func (q *Queue) moveToDeadLetter(jobID int64, errMsg string) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO dead_letter_jobs (job_id, printer_id, template_id, variables_json, tspl_content, priority, copies, retry_count, submitted_by, request_id, namespace, failure_reason, created_at)
+		SELECT id, printer_id, template_id, variables_json, tspl_content, priority, copies, retry_count, submitted_by, request_id, namespace, ?, created_at
+		FROM print_jobs WHERE id = ?
+	`, errMsg, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to insert dead-letter job: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM print_jobs WHERE id = ?", jobID); err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ListDeadLetterJobs returns dead-letter jobs, most recently failed first,
+// and the total number of them. A non-empty namespace restricts both to jobs
+// tagged with it.
+func (q *Queue) ListDeadLetterJobs(namespace string, limit, offset int) ([]*DeadLetterJob, int, error) {
+	where, args := "", []interface{}{}
+	if namespace != "" {
+		where, args = " WHERE namespace = ?", append(args, namespace)
+	}
+
+	var total int
+	if err := q.db.QueryRow("SELECT COUNT(*) FROM dead_letter_jobs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead-letter jobs: %w", err)
+	}
+
+	rows, err := q.db.Query(`SELECT `+deadLetterColumns+` FROM dead_letter_jobs`+where+` ORDER BY failed_at DESC, id DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query dead-letter jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*DeadLetterJob
+	for rows.Next() {
+		job, err := scanDeadLetterJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query dead-letter jobs: %w", err)
+	}
+	return jobs, total, nil
+}
+
+// GetDeadLetterJob returns a dead-letter job, or ErrDeadLetterNotFound.
+func (q *Queue) GetDeadLetterJob(id int64) (*DeadLetterJob, error) {
+	job, err := scanDeadLetterJob(q.db.QueryRow(`SELECT `+deadLetterColumns+` FROM dead_letter_jobs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeadLetterNotFound
+	}
+	return job, err
+}
+
+func scanDeadLetterJob(row interface{ Scan(...any) error }) (*DeadLetterJob, error) {
+	var job DeadLetterJob
+	err := row.Scan(&job.ID, &job.JobID, &job.PrinterID, &job.TemplateID, &job.VariablesJSON, &job.TSPLContent,
+		&job.Priority, &job.Copies, &job.RetryCount, &job.SubmittedBy, &job.RequestID, &job.Namespace, &job.FailureReason, &job.CreatedAt, &job.FailedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan dead-letter job: %w", err)
+	}
+	return &job, nil
+}
+
+// RequeueDeadLetterJob enqueues a dead-letter job again as a new pending
+// job with a fresh retry count, removes it from the dead-letter list and
+// returns the new job's ID. Like a reprint, the new job is tagged with the
+// request ID carried by ctx.
+func (q *Queue) RequeueDeadLetterJob(ctx context.Context, id int64) (int64, error) {
+	dead, err := q.GetDeadLetterJob(id)
+	if err != nil {
+		return 0, err
+	}
+
+	// Claim the entry first so two concurrent requeues cannot both enqueue it.
+	result, err := q.db.Exec("DELETE FROM dead_letter_jobs WHERE id = ?", id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove dead-letter job: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return 0, ErrDeadLetterNotFound
+	}
+
+	jobID, err := q.Enqueue(&Job{
+		PrinterID:     dead.PrinterID,
+		TemplateID:    dead.TemplateID,
+		VariablesJSON: dead.VariablesJSON,
+		TSPLContent:   dead.TSPLContent,
+		Priority:      dead.Priority,
+		Copies:        dead.Copies,
+		SubmittedBy:   dead.SubmittedBy,
+		RequestID:     logging.RequestID(ctx),
+		Namespace:     dead.Namespace,
+		Status:        JobStatusPending,
+	})
+	if err != nil {
+		q.restoreDeadLetterJob(dead)
+		return 0, err
+	}
+	return jobID, nil
+}
+
+// restoreDeadLetterJob puts back an entry whose requeue failed.
+func (q *Queue) restoreDeadLetterJob(job *DeadLetterJob) {
+	_, err := q.db.Exec(`
+		INSERT INTO dead_letter_jobs (id, job_id, printer_id, template_id, variables_json, tspl_content, priority, copies, retry_count, submitted_by, request_id, namespace, failure_reason, created_at, failed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, job.ID, job.JobID, job.PrinterID, job.TemplateID, job.VariablesJSON, job.TSPLContent, job.Priority, job.Copies,
+		job.RetryCount, job.SubmittedBy, job.RequestID, job.Namespace, job.FailureReason, job.CreatedAt, job.FailedAt)
+	if err != nil {
+		logging.ForRequest(job.RequestID).Error("failed to restore dead-letter job", "dead_letter_id", job.ID, "job_id", job.JobID, "error", err)
+	}
+}
+
+// DeleteDeadLetterJob discards a dead-letter job for good.
+func (q *Queue) DeleteDeadLetterJob(id int64) error {
+	result, err := q.db.Exec("DELETE FROM dead_letter_jobs WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead-letter job: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/logging"
+)
+
+func TestJobExceedingRetriesIsDeadLettered(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	pm := newFakePrinterManager()
+	pm.addPrinter(&Printer{ID: printerID, Status: "online"})
+	failing := &failingPrinters{pm}
+	q := NewQueue(database, failing, nil, nil, &config.QueueConfig{MaxRetries: 1, WorkerCount: 1, RetryDelay: time.Millisecond, DeadLetter: true})
+
+	jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 2, SubmittedBy: "erp", RequestID: "order-1"})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	var dead []*DeadLetterJob
+	deadline := time.Now().Add(10 * time.Second)
+	for len(dead) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d was not dead-lettered, status %s", jobID, jobStatus(t, database, jobID))
+		}
+		time.Sleep(10 * time.Millisecond)
+		if dead, _, err = q.ListDeadLetterJobs("", 10, 0); err != nil {
+			t.Fatalf("list dead-letter jobs: %v", err)
+		}
+	}
+
+	got := dead[0]
+	if got.JobID != jobID || got.PrinterID != printerID || got.TSPLContent != "PRINT 1" || got.Copies != 2 || got.SubmittedBy != "erp" || got.RequestID != "order-1" {
+		t.Errorf("dead-letter snapshot = %+v, want job %d's fields", got, jobID)
+	}
+	if got.RetryCount != 1 {
+		t.Errorf("dead-letter retry count = %d, want 1", got.RetryCount)
+	}
+	if got.FailureReason != ErrConnectionFailed.Error() {
+		t.Errorf("failure reason = %q, want %q", got.FailureReason, ErrConnectionFailed.Error())
+	}
+	var remaining int
+	if err := database.QueryRow("SELECT COUNT(*) FROM print_jobs WHERE id = ?", jobID).Scan(&remaining); err != nil {
+		t.Fatalf("count jobs: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("job %d is still in print_jobs", jobID)
+	}
+
+	q.Stop()
+	ctx := logging.WithRequestID(context.Background(), "requeue-1")
+	newID, err := q.RequeueDeadLetterJob(ctx, got.ID)
+	if err != nil {
+		t.Fatalf("requeue: %v", err)
+	}
+	requeued, err := q.GetJob(newID)
+	if err != nil {
+		t.Fatalf("get requeued job: %v", err)
+	}
+	if requeued.Status != JobStatusPending || requeued.RetryCount != 0 || requeued.TSPLContent != "PRINT 1" || requeued.Copies != 2 {
+		t.Errorf("requeued job = %+v, want a pending copy with no retries", requeued)
+	}
+	var requestID string
+	if err := database.QueryRow("SELECT request_id FROM print_jobs WHERE id = ?", newID).Scan(&requestID); err != nil {
+		t.Fatalf("read request ID: %v", err)
+	}
+	if requestID != "requeue-1" {
+		t.Errorf("requeued job request ID = %q, want requeue-1", requestID)
+	}
+
+	if _, err := q.GetDeadLetterJob(got.ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("dead-letter job after requeue: err = %v, want ErrDeadLetterNotFound", err)
+	}
+	if _, err := q.RequeueDeadLetterJob(ctx, got.ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("second requeue: err = %v, want ErrDeadLetterNotFound", err)
+	}
+}
+
+func TestFailedJobStaysWithoutDeadLetter(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	pm := newFakePrinterManager()
+	pm.addPrinter(&Printer{ID: printerID, Status: "online"})
+	q := NewQueue(database, &failingPrinters{pm}, nil, nil, &config.QueueConfig{MaxRetries: 0, WorkerCount: 1, RetryDelay: time.Millisecond})
+
+	jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	waitForJobStatus(t, database, jobID, JobStatusFailed)
+
+	if _, total, err := q.ListDeadLetterJobs("", 10, 0); err != nil || total != 0 {
+		t.Errorf("dead-letter jobs = %d (err %v), want none with dead_letter off", total, err)
+	}
+}
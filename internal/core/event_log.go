@@ -0,0 +1,72 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultEventLogSize = 500
+
+// Event is a single job or printer lifecycle event kept for the recent
+// activity feed.
+type Event struct {
+	Seq       int64
+	Type      string
+	JobID     int64
+	PrinterID int64
+	Status    string
+	Message   string
+	Timestamp time.Time
+}
+
+// EventLog is a fixed-size, thread-safe ring buffer of recent events. Once
+// full, each new event overwrites the oldest one.
+type EventLog struct {
+	mu     sync.RWMutex
+	events []Event
+	next   int
+	count  int
+	seq    int64
+}
+
+func NewEventLog(size int) *EventLog {
+	if size <= 0 {
+		size = DefaultEventLogSize
+	}
+	return &EventLog{events: make([]Event, size)}
+}
+
+func (l *EventLog) Record(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	e.Seq = l.seq
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	l.events[l.next] = e
+	l.next = (l.next + 1) % len(l.events)
+	if l.count < len(l.events) {
+		l.count++
+	}
+}
+
+// Recent returns up to limit events, newest first. A limit of 0 or less
+// returns everything retained.
+func (l *EventLog) Recent(limit int) []Event {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if limit <= 0 || limit > l.count {
+		limit = l.count
+	}
+
+	result := make([]Event, 0, limit)
+	for i := 1; i <= limit; i++ {
+		idx := (l.next - i + len(l.events)) % len(l.events)
+		result = append(result, l.events[idx])
+	}
+	return result
+}
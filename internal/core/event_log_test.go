@@ -0,0 +1,82 @@
+package core
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestEventLogKeepsNewestEvents(t *testing.T) {
+	l := NewEventLog(3)
+	for id := int64(1); id <= 5; id++ {
+		l.Record(Event{Type: "job_completed", JobID: id})
+	}
+
+	events := l.Recent(0)
+	if len(events) != 3 {
+		t.Fatalf("%d events retained, want 3", len(events))
+	}
+	for i, want := range []int64{5, 4, 3} {
+		if events[i].JobID != want || events[i].Seq != want {
+			t.Errorf("event %d is job %d seq %d, want job %d seq %d", i, events[i].JobID, events[i].Seq, want, want)
+		}
+		if events[i].Timestamp.IsZero() {
+			t.Errorf("event %d has no timestamp", i)
+		}
+	}
+
+	if events := l.Recent(2); len(events) != 2 || events[0].JobID != 5 || events[1].JobID != 4 {
+		t.Errorf("Recent(2) returned %+v, want jobs 5 and 4", events)
+	}
+}
+
+func TestEventLogConcurrentRecord(t *testing.T) {
+	l := NewEventLog(DefaultEventLogSize)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.Record(Event{Type: "job_started"})
+				l.Recent(10)
+			}
+		}()
+	}
+	wg.Wait()
+
+	events := l.Recent(0)
+	if len(events) != DefaultEventLogSize {
+		t.Fatalf("%d events retained, want %d", len(events), DefaultEventLogSize)
+	}
+	if events[0].Seq != 800 {
+		t.Errorf("newest event has seq %d, want 800", events[0].Seq)
+	}
+}
+
+func TestQueueRecordsJobEvents(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	pm := newFakePrinterManager()
+	pm.addPrinter(&Printer{ID: printerID, Status: "online"})
+	q := NewQueue(database, pm, nil, nil, nil)
+	events := NewEventLog(10)
+	q.SetEventLog(events)
+
+	jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	job, err := q.Dequeue()
+	if err != nil || job == nil {
+		t.Fatalf("dequeue returned %v, %v", job, err)
+	}
+	q.processJob(job)
+
+	got := events.Recent(0)
+	if len(got) != 2 || got[0].Type != "job_completed" || got[1].Type != "job_started" {
+		t.Fatalf("events are %+v, want job_started then job_completed", got)
+	}
+	if got[0].JobID != jobID || got[0].PrinterID != printerID {
+		t.Errorf("completed event is for job %d on printer %d, want job %d on printer %d", got[0].JobID, got[0].PrinterID, jobID, printerID)
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import "image"
+
+// flipElement returns elem moved for a schema with FlipVertical or
+// FlipHorizontal set, or elem itself when neither is. The element's
+// bounding box is reflected across the label, so a box, bar, circle or
+// ellipse lands exactly where its mirror image would while text and codes
+// stay readable: y' = height - y for a point, and the far edge becomes the
+// near one for anything with a size.
+func (g *TSPL2Generator) flipElement(elem *LabelElement, variables map[string]string, schema *LabelSchema) *LabelElement {
+	if !schema.FlipVertical && !schema.FlipHorizontal {
+		return elem
+	}
+
+	dpi := schema.DPI
+	if dpi == 0 {
+		dpi = 203
+	}
+	bounds := g.elementExtent(elem, variables, schema, dpi)
+
+	var dx, dy int
+	if schema.FlipHorizontal {
+		dx = mmToDots(schema.WidthMM, dpi) - bounds.Max.X - bounds.Min.X
+	}
+	if schema.FlipVertical {
+		dy = mmToDots(schema.HeightMM, dpi) - bounds.Max.Y - bounds.Min.Y
+	}
+
+	flipped := *elem
+	switch flipped.Type {
+	case "box":
+		flipped.X += dx
+		flipped.Y += dy
+		flipped.XEnd += dx
+		flipped.YEnd += dy
+	case "line":
+		// X2 and Y2 are the bar's width and height, not a second point.
+		flipped.X1 += dx
+		flipped.Y1 += dy
+	default:
+		flipped.X += dx
+		flipped.Y += dy
+	}
+	return &flipped
+}
+
+// elementExtent returns the area elem covers in dots. Shapes and blocks
+// have a declared size; text, codes and images are measured by drawing
+// them as Lint does. An element that cannot be drawn is treated as the
+// point at its anchor.
+func (g *TSPL2Generator) elementExtent(elem *LabelElement, variables map[string]string, schema *LabelSchema, dpi int) image.Rectangle {
+	switch elem.Type {
+	case "box":
+		return image.Rect(elem.X, elem.Y, elem.XEnd, elem.YEnd)
+	case "line":
+		return image.Rect(elem.X1, elem.Y1, elem.X1+elem.X2, elem.Y1+elem.Y2)
+	case "circle":
+		return image.Rect(elem.X, elem.Y, elem.X+elem.Radius, elem.Y+elem.Radius)
+	case "ellipse":
+		return image.Rect(elem.X, elem.Y, elem.X+elem.XRadius, elem.Y+elem.YRadius)
+	case "block":
+		return image.Rect(elem.X, elem.Y, elem.X+elem.Width, elem.Y+elem.Height)
+	}
+
+	var inked image.Rectangle
+	canvas := &labelCanvas{img: image.NewGray(image.Rectangle{}), inked: &inked}
+	if err := g.renderElement(canvas, elem, variables, schema, dpi); err != nil || inked.Empty() {
+		return image.Rectangle{Min: image.Pt(elem.X, elem.Y), Max: image.Pt(elem.X, elem.Y)}
+	}
+	return inked
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFlipElements(t *testing.T) {
+	// A 50 x 30 mm label at 203 dpi is 399 x 239 dots.
+	elements := []LabelElement{
+		{Type: "box", X: 10, Y: 20, XEnd: 110, YEnd: 60, Thickness: 2},
+		{Type: "line", X1: 20, Y1: 100, X2: 200, Y2: 3},
+		{Type: "circle", X: 300, Y: 10, Radius: 50},
+		{Type: "ellipse", X: 200, Y: 150, XRadius: 80, YRadius: 40},
+		{Type: "text", X: 30, Y: 150, Content: "FLIP"},
+	}
+
+	tests := []struct {
+		name       string
+		vertical   bool
+		horizontal bool
+		want       []string
+	}{
+		{"unflipped", false, false, []string{
+			"BOX 10,20,110,60,2", "BAR 20,100,200,3,1", "CIRCLE 300,10,50,1", "ELLIPSE 200,150,80,40,1",
+		}},
+		{"vertical", true, false, []string{
+			"BOX 10,179,110,219,2", "BAR 20,136,200,3,1", "CIRCLE 300,179,50,1", "ELLIPSE 200,49,80,40,1",
+		}},
+		{"horizontal", false, true, []string{
+			"BOX 289,20,389,60,2", "BAR 179,100,200,3,1", "CIRCLE 49,10,50,1", "ELLIPSE 119,150,80,40,1",
+		}},
+		{"both", true, true, []string{
+			"BOX 289,179,389,219,2", "BAR 179,136,200,3,1", "CIRCLE 49,179,50,1", "ELLIPSE 119,49,80,40,1",
+		}},
+	}
+
+	g := NewTSPL2Generator()
+	plain := &LabelSchema{WidthMM: 50, HeightMM: 30, DPI: 203, Elements: elements}
+	text := g.elementExtent(&elements[4], nil, plain, 203)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &LabelSchema{WidthMM: 50, HeightMM: 30, DPI: 203, Elements: elements,
+				FlipVertical: tt.vertical, FlipHorizontal: tt.horizontal}
+			tspl, err := g.Generate(schema, nil)
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(tspl, "\n"+line+"\n") {
+					t.Errorf("TSPL has no %q line:\n%s", line, tspl)
+				}
+			}
+
+			// Text keeps its orientation; its measured bounds are reflected.
+			wantX, wantY := text.Min.X, text.Min.Y
+			if tt.horizontal {
+				wantX = 399 - text.Max.X
+			}
+			if tt.vertical {
+				wantY = 239 - text.Max.Y
+			}
+			flipped := g.elementExtent(g.flipElement(&elements[4], nil, schema), nil, schema, 203)
+			if flipped.Min.X != wantX || flipped.Min.Y != wantY || flipped.Size() != text.Size() {
+				t.Errorf("text bounds = %v, want %v at (%d,%d)", flipped, text.Size(), wantX, wantY)
+			}
+			wantText := fmt.Sprintf("TEXT %d,%d,", elements[4].X+wantX-text.Min.X, elements[4].Y+wantY-text.Min.Y)
+			if !strings.Contains(tspl, "\n"+wantText) {
+				t.Errorf("TSPL has no %q line:\n%s", wantText, tspl)
+			}
+		})
+	}
+
+	if elements[0].Y != 20 || elements[1].Y1 != 100 {
+		t.Error("flipping changed the schema's own elements")
+	}
+}
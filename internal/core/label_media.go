@@ -0,0 +1,67 @@
+package core
+
+import "fmt"
+
+// Label media types. Gap media has a gap between labels that the sensor
+// sees through, bline media a black mark printed on the back of each, and
+// continuous media neither, so each label is fed to the tear bar.
+const (
+	LabelMediaGap        = "gap"
+	LabelMediaBline      = "bline"
+	LabelMediaContinuous = "continuous"
+)
+
+// ValidateLabelMedia checks a schema's media type against the lengths set
+// for it. gap_mm only applies to gap media and bline_mm, which is
+// required, only to bline media. Continuous media has no marks to offset
+// from, so it takes none of the three. An empty media type is gap.
+func ValidateLabelMedia(mediaType string, gapMM, blineMM, offsetMM float64) error {
+	if gapMM < 0 || blineMM < 0 || offsetMM < 0 {
+		return fmt.Errorf("gap_mm, bline_mm and offset_mm cannot be negative")
+	}
+	switch mediaType {
+	case "", LabelMediaGap:
+		if blineMM != 0 {
+			return fmt.Errorf("bline_mm only applies to bline media, not %s", LabelMediaGap)
+		}
+	case LabelMediaBline:
+		if blineMM == 0 {
+			return fmt.Errorf("bline_mm is required for bline media")
+		}
+		if gapMM != 0 {
+			return fmt.Errorf("gap_mm only applies to gap media, not %s", LabelMediaBline)
+		}
+	case LabelMediaContinuous:
+		if gapMM != 0 || blineMM != 0 || offsetMM != 0 {
+			return fmt.Errorf("continuous media takes no gap_mm, bline_mm or offset_mm")
+		}
+	default:
+		return fmt.Errorf("invalid media_type %q (valid: gap, bline, continuous)", mediaType)
+	}
+	return nil
+}
+
+// mediaCommands returns the GAP or BLINE line for a schema, with lengths
+// in millimetres, or in dots at dpi if dpi is not zero. Continuous media is
+// GAP 0 with the printer set to feed each label to the tear bar.
+func mediaCommands(schema *LabelSchema, dpi int) (string, error) {
+	if err := ValidateLabelMedia(schema.MediaType, schema.GapMM, schema.BlineMM, schema.OffsetMM); err != nil {
+		return "", err
+	}
+
+	length := func(mm float64) string { return FormatMM(mm) + " mm" }
+	sep := ", "
+	if dpi != 0 {
+		length = func(mm float64) string { return fmt.Sprintf("%d dot", mmToDots(mm, dpi)) }
+		sep = ","
+	}
+
+	switch schema.MediaType {
+	case LabelMediaBline:
+		return "BLINE " + length(schema.BlineMM) + sep + length(schema.OffsetMM) + "\n", nil
+	case LabelMediaContinuous:
+		return "GAP " + length(0) + sep + length(0) + "\nSET TEAR ON\n", nil
+	default:
+		return "GAP " + length(schema.GapMM) + sep + length(schema.OffsetMM) + "\n", nil
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMediaCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema LabelSchema
+		want   string
+		absent string
+	}{
+		{"default is gap", LabelSchema{GapMM: 2}, "GAP 2 mm, 0 mm\n", "BLINE"},
+		{"gap", LabelSchema{MediaType: LabelMediaGap, GapMM: 3, OffsetMM: 0.5}, "GAP 3 mm, 0.5 mm\n", "BLINE"},
+		{"bline", LabelSchema{MediaType: LabelMediaBline, BlineMM: 3, OffsetMM: 1}, "BLINE 3 mm, 1 mm\n", "GAP"},
+		{"continuous", LabelSchema{MediaType: LabelMediaContinuous}, "GAP 0 mm, 0 mm\nSET TEAR ON\n", "BLINE"},
+	}
+
+	g := NewTSPL2Generator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := tt.schema
+			schema.WidthMM, schema.HeightMM, schema.DPI = 50, 30, 203
+			schema.Elements = []LabelElement{{Type: "text", X: 10, Y: 10, Content: "A"}}
+
+			tspl, err := g.Generate(&schema, nil)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if !strings.Contains(tspl, "SIZE 50 mm, 30 mm\n"+tt.want) {
+				t.Errorf("TSPL does not set up the media with %q:\n%s", tt.want, tspl)
+			}
+			if strings.Contains(tspl, tt.absent) {
+				t.Errorf("TSPL contains %s:\n%s", tt.absent, tspl)
+			}
+		})
+	}
+}
+
+func TestMediaCommandsInDots(t *testing.T) {
+	schema := &LabelSchema{WidthMM: 50, HeightMM: 30, DPI: 203, MediaType: LabelMediaBline, BlineMM: 3, OffsetMM: 1,
+		Elements: []LabelElement{{Type: "text", X: 10, Y: 10, Content: "A"}}}
+
+	tspl, err := NewTSPL2Generator().GenerateWithDotCoordinates(schema, nil, true)
+	if err != nil {
+		t.Fatalf("GenerateWithDotCoordinates: %v", err)
+	}
+	if !strings.Contains(tspl, "BLINE 23 dot,7 dot\n") {
+		t.Errorf("TSPL does not set up bline media in dots:\n%s", tspl)
+	}
+}
+
+func TestValidateLabelMedia(t *testing.T) {
+	tests := []struct {
+		mediaType          string
+		gap, bline, offset float64
+		want               string
+	}{
+		{"", 2, 0, 0, ""},
+		{LabelMediaGap, 2, 0, 1, ""},
+		{LabelMediaBline, 0, 3, 1, ""},
+		{LabelMediaContinuous, 0, 0, 0, ""},
+		{LabelMediaGap, 2, 3, 0, "bline_mm only applies to bline media"},
+		{LabelMediaBline, 0, 0, 0, "bline_mm is required"},
+		{LabelMediaBline, 2, 3, 0, "gap_mm only applies to gap media"},
+		{LabelMediaContinuous, 2, 0, 0, "continuous media takes no"},
+		{LabelMediaGap, -1, 0, 0, "cannot be negative"},
+		{"black_mark", 0, 3, 0, "invalid media_type"},
+	}
+	for _, tt := range tests {
+		err := ValidateLabelMedia(tt.mediaType, tt.gap, tt.bline, tt.offset)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%q gap %v bline %v offset %v: %v, want valid", tt.mediaType, tt.gap, tt.bline, tt.offset, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%q gap %v bline %v offset %v: %v, want %q", tt.mediaType, tt.gap, tt.bline, tt.offset, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package core
+
+import (
+	"bytes"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"unicode/utf8"
+)
+
+// Cell size of renderFont glyphs, including spacing.
+const (
+	renderCellWidth  = 6
+	renderCellHeight = 8
+)
+
+// Placeholder size in dots for images that live on the printer.
+const renderPrinterImageSize = 64
+
+// dataMatrixSizes lists ECC 200 square symbol sizes with their data
+// capacity in bytes, used to size DataMatrix placeholders.
+var dataMatrixSizes = [][2]int{
+	{10, 3}, {12, 5}, {14, 8}, {16, 12}, {18, 18}, {20, 22}, {22, 30}, {24, 36},
+	{26, 44}, {32, 62}, {36, 86}, {40, 114}, {44, 144}, {48, 174}, {52, 204},
+	{64, 280}, {72, 368}, {80, 456}, {88, 576}, {96, 696}, {104, 816},
+	{120, 1050}, {132, 1304}, {144, 1558},
+}
+
+// labelCanvas is a white label that elements are drawn onto in printer
+// dots. When inked is set it is grown to cover every dot drawn, including
+// dots that fall outside the label.
+type labelCanvas struct {
+	img   *image.Gray
+	inked *image.Rectangle
+}
+
+func newLabelCanvas(width, height int) *labelCanvas {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	return &labelCanvas{img: img}
+}
+
+// rotate maps offset (dx, dy) from the anchor (x, y) in a frame rotated
+// clockwise by rotation degrees, as TSPL rotates elements about their
+// reference point.
+func rotate(x, y, dx, dy, rotation int) (int, int) {
+	switch rotation {
+	case 90:
+		return x - dy, y + dx
+	case 180:
+		return x - dx, y - dy
+	case 270:
+		return x + dy, y - dx
+	default:
+		return x + dx, y + dy
+	}
+}
+
+func (c *labelCanvas) set(x, y, dx, dy, rotation int, v uint8) {
+	x, y = rotate(x, y, dx, dy, rotation)
+	if c.inked != nil && v == 0 {
+		*c.inked = c.inked.Union(image.Rect(x, y, x+1, y+1))
+	}
+	if image.Pt(x, y).In(c.img.Rect) {
+		c.img.SetGray(x, y, color.Gray{Y: v})
+	}
+}
+
+// plot blackens the dot at offset (dx, dy) from the anchor (x, y).
+func (c *labelCanvas) plot(x, y, dx, dy, rotation int) {
+	c.set(x, y, dx, dy, rotation, 0)
+}
+
+func (c *labelCanvas) fill(x, y, dx, dy, width, height, rotation int) {
+	c.paint(x, y, dx, dy, width, height, rotation, 0)
+}
+
+func (c *labelCanvas) paint(x, y, dx, dy, width, height, rotation int, v uint8) {
+	for j := dy; j < dy+height; j++ {
+		for i := dx; i < dx+width; i++ {
+			c.set(x, y, i, j, rotation, v)
+		}
+	}
+}
+
+func (c *labelCanvas) outline(x, y, width, height, thickness int) {
+	c.fill(x, y, 0, 0, width, thickness, 0)
+	c.fill(x, y, 0, height-thickness, width, thickness, 0)
+	c.fill(x, y, 0, 0, thickness, height, 0)
+	c.fill(x, y, width-thickness, 0, thickness, height, 0)
+}
+
+// ring draws an ellipse outline inside the box at (x, y) of the given size.
+func (c *labelCanvas) ring(x, y, width, height, thickness int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	rx, ry := float64(width)/2, float64(height)/2
+	irx, iry := rx-float64(thickness), ry-float64(thickness)
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			px, py := float64(i)+0.5-rx, float64(j)+0.5-ry
+			if px*px/(rx*rx)+py*py/(ry*ry) > 1 {
+				continue
+			}
+			if irx > 0 && iry > 0 && px*px/(irx*irx)+py*py/(iry*iry) < 1 {
+				continue
+			}
+			c.plot(x, y, i, j, 0)
+		}
+	}
+}
+
+// text draws a single line starting at offset (dx, dy) from the anchor,
+// scaling each glyph to a cellWidth x cellHeight character cell.
+func (c *labelCanvas) text(x, y, dx, dy int, s string, cellWidth, cellHeight, rotation int) {
+	if cellWidth <= 0 || cellHeight <= 0 {
+		return
+	}
+	col := 0
+	for _, r := range s {
+		glyph := renderGlyph(r)
+		for j := 0; j < cellHeight; j++ {
+			row := j * renderCellHeight / cellHeight
+			for i := 0; i < cellWidth; i++ {
+				gx := i * renderCellWidth / cellWidth
+				if gx < len(glyph) && glyph[gx]&(1<<uint(row)) != 0 {
+					c.plot(x, y, dx+col*cellWidth+i, dy+j, rotation)
+				}
+			}
+		}
+		col++
+	}
+}
+
+// patternBits returns n deterministic pseudo-random bits derived from
+// content, standing in for encoded symbol data.
+func patternBits(content string, n int) []bool {
+	h := fnv.New64a()
+	h.Write([]byte(content))
+	state := h.Sum64() | 1
+	bits := make([]bool, n)
+	for i := range bits {
+		state ^= state << 13
+		state ^= state >> 7
+		state ^= state << 17
+		bits[i] = state&1 == 1
+	}
+	return bits
+}
+
+// pattern fills a columns x rows grid of modules with patternBits.
+func (c *labelCanvas) pattern(x, y, columns, rows, module int, content string, rotation int) {
+	for i, on := range patternBits(content, columns*rows) {
+		if on {
+			c.fill(x, y, (i%columns)*module, (i/columns)*module, module, module, rotation)
+		}
+	}
+}
+
+// Render rasterizes a label at the schema DPI, white background with black
+// dots. It draws what the commands from Generate would print: shapes use the
+// same parameters, text uses a built-in font scaled to the printer font's
+// character cell, and QR codes are encoded for real, so the preview scans.
+// Other barcodes and 2D symbols are drawn as placeholders of roughly the
+// right size rather than scannable codes. Labels that Generate rejects fail
+// here with the same error.
+func (g *TSPL2Generator) Render(schema *LabelSchema, variables map[string]string) (*image.Gray, error) {
+	if _, err := g.Generate(schema, variables); err != nil {
+		return nil, err
+	}
+
+	dpi := schema.DPI
+	if dpi == 0 {
+		dpi = 203
+	}
+	canvas := newLabelCanvas(mmToDots(schema.WidthMM, dpi), mmToDots(schema.HeightMM, dpi))
+
+	for i := range schema.Elements {
+		elem := g.flipElement(&schema.Elements[i], variables, schema)
+		if err := g.renderElement(canvas, elem, variables, schema, dpi); err != nil {
+			return nil, err
+		}
+	}
+
+	return canvas.img, nil
+}
+
+// RenderPNG renders the label with Render and encodes it as a PNG.
+func (g *TSPL2Generator) RenderPNG(schema *LabelSchema, variables map[string]string) ([]byte, error) {
+	img, err := g.Render(schema, variables)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *TSPL2Generator) renderElement(c *labelCanvas, elem *LabelElement, variables map[string]string, schema *LabelSchema, dpi int) error {
+	if !g.elementVisible(elem, variables, schema) {
+		return nil
+	}
+
+	thickness := elem.Thickness
+	if thickness == 0 {
+		thickness = 1
+	}
+
+	switch elem.Type {
+	case "text":
+		content, err := g.substituteVariables(elem.Content, variables, schema)
+		if err != nil {
+			return err
+		}
+		font, xScale, yScale := renderFontParams(elem)
+		cellWidth, cellHeight := blockMetrics(font, xScale, yScale, dpi)
+		for i, line := range strings.Split(content, "\n") {
+			c.text(elem.X, elem.Y, 0, i*cellHeight, line, cellWidth, cellHeight, elem.Rotation)
+		}
+
+	case "block":
+		content, err := g.substituteVariables(elem.Content, variables, schema)
+		if err != nil {
+			return err
+		}
+		font, xScale, yScale := renderFontParams(elem)
+		content, xScale, yScale, err = applyBlockOverflow(elem, content, font, xScale, yScale, dpi)
+		if err != nil {
+			return err
+		}
+		cellWidth, cellHeight := blockMetrics(font, xScale, yScale, dpi)
+		if cellWidth <= 0 || cellHeight <= 0 {
+			return nil
+		}
+		for i, line := range wrapBlockText(content, elem.Width/cellWidth) {
+			top := i * (cellHeight + elem.Spacing)
+			if top+cellHeight > elem.Height {
+				break
+			}
+			c.text(elem.X, elem.Y, 0, top, line, cellWidth, cellHeight, elem.Rotation)
+		}
+
+	case "barcode":
+		content, err := g.substituteVariables(elem.Content, variables, schema)
+		if err != nil {
+			return err
+		}
+		height := elem.Height
+		if height == 0 {
+			height = 80
+		}
+		narrow := elem.Narrow
+		if narrow == 0 {
+			narrow = 2
+		}
+		// Code 128 width: 11 modules per character plus start, check and
+		// stop symbols and quiet zones.
+		modules := 11*utf8.RuneCountInString(content) + 35
+		for m, on := range patternBits(content, modules) {
+			if on {
+				c.fill(elem.X, elem.Y, m*narrow, 0, narrow, height, elem.Rotation)
+			}
+		}
+		// Human-readable text goes under the bars, aligned within their width.
+		if elem.HumanReadable > 0 {
+			cellWidth, cellHeight := blockMetrics("2", 1, 1, dpi)
+			dx := 0
+			switch slack := modules*narrow - cellWidth*utf8.RuneCountInString(content); elem.HumanReadable {
+			case 2:
+				dx = slack / 2
+			case 3:
+				dx = slack
+			}
+			c.text(elem.X, elem.Y, dx, height, content, cellWidth, cellHeight, elem.Rotation)
+		}
+
+	case "qrcode":
+		content, err := g.substituteVariables(elem.Content, variables, schema)
+		if err != nil {
+			return err
+		}
+		fill := func(dx, dy, width, height int) {
+			c.fill(elem.X, elem.Y, dx, dy, width, height, elem.Rotation)
+		}
+		if err := drawQRCode(elem, content, fill); err != nil {
+			return err
+		}
+
+	case "datamatrix":
+		content, err := g.substituteVariables(elem.Content, variables, schema)
+		if err != nil {
+			return err
+		}
+		module := elem.ModuleSize
+		if module == 0 {
+			module = 2
+		}
+		size := dataMatrixSizes[len(dataMatrixSizes)-1][0]
+		for _, s := range dataMatrixSizes {
+			if len(content) <= s[1] {
+				size = s[0]
+				break
+			}
+		}
+		c.pattern(elem.X, elem.Y, size, size, module, content, elem.Rotation)
+		c.fill(elem.X, elem.Y, 0, 0, module, size*module, elem.Rotation)
+		c.fill(elem.X, elem.Y, 0, (size-1)*module, size*module, module, elem.Rotation)
+
+	case "pdf417":
+		content, err := g.substituteVariables(elem.Content, variables, schema)
+		if err != nil {
+			return err
+		}
+		columns := elem.Columns
+		if columns == 0 {
+			columns = 3
+		}
+		module := elem.ModuleSize
+		if module == 0 {
+			module = 2
+		}
+		rows := elem.Rows
+		if rows == 0 {
+			rows = (len(content)/2 + columns) / columns
+			if rows < 3 {
+				rows = 3
+			}
+		}
+		// Each row is start and stop patterns, two row indicators and a
+		// 17-module codeword per data column, three modules high.
+		width := 17*columns + 69
+		c.pattern(elem.X, elem.Y, width, rows*3, module, content, elem.Rotation)
+
+	case "box":
+		c.outline(elem.X, elem.Y, elem.XEnd-elem.X, elem.YEnd-elem.Y, thickness)
+
+	case "line":
+		// Lines are sent as BAR x1,y1,x2,y2, which the printer draws as a
+		// filled bar x2 dots wide and y2 dots high.
+		c.fill(elem.X1, elem.Y1, 0, 0, elem.X2, elem.Y2, 0)
+
+	case "circle":
+		c.ring(elem.X, elem.Y, elem.Radius, elem.Radius, thickness)
+
+	case "ellipse":
+		c.ring(elem.X, elem.Y, elem.XRadius, elem.YRadius, thickness)
+
+	case "image":
+		if elem.ImageData == "" && !elem.Embed {
+			c.outline(elem.X, elem.Y, renderPrinterImageSize, renderPrinterImageSize, 1)
+			for i := 0; i < renderPrinterImageSize; i++ {
+				c.plot(elem.X, elem.Y, i, i, 0)
+				c.plot(elem.X, elem.Y, renderPrinterImageSize-1-i, i, 0)
+			}
+			return nil
+		}
+		threshold := elem.Threshold
+		if threshold == 0 {
+			threshold = defaultBitmapThreshold
+		}
+		img, err := loadElementImage(elem)
+		if err != nil {
+			return err
+		}
+		widthBytes, height, data := imageToBitmap(img, threshold, elem.Dither)
+		for j := 0; j < height; j++ {
+			for i := 0; i < widthBytes*8; i++ {
+				if data[j*widthBytes+i/8]&(0x80>>uint(i%8)) == 0 {
+					c.plot(elem.X, elem.Y, i, j, 0)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func renderFontParams(elem *LabelElement) (font string, xScale, yScale int) {
+	font = elem.Font
+	if font == "" {
+		font = "3"
+	}
+	xScale = elem.XScale
+	if xScale == 0 {
+		xScale = 1
+	}
+	yScale = elem.YScale
+	if yScale == 0 {
+		yScale = 1
+	}
+	return font, xScale, yScale
+}
+
+// wrapBlockText splits content into the lines a block prints at cols
+// characters per line, wrapping the same way blockLineCount counts.
+func wrapBlockText(content string, cols int) []string {
+	if cols < 1 {
+		return nil
+	}
+	var lines []string
+	for _, para := range strings.Split(content, "\n") {
+		var line []rune
+		for _, word := range strings.Fields(para) {
+			w := []rune(word)
+			if len(line) > 0 && len(line)+1+len(w) <= cols {
+				line = append(append(line, ' '), w...)
+				continue
+			}
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+			}
+			for len(w) > cols {
+				lines = append(lines, string(w[:cols]))
+				w = w[cols:]
+			}
+			line = w
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
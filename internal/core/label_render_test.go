@@ -0,0 +1,65 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderQRCodeDecodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		elem      LabelElement
+		variables map[string]string
+		want      string
+	}{
+		{"variable content", LabelElement{Content: "{{sku}}", Level: "Q", CellWidth: 3}, map[string]string{"sku": "ABC123"}, "ABC123"},
+		{"default level and cell width", LabelElement{Content: "https://example.com/labels?id=42"}, nil, "https://example.com/labels?id=42"},
+		{"numeric", LabelElement{Content: "0042", Level: "H", CellWidth: 2}, nil, "0042"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elem := tt.elem
+			elem.Type = "qrcode"
+			elem.X, elem.Y = 240, 240
+			schema := &LabelSchema{WidthMM: 80, HeightMM: 80, DPI: 203, Elements: []LabelElement{elem}}
+
+			img, err := NewTSPL2Generator().Render(schema, tt.variables)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			got, err := decodeQR(img)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			if got.content != tt.want {
+				t.Errorf("decoded %q, want %q", got.content, tt.want)
+			}
+			if level := qrLevel(&elem); got.level != level {
+				t.Errorf("level %s, want %s", got.level, level)
+			}
+			cellWidth := elem.CellWidth
+			if cellWidth == 0 {
+				cellWidth = 4
+			}
+			if got.module != cellWidth {
+				t.Errorf("module is %d dots, want the cell width %d", got.module, cellWidth)
+			}
+		})
+	}
+}
+
+func TestRenderRejectsQRCodeOverCapacity(t *testing.T) {
+	content := strings.Repeat("a", qrCapacity["H"][symbolModeByte]+1)
+	schema := &LabelSchema{WidthMM: 100, HeightMM: 100, DPI: 203, Elements: []LabelElement{
+		{Type: "qrcode", Content: content, Level: "H", CellWidth: 1},
+	}}
+	if _, err := NewTSPL2Generator().Render(schema, nil); err == nil {
+		t.Fatal("rendered a QR code over the level H capacity, want an error")
+	}
+
+	schema.Elements[0].Level = "L"
+	if _, err := NewTSPL2Generator().Render(schema, nil); err != nil {
+		t.Fatalf("render the same content at level L: %v", err)
+	}
+}
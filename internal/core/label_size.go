@@ -0,0 +1,43 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Label size check modes. Warn accepts a job whose template does not match
+// its printer's labels but says so; strict rejects it.
+const (
+	LabelSizeCheckOff    = "off"
+	LabelSizeCheckWarn   = "warn"
+	LabelSizeCheckStrict = "strict"
+)
+
+var ErrLabelSizeMismatch = errors.New("template size does not match the printer's labels")
+
+// CheckLabelSize compares a template's size with the labels its printer is
+// configured for. A difference beyond label_size_tolerance_mm in either
+// dimension is returned as a warning, or in strict mode as an error
+// wrapping ErrLabelSizeMismatch. Sizes of 0 are unknown and never mismatch.
+func (q *Queue) CheckLabelSize(templateWidthMM, templateHeightMM, labelWidthMM, labelHeightMM float64) (string, error) {
+	mode := q.config.LabelSizeCheck
+	if mode == LabelSizeCheckOff {
+		return "", nil
+	}
+
+	tolerance := q.config.LabelSizeToleranceMM
+	mismatch := func(template, label float64) bool {
+		return template > 0 && label > 0 && math.Abs(template-label) > tolerance
+	}
+	if !mismatch(templateWidthMM, labelWidthMM) && !mismatch(templateHeightMM, labelHeightMM) {
+		return "", nil
+	}
+
+	msg := fmt.Sprintf("template is %gx%g mm but the printer's labels are %gx%g mm",
+		templateWidthMM, templateHeightMM, labelWidthMM, labelHeightMM)
+	if mode == LabelSizeCheckStrict {
+		return "", fmt.Errorf("%w: %s", ErrLabelSizeMismatch, msg)
+	}
+	return msg, nil
+}
@@ -0,0 +1,70 @@
+package core
+
+import "time"
+
+// Barcode scanners sometimes fire the same legacy /print/:layout/:uid
+// request twice within milliseconds. For legacy_dedup_window after a job
+// is enqueued for a layout and uid, repeats get that job instead of
+// printing a second label.
+
+type legacyKey struct {
+	layout string
+	uid    string
+}
+
+// legacyJob is the job most recently enqueued for a legacyKey.
+type legacyJob struct {
+	jobID     int64
+	printerID int64
+	at        time.Time
+}
+
+// EnqueueLegacy enqueues job for a legacy print of layout and uid, unless
+// one was enqueued for them within the dedup window. Then job is not
+// enqueued: its ID and PrinterID are set to the earlier job's, and
+// duplicate is true. When admit is not nil it is called only before a new
+// job is enqueued, so duplicates skip it, and its error is returned without
+// enqueuing anything.
+func (q *Queue) EnqueueLegacy(layout, uid string, job *Job, admit func() error) (id int64, duplicate bool, err error) {
+	window := q.config.LegacyDedupWindow
+	if window <= 0 {
+		if admit != nil {
+			if err := admit(); err != nil {
+				return 0, false, err
+			}
+		}
+		id, err = q.Enqueue(job)
+		return id, false, err
+	}
+
+	// The lock is held across Enqueue so that two requests arriving
+	// together cannot both miss and both enqueue.
+	q.dedupMu.Lock()
+	defer q.dedupMu.Unlock()
+
+	now := q.now()
+	for key, recent := range q.legacyJobs {
+		if now.Sub(recent.at) >= window {
+			delete(q.legacyJobs, key)
+		}
+	}
+
+	key := legacyKey{layout: layout, uid: uid}
+	if recent, ok := q.legacyJobs[key]; ok {
+		job.ID = recent.jobID
+		job.PrinterID = recent.printerID
+		return recent.jobID, true, nil
+	}
+
+	if admit != nil {
+		if err := admit(); err != nil {
+			return 0, false, err
+		}
+	}
+	id, err = q.Enqueue(job)
+	if err != nil {
+		return 0, false, err
+	}
+	q.legacyJobs[key] = legacyJob{jobID: id, printerID: job.PrinterID, at: now}
+	return id, false, nil
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+func TestEnqueueLegacyDedupsWithinWindow(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	q := NewQueue(database, nil, nil, nil, &config.QueueConfig{WorkerCount: 1, LegacyDedupWindow: 2 * time.Second})
+	now := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	q.SetClock(func() time.Time { return now })
+
+	enqueue := func(uid string) (int64, bool) {
+		t.Helper()
+		id, duplicate, err := q.EnqueueLegacy("shelf", uid, &Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1}, nil)
+		if err != nil {
+			t.Fatalf("enqueue %s: %v", uid, err)
+		}
+		return id, duplicate
+	}
+
+	first, duplicate := enqueue("SCAN-1")
+	if duplicate {
+		t.Fatal("first scan reported as a duplicate")
+	}
+
+	now = now.Add(50 * time.Millisecond)
+	if id, duplicate := enqueue("SCAN-1"); !duplicate || id != first {
+		t.Errorf("rapid repeat gave job %d (duplicate %v), want job %d as a duplicate", id, duplicate, first)
+	}
+	if _, duplicate := enqueue("SCAN-2"); duplicate {
+		t.Error("a different uid was deduplicated")
+	}
+
+	now = now.Add(2 * time.Second)
+	if id, duplicate := enqueue("SCAN-1"); duplicate || id == first {
+		t.Errorf("repeat after the window gave job %d (duplicate %v), want a new job", id, duplicate)
+	}
+
+	var jobs int
+	if err := database.QueryRow("SELECT COUNT(*) FROM print_jobs").Scan(&jobs); err != nil {
+		t.Fatalf("count jobs: %v", err)
+	}
+	if jobs != 3 {
+		t.Errorf("%d jobs enqueued, want 3", jobs)
+	}
+}
+
+func TestEnqueueLegacyWithoutWindow(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	q := NewQueue(database, nil, nil, nil, &config.QueueConfig{WorkerCount: 1})
+
+	for i := 0; i < 2; i++ {
+		if _, duplicate, err := q.EnqueueLegacy("shelf", "SCAN-1", &Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1}, nil); err != nil || duplicate {
+			t.Fatalf("enqueue %d: duplicate %v, err %v; want every request enqueued", i, duplicate, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// postCommandRule allows one form of post command. Settings configure how
+// the label is printed, so they are sent before PRINT; the rest act on the
+// printed label and follow it.
+type postCommandRule struct {
+	pattern *regexp.Regexp
+	setting bool
+}
+
+var postCommandRules = []postCommandRule{
+	{regexp.MustCompile(`^SET (CUTTER|PARTIAL_CUTTER) (OFF|BATCH|[0-9]+)$`), true},
+	{regexp.MustCompile(`^SET (PEEL|TEAR) (ON|OFF)$`), true},
+	{regexp.MustCompile(`^CUT$`), false},
+	{regexp.MustCompile(`^(FEED|BACKFEED) [0-9]+$`), false},
+}
+
+// normalizePostCommand upper-cases a command and collapses its whitespace,
+// so "set cutter  1" is sent as "SET CUTTER 1".
+func normalizePostCommand(command string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(command), " "))
+}
+
+func matchPostCommand(command string) (postCommandRule, bool) {
+	for _, rule := range postCommandRules {
+		if rule.pattern.MatchString(command) {
+			return rule, true
+		}
+	}
+	return postCommandRule{}, false
+}
+
+// ValidatePostCommands checks a template's post commands against the
+// allowlist: SET CUTTER, SET PARTIAL_CUTTER, SET PEEL, SET TEAR, CUT, FEED
+// and BACKFEED.
+func ValidatePostCommands(commands []string) error {
+	for i, command := range commands {
+		if _, ok := matchPostCommand(normalizePostCommand(command)); !ok {
+			return fmt.Errorf("post_commands[%d] %q is not an allowed command", i, command)
+		}
+	}
+	return nil
+}
+
+// postCommandLines splits a schema's post commands into the settings sent
+// with the label setup and the commands sent after each PRINT.
+func postCommandLines(commands []string) (settings, trailer string, err error) {
+	if err := ValidatePostCommands(commands); err != nil {
+		return "", "", err
+	}
+	var s, t strings.Builder
+	for _, command := range commands {
+		command = normalizePostCommand(command)
+		rule, _ := matchPostCommand(command)
+		if rule.setting {
+			s.WriteString(command + "\n")
+		} else {
+			t.WriteString(command + "\n")
+		}
+	}
+	return s.String(), t.String(), nil
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostCommandsAutoCut(t *testing.T) {
+	schema := &LabelSchema{
+		WidthMM: 50, HeightMM: 30, DPI: 203,
+		Elements:     []LabelElement{{Type: "text", X: 10, Y: 10, Content: "A"}},
+		PostCommands: []string{"set cutter 1", "CUT"},
+	}
+	g := NewTSPL2Generator()
+
+	tspl, err := g.Generate(schema, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if want := "DIRECTION 0,0\nSET CUTTER 1\nCLS\n"; !strings.Contains(tspl, want) {
+		t.Errorf("TSPL is %q, want SET CUTTER in the setup: %q", tspl, want)
+	}
+	if !strings.HasSuffix(tspl, "PRINT 1\nCUT\n") {
+		t.Errorf("TSPL is %q, want CUT after PRINT", tspl)
+	}
+
+	multi, err := g.GenerateMultiLabel(schema, []map[string]string{{}, {}}, 2)
+	if err != nil {
+		t.Fatalf("generate multi-label: %v", err)
+	}
+	if got := strings.Count(multi, "SET CUTTER 1\n"); got != 1 {
+		t.Errorf("multi-label TSPL sets the cutter %d times, want once", got)
+	}
+	if got := strings.Count(multi, "PRINT 2\nCUT\n"); got != 2 {
+		t.Errorf("multi-label TSPL cuts after %d labels, want 2", got)
+	}
+}
+
+func TestValidatePostCommands(t *testing.T) {
+	allowed := []string{"SET CUTTER BATCH", "SET PARTIAL_CUTTER 5", "SET PEEL ON", "set tear off", "CUT", "FEED 40", "BACKFEED 40"}
+	if err := ValidatePostCommands(allowed); err != nil {
+		t.Errorf("allowed commands rejected: %v", err)
+	}
+	for _, command := range []string{"", "SET CUTTER", "SET CUTTER -1", "CUT\nPRINT 5", "PRINT 1", "KILL \"*\"", "FEED"} {
+		if err := ValidatePostCommands([]string{command}); err == nil {
+			t.Errorf("ValidatePostCommands(%q) succeeded, want an error", command)
+		}
+	}
+
+	schema := &LabelSchema{WidthMM: 50, HeightMM: 30, PostCommands: []string{"FORMFEED"}}
+	if _, err := NewTSPL2Generator().Generate(schema, nil); err == nil {
+		t.Error("generate with a disallowed post command succeeded, want an error")
+	}
+}
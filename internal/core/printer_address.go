@@ -0,0 +1,42 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+var ErrPublicPrinterAddress = errors.New("printer address is not in a private range")
+
+// ValidatePrinterAddress checks that ip is usable as a printer address.
+// Loopback, link-local, multicast and unspecified addresses are always
+// rejected; link-local covers cloud metadata services such as
+// 169.254.169.254. Unless
+// allowPublic is set, only private addresses (RFC 1918 and IPv6 unique local)
+// are accepted.
+func ValidatePrinterAddress(ip string, allowPublic bool) error {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return fmt.Errorf("invalid IP address: %s", ip)
+	}
+	switch {
+	case addr.IsLoopback():
+		return fmt.Errorf("loopback address %s cannot be used for a printer", ip)
+	case addr.IsLinkLocalUnicast():
+		return fmt.Errorf("link-local address %s cannot be used for a printer", ip)
+	case addr.IsMulticast():
+		return fmt.Errorf("multicast address %s cannot be used for a printer", ip)
+	case addr.IsUnspecified():
+		return fmt.Errorf("unspecified address %s cannot be used for a printer", ip)
+	}
+	if !allowPublic && !addr.IsPrivate() {
+		return fmt.Errorf("%w: %s (set printers.allow_public_ips to permit it)", ErrPublicPrinterAddress, ip)
+	}
+	return nil
+}
+
+// ValidateAddress applies ValidatePrinterAddress with the manager's
+// allow_public_ips setting.
+func (pm *PrinterManager) ValidateAddress(ip string) error {
+	return ValidatePrinterAddress(ip, pm.config.AllowPublicIPs)
+}
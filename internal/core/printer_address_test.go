@@ -0,0 +1,40 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePrinterAddress(t *testing.T) {
+	tests := []struct {
+		ip          string
+		allowPublic bool
+		wantErr     bool
+	}{
+		{"192.168.1.50", false, false},
+		{"10.0.0.7", false, false},
+		{"172.16.4.2", false, false},
+		{"fd00::10", false, false},
+		{"8.8.8.8", false, true},
+		{"8.8.8.8", true, false},
+		{"2001:db8::1", true, false},
+		{"127.0.0.1", true, true},
+		{"::1", true, true},
+		{"169.254.169.254", true, true},
+		{"169.254.1.20", false, true},
+		{"fe80::1", true, true},
+		{"224.0.0.1", true, true},
+		{"0.0.0.0", true, true},
+		{"printer.local", true, true},
+	}
+	for _, tt := range tests {
+		err := ValidatePrinterAddress(tt.ip, tt.allowPublic)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePrinterAddress(%q, %v) = %v, want error %v", tt.ip, tt.allowPublic, err, tt.wantErr)
+		}
+	}
+
+	if err := ValidatePrinterAddress("8.8.8.8", false); !errors.Is(err, ErrPublicPrinterAddress) {
+		t.Errorf("public address returned %v, want ErrPublicPrinterAddress", err)
+	}
+}
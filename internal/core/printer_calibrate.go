@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Calibration media types. The printer feeds a few labels to measure the
+// gap or black mark between them; auto also works out which of the two the
+// loaded media has.
+const (
+	MediaTypeGap       = "gap"
+	MediaTypeBlackMark = "black_mark"
+	MediaTypeAuto      = "auto"
+)
+
+var calibrationCommands = map[string]string{
+	MediaTypeGap:       "GAPDETECT",
+	MediaTypeBlackMark: "BLINEDETECT",
+	MediaTypeAuto:      "AUTODETECT",
+}
+
+// calibrationPollInterval is how often the printer is polled while it
+// calibrates, and calibrationTimeout how long it may take. Calibration
+// feeds several labels, so it can take some seconds.
+var (
+	calibrationPollInterval = 250 * time.Millisecond
+	calibrationTimeout      = 30 * time.Second
+)
+
+// ErrCalibrationFailed is returned when the printer finishes calibrating
+// in an error state, usually because it could not find the gap or mark.
+var ErrCalibrationFailed = errors.New("calibration failed")
+
+// CalibrationCommand returns the command that calibrates the sensor for
+// mediaType.
+func CalibrationCommand(mediaType string) (string, error) {
+	command, ok := calibrationCommands[mediaType]
+	if !ok {
+		return "", fmt.Errorf("invalid media_type %q (valid: gap, black_mark, auto)", mediaType)
+	}
+	return command, nil
+}
+
+// Calibrate recalibrates the printer's media sensor for mediaType and waits
+// until the printer has stopped feeding, returning its status afterwards.
+// The printer must be online. File printers have no sensor, so Calibrate
+// returns ErrNotNetworkPrinter for them.
+func (pm *PrinterManager) Calibrate(ctx context.Context, id int64, mediaType string) (*PrinterStatus, error) {
+	command, err := CalibrationCommand(mediaType)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pm.GetPrinter(id); err != nil {
+		return nil, err
+	}
+	if _, ok := pm.filePrinter(id); ok {
+		return nil, ErrNotNetworkPrinter
+	}
+
+	status, err := pm.CheckStatus(ctx, id)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, ErrPrinterOffline
+	}
+	if !status.IsOnline {
+		return nil, ErrPrinterOffline
+	}
+
+	if err := pm.SendCommand(ctx, id, command+"\n"); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, calibrationTimeout)
+	defer cancel()
+	ticker := time.NewTicker(calibrationPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, ErrTimeout
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		status, err := pm.CheckStatus(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			return nil, ErrPrinterOffline
+		}
+		if status.PrinterState == "feeding" {
+			continue
+		}
+		if pm.determineStatusString(status) == "error" {
+			return status, fmt.Errorf("%w: printer reports %s", ErrCalibrationFailed, calibrationFault(status))
+		}
+		return status, nil
+	}
+}
+
+// calibrationFault describes the error a printer finished calibrating with.
+func calibrationFault(status *PrinterStatus) string {
+	switch {
+	case status.MediaError != "none":
+		return status.MediaError
+	case status.Error != "none":
+		return status.Error
+	default:
+		return status.PrinterState
+	}
+}
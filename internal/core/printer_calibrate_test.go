@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newCalibratingPrinter returns a manager whose printer 1 answers status
+// queries with statuses in turn, repeating the last, and records every
+// other command it is sent.
+func newCalibratingPrinter(t *testing.T, statuses ...string) (*PrinterManager, func() []string) {
+	t.Helper()
+
+	interval := calibrationPollInterval
+	calibrationPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { calibrationPollInterval = interval })
+
+	var (
+		mu       sync.Mutex
+		commands []string
+		polls    int
+	)
+	pm := newListeningPrinter(t, func(conn net.Conn) {
+		buf := make([]byte, 512)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			data := string(buf[:n])
+			if data != statusCommand {
+				mu.Lock()
+				commands = append(commands, data)
+				mu.Unlock()
+				continue
+			}
+			mu.Lock()
+			reply := statuses[min(polls, len(statuses)-1)]
+			polls++
+			mu.Unlock()
+			if _, err := conn.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	})
+	pm.db = newTestDB(t)
+	return pm, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), commands...)
+	}
+}
+
+func TestCalibrateSendsCommandForMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		want      string
+	}{
+		{MediaTypeGap, "GAPDETECT\n"},
+		{MediaTypeBlackMark, "BLINEDETECT\n"},
+		{MediaTypeAuto, "AUTODETECT\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mediaType, func(t *testing.T) {
+			// Ready, then feeding while it measures, then ready again.
+			pm, sent := newCalibratingPrinter(t, "@@@@", "F@@@", "F@@@", "@@@@")
+
+			status, err := pm.Calibrate(context.Background(), 1, tt.mediaType)
+			if err != nil {
+				t.Fatalf("Calibrate: %v", err)
+			}
+			if status.PrinterState != "normal" {
+				t.Errorf("calibration returned with the printer %s, want normal", status.PrinterState)
+			}
+			if got := sent(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("printer was sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalibrateReportsMediaError(t *testing.T) {
+	pm, _ := newCalibratingPrinter(t, "@@@@", "F@@@", "@@@A")
+
+	_, err := pm.Calibrate(context.Background(), 1, MediaTypeGap)
+	if !errors.Is(err, ErrCalibrationFailed) {
+		t.Fatalf("Calibrate with the paper out: %v, want ErrCalibrationFailed", err)
+	}
+	if !strings.Contains(err.Error(), "paper_empty") {
+		t.Errorf("error %q does not name the media error", err)
+	}
+}
+
+func TestCalibrateRequiresOnlinePrinter(t *testing.T) {
+	pm, sent := newCalibratingPrinter(t, "@@@@")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+	pm.printers[1].Port = addr.Port
+
+	if _, err := pm.Calibrate(context.Background(), 1, MediaTypeAuto); !errors.Is(err, ErrPrinterOffline) {
+		t.Errorf("Calibrate an unreachable printer: %v, want ErrPrinterOffline", err)
+	}
+	if got := sent(); len(got) != 0 {
+		t.Errorf("printer was sent %q, want nothing", got)
+	}
+	if _, err := pm.Calibrate(context.Background(), 1, "continuous"); err == nil {
+		t.Error("Calibrate with an unknown media type succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Print setting queries, read from the same TSPL settings table as the
+// label size.
+const (
+	gapSizeCommand = "OUT \"\",GETSETTING$(\"CONFIG\",\"TSPL\",\"GAP SIZE\")\r\n"
+	speedCommand   = "OUT \"\",GETSETTING$(\"CONFIG\",\"TSPL\",\"SPEED\")\r\n"
+	densityCommand = "OUT \"\",GETSETTING$(\"CONFIG\",\"TSPL\",\"DENSITY\")\r\n"
+)
+
+// GetPrinterConfig reads the media and print settings stored on a printer:
+// its label size, gap, speed and density. Settings the printer does not
+// report are left nil.
+func (pm *PrinterManager) GetPrinterConfig(id int64) (*PrinterConfig, error) {
+	pm.mu.RLock()
+	_, exists := pm.printers[id]
+	pm.mu.RUnlock()
+	if !exists {
+		return nil, ErrPrinterNotFound
+	}
+
+	ioLock := pm.ioLock(id)
+	ioLock.Lock()
+	defer ioLock.Unlock()
+
+	conn, err := pm.connect(context.Background(), id)
+	if err == ErrNotNetworkPrinter {
+		return nil, err
+	}
+	if err != nil {
+		return nil, ErrPrinterOffline
+	}
+
+	cfg, err := readPrinterConfig(pm.newQuerySession(id, conn))
+	if err != nil {
+		pm.disconnect(id)
+		return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+	return cfg, nil
+}
+
+// readPrinterConfig queries each stored setting in session. It fails only if
+// the connection breaks; a printer ignoring a query just leaves it nil.
+func readPrinterConfig(session *querySession) (*PrinterConfig, error) {
+	cfg := &PrinterConfig{QueriedAt: time.Now()}
+	queries := []struct {
+		command string
+		apply   func(string)
+	}{
+		{labelWidthCommand, func(v string) { cfg.LabelWidthMM = parseLabelDimension(v) }},
+		{labelHeightCommand, func(v string) { cfg.LabelHeightMM = parseLabelDimension(v) }},
+		{gapSizeCommand, func(v string) { cfg.GapMM = parseMediaLength(v, true) }},
+		{speedCommand, func(v string) {
+			if n, ok := parseSettingNumber(v); ok && n > 0 {
+				cfg.Speed = &n
+			}
+		}},
+		{densityCommand, func(v string) {
+			if n, ok := parseSettingNumber(v); ok && n >= 0 {
+				density := int(math.Round(n))
+				cfg.Density = &density
+			}
+		}},
+	}
+	for _, q := range queries {
+		value, err := session.query(q.command)
+		if value != "" {
+			q.apply(value)
+		}
+		if err != nil && !isTimeout(err) {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// parseSettingNumber reads the number at the start of a reported setting
+// such as "4.0" or "8 ips".
+func parseSettingNumber(value string) (float64, bool) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(fields[0], 64)
+	return n, err == nil
+}
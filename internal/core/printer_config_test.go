@@ -0,0 +1,37 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+func TestGetPrinterConfigPartial(t *testing.T) {
+	// A printer that reports its media but not its print settings.
+	pm := newScriptedPrinter(t, map[string]string{
+		labelWidthCommand:  "50 mm\r\n",
+		labelHeightCommand: "30 mm\r\n",
+		gapSizeCommand:     "0.12\r\n",
+	})
+
+	cfg, err := pm.GetPrinterConfig(1)
+	if err != nil {
+		t.Fatalf("GetPrinterConfig: %v", err)
+	}
+	if cfg.LabelWidthMM == nil || *cfg.LabelWidthMM != 50 || cfg.LabelHeightMM == nil || *cfg.LabelHeightMM != 30 {
+		t.Errorf("label size is %v x %v, want 50 x 30", cfg.LabelWidthMM, cfg.LabelHeightMM)
+	}
+	if cfg.GapMM == nil || *cfg.GapMM < 3.04 || *cfg.GapMM > 3.05 {
+		t.Errorf("gap is %v, want 3.048", cfg.GapMM)
+	}
+	if cfg.Speed != nil || cfg.Density != nil {
+		t.Errorf("speed %v and density %v, want both unreported", cfg.Speed, cfg.Density)
+	}
+}
+
+func TestGetPrinterConfigUnknownPrinter(t *testing.T) {
+	pm := NewPrinterManager(nil, &config.PrintersConfig{}, nil)
+	if _, err := pm.GetPrinterConfig(7); err != ErrPrinterNotFound {
+		t.Errorf("GetPrinterConfig returned %v, want ErrPrinterNotFound", err)
+	}
+}
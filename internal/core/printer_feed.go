@@ -0,0 +1,39 @@
+package core
+
+import "fmt"
+
+// Feed-on-error options. After a failed print, formfeed feeds to the start
+// of the next label, while gap, black_mark and auto recalibrate the sensor
+// for that media first.
+const (
+	FeedOnErrorNone     = "none"
+	FeedOnErrorFormfeed = "formfeed"
+)
+
+var feedOnErrorCommands = map[string]string{
+	FeedOnErrorFormfeed: "FORMFEED",
+	"gap":               "GAPDETECT",
+	"black_mark":        "BLINEDETECT",
+	"auto":              "AUTODETECT",
+}
+
+// FeedOnErrorCommand returns the command a printer configured with option
+// is sent after a failed print, or "" for none.
+func FeedOnErrorCommand(option string) (string, error) {
+	if option == "" || option == FeedOnErrorNone {
+		return "", nil
+	}
+	command, ok := feedOnErrorCommands[option]
+	if !ok {
+		return "", fmt.Errorf("invalid feed_on_error %q (valid: none, formfeed, gap, black_mark, auto)", option)
+	}
+	return command, nil
+}
+
+// feedOnError returns p's feed-on-error option as stored.
+func feedOnError(p *Printer) string {
+	if p.FeedOnError == "" {
+		return FeedOnErrorNone
+	}
+	return p.FeedOnError
+}
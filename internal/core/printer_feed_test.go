@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+func TestFeedAfterFailedPrint(t *testing.T) {
+	tests := []struct {
+		option string
+		want   []string
+	}{
+		{"", nil},
+		{FeedOnErrorNone, nil},
+		{FeedOnErrorFormfeed, []string{"FORMFEED\n"}},
+		{"gap", []string{"GAPDETECT\n"}},
+		{"black_mark", []string{"BLINEDETECT\n"}},
+		{"auto", []string{"AUTODETECT\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("option %q", tt.option), func(t *testing.T) {
+			database := newTestDB(t)
+			printerID := insertTestPrinter(t, database, "printer")
+			pm := &failingPrinters{newFakePrinterManager()}
+			pm.addPrinter(&Printer{ID: printerID, Status: "online", FeedOnError: tt.option})
+
+			q := NewQueue(database, pm, nil, nil, &config.QueueConfig{WorkerCount: 1, RetryDelay: time.Millisecond})
+			jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1})
+			if err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+			if err := q.Start(); err != nil {
+				t.Fatalf("start queue: %v", err)
+			}
+			defer q.Stop()
+
+			waitForJobStatus(t, database, jobID, JobStatusFailed)
+			if got := pm.sentCommands(printerID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("printer was sent %q after the failure, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoFeedWhenPrinterWasOffline(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	pm := newFakePrinterManager()
+	pm.addPrinter(&Printer{ID: printerID, Status: "offline", FeedOnError: FeedOnErrorFormfeed})
+
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{WorkerCount: 1, RetryDelay: time.Millisecond})
+	jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	waitForJobStatus(t, database, jobID, JobStatusFailed)
+	if got := pm.sentCommands(printerID); len(got) != 0 {
+		t.Errorf("offline printer was sent %q, want nothing since nothing printed", got)
+	}
+}
+
+func TestFeedOnErrorCommandRejectsUnknownOption(t *testing.T) {
+	if _, err := FeedOnErrorCommand("eject"); err == nil {
+		t.Error("FeedOnErrorCommand(\"eject\") succeeded, want an error")
+	}
+}
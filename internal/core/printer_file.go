@@ -0,0 +1,176 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Printer types. Network printers are sent TSPL over TCP. File printers
+// write each print to a file in their spool directory instead, for testing
+// or for handing labels to a spooler on another host.
+const (
+	PrinterTypeNetwork = "network"
+	PrinterTypeFile    = "file"
+)
+
+// spoolFileTimeFormat names spool files so they sort in the order they were
+// written.
+const spoolFileTimeFormat = "20060102T150405.000000000"
+
+// ErrNotNetworkPrinter is returned for operations that need a connection to
+// the printer, such as querying its info, on a file printer.
+var ErrNotNetworkPrinter = errors.New("printer is not a network printer")
+
+// printerType returns p's type.
+func printerType(p *Printer) string {
+	if p.Type == "" {
+		return PrinterTypeNetwork
+	}
+	return p.Type
+}
+
+func isFilePrinter(p *Printer) bool {
+	return printerType(p) == PrinterTypeFile
+}
+
+// filePrinter returns a snapshot of printer id if it is a file printer.
+func (pm *PrinterManager) filePrinter(id int64) (*Printer, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	p, exists := pm.printers[id]
+	if !exists || !isFilePrinter(p) {
+		return nil, false
+	}
+	snapshot := *p
+	return &snapshot, true
+}
+
+// FilePrinterAddress is what a file printer stores as its address, which
+// must be unique among printers. Two file printers cannot share a spool
+// directory.
+func FilePrinterAddress(spoolDir string) string {
+	return "file:" + spoolDir
+}
+
+// ErrSpoolDirOutsideRoot is returned for a spool directory that does not
+// lie under printers.spool_root.
+var ErrSpoolDirOutsideRoot = errors.New("spool_dir is outside the spool root")
+
+// ValidateSpoolDir checks that dir can be a file printer's spool directory:
+// an absolute path that, cleaned and with symlinks resolved, lies under
+// root. It need not exist yet; until it does the printer reports offline.
+// With no root, no directory is accepted.
+func ValidateSpoolDir(dir, root string) error {
+	if dir == "" {
+		return errors.New("spool_dir is required for file printers")
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("spool_dir must be an absolute path, got %q", dir)
+	}
+	if root == "" {
+		return errors.New("file printers are disabled: printers.spool_root is not set")
+	}
+
+	rootPath, err := resolvePath(root)
+	if err != nil {
+		return fmt.Errorf("failed to resolve spool root: %w", err)
+	}
+	dirPath, err := resolvePath(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve spool_dir: %w", err)
+	}
+	rel, err := filepath.Rel(rootPath, dirPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: %s", ErrSpoolDirOutsideRoot, dir)
+	}
+	return nil
+}
+
+// ValidateSpoolDir applies ValidateSpoolDir with the manager's spool_root
+// setting.
+func (pm *PrinterManager) ValidateSpoolDir(dir string) error {
+	return ValidateSpoolDir(dir, pm.config.SpoolRoot)
+}
+
+// resolvePath returns path made absolute and cleaned, with the symlinks in
+// the part of it that exists resolved.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	existing, rest := path, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// checkFileStatus reports a file printer online while its spool directory
+// exists, and offline otherwise.
+func (pm *PrinterManager) checkFileStatus(id int64, p *Printer) *PrinterStatus {
+	status := &PrinterStatus{LastChecked: time.Now()}
+	if info, err := os.Stat(p.SpoolDir); err != nil || !info.IsDir() {
+		pm.updatePrinterStatus(id, "offline")
+		return status
+	}
+
+	status.PrinterState = "normal"
+	status.Warning = "none"
+	status.Error = "none"
+	status.MediaError = "none"
+	status.IsOnline = true
+	status.CanPrint = true
+	pm.updatePrinterStatus(id, pm.determineStatusString(status))
+	return status
+}
+
+// writeSpoolFile writes tspl, encoded as it would be sent to a network
+// printer, to a new timestamped file in p's spool directory. The file is
+// written under a temporary name and renamed into place, so a spooler
+// watching the directory never picks up a partial print.
+func writeSpoolFile(p *Printer, tspl string) (string, error) {
+	data, err := EncodeForPrinter(p, tspl)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("printer-%d-%s.tspl", p.ID, time.Now().UTC().Format(spoolFileTimeFormat))
+	tmp, err := os.CreateTemp(p.SpoolDir, "."+name+".*")
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+
+	path := filepath.Join(p.SpoolDir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+func TestFilePrinterPrintWritesSpoolFile(t *testing.T) {
+	database := newTestDB(t)
+	spoolDir := t.TempDir()
+	id := insertTestPrinter(t, database, "virtual")
+
+	pm := NewPrinterManager(database, &config.PrintersConfig{}, nil)
+	pm.printers[id] = &Printer{ID: id, Name: "virtual", Type: PrinterTypeFile, SpoolDir: spoolDir}
+
+	if err := pm.Print(context.Background(), id, "PRINT 1", 2); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+
+	entries, err := os.ReadDir(spoolDir)
+	if err != nil {
+		t.Fatalf("read spool dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("spool dir holds %d files, want 1", len(entries))
+	}
+	name := entries[0].Name()
+	if !strings.HasPrefix(name, "printer-") || !strings.HasSuffix(name, ".tspl") {
+		t.Errorf("spool file is named %s, want printer-<id>-<timestamp>.tspl", name)
+	}
+	data, err := os.ReadFile(filepath.Join(spoolDir, name))
+	if err != nil {
+		t.Fatalf("read spool file: %v", err)
+	}
+	if got := string(data); got != "PRINT 1\nPRINT 1" {
+		t.Errorf("spool file holds %q, want both copies", got)
+	}
+	if p, _ := pm.GetPrinter(id); p.Status != "online" || p.TotalPrints != 2 {
+		t.Errorf("printer is %s with %d prints, want online with 2", p.Status, p.TotalPrints)
+	}
+
+	if err := pm.SendCommand(context.Background(), id, "FORMFEED\n"); !errors.Is(err, ErrNotNetworkPrinter) {
+		t.Errorf("SendCommand to a file printer: %v, want ErrNotNetworkPrinter", err)
+	}
+}
+
+func TestFilePrinterWithoutSpoolDirIsOffline(t *testing.T) {
+	database := newTestDB(t)
+	id := insertTestPrinter(t, database, "virtual")
+
+	pm := NewPrinterManager(database, &config.PrintersConfig{}, nil)
+	pm.printers[id] = &Printer{ID: id, Name: "virtual", Type: PrinterTypeFile, SpoolDir: filepath.Join(t.TempDir(), "missing")}
+
+	if err := pm.Print(context.Background(), id, "PRINT 1", 1); !errors.Is(err, ErrPrinterOffline) {
+		t.Errorf("Print to a missing spool dir: %v, want ErrPrinterOffline", err)
+	}
+	if p, _ := pm.GetPrinter(id); p.Status != "offline" {
+		t.Errorf("printer is %s, want offline", p.Status)
+	}
+}
+
+func TestValidateSpoolDirKeepsToRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	tests := []struct {
+		dir     string
+		wantErr bool
+	}{
+		{filepath.Join(root, "labels"), false},
+		{filepath.Join(root, "labels", "zone-a"), false},
+		{root, false},
+		{outside, true},
+		{filepath.Join(root, "..", filepath.Base(outside)), true},
+		{filepath.Join(root, "escape"), true},
+		{filepath.Join(root, "escape", "labels"), true},
+		{"labels", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateSpoolDir(tt.dir, root); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateSpoolDir(%q): %v, want error %v", tt.dir, err, tt.wantErr)
+		}
+	}
+
+	if err := ValidateSpoolDir(filepath.Join(root, "labels"), ""); err == nil {
+		t.Error("ValidateSpoolDir without a spool root succeeded")
+	}
+}
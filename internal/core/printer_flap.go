@@ -0,0 +1,167 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	defaultReconnectDelay      = time.Second
+	defaultMaxReconnectBackoff = time.Minute
+
+	// dialRetryDelay is the pause before the first redial of a failed
+	// connection attempt, doubling before each further one.
+	dialRetryDelay = 100 * time.Millisecond
+)
+
+// reconnectState counts consecutive failed dials to a printer and when the
+// next dial may be attempted.
+type reconnectState struct {
+	failures int
+	retryAt  time.Time
+}
+
+// pendingStatusChange is a status change waiting out the debounce window.
+// from is the status the printer had before the window opened.
+type pendingStatusChange struct {
+	from  string
+	timer *time.Timer
+}
+
+// dialPrinter opens a connection to a printer, refusing without dialing
+// while the printer is in reconnect backoff. A failed dial is retried up to
+// dial_retries times after a short pause, all within timeout, so a
+// momentary refusal does not count as a failure. Each failed attempt
+// doubles the backoff, from reconnect_delay up to max_reconnect_backoff; a
+// successful dial clears it.
+func (pm *PrinterManager) dialPrinter(ctx context.Context, id int64, address string, timeout time.Duration) (net.Conn, error) {
+	pm.mu.RLock()
+	var wait time.Duration
+	if st, ok := pm.reconnects[id]; ok {
+		wait = time.Until(st.retryAt)
+	}
+	pm.mu.RUnlock()
+	if wait > 0 {
+		return nil, fmt.Errorf("%w: reconnect backoff, next attempt in %s", ErrConnectionFailed, wait.Round(time.Millisecond))
+	}
+
+	deadline := time.Now().Add(timeout)
+	delay := dialRetryDelay
+	for attempt := 0; ; attempt++ {
+		dialer := net.Dialer{Deadline: deadline}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			pm.mu.Lock()
+			delete(pm.reconnects, id)
+			pm.mu.Unlock()
+			return conn, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if attempt >= pm.config.DialRetries || time.Until(deadline) <= delay {
+			pm.noteDialFailure(id)
+			return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (pm *PrinterManager) noteDialFailure(id int64) {
+	base := pm.config.ReconnectDelay
+	if base == 0 {
+		base = defaultReconnectDelay
+	}
+	maxBackoff := pm.config.MaxReconnectBackoff
+	if maxBackoff == 0 {
+		maxBackoff = defaultMaxReconnectBackoff
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	st, ok := pm.reconnects[id]
+	if !ok {
+		st = &reconnectState{}
+		pm.reconnects[id] = st
+	}
+	st.failures++
+
+	delay := base
+	for i := 1; i < st.failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	st.retryAt = time.Now().Add(delay)
+}
+
+// emitStatusChange publishes a printer status transition to webhooks and the
+// event log. With status_debounce set, the first change opens a window and
+// further changes inside it are absorbed; when the window closes a single
+// change from the starting status to the current one is published, or
+// nothing if the printer is back where it started. Callers must hold pm.mu.
+func (pm *PrinterManager) emitStatusChange(id int64, name, oldStatus, newStatus string) {
+	window := pm.config.StatusDebounce
+	if window <= 0 {
+		pm.publishStatusChange(id, name, oldStatus, newStatus)
+		return
+	}
+	if _, pending := pm.pendingStatus[id]; pending {
+		return
+	}
+	pm.pendingStatus[id] = &pendingStatusChange{
+		from:  oldStatus,
+		timer: time.AfterFunc(window, func() { pm.flushStatusChange(id) }),
+	}
+}
+
+func (pm *PrinterManager) flushStatusChange(id int64) {
+	pm.mu.Lock()
+	pending, ok := pm.pendingStatus[id]
+	delete(pm.pendingStatus, id)
+	p, exists := pm.printers[id]
+	if !ok || !exists {
+		pm.mu.Unlock()
+		return
+	}
+	name, current := p.Name, p.Status
+	pm.mu.Unlock()
+
+	if current != pending.from {
+		pm.publishStatusChange(id, name, pending.from, current)
+	}
+}
+
+func (pm *PrinterManager) publishStatusChange(id int64, name, oldStatus, newStatus string) {
+	if pm.webhookSender != nil {
+		go pm.webhookSender.SendPrinterStatusChange(id, name, oldStatus, newStatus, nil)
+	}
+	if pm.events != nil {
+		pm.events.Record(Event{
+			Type:      "printer_status_changed",
+			PrinterID: id,
+			Status:    newStatus,
+			Message:   fmt.Sprintf("%s: %s -> %s", name, oldStatus, newStatus),
+		})
+	}
+}
+
+// forgetPrinterState drops the backoff and any pending status change for a
+// printer whose address changed or that was removed. Callers must hold pm.mu.
+func (pm *PrinterManager) forgetPrinterState(id int64) {
+	delete(pm.reconnects, id)
+	if pending, ok := pm.pendingStatus[id]; ok {
+		pending.timer.Stop()
+		delete(pm.pendingStatus, id)
+	}
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+// newFlappingManager returns a manager with an online printer 1 whose
+// status changes are debounced over window and recorded in the returned
+// event log.
+func newFlappingManager(t *testing.T, window time.Duration) (*PrinterManager, *EventLog) {
+	t.Helper()
+
+	events := NewEventLog(DefaultEventLogSize)
+	pm := NewPrinterManager(newTestDB(t), &config.PrintersConfig{StatusDebounce: window}, nil)
+	pm.SetEventLog(events)
+	pm.printers[1] = &Printer{ID: 1, Name: "dock", Status: "online"}
+	t.Cleanup(pm.Stop)
+	return pm, events
+}
+
+func statusChanges(events *EventLog) []Event {
+	var changes []Event
+	for _, e := range events.Recent(0) {
+		if e.Type == "printer_status_changed" {
+			changes = append(changes, e)
+		}
+	}
+	return changes
+}
+
+func TestFlappingPrinterPublishesOneChangePerWindow(t *testing.T) {
+	pm, events := newFlappingManager(t, 100*time.Millisecond)
+
+	for _, status := range []string{"offline", "online", "offline", "online", "offline"} {
+		pm.updatePrinterStatus(1, status)
+	}
+	if changes := statusChanges(events); len(changes) != 0 {
+		t.Fatalf("%d status changes published inside the debounce window, want none yet", len(changes))
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	changes := statusChanges(events)
+	if len(changes) != 1 {
+		t.Fatalf("%d status changes published for the window, want 1: %+v", len(changes), changes)
+	}
+	if changes[0].Status != "offline" || !strings.Contains(changes[0].Message, "online -> offline") {
+		t.Errorf("published change is %+v, want online -> offline", changes[0])
+	}
+}
+
+func TestFlapBackToStartPublishesNothing(t *testing.T) {
+	pm, events := newFlappingManager(t, 100*time.Millisecond)
+
+	pm.updatePrinterStatus(1, "offline")
+	pm.updatePrinterStatus(1, "online")
+
+	time.Sleep(300 * time.Millisecond)
+	if changes := statusChanges(events); len(changes) != 0 {
+		t.Errorf("printer that ended the window where it started published %+v, want nothing", changes)
+	}
+}
+
+func TestStatusChangesWithoutDebounce(t *testing.T) {
+	pm, events := newFlappingManager(t, 0)
+
+	pm.updatePrinterStatus(1, "offline")
+	pm.updatePrinterStatus(1, "online")
+
+	if changes := statusChanges(events); len(changes) != 2 {
+		t.Errorf("%d status changes published, want both", len(changes))
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	// Nothing listens on a port that was just released.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	pm := NewPrinterManager(nil, &config.PrintersConfig{
+		ReconnectDelay:      50 * time.Millisecond,
+		MaxReconnectBackoff: 150 * time.Millisecond,
+	}, nil)
+	ctx := context.Background()
+
+	if _, err := pm.dialPrinter(ctx, 1, addr, time.Second); err == nil || strings.Contains(err.Error(), "backoff") {
+		t.Fatalf("first dial returned %v, want a dial failure", err)
+	}
+	if _, err := pm.dialPrinter(ctx, 1, addr, time.Second); !errors.Is(err, ErrConnectionFailed) || !strings.Contains(err.Error(), "reconnect backoff") {
+		t.Fatalf("dial during backoff returned %v, want it refused without dialing", err)
+	}
+
+	// Each failure doubles the wait, up to the maximum.
+	for i, want := range []time.Duration{100 * time.Millisecond, 150 * time.Millisecond, 150 * time.Millisecond} {
+		pm.mu.RLock()
+		wait := time.Until(pm.reconnects[1].retryAt)
+		pm.mu.RUnlock()
+		time.Sleep(wait)
+
+		pm.dialPrinter(ctx, 1, addr, time.Second)
+		pm.mu.RLock()
+		got := time.Until(pm.reconnects[1].retryAt)
+		pm.mu.RUnlock()
+		if got > want || got < want-25*time.Millisecond {
+			t.Errorf("after failure %d the backoff is %s, want %s", i+2, got, want)
+		}
+	}
+
+	// A successful dial clears the backoff.
+	ln, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	pm.mu.RLock()
+	wait := time.Until(pm.reconnects[1].retryAt)
+	pm.mu.RUnlock()
+	time.Sleep(wait)
+	conn, err := pm.dialPrinter(ctx, 1, ln.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("dial listening printer: %v", err)
+	}
+	conn.Close()
+	pm.mu.RLock()
+	_, backingOff := pm.reconnects[1]
+	pm.mu.RUnlock()
+	if backingOff {
+		t.Error("printer is still in reconnect backoff after a successful dial")
+	}
+}
+
+func TestDialRetriesRefusedConnection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// The printer refuses the first attempt and is listening by the retry.
+	listening := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			close(listening)
+			return
+		}
+		listening <- ln
+	}()
+	t.Cleanup(func() {
+		if ln, ok := <-listening; ok {
+			ln.Close()
+		}
+	})
+
+	pm := NewPrinterManager(nil, &config.PrintersConfig{DialRetries: 2}, nil)
+	start := time.Now()
+	conn, err := pm.dialPrinter(context.Background(), 1, addr, time.Second)
+	if err != nil {
+		t.Fatalf("dial with retries returned %v, want the retry to connect", err)
+	}
+	conn.Close()
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("dial took %s, want it to have waited for a retry", elapsed)
+	}
+	pm.mu.RLock()
+	_, backingOff := pm.reconnects[1]
+	pm.mu.RUnlock()
+	if backingOff {
+		t.Error("printer is in reconnect backoff after a retried dial succeeded")
+	}
+}
+
+func TestDialRetriesStayWithinTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	pm := NewPrinterManager(nil, &config.PrintersConfig{DialRetries: 10}, nil)
+	start := time.Now()
+	if _, err := pm.dialPrinter(context.Background(), 1, addr, 250*time.Millisecond); !errors.Is(err, ErrConnectionFailed) {
+		t.Fatalf("dial returned %v, want ErrConnectionFailed", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("retries took %s, want them bounded by the 250ms timeout", elapsed)
+	}
+	pm.mu.RLock()
+	_, backingOff := pm.reconnects[1]
+	pm.mu.RUnlock()
+	if !backingOff {
+		t.Error("printer is not in reconnect backoff after every retry failed")
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPrinterGroupEmpty  = errors.New("no printers in group")
+	ErrNoPrinterAvailable = errors.New("no online printer in group")
+)
+
+// SelectGroupPrinter picks the printer a group-targeted job is sent to. Only
+// online or busy printers that are not paused in the queue are considered;
+// among them the one with the fewest pending and processing jobs wins, with
+// ties going to the lowest printer ID.
+func (q *Queue) SelectGroupPrinter(group string) (int64, error) {
+	rows, err := q.db.Query(`
+		SELECT p.id
+		FROM printers p
+		LEFT JOIN (
+			SELECT printer_id, COUNT(*) AS queued
+			FROM print_jobs
+			WHERE status IN ('pending', 'processing')
+			GROUP BY printer_id
+		) j ON j.printer_id = p.id
+		WHERE p.printer_group = ?
+		ORDER BY COALESCE(j.queued, 0) ASC, p.id ASC
+	`, group)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list group printers: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, fmt.Errorf("failed to scan group printer: %w", err)
+		}
+		candidates = append(candidates, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list group printers: %w", err)
+	}
+	if len(candidates) == 0 {
+		return 0, ErrPrinterGroupEmpty
+	}
+
+	for _, id := range candidates {
+		if q.IsPrinterPaused(id) {
+			continue
+		}
+		if q.printerManager != nil {
+			p, err := q.printerManager.GetPrinter(id)
+			if err != nil || (p.Status != "online" && p.Status != "busy") {
+				continue
+			}
+		}
+		return id, nil
+	}
+
+	return 0, ErrNoPrinterAvailable
+}
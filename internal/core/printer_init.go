@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// initCommands returns the commands sent on each new connection to p: its
+// own, or the configured default when it has none.
+func (pm *PrinterManager) initCommands(p *Printer) []string {
+	if len(p.InitCommands) > 0 {
+		return p.InitCommands
+	}
+	return pm.config.InitCommands
+}
+
+// sendInitCommands writes p's init commands to a freshly dialed connection
+// before it is cached. Only the cached connection gets them, not the
+// short-lived dedicated status connections, so a calibration such as
+// GAPDETECT runs once per connection rather than on every poll.
+func (pm *PrinterManager) sendInitCommands(ctx context.Context, p *Printer, conn net.Conn, timeout time.Duration) error {
+	commands := pm.initCommands(p)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	data, err := EncodeForPrinter(p, strings.Join(commands, "\n")+"\n")
+	if err != nil {
+		return err
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if _, err := conn.Write(data); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("%w: init commands: %v", ErrConnectionFailed, err)
+	}
+	return nil
+}
+
+// ValidateInitCommands checks that each init command is a single non-empty
+// line, as they are stored one per line.
+func ValidateInitCommands(commands []string) error {
+	for i, command := range commands {
+		if strings.TrimSpace(command) == "" {
+			return fmt.Errorf("init_commands[%d] is empty", i)
+		}
+		if strings.ContainsAny(command, "\r\n") {
+			return fmt.Errorf("init_commands[%d] must be a single line", i)
+		}
+	}
+	return nil
+}
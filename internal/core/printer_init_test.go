@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRecordingPrinter returns a manager with printer 1 and a function
+// reporting what each connection to it has received so far, in order.
+func newRecordingPrinter(t *testing.T) (*PrinterManager, func() []string) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		received []*strings.Builder
+	)
+	pm := newListeningPrinter(t, func(conn net.Conn) {
+		var sb strings.Builder
+		mu.Lock()
+		received = append(received, &sb)
+		mu.Unlock()
+		buf := make([]byte, 512)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			sb.Write(buf[:n])
+			mu.Unlock()
+		}
+	})
+	return pm, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		got := make([]string, len(received))
+		for i, sb := range received {
+			got[i] = sb.String()
+		}
+		return got
+	}
+}
+
+// waitForReceived polls until the printer has seen want on each connection.
+func waitForReceived(t *testing.T, received func() []string, want []string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := received()
+		if strings.Join(got, "|") == strings.Join(want, "|") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("printer received %q, want %q", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInitCommandsSentOncePerConnection(t *testing.T) {
+	pm, received := newRecordingPrinter(t)
+	pm.printers[1].InitCommands = []string{"GAPDETECT", "DIRECTION 1"}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := pm.SendCommand(ctx, 1, "CLS\n"); err != nil {
+			t.Fatalf("send command: %v", err)
+		}
+	}
+	waitForReceived(t, received, []string{"GAPDETECT\nDIRECTION 1\nCLS\nCLS\n"})
+
+	pm.CloseConnection(1)
+	if err := pm.SendCommand(ctx, 1, "CLS\n"); err != nil {
+		t.Fatalf("send command after reconnect: %v", err)
+	}
+	waitForReceived(t, received, []string{"GAPDETECT\nDIRECTION 1\nCLS\nCLS\n", "GAPDETECT\nDIRECTION 1\nCLS\n"})
+}
+
+func TestInitCommandsDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		printer []string
+		want    string
+	}{
+		{"default", nil, "GAPDETECT\nCLS\n"},
+		{"printer overrides default", []string{"BLINEDETECT"}, "BLINEDETECT\nCLS\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm, received := newRecordingPrinter(t)
+			pm.config.InitCommands = []string{"GAPDETECT"}
+			pm.printers[1].InitCommands = tt.printer
+
+			if err := pm.SendCommand(context.Background(), 1, "CLS\n"); err != nil {
+				t.Fatalf("send command: %v", err)
+			}
+			waitForReceived(t, received, []string{tt.want})
+		})
+	}
+}
+
+func TestValidateInitCommands(t *testing.T) {
+	if err := ValidateInitCommands([]string{"GAPDETECT", "SET TEAR ON"}); err != nil {
+		t.Errorf("valid commands rejected: %v", err)
+	}
+	for _, commands := range [][]string{{" "}, {"GAPDETECT\nCLS"}} {
+		if err := ValidateInitCommands(commands); err == nil {
+			t.Errorf("ValidateInitCommands(%q) succeeded, want an error", commands)
+		}
+	}
+}
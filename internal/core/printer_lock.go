@@ -0,0 +1,30 @@
+package core
+
+// claimNext dequeues the next job whose printer is not already printing one
+// and marks that printer as printing until releasePrinter. Two jobs' TSPL is
+// never interleaved on one printer, while jobs for other printers are still
+// claimed by idle workers. Because the busy printer's jobs are skipped
+// rather than waited on, they still print in dispatch order.
+func (q *Queue) claimNext() (*Job, error) {
+	q.printingMu.Lock()
+	defer q.printingMu.Unlock()
+
+	busy := make([]int64, 0, len(q.printing))
+	for id := range q.printing {
+		busy = append(busy, id)
+	}
+	job, err := q.dequeue(busy)
+	if job != nil {
+		q.printing[job.PrinterID] = true
+	}
+	return job, err
+}
+
+// releasePrinter lets the next job for printerID be claimed and wakes a
+// worker to claim it.
+func (q *Queue) releasePrinter(printerID int64) {
+	q.printingMu.Lock()
+	delete(q.printing, printerID)
+	q.printingMu.Unlock()
+	q.wake()
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+// overlapPrinterManager records how many jobs are printing at once, per
+// printer and in total.
+type overlapPrinterManager struct {
+	*fakePrinterManager
+
+	mu         sync.Mutex
+	active     map[int64]int
+	total      int
+	maxActive  map[int64]int
+	maxOverall int
+}
+
+func (o *overlapPrinterManager) Print(ctx context.Context, printerID int64, tsplContent string, copies int) error {
+	o.mu.Lock()
+	o.active[printerID]++
+	o.total++
+	o.maxActive[printerID] = max(o.maxActive[printerID], o.active[printerID])
+	o.maxOverall = max(o.maxOverall, o.total)
+	o.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	o.mu.Lock()
+	o.active[printerID]--
+	o.total--
+	o.mu.Unlock()
+	return o.fakePrinterManager.Print(ctx, printerID, tsplContent, copies)
+}
+
+func TestQueuePrintsOneJobPerPrinterAtATime(t *testing.T) {
+	database := newTestDB(t)
+	pm := &overlapPrinterManager{
+		fakePrinterManager: newFakePrinterManager(),
+		active:             make(map[int64]int),
+		maxActive:          make(map[int64]int),
+	}
+	var printerIDs []int64
+	for i := 0; i < 3; i++ {
+		id := insertTestPrinter(t, database, fmt.Sprintf("printer-%d", i))
+		pm.addPrinter(&Printer{ID: id, Status: "online"})
+		printerIDs = append(printerIDs, id)
+	}
+
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{WorkerCount: 8})
+
+	const jobs = 30
+	for i := 0; i < jobs; i++ {
+		job := &Job{PrinterID: printerIDs[i%len(printerIDs)], TSPLContent: fmt.Sprintf("PRINT %d", i), Copies: 1}
+		if _, err := q.Enqueue(job); err != nil {
+			t.Fatalf("enqueue job %d: %v", i, err)
+		}
+	}
+
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	waitForJobs(t, database, jobs)
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	for _, id := range printerIDs {
+		if pm.maxActive[id] != 1 {
+			t.Errorf("printer %d printed %d jobs at once, want 1", id, pm.maxActive[id])
+		}
+	}
+	if pm.maxOverall < 2 {
+		t.Errorf("at most %d jobs printed at once across printers, want different printers in parallel", pm.maxOverall)
+	}
+}
+
+func TestClaimNextSkipsPrinterThatIsPrinting(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	q := NewQueue(database, nil, nil, nil, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: fmt.Sprintf("PRINT %d", i), Copies: 1}); err != nil {
+			t.Fatalf("enqueue job %d: %v", i, err)
+		}
+	}
+
+	first, err := q.claimNext()
+	if err != nil || first == nil {
+		t.Fatalf("claim first job: %v, %v", first, err)
+	}
+	if job, err := q.claimNext(); err != nil || job != nil {
+		t.Fatalf("claimed %v (%v) while the printer was printing, want nothing", job, err)
+	}
+
+	q.releasePrinter(printerID)
+	second, err := q.claimNext()
+	if err != nil || second == nil || second.TSPLContent != "PRINT 1" {
+		t.Fatalf("claim after release: %v, %v, want the second job", second, err)
+	}
+}
@@ -1,12 +1,15 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +24,7 @@ var (
 	ErrTimeout              = errors.New("operation timed out")
 	ErrInvalidStatus        = errors.New("invalid status response")
 	ErrPrinterCannotPrint   = errors.New("printer cannot print in current state")
+	ErrPrinterBusy          = errors.New("printer is busy")
 	ErrPrinterAlreadyExists = errors.New("printer already exists")
 )
 
@@ -29,6 +33,17 @@ const (
 	statusCommand          = "\x1b!?"
 	statusResponseLength   = 4
 	defaultReadWriteTimeout = 10 * time.Second
+	infoQueryTimeout       = 2 * time.Second
+	infoUnknown            = "unknown"
+)
+
+// Info queries. Firmware has no immediate command, so it is read from the
+// _VERSION$ system variable.
+const (
+	infoModelCommand    = "~!T"
+	infoCodepageCommand = "~!I"
+	infoMileageCommand  = "~!@"
+	infoFirmwareCommand = "OUT \"\",_VERSION$\r\n"
 )
 
 var printerStateMap = map[byte]string{
@@ -68,13 +83,24 @@ var mediaErrorMap = map[byte]string{
 	'`': "head_open",
 }
 
+// PrinterManager owns the printers and their connections. mu guards the
+// maps and every field of the *Printer values in printers, which are never
+// handed out: GetPrinter and ListPrinters return copies. ioLocks serializes
+// use of each printer's cached connection, so a status poll cannot
+// interleave with a print. reconnects and pendingStatus, also guarded by
+// mu, hold each flapping printer's reconnect backoff and debounced status
+// change.
 type PrinterManager struct {
 	db            *sql.DB
 	config        *config.PrintersConfig
 	printers      map[int64]*Printer
 	connections   map[int64]net.Conn
+	ioLocks       map[int64]*sync.Mutex
+	reconnects    map[int64]*reconnectState
+	pendingStatus map[int64]*pendingStatusChange
 	mu            sync.RWMutex
 	webhookSender WebhookSender
+	events        *EventLog
 	stopCh        chan struct{}
 	wg            sync.WaitGroup
 }
@@ -85,16 +111,29 @@ func NewPrinterManager(database *sql.DB, cfg *config.PrintersConfig, webhookSend
 		config:        cfg,
 		printers:      make(map[int64]*Printer),
 		connections:   make(map[int64]net.Conn),
+		ioLocks:       make(map[int64]*sync.Mutex),
+		reconnects:    make(map[int64]*reconnectState),
+		pendingStatus: make(map[int64]*pendingStatusChange),
 		webhookSender: webhookSender,
 		stopCh:        make(chan struct{}),
 	}
 }
 
+// SetEventLog makes the manager record printer status changes in l.
+func (pm *PrinterManager) SetEventLog(l *EventLog) {
+	pm.events = l
+}
+
 func (pm *PrinterManager) Start() {
 	pm.loadPrintersFromDB()
 	
 	pm.wg.Add(1)
 	go pm.healthCheckLoop()
+	
+	if pm.config.RecountInterval > 0 {
+		pm.wg.Add(1)
+		go pm.recountLoop(pm.config.RecountInterval)
+	}
 }
 
 func (pm *PrinterManager) Stop() {
@@ -107,6 +146,9 @@ func (pm *PrinterManager) Stop() {
 			delete(pm.connections, id)
 		}
 	}
+	for id := range pm.pendingStatus {
+		pm.forgetPrinterState(id)
+	}
 	pm.mu.Unlock()
 	
 	pm.wg.Wait()
@@ -125,8 +167,8 @@ func (pm *PrinterManager) loadPrintersFromDB() {
 		err := rows.Scan(
 			&p.ID, &p.Name, &p.IPAddress, &p.Port, &p.DPI,
 			&p.LabelWidthMM, &p.LabelHeightMM, &p.GapMM,
-			&p.Status, &lastSeenAt, &p.TotalPrints,
-			new(any), new(any),
+			&p.Status, &lastSeenAt, &p.TotalPrints, &p.DefaultTemplateID,
+			&p.LineEnding, &p.Encoding, &p.FallbackPrinterID, &p.Group, &p.FeedOnError, (*db.CommandList)(&p.InitCommands), &p.StatusLength, &p.Type, &p.SpoolDir, new(any), new(any),
 		)
 		if err != nil {
 			continue
@@ -134,7 +176,9 @@ func (pm *PrinterManager) loadPrintersFromDB() {
 		if lastSeenAt.Valid {
 			p.LastSeenAt = &lastSeenAt.Time
 		}
+		pm.mu.Lock()
 		pm.printers[p.ID] = &p
+		pm.mu.Unlock()
 	}
 }
 
@@ -149,17 +193,22 @@ func (pm *PrinterManager) AddPrinter(p *Printer) error {
 	if p.Port == 0 {
 		p.Port = defaultTCPPort
 	}
+	if p.FeedOnError == "" {
+		p.FeedOnError = FeedOnErrorNone
+	}
 	p.Status = "unknown"
 	
 	_, err := pm.db.Exec(db.InsertPrinter,
 		p.Name, p.IPAddress, p.Port, p.DPI,
-		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.Status,
+		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.Status, p.DefaultTemplateID,
+		p.LineEnding, p.Encoding, p.FallbackPrinterID, p.Group, p.FeedOnError, db.CommandList(p.InitCommands), statusLength(p), printerType(p), p.SpoolDir,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert printer: %w", err)
 	}
 	
-	pm.printers[p.ID] = p
+	stored := *p
+	pm.printers[p.ID] = &stored
 	
 	return nil
 }
@@ -185,6 +234,8 @@ func (pm *PrinterManager) RemovePrinter(id int64) error {
 	}
 	
 	delete(pm.printers, id)
+	delete(pm.ioLocks, id)
+	pm.forgetPrinterState(id)
 	
 	return nil
 }
@@ -198,7 +249,8 @@ func (pm *PrinterManager) GetPrinter(id int64) (*Printer, error) {
 		return nil, ErrPrinterNotFound
 	}
 	
-	return p, nil
+	snapshot := *p
+	return &snapshot, nil
 }
 
 func (pm *PrinterManager) ListPrinters() []*Printer {
@@ -207,12 +259,13 @@ func (pm *PrinterManager) ListPrinters() []*Printer {
 	
 	printers := make([]*Printer, 0, len(pm.printers))
 	for _, p := range pm.printers {
-		printers = append(printers, p)
+		snapshot := *p
+		printers = append(printers, &snapshot)
 	}
 	return printers
 }
 
-func (pm *PrinterManager) connect(id int64) (net.Conn, error) {
+func (pm *PrinterManager) connect(ctx context.Context, id int64) (net.Conn, error) {
 	pm.mu.RLock()
 	p, exists := pm.printers[id]
 	if !exists {
@@ -220,21 +273,31 @@ func (pm *PrinterManager) connect(id int64) (net.Conn, error) {
 		return nil, ErrPrinterNotFound
 	}
 	
+	if isFilePrinter(p) {
+		pm.mu.RUnlock()
+		return nil, ErrNotNetworkPrinter
+	}
 	if conn, exists := pm.connections[id]; exists && conn != nil {
 		pm.mu.RUnlock()
 		return conn, nil
 	}
+	address := net.JoinHostPort(p.IPAddress, strconv.Itoa(p.Port))
+	snapshot := *p
 	pm.mu.RUnlock()
 	
-	address := fmt.Sprintf("%s:%d", p.IPAddress, p.Port)
 	timeout := pm.config.ConnectionTimeout
 	if timeout == 0 {
 		timeout = defaultReadWriteTimeout
 	}
 	
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := pm.dialPrinter(ctx, id, address, timeout)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+		return nil, err
+	}
+	
+	if err := pm.sendInitCommands(ctx, &snapshot, conn, timeout); err != nil {
+		_ = conn.Close()
+		return nil, err
 	}
 	
 	pm.mu.Lock()
@@ -256,22 +319,70 @@ func (pm *PrinterManager) disconnect(id int64) {
 	}
 }
 
-func (pm *PrinterManager) reconnect(id int64) (net.Conn, error) {
+// ioLock returns the mutex that serializes use of a printer's cached
+// connection.
+func (pm *PrinterManager) ioLock(id int64) *sync.Mutex {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	l, ok := pm.ioLocks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		pm.ioLocks[id] = l
+	}
+	return l
+}
+
+func (pm *PrinterManager) reconnect(ctx context.Context, id int64) (net.Conn, error) {
 	pm.disconnect(id)
-	return pm.connect(id)
+	return pm.connect(ctx, id)
 }
 
-func (pm *PrinterManager) CheckStatus(id int64) (*PrinterStatus, error) {
+// abortOnCancel makes pending I/O on a printer connection fail as soon as
+// ctx is done. The returned release function must be called once the I/O
+// is over; it drops the cached connection if the abort fired, since the
+// connection may be mid-command and its deadline is no longer usable.
+func (pm *PrinterManager) abortOnCancel(ctx context.Context, id int64, conn net.Conn) (release func()) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		if !stop() {
+			pm.disconnect(id)
+		}
+	}
+}
+
+// CheckStatus queries a printer's status. If ctx is done before the
+// printer answers, the query is abandoned and ctx's error is returned
+// without changing the printer's recorded status.
+func (pm *PrinterManager) CheckStatus(ctx context.Context, id int64) (*PrinterStatus, error) {
 	pm.mu.RLock()
 	p, exists := pm.printers[id]
+	var length int
+	if exists {
+		length = statusLength(p)
+	}
+	pm.mu.RUnlock()
 	if !exists {
-		pm.mu.RUnlock()
 		return nil, ErrPrinterNotFound
 	}
-	pm.mu.RUnlock()
+	if p, ok := pm.filePrinter(id); ok {
+		return pm.checkFileStatus(id, p), nil
+	}
 	
-	conn, err := pm.connect(id)
+	if pm.config.DedicatedStatusConnection {
+		return pm.checkStatusDedicated(ctx, id)
+	}
+	
+	ioLock := pm.ioLock(id)
+	ioLock.Lock()
+	defer ioLock.Unlock()
+	
+	conn, err := pm.connect(ctx, id)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		status := &PrinterStatus{
 			IsOnline:    false,
 			CanPrint:    false,
@@ -288,11 +399,22 @@ func (pm *PrinterManager) CheckStatus(id int64) (*PrinterStatus, error) {
 	
 	deadline := time.Now().Add(timeout)
 	_ = conn.SetDeadline(deadline)
+	release := pm.abortOnCancel(ctx, id, conn)
+	defer func() { release() }()
 	
 	_, err = conn.Write([]byte(statusCommand))
 	if err != nil {
-		conn, err = pm.reconnect(id)
+		release()
+		release = func() {}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			pm.disconnect(id)
+			return nil, ctxErr
+		}
+		conn, err = pm.reconnect(ctx, id)
 		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
 			status := &PrinterStatus{
 				IsOnline:    false,
 				CanPrint:    false,
@@ -302,9 +424,13 @@ func (pm *PrinterManager) CheckStatus(id int64) (*PrinterStatus, error) {
 			return status, err
 		}
 		_ = conn.SetDeadline(deadline)
+		release = pm.abortOnCancel(ctx, id, conn)
 		_, err = conn.Write([]byte(statusCommand))
 		if err != nil {
 			pm.disconnect(id)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
 			status := &PrinterStatus{
 				IsOnline:    false,
 				CanPrint:    false,
@@ -315,11 +441,15 @@ func (pm *PrinterManager) CheckStatus(id int64) (*PrinterStatus, error) {
 		}
 	}
 	
-	response := make([]byte, statusResponseLength)
+	response := make([]byte, length)
 	totalRead := 0
-	for totalRead < statusResponseLength {
+	for totalRead < length {
 		n, err := conn.Read(response[totalRead:])
 		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				pm.disconnect(id)
+				return nil, ctxErr
+			}
 			if errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded) {
 				break
 			}
@@ -335,7 +465,69 @@ func (pm *PrinterManager) CheckStatus(id int64) (*PrinterStatus, error) {
 		totalRead += n
 	}
 	
-	if totalRead < statusResponseLength {
+	return pm.applyStatusResponse(id, response[:totalRead], length)
+}
+
+// checkStatusDedicated polls status over a connection opened just for the
+// query and closed afterwards, so a print in progress on the cached
+// connection is never interrupted or torn down by a failed poll.
+func (pm *PrinterManager) checkStatusDedicated(ctx context.Context, id int64) (*PrinterStatus, error) {
+	pm.mu.RLock()
+	p, exists := pm.printers[id]
+	if !exists {
+		pm.mu.RUnlock()
+		return nil, ErrPrinterNotFound
+	}
+	address := net.JoinHostPort(p.IPAddress, strconv.Itoa(p.Port))
+	length := statusLength(p)
+	pm.mu.RUnlock()
+
+	timeout := pm.config.ConnectionTimeout
+	if timeout == 0 {
+		timeout = defaultReadWriteTimeout
+	}
+
+	offline := func(err error) (*PrinterStatus, error) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		pm.updatePrinterStatus(id, "offline")
+		return &PrinterStatus{LastChecked: time.Now()}, err
+	}
+
+	conn, err := pm.dialPrinter(ctx, id, address, timeout)
+	if err != nil {
+		return offline(err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if _, err := conn.Write([]byte(statusCommand)); err != nil {
+		return offline(fmt.Errorf("%w: %v", ErrConnectionFailed, err))
+	}
+
+	response := make([]byte, length)
+	n, err := io.ReadFull(conn, response)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) && !isTimeout(err) {
+		return offline(fmt.Errorf("%w: %v", ErrConnectionFailed, err))
+	}
+
+	return pm.applyStatusResponse(id, response[:n], length)
+}
+
+// applyStatusResponse records the printer state described by a status
+// response. A response shorter than the length bytes the printer is
+// configured to send marks the printer as errored.
+func (pm *PrinterManager) applyStatusResponse(id int64, response []byte, length int) (*PrinterStatus, error) {
+	if len(response) < length {
 		status := &PrinterStatus{
 			IsOnline:    false,
 			CanPrint:    false,
@@ -344,48 +536,16 @@ func (pm *PrinterManager) CheckStatus(id int64) (*PrinterStatus, error) {
 		pm.updatePrinterStatus(id, "error")
 		return status, ErrInvalidStatus
 	}
-	
+
 	status := pm.parseStatus(response)
 	status.IsOnline = true
 	status.LastChecked = time.Now()
 	status.CanPrint = status.PrinterState == "normal" || status.PrinterState == "standby" || status.PrinterState == "idle"
-	
+
 	newStatus := pm.determineStatusString(status)
 	pm.updatePrinterStatus(id, newStatus)
-	
-	return status, nil
-}
 
-func (pm *PrinterManager) parseStatus(response []byte) *PrinterStatus {
-	status := &PrinterStatus{
-		RawStatus: [4]byte{response[0], response[1], response[2], response[3]},
-	}
-	
-	if state, ok := printerStateMap[response[0]]; ok {
-		status.PrinterState = state
-	} else {
-		status.PrinterState = "unknown"
-	}
-	
-	if warning, ok := warningMap[response[1]]; ok {
-		status.Warning = warning
-	} else {
-		status.Warning = "unknown"
-	}
-	
-	if err, ok := errorMap[response[2]]; ok {
-		status.Error = err
-	} else {
-		status.Error = "unknown"
-	}
-	
-	if mediaErr, ok := mediaErrorMap[response[3]]; ok {
-		status.MediaError = mediaErr
-	} else {
-		status.MediaError = "unknown"
-	}
-	
-	return status
+	return status, nil
 }
 
 func (pm *PrinterManager) determineStatusString(status *PrinterStatus) string {
@@ -428,22 +588,13 @@ func (pm *PrinterManager) updatePrinterStatus(id int64, status string) {
 	
 	_, _ = pm.db.Exec(db.UpdatePrinterStatus, status, id)
 	
-	if oldStatus != status && pm.webhookSender != nil {
-		go pm.webhookSender.SendPrinterStatusChange(id, p.Name, oldStatus, status, nil)
+	if oldStatus != status {
+		pm.emitStatusChange(id, p.Name, oldStatus, status)
 	}
 }
 
 func (pm *PrinterManager) CheckAllStatuses() {
-	pm.mu.RLock()
-	ids := make([]int64, 0, len(pm.printers))
-	for id := range pm.printers {
-		ids = append(ids, id)
-	}
-	pm.mu.RUnlock()
-	
-	for _, id := range ids {
-		_, _ = pm.CheckStatus(id)
-	}
+	pm.RefreshStatuses(context.Background(), 1)
 }
 
 func (pm *PrinterManager) healthCheckLoop() {
@@ -469,17 +620,32 @@ func (pm *PrinterManager) healthCheckLoop() {
 	}
 }
 
-func (pm *PrinterManager) SendCommand(id int64, tspl string) error {
+// SendCommand writes tspl to the printer. Cancelling ctx aborts the write
+// and returns ctx's error; the printer may have received part of the data.
+// File printers only take prints, so SendCommand returns
+// ErrNotNetworkPrinter for them.
+func (pm *PrinterManager) SendCommand(ctx context.Context, id int64, tspl string) error {
 	pm.mu.RLock()
 	p, exists := pm.printers[id]
 	if !exists {
 		pm.mu.RUnlock()
 		return ErrPrinterNotFound
 	}
+	snapshot := *p
 	pm.mu.RUnlock()
+	if isFilePrinter(&snapshot) {
+		return ErrNotNetworkPrinter
+	}
+	
+	ioLock := pm.ioLock(id)
+	ioLock.Lock()
+	defer ioLock.Unlock()
 	
-	conn, err := pm.connect(id)
+	conn, err := pm.connect(ctx, id)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		return ErrPrinterOffline
 	}
 	
@@ -489,19 +655,152 @@ func (pm *PrinterManager) SendCommand(id int64, tspl string) error {
 	}
 	
 	_ = conn.SetDeadline(time.Now().Add(timeout))
+	release := pm.abortOnCancel(ctx, id, conn)
+	defer release()
 	
-	_, err = conn.Write([]byte(tspl))
+	data, err := EncodeForPrinter(&snapshot, tspl)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(data)
 	if err != nil {
 		_ = conn.Close()
 		pm.disconnect(id)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		return fmt.Errorf("%w: %v", ErrConnectionFailed, err)
 	}
 	
 	return nil
 }
 
-func (pm *PrinterManager) Print(id int64, tspl string, copies int) error {
-	status, err := pm.CheckStatus(id)
+// GetPrinterInfo queries a printer for its model, firmware, mileage and
+// codepage. Each query is attempted independently so printers that ignore
+// some of them still return the fields they do answer.
+func (pm *PrinterManager) GetPrinterInfo(id int64) (*PrinterInfo, error) {
+	pm.mu.RLock()
+	_, exists := pm.printers[id]
+	pm.mu.RUnlock()
+	if !exists {
+		return nil, ErrPrinterNotFound
+	}
+
+	ioLock := pm.ioLock(id)
+	ioLock.Lock()
+	defer ioLock.Unlock()
+
+	conn, err := pm.connect(context.Background(), id)
+	if err == ErrNotNetworkPrinter {
+		return nil, err
+	}
+	if err != nil {
+		return nil, ErrPrinterOffline
+	}
+
+	info := &PrinterInfo{Complete: true, QueriedAt: time.Now()}
+	fields := []struct {
+		command string
+		dest    *string
+	}{
+		{infoModelCommand, &info.Model},
+		{infoFirmwareCommand, &info.Firmware},
+		{infoMileageCommand, &info.Mileage},
+		{infoCodepageCommand, &info.Codepage},
+	}
+
+	session := pm.newQuerySession(id, conn)
+	for _, f := range fields {
+		value, err := session.query(f.command)
+		if err != nil && !isTimeout(err) {
+			pm.disconnect(id)
+			return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+		}
+		if value == "" {
+			value = infoUnknown
+			info.Complete = false
+		}
+		*f.dest = value
+	}
+
+	return info, nil
+}
+
+// queryPrinter sends a query command and reads a single response line. An
+// empty string with a timeout error means the printer did not answer.
+func queryPrinter(conn net.Conn, command string, timeout time.Duration) (string, error) {
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(command)); err != nil {
+		return "", err
+	}
+
+	var response []byte
+	buf := make([]byte, 128)
+	for {
+		n, err := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		if i := bytes.IndexAny(response, "\r\n"); i >= 0 {
+			return strings.TrimSpace(string(response[:i])), nil
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || isTimeout(err) {
+				return strings.TrimSpace(string(response)), err
+			}
+			return "", err
+		}
+	}
+}
+
+// querySession sends queries to a printer one at a time. A printer can
+// still answer a query after it timed out, and on the same connection that
+// late answer would be read as the next query's, so a timeout drops the
+// connection and the next query is sent on a fresh one.
+type querySession struct {
+	conn   net.Conn
+	redial func() (net.Conn, error)
+	drop   func()
+}
+
+// newQuerySession starts a query session on printer id's cached
+// connection conn.
+func (pm *PrinterManager) newQuerySession(id int64, conn net.Conn) *querySession {
+	return &querySession{
+		conn:   conn,
+		redial: func() (net.Conn, error) { return pm.connect(context.Background(), id) },
+		drop:   func() { pm.disconnect(id) },
+	}
+}
+
+// query sends command and reads the answer as queryPrinter does. A failure
+// to redial is an ErrConnectionFailed, never a timeout.
+func (s *querySession) query(command string) (string, error) {
+	if s.conn == nil {
+		conn, err := s.redial()
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+		}
+		s.conn = conn
+	}
+	value, err := queryPrinter(s.conn, command, infoQueryTimeout)
+	if isTimeout(err) {
+		s.drop()
+		s.conn = nil
+	}
+	return value, err
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Print checks that the printer is ready and sends tspl copies times. A
+// file printer writes the copies to a new file in its spool directory
+// instead. Cancelling ctx abandons the status check or the send, whichever
+// is in progress.
+func (pm *PrinterManager) Print(ctx context.Context, id int64, tspl string, copies int) error {
+	status, err := pm.CheckStatus(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -511,17 +810,24 @@ func (pm *PrinterManager) Print(id int64, tspl string, copies int) error {
 	}
 	
 	if !status.CanPrint {
+		if status.PrinterState == "feeding" {
+			return ErrPrinterBusy
+		}
 		return ErrPrinterCannotPrint
 	}
 	
 	fullTSPL := tspl
 	if copies > 1 {
 		for i := 1; i < copies; i++ {
-			fullTSPL += "\r\n" + tspl
+			fullTSPL += "\n" + tspl
 		}
 	}
 	
-	err = pm.SendCommand(id, fullTSPL)
+	if p, ok := pm.filePrinter(id); ok {
+		_, err = writeSpoolFile(p, fullTSPL)
+	} else {
+		err = pm.SendCommand(ctx, id, fullTSPL)
+	}
 	if err != nil {
 		return err
 	}
@@ -551,8 +857,8 @@ func (pm *PrinterManager) PausePrinter(id int64) error {
 	
 	_, _ = pm.db.Exec(db.UpdatePrinterStatus, "paused", id)
 	
-	if oldStatus != "paused" && pm.webhookSender != nil {
-		go pm.webhookSender.SendPrinterStatusChange(id, p.Name, oldStatus, "paused", nil)
+	if oldStatus != "paused" {
+		pm.emitStatusChange(id, p.Name, oldStatus, "paused")
 	}
 	
 	return nil
@@ -572,8 +878,8 @@ func (pm *PrinterManager) ResumePrinter(id int64) error {
 	
 	_, _ = pm.db.Exec(db.UpdatePrinterStatus, "online", id)
 	
-	if oldStatus != "online" && pm.webhookSender != nil {
-		go pm.webhookSender.SendPrinterStatusChange(id, p.Name, oldStatus, "online", nil)
+	if oldStatus != "online" {
+		pm.emitStatusChange(id, p.Name, oldStatus, "online")
 	}
 	
 	return nil
@@ -589,13 +895,16 @@ func (pm *PrinterManager) UpdatePrinter(p *Printer) error {
 	
 	_, err := pm.db.Exec(db.UpdatePrinter,
 		p.Name, p.IPAddress, p.Port, p.DPI,
-		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.ID,
+		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.DefaultTemplateID,
+		p.LineEnding, p.Encoding, p.FallbackPrinterID, p.Group, feedOnError(p), db.CommandList(p.InitCommands), statusLength(p), p.SpoolDir, p.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update printer: %w", err)
 	}
 	
-	pm.printers[p.ID] = p
+	stored := *p
+	pm.printers[p.ID] = &stored
+	pm.forgetPrinterState(p.ID)
 	
 	if conn, exists := pm.connections[p.ID]; exists && conn != nil {
 		conn.Close()
@@ -606,7 +915,7 @@ func (pm *PrinterManager) UpdatePrinter(p *Printer) error {
 }
 
 func (pm *PrinterManager) GetConnection(id int64) (net.Conn, error) {
-	return pm.connect(id)
+	return pm.connect(context.Background(), id)
 }
 
 func (pm *PrinterManager) CloseConnection(id int64) {
@@ -0,0 +1,450 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+// newScriptedPrinter starts a listener that answers each command it reads
+// with the reply replies holds for it, and stays silent on any other, and
+// returns a manager with it added as printer 1.
+func newScriptedPrinter(t *testing.T, replies map[string]string) *PrinterManager {
+	t.Helper()
+
+	return newListeningPrinter(t, func(conn net.Conn) {
+		buf := make([]byte, 512)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			if reply, ok := replies[string(buf[:n])]; ok {
+				conn.Write([]byte(reply))
+			}
+		}
+	})
+}
+
+// newReadyPrinter starts a listener that answers every status query as a
+// ready printer, however the queries are split or joined with print data
+// on the wire, and returns a manager with it added as printer 1.
+func newReadyPrinter(t *testing.T) *PrinterManager {
+	t.Helper()
+
+	return newListeningPrinter(t, func(conn net.Conn) {
+		buf := make([]byte, 4096)
+		var pending []byte
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			pending = append(pending, buf[:n]...)
+			for {
+				i := bytes.Index(pending, []byte(statusCommand))
+				if i < 0 {
+					break
+				}
+				pending = pending[i+len(statusCommand):]
+				if _, err := conn.Write([]byte("@@@@")); err != nil {
+					return
+				}
+			}
+			if keep := len(statusCommand) - 1; len(pending) > keep {
+				pending = pending[len(pending)-keep:]
+			}
+		}
+	})
+}
+
+// newListeningPrinter starts a listener that hands each connection to
+// serve, and returns a manager with it added as printer 1.
+func newListeningPrinter(t *testing.T, serve func(net.Conn)) *PrinterManager {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	})
+
+	addr := ln.Addr().(*net.TCPAddr)
+	pm := NewPrinterManager(nil, &config.PrintersConfig{ConnectionTimeout: 2 * time.Second}, nil)
+	pm.printers[1] = &Printer{ID: 1, IPAddress: addr.IP.String(), Port: addr.Port, Status: "online"}
+	t.Cleanup(func() { pm.CloseConnection(1) })
+	return pm
+}
+
+// newStalledPrinter returns a manager whose printer 1 accepts connections
+// but never reads from or answers them, like a printer that has hung.
+func newStalledPrinter(t *testing.T) *PrinterManager {
+	t.Helper()
+
+	stop := make(chan struct{})
+	pm := newListeningPrinter(t, func(net.Conn) { <-stop })
+	t.Cleanup(func() { close(stop) })
+	return pm
+}
+
+// cancelAfter returns a context cancelled after d.
+func cancelAfter(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(d, cancel)
+	t.Cleanup(func() {
+		timer.Stop()
+		cancel()
+	})
+	return ctx
+}
+
+func TestGetPrinterInfo(t *testing.T) {
+	pm := newScriptedPrinter(t, map[string]string{
+		infoModelCommand:    "TSC TE200\r\n",
+		infoFirmwareCommand: "V1.5.2 EZ\r\n",
+		infoMileageCommand:  "1234.5\r\n",
+		infoCodepageCommand: "8,437\r\n",
+	})
+
+	info, err := pm.GetPrinterInfo(1)
+	if err != nil {
+		t.Fatalf("GetPrinterInfo: %v", err)
+	}
+	if info.Model != "TSC TE200" || info.Firmware != "V1.5.2 EZ" || info.Mileage != "1234.5" || info.Codepage != "8,437" {
+		t.Errorf("info is %+v, want the printer's canned answers", info)
+	}
+	if !info.Complete {
+		t.Error("info is incomplete, want every field answered")
+	}
+}
+
+func TestGetPrinterInfoPartial(t *testing.T) {
+	// An older printer that only knows its model.
+	pm := newScriptedPrinter(t, map[string]string{infoModelCommand: "TSC TTP-244\n"})
+
+	info, err := pm.GetPrinterInfo(1)
+	if err != nil {
+		t.Fatalf("GetPrinterInfo: %v", err)
+	}
+	if info.Model != "TSC TTP-244" {
+		t.Errorf("model is %q, want TSC TTP-244", info.Model)
+	}
+	for name, value := range map[string]string{"firmware": info.Firmware, "mileage": info.Mileage, "codepage": info.Codepage} {
+		if value != infoUnknown {
+			t.Errorf("%s is %q, want %q", name, value, infoUnknown)
+		}
+	}
+	if info.Complete {
+		t.Error("info is complete, want it marked partial")
+	}
+}
+
+func TestGetPrinterInfoIgnoresLateReplies(t *testing.T) {
+	replies := map[string]string{
+		infoFirmwareCommand: "V1.5.2 EZ\r\n",
+		infoMileageCommand:  "1234.5\r\n",
+		infoCodepageCommand: "8,437\r\n",
+	}
+	// A busy printer that answers the model query only after it timed out.
+	pm := newListeningPrinter(t, func(conn net.Conn) {
+		buf := make([]byte, 512)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			command := string(buf[:n])
+			if command == infoModelCommand {
+				time.Sleep(infoQueryTimeout + 200*time.Millisecond)
+				conn.Write([]byte("TSC TE200\r\n"))
+				continue
+			}
+			if reply, ok := replies[command]; ok {
+				conn.Write([]byte(reply))
+			}
+		}
+	})
+
+	info, err := pm.GetPrinterInfo(1)
+	if err != nil {
+		t.Fatalf("GetPrinterInfo: %v", err)
+	}
+	if info.Model != infoUnknown || info.Firmware != "V1.5.2 EZ" || info.Mileage != "1234.5" || info.Codepage != "8,437" {
+		t.Errorf("info is %+v, want the model unknown and every other answer matched to its query", info)
+	}
+}
+
+func TestGetPrinterInfoUnknownPrinter(t *testing.T) {
+	pm := NewPrinterManager(nil, &config.PrintersConfig{}, nil)
+	if _, err := pm.GetPrinterInfo(7); err != ErrPrinterNotFound {
+		t.Errorf("GetPrinterInfo returned %v, want ErrPrinterNotFound", err)
+	}
+}
+
+func TestEncodeForPrinterLineEndings(t *testing.T) {
+	tspl := "SIZE 50 mm, 30 mm\nCLS\r\nPRINT 1\n"
+	for _, tt := range []struct {
+		lineEnding string
+		want       string
+	}{
+		{"", "SIZE 50 mm, 30 mm\nCLS\nPRINT 1\n"},
+		{LineEndingLF, "SIZE 50 mm, 30 mm\nCLS\nPRINT 1\n"},
+		{LineEndingCRLF, "SIZE 50 mm, 30 mm\r\nCLS\r\nPRINT 1\r\n"},
+	} {
+		got, err := EncodeForPrinter(&Printer{LineEnding: tt.lineEnding}, tspl)
+		if err != nil {
+			t.Fatalf("encode with %q: %v", tt.lineEnding, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("encode with %q gave %q, want %q", tt.lineEnding, got, tt.want)
+		}
+	}
+}
+
+func TestDedicatedStatusConnectionLeavesPrintConnection(t *testing.T) {
+	pm := newScriptedPrinter(t, map[string]string{statusCommand: "@@@@"})
+	pm.db = newTestDB(t)
+	pm.config.DedicatedStatusConnection = true
+
+	printConn, err := pm.connect(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		status, err := pm.CheckStatus(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("CheckStatus: %v", err)
+		}
+		if !status.IsOnline {
+			t.Errorf("status is %+v, want the printer online", status)
+		}
+	}
+
+	pm.mu.RLock()
+	cached := pm.connections[1]
+	pm.mu.RUnlock()
+	if cached != printConn {
+		t.Fatal("polling replaced the cached print connection")
+	}
+	// The print connection still answers, so nothing was left unread on it
+	// and it was not closed.
+	_ = printConn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := printConn.Write([]byte(statusCommand)); err != nil {
+		t.Fatalf("write on print connection: %v", err)
+	}
+	reply := make([]byte, statusResponseLength)
+	if _, err := io.ReadFull(printConn, reply); err != nil || string(reply) != "@@@@" {
+		t.Errorf("print connection answered %q (%v), want @@@@", reply, err)
+	}
+}
+
+func TestConcurrentPrintAndPoll(t *testing.T) {
+	pm := newReadyPrinter(t)
+	pm.db = newTestDB(t)
+	const printerID = 1
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*rounds)
+	for i := 0; i < rounds; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			errs <- pm.Print(context.Background(), printerID, "CLS\nPRINT 1\n", 2)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := pm.CheckStatus(context.Background(), printerID)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			p, err := pm.GetPrinter(printerID)
+			if err == nil {
+				// Callers get a copy they are free to change.
+				p.Status = "scribbled"
+				p.TotalPrints = -1
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent call failed: %v", err)
+		}
+	}
+
+	p, err := pm.GetPrinter(printerID)
+	if err != nil {
+		t.Fatalf("GetPrinter: %v", err)
+	}
+	if p.TotalPrints != 2*rounds {
+		t.Errorf("total prints is %d, want %d", p.TotalPrints, 2*rounds)
+	}
+	if p.Status != "online" {
+		t.Errorf("status is %q, want online", p.Status)
+	}
+}
+
+func TestTestConnection(t *testing.T) {
+	pm := newScriptedPrinter(t, map[string]string{
+		statusCommand:       "@@@@",
+		infoModelCommand:    "TSC TE200\r\n",
+		infoFirmwareCommand: "V1.5.2 EZ\r\n",
+		labelWidthCommand:   "4.00\r\n",
+		labelHeightCommand:  "50.8 mm\r\n",
+	})
+	target := pm.printers[1]
+	delete(pm.printers, 1)
+
+	result, err := pm.TestConnection(target.IPAddress, target.Port)
+	if err != nil {
+		t.Fatalf("TestConnection: %v", err)
+	}
+	if result.Status == nil || !result.Status.IsOnline {
+		t.Fatalf("status is %+v, want the printer online", result.Status)
+	}
+	if result.Model != "TSC TE200" || result.Firmware != "V1.5.2 EZ" {
+		t.Errorf("printer is %q %q, want the canned model and firmware", result.Model, result.Firmware)
+	}
+	if result.LabelWidthMM == nil || *result.LabelWidthMM != 101.6 || result.LabelHeightMM == nil || *result.LabelHeightMM != 50.8 {
+		t.Errorf("label size is %v x %v, want 101.6 x 50.8 mm", result.LabelWidthMM, result.LabelHeightMM)
+	}
+	if len(pm.printers) != 0 || len(pm.connections) != 0 {
+		t.Errorf("manager has %d printers and %d connections, want the probe to leave it untouched", len(pm.printers), len(pm.connections))
+	}
+}
+
+func TestTestConnectionSilentPrinter(t *testing.T) {
+	pm := newScriptedPrinter(t, nil)
+	pm.config.ConnectionTimeout = 100 * time.Millisecond
+	target := pm.printers[1]
+
+	result, err := pm.TestConnection(target.IPAddress, target.Port)
+	if err != nil {
+		t.Fatalf("TestConnection: %v", err)
+	}
+	if result.Status != nil || result.StatusString != "unknown" {
+		t.Errorf("result is %+v, want a reachable printer with unknown status", result)
+	}
+}
+
+func TestTestConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	pm := NewPrinterManager(nil, &config.PrintersConfig{ConnectionTimeout: time.Second}, nil)
+	if _, err := pm.TestConnection(addr.IP.String(), addr.Port); !errors.Is(err, ErrConnectionFailed) {
+		t.Errorf("TestConnection returned %v, want ErrConnectionFailed", err)
+	}
+}
+
+func TestParseLabelDimension(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"4.00", 101.6},
+		{"2 inch", 50.8},
+		{"2in", 50.8},
+		{"50.8 mm", 50.8},
+		{"30mm", 30},
+		{"812 dot", 0},
+		{"", 0},
+		{"wide", 0},
+	}
+	for _, tt := range tests {
+		got := parseLabelDimension(tt.value)
+		if (got == nil) != (tt.want == 0) || (got != nil && *got != tt.want) {
+			t.Errorf("parseLabelDimension(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPrintReportsFeedingPrinterBusy(t *testing.T) {
+	pm := newScriptedPrinter(t, map[string]string{statusCommand: "F@@@"})
+	pm.db = newTestDB(t)
+
+	if err := pm.Print(context.Background(), 1, "PRINT 1\n", 1); !errors.Is(err, ErrPrinterBusy) {
+		t.Errorf("print to a feeding printer returned %v, want ErrPrinterBusy", err)
+	}
+}
+
+func TestSendCommandStopsWhenContextCancelled(t *testing.T) {
+	pm := newStalledPrinter(t)
+	ctx := cancelAfter(t, 100*time.Millisecond)
+
+	// Far more than the socket buffers hold, so the write blocks until
+	// the printer reads.
+	payload := strings.Repeat("A", 64<<20)
+	start := time.Now()
+	if err := pm.SendCommand(ctx, 1, payload); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendCommand returned %v, want context.Canceled", err)
+	}
+	// The connection timeout would only end the write after two seconds.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SendCommand took %s after cancellation, want it to stop promptly", elapsed)
+	}
+}
+
+func TestCheckStatusStopsWhenContextCancelled(t *testing.T) {
+	pm := newStalledPrinter(t)
+	ctx := cancelAfter(t, 100*time.Millisecond)
+
+	start := time.Now()
+	if _, err := pm.CheckStatus(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("CheckStatus returned %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CheckStatus took %s after cancellation, want it to stop promptly", elapsed)
+	}
+	if status := pm.printers[1].Status; status != "online" {
+		t.Errorf("cancelled check recorded status %q, want it left online", status)
+	}
+}
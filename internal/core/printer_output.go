@@ -0,0 +1,106 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Line endings a printer can require between TSPL commands.
+const (
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+)
+
+// printerEncodings maps CODEPAGE tokens to the charset used to encode data
+// for the printer. UTF-8 needs no conversion.
+var printerEncodings = map[string]encoding.Encoding{
+	"UTF-8":  nil,
+	"8859-1": charmap.ISO8859_1,
+	"1250":   charmap.Windows1250,
+	"1251":   charmap.Windows1251,
+	"1252":   charmap.Windows1252,
+	"1253":   charmap.Windows1253,
+	"1254":   charmap.Windows1254,
+	"1257":   charmap.Windows1257,
+	"437":    charmap.CodePage437,
+	"850":    charmap.CodePage850,
+	"852":    charmap.CodePage852,
+	"860":    charmap.CodePage860,
+	"863":    charmap.CodePage863,
+	"865":    charmap.CodePage865,
+	"866":    charmap.CodePage866,
+}
+
+// ValidatePrinterOutput checks a printer's line ending and encoding. Encoding
+// names are the same ones templates accept for codepage.
+func ValidatePrinterOutput(lineEnding, enc string) error {
+	switch lineEnding {
+	case "", LineEndingLF, LineEndingCRLF:
+	default:
+		return fmt.Errorf("invalid line ending %q (valid: lf, crlf)", lineEnding)
+	}
+	if _, err := lookupPrinterEncoding(enc); err != nil {
+		return err
+	}
+	return nil
+}
+
+func lookupPrinterEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return nil, nil
+	}
+	token, ok := codepageAliases[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+	enc, ok := printerEncodings[token]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+	return enc, nil
+}
+
+// EncodeForPrinter converts TSPL to the bytes sent to p: every line break is
+// rewritten to the printer's line ending and the text is encoded in its
+// charset. Generated TSPL always uses "\n", so this is the single place line
+// endings are decided. Binary BITMAP data is copied through untouched.
+func EncodeForPrinter(p *Printer, tspl string) ([]byte, error) {
+	enc, err := lookupPrinterEncoding(p.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	terminator := "\n"
+	if p.LineEnding == LineEndingCRLF {
+		terminator = "\r\n"
+	}
+
+	var out bytes.Buffer
+	for len(tspl) > 0 {
+		if n, ok := splitBitmapCommand(tspl); ok {
+			out.WriteString(tspl[:n])
+			tspl = tspl[n:]
+			continue
+		}
+
+		line, rest, hasBreak := strings.Cut(tspl, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		if enc != nil {
+			encoded, err := enc.NewEncoder().String(line)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode for printer %d as %s: %w", p.ID, p.Encoding, err)
+			}
+			line = encoded
+		}
+		out.WriteString(line)
+		if hasBreak {
+			out.WriteString(terminator)
+		}
+		tspl = rest
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Label size queries. The printer reports its configured media size from the
+// TSPL settings table.
+const (
+	labelWidthCommand  = "OUT \"\",GETSETTING$(\"CONFIG\",\"TSPL\",\"PAPER WIDTH\")\r\n"
+	labelHeightCommand = "OUT \"\",GETSETTING$(\"CONFIG\",\"TSPL\",\"PAPER SIZE\")\r\n"
+)
+
+// TestConnection probes a printer at ipAddress:port without adding it. It
+// dials a one-off connection, sends the status query and then asks for the
+// model, firmware and configured label size. Nothing is stored and the
+// manager's printers and connections are not touched.
+//
+// A failure to connect is returned as an error wrapping ErrTimeout or
+// ErrConnectionFailed. Once connected, queries the printer does not answer
+// are left empty rather than failing the test.
+func (pm *PrinterManager) TestConnection(ipAddress string, port int) (*ConnectionTest, error) {
+	if port == 0 {
+		port = defaultTCPPort
+	}
+	address := net.JoinHostPort(ipAddress, strconv.Itoa(port))
+
+	timeout := pm.config.ConnectionTimeout
+	if timeout == 0 {
+		timeout = defaultReadWriteTimeout
+	}
+
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		if isTimeout(err) {
+			return nil, fmt.Errorf("%w: %v", ErrTimeout, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+	defer conn.Close()
+
+	result := &ConnectionTest{StatusString: "unknown"}
+
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(statusCommand)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+	response := make([]byte, statusResponseLength)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || isTimeout(err) {
+			return result, nil
+		}
+		return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+
+	status := pm.parseStatus(response)
+	status.IsOnline = true
+	status.LastChecked = time.Now()
+	status.CanPrint = status.PrinterState == "normal" || status.PrinterState == "standby" || status.PrinterState == "idle"
+	result.Status = status
+	result.StatusString = pm.determineStatusString(status)
+
+	queries := []struct {
+		command string
+		apply   func(string)
+	}{
+		{infoModelCommand, func(v string) { result.Model = v }},
+		{infoFirmwareCommand, func(v string) { result.Firmware = v }},
+		{labelWidthCommand, func(v string) { result.LabelWidthMM = parseLabelDimension(v) }},
+		{labelHeightCommand, func(v string) { result.LabelHeightMM = parseLabelDimension(v) }},
+	}
+	session := &querySession{
+		conn:   conn,
+		redial: func() (net.Conn, error) { return net.DialTimeout("tcp", address, timeout) },
+	}
+	session.drop = func() { session.conn.Close() }
+	defer func() {
+		if session.conn != nil {
+			session.conn.Close()
+		}
+	}()
+	for _, q := range queries {
+		value, err := session.query(q.command)
+		if value != "" {
+			q.apply(value)
+		}
+		if err != nil && !isTimeout(err) {
+			// The connection is gone, so later queries cannot be answered.
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// parseLabelDimension converts a reported media dimension such as "4.00",
+// "2 inch" or "50.8 mm" to millimetres. A bare number is in inches, as it
+// is for the SIZE command. Values in dots or that do not parse give nil.
+func parseLabelDimension(value string) *float64 {
+	return parseMediaLength(value, false)
+}
+
+// parseMediaLength is parseLabelDimension, also accepting zero when
+// allowZero is set, as for a continuous media's gap.
+func parseMediaLength(value string, allowZero bool) *float64 {
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	number, unit := fields[0], ""
+	if len(fields) > 1 {
+		unit = fields[1]
+	} else if i := strings.IndexFunc(number, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	}); i > 0 {
+		number, unit = number[:i], number[i:]
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 || (n == 0 && !allowZero) {
+		return nil
+	}
+
+	switch strings.Trim(unit, "\"") {
+	case "mm":
+	case "", "in", "inch", "inches":
+		n *= 25.4
+	default:
+		return nil
+	}
+	return &n
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/logging"
+)
+
+// total_prints is kept as a running sum, so an increment that fails leaves
+// it behind the daily print_counters for good. A recount resets it to the
+// sum of the counters.
+
+// RecountTotalPrints resets the printer's total_prints to the sum of its
+// daily print counters and returns the previous and corrected totals.
+func (pm *PrinterManager) RecountTotalPrints(id int64) (previous, total int64, err error) {
+	err = pm.db.QueryRow("SELECT total_prints FROM printers WHERE id = ?", id).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrPrinterNotFound
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read printer: %w", err)
+	}
+	if _, err := pm.db.Exec(db.RecountPrinterPrints, id); err != nil {
+		return 0, 0, fmt.Errorf("failed to recount prints: %w", err)
+	}
+	if err := pm.db.QueryRow("SELECT total_prints FROM printers WHERE id = ?", id).Scan(&total); err != nil {
+		return 0, 0, fmt.Errorf("failed to read recounted prints: %w", err)
+	}
+
+	pm.mu.Lock()
+	if p, exists := pm.printers[id]; exists {
+		p.TotalPrints = total
+	}
+	pm.mu.Unlock()
+	return previous, total, nil
+}
+
+// RecountAllTotalPrints recounts total_prints for every printer.
+func (pm *PrinterManager) RecountAllTotalPrints() error {
+	if _, err := pm.db.Exec(db.RecountAllPrinterPrints); err != nil {
+		return fmt.Errorf("failed to recount prints: %w", err)
+	}
+
+	rows, err := pm.db.Query("SELECT id, total_prints FROM printers")
+	if err != nil {
+		return fmt.Errorf("failed to read recounted prints: %w", err)
+	}
+	totals := make(map[int64]int64)
+	for rows.Next() {
+		var id, total int64
+		if err := rows.Scan(&id, &total); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read recounted prints: %w", err)
+		}
+		totals[id] = total
+	}
+	rows.Close()
+
+	pm.mu.Lock()
+	for id, total := range totals {
+		if p, exists := pm.printers[id]; exists {
+			p.TotalPrints = total
+		}
+	}
+	pm.mu.Unlock()
+	return nil
+}
+
+func (pm *PrinterManager) recountLoop(interval time.Duration) {
+	defer pm.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pm.stopCh:
+			return
+		case <-ticker.C:
+			if err := pm.RecountAllTotalPrints(); err != nil {
+				logging.ForRequest("").Warn("printer print recount failed", "error", err)
+			}
+		}
+	}
+}
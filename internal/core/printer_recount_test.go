@@ -0,0 +1,53 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+func TestRecountTotalPrints(t *testing.T) {
+	database := newTestDB(t)
+	drifted := insertTestPrinter(t, database, "drifted")
+	idle := insertTestPrinter(t, database, "idle")
+	for _, row := range []struct {
+		date  string
+		count int
+	}{{"2026-10-15", 5}, {"2026-10-16", 7}} {
+		if _, err := database.Exec("INSERT INTO print_counters (printer_id, date, count) VALUES (?, ?, ?)", drifted, row.date, row.count); err != nil {
+			t.Fatalf("insert counter: %v", err)
+		}
+	}
+	// Two increments were lost, and the idle printer was over-counted.
+	database.Exec("UPDATE printers SET total_prints = 10 WHERE id = ?", drifted)
+	database.Exec("UPDATE printers SET total_prints = 4 WHERE id = ?", idle)
+
+	pm := NewPrinterManager(database, &config.PrintersConfig{}, nil)
+	pm.printers[drifted] = &Printer{ID: drifted, TotalPrints: 10}
+	pm.printers[idle] = &Printer{ID: idle, TotalPrints: 4}
+
+	previous, total, err := pm.RecountTotalPrints(drifted)
+	if err != nil {
+		t.Fatalf("RecountTotalPrints: %v", err)
+	}
+	if previous != 10 || total != 12 {
+		t.Errorf("recount went from %d to %d, want 10 to 12", previous, total)
+	}
+	if p, _ := pm.GetPrinter(drifted); p.TotalPrints != 12 {
+		t.Errorf("manager still has %d prints, want 12", p.TotalPrints)
+	}
+
+	if err := pm.RecountAllTotalPrints(); err != nil {
+		t.Fatalf("RecountAllTotalPrints: %v", err)
+	}
+	var stored int64
+	database.QueryRow("SELECT total_prints FROM printers WHERE id = ?", idle).Scan(&stored)
+	if p, _ := pm.GetPrinter(idle); stored != 0 || p.TotalPrints != 0 {
+		t.Errorf("printer without counters has %d stored and %d in the manager, want 0", stored, p.TotalPrints)
+	}
+
+	if _, _, err := pm.RecountTotalPrints(999); !errors.Is(err, ErrPrinterNotFound) {
+		t.Errorf("recount of an unknown printer returned %v, want ErrPrinterNotFound", err)
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// StatusRefresh is the outcome of refreshing one printer's status. Status
+// is one of the printer status strings, or "timeout" when the check had not
+// finished by the time the refresh gave up on it.
+type StatusRefresh struct {
+	PrinterID int64
+	Name      string
+	Status    string
+	Err       error
+}
+
+// RefreshStatuses checks every printer, running at most parallelism checks
+// at once, and returns one result per printer ordered by ID. Checks still
+// running when ctx is done are abandoned and reported as "timeout", and
+// those printers keep their previously recorded status.
+func (pm *PrinterManager) RefreshStatuses(ctx context.Context, parallelism int) []StatusRefresh {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	pm.mu.RLock()
+	results := make([]StatusRefresh, 0, len(pm.printers))
+	for id, p := range pm.printers {
+		results = append(results, StatusRefresh{PrinterID: id, Name: p.Name, Status: "timeout", Err: ErrTimeout})
+	}
+	pm.mu.RUnlock()
+	sort.Slice(results, func(i, j int) bool { return results[i].PrinterID < results[j].PrinterID })
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, parallelism)
+		done = make(chan struct{})
+	)
+
+dispatch:
+	for i := range results {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(i int, id int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			status, err := pm.CheckStatus(ctx, id)
+			result := "offline"
+			switch {
+			case err == nil:
+				result = pm.determineStatusString(status)
+			case errors.Is(err, ErrInvalidStatus):
+				result = "error"
+			case ctx.Err() != nil:
+				return
+			}
+
+			mu.Lock()
+			results[i].Status = result
+			results[i].Err = err
+			mu.Unlock()
+		}(i, results[i].PrinterID)
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]StatusRefresh(nil), results...)
+}
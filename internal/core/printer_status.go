@@ -0,0 +1,144 @@
+package core
+
+// Status response lengths. Most printers answer the status query with four
+// bytes: state, warning, error and media error. Older models answer with a
+// single bitmask byte, and some newer ones append four extended bytes to
+// the standard four.
+const (
+	StatusLengthSingle   = 1
+	StatusLengthStandard = statusResponseLength
+	StatusLengthExtended = 8
+)
+
+// Bits of a single-byte status response.
+const (
+	singleStatusHeadOpen   = 0x01
+	singleStatusPaperJam   = 0x02
+	singleStatusOutOfPaper = 0x04
+	singleStatusNoRibbon   = 0x08
+	singleStatusPaused     = 0x10
+	singleStatusPrinting   = 0x20
+	singleStatusCoverOpen  = 0x40
+	singleStatusOtherError = 0x80
+)
+
+// mediaDetailMap decodes the sixth byte of an extended status response,
+// which says more about why the printer is out of media.
+var mediaDetailMap = map[byte]string{
+	'@': "none",
+	'A': "gap_not_found",
+	'B': "black_mark_not_found",
+	'C': "paper_end",
+	'D': "paper_jam",
+	'E': "ribbon_end",
+}
+
+// statusLength returns how many bytes p answers a status query with.
+func statusLength(p *Printer) int {
+	if p.StatusLength == 0 {
+		return StatusLengthStandard
+	}
+	return p.StatusLength
+}
+
+// parseStatus decodes a status response of any supported length. The
+// response is kept whole in RawStatus.
+func (pm *PrinterManager) parseStatus(response []byte) *PrinterStatus {
+	var status *PrinterStatus
+	if len(response) < StatusLengthStandard {
+		status = parseSingleByteStatus(response[0])
+	} else {
+		status = parseStandardStatus(response)
+		if len(response) >= StatusLengthExtended {
+			parseExtendedStatus(status, response[StatusLengthStandard:])
+		}
+	}
+	status.RawStatus = append([]byte(nil), response...)
+	return status
+}
+
+func parseStandardStatus(response []byte) *PrinterStatus {
+	status := &PrinterStatus{}
+
+	if state, ok := printerStateMap[response[0]]; ok {
+		status.PrinterState = state
+	} else {
+		status.PrinterState = "unknown"
+	}
+
+	if warning, ok := warningMap[response[1]]; ok {
+		status.Warning = warning
+	} else {
+		status.Warning = "unknown"
+	}
+
+	if err, ok := errorMap[response[2]]; ok {
+		status.Error = err
+	} else {
+		status.Error = "unknown"
+	}
+
+	if mediaErr, ok := mediaErrorMap[response[3]]; ok {
+		status.MediaError = mediaErr
+	} else {
+		status.MediaError = "unknown"
+	}
+
+	return status
+}
+
+// parseSingleByteStatus decodes the bitmask a single-byte printer answers
+// with into the fields a four-byte response would have set.
+func parseSingleByteStatus(b byte) *PrinterStatus {
+	status := &PrinterStatus{
+		PrinterState: "normal",
+		Warning:      "none",
+		Error:        "none",
+		MediaError:   "none",
+	}
+
+	switch {
+	case b&singleStatusOtherError != 0:
+		status.PrinterState = "error"
+	case b&(singleStatusHeadOpen|singleStatusCoverOpen) != 0:
+		status.PrinterState = "head_open"
+	case b&singleStatusPaused != 0:
+		status.PrinterState = "paused"
+	case b&singleStatusPrinting != 0:
+		status.PrinterState = "feeding"
+	}
+
+	switch {
+	case b&singleStatusPaperJam != 0:
+		status.Error = "paper_jam"
+	case b&singleStatusOtherError != 0:
+		status.Error = "unknown"
+	}
+
+	switch {
+	case b&singleStatusOutOfPaper != 0 && b&singleStatusNoRibbon != 0:
+		status.MediaError = "paper_and_ribbon_empty"
+	case b&singleStatusOutOfPaper != 0:
+		status.MediaError = "paper_empty"
+	case b&singleStatusNoRibbon != 0:
+		status.MediaError = "ribbon_empty"
+	case b&(singleStatusHeadOpen|singleStatusCoverOpen) != 0:
+		status.MediaError = "head_open"
+	}
+
+	return status
+}
+
+// parseExtendedStatus decodes the bytes an extended response adds after
+// the standard four: the print head temperature in degrees Celsius, then a
+// media error detail. The last two bytes are reserved.
+func parseExtendedStatus(status *PrinterStatus, extended []byte) {
+	temperature := int(extended[0])
+	status.HeadTemperatureC = &temperature
+
+	if detail, ok := mediaDetailMap[extended[1]]; ok {
+		status.MediaDetail = detail
+	} else {
+		status.MediaDetail = "unknown"
+	}
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	pm := &PrinterManager{}
+
+	tests := []struct {
+		name     string
+		response string
+		want     PrinterStatus
+		status   string
+	}{
+		{"single byte ready", "\x00",
+			PrinterStatus{PrinterState: "normal", Warning: "none", Error: "none", MediaError: "none"}, "online"},
+		{"single byte out of paper and paused", "\x14",
+			PrinterStatus{PrinterState: "paused", Warning: "none", Error: "none", MediaError: "paper_empty"}, "paused"},
+		{"single byte head open", "\x01",
+			PrinterStatus{PrinterState: "head_open", Warning: "none", Error: "none", MediaError: "head_open"}, "error"},
+		{"single byte paper jam", "\x02",
+			PrinterStatus{PrinterState: "normal", Warning: "none", Error: "paper_jam", MediaError: "none"}, "error"},
+		{"single byte printing", "\x20",
+			PrinterStatus{PrinterState: "feeding", Warning: "none", Error: "none", MediaError: "none"}, "busy"},
+		{"standard ready", "@@@@",
+			PrinterStatus{PrinterState: "normal", Warning: "none", Error: "none", MediaError: "none"}, "online"},
+		{"standard paper low and empty", "@A@A",
+			PrinterStatus{PrinterState: "normal", Warning: "paper_low", Error: "none", MediaError: "paper_empty"}, "error"},
+		{"extended gap not found", "@@@A\x2aA@@",
+			PrinterStatus{PrinterState: "normal", Warning: "none", Error: "none", MediaError: "paper_empty", MediaDetail: "gap_not_found"}, "error"},
+		{"extended unknown detail", "@@@@\x1f\x7f@@",
+			PrinterStatus{PrinterState: "normal", Warning: "none", Error: "none", MediaError: "none", MediaDetail: "unknown"}, "online"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pm.parseStatus([]byte(tt.response))
+			if !bytes.Equal(got.RawStatus, []byte(tt.response)) {
+				t.Errorf("RawStatus = %x, want %x", got.RawStatus, tt.response)
+			}
+			if got.PrinterState != tt.want.PrinterState || got.Warning != tt.want.Warning ||
+				got.Error != tt.want.Error || got.MediaError != tt.want.MediaError || got.MediaDetail != tt.want.MediaDetail {
+				t.Errorf("parsed %+v, want %+v", got, tt.want)
+			}
+			if len(tt.response) < StatusLengthExtended && got.HeadTemperatureC != nil {
+				t.Errorf("head temperature %d parsed from a %d-byte response", *got.HeadTemperatureC, len(tt.response))
+			}
+			got.IsOnline = true
+			if s := pm.determineStatusString(got); s != tt.status {
+				t.Errorf("status = %s, want %s", s, tt.status)
+			}
+		})
+	}
+
+	extended := pm.parseStatus([]byte("@@@@\x2a@@@"))
+	if extended.HeadTemperatureC == nil || *extended.HeadTemperatureC != 42 {
+		t.Errorf("head temperature = %v, want 42", extended.HeadTemperatureC)
+	}
+}
+
+func TestCheckStatusReadsConfiguredLength(t *testing.T) {
+	tests := []struct {
+		length   int
+		response string
+		state    string
+		status   string
+	}{
+		{StatusLengthSingle, "\x10", "paused", "paused"},
+		{StatusLengthStandard, "@@@@", "normal", "online"},
+		{StatusLengthExtended, "@@@@\x2aC@@", "normal", "online"},
+	}
+
+	for _, tt := range tests {
+		pm := newScriptedPrinter(t, map[string]string{statusCommand: tt.response})
+		pm.db = newTestDB(t)
+		pm.printers[1].StatusLength = tt.length
+
+		status, err := pm.CheckStatus(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("%d-byte status: %v", tt.length, err)
+		}
+		if !bytes.Equal(status.RawStatus, []byte(tt.response)) || status.PrinterState != tt.state {
+			t.Errorf("%d-byte status read %x as %s, want %x as %s", tt.length, status.RawStatus, status.PrinterState, tt.response, tt.state)
+		}
+		if got, _ := pm.GetPrinter(1); got.Status != tt.status {
+			t.Errorf("%d-byte status left the printer %s, want %s", tt.length, got.Status, tt.status)
+		}
+		if tt.length == StatusLengthExtended && (status.HeadTemperatureC == nil || *status.HeadTemperatureC != 42 || status.MediaDetail != "paper_end") {
+			t.Errorf("extended fields are %v and %q, want 42 and paper_end", status.HeadTemperatureC, status.MediaDetail)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"rsc.io/qr/coding"
+)
+
+// qrLevels maps the TSPL QR error correction levels to the encoder's.
+var qrLevels = map[string]coding.Level{
+	"L": coding.L,
+	"M": coding.M,
+	"Q": coding.Q,
+	"H": coding.H,
+}
+
+// qrLevel returns the element's error correction level, M when unset.
+func qrLevel(elem *LabelElement) string {
+	if elem.Level == "" {
+		return "M"
+	}
+	return strings.ToUpper(elem.Level)
+}
+
+// encodeQR encodes content the way the printer does with automatic mode
+// selection: in the densest mode the content allows, at the smallest version
+// that holds it at the given level. mask picks the data mask; the printer's
+// automatic choice (nil or 8) is drawn with mask 0, which scans the same.
+func encodeQR(content, level string, mask *int) (*coding.Code, error) {
+	l, ok := qrLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("invalid QR code error correction level %q (valid: L, M, Q, H)", level)
+	}
+
+	mode := classifySymbolContent(content)
+	var enc coding.Encoding
+	switch mode {
+	case symbolModeNumeric:
+		enc = coding.Num(content)
+	case symbolModeAlphanumeric:
+		enc = coding.Alpha(content)
+	default:
+		enc = coding.String(content)
+	}
+
+	m := coding.Mask(0)
+	if mask != nil && *mask >= 0 && *mask <= 7 {
+		m = coding.Mask(*mask)
+	}
+
+	for v := coding.Version(coding.MinVersion); v <= coding.MaxVersion; v++ {
+		if enc.Bits(v) > v.DataBytes(l)*8 {
+			continue
+		}
+		plan, err := coding.NewPlan(v, l, m)
+		if err != nil {
+			return nil, err
+		}
+		return plan.Encode(enc)
+	}
+	return nil, fmt.Errorf("QR code content is %d characters, exceeding the %d allowed at level %s", len(content), qrCapacity[level][mode], level)
+}
+
+// drawQRCode encodes content as elem's QR code and calls fill for each dark
+// module with its offset from the symbol's top-left corner, cell_width dots
+// square as the printer draws it.
+func drawQRCode(elem *LabelElement, content string, fill func(dx, dy, width, height int)) error {
+	code, err := encodeQR(content, qrLevel(elem), elem.Mask)
+	if err != nil {
+		return err
+	}
+	cellWidth := elem.CellWidth
+	if cellWidth == 0 {
+		cellWidth = 4
+	}
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fill(x*cellWidth, y*cellWidth, cellWidth, cellWidth)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+)
+
+// testQRVersions describes QR versions 1 to 10 for decodeQR: the codewords
+// in the symbol, the alignment pattern centers, and the error correction
+// codewords per block and block count at levels L, M, Q and H.
+var testQRVersions = []struct {
+	codewords int
+	align     []int
+	blocks    [4][2]int
+}{
+	1:  {26, nil, [4][2]int{{7, 1}, {10, 1}, {13, 1}, {17, 1}}},
+	2:  {44, []int{6, 18}, [4][2]int{{10, 1}, {16, 1}, {22, 1}, {28, 1}}},
+	3:  {70, []int{6, 22}, [4][2]int{{15, 1}, {26, 1}, {18, 2}, {22, 2}}},
+	4:  {100, []int{6, 26}, [4][2]int{{20, 1}, {18, 2}, {26, 2}, {16, 4}}},
+	5:  {134, []int{6, 30}, [4][2]int{{26, 1}, {24, 2}, {18, 4}, {22, 4}}},
+	6:  {172, []int{6, 34}, [4][2]int{{18, 2}, {16, 4}, {24, 4}, {28, 4}}},
+	7:  {196, []int{6, 22, 38}, [4][2]int{{20, 2}, {18, 4}, {18, 6}, {26, 5}}},
+	8:  {242, []int{6, 24, 42}, [4][2]int{{24, 2}, {22, 4}, {22, 6}, {26, 6}}},
+	9:  {292, []int{6, 26, 46}, [4][2]int{{30, 2}, {22, 5}, {20, 8}, {24, 8}}},
+	10: {346, []int{6, 28, 50}, [4][2]int{{18, 4}, {26, 5}, {24, 8}, {28, 8}}},
+}
+
+// testQRLevels lists the levels in table order with their format bits.
+var testQRLevels = []struct {
+	name string
+	bits int
+}{{"L", 1}, {"M", 0}, {"Q", 3}, {"H", 2}}
+
+var testQRMasks = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (y/2+x/3)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// qrBCH appends the remainder of a BCH code with the given generator to
+// data, as used for the format and version information.
+func qrBCH(data, generator, bits int) int {
+	rem := data
+	for i := 0; i < bits; i++ {
+		rem = rem<<1 ^ (rem>>(bits-1))*generator
+	}
+	return data<<bits | rem
+}
+
+// gfMul multiplies in GF(256) with the QR code polynomial.
+func gfMul(a, b byte) byte {
+	var p byte
+	for ; b > 0; b >>= 1 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a&0x80 != 0
+		a <<= 1
+		if carry {
+			a ^= 0x1D
+		}
+	}
+	return p
+}
+
+type qrBitReader struct {
+	data    []byte
+	pos     int
+	overrun bool
+}
+
+func (r *qrBitReader) remaining() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *qrBitReader) read(n int) int {
+	if r.remaining() < n {
+		r.overrun = true
+		return 0
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | int(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
+
+// decodedQR is what decodeQR reads back from a rendered symbol.
+type decodedQR struct {
+	content string
+	level   string
+	mask    int
+	version int
+	module  int
+}
+
+// decodeQR reads back the only QR code drawn on img, as a scanner would: it
+// locates the symbol, reads its format and version information, checks the
+// error correction of every block, and decodes the numeric, alphanumeric
+// and byte segments. It handles versions 1 to 10.
+func decodeQR(img *image.Gray) (*decodedQR, error) {
+	dark := func(x, y int) bool { return img.GrayAt(x, y).Y < 128 }
+
+	bounds := img.Bounds()
+	minX, minY, maxX, maxY := bounds.Max.X, bounds.Max.Y, -1, -1
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if dark(x, y) {
+				minX, minY = min(minX, x), min(minY, y)
+				maxX, maxY = max(maxX, x), max(maxY, y)
+			}
+		}
+	}
+	if maxX < 0 {
+		return nil, errors.New("no symbol found")
+	}
+
+	// The top edge of the top-left finder pattern is 7 modules wide.
+	run := 0
+	for x := minX; x <= maxX && dark(x, minY); x++ {
+		run++
+	}
+	module := run / 7
+	if module == 0 || run%7 != 0 {
+		return nil, fmt.Errorf("finder pattern is %d dots wide", run)
+	}
+	width, height := maxX-minX+1, maxY-minY+1
+	size := width / module
+	if width != height || width%module != 0 || (size-17)%4 != 0 {
+		return nil, fmt.Errorf("symbol is %dx%d dots at %d dots per module", width, height, module)
+	}
+	version := (size - 17) / 4
+	if version < 1 || version >= len(testQRVersions) {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+	at := func(x, y int) int {
+		if dark(minX+x*module+module/2, minY+y*module+module/2) {
+			return 1
+		}
+		return 0
+	}
+
+	format := 0
+	for i, p := range [15][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}} {
+		format |= at(p[0], p[1]) << i
+	}
+	levelIndex, mask := -1, -1
+	for i, l := range testQRLevels {
+		for m := range testQRMasks {
+			if qrBCH(l.bits<<3|m, 0x537, 10)^0x5412 == format {
+				levelIndex, mask = i, m
+			}
+		}
+	}
+	if levelIndex < 0 {
+		return nil, fmt.Errorf("invalid format information %015b", format)
+	}
+
+	if version >= 7 {
+		want := qrBCH(version, 0x1F25, 12)
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			if at(a, b) != want>>i&1 || at(b, a) != want>>i&1 {
+				return nil, fmt.Errorf("invalid version information for version %d", version)
+			}
+		}
+	}
+
+	reserved := make([][]bool, size)
+	for y := range reserved {
+		reserved[y] = make([]bool, size)
+	}
+	mark := func(x0, y0, w, h int) {
+		for y := max(y0, 0); y < min(y0+h, size); y++ {
+			for x := max(x0, 0); x < min(x0+w, size); x++ {
+				reserved[y][x] = true
+			}
+		}
+	}
+	mark(0, 0, 9, 9)
+	mark(size-8, 0, 8, 9)
+	mark(0, size-8, 9, 8)
+	mark(6, 0, 1, size)
+	mark(0, 6, size, 1)
+	align := testQRVersions[version].align
+	for _, ay := range align {
+		for _, ax := range align {
+			last := align[len(align)-1]
+			if (ax == 6 && ay == 6) || (ax == 6 && ay == last) || (ax == last && ay == 6) {
+				continue
+			}
+			mark(ax-2, ay-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		mark(size-11, 0, 3, 6)
+		mark(0, size-11, 6, 3)
+	}
+
+	total := testQRVersions[version].codewords
+	codewords := make([]byte, 0, total)
+	var cw, nbits int
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for x := right; x > right-2; x-- {
+				if reserved[y][x] {
+					continue
+				}
+				bit := at(x, y)
+				if testQRMasks[mask](x, y) {
+					bit ^= 1
+				}
+				cw = cw<<1 | bit
+				if nbits++; nbits%8 == 0 && len(codewords) < total {
+					codewords = append(codewords, byte(cw))
+					cw = 0
+				}
+			}
+		}
+	}
+	if len(codewords) != total {
+		return nil, fmt.Errorf("read %d codewords, want %d", len(codewords), total)
+	}
+
+	ecLen, numBlocks := testQRVersions[version].blocks[levelIndex][0], testQRVersions[version].blocks[levelIndex][1]
+	numShort := numBlocks - total%numBlocks
+	shortData := total/numBlocks - ecLen
+	blocks := make([][]byte, numBlocks)
+	next := 0
+	for i := 0; i <= shortData; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], codewords[next])
+				next++
+			}
+		}
+	}
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, block...)
+	}
+	for i := 0; i < ecLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[next])
+			next++
+		}
+	}
+	for j, block := range blocks {
+		alpha := byte(1)
+		for i := 0; i < ecLen; i++ {
+			var syndrome byte
+			for _, c := range block {
+				syndrome = gfMul(syndrome, alpha) ^ c
+			}
+			if syndrome != 0 {
+				return nil, fmt.Errorf("block %d fails error correction check %d", j, i)
+			}
+			alpha = gfMul(alpha, 2)
+		}
+	}
+
+	const alphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+	countBits := map[int]int{1: 10, 2: 9, 4: 8}
+	if version >= 10 {
+		countBits = map[int]int{1: 12, 2: 11, 4: 16}
+	}
+	r := &qrBitReader{data: data}
+	var content strings.Builder
+	for r.remaining() >= 4 {
+		mode := r.read(4)
+		if mode == 0 {
+			break
+		}
+		bits, ok := countBits[mode]
+		if !ok {
+			return nil, fmt.Errorf("unsupported mode %04b", mode)
+		}
+		n := r.read(bits)
+		switch mode {
+		case 1:
+			for ; n >= 3; n -= 3 {
+				fmt.Fprintf(&content, "%03d", r.read(10))
+			}
+			switch n {
+			case 2:
+				fmt.Fprintf(&content, "%02d", r.read(7))
+			case 1:
+				fmt.Fprintf(&content, "%d", r.read(4))
+			}
+		case 2:
+			for ; n >= 2; n -= 2 {
+				v := r.read(11)
+				content.WriteByte(alphanumeric[v/45])
+				content.WriteByte(alphanumeric[v%45])
+			}
+			if n == 1 {
+				content.WriteByte(alphanumeric[r.read(6)])
+			}
+		case 4:
+			for ; n > 0; n-- {
+				content.WriteByte(byte(r.read(8)))
+			}
+		}
+		if r.overrun {
+			return nil, errors.New("segment runs past the data codewords")
+		}
+	}
+
+	return &decodedQR{
+		content: content.String(),
+		level:   testQRLevels[levelIndex].name,
+		mask:    mask,
+		version: version,
+		module:  module,
+	}, nil
+}
+
+func TestDrawQRCodeDecodes(t *testing.T) {
+	mask := func(m int) *int { return &m }
+	tests := []struct {
+		name    string
+		elem    LabelElement
+		version int
+	}{
+		{"numeric", LabelElement{Content: "0123456789012345", Level: "L", CellWidth: 4}, 1},
+		{"alphanumeric", LabelElement{Content: "SKU-42 $%*+./:", Level: "M", CellWidth: 3}, 1},
+		{"byte", LabelElement{Content: "https://example.com/labels?id=42", Level: "Q", CellWidth: 5}, 3},
+		{"utf-8", LabelElement{Content: "Größe 42 – Müller", Level: "H", CellWidth: 2}, 3},
+		{"default level and cell width", LabelElement{Content: "ABC123"}, 1},
+		{"lowercase level", LabelElement{Content: "12345", Level: "q", CellWidth: 3}, 1},
+		{"fixed mask", LabelElement{Content: "mask five", Level: "M", CellWidth: 3, Mask: mask(5)}, 1},
+		{"auto mask", LabelElement{Content: "mask auto", Level: "M", CellWidth: 3, Mask: mask(8)}, 1},
+		{"version 7", LabelElement{Content: strings.Repeat("lot 0042;", 13), Level: "M", CellWidth: 2}, 7},
+		{"version 10", LabelElement{Content: strings.Repeat("9", 280), Level: "H", CellWidth: 2}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elem := tt.elem
+			img := image.NewGray(image.Rect(0, 0, 400, 400))
+			for i := range img.Pix {
+				img.Pix[i] = 0xFF
+			}
+			// Draw away from the edges to leave a quiet zone.
+			err := drawQRCode(&elem, elem.Content, func(dx, dy, width, height int) {
+				for y := dy; y < dy+height; y++ {
+					for x := dx; x < dx+width; x++ {
+						img.Pix[(y+16)*img.Stride+x+16] = 0
+					}
+				}
+			})
+			if err != nil {
+				t.Fatalf("draw: %v", err)
+			}
+			got, err := decodeQR(img)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			if got.content != elem.Content {
+				t.Errorf("decoded %q, want %q", got.content, elem.Content)
+			}
+			if level := qrLevel(&elem); got.level != level {
+				t.Errorf("level %s, want %s", got.level, level)
+			}
+			cellWidth := elem.CellWidth
+			if cellWidth == 0 {
+				cellWidth = 4
+			}
+			if got.module != cellWidth {
+				t.Errorf("module is %d dots, want the cell width %d", got.module, cellWidth)
+			}
+			if got.version != tt.version {
+				t.Errorf("version %d, want %d", got.version, tt.version)
+			}
+			if elem.Mask != nil && *elem.Mask < 8 && got.mask != *elem.Mask {
+				t.Errorf("mask %d, want %d", got.mask, *elem.Mask)
+			}
+		})
+	}
+}
+
+func TestEncodeQRHonorsLevelCapacity(t *testing.T) {
+	samples := map[symbolContentMode]string{
+		symbolModeNumeric:      "7",
+		symbolModeAlphanumeric: "A",
+		symbolModeByte:         "a",
+	}
+	for level, capacities := range qrCapacity {
+		for mode, capacity := range capacities {
+			content := strings.Repeat(samples[mode], capacity)
+			if _, err := encodeQR(content, level, nil); err != nil {
+				t.Errorf("level %s: %d characters in mode %d: %v", level, capacity, mode, err)
+			}
+			if _, err := encodeQR(content+samples[mode], level, nil); err == nil {
+				t.Errorf("level %s: %d characters in mode %d encoded, want an error", level, capacity+1, mode)
+			}
+		}
+	}
+}
@@ -1,13 +1,41 @@
 package core
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"math/rand"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"orrn-spool/internal/config"
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/logging"
+)
+
+var ErrTSPLTooLarge = errors.New("tspl content exceeds maximum size")
+
+var ErrTooManyCopies = errors.New("copies exceed maximum per job")
+
+const tsplGenerationFailedPrefix = "TSPL generation failed: "
+
+// permanentFailurePrefixes identify failed jobs whose error retrying cannot
+// fix, such as a broken template or oversized TSPL.
+var permanentFailurePrefixes = []string{
+	tsplGenerationFailedPrefix,
+	ErrTSPLTooLarge.Error(),
+}
+
+// Job sources used to look up default priorities in QueueConfig.
+const (
+	JobSourceAPI        = "api"
+	JobSourceLegacy     = "legacy"
+	JobSourceQuickPrint = "quick_print"
 )
 
 type JobStatus string
@@ -34,9 +62,14 @@ type Job struct {
 	Copies        int
 	ErrorMessage  string
 	SubmittedBy   string
-	CreatedAt     time.Time
-	StartedAt     *time.Time
-	CompletedAt   *time.Time
+	RequestID     string
+	// Namespace tags the job for callers scoped to a namespace. Jobs
+	// printed from a template take the template's namespace.
+	Namespace   string
+	CreatedAt   time.Time
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+	ScheduledAt *time.Time
 }
 
 type QueueStats struct {
@@ -49,12 +82,9 @@ type QueueStats struct {
 	Total      int
 }
 
-type WebhookSender interface {
-	SendJobEvent(event string, jobID int64, printerID int64, status JobStatus, errorMsg string) error
-}
-
 type PrinterManagerInterface interface {
-	Print(printerID int64, tsplContent string, copies int) error
+	Print(ctx context.Context, printerID int64, tsplContent string, copies int) error
+	SendCommand(ctx context.Context, printerID int64, tspl string) error
 	GetPrinter(printerID int64) (*Printer, error)
 	IncrementPrintCount(printerID int64, count int) error
 }
@@ -70,11 +100,22 @@ type Queue struct {
 	webhookSender  WebhookSender
 	config         *config.QueueConfig
 	workers        int
+	workerStops    []chan struct{}
+	retry          RetryPolicy
 	stopCh         chan struct{}
-	jobCh          chan int64
+	wakeCh         chan struct{}
+	thumbnails     chan *Job
 	pausedPrinters map[int64]bool
 	mu             sync.RWMutex
 	running        bool
+	now            func() time.Time
+	randInt63n     func(n int64) int64
+	busySince      map[int64]time.Time
+	events         *EventLog
+	printingMu     sync.Mutex
+	printing       map[int64]bool
+	dedupMu        sync.Mutex
+	legacyJobs     map[legacyKey]legacyJob
 }
 
 func NewQueue(db *sql.DB, pm PrinterManagerInterface, tg TSPL2GeneratorInterface, ws WebhookSender, cfg *config.QueueConfig) *Queue {
@@ -96,10 +137,85 @@ func NewQueue(db *sql.DB, pm PrinterManagerInterface, tg TSPL2GeneratorInterface
 		webhookSender:  ws,
 		config:         cfg,
 		workers:        cfg.WorkerCount,
+		retry: RetryPolicy{
+			MaxRetries:      cfg.MaxRetries,
+			RetryDelay:      cfg.RetryDelay,
+			MaxRetryBackoff: cfg.MaxRetryBackoff,
+		},
 		stopCh:         make(chan struct{}),
-		jobCh:          make(chan int64, 1000),
+		wakeCh:         make(chan struct{}, 1),
+		thumbnails:     make(chan *Job, thumbnailBacklog),
 		pausedPrinters: make(map[int64]bool),
+		busySince:      make(map[int64]time.Time),
+		printing:       make(map[int64]bool),
+		legacyJobs:     make(map[legacyKey]legacyJob),
+		now:            time.Now,
+		randInt63n:     rand.Int63n,
+	}
+}
+
+// SetEventLog makes the queue record job lifecycle events in l alongside
+// webhook delivery.
+func (q *Queue) SetEventLog(l *EventLog) {
+	q.events = l
+}
+
+// emit publishes a job lifecycle event to webhooks, the event log and the
+// log, tagged with the request that created the job.
+func (q *Queue) emit(event string, job *Job, status JobStatus, errMsg string) {
+	if errMsg != "" {
+		jobLogger(job).Warn(event, "status", status, "error", errMsg)
+	} else {
+		jobLogger(job).Info(event, "status", status)
 	}
+
+	if q.webhookSender != nil {
+		ctx := logging.WithRequestID(context.Background(), job.RequestID)
+		q.webhookSender.SendJobEvent(ctx, event, job.ID, job.PrinterID, job.TemplateID, status, errMsg)
+	}
+	if q.events != nil {
+		q.events.Record(Event{
+			Type:      event,
+			JobID:     job.ID,
+			PrinterID: job.PrinterID,
+			Status:    string(status),
+			Message:   errMsg,
+		})
+	}
+}
+
+// jobLogger returns a logger tagged with the job and the request that
+// created it.
+func jobLogger(job *Job) *slog.Logger {
+	return logging.ForRequest(job.RequestID).With("job_id", job.ID, "printer_id", job.PrinterID)
+}
+
+// SetClock replaces the clock used to decide when scheduled jobs become
+// eligible for dispatch and how long a job has waited on a busy printer.
+func (q *Queue) SetClock(now func() time.Time) {
+	q.mu.Lock()
+	q.now = now
+	q.mu.Unlock()
+}
+
+// SetRandSource replaces the source used to jitter retry backoff, so tests
+// can make retry delays deterministic.
+func (q *Queue) SetRandSource(src rand.Source) {
+	r := rand.New(src)
+	var rmu sync.Mutex
+	q.mu.Lock()
+	q.randInt63n = func(n int64) int64 {
+		rmu.Lock()
+		defer rmu.Unlock()
+		return r.Int63n(n)
+	}
+	q.mu.Unlock()
+}
+
+func (q *Queue) clock() time.Time {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.now()
 }
 
 func (q *Queue) Start() error {
@@ -115,11 +231,16 @@ func (q *Queue) Start() error {
 		return fmt.Errorf("failed to recover jobs: %w", err)
 	}
 
-	for i := 0; i < q.workers; i++ {
-		go q.worker(i)
+	if err := q.loadSettings(); err != nil {
+		log.Printf("queue: %v", err)
 	}
 
+	q.mu.Lock()
+	q.scaleWorkers()
+	q.mu.Unlock()
+
 	go q.dispatcher()
+	go q.thumbnailer()
 
 	return nil
 }
@@ -131,6 +252,7 @@ func (q *Queue) Stop() {
 		return
 	}
 	q.running = false
+	q.workerStops = nil
 	q.mu.Unlock()
 
 	close(q.stopCh)
@@ -142,30 +264,23 @@ func (q *Queue) recoverJobs() error {
 		return fmt.Errorf("failed to reset processing jobs: %w", err)
 	}
 
-	rows, err := q.db.Query(`
-		SELECT id FROM print_jobs 
-		WHERE status = 'pending' 
-		ORDER BY priority DESC, created_at ASC
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to query pending jobs: %w", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var jobID int64
-		if err := rows.Scan(&jobID); err != nil {
-			return fmt.Errorf("failed to scan job id: %w", err)
-		}
-		select {
-		case q.jobCh <- jobID:
-		default:
-		}
-	}
+	q.wake()
 
 	return nil
 }
 
+// wake signals an idle worker that pending jobs may be available. Workers
+// claim jobs themselves through Dequeue, so the signal carries no job ID.
+// It only says that something changed: wakeCh holds one signal whatever
+// the pool size, a signal already waiting covers this one, and each worker
+// that claims a job wakes the next, so SetWorkerCount need not resize it.
+func (q *Queue) wake() {
+	select {
+	case q.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
 func (q *Queue) dispatcher() {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
@@ -175,58 +290,58 @@ func (q *Queue) dispatcher() {
 		case <-q.stopCh:
 			return
 		case <-ticker.C:
-			q.enqueuePendingJobs()
+			q.wake()
 		}
 	}
 }
 
-func (q *Queue) enqueuePendingJobs() {
-	rows, err := q.db.Query(`
-		SELECT id FROM print_jobs 
-		WHERE status = 'pending' 
-		ORDER BY priority DESC, created_at ASC
-		LIMIT 100
-	`)
-	if err != nil {
-		log.Printf("failed to query pending jobs: %v", err)
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var jobID int64
-		if err := rows.Scan(&jobID); err != nil {
-			continue
-		}
+// worker processes jobs until the queue stops or quit is closed by
+// SetWorkerCount scaling the pool down.
+func (q *Queue) worker(id int, quit <-chan struct{}) {
+	for {
 		select {
-		case q.jobCh <- jobID:
-		default:
+		case <-q.stopCh:
+			return
+		case <-quit:
 			return
+		case <-q.wakeCh:
+			q.drain(id, quit)
 		}
 	}
 }
 
-func (q *Queue) worker(id int) {
+// drain claims and processes jobs until the queue is empty. Each claim
+// re-signals so that another idle worker can pick up the next job in
+// parallel. Stop signals are only checked between jobs, so a job that has
+// been claimed always runs to completion.
+func (q *Queue) drain(id int, quit <-chan struct{}) {
 	for {
 		select {
 		case <-q.stopCh:
 			return
-		case jobID := <-q.jobCh:
-			q.processJob(jobID)
+		case <-quit:
+			return
+		default:
 		}
-	}
-}
 
-func (q *Queue) processJob(jobID int64) {
-	job, err := q.GetJob(jobID)
-	if err != nil {
-		log.Printf("worker: failed to get job %d: %v", jobID, err)
-		return
-	}
+		job, err := q.claimNext()
+		if err != nil {
+			log.Printf("worker %d: failed to dequeue job: %v", id, err)
+			return
+		}
+		if job == nil {
+			return
+		}
 
-	if job.Status != JobStatusPending {
-		return
+		q.wake()
+		printerID := job.PrinterID
+		q.processJob(job)
+		q.releasePrinter(printerID)
 	}
+}
+
+func (q *Queue) processJob(job *Job) {
+	jobID := job.ID
 
 	q.mu.RLock()
 	printerPaused := q.pausedPrinters[job.PrinterID]
@@ -240,37 +355,41 @@ func (q *Queue) processJob(jobID int64) {
 	if job.TSPLContent == "" && q.tsplGenerator != nil {
 		tspl, err := q.tsplGenerator.GenerateFromTemplate(job.TemplateID, job.VariablesJSON)
 		if err != nil {
-			q.handleJobFailure(job, fmt.Sprintf("TSPL generation failed: %v", err))
+			q.handleJobFailure(job, tsplGenerationFailedPrefix+err.Error())
+			return
+		}
+		if err := q.CheckTSPLSize(tspl); err != nil {
+			q.failJob(job, err.Error())
 			return
 		}
 		job.TSPLContent = tspl
 		q.updateJobTSPL(jobID, tspl)
 	}
 
-	now := time.Now()
-	q.updateJobStatus(jobID, JobStatusProcessing, "", &now, nil)
-
-	if q.webhookSender != nil {
-		q.webhookSender.SendJobEvent("job_started", jobID, job.PrinterID, JobStatusProcessing, "")
-	}
+	q.emit("job_started", job, JobStatusProcessing, "")
 
 	if q.printerManager == nil {
 		q.handleJobFailure(job, "printer manager not configured")
 		return
 	}
 
-	err = q.printerManager.Print(job.PrinterID, job.TSPLContent, job.Copies)
+	err := q.printerManager.Print(context.Background(), job.PrinterID, job.TSPLContent, job.Copies)
+	if errors.Is(err, ErrPrinterBusy) && q.waitForBusyPrinter(job) {
+		return
+	}
+	q.clearBusy(jobID)
 	if err != nil {
+		q.feedAfterFailure(job, err)
 		q.handleJobFailure(job, err.Error())
 		return
 	}
 
-	now = time.Now()
+	now := time.Now()
 	q.updateJobStatus(jobID, JobStatusCompleted, "", nil, &now)
 
-	if q.webhookSender != nil {
-		q.webhookSender.SendJobEvent("job_completed", jobID, job.PrinterID, JobStatusCompleted, "")
-	}
+	q.emit("job_completed", job, JobStatusCompleted, "")
+
+	q.queueThumbnail(job)
 
 	q.printerManager.IncrementPrintCount(job.PrinterID, job.Copies)
 
@@ -287,33 +406,190 @@ func (q *Queue) handleJobFailure(job *Job, errMsg string) {
 		return
 	}
 
+	if q.failover(job, errMsg) {
+		return
+	}
+
+	if q.config.DeadLetter {
+		q.deadLetter(job, errMsg)
+		return
+	}
+	q.failJob(job, errMsg)
+}
+
+// feedAfterFailure sends the printer's feed-on-error command after a failed
+// print, so a run cut short does not leave the next label misaligned.
+// Nothing is sent when the printer was offline or busy, as nothing was
+// printed.
+func (q *Queue) feedAfterFailure(job *Job, printErr error) {
+	if errors.Is(printErr, ErrPrinterNotFound) || errors.Is(printErr, ErrPrinterOffline) || errors.Is(printErr, ErrPrinterBusy) {
+		return
+	}
+	printer, err := q.printerManager.GetPrinter(job.PrinterID)
+	if err != nil {
+		return
+	}
+	command, err := FeedOnErrorCommand(printer.FeedOnError)
+	if err != nil || command == "" {
+		return
+	}
+	if err := q.printerManager.SendCommand(context.Background(), job.PrinterID, command+"\n"); err != nil {
+		log.Printf("queue: failed to send feed-on-error command to printer %d after job %d: %v", job.PrinterID, job.ID, err)
+	}
+}
+
+// waitForBusyPrinter requeues a job whose printer is busy feeding after
+// busy_retry_delay, without counting a retry. It reports false once the job
+// has been waiting longer than busy_timeout, so the caller can handle the
+// busy printer as an ordinary failure.
+func (q *Queue) waitForBusyPrinter(job *Job) bool {
+	delay := q.config.BusyRetryDelay
+	if delay == 0 {
+		delay = 2 * time.Second
+	}
+	timeout := q.config.BusyTimeout
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+
+	now := q.clock()
+	q.mu.Lock()
+	since, waiting := q.busySince[job.ID]
+	if !waiting {
+		since = now
+		q.busySince[job.ID] = now
+	}
+	q.mu.Unlock()
+
+	if now.Sub(since) >= timeout {
+		return false
+	}
+
+	time.AfterFunc(delay, func() {
+		q.retryJob(job.ID)
+	})
+	return true
+}
+
+func (q *Queue) clearBusy(jobID int64) {
+	q.mu.Lock()
+	delete(q.busySince, jobID)
+	q.mu.Unlock()
+}
+
+// failover moves a job that exhausted its retries to its printer's fallback,
+// if one is configured, online and not already tried for this job. The
+// attempted printers are stored on the job so a chain of fallbacks cannot
+// loop. It reports whether the job was rerouted.
+func (q *Queue) failover(job *Job, errMsg string) bool {
+	if q.printerManager == nil {
+		return false
+	}
+	printer, err := q.printerManager.GetPrinter(job.PrinterID)
+	if err != nil || printer.FallbackPrinterID == nil {
+		return false
+	}
+	fallback, err := q.printerManager.GetPrinter(*printer.FallbackPrinterID)
+	if err != nil || (fallback.Status != "online" && fallback.Status != "busy") {
+		return false
+	}
+
+	var attemptedStr string
+	if err := q.db.QueryRow("SELECT COALESCE(attempted_printers, '') FROM print_jobs WHERE id = ?", job.ID).Scan(&attemptedStr); err != nil {
+		log.Printf("failover: failed to load attempted printers for job %d: %v", job.ID, err)
+		return false
+	}
+	attempted := map[int64]bool{job.PrinterID: true}
+	for _, f := range strings.Split(attemptedStr, ",") {
+		if id, err := strconv.ParseInt(f, 10, 64); err == nil {
+			attempted[id] = true
+		}
+	}
+	if attempted[fallback.ID] {
+		return false
+	}
+
+	ids := strings.TrimPrefix(attemptedStr+","+strconv.FormatInt(job.PrinterID, 10), ",")
+	message := fmt.Sprintf("failed over from printer %d to %d: %s", job.PrinterID, fallback.ID, errMsg)
+	_, err = q.db.Exec(`
+		UPDATE print_jobs
+		SET printer_id = ?, status = 'pending', retry_count = 0, error_message = ?, attempted_printers = ?, started_at = NULL
+		WHERE id = ?
+	`, fallback.ID, message, ids, job.ID)
+	if err != nil {
+		log.Printf("failover: failed to reroute job %d: %v", job.ID, err)
+		return false
+	}
+
+	q.emit("job_failover", job, JobStatusPending, message)
+	q.wake()
+	return true
+}
+
+// failJob marks a job as permanently failed without scheduling a retry.
+func (q *Queue) failJob(job *Job, errMsg string) {
 	now := time.Now()
 	q.updateJobStatus(job.ID, JobStatusFailed, errMsg, nil, &now)
 
-	if q.webhookSender != nil {
-		q.webhookSender.SendJobEvent("job_failed", job.ID, job.PrinterID, JobStatusFailed, errMsg)
+	q.emit("job_failed", job, JobStatusFailed, errMsg)
+}
+
+// DefaultPriority returns the configured priority for jobs submitted through
+// source without an explicit priority, or 0 when none is configured.
+func (q *Queue) DefaultPriority(source string) int {
+	return q.config.SourcePriorities[source]
+}
+
+// CheckTSPLSize returns ErrTSPLTooLarge when content exceeds the configured
+// max_tspl_bytes. A limit of 0 disables the check.
+func (q *Queue) CheckTSPLSize(content string) error {
+	limit := q.config.MaxTSPLBytes
+	if limit > 0 && len(content) > limit {
+		return fmt.Errorf("%w: %d bytes (limit %d)", ErrTSPLTooLarge, len(content), limit)
+	}
+	return nil
+}
+
+// CheckCopies returns ErrTooManyCopies when copies exceeds the configured
+// max_copies_per_job. A limit of 0 disables the check.
+func (q *Queue) CheckCopies(copies int) error {
+	limit := q.config.MaxCopiesPerJob
+	if limit > 0 && copies > limit {
+		return fmt.Errorf("%w: %d copies (limit %d)", ErrTooManyCopies, copies, limit)
 	}
+	return nil
 }
 
+// calculateBackoff returns a retry delay with full jitter: a random duration
+// between 0 and baseDelay * 2^retryCount, capped at max_retry_backoff.
 func (q *Queue) calculateBackoff(retryCount int) time.Duration {
-	baseDelay := q.config.RetryDelay
+	policy := q.RetryPolicy()
+	baseDelay := policy.RetryDelay
 	if baseDelay == 0 {
 		baseDelay = 10 * time.Second
 	}
-	backoff := baseDelay * time.Duration(1<<uint(retryCount))
-	maxBackoff := 5 * time.Minute
-	if backoff > maxBackoff {
-		backoff = maxBackoff
+	maxBackoff := policy.MaxRetryBackoff
+	if maxBackoff == 0 {
+		maxBackoff = 5 * time.Minute
+	}
+
+	backoff := maxBackoff
+	if retryCount < 32 {
+		if d := baseDelay * time.Duration(1<<uint(retryCount)); d > 0 && d < maxBackoff {
+			backoff = d
+		}
 	}
-	return backoff
+
+	q.mu.RLock()
+	randInt63n := q.randInt63n
+	q.mu.RUnlock()
+
+	return time.Duration(randInt63n(int64(backoff) + 1))
 }
 
 func (q *Queue) retryJob(jobID int64) {
 	q.updateJobStatus(jobID, JobStatusPending, "", nil, nil)
-	select {
-	case q.jobCh <- jobID:
-	default:
-	}
+	q.wake()
 }
 
 func (q *Queue) incrementRetryCount(jobID int64) {
@@ -340,27 +616,41 @@ func (q *Queue) updateJobTSPL(jobID int64, tspl string) {
 	q.db.Exec("UPDATE print_jobs SET tspl_content = ? WHERE id = ?", tspl, jobID)
 }
 
+// incrementPrintCounter adds count to the printer's daily and hourly
+// totals, both in local time.
 func (q *Queue) incrementPrintCounter(printerID int64, count int) {
-	today := time.Now().Format("2006-01-02")
+	now := time.Now()
 	q.db.Exec(`
 		INSERT INTO print_counters (printer_id, date, count)
 		VALUES (?, ?, ?)
 		ON CONFLICT(printer_id, date) DO UPDATE SET count = count + ?
-	`, printerID, today, count, count)
+	`, printerID, now.Format("2006-01-02"), count, count)
+	q.db.Exec(`
+		INSERT INTO print_counters_hourly (printer_id, hour, count)
+		VALUES (?, ?, ?)
+		ON CONFLICT(printer_id, hour) DO UPDATE SET count = count + ?
+	`, printerID, now.Format(db.HourLayout), count, count)
 }
 
 func (q *Queue) Enqueue(job *Job) (int64, error) {
+	if err := q.CheckTSPLSize(job.TSPLContent); err != nil {
+		return 0, err
+	}
 	if job.MaxRetries == 0 {
-		job.MaxRetries = q.config.MaxRetries
+		job.MaxRetries = q.RetryPolicy().MaxRetries
 	}
 	if job.Status == "" {
 		job.Status = JobStatusPending
 	}
+	if job.ScheduledAt != nil {
+		scheduledAt := job.ScheduledAt.UTC()
+		job.ScheduledAt = &scheduledAt
+	}
 
 	result, err := q.db.Exec(`
-		INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, priority, copies, submitted_by)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, job.PrinterID, job.TemplateID, job.VariablesJSON, job.TSPLContent, job.Status, job.Priority, job.Copies, job.SubmittedBy)
+		INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, priority, copies, submitted_by, scheduled_at, request_id, namespace)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, job.PrinterID, job.TemplateID, job.VariablesJSON, job.TSPLContent, job.Status, job.Priority, job.Copies, job.SubmittedBy, job.ScheduledAt, job.RequestID, job.Namespace)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert job: %w", err)
 	}
@@ -369,33 +659,51 @@ func (q *Queue) Enqueue(job *Job) (int64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("failed to get job id: %w", err)
 	}
+	job.ID = jobID
 
-	select {
-	case q.jobCh <- jobID:
-	default:
-	}
+	jobLogger(job).Info("job_enqueued", "status", job.Status, "copies", job.Copies)
+
+	q.wake()
 
 	return jobID, nil
 }
 
+// Dequeue atomically claims the highest priority pending job by flipping it
+// to processing. It returns nil when no job is pending. The status guard on
+// the update means a job can only ever be claimed by one caller. Jobs whose
+// scheduled_at lies in the future are skipped until the clock passes it.
 func (q *Queue) Dequeue() (*Job, error) {
+	return q.dequeue(nil)
+}
+
+// dequeue is Dequeue skipping jobs for the printers in exclude.
+func (q *Queue) dequeue(exclude []int64) (*Job, error) {
 	tx, err := q.db.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	where := "status = 'pending' AND (scheduled_at IS NULL OR scheduled_at <= ?)"
+	args := []interface{}{q.clock().UTC()}
+	if len(exclude) > 0 {
+		where += " AND printer_id NOT IN (?" + strings.Repeat(", ?", len(exclude)-1) + ")"
+		for _, id := range exclude {
+			args = append(args, id)
+		}
+	}
+
 	var job Job
 	err = tx.QueryRow(`
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+		SELECT id, printer_id, template_id, COALESCE(variables_json, ''), COALESCE(tspl_content, ''), status, priority, retry_count, COALESCE(error_message, ''), copies, COALESCE(submitted_by, ''), created_at, started_at, completed_at, scheduled_at, COALESCE(request_id, '')
 		FROM print_jobs 
-		WHERE status = 'pending' 
-		ORDER BY priority DESC, created_at ASC 
+		WHERE `+where+`
+		ORDER BY `+db.JobDispatchOrder+`
 		LIMIT 1
-	`).Scan(
+	`, args...).Scan(
 		&job.ID, &job.PrinterID, &job.TemplateID, &job.VariablesJSON, &job.TSPLContent,
 		&job.Status, &job.Priority, &job.RetryCount, &job.ErrorMessage,
-		&job.Copies, &job.SubmittedBy, &job.CreatedAt, &job.StartedAt, &job.CompletedAt,
+		&job.Copies, &job.SubmittedBy, &job.CreatedAt, &job.StartedAt, &job.CompletedAt, &job.ScheduledAt, &job.RequestID,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -405,12 +713,19 @@ func (q *Queue) Dequeue() (*Job, error) {
 	}
 
 	now := time.Now()
-	_, err = tx.Exec(`
-		UPDATE print_jobs SET status = 'processing', started_at = ? WHERE id = ?
+	result, err := tx.Exec(`
+		UPDATE print_jobs SET status = 'processing', started_at = ? WHERE id = ? AND status = 'pending'
 	`, now, job.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update job status: %w", err)
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return nil, nil
+	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
@@ -418,20 +733,21 @@ func (q *Queue) Dequeue() (*Job, error) {
 
 	job.Status = JobStatusProcessing
 	job.StartedAt = &now
+	job.MaxRetries = q.RetryPolicy().MaxRetries
 
 	return &job, nil
 }
 
 func (q *Queue) GetJob(id int64) (*Job, error) {
 	var job Job
-	var startedAt, completedAt sql.NullTime
+	var startedAt, completedAt, scheduledAt sql.NullTime
 	err := q.db.QueryRow(`
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, COALESCE(error_message, ''), copies, submitted_by, created_at, started_at, completed_at, scheduled_at, namespace
 		FROM print_jobs WHERE id = ?
 	`, id).Scan(
 		&job.ID, &job.PrinterID, &job.TemplateID, &job.VariablesJSON, &job.TSPLContent,
 		&job.Status, &job.Priority, &job.RetryCount, &job.ErrorMessage,
-		&job.Copies, &job.SubmittedBy, &job.CreatedAt, &startedAt, &completedAt,
+		&job.Copies, &job.SubmittedBy, &job.CreatedAt, &startedAt, &completedAt, &scheduledAt, &job.Namespace,
 	)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("job not found: %d", id)
@@ -446,6 +762,9 @@ func (q *Queue) GetJob(id int64) (*Job, error) {
 	if completedAt.Valid {
 		job.CompletedAt = &completedAt.Time
 	}
+	if scheduledAt.Valid {
+		job.ScheduledAt = &scheduledAt.Time
+	}
 
 	return &job, nil
 }
@@ -456,14 +775,14 @@ func (q *Queue) ListJobs(status JobStatus, limit, offset int) ([]*Job, error) {
 
 	if status != "" {
 		rows, err = q.db.Query(`
-			SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+			SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at, scheduled_at
 			FROM print_jobs WHERE status = ?
 			ORDER BY priority DESC, created_at DESC
 			LIMIT ? OFFSET ?
 		`, status, limit, offset)
 	} else {
 		rows, err = q.db.Query(`
-			SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+			SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at, scheduled_at
 			FROM print_jobs
 			ORDER BY priority DESC, created_at DESC
 			LIMIT ? OFFSET ?
@@ -477,11 +796,11 @@ func (q *Queue) ListJobs(status JobStatus, limit, offset int) ([]*Job, error) {
 	var jobs []*Job
 	for rows.Next() {
 		job := &Job{}
-		var startedAt, completedAt sql.NullTime
+		var startedAt, completedAt, scheduledAt sql.NullTime
 		err := rows.Scan(
 			&job.ID, &job.PrinterID, &job.TemplateID, &job.VariablesJSON, &job.TSPLContent,
 			&job.Status, &job.Priority, &job.RetryCount, &job.ErrorMessage,
-			&job.Copies, &job.SubmittedBy, &job.CreatedAt, &startedAt, &completedAt,
+			&job.Copies, &job.SubmittedBy, &job.CreatedAt, &startedAt, &completedAt, &scheduledAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan job: %w", err)
@@ -492,6 +811,9 @@ func (q *Queue) ListJobs(status JobStatus, limit, offset int) ([]*Job, error) {
 		if completedAt.Valid {
 			job.CompletedAt = &completedAt.Time
 		}
+		if scheduledAt.Valid {
+			job.ScheduledAt = &scheduledAt.Time
+		}
 		jobs = append(jobs, job)
 	}
 
@@ -527,6 +849,79 @@ func (q *Queue) CancelJob(id int64) error {
 	return nil
 }
 
+// CancelJobs cancels every pending or paused job matching the filter in a
+// single statement and returns how many were cancelled. A zero printerID
+// matches all printers, an empty status matches both pending and paused and
+// an empty namespace matches every namespace. Processing jobs are never
+// touched.
+func (q *Queue) CancelJobs(printerID int64, status JobStatus, namespace string) (int64, error) {
+	statuses := []interface{}{JobStatusPending, JobStatusPaused}
+	if status != "" {
+		if status != JobStatusPending && status != JobStatusPaused {
+			return 0, fmt.Errorf("only pending or paused jobs can be cancelled")
+		}
+		statuses = []interface{}{status, status}
+	}
+
+	query := `
+		UPDATE print_jobs SET status = 'cancelled', completed_at = CURRENT_TIMESTAMP 
+		WHERE status IN (?, ?)`
+	args := statuses
+	if printerID > 0 {
+		query += " AND printer_id = ?"
+		args = append(args, printerID)
+	}
+	if namespace != "" {
+		query += " AND namespace = ?"
+		args = append(args, namespace)
+	}
+
+	result, err := q.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel jobs: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected, nil
+}
+
+// RecordScan stores the result of scanning back a printed label. Only
+// completed jobs can be verified.
+func (q *Queue) RecordScan(job *Job, scannedValue string, matched bool) error {
+	result := "mismatch"
+	event := "job_scan_mismatch"
+	errMsg := "scanned value does not match printed content"
+	if matched {
+		result = "verified"
+		event = "job_verified"
+		errMsg = ""
+	}
+
+	res, err := q.db.Exec(`
+		UPDATE print_jobs SET verification_status = ?, scanned_value = ?, verified_at = ?
+		WHERE id = ? AND status = 'completed'
+	`, result, scannedValue, time.Now(), job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record scan: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("only completed jobs can be verified")
+	}
+
+	q.emit(event, job, JobStatusCompleted, errMsg)
+
+	return nil
+}
+
 func (q *Queue) RetryJob(id int64) error {
 	job, err := q.GetJob(id)
 	if err != nil {
@@ -546,15 +941,81 @@ func (q *Queue) RetryJob(id int64) error {
 		return fmt.Errorf("failed to retry job: %w", err)
 	}
 
-	select {
-	case q.jobCh <- id:
-	default:
-	}
+	q.wake()
 
 	return nil
 }
 
-func (q *Queue) ReprintJob(id int64) (int64, error) {
+// RetryAllFailed requeues every failed job for a printer, resetting retry
+// counts and errors, and returns how many were requeued. Jobs that failed
+// permanently are left alone.
+func (q *Queue) RetryAllFailed(printerID int64) (int, error) {
+	return q.RetryFailed(printerID, "", nil, nil)
+}
+
+// sqliteTimeLayout is how SQLite's CURRENT_TIMESTAMP and datetime() write
+// times, so bounds formatted with it compare correctly as text.
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// RetryFailed requeues, in a single statement, the failed jobs for a printer
+// that failed between from and to, as RetryAllFailed does. A zero printerID
+// matches all printers, an empty namespace matches every namespace and a nil
+// bound leaves that end of the range open.
+func (q *Queue) RetryFailed(printerID int64, namespace string, from, to *time.Time) (int, error) {
+	query := `
+		UPDATE print_jobs
+		SET status = 'pending', retry_count = 0, error_message = '', started_at = NULL, completed_at = NULL
+		WHERE status = 'failed'`
+	var args []interface{}
+	if printerID > 0 {
+		query += " AND printer_id = ?"
+		args = append(args, printerID)
+	}
+	if namespace != "" {
+		query += " AND namespace = ?"
+		args = append(args, namespace)
+	}
+	// completed_at is when the job failed. failJob writes it with its zone,
+	// so it is normalised to UTC before comparing.
+	if from != nil {
+		query += " AND datetime(completed_at) >= ?"
+		args = append(args, from.UTC().Format(sqliteTimeLayout))
+	}
+	if to != nil {
+		query += " AND datetime(completed_at) <= ?"
+		args = append(args, to.UTC().Format(sqliteTimeLayout))
+	}
+	for _, prefix := range permanentFailurePrefixes {
+		query += " AND COALESCE(error_message, '') NOT LIKE ? ESCAPE '\\'"
+		args = append(args, escapeLike(prefix)+"%")
+	}
+
+	result, err := q.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retry failed jobs: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected > 0 {
+		q.wake()
+	}
+
+	return int(affected), nil
+}
+
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+// ReprintJob enqueues a copy of a job. The copy is tagged with the request
+// ID carried by ctx rather than the original job's.
+func (q *Queue) ReprintJob(ctx context.Context, id int64) (int64, error) {
 	job, err := q.GetJob(id)
 	if err != nil {
 		return 0, err
@@ -569,12 +1030,67 @@ func (q *Queue) ReprintJob(id int64) (int64, error) {
 		MaxRetries:    job.MaxRetries,
 		Copies:        job.Copies,
 		SubmittedBy:   job.SubmittedBy,
+		RequestID:     logging.RequestID(ctx),
+		Namespace:     job.Namespace,
 		Status:        JobStatusPending,
 	}
 
 	return q.Enqueue(newJob)
 }
 
+// JobReprint pairs a job with the reprint created from it.
+type JobReprint struct {
+	OriginalJobID int64
+	NewJobID      int64
+}
+
+// ReprintRecent reprints the last count completed jobs for a printer, for
+// recovering labels lost to a jam. With includeFailed, failed and cancelled
+// jobs are candidates too. Reprints are enqueued oldest first so they come
+// out in the order the originals did. If a reprint fails, the ones already
+// enqueued are returned with the error.
+func (q *Queue) ReprintRecent(ctx context.Context, printerID int64, count int, includeFailed bool) ([]JobReprint, error) {
+	statuses := "'completed'"
+	if includeFailed {
+		statuses = "'completed', 'failed', 'cancelled'"
+	}
+
+	rows, err := q.db.Query(`
+		SELECT id FROM print_jobs
+		WHERE printer_id = ? AND status IN (`+statuses+`)
+		ORDER BY COALESCE(completed_at, created_at) DESC, id DESC
+		LIMIT ?
+	`, printerID, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent jobs: %w", err)
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan job id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query recent jobs: %w", err)
+	}
+
+	reprints := make([]JobReprint, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		newID, err := q.ReprintJob(ctx, ids[i])
+		if err != nil {
+			return reprints, fmt.Errorf("failed to reprint job %d: %w", ids[i], err)
+		}
+		reprints = append(reprints, JobReprint{OriginalJobID: ids[i], NewJobID: newID})
+	}
+
+	return reprints, nil
+}
+
 func (q *Queue) PausePrinter(printerID int64) error {
 	q.mu.Lock()
 	q.pausedPrinters[printerID] = true
@@ -616,11 +1132,8 @@ func (q *Queue) ResumePrinter(printerID int64) error {
 
 	for _, id := range jobIDs {
 		q.updateJobStatus(id, JobStatusPending, "", nil, nil)
-		select {
-		case q.jobCh <- id:
-		default:
-		}
 	}
+	q.wake()
 
 	return nil
 }
@@ -665,10 +1178,7 @@ func (q *Queue) ResumeJob(id int64) error {
 
 	q.updateJobStatus(id, JobStatusPending, "", nil, nil)
 
-	select {
-	case q.jobCh <- id:
-	default:
-	}
+	q.wake()
 
 	return nil
 }
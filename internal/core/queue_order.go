@@ -0,0 +1,110 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrJobNotPending = errors.New("only pending jobs can be reordered")
+
+// PromoteJob moves a pending job to the front of the queue. The job takes
+// the highest priority among pending jobs and a queue position ahead of
+// every job at that priority, so it is dequeued next even if it was created
+// after jobs that had a higher priority.
+func (q *Queue) PromoteJob(id int64) error {
+	return q.reorderJob(id, true)
+}
+
+// DemoteJob moves a pending job to the back of the queue, taking the lowest
+// pending priority and a queue position behind every job at that priority.
+func (q *Queue) DemoteJob(id int64) error {
+	return q.reorderJob(id, false)
+}
+
+func (q *Queue) reorderJob(id int64, front bool) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM print_jobs WHERE id = ?`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("job not found: %d", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query job: %w", err)
+	}
+	if JobStatus(status) != JobStatusPending {
+		return ErrJobNotPending
+	}
+
+	priorityAgg, positionAgg, step := "MIN", "MAX", int64(1)
+	if front {
+		priorityAgg, positionAgg, step = "MAX", "MIN", -1
+	}
+
+	var priority sql.NullInt64
+	var position int64
+	err = tx.QueryRow(fmt.Sprintf(`
+		SELECT p.priority, COALESCE(%s(j.queue_position), 0)
+		FROM (SELECT %s(priority) AS priority FROM print_jobs WHERE status = 'pending' AND id != ?) p
+		LEFT JOIN print_jobs j ON j.priority = p.priority AND j.status = 'pending' AND j.id != ?
+	`, positionAgg, priorityAgg), id, id).Scan(&priority, &position)
+	if err != nil {
+		return fmt.Errorf("failed to query queue order: %w", err)
+	}
+	if !priority.Valid {
+		// No other pending jobs, so the job is already first and last.
+		return nil
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE print_jobs SET priority = ?, queue_position = ? WHERE id = ?
+	`, priority.Int64, position+step, id); err != nil {
+		return fmt.Errorf("failed to reorder job: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// QueuePosition returns where a pending job stands in its printer's line,
+// 1 meaning it is the next job that printer will print. Each printer prints
+// one job at a time but independently of the others, so only pending jobs
+// for the same printer that dispatch before this one are counted. Jobs that
+// are not pending, or whose scheduled time has not come, have position 0.
+func (q *Queue) QueuePosition(id int64) (int, error) {
+	now := q.clock().UTC()
+
+	var status string
+	var scheduledAt sql.NullTime
+	err := q.db.QueryRow(`SELECT status, scheduled_at FROM print_jobs WHERE id = ?`, id).Scan(&status, &scheduledAt)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("job not found: %d", id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query job: %w", err)
+	}
+	if JobStatus(status) != JobStatusPending || (scheduledAt.Valid && scheduledAt.Time.After(now)) {
+		return 0, nil
+	}
+
+	// Jobs ahead sort earlier in JobDispatchOrder; the ID breaks ties.
+	var ahead int
+	err = q.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM print_jobs o JOIN print_jobs j ON j.id = ?
+		WHERE o.printer_id = j.printer_id AND o.id != j.id
+		  AND o.status = 'pending' AND (o.scheduled_at IS NULL OR o.scheduled_at <= ?)
+		  AND (o.priority > j.priority
+		    OR (o.priority = j.priority AND o.queue_position < j.queue_position)
+		    OR (o.priority = j.priority AND o.queue_position = j.queue_position AND o.created_at < j.created_at)
+		    OR (o.priority = j.priority AND o.queue_position = j.queue_position AND o.created_at = j.created_at AND o.id < j.id))
+	`, id, now).Scan(&ahead)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query queue position: %w", err)
+	}
+	return ahead + 1, nil
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// dequeueOrder dequeues every ready job and returns their IDs in order.
+func dequeueOrder(t *testing.T, q *Queue) []int64 {
+	t.Helper()
+
+	var ids []int64
+	for {
+		job, err := q.Dequeue()
+		if err != nil {
+			t.Fatalf("dequeue: %v", err)
+		}
+		if job == nil {
+			return ids
+		}
+		ids = append(ids, job.ID)
+	}
+}
+
+func TestPromoteJobMovesAheadOfHigherPriority(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	q := NewQueue(database, nil, nil, nil, nil)
+
+	enqueue := func(priority int) int64 {
+		t.Helper()
+		id, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1, Priority: priority})
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		return id
+	}
+	urgent := enqueue(5)
+	alsoUrgent := enqueue(5)
+	routine := enqueue(0)
+	late := enqueue(0)
+
+	if err := q.PromoteJob(late); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if err := q.DemoteJob(urgent); err != nil {
+		t.Fatalf("demote: %v", err)
+	}
+
+	got := dequeueOrder(t, q)
+	want := []int64{late, alsoUrgent, routine, urgent}
+	if len(got) != len(want) {
+		t.Fatalf("dequeued %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("dequeued %v, want %v", got, want)
+		}
+	}
+}
+
+func TestReorderOnlyPendingJobs(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	q := NewQueue(database, nil, nil, nil, nil)
+
+	id, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1, Status: JobStatusCompleted})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.PromoteJob(id); !errors.Is(err, ErrJobNotPending) {
+		t.Errorf("promote completed job returned %v, want ErrJobNotPending", err)
+	}
+	if err := q.DemoteJob(9999); err == nil {
+		t.Error("demote unknown job succeeded, want an error")
+	}
+}
+
+func TestQueuePosition(t *testing.T) {
+	database := newTestDB(t)
+	printerA := insertTestPrinter(t, database, "a")
+	printerB := insertTestPrinter(t, database, "b")
+	q := NewQueue(database, nil, nil, nil, nil)
+
+	enqueue := func(job *Job) int64 {
+		t.Helper()
+		job.TSPLContent, job.Copies = "PRINT 1", 1
+		id, err := q.Enqueue(job)
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		return id
+	}
+	routine := enqueue(&Job{PrinterID: printerA})
+	urgent := enqueue(&Job{PrinterID: printerA, Priority: 5})
+	later := enqueue(&Job{PrinterID: printerA})
+	tomorrow := time.Now().Add(24 * time.Hour)
+	scheduled := enqueue(&Job{PrinterID: printerA, ScheduledAt: &tomorrow})
+	other := enqueue(&Job{PrinterID: printerB})
+
+	positions := func() map[int64]int {
+		t.Helper()
+		got := map[int64]int{}
+		for _, id := range []int64{routine, urgent, later, scheduled, other} {
+			position, err := q.QueuePosition(id)
+			if err != nil {
+				t.Fatalf("queue position of job %d: %v", id, err)
+			}
+			got[id] = position
+		}
+		return got
+	}
+	check := func(want map[int64]int) {
+		t.Helper()
+		got := positions()
+		for id, position := range want {
+			if got[id] != position {
+				t.Errorf("job %d is at %d, want %d", id, got[id], position)
+			}
+		}
+	}
+
+	// The urgent job goes first; the other printer has its own line, and a
+	// job scheduled for later is not in line yet.
+	check(map[int64]int{urgent: 1, routine: 2, later: 3, scheduled: 0, other: 1})
+
+	if err := q.PromoteJob(later); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	check(map[int64]int{later: 1, urgent: 2, routine: 3})
+
+	// Once a job is dequeued it leaves the line.
+	job, err := q.Dequeue()
+	if err != nil || job == nil || job.ID != later {
+		t.Fatalf("dequeued %+v, %v, want job %d", job, err, later)
+	}
+	check(map[int64]int{later: 0, urgent: 1, routine: 2})
+}
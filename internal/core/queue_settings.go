@@ -0,0 +1,139 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Settings keys that override the queue values from config.yaml. They are
+// applied when the queue starts.
+const (
+	SettingQueueWorkerCount     = "queue_worker_count"
+	SettingQueueMaxRetries      = "queue_max_retries"
+	SettingQueueRetryDelay      = "queue_retry_delay"
+	SettingQueueMaxRetryBackoff = "queue_max_retry_backoff"
+)
+
+var errInvalidWorkerCount = errors.New("worker count must be at least 1")
+
+// RetryPolicy controls how often and how quickly failed jobs are retried.
+// Changes apply to jobs claimed after the change; a job already being
+// processed keeps the limit it was claimed with.
+type RetryPolicy struct {
+	MaxRetries      int
+	RetryDelay      time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// WorkerCount returns the number of workers the queue runs.
+func (q *Queue) WorkerCount() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.workers
+}
+
+// SetWorkerCount resizes the worker pool. New workers start immediately
+// when the queue is running. Surplus workers are told to stop and exit once
+// the job they are processing, if any, has finished, so no claimed job is
+// dropped.
+func (q *Queue) SetWorkerCount(n int) error {
+	if n < 1 {
+		return errInvalidWorkerCount
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.workers = n
+	if q.running {
+		q.scaleWorkers()
+	}
+	return nil
+}
+
+// scaleWorkers starts or stops workers until the pool matches q.workers.
+// Callers must hold q.mu.
+func (q *Queue) scaleWorkers() {
+	for len(q.workerStops) < q.workers {
+		quit := make(chan struct{})
+		id := len(q.workerStops)
+		q.workerStops = append(q.workerStops, quit)
+		go q.worker(id, quit)
+	}
+	for len(q.workerStops) > q.workers {
+		last := len(q.workerStops) - 1
+		close(q.workerStops[last])
+		q.workerStops = q.workerStops[:last]
+	}
+}
+
+// RetryPolicy returns the retry settings currently in effect.
+func (q *Queue) RetryPolicy() RetryPolicy {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.retry
+}
+
+// SetRetryPolicy replaces the retry settings. Zero delays fall back to the
+// built-in defaults, as they do in config.yaml.
+func (q *Queue) SetRetryPolicy(p RetryPolicy) error {
+	if p.MaxRetries < 0 || p.RetryDelay < 0 || p.MaxRetryBackoff < 0 {
+		return errors.New("retry settings must not be negative")
+	}
+
+	q.mu.Lock()
+	q.retry = p
+	q.mu.Unlock()
+	return nil
+}
+
+// loadSettings applies queue settings stored through the API over the
+// values from config.yaml. Unparseable values are skipped.
+func (q *Queue) loadSettings() error {
+	rows, err := q.db.Query(`SELECT key, value FROM settings WHERE key IN (?, ?, ?, ?)`,
+		SettingQueueWorkerCount, SettingQueueMaxRetries, SettingQueueRetryDelay, SettingQueueMaxRetryBackoff)
+	if err != nil {
+		return fmt.Errorf("failed to load queue settings: %w", err)
+	}
+	defer rows.Close()
+
+	policy := q.RetryPolicy()
+	workers := q.WorkerCount()
+	for rows.Next() {
+		var key string
+		var value sql.NullString
+		if err := rows.Scan(&key, &value); err != nil {
+			return fmt.Errorf("failed to scan queue setting: %w", err)
+		}
+
+		switch key {
+		case SettingQueueWorkerCount:
+			if n, err := strconv.Atoi(value.String); err == nil && n >= 1 {
+				workers = n
+			}
+		case SettingQueueMaxRetries:
+			if n, err := strconv.Atoi(value.String); err == nil && n >= 0 {
+				policy.MaxRetries = n
+			}
+		case SettingQueueRetryDelay:
+			if d, err := time.ParseDuration(value.String); err == nil && d >= 0 {
+				policy.RetryDelay = d
+			}
+		case SettingQueueMaxRetryBackoff:
+			if d, err := time.ParseDuration(value.String); err == nil && d >= 0 {
+				policy.MaxRetryBackoff = d
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load queue settings: %w", err)
+	}
+
+	q.mu.Lock()
+	q.workers = workers
+	q.retry = policy
+	q.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+// blockingPrinters holds every print until release receives a value, and
+// tracks how many prints are in progress at once.
+type blockingPrinters struct {
+	*fakePrinterManager
+	release chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+}
+
+func (b *blockingPrinters) Print(ctx context.Context, printerID int64, tsplContent string, copies int) error {
+	b.mu.Lock()
+	b.inFlight++
+	b.mu.Unlock()
+
+	<-b.release
+
+	b.mu.Lock()
+	b.inFlight--
+	b.mu.Unlock()
+	return b.fakePrinterManager.Print(ctx, printerID, tsplContent, copies)
+}
+
+// waitForInFlight waits until want prints are in progress, and fails if the
+// count then changes within a short settling time.
+func (b *blockingPrinters) waitForInFlight(t *testing.T, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		got := b.inFlight
+		b.mu.Unlock()
+		if got == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d prints in progress, want %d", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inFlight != want {
+		t.Fatalf("%d prints in progress after settling, want %d", b.inFlight, want)
+	}
+}
+
+func TestSetWorkerCountScalesWorkers(t *testing.T) {
+	database := newTestDB(t)
+	pm := &blockingPrinters{fakePrinterManager: newFakePrinterManager(), release: make(chan struct{})}
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{WorkerCount: 1})
+
+	const jobs = 6
+	for i := 0; i < jobs; i++ {
+		id := insertTestPrinter(t, database, fmt.Sprintf("printer-%d", i))
+		pm.addPrinter(&Printer{ID: id, Status: "online"})
+		if _, err := q.Enqueue(&Job{PrinterID: id, TSPLContent: fmt.Sprintf("PRINT %d", i), Copies: 1}); err != nil {
+			t.Fatalf("enqueue job %d: %v", i, err)
+		}
+	}
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	pm.waitForInFlight(t, 1)
+
+	if err := q.SetWorkerCount(3); err != nil {
+		t.Fatalf("scale up: %v", err)
+	}
+	pm.waitForInFlight(t, 3)
+
+	// Scaling down lets the claimed jobs finish; the surplus workers then
+	// exit instead of claiming more.
+	if err := q.SetWorkerCount(1); err != nil {
+		t.Fatalf("scale down: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		pm.release <- struct{}{}
+	}
+	pm.waitForInFlight(t, 1)
+
+	close(pm.release)
+	waitForJobs(t, database, jobs)
+	for i := 0; i < jobs; i++ {
+		if n := pm.printCount(fmt.Sprintf("PRINT %d", i)); n != 1 {
+			t.Errorf("job %d printed %d times, want 1", i, n)
+		}
+	}
+	if n := q.WorkerCount(); n != 1 {
+		t.Errorf("queue reports %d workers, want 1", n)
+	}
+}
+
+// TestScaledUpWorkersPickUpNewJobs grows the pool past the configured
+// worker count while it is idle, then checks that jobs enqueued afterwards
+// wake every worker.
+func TestScaledUpWorkersPickUpNewJobs(t *testing.T) {
+	database := newTestDB(t)
+	pm := &blockingPrinters{fakePrinterManager: newFakePrinterManager(), release: make(chan struct{})}
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{WorkerCount: 1})
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	if err := q.SetWorkerCount(4); err != nil {
+		t.Fatalf("scale up: %v", err)
+	}
+
+	const jobs = 4
+	for i := 0; i < jobs; i++ {
+		id := insertTestPrinter(t, database, fmt.Sprintf("printer-%d", i))
+		pm.addPrinter(&Printer{ID: id, Status: "online"})
+		if _, err := q.Enqueue(&Job{PrinterID: id, TSPLContent: fmt.Sprintf("PRINT %d", i), Copies: 1}); err != nil {
+			t.Fatalf("enqueue job %d: %v", i, err)
+		}
+	}
+	pm.waitForInFlight(t, jobs)
+
+	close(pm.release)
+	waitForJobs(t, database, jobs)
+}
+
+func TestSetWorkerCountRejectsZero(t *testing.T) {
+	q := NewQueue(newTestDB(t), newFakePrinterManager(), nil, nil, &config.QueueConfig{WorkerCount: 2})
+	if err := q.SetWorkerCount(0); err == nil {
+		t.Error("SetWorkerCount(0) succeeded, want an error")
+	}
+	if n := q.WorkerCount(); n != 2 {
+		t.Errorf("queue reports %d workers, want 2", n)
+	}
+}
+
+func TestQueueAppliesStoredSettingsOnStart(t *testing.T) {
+	database := newTestDB(t)
+	for key, value := range map[string]string{
+		SettingQueueWorkerCount:     "5",
+		SettingQueueMaxRetries:      "7",
+		SettingQueueRetryDelay:      "2s",
+		SettingQueueMaxRetryBackoff: "not a duration",
+	} {
+		if _, err := database.Exec("INSERT INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			t.Fatalf("store %s: %v", key, err)
+		}
+	}
+	q := NewQueue(database, newFakePrinterManager(), nil, nil, &config.QueueConfig{
+		WorkerCount: 1, MaxRetries: 3, RetryDelay: time.Second, MaxRetryBackoff: time.Minute,
+	})
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	if n := q.WorkerCount(); n != 5 {
+		t.Errorf("queue runs %d workers, want the stored 5", n)
+	}
+	want := RetryPolicy{MaxRetries: 7, RetryDelay: 2 * time.Second, MaxRetryBackoff: time.Minute}
+	if got := q.RetryPolicy(); got != want {
+		t.Errorf("retry policy is %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,734 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/db"
+)
+
+// newTestDB opens an in-memory database with every migration applied. Like
+// db.Init, it keeps a single connection, as each in-memory connection would
+// otherwise get its own empty database.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	database, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+
+	paths, err := filepath.Glob("../db/migrations/*.sql")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("find migrations: %v", err)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		migration, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if _, err := database.Exec(string(migration)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(path), err)
+		}
+	}
+	return database
+}
+
+// insertTestPrinter adds a printer row and returns its ID.
+func insertTestPrinter(t *testing.T, database *sql.DB, name string) int64 {
+	t.Helper()
+
+	result, err := database.Exec("INSERT INTO printers (name, ip_address, status) VALUES (?, ?, 'online')", name, name+".test")
+	if err != nil {
+		t.Fatalf("insert printer: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatalf("insert printer: %v", err)
+	}
+	return id
+}
+
+// fakePrinterManager records what the queue sends instead of talking to
+// printers.
+type fakePrinterManager struct {
+	mu       sync.Mutex
+	printers map[int64]*Printer
+	printed  map[string]int
+	// printedOn counts the jobs each printer has printed.
+	printedOn map[int64]int
+	// commands holds what SendCommand sent to each printer.
+	commands map[int64][]string
+}
+
+func newFakePrinterManager() *fakePrinterManager {
+	return &fakePrinterManager{
+		printers:  make(map[int64]*Printer),
+		printed:   make(map[string]int),
+		printedOn: make(map[int64]int),
+		commands:  make(map[int64][]string),
+	}
+}
+
+func (f *fakePrinterManager) addPrinter(p *Printer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.printers[p.ID] = p
+}
+
+// Print fails for printers that are not online, as the real manager does
+// when it cannot connect.
+func (f *fakePrinterManager) Print(ctx context.Context, printerID int64, tsplContent string, copies int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p, ok := f.printers[printerID]; ok && p.Status == "offline" {
+		return ErrPrinterOffline
+	}
+	f.printed[tsplContent]++
+	f.printedOn[printerID]++
+	return nil
+}
+
+func (f *fakePrinterManager) SendCommand(ctx context.Context, printerID int64, tspl string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands[printerID] = append(f.commands[printerID], tspl)
+	return nil
+}
+
+func (f *fakePrinterManager) sentCommands(printerID int64) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands[printerID]...)
+}
+
+func (f *fakePrinterManager) GetPrinter(printerID int64) (*Printer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.printers[printerID]
+	if !ok {
+		return nil, ErrPrinterNotFound
+	}
+	snapshot := *p
+	return &snapshot, nil
+}
+
+func (f *fakePrinterManager) IncrementPrintCount(printerID int64, count int) error {
+	return nil
+}
+
+func (f *fakePrinterManager) printCount(tspl string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.printed[tspl]
+}
+
+// waitForJobs waits until want jobs have completed.
+func waitForJobs(t *testing.T, database *sql.DB, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		var completed int
+		if err := database.QueryRow("SELECT COUNT(*) FROM print_jobs WHERE status = 'completed'").Scan(&completed); err != nil {
+			t.Fatalf("count completed jobs: %v", err)
+		}
+		if completed == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d jobs completed before the deadline", completed, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueueWorkersPrintEachJobOnce(t *testing.T) {
+	database := newTestDB(t)
+	pm := newFakePrinterManager()
+	var printerIDs []int64
+	for i := 0; i < 4; i++ {
+		id := insertTestPrinter(t, database, fmt.Sprintf("printer-%d", i))
+		pm.addPrinter(&Printer{ID: id, Status: "online"})
+		printerIDs = append(printerIDs, id)
+	}
+
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{WorkerCount: 16})
+
+	const jobs = 200
+	for i := 0; i < jobs; i++ {
+		job := &Job{PrinterID: printerIDs[i%len(printerIDs)], TSPLContent: fmt.Sprintf("PRINT %d", i), Copies: 1}
+		if _, err := q.Enqueue(job); err != nil {
+			t.Fatalf("enqueue job %d: %v", i, err)
+		}
+	}
+
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	waitForJobs(t, database, jobs)
+
+	for i := 0; i < jobs; i++ {
+		if n := pm.printCount(fmt.Sprintf("PRINT %d", i)); n != 1 {
+			t.Errorf("job %d printed %d times, want 1", i, n)
+		}
+	}
+}
+
+func TestDequeueClaimsEachJobOnce(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	q := NewQueue(database, nil, nil, nil, nil)
+
+	const jobs = 100
+	for i := 0; i < jobs; i++ {
+		if _, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: fmt.Sprintf("PRINT %d", i), Copies: 1}); err != nil {
+			t.Fatalf("enqueue job %d: %v", i, err)
+		}
+	}
+
+	var mu sync.Mutex
+	claimed := make(map[int64]int)
+	var wg sync.WaitGroup
+	for w := 0; w < 10; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := q.Dequeue()
+				if err != nil {
+					t.Errorf("dequeue: %v", err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				mu.Lock()
+				claimed[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != jobs {
+		t.Errorf("claimed %d jobs, want %d", len(claimed), jobs)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Errorf("job %d claimed %d times, want 1", id, n)
+		}
+	}
+}
+
+func TestDequeueHoldsScheduledJobsUntilDue(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	q := NewQueue(database, nil, nil, nil, nil)
+	now := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	q.SetClock(func() time.Time { return now })
+
+	runAt := now.Add(2 * time.Hour)
+	jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1, ScheduledAt: &runAt})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	if job, err := q.Dequeue(); err != nil || job != nil {
+		t.Fatalf("dequeue before run_at returned %v, %v, want no job", job, err)
+	}
+
+	now = runAt.Add(time.Second)
+	job, err := q.Dequeue()
+	if err != nil {
+		t.Fatalf("dequeue after run_at: %v", err)
+	}
+	if job == nil || job.ID != jobID {
+		t.Fatalf("dequeue after run_at returned %v, want job %d", job, jobID)
+	}
+}
+
+// jobStatus returns the stored status of a job.
+func jobStatus(t *testing.T, database *sql.DB, id int64) JobStatus {
+	t.Helper()
+
+	var status JobStatus
+	if err := database.QueryRow("SELECT status FROM print_jobs WHERE id = ?", id).Scan(&status); err != nil {
+		t.Fatalf("read job %d: %v", id, err)
+	}
+	return status
+}
+
+func TestCancelJobsForPrinter(t *testing.T) {
+	database := newTestDB(t)
+	stuck := insertTestPrinter(t, database, "stuck")
+	other := insertTestPrinter(t, database, "other")
+	q := NewQueue(database, nil, nil, nil, nil)
+
+	enqueue := func(printerID int64, status JobStatus) int64 {
+		t.Helper()
+		id, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1, Status: status})
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		return id
+	}
+	var backlog []int64
+	for i := 0; i < 3; i++ {
+		backlog = append(backlog, enqueue(stuck, JobStatusPending))
+	}
+	backlog = append(backlog, enqueue(stuck, JobStatusPaused))
+	processing := enqueue(stuck, JobStatusProcessing)
+	untouched := enqueue(other, JobStatusPending)
+
+	cancelled, err := q.CancelJobs(stuck, "", "")
+	if err != nil {
+		t.Fatalf("cancel jobs: %v", err)
+	}
+	if cancelled != int64(len(backlog)) {
+		t.Errorf("cancelled %d jobs, want %d", cancelled, len(backlog))
+	}
+	for _, id := range backlog {
+		if status := jobStatus(t, database, id); status != JobStatusCancelled {
+			t.Errorf("job %d is %s, want cancelled", id, status)
+		}
+	}
+	if status := jobStatus(t, database, processing); status != JobStatusProcessing {
+		t.Errorf("processing job is %s, want it left processing", status)
+	}
+	if status := jobStatus(t, database, untouched); status != JobStatusPending {
+		t.Errorf("other printer's job is %s, want it left pending", status)
+	}
+
+	if _, err := q.CancelJobs(0, JobStatusProcessing, ""); err == nil {
+		t.Error("cancelling processing jobs succeeded, want an error")
+	}
+}
+
+func TestEnqueueEnforcesMaxTSPLBytes(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	q := NewQueue(database, nil, nil, nil, &config.QueueConfig{MaxRetries: 3, WorkerCount: 1, MaxTSPLBytes: 64})
+
+	if _, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: strings.Repeat("x", 64), Copies: 1}); err != nil {
+		t.Errorf("enqueue at the limit: %v", err)
+	}
+	_, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: strings.Repeat("x", 65), Copies: 1})
+	if !errors.Is(err, ErrTSPLTooLarge) {
+		t.Errorf("enqueue over the limit returned %v, want ErrTSPLTooLarge", err)
+	}
+
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM print_jobs").Scan(&count); err != nil {
+		t.Fatalf("count jobs: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("%d jobs stored, want only the one within the limit", count)
+	}
+}
+
+func TestCalculateBackoffJitter(t *testing.T) {
+	q := NewQueue(nil, nil, nil, nil, &config.QueueConfig{
+		MaxRetries: 3, WorkerCount: 1, RetryDelay: time.Second, MaxRetryBackoff: 20 * time.Second,
+	})
+	q.SetRandSource(rand.NewSource(1))
+
+	for retry, ceiling := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 20 * time.Second, 20 * time.Second} {
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 20; i++ {
+			d := q.calculateBackoff(retry)
+			if d < 0 || d > ceiling {
+				t.Fatalf("retry %d: backoff %s, want between 0 and %s", retry, d, ceiling)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("retry %d: backoff was the same on every call, want jitter", retry)
+		}
+	}
+
+	// Very high retry counts must not overflow past the cap.
+	if d := q.calculateBackoff(100); d < 0 || d > 20*time.Second {
+		t.Errorf("retry 100: backoff %s, want at most the 20s cap", d)
+	}
+}
+
+func TestCalculateBackoffIsDeterministicWithSource(t *testing.T) {
+	backoffs := func() []time.Duration {
+		q := NewQueue(nil, nil, nil, nil, &config.QueueConfig{MaxRetries: 3, WorkerCount: 1, RetryDelay: time.Second})
+		q.SetRandSource(rand.NewSource(42))
+		var out []time.Duration
+		for retry := 0; retry < 5; retry++ {
+			out = append(out, q.calculateBackoff(retry))
+		}
+		return out
+	}
+	first, second := backoffs(), backoffs()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("backoffs %v and %v differ with the same seed", first, second)
+		}
+	}
+}
+
+func TestRetryAllFailedSkipsPermanentFailures(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	other := insertTestPrinter(t, database, "other")
+	q := NewQueue(database, nil, nil, nil, nil)
+
+	failedJob := func(printerID int64, message string) int64 {
+		t.Helper()
+		id, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1, Status: JobStatusFailed})
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		if _, err := database.Exec("UPDATE print_jobs SET error_message = ?, retry_count = 3 WHERE id = ?", message, id); err != nil {
+			t.Fatalf("fail job: %v", err)
+		}
+		return id
+	}
+	jammed := failedJob(printerID, "printer error: paper jam")
+	offline := failedJob(printerID, "connection refused")
+	brokenTemplate := failedJob(printerID, tsplGenerationFailedPrefix+"unknown variable")
+	oversized := failedJob(printerID, ErrTSPLTooLarge.Error())
+	otherPrinter := failedJob(other, "connection refused")
+
+	requeued, err := q.RetryAllFailed(printerID)
+	if err != nil {
+		t.Fatalf("retry all failed: %v", err)
+	}
+	if requeued != 2 {
+		t.Errorf("requeued %d jobs, want 2", requeued)
+	}
+
+	for _, id := range []int64{jammed, offline} {
+		var retries int
+		var message string
+		if err := database.QueryRow("SELECT retry_count, error_message FROM print_jobs WHERE id = ?", id).Scan(&retries, &message); err != nil {
+			t.Fatalf("read job %d: %v", id, err)
+		}
+		if status := jobStatus(t, database, id); status != JobStatusPending || retries != 0 || message != "" {
+			t.Errorf("job %d is %s with %d retries and error %q, want pending and reset", id, status, retries, message)
+		}
+	}
+	for _, id := range []int64{brokenTemplate, oversized, otherPrinter} {
+		if status := jobStatus(t, database, id); status != JobStatusFailed {
+			t.Errorf("job %d is %s, want it left failed", id, status)
+		}
+	}
+}
+
+// waitForJobStatus waits until a job reaches status.
+func waitForJobStatus(t *testing.T, database *sql.DB, id int64, status JobStatus) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for jobStatus(t, database, id) != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s, want %s", id, jobStatus(t, database, id), status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFailoverToFallbackPrinter(t *testing.T) {
+	database := newTestDB(t)
+	primary := insertTestPrinter(t, database, "primary")
+	fallback := insertTestPrinter(t, database, "fallback")
+	pm := newFakePrinterManager()
+	pm.addPrinter(&Printer{ID: primary, Status: "offline", FallbackPrinterID: &fallback})
+	pm.addPrinter(&Printer{ID: fallback, Status: "online"})
+
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{MaxRetries: 1, WorkerCount: 1, RetryDelay: time.Millisecond})
+	jobID, err := q.Enqueue(&Job{PrinterID: primary, TSPLContent: "PRINT 1", Copies: 1})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	waitForJobStatus(t, database, jobID, JobStatusCompleted)
+
+	var printerID int64
+	var attempted string
+	if err := database.QueryRow("SELECT printer_id, attempted_printers FROM print_jobs WHERE id = ?", jobID).Scan(&printerID, &attempted); err != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	if printerID != fallback {
+		t.Errorf("job finished on printer %d, want the fallback %d", printerID, fallback)
+	}
+	if attempted != strconv.FormatInt(primary, 10) {
+		t.Errorf("attempted printers are %q, want the primary %d", attempted, primary)
+	}
+	if pm.printedOn[fallback] != 1 {
+		t.Errorf("fallback printed %d jobs, want 1", pm.printedOn[fallback])
+	}
+}
+
+func TestFailoverDoesNotLoop(t *testing.T) {
+	database := newTestDB(t)
+	first := insertTestPrinter(t, database, "first")
+	second := insertTestPrinter(t, database, "second")
+	pm := newFakePrinterManager()
+	// Each is the other's fallback and neither can print, though both
+	// report online.
+	pm.addPrinter(&Printer{ID: first, Status: "online", FallbackPrinterID: &second})
+	pm.addPrinter(&Printer{ID: second, Status: "online", FallbackPrinterID: &first})
+	q := NewQueue(database, &failingPrinters{pm}, nil, nil, &config.QueueConfig{MaxRetries: 1, WorkerCount: 1, RetryDelay: time.Millisecond})
+
+	jobID, err := q.Enqueue(&Job{PrinterID: first, TSPLContent: "PRINT 1", Copies: 1})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	waitForJobStatus(t, database, jobID, JobStatusFailed)
+
+	var printerID int64
+	if err := database.QueryRow("SELECT printer_id FROM print_jobs WHERE id = ?", jobID).Scan(&printerID); err != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	if printerID != second {
+		t.Errorf("job failed on printer %d, want it to stop at %d after one failover", printerID, second)
+	}
+}
+
+// failingPrinters reports printers as the wrapped manager does but fails
+// every print.
+type failingPrinters struct {
+	*fakePrinterManager
+}
+
+func (*failingPrinters) Print(context.Context, int64, string, int) error { return ErrConnectionFailed }
+
+func TestSelectGroupPrinterSkipsUnavailablePrinters(t *testing.T) {
+	database := newTestDB(t)
+	pm := newFakePrinterManager()
+	grouped := func(name, status string) int64 {
+		id := insertTestPrinter(t, database, name)
+		if _, err := database.Exec("UPDATE printers SET printer_group = 'zone-a' WHERE id = ?", id); err != nil {
+			t.Fatalf("set group: %v", err)
+		}
+		pm.addPrinter(&Printer{ID: id, Status: status})
+		return id
+	}
+	offline := grouped("offline", "offline")
+	paused := grouped("paused", "online")
+	q := NewQueue(database, pm, nil, nil, nil)
+	if err := q.PausePrinter(paused); err != nil {
+		t.Fatalf("pause printer: %v", err)
+	}
+
+	if _, err := q.SelectGroupPrinter("zone-a"); err != ErrNoPrinterAvailable {
+		t.Errorf("select with no usable printer returned %v, want ErrNoPrinterAvailable", err)
+	}
+	if _, err := q.SelectGroupPrinter("zone-z"); err != ErrPrinterGroupEmpty {
+		t.Errorf("select from an empty group returned %v, want ErrPrinterGroupEmpty", err)
+	}
+
+	online := grouped("online", "online")
+	if id, err := q.SelectGroupPrinter("zone-a"); err != nil || id != online {
+		t.Errorf("select returned %d, %v, want the online printer %d (not %d or %d)", id, err, online, offline, paused)
+	}
+}
+
+// busyPrinters reports the first busyFor prints as busy, then prints as
+// the wrapped manager does. busyFor < 0 keeps the printer busy for good.
+type busyPrinters struct {
+	*fakePrinterManager
+
+	mu      sync.Mutex
+	busyFor int
+	busy    int
+}
+
+func (b *busyPrinters) Print(ctx context.Context, printerID int64, tsplContent string, copies int) error {
+	b.mu.Lock()
+	if b.busyFor < 0 || b.busy < b.busyFor {
+		b.busy++
+		b.mu.Unlock()
+		return ErrPrinterBusy
+	}
+	b.mu.Unlock()
+	return b.fakePrinterManager.Print(ctx, printerID, tsplContent, copies)
+}
+
+func TestJobWaitsForBusyPrinter(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	pm := &busyPrinters{fakePrinterManager: newFakePrinterManager(), busyFor: 3}
+	pm.addPrinter(&Printer{ID: printerID, Status: "online"})
+	// With one retry allowed, the job only completes if busy attempts are
+	// not counted as retries.
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{
+		MaxRetries: 1, WorkerCount: 1, RetryDelay: time.Millisecond,
+		BusyRetryDelay: 5 * time.Millisecond, BusyTimeout: time.Minute,
+	})
+	jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	waitForJobStatus(t, database, jobID, JobStatusCompleted)
+
+	var retries int
+	if err := database.QueryRow("SELECT retry_count FROM print_jobs WHERE id = ?", jobID).Scan(&retries); err != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	if retries != 0 {
+		t.Errorf("retry count is %d, want busy waits not counted", retries)
+	}
+	pm.mu.Lock()
+	busy := pm.busy
+	pm.mu.Unlock()
+	if busy != 3 || pm.printCount("PRINT 1") != 1 {
+		t.Errorf("printer was busy %d times and printed %d jobs, want 3 and 1", busy, pm.printCount("PRINT 1"))
+	}
+}
+
+func TestBusyPrinterTimesOut(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	pm := &busyPrinters{fakePrinterManager: newFakePrinterManager(), busyFor: -1}
+	pm.addPrinter(&Printer{ID: printerID, Status: "online"})
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{
+		MaxRetries: 1, WorkerCount: 1, RetryDelay: time.Millisecond,
+		BusyRetryDelay: 5 * time.Millisecond, BusyTimeout: 30 * time.Millisecond,
+	})
+	jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: "PRINT 1", Copies: 1})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	waitForJobStatus(t, database, jobID, JobStatusFailed)
+}
+
+func TestReprintRecent(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	other := insertTestPrinter(t, database, "other")
+	q := NewQueue(database, newFakePrinterManager(), nil, nil, nil)
+
+	// finished adds a job that finished minutesAgo minutes ago.
+	finished := func(printerID int64, status string, minutesAgo int) int64 {
+		t.Helper()
+		result, err := database.Exec(`INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, status, copies, submitted_by, completed_at)
+			VALUES (?, 0, '{}', ?, ?, 1, 'test', datetime('now', ?))`, printerID, fmt.Sprintf("PRINT %d", minutesAgo), status, fmt.Sprintf("-%d minutes", minutesAgo))
+		if err != nil {
+			t.Fatalf("insert job: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return id
+	}
+	finished(printerID, "completed", 50)
+	second := finished(printerID, "completed", 40)
+	failed := finished(printerID, "failed", 30)
+	third := finished(printerID, "completed", 20)
+	finished(other, "completed", 10)
+	cancelled := finished(printerID, "cancelled", 5)
+
+	originals := func(reprints []JobReprint) []int64 {
+		ids := make([]int64, len(reprints))
+		for i, r := range reprints {
+			ids[i] = r.OriginalJobID
+		}
+		return ids
+	}
+
+	reprints, err := q.ReprintRecent(context.Background(), printerID, 2, false)
+	if err != nil {
+		t.Fatalf("reprint recent: %v", err)
+	}
+	if got, want := originals(reprints), []int64{second, third}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("reprinted jobs %v, want the last two completed %v, oldest first", got, want)
+	}
+	if reprints[0].NewJobID >= reprints[1].NewJobID {
+		t.Errorf("reprints were enqueued as %d then %d, want the older job's reprint first", reprints[0].NewJobID, reprints[1].NewJobID)
+	}
+	for _, r := range reprints {
+		job, err := q.GetJob(r.NewJobID)
+		if err != nil {
+			t.Fatalf("get reprint: %v", err)
+		}
+		original, _ := q.GetJob(r.OriginalJobID)
+		if job.Status != JobStatusPending || job.PrinterID != printerID || job.TSPLContent != original.TSPLContent {
+			t.Errorf("reprint of job %d is %+v, want a pending copy on the same printer", r.OriginalJobID, job)
+		}
+	}
+
+	reprints, err = q.ReprintRecent(context.Background(), printerID, 3, true)
+	if err != nil {
+		t.Fatalf("reprint recent including failed: %v", err)
+	}
+	if got, want := originals(reprints), []int64{failed, third, cancelled}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with failed jobs included reprinted %v, want %v", got, want)
+	}
+}
+
+func TestIncrementPrintCounterHourly(t *testing.T) {
+	database := newTestDB(t)
+	printer := insertTestPrinter(t, database, "printer")
+	other := insertTestPrinter(t, database, "other")
+	q := NewQueue(database, nil, nil, nil, nil)
+
+	q.incrementPrintCounter(printer, 2)
+	q.incrementPrintCounter(printer, 3)
+	q.incrementPrintCounter(other, 4)
+
+	hour := time.Now().Format(db.HourLayout)
+	for _, tt := range []struct {
+		printerID int64
+		want      int
+	}{{printer, 5}, {other, 4}} {
+		var hours, count int
+		if err := database.QueryRow("SELECT COUNT(*), COALESCE(SUM(count), 0) FROM print_counters_hourly WHERE printer_id = ? AND hour = ?",
+			tt.printerID, hour).Scan(&hours, &count); err != nil {
+			t.Fatalf("read hourly counters: %v", err)
+		}
+		if hours != 1 || count != tt.want {
+			t.Errorf("printer %d has %d hourly rows totalling %d, want one row of %d", tt.printerID, hours, count, tt.want)
+		}
+
+		var daily int
+		if err := database.QueryRow("SELECT count FROM print_counters WHERE printer_id = ?", tt.printerID).Scan(&daily); err != nil {
+			t.Fatalf("read daily counter: %v", err)
+		}
+		if daily != tt.want {
+			t.Errorf("printer %d daily count = %d, want %d", tt.printerID, daily, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+	"github.com/orrn/spool/internal/db"
+)
+
+// Settings keys that override the retention values from config.yaml at
+// runtime.
+const (
+	SettingRetentionEnabled = "retention_enabled"
+	SettingRetentionDays    = "retention_days"
+)
+
+// RetentionWorker periodically deletes old completed and cancelled jobs. It is
+// independent of the archiver and needs no passphrase; jobs it removes are
+// gone for good.
+type RetentionWorker struct {
+	config *config.DatabaseConfig
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewRetentionWorker(cfg *config.DatabaseConfig) *RetentionWorker {
+	return &RetentionWorker{
+		config: cfg,
+		stopCh: make(chan struct{}),
+	}
+}
+
+func (w *RetentionWorker) Start() {
+	interval := w.config.RetentionInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stopCh:
+				return
+			case <-ticker.C:
+				if _, err := w.RunOnce(context.Background()); err != nil {
+					log.Printf("retention: %v", err)
+				}
+				if _, err := w.PurgeTSPL(context.Background()); err != nil {
+					log.Printf("retention: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (w *RetentionWorker) Stop() {
+	close(w.stopCh)
+	w.wg.Wait()
+}
+
+// Policy returns whether retention is enabled and how many days finished jobs
+// are kept, preferring stored settings over config.
+func (w *RetentionWorker) Policy(ctx context.Context) (bool, int) {
+	enabled := w.config.RetentionEnabled
+	days := w.config.RetentionDays
+
+	if setting, err := db.Settings.GetSetting(ctx, SettingRetentionEnabled); err == nil {
+		enabled = setting.Value == "true"
+	}
+	if setting, err := db.Settings.GetSetting(ctx, SettingRetentionDays); err == nil {
+		if d, err := strconv.Atoi(setting.Value); err == nil && d > 0 {
+			days = d
+		}
+	}
+
+	return enabled, days
+}
+
+// RunOnce applies the retention policy and returns how many jobs were
+// deleted. It does nothing when retention is disabled.
+func (w *RetentionWorker) RunOnce(ctx context.Context) (int64, error) {
+	enabled, days := w.Policy(ctx)
+	if !enabled || days <= 0 {
+		return 0, nil
+	}
+
+	deleted, err := db.Jobs.DeleteCompletedJobsBefore(ctx, days)
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		log.Printf("retention: deleted %d jobs older than %d days", deleted, days)
+	}
+	return deleted, nil
+}
+
+// PurgeTSPL clears the stored TSPL of completed and cancelled template jobs
+// older than tspl_retention_days and returns how many were cleared. The jobs
+// are kept, and reprinting one regenerates its TSPL from the template. Raw
+// TSPL jobs have no template to regenerate from, so they keep theirs. It
+// does nothing when tspl_retention_days is 0.
+func (w *RetentionWorker) PurgeTSPL(ctx context.Context) (int64, error) {
+	days := w.config.TSPLRetentionDays
+	if days <= 0 {
+		return 0, nil
+	}
+
+	purged, err := db.Jobs.PurgeCompletedJobTSPLBefore(ctx, days)
+	if err != nil {
+		return 0, err
+	}
+	if purged > 0 {
+		log.Printf("retention: cleared the TSPL of %d jobs older than %d days", purged, days)
+	}
+	return purged, nil
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sampleEAN13Base is the 12-digit body used for sample barcode values; the
+// check digit is computed so the sample scans.
+const sampleEAN13Base = "400638133393"
+
+// sampleGenerators produce a realistic value for a variable of the given
+// type when the variable has no sample or default of its own.
+var sampleGenerators = map[string]func(name string) string{
+	"string": func(name string) string {
+		return "Sample " + strings.ReplaceAll(name, "_", " ")
+	},
+	"number": func(string) string {
+		return "42"
+	},
+	"barcode": func(string) string {
+		return sampleEAN13Base + strconv.Itoa(EAN13CheckDigit(sampleEAN13Base))
+	},
+	"date": func(string) string {
+		return time.Now().Format("2006-01-02")
+	},
+	"datetime": func(string) string {
+		return time.Now().Format("2006-01-02 15:04")
+	},
+}
+
+// EAN13CheckDigit returns the check digit for the first 12 digits of an
+// EAN-13 code. Digits in odd positions (from the left, starting at 1) have
+// weight 1 and even positions weight 3.
+func EAN13CheckDigit(digits string) int {
+	sum := 0
+	for i := 0; i < 12 && i < len(digits); i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// SampleValue returns the value a preview uses for a variable: its sample
+// hint, then its default, then a value generated from its type and format.
+func SampleValue(name string, def VariableDef) string {
+	if def.Sample != "" {
+		return def.Sample
+	}
+	if def.Default != "" {
+		return def.Default
+	}
+	if def.Format != "" && (def.Type == "date" || def.Type == "datetime") {
+		return time.Now().Format(def.Format)
+	}
+	if gen, ok := sampleGenerators[def.Type]; ok {
+		return gen(name)
+	}
+	return sampleGenerators["string"](name)
+}
+
+// SampleVariables returns a sample value for every variable in schema. A
+// variable that is the whole content of an EAN or UPC barcode gets a sample
+// of the length that symbology needs, unless it has a sample or default.
+func (g *TSPL2Generator) SampleVariables(schema *LabelSchema) map[string]string {
+	samples := make(map[string]string, len(schema.Variables))
+	for name, def := range schema.Variables {
+		samples[name] = SampleValue(name, def)
+	}
+	for _, elem := range schema.Elements {
+		if elem.Type != "barcode" {
+			continue
+		}
+		match := variablePattern.FindStringSubmatch(elem.Content)
+		if match == nil || match[0] != elem.Content || match[2] != "" {
+			continue
+		}
+		def, ok := schema.Variables[match[1]]
+		if !ok || def.Sample != "" || def.Default != "" {
+			continue
+		}
+		if sample := retailSample(elem.Symbology); sample != "" {
+			samples[match[1]] = sample
+		}
+	}
+	return samples
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// validEAN13 reports whether code is 13 digits ending in the right check
+// digit.
+func validEAN13(code string) bool {
+	if len(code) != 13 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return int(code[12]-'0') == EAN13CheckDigit(code[:12])
+}
+
+func TestEAN13CheckDigit(t *testing.T) {
+	for body, want := range map[string]int{
+		"400638133393": 1,
+		"590123412345": 7,
+		"978020137962": 4,
+		"000000000000": 0,
+	} {
+		if got := EAN13CheckDigit(body); got != want {
+			t.Errorf("EAN13CheckDigit(%s) = %d, want %d", body, got, want)
+		}
+	}
+}
+
+func TestSampleBarcodeHasValidCheckDigit(t *testing.T) {
+	sample := SampleValue("sku", VariableDef{Type: "barcode"})
+	if !validEAN13(sample) {
+		t.Errorf("barcode sample %q is not a valid EAN-13", sample)
+	}
+}
+
+func TestSampleValuePrecedence(t *testing.T) {
+	tests := []struct {
+		def  VariableDef
+		want string
+	}{
+		{VariableDef{Type: "string", Sample: "ACME Ltd", Default: "unused"}, "ACME Ltd"},
+		{VariableDef{Type: "number", Default: "7"}, "7"},
+		{VariableDef{Type: "number"}, "42"},
+		{VariableDef{Type: "string"}, "Sample ship to"},
+		{VariableDef{Type: "colour"}, "Sample ship to"},
+	}
+	for _, tt := range tests {
+		if got := SampleValue("ship_to", tt.def); got != tt.want {
+			t.Errorf("SampleValue(%+v) = %q, want %q", tt.def, got, tt.want)
+		}
+	}
+
+	if _, err := time.Parse("2006-01-02", SampleValue("packed_on", VariableDef{Type: "date"})); err != nil {
+		t.Errorf("date sample does not parse: %v", err)
+	}
+}
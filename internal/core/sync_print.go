@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrPrinterPaused = errors.New("printer is paused")
+
+// syncPrintPollInterval is how often PrintNow checks whether a printer busy
+// with a queued job has finished it.
+const syncPrintPollInterval = 50 * time.Millisecond
+
+// SyncPrintTimeout returns how long a synchronous print may take, including
+// waiting for the printer to finish a queued job.
+func (q *Queue) SyncPrintTimeout() time.Duration {
+	if q.config.SyncPrintTimeout > 0 {
+		return q.config.SyncPrintTimeout
+	}
+	return 30 * time.Second
+}
+
+// PrintNow prints job on its printer straight away instead of queueing it,
+// and returns once the printer has accepted or refused it. The job is
+// recorded as processing while it prints and then as completed or failed,
+// so it appears in the history like any other. A failed synchronous print
+// is not retried or failed over: the caller sees the error and decides.
+//
+// The printer is claimed the same way workers claim it, so a synchronous
+// print waits for a queued job already printing there rather than
+// interleaving with it. ctx bounds that wait as well as the print. The
+// returned ID is 0 only if the job could not be recorded.
+func (q *Queue) PrintNow(ctx context.Context, job *Job) (int64, error) {
+	q.mu.RLock()
+	paused := q.pausedPrinters[job.PrinterID]
+	q.mu.RUnlock()
+	if paused {
+		return 0, ErrPrinterPaused
+	}
+
+	job.Status = JobStatusProcessing
+	jobID, err := q.Enqueue(job)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	job.StartedAt = &now
+	q.updateJobStatus(jobID, JobStatusProcessing, "", &now, nil)
+
+	if err := q.acquirePrinter(ctx, job.PrinterID); err != nil {
+		q.failJob(job, "waiting for printer: "+err.Error())
+		return jobID, err
+	}
+	defer q.releasePrinter(job.PrinterID)
+
+	q.emit("job_started", job, JobStatusProcessing, "")
+
+	if q.printerManager == nil {
+		err := errors.New("printer manager not configured")
+		q.failJob(job, err.Error())
+		return jobID, err
+	}
+
+	if err := q.printerManager.Print(ctx, job.PrinterID, job.TSPLContent, job.Copies); err != nil {
+		q.feedAfterFailure(job, err)
+		q.failJob(job, err.Error())
+		return jobID, err
+	}
+
+	completed := time.Now()
+	q.updateJobStatus(jobID, JobStatusCompleted, "", &now, &completed)
+	q.emit("job_completed", job, JobStatusCompleted, "")
+	q.queueThumbnail(job)
+	q.printerManager.IncrementPrintCount(job.PrinterID, job.Copies)
+	q.incrementPrintCounter(job.PrinterID, job.Copies)
+
+	return jobID, nil
+}
+
+// acquirePrinter marks printerID as printing, first waiting for a job
+// already printing there to finish, so workers skip the printer until
+// releasePrinter.
+func (q *Queue) acquirePrinter(ctx context.Context, printerID int64) error {
+	ticker := time.NewTicker(syncPrintPollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.printingMu.Lock()
+		if !q.printing[printerID] {
+			q.printing[printerID] = true
+			q.printingMu.Unlock()
+			return nil
+		}
+		q.printingMu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPrintNow(t *testing.T) {
+	database := newTestDB(t)
+	onlineID := insertTestPrinter(t, database, "online")
+	offlineID := insertTestPrinter(t, database, "offline")
+	pm := newFakePrinterManager()
+	pm.addPrinter(&Printer{ID: onlineID, Status: "online"})
+	pm.addPrinter(&Printer{ID: offlineID, Status: "offline"})
+	q := NewQueue(database, pm, nil, nil, nil)
+
+	t.Run("success", func(t *testing.T) {
+		jobID, err := q.PrintNow(context.Background(), &Job{PrinterID: onlineID, TSPLContent: "PRINT 1", Copies: 1})
+		if err != nil {
+			t.Fatalf("PrintNow: %v", err)
+		}
+		if got := jobStatus(t, database, jobID); got != JobStatusCompleted {
+			t.Fatalf("job status = %s, want completed", got)
+		}
+		if got := pm.printCount("PRINT 1"); got != 1 {
+			t.Fatalf("printed %d times, want 1", got)
+		}
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		jobID, err := q.PrintNow(context.Background(), &Job{PrinterID: offlineID, TSPLContent: "PRINT 2", Copies: 1})
+		if !errors.Is(err, ErrPrinterOffline) {
+			t.Fatalf("PrintNow error = %v, want ErrPrinterOffline", err)
+		}
+		if jobID == 0 {
+			t.Fatal("failed print was not recorded")
+		}
+		if got := jobStatus(t, database, jobID); got != JobStatusFailed {
+			t.Fatalf("job status = %s, want failed", got)
+		}
+	})
+
+	t.Run("waits for a busy printer", func(t *testing.T) {
+		q.printingMu.Lock()
+		q.printing[onlineID] = true
+		q.printingMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		jobID, err := q.PrintNow(ctx, &Job{PrinterID: onlineID, TSPLContent: "PRINT 3", Copies: 1})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("PrintNow error = %v, want deadline exceeded", err)
+		}
+		if got := jobStatus(t, database, jobID); got != JobStatusFailed {
+			t.Fatalf("job status = %s, want failed", got)
+		}
+		if got := pm.printCount("PRINT 3"); got != 0 {
+			t.Fatalf("printed %d times while the printer was busy", got)
+		}
+		q.releasePrinter(onlineID)
+	})
+
+	t.Run("paused", func(t *testing.T) {
+		if err := q.PausePrinter(onlineID); err != nil {
+			t.Fatalf("pause printer: %v", err)
+		}
+		defer q.ResumePrinter(onlineID)
+
+		if _, err := q.PrintNow(context.Background(), &Job{PrinterID: onlineID, TSPLContent: "PRINT 4", Copies: 1}); !errors.Is(err, ErrPrinterPaused) {
+			t.Fatalf("PrintNow error = %v, want ErrPrinterPaused", err)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"fmt"
+	"image"
+)
+
+// Lint finding codes.
+const (
+	LintOverlap             = "overlap"
+	LintZeroSize            = "zero_size"
+	LintNegativeCoordinates = "negative_coordinates"
+)
+
+// LintFinding is a layout problem in a template. Elements holds the indices
+// of the elements involved, in schema order.
+type LintFinding struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Elements []int  `json:"elements"`
+}
+
+// outlinedElements are drawn as an outline, so an element inside one does
+// not overlap it.
+var outlinedElements = map[string]bool{
+	"box":     true,
+	"circle":  true,
+	"ellipse": true,
+}
+
+// Lint reports elements whose bounding boxes overlap, elements that draw
+// nothing and elements placed at negative coordinates. Bounding boxes are
+// measured by drawing each element as Render would with variables, so
+// text and barcodes are sized for those values. Conditional elements are
+// linted as if shown. An element that cannot be drawn is left to
+// validation and skipped here.
+func (g *TSPL2Generator) Lint(schema *LabelSchema, variables map[string]string) []LintFinding {
+	dpi := schema.DPI
+	if dpi == 0 {
+		dpi = 203
+	}
+
+	var findings []LintFinding
+	bounds := make([]image.Rectangle, len(schema.Elements))
+	drawn := make([]bool, len(schema.Elements))
+	for i := range schema.Elements {
+		elem := schema.Elements[i]
+		elem.ShowIf = ""
+
+		if elem.X < 0 || elem.Y < 0 || elem.X1 < 0 || elem.Y1 < 0 {
+			findings = append(findings, LintFinding{
+				Code:     LintNegativeCoordinates,
+				Message:  fmt.Sprintf("element %d (%s) has negative coordinates", i, elem.Type),
+				Elements: []int{i},
+			})
+		}
+
+		canvas := &labelCanvas{img: image.NewGray(image.Rectangle{}), inked: &bounds[i]}
+		if err := g.renderElement(canvas, &elem, variables, schema, dpi); err != nil {
+			continue
+		}
+		if zeroSizeShape(&elem) || bounds[i].Empty() {
+			findings = append(findings, LintFinding{
+				Code:     LintZeroSize,
+				Message:  fmt.Sprintf("element %d (%s) has zero size", i, elem.Type),
+				Elements: []int{i},
+			})
+			continue
+		}
+		drawn[i] = true
+	}
+
+	for i := range schema.Elements {
+		for j := i + 1; j < len(schema.Elements); j++ {
+			if !drawn[i] || !drawn[j] || !bounds[i].Overlaps(bounds[j]) {
+				continue
+			}
+			if insideOutline(&schema.Elements[i], bounds[i], bounds[j]) || insideOutline(&schema.Elements[j], bounds[j], bounds[i]) {
+				continue
+			}
+			findings = append(findings, LintFinding{
+				Code: LintOverlap,
+				Message: fmt.Sprintf("element %d (%s) overlaps element %d (%s)",
+					i, schema.Elements[i].Type, j, schema.Elements[j].Type),
+				Elements: []int{i, j},
+			})
+		}
+	}
+
+	return findings
+}
+
+// zeroSizeShape reports whether a shape's own dimensions are empty. The
+// printer may still draw a stray line for one, so it is not left to the
+// measured bounds.
+func zeroSizeShape(elem *LabelElement) bool {
+	switch elem.Type {
+	case "box":
+		return elem.XEnd <= elem.X || elem.YEnd <= elem.Y
+	case "line":
+		return elem.X2 <= 0 || elem.Y2 <= 0
+	case "circle":
+		return elem.Radius <= 0
+	case "ellipse":
+		return elem.XRadius <= 0 || elem.YRadius <= 0
+	case "block":
+		return elem.Width <= 0 || elem.Height <= 0
+	}
+	return false
+}
+
+// insideOutline reports whether inner lies wholly within the empty interior
+// of the outlined element elem drawn over outer.
+func insideOutline(elem *LabelElement, outer, inner image.Rectangle) bool {
+	if !outlinedElements[elem.Type] {
+		return false
+	}
+	thickness := elem.Thickness
+	if thickness == 0 {
+		thickness = 1
+	}
+	return inner.In(outer.Inset(thickness))
+}
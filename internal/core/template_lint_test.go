@@ -0,0 +1,66 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements []LabelElement
+		want     []LintFinding
+	}{
+		{
+			name: "overlapping boxes",
+			elements: []LabelElement{
+				{Type: "box", X: 10, Y: 10, XEnd: 100, YEnd: 100, Thickness: 2},
+				{Type: "box", X: 50, Y: 50, XEnd: 150, YEnd: 150, Thickness: 2},
+			},
+			want: []LintFinding{{Code: LintOverlap, Message: "element 0 (box) overlaps element 1 (box)", Elements: []int{0, 1}}},
+		},
+		{
+			name: "separate elements",
+			elements: []LabelElement{
+				{Type: "box", X: 10, Y: 10, XEnd: 100, YEnd: 100},
+				{Type: "text", X: 150, Y: 10, Content: "SKU"},
+			},
+		},
+		{
+			name: "text inside a frame",
+			elements: []LabelElement{
+				{Type: "box", X: 0, Y: 0, XEnd: 300, YEnd: 200, Thickness: 3},
+				{Type: "text", X: 20, Y: 20, Content: "SKU"},
+			},
+		},
+		{
+			name: "barcode over text",
+			elements: []LabelElement{
+				{Type: "text", X: 20, Y: 20, Content: "SKU 12345"},
+				{Type: "barcode", X: 30, Y: 25, Content: "12345", Height: 40},
+			},
+			want: []LintFinding{{Code: LintOverlap, Message: "element 0 (text) overlaps element 1 (barcode)", Elements: []int{0, 1}}},
+		},
+		{
+			name: "zero-size and negative elements",
+			elements: []LabelElement{
+				{Type: "box", X: 10, Y: 10, XEnd: 10, YEnd: 50},
+				{Type: "text", X: -5, Y: 20, Content: "A"},
+			},
+			want: []LintFinding{
+				{Code: LintZeroSize, Message: "element 0 (box) has zero size", Elements: []int{0}},
+				{Code: LintNegativeCoordinates, Message: "element 1 (text) has negative coordinates", Elements: []int{1}},
+			},
+		},
+	}
+
+	g := NewTSPL2Generator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &LabelSchema{WidthMM: 50, HeightMM: 30, DPI: 203, Elements: tt.elements}
+			if got := g.Lint(schema, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+)
+
+// DefaultThumbnailSize is the longer side of a job thumbnail in pixels when
+// the queue config leaves it unset.
+const DefaultThumbnailSize = 200
+
+// thumbnailBacklog is how many completed jobs can wait for a thumbnail
+// before further ones are skipped, so rendering never holds up printing.
+const thumbnailBacklog = 64
+
+// queueThumbnail hands a completed job to the thumbnailer, which renders it
+// in the background. It never blocks: when the backlog is full the job goes
+// without a thumbnail.
+func (q *Queue) queueThumbnail(job *Job) {
+	if !q.config.Thumbnails {
+		return
+	}
+	snapshot := *job
+	select {
+	case q.thumbnails <- &snapshot:
+	default:
+		log.Printf("thumbnail backlog full, skipping thumbnail for job %d", job.ID)
+	}
+}
+
+// thumbnailer stores the thumbnails of queued jobs until the queue stops.
+func (q *Queue) thumbnailer() {
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case job := <-q.thumbnails:
+			q.storeThumbnail(job)
+		}
+	}
+}
+
+// storeThumbnail renders the label a completed job printed, scaled down to
+// a thumbnail, and stores it as a PNG keyed by the job ID. Failures are
+// logged and never fail the job.
+func (q *Queue) storeThumbnail(job *Job) {
+	thumbnail, err := q.renderThumbnail(job)
+	if err != nil {
+		log.Printf("failed to render thumbnail for job %d: %v", job.ID, err)
+		return
+	}
+	if _, err := q.db.Exec(`
+		INSERT OR REPLACE INTO job_thumbnails (job_id, png) VALUES (?, ?)
+	`, job.ID, thumbnail); err != nil {
+		log.Printf("failed to store thumbnail for job %d: %v", job.ID, err)
+	}
+}
+
+// renderThumbnail rasterizes the TSPL the job sent at its printer's DPI and
+// encodes it as a PNG no larger than ThumbnailSize on its longer side.
+// Drawing the TSPL rather than the template shows what was printed even
+// after the template changes, and covers raw TSPL jobs.
+func (q *Queue) renderThumbnail(job *Job) ([]byte, error) {
+	dpi := 0
+	if printer, err := q.printerManager.GetPrinter(job.PrinterID); err == nil {
+		dpi = printer.DPI
+	}
+	img, err := NewTSPL2Generator().renderTSPL(job.TSPLContent, dpi)
+	if err != nil {
+		return nil, err
+	}
+
+	size := q.config.ThumbnailSize
+	if size <= 0 {
+		size = DefaultThumbnailSize
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, shrinkToFit(img, size)); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// shrinkToFit scales img down so its longer side is at most size pixels,
+// averaging the dots each pixel covers so thin lines stay visible as grey.
+// Images that already fit are returned unchanged.
+func shrinkToFit(img *image.Gray, size int) *image.Gray {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	longer := max(w, h)
+	if longer <= size {
+		return img
+	}
+
+	tw, th := max(w*size/longer, 1), max(h*size/longer, 1)
+	thumb := image.NewGray(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := ty*h/th, max((ty+1)*h/th, ty*h/th+1)
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := tx*w/tw, max((tx+1)*w/tw, tx*w/tw+1)
+			sum := 0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += int(img.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y)
+				}
+			}
+			thumb.Pix[ty*thumb.Stride+tx] = uint8(sum / ((y1 - y0) * (x1 - x0)))
+		}
+	}
+	return thumb
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bytes"
+	"database/sql"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+// thumbnailLabel is a 50x30 mm raw TSPL label with a frame and a QR code.
+const thumbnailLabel = "SIZE 50 mm, 30 mm\r\nCLS\r\nBOX 10,10,380,220,4\r\nQRCODE 40,40,M,4,0,A,\"SKU-42\"\r\nPRINT 1\r\n"
+
+// waitForThumbnail waits until a thumbnail is stored for the job and
+// decodes it.
+func waitForThumbnail(t *testing.T, database *sql.DB, jobID int64) image.Image {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var data []byte
+		err := database.QueryRow("SELECT png FROM job_thumbnails WHERE job_id = ?", jobID).Scan(&data)
+		if err == nil {
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decode thumbnail: %v", err)
+			}
+			return img
+		}
+		if err != sql.ErrNoRows {
+			t.Fatalf("get thumbnail: %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no thumbnail stored for job %d", jobID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCompletedJobHasThumbnail(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	pm := newFakePrinterManager()
+	pm.addPrinter(&Printer{ID: printerID, Status: "online", DPI: 203})
+
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{WorkerCount: 1, Thumbnails: true, ThumbnailSize: 100})
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	jobID, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: thumbnailLabel, Copies: 1})
+	if err != nil {
+		t.Fatalf("enqueue job: %v", err)
+	}
+	img := waitForThumbnail(t, database, jobID)
+
+	// The 50x30 mm label is 399x239 dots at 203 DPI.
+	if got, want := img.Bounds().Size(), image.Pt(100, 59); got != want {
+		t.Errorf("thumbnail is %v, want %v", got, want)
+	}
+	// The frame's top-left corner is at (2, 2) in the thumbnail.
+	if r, _, _, _ := img.At(3, 3).RGBA(); r >= 0x8000 {
+		t.Error("thumbnail has no frame, want the label's box")
+	}
+}
+
+func TestThumbnailsOff(t *testing.T) {
+	database := newTestDB(t)
+	printerID := insertTestPrinter(t, database, "printer")
+	pm := newFakePrinterManager()
+	pm.addPrinter(&Printer{ID: printerID, Status: "online"})
+
+	q := NewQueue(database, pm, nil, nil, &config.QueueConfig{WorkerCount: 1})
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+
+	if _, err := q.Enqueue(&Job{PrinterID: printerID, TSPLContent: thumbnailLabel, Copies: 1}); err != nil {
+		t.Fatalf("enqueue job: %v", err)
+	}
+	waitForJobs(t, database, 1)
+
+	var n int
+	if err := database.QueryRow("SELECT COUNT(*) FROM job_thumbnails").Scan(&n); err != nil {
+		t.Fatalf("count thumbnails: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("%d thumbnails stored with thumbnails off, want 0", n)
+	}
+}
@@ -3,9 +3,12 @@ package core
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type LabelSchema struct {
@@ -14,8 +17,33 @@ type LabelSchema struct {
 	HeightMM  float64                `json:"height_mm"`
 	GapMM     float64                `json:"gap_mm"`
 	DPI       int                    `json:"dpi"`
+	Codepage  string                 `json:"codepage,omitempty"`
+	Direction int                    `json:"direction,omitempty"`
+	Mirror    bool                   `json:"mirror,omitempty"`
 	Elements  []LabelElement         `json:"elements"`
 	Variables map[string]VariableDef `json:"variables"`
+
+	// MediaType is gap (the default), bline or continuous; see
+	// ValidateLabelMedia. BlineMM is the height of the black mark on bline
+	// media, and OffsetMM how far past the gap or mark the label starts.
+	MediaType string  `json:"media_type,omitempty"`
+	BlineMM   float64 `json:"bline_mm,omitempty"`
+	OffsetMM  float64 `json:"offset_mm,omitempty"`
+
+	// PostCommands are printer commands such as SET CUTTER or CUT sent with
+	// each label; see ValidatePostCommands.
+	PostCommands []string `json:"post_commands,omitempty"`
+
+	// Speed in inches per second and Density (print darkness) override the
+	// printer's settings for this label. Unset leaves the printer's own.
+	Speed   int  `json:"speed,omitempty"`
+	Density *int `json:"density,omitempty"`
+
+	// FlipVertical and FlipHorizontal move every element to the opposite
+	// side of the label, for fixtures that feed labels upside down or
+	// reversed, without repositioning each element by hand.
+	FlipVertical   bool `json:"flip_vertical,omitempty"`
+	FlipHorizontal bool `json:"flip_horizontal,omitempty"`
 }
 
 type LabelElement struct {
@@ -23,6 +51,10 @@ type LabelElement struct {
 	X    int    `json:"x"`
 	Y    int    `json:"y"`
 
+	// ShowIf names a variable the element depends on; the element is only
+	// drawn when that variable is truthy. A leading "!" inverts the test.
+	ShowIf string `json:"show_if,omitempty"`
+
 	Content   string `json:"content,omitempty"`
 	Font      string `json:"font,omitempty"`
 	Rotation  int    `json:"rotation,omitempty"`
@@ -33,9 +65,13 @@ type LabelElement struct {
 	Height    int    `json:"height,omitempty"`
 	Narrow    int    `json:"narrow,omitempty"`
 	Wide      int    `json:"wide,omitempty"`
+	// HumanReadable prints a barcode's content under it: 0 for none, or
+	// 1, 2 or 3 to align the text left, centre or right.
+	HumanReadable int `json:"human_readable,omitempty"`
 
 	Level     string `json:"level,omitempty"`
 	CellWidth int    `json:"cell_width,omitempty"`
+	Mask      *int   `json:"mask,omitempty"`
 
 	XEnd      int `json:"x_end,omitempty"`
 	YEnd      int `json:"y_end,omitempty"`
@@ -59,17 +95,42 @@ type LabelElement struct {
 	Encoding string `json:"encoding,omitempty"`
 
 	ImagePath string `json:"image_path,omitempty"`
+	// ImageData (base64) or Embed with ImagePath sends the image as a BITMAP
+	// command instead of referencing a file already on the printer.
+	ImageData string `json:"image_data,omitempty"`
+	Embed     bool   `json:"embed,omitempty"`
+	Threshold int    `json:"threshold,omitempty"`
+	Dither    bool   `json:"dither,omitempty"`
 
 	Width  int `json:"width,omitempty"`
 	Spacing int `json:"spacing,omitempty"`
+	// Overflow is the block policy when content exceeds the box: clip
+	// (default), ellipsis, shrink or error.
+	Overflow string `json:"overflow,omitempty"`
 }
 
+// VariableDef describes a template variable. Locked variables are filled by
+// the server from Source and cannot be supplied by clients. Sample is an
+// example value used only by previews. Format is the Go time layout a date
+// or datetime value must be in, and Pattern a regular expression the whole
+// value must match; see CheckVariableValue.
 type VariableDef struct {
 	Type     string `json:"type"`
 	Required bool   `json:"required"`
 	Default  string `json:"default"`
+	Locked   bool   `json:"locked,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Sample   string `json:"sample,omitempty"`
+	Format   string `json:"format,omitempty"`
+	Pattern  string `json:"pattern,omitempty"`
 }
 
+const (
+	VariableSourceDefault  = "default"
+	VariableSourceDate     = "date"
+	VariableSourceDateTime = "datetime"
+)
+
 type TSPL2Generator struct{}
 
 func NewTSPL2Generator() *TSPL2Generator {
@@ -89,32 +150,223 @@ func (g *TSPL2Generator) ParseSchema(jsonStr string) (*LabelSchema, error) {
 
 func (g *TSPL2Generator) ValidateVariables(schema *LabelSchema, variables map[string]string) error {
 	for name, def := range schema.Variables {
+		if def.Locked && def.Source != "" && def.Source != VariableSourceDefault {
+			continue
+		}
 		value, provided := variables[name]
 		if !provided || value == "" {
 			if def.Required && def.Default == "" {
 				return fmt.Errorf("required variable '%s' is missing", name)
 			}
+			continue
+		}
+		if err := CheckVariableValue(name, def, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckLockedVariables rejects client-supplied values for locked variables.
+func (g *TSPL2Generator) CheckLockedVariables(schema *LabelSchema, variables map[string]string) error {
+	for name, def := range schema.Variables {
+		if !def.Locked {
+			continue
+		}
+		if _, provided := variables[name]; provided {
+			return fmt.Errorf("variable '%s' is server-controlled and cannot be set", name)
 		}
 	}
 	return nil
 }
 
-func (g *TSPL2Generator) substituteVariables(content string, variables map[string]string, schema *LabelSchema) string {
-	result := content
-	re := regexp.MustCompile(`\{\{(\w+)\}\}`)
+// ApplyServerVariables returns a copy of variables with every locked
+// variable filled from its configured source.
+func (g *TSPL2Generator) ApplyServerVariables(schema *LabelSchema, variables map[string]string) map[string]string {
+	result := make(map[string]string, len(variables))
+	for name, value := range variables {
+		result[name] = value
+	}
+
+	now := time.Now()
+	for name, def := range schema.Variables {
+		if !def.Locked {
+			continue
+		}
+		switch def.Source {
+		case VariableSourceDate:
+			result[name] = now.Format("2006-01-02")
+		case VariableSourceDateTime:
+			result[name] = now.Format("2006-01-02 15:04")
+		default:
+			result[name] = def.Default
+		}
+	}
+	return result
+}
+
+// ScanContent returns the content of the first scannable element (barcode,
+// QR, DataMatrix or PDF417) that generation prints, i.e. what a scanner
+// reading the printed label should return. Elements hidden by show_if are
+// skipped, and barcode content gets the check digit generation appends.
+// ok is false when the printed label has no scannable element.
+func (g *TSPL2Generator) ScanContent(schema *LabelSchema, variables map[string]string) (content string, ok bool, err error) {
+	for _, elem := range schema.Elements {
+		if !g.elementVisible(&elem, variables, schema) {
+			continue
+		}
+		switch elem.Type {
+		case "barcode":
+			content, err := g.barcodeContent(&elem, variables, schema)
+			return content, true, err
+		case "qrcode", "datamatrix", "pdf417":
+			content, err := g.substituteVariables(elem.Content, variables, schema)
+			return content, true, err
+		}
+	}
+	return "", false, nil
+}
+
+// variablePattern matches {{name}} and {{name|directive|directive:arg}}.
+var variablePattern = regexp.MustCompile(`\{\{(\w+)((?:\|[^|{}]+)*)\}\}`)
+
+// variableDateLayouts are the formats a value may be in for the date
+// directive, including those produced by the date and datetime sources.
+var variableDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// variableDirectives are the named transforms that can follow a variable
+// name, e.g. {{sku|upper}} or {{qty|pad:6}}. Each receives the current value
+// and the text after the first colon, if any. Directives run left to right.
+var variableDirectives = map[string]func(value, arg string) (string, error){
+	"upper": func(value, _ string) (string, error) {
+		return strings.ToUpper(value), nil
+	},
+	"lower": func(value, _ string) (string, error) {
+		return strings.ToLower(value), nil
+	},
+	"trim": func(value, _ string) (string, error) {
+		return strings.TrimSpace(value), nil
+	},
+	// truncate:N keeps the first N characters.
+	"truncate": func(value, arg string) (string, error) {
+		n, err := directiveCount(arg)
+		if err != nil {
+			return "", err
+		}
+		if runes := []rune(value); len(runes) > n {
+			return string(runes[:n]), nil
+		}
+		return value, nil
+	},
+	// pad:N[:C] left-pads to N characters with C, default "0".
+	"pad": func(value, arg string) (string, error) {
+		width, fill := arg, "0"
+		if i := strings.Index(arg, ":"); i >= 0 {
+			width, fill = arg[:i], arg[i+1:]
+		}
+		n, err := directiveCount(width)
+		if err != nil {
+			return "", err
+		}
+		if utf8.RuneCountInString(fill) != 1 {
+			return "", fmt.Errorf("pad character must be a single character")
+		}
+		if count := n - utf8.RuneCountInString(value); count > 0 {
+			return strings.Repeat(fill, count) + value, nil
+		}
+		return value, nil
+	},
+	// currency[:SYMBOL] formats a number with two decimals.
+	"currency": func(value, arg string) (string, error) {
+		if value == "" {
+			return "", nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("value '%s' is not a number", value)
+		}
+		if f < 0 {
+			return fmt.Sprintf("-%s%.2f", arg, -f), nil
+		}
+		return fmt.Sprintf("%s%.2f", arg, f), nil
+	},
+	// date:LAYOUT reformats a date using a Go time layout.
+	"date": func(value, arg string) (string, error) {
+		if arg == "" {
+			return "", fmt.Errorf("date directive needs a layout")
+		}
+		if value == "" {
+			return "", nil
+		}
+		for _, layout := range variableDateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+				return t.Format(arg), nil
+			}
+		}
+		return "", fmt.Errorf("value '%s' is not a date", value)
+	},
+}
+
+func directiveCount(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a non-negative count, got '%s'", arg)
+	}
+	return n, nil
+}
+
+// applyDirectives runs the "|directive|directive:arg" suffix of a variable
+// reference over value.
+func applyDirectives(value, pipeline string) (string, error) {
+	if pipeline == "" {
+		return value, nil
+	}
+	for _, directive := range strings.Split(pipeline[1:], "|") {
+		name, arg := directive, ""
+		if i := strings.Index(directive, ":"); i >= 0 {
+			name, arg = directive[:i], directive[i+1:]
+		}
+		name = strings.TrimSpace(name)
+		transform, ok := variableDirectives[name]
+		if !ok {
+			return "", fmt.Errorf("unknown directive '%s'", name)
+		}
+		var err error
+		if value, err = transform(value, arg); err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return value, nil
+}
 
-	matches := re.FindAllStringSubmatch(content, -1)
-	for _, match := range matches {
-		varName := match[1]
+func (g *TSPL2Generator) substituteVariables(content string, variables map[string]string, schema *LabelSchema) (string, error) {
+	var substErr error
+	result := variablePattern.ReplaceAllStringFunc(content, func(match string) string {
+		if substErr != nil {
+			return match
+		}
+		m := variablePattern.FindStringSubmatch(match)
+		varName := m[1]
 		value, provided := variables[varName]
 		if !provided || value == "" {
 			if def, exists := schema.Variables[varName]; exists {
 				value = def.Default
 			}
 		}
-		result = strings.ReplaceAll(result, match[0], value)
-	}
-	return result
+		formatted, err := applyDirectives(value, m[2])
+		if err != nil {
+			substErr = fmt.Errorf("variable %s: %w", match, err)
+			return match
+		}
+		return formatted
+	})
+	return result, substErr
 }
 
 func (g *TSPL2Generator) Generate(schema *LabelSchema, variables map[string]string) (string, error) {
@@ -122,12 +374,36 @@ func (g *TSPL2Generator) Generate(schema *LabelSchema, variables map[string]stri
 		return "", err
 	}
 
+	codepage, err := codepageCommand(schema.Codepage)
+	if err != nil {
+		return "", err
+	}
+	direction, err := directionCommand(schema.Direction, schema.Mirror)
+	if err != nil {
+		return "", err
+	}
+	settings, trailer, err := postCommandLines(schema.PostCommands)
+	if err != nil {
+		return "", err
+	}
+	quality, err := qualityCommands(schema.Speed, schema.Density)
+	if err != nil {
+		return "", err
+	}
+	media, err := mediaCommands(schema, 0)
+	if err != nil {
+		return "", err
+	}
+
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("SIZE %.0f mm, %.0f mm\n", schema.WidthMM, schema.HeightMM))
-	sb.WriteString(fmt.Sprintf("GAP %.0f mm, 0 mm\n", schema.GapMM))
-	sb.WriteString("DIRECTION 0\n")
+	sb.WriteString(fmt.Sprintf("SIZE %s mm, %s mm\n", FormatMM(schema.WidthMM), FormatMM(schema.HeightMM)))
+	sb.WriteString(media)
+	sb.WriteString(quality)
+	sb.WriteString(direction)
+	sb.WriteString(settings)
 	sb.WriteString("CLS\n")
+	sb.WriteString(codepage)
 
 	for _, elem := range schema.Elements {
 		cmd, err := g.generateElement(&elem, variables, schema)
@@ -141,21 +417,60 @@ func (g *TSPL2Generator) Generate(schema *LabelSchema, variables map[string]stri
 	}
 
 	sb.WriteString("PRINT 1\n")
+	sb.WriteString(trailer)
 	return sb.String(), nil
 }
 
+// ConditionVariable returns the variable a show_if condition refers to and
+// whether the condition is negated.
+func ConditionVariable(cond string) (name string, negate bool) {
+	cond = strings.TrimSpace(cond)
+	if strings.HasPrefix(cond, "!") {
+		return strings.TrimSpace(cond[1:]), true
+	}
+	return cond, false
+}
+
+// elementVisible reports whether elem's show_if condition holds. A variable
+// that is unset falls back to its default; empty, "0", "false", "no" and
+// "off" are false and anything else is true.
+func (g *TSPL2Generator) elementVisible(elem *LabelElement, variables map[string]string, schema *LabelSchema) bool {
+	if elem.ShowIf == "" {
+		return true
+	}
+	name, negate := ConditionVariable(elem.ShowIf)
+	value, provided := variables[name]
+	if !provided || value == "" {
+		if def, exists := schema.Variables[name]; exists {
+			value = def.Default
+		}
+	}
+
+	truthy := true
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false", "no", "off":
+		truthy = false
+	}
+	return truthy != negate
+}
+
 func (g *TSPL2Generator) generateElement(elem *LabelElement, variables map[string]string, schema *LabelSchema) (string, error) {
+	if !g.elementVisible(elem, variables, schema) {
+		return "", nil
+	}
+	elem = g.flipElement(elem, variables, schema)
+
 	switch elem.Type {
 	case "text":
-		return g.generateText(elem, variables, schema), nil
+		return g.generateText(elem, variables, schema)
 	case "barcode":
-		return g.generateBarcode(elem, variables, schema), nil
+		return g.generateBarcode(elem, variables, schema)
 	case "qrcode":
-		return g.generateQRCode(elem, variables, schema), nil
+		return g.generateQRCode(elem, variables, schema)
 	case "pdf417":
-		return g.generatePDF417(elem, variables, schema), nil
+		return g.generatePDF417(elem, variables, schema)
 	case "datamatrix":
-		return g.generateDataMatrix(elem, variables, schema), nil
+		return g.generateDataMatrix(elem, variables, schema)
 	case "box":
 		return g.generateBox(elem), nil
 	case "line":
@@ -165,16 +480,19 @@ func (g *TSPL2Generator) generateElement(elem *LabelElement, variables map[strin
 	case "ellipse":
 		return g.generateEllipse(elem), nil
 	case "block":
-		return g.generateBlock(elem, variables, schema), nil
+		return g.generateBlock(elem, variables, schema)
 	case "image":
-		return g.generateImage(elem), nil
+		return g.generateImage(elem)
 	default:
 		return "", fmt.Errorf("unsupported element type: %s", elem.Type)
 	}
 }
 
-func (g *TSPL2Generator) generateText(elem *LabelElement, variables map[string]string, schema *LabelSchema) string {
-	content := g.substituteVariables(elem.Content, variables, schema)
+func (g *TSPL2Generator) generateText(elem *LabelElement, variables map[string]string, schema *LabelSchema) (string, error) {
+	content, err := g.substituteVariables(elem.Content, variables, schema)
+	if err != nil {
+		return "", err
+	}
 	content = escapeTSPLString(content)
 	font := elem.Font
 	if font == "" {
@@ -188,16 +506,36 @@ func (g *TSPL2Generator) generateText(elem *LabelElement, variables map[string]s
 	if yScale == 0 {
 		yScale = 1
 	}
-	return fmt.Sprintf(`TEXT %d,%d,"%s",%d,%d,%d,"%s"`, elem.X, elem.Y, font, elem.Rotation, xScale, yScale, content)
+	return fmt.Sprintf(`TEXT %d,%d,"%s",%d,%d,%d,"%s"`, elem.X, elem.Y, font, elem.Rotation, xScale, yScale, content), nil
 }
 
-func (g *TSPL2Generator) generateBarcode(elem *LabelElement, variables map[string]string, schema *LabelSchema) string {
-	content := g.substituteVariables(elem.Content, variables, schema)
-	content = escapeTSPLString(content)
-	symbology := elem.Symbology
-	if symbology == "" {
-		symbology = "128"
+// barcodeSymbology returns a barcode element's symbology, Code 128 if it
+// names none.
+func barcodeSymbology(elem *LabelElement) string {
+	if elem.Symbology == "" {
+		return "128"
+	}
+	return elem.Symbology
+}
+
+// barcodeContent returns the data a barcode element encodes: its content
+// with variables substituted and, for the retail symbologies, the check
+// digit.
+func (g *TSPL2Generator) barcodeContent(elem *LabelElement, variables map[string]string, schema *LabelSchema) (string, error) {
+	content, err := g.substituteVariables(elem.Content, variables, schema)
+	if err != nil {
+		return "", err
 	}
+	return checkRetailBarcode(barcodeSymbology(elem), content)
+}
+
+func (g *TSPL2Generator) generateBarcode(elem *LabelElement, variables map[string]string, schema *LabelSchema) (string, error) {
+	content, err := g.barcodeContent(elem, variables, schema)
+	if err != nil {
+		return "", err
+	}
+	symbology := barcodeSymbology(elem)
+	content = escapeTSPLString(content)
 	height := elem.Height
 	if height == 0 {
 		height = 80
@@ -210,26 +548,45 @@ func (g *TSPL2Generator) generateBarcode(elem *LabelElement, variables map[strin
 	if wide == 0 {
 		wide = 2
 	}
+	if elem.HumanReadable < 0 || elem.HumanReadable > 3 {
+		return "", fmt.Errorf("invalid barcode human_readable %d (valid: 0 for none, 1-3 for left, centre or right)", elem.HumanReadable)
+	}
 	return fmt.Sprintf(`BARCODE %d,%d,"%s",%d,%d,%d,%d,%d,"%s"`,
-		elem.X, elem.Y, symbology, height, elem.Rotation, narrow, wide, narrow, content)
+		elem.X, elem.Y, symbology, height, elem.HumanReadable, elem.Rotation, narrow, wide, content), nil
 }
 
-func (g *TSPL2Generator) generateQRCode(elem *LabelElement, variables map[string]string, schema *LabelSchema) string {
-	content := g.substituteVariables(elem.Content, variables, schema)
-	content = escapeTSPLString(content)
-	level := elem.Level
-	if level == "" {
-		level = "M"
+func (g *TSPL2Generator) generateQRCode(elem *LabelElement, variables map[string]string, schema *LabelSchema) (string, error) {
+	content, err := g.substituteVariables(elem.Content, variables, schema)
+	if err != nil {
+		return "", err
+	}
+	level := qrLevel(elem)
+	capacity, ok := qrCapacity[level]
+	if !ok {
+		return "", fmt.Errorf("invalid QR code error correction level %q (valid: L, M, Q, H)", elem.Level)
+	}
+	if limit := capacity[classifySymbolContent(content)]; len(content) > limit {
+		return "", fmt.Errorf("QR code content is %d characters, exceeding the %d allowed at level %s", len(content), limit, level)
 	}
 	cellWidth := elem.CellWidth
 	if cellWidth == 0 {
 		cellWidth = 4
 	}
-	return fmt.Sprintf(`QRCODE %d,%d,%s,%d,%d,A,"%s"`, elem.X, elem.Y, level, cellWidth, elem.Rotation, content)
+	content = escapeTSPLString(content)
+	if elem.Mask != nil {
+		if *elem.Mask < 0 || *elem.Mask > 8 {
+			return "", fmt.Errorf("invalid QR code mask %d (valid: 0-7, or 8 for auto)", *elem.Mask)
+		}
+		return fmt.Sprintf(`QRCODE %d,%d,%s,%d,%d,A,M2,S%d,"%s"`, elem.X, elem.Y, level, cellWidth, elem.Rotation, *elem.Mask, content), nil
+	}
+	return fmt.Sprintf(`QRCODE %d,%d,%s,%d,%d,A,"%s"`, elem.X, elem.Y, level, cellWidth, elem.Rotation, content), nil
 }
 
-func (g *TSPL2Generator) generatePDF417(elem *LabelElement, variables map[string]string, schema *LabelSchema) string {
-	content := g.substituteVariables(elem.Content, variables, schema)
+func (g *TSPL2Generator) generatePDF417(elem *LabelElement, variables map[string]string, schema *LabelSchema) (string, error) {
+	content, err := g.substituteVariables(elem.Content, variables, schema)
+	if err != nil {
+		return "", err
+	}
 	content = escapeTSPLString(content)
 	columns := elem.Columns
 	if columns == 0 {
@@ -248,11 +605,17 @@ func (g *TSPL2Generator) generatePDF417(elem *LabelElement, variables map[string
 		moduleSize = 2
 	}
 	return fmt.Sprintf(`PDF417 %d,%d,%d,%d,%d,%d,%d,"%s"`,
-		elem.X, elem.Y, columns, rows, security, moduleSize, elem.Rotation, content)
+		elem.X, elem.Y, columns, rows, security, moduleSize, elem.Rotation, content), nil
 }
 
-func (g *TSPL2Generator) generateDataMatrix(elem *LabelElement, variables map[string]string, schema *LabelSchema) string {
-	content := g.substituteVariables(elem.Content, variables, schema)
+func (g *TSPL2Generator) generateDataMatrix(elem *LabelElement, variables map[string]string, schema *LabelSchema) (string, error) {
+	content, err := g.substituteVariables(elem.Content, variables, schema)
+	if err != nil {
+		return "", err
+	}
+	if limit := dataMatrixCapacity[classifySymbolContent(content)]; len(content) > limit {
+		return "", fmt.Errorf("DataMatrix content is %d characters, exceeding the %d allowed", len(content), limit)
+	}
 	content = escapeTSPLString(content)
 	moduleSize := elem.ModuleSize
 	if moduleSize == 0 {
@@ -262,7 +625,51 @@ func (g *TSPL2Generator) generateDataMatrix(elem *LabelElement, variables map[st
 	if encoding == "" {
 		encoding = "A"
 	}
-	return fmt.Sprintf(`DMATRIX %d,%d,%d,%d,%s,"%s"`, elem.X, elem.Y, moduleSize, elem.Rotation, encoding, content)
+	return fmt.Sprintf(`DMATRIX %d,%d,%d,%d,%s,"%s"`, elem.X, elem.Y, moduleSize, elem.Rotation, encoding, content), nil
+}
+
+type symbolContentMode int
+
+const (
+	symbolModeNumeric symbolContentMode = iota
+	symbolModeAlphanumeric
+	symbolModeByte
+)
+
+// qrCapacity is the data capacity of the largest QR code (version 40) for
+// each error correction level. The printer picks the version automatically,
+// so anything beyond these limits cannot be encoded at all.
+var qrCapacity = map[string]map[symbolContentMode]int{
+	"L": {symbolModeNumeric: 7089, symbolModeAlphanumeric: 4296, symbolModeByte: 2953},
+	"M": {symbolModeNumeric: 5596, symbolModeAlphanumeric: 3391, symbolModeByte: 2331},
+	"Q": {symbolModeNumeric: 3993, symbolModeAlphanumeric: 2420, symbolModeByte: 1663},
+	"H": {symbolModeNumeric: 3057, symbolModeAlphanumeric: 1852, symbolModeByte: 1273},
+}
+
+// dataMatrixCapacity is the data capacity of the largest ECC 200 symbol
+// (144x144).
+var dataMatrixCapacity = map[symbolContentMode]int{
+	symbolModeNumeric:      3116,
+	symbolModeAlphanumeric: 2335,
+	symbolModeByte:         1556,
+}
+
+// classifySymbolContent returns the densest encoding mode that can represent
+// content. Byte mode capacities are counted in bytes, so len(content) is the
+// right measure for every mode.
+func classifySymbolContent(content string) symbolContentMode {
+	mode := symbolModeNumeric
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c >= '0' && c <= '9':
+		case (c >= 'A' && c <= 'Z') || strings.IndexByte(" $%*+-./:", c) >= 0:
+			mode = symbolModeAlphanumeric
+		default:
+			return symbolModeByte
+		}
+	}
+	return mode
 }
 
 func (g *TSPL2Generator) generateBox(elem *LabelElement) string {
@@ -297,9 +704,11 @@ func (g *TSPL2Generator) generateEllipse(elem *LabelElement) string {
 	return fmt.Sprintf("ELLIPSE %d,%d,%d,%d,%d", elem.X, elem.Y, elem.XRadius, elem.YRadius, thickness)
 }
 
-func (g *TSPL2Generator) generateBlock(elem *LabelElement, variables map[string]string, schema *LabelSchema) string {
-	content := g.substituteVariables(elem.Content, variables, schema)
-	content = escapeTSPLString(content)
+func (g *TSPL2Generator) generateBlock(elem *LabelElement, variables map[string]string, schema *LabelSchema) (string, error) {
+	content, err := g.substituteVariables(elem.Content, variables, schema)
+	if err != nil {
+		return "", err
+	}
 	font := elem.Font
 	if font == "" {
 		font = "3"
@@ -312,33 +721,121 @@ func (g *TSPL2Generator) generateBlock(elem *LabelElement, variables map[string]
 	if yScale == 0 {
 		yScale = 1
 	}
+	content, xScale, yScale, err = applyBlockOverflow(elem, content, font, xScale, yScale, schema.DPI)
+	if err != nil {
+		return "", err
+	}
+	content = escapeTSPLString(content)
 	return fmt.Sprintf(`BLOCK %d,%d,%d,%d,"%s",%d,%d,%d,"%s"`,
-		elem.X, elem.Y, elem.Width, elem.Height, font, elem.Rotation, xScale, yScale, content)
+		elem.X, elem.Y, elem.Width, elem.Height, font, elem.Rotation, xScale, yScale, content), nil
 }
 
-func (g *TSPL2Generator) generateImage(elem *LabelElement) string {
-	return fmt.Sprintf(`PUTBMP %d,%d,"%s"`, elem.X, elem.Y, elem.ImagePath)
+func (g *TSPL2Generator) generateImage(elem *LabelElement) (string, error) {
+	if elem.ImageData != "" || elem.Embed {
+		return g.generateBitmap(elem)
+	}
+	return fmt.Sprintf(`PUTBMP %d,%d,"%s"`, elem.X, elem.Y, elem.ImagePath), nil
 }
 
+// GeneratePreview generates the label with every variable filled from
+// SampleVariables.
 func (g *TSPL2Generator) GeneratePreview(schema *LabelSchema) (string, error) {
-	previewVars := make(map[string]string)
-	for name, def := range schema.Variables {
-		if def.Default != "" {
-			previewVars[name] = def.Default
-		} else {
-			switch def.Type {
-			case "string":
-				previewVars[name] = "SAMPLE"
-			case "number":
-				previewVars[name] = "123"
-			case "barcode":
-				previewVars[name] = "12345678"
-			default:
-				previewVars[name] = "SAMPLE"
-			}
+	return g.Generate(schema, g.ApplyServerVariables(schema, g.SampleVariables(schema)))
+}
+
+// codepageAliases maps friendly codepage names to the tokens accepted by the
+// TSPL CODEPAGE command.
+var codepageAliases = map[string]string{
+	"UTF-8":        "UTF-8",
+	"UTF8":         "UTF-8",
+	"LATIN-1":      "8859-1",
+	"LATIN1":       "8859-1",
+	"ISO-8859-1":   "8859-1",
+	"8859-1":       "8859-1",
+	"WINDOWS-1250": "1250",
+	"WINDOWS-1251": "1251",
+	"WINDOWS-1252": "1252",
+	"WINDOWS-1253": "1253",
+	"WINDOWS-1254": "1254",
+	"WINDOWS-1257": "1257",
+	"CP1252":       "1252",
+	"CP850":        "850",
+	"CP437":        "437",
+	"437":          "437",
+	"850":          "850",
+	"852":          "852",
+	"860":          "860",
+	"863":          "863",
+	"865":          "865",
+	"866":          "866",
+	"1250":         "1250",
+	"1251":         "1251",
+	"1252":         "1252",
+	"1253":         "1253",
+	"1254":         "1254",
+	"1257":         "1257",
+}
+
+// codepageCommand returns the CODEPAGE line for a schema's codepage, or an
+// empty string when none is set so the printer keeps its configured default.
+// Label content is sent as UTF-8, so "UTF-8" is the right choice for
+// non-ASCII text.
+func codepageCommand(codepage string) (string, error) {
+	if codepage == "" {
+		return "", nil
+	}
+	token, ok := codepageAliases[strings.ToUpper(strings.TrimSpace(codepage))]
+	if !ok {
+		return "", fmt.Errorf("unsupported codepage: %s", codepage)
+	}
+	return fmt.Sprintf("CODEPAGE %s\n", token), nil
+}
+
+// directionCommand returns the DIRECTION line for a schema. Direction 1
+// prints the label rotated 180 degrees relative to the feed, and mirror
+// flips it horizontally, for printers mounted the other way round.
+func directionCommand(direction int, mirror bool) (string, error) {
+	if direction != 0 && direction != 1 {
+		return "", fmt.Errorf("direction must be 0 or 1, got %d", direction)
+	}
+	m := 0
+	if mirror {
+		m = 1
+	}
+	return fmt.Sprintf("DIRECTION %d,%d\n", direction, m), nil
+}
+
+// Ranges accepted by the TSPL SPEED and DENSITY commands.
+const (
+	MinPrintSpeed   = 1
+	MaxPrintSpeed   = 12
+	MinPrintDensity = 0
+	MaxPrintDensity = 15
+)
+
+// qualityCommands returns the SPEED and DENSITY lines for a schema. A zero
+// speed or nil density sends nothing, so the printer keeps its setting.
+func qualityCommands(speed int, density *int) (string, error) {
+	var sb strings.Builder
+	if speed != 0 {
+		if speed < MinPrintSpeed || speed > MaxPrintSpeed {
+			return "", fmt.Errorf("speed must be between %d and %d, got %d", MinPrintSpeed, MaxPrintSpeed, speed)
+		}
+		sb.WriteString(fmt.Sprintf("SPEED %d\n", speed))
+	}
+	if density != nil {
+		if *density < MinPrintDensity || *density > MaxPrintDensity {
+			return "", fmt.Errorf("density must be between %d and %d, got %d", MinPrintDensity, MaxPrintDensity, *density)
 		}
+		sb.WriteString(fmt.Sprintf("DENSITY %d\n", *density))
 	}
-	return g.Generate(schema, previewVars)
+	return sb.String(), nil
+}
+
+// FormatMM formats a millimetre dimension for SIZE and GAP, keeping up to
+// two decimal places so fractional label sizes are not truncated.
+func FormatMM(mm float64) string {
+	return strconv.FormatFloat(math.Round(mm*100)/100, 'f', -1, 64)
 }
 
 func mmToDots(mm float64, dpi int) int {
@@ -364,20 +861,44 @@ func (g *TSPL2Generator) GenerateWithDotCoordinates(schema *LabelSchema, variabl
 		return "", err
 	}
 
-	var sb strings.Builder
+	codepage, err := codepageCommand(schema.Codepage)
+	if err != nil {
+		return "", err
+	}
+	direction, err := directionCommand(schema.Direction, schema.Mirror)
+	if err != nil {
+		return "", err
+	}
+	settings, trailer, err := postCommandLines(schema.PostCommands)
+	if err != nil {
+		return "", err
+	}
+	quality, err := qualityCommands(schema.Speed, schema.Density)
+	if err != nil {
+		return "", err
+	}
+
 	dpi := schema.DPI
 	if dpi == 0 {
 		dpi = 203
 	}
+	media, err := mediaCommands(schema, dpi)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
 
 	widthDots := mmToDots(schema.WidthMM, dpi)
 	heightDots := mmToDots(schema.HeightMM, dpi)
-	gapDots := mmToDots(schema.GapMM, dpi)
 
 	sb.WriteString(fmt.Sprintf("SIZE %d dot,%d dot\n", widthDots, heightDots))
-	sb.WriteString(fmt.Sprintf("GAP %d dot,0 dot\n", gapDots))
-	sb.WriteString("DIRECTION 0\n")
+	sb.WriteString(media)
+	sb.WriteString(quality)
+	sb.WriteString(direction)
+	sb.WriteString(settings)
 	sb.WriteString("CLS\n")
+	sb.WriteString(codepage)
 
 	for _, elem := range schema.Elements {
 		cmd, err := g.generateElement(&elem, variables, schema)
@@ -391,6 +912,7 @@ func (g *TSPL2Generator) GenerateWithDotCoordinates(schema *LabelSchema, variabl
 	}
 
 	sb.WriteString("PRINT 1\n")
+	sb.WriteString(trailer)
 	return sb.String(), nil
 }
 
@@ -399,11 +921,34 @@ func (g *TSPL2Generator) GenerateMultiLabel(schema *LabelSchema, labelDataList [
 		copies = 1
 	}
 
+	codepage, err := codepageCommand(schema.Codepage)
+	if err != nil {
+		return "", err
+	}
+	direction, err := directionCommand(schema.Direction, schema.Mirror)
+	if err != nil {
+		return "", err
+	}
+	settings, trailer, err := postCommandLines(schema.PostCommands)
+	if err != nil {
+		return "", err
+	}
+	quality, err := qualityCommands(schema.Speed, schema.Density)
+	if err != nil {
+		return "", err
+	}
+	media, err := mediaCommands(schema, 0)
+	if err != nil {
+		return "", err
+	}
+
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("SIZE %.0f mm, %.0f mm\n", schema.WidthMM, schema.HeightMM))
-	sb.WriteString(fmt.Sprintf("GAP %.0f mm, 0 mm\n", schema.GapMM))
-	sb.WriteString("DIRECTION 0\n")
+	sb.WriteString(fmt.Sprintf("SIZE %s mm, %s mm\n", FormatMM(schema.WidthMM), FormatMM(schema.HeightMM)))
+	sb.WriteString(media)
+	sb.WriteString(quality)
+	sb.WriteString(direction)
+	sb.WriteString(settings)
 
 	for _, variables := range labelDataList {
 		if err := g.ValidateVariables(schema, variables); err != nil {
@@ -411,6 +956,7 @@ func (g *TSPL2Generator) GenerateMultiLabel(schema *LabelSchema, labelDataList [
 		}
 
 		sb.WriteString("CLS\n")
+		sb.WriteString(codepage)
 		for _, elem := range schema.Elements {
 			cmd, err := g.generateElement(&elem, variables, schema)
 			if err != nil {
@@ -422,6 +968,7 @@ func (g *TSPL2Generator) GenerateMultiLabel(schema *LabelSchema, labelDataList [
 			}
 		}
 		sb.WriteString(fmt.Sprintf("PRINT %d\n", copies))
+		sb.WriteString(trailer)
 	}
 
 	return sb.String(), nil
@@ -0,0 +1,584 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generateOne generates a label holding just elem.
+func generateOne(t *testing.T, elem LabelElement, variables map[string]string) (string, error) {
+	t.Helper()
+
+	schema := &LabelSchema{WidthMM: 50, HeightMM: 30, DPI: 203, Elements: []LabelElement{elem}}
+	return NewTSPL2Generator().Generate(schema, variables)
+}
+
+func TestQRCodeRejectsContentOverCapacity(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		content string
+		wantErr string
+	}{
+		{"numeric fits at L", "L", strings.Repeat("7", 7089), ""},
+		{"numeric over L", "L", strings.Repeat("7", 7090), "7090 characters, exceeding the 7089 allowed at level L"},
+		{"alphanumeric over H", "H", strings.Repeat("A", 1853), "exceeding the 1852 allowed at level H"},
+		{"byte over default M", "", strings.Repeat("a", 2332), "exceeding the 2331 allowed at level M"},
+		{"unknown level", "X", "hello", `invalid QR code error correction level "X"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generateOne(t, LabelElement{Type: "qrcode", Level: tt.level, Content: "{{data}}"}, map[string]string{"data": tt.content})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("generate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("generate returned %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQRCodeMask(t *testing.T) {
+	mask := 3
+	tspl, err := generateOne(t, LabelElement{Type: "qrcode", X: 10, Y: 20, Level: "q", Mask: &mask, Content: "hello"}, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if want := `QRCODE 10,20,Q,4,0,A,M2,S3,"hello"`; !strings.Contains(tspl, want) {
+		t.Errorf("TSPL is %q, want it to contain %q", tspl, want)
+	}
+
+	mask = 9
+	if _, err := generateOne(t, LabelElement{Type: "qrcode", Mask: &mask, Content: "hello"}, nil); err == nil {
+		t.Error("generate with mask 9 succeeded, want an error")
+	}
+}
+
+func TestBarcodeArgumentOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		elem LabelElement
+		want string
+	}{
+		{
+			name: "defaults",
+			elem: LabelElement{Type: "barcode", X: 10, Y: 20, Content: "A-1"},
+			want: `BARCODE 10,20,"128",80,0,0,2,2,"A-1"`,
+		},
+		{
+			name: "every argument set",
+			elem: LabelElement{Type: "barcode", X: 10, Y: 20, Symbology: "39", Height: 60, HumanReadable: 2, Rotation: 90, Narrow: 3, Wide: 6, Content: "A-1"},
+			want: `BARCODE 10,20,"39",60,2,90,3,6,"A-1"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tspl, err := generateOne(t, tt.elem, nil)
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			if !strings.Contains(tspl, tt.want) {
+				t.Errorf("TSPL is %q, want it to contain %q", tspl, tt.want)
+			}
+		})
+	}
+
+	if _, err := generateOne(t, LabelElement{Type: "barcode", HumanReadable: 4, Content: "A-1"}, nil); err == nil {
+		t.Error("generate with human_readable 4 succeeded, want an error")
+	}
+}
+
+func TestDataMatrixRejectsContentOverCapacity(t *testing.T) {
+	if _, err := generateOne(t, LabelElement{Type: "datamatrix", Content: strings.Repeat("1", 3116)}, nil); err != nil {
+		t.Fatalf("generate at capacity: %v", err)
+	}
+	_, err := generateOne(t, LabelElement{Type: "datamatrix", Content: strings.Repeat("b", 1557)}, nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeding the 1556 allowed") {
+		t.Errorf("generate over capacity returned %v, want a capacity error", err)
+	}
+}
+
+func TestCodepageCommand(t *testing.T) {
+	tests := []struct {
+		codepage string
+		want     string
+	}{
+		{"UTF-8", "CODEPAGE UTF-8"},
+		{"utf8", "CODEPAGE UTF-8"},
+		{"1252", "CODEPAGE 1252"},
+		{"Windows-1252", "CODEPAGE 1252"},
+		{"850", "CODEPAGE 850"},
+		{"cp850", "CODEPAGE 850"},
+		{"Latin-1", "CODEPAGE 8859-1"},
+		{" 437 ", "CODEPAGE 437"},
+	}
+	g := NewTSPL2Generator()
+	for _, tt := range tests {
+		schema := &LabelSchema{WidthMM: 50, HeightMM: 30, DPI: 203, Codepage: tt.codepage,
+			Elements: []LabelElement{{Type: "text", Content: "Größe"}}}
+		outputs := map[string]func() (string, error){
+			"Generate": func() (string, error) { return g.Generate(schema, nil) },
+			"GenerateWithDotCoordinates": func() (string, error) {
+				return g.GenerateWithDotCoordinates(schema, nil, true)
+			},
+			"GenerateMultiLabel": func() (string, error) {
+				return g.GenerateMultiLabel(schema, []map[string]string{{}}, 1)
+			},
+		}
+		for name, generate := range outputs {
+			tspl, err := generate()
+			if err != nil {
+				t.Fatalf("%s with codepage %q: %v", name, tt.codepage, err)
+			}
+			if !strings.Contains(tspl, "CLS\n"+tt.want+"\n") {
+				t.Errorf("%s with codepage %q gave %q, want %q right after CLS", name, tt.codepage, tspl, tt.want)
+			}
+		}
+	}
+}
+
+func TestCodepageUnsetOrUnknown(t *testing.T) {
+	g := NewTSPL2Generator()
+	schema := &LabelSchema{WidthMM: 50, HeightMM: 30, Elements: []LabelElement{{Type: "text", Content: "x"}}}
+	tspl, err := g.Generate(schema, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if strings.Contains(tspl, "CODEPAGE") {
+		t.Errorf("TSPL without a codepage is %q, want no CODEPAGE line", tspl)
+	}
+
+	schema.Codepage = "EBCDIC"
+	if _, err := g.Generate(schema, nil); err == nil {
+		t.Error("generate with an unknown codepage succeeded, want an error")
+	}
+}
+
+func TestDirectionCommand(t *testing.T) {
+	tests := []struct {
+		direction int
+		mirror    bool
+		want      string
+	}{
+		{0, false, "DIRECTION 0,0"},
+		{0, true, "DIRECTION 0,1"},
+		{1, false, "DIRECTION 1,0"},
+		{1, true, "DIRECTION 1,1"},
+	}
+	g := NewTSPL2Generator()
+	for _, tt := range tests {
+		schema := &LabelSchema{WidthMM: 50, HeightMM: 30, DPI: 203, Direction: tt.direction, Mirror: tt.mirror,
+			Elements: []LabelElement{{Type: "text", Content: "x"}}}
+		outputs := map[string]func() (string, error){
+			"Generate": func() (string, error) { return g.Generate(schema, nil) },
+			"GenerateWithDotCoordinates": func() (string, error) {
+				return g.GenerateWithDotCoordinates(schema, nil, true)
+			},
+			"GenerateMultiLabel": func() (string, error) {
+				return g.GenerateMultiLabel(schema, []map[string]string{{}}, 1)
+			},
+		}
+		for name, generate := range outputs {
+			tspl, err := generate()
+			if err != nil {
+				t.Fatalf("%s with direction %d, mirror %v: %v", name, tt.direction, tt.mirror, err)
+			}
+			if strings.Count(tspl, "DIRECTION") != 1 || !strings.Contains(tspl, "\n"+tt.want+"\n") {
+				t.Errorf("%s with direction %d, mirror %v gave %q, want a single %q line", name, tt.direction, tt.mirror, tspl, tt.want)
+			}
+		}
+	}
+}
+
+func TestSpeedAndDensityCommands(t *testing.T) {
+	density := 8
+	zero := 0
+	tests := []struct {
+		name    string
+		speed   int
+		density *int
+		want    string
+	}{
+		{"unset", 0, nil, "GAP 2 mm, 0 mm\nDIRECTION 0,0\n"},
+		{"speed only", 4, nil, "GAP 2 mm, 0 mm\nSPEED 4\nDIRECTION 0,0\n"},
+		{"both", 6, &density, "GAP 2 mm, 0 mm\nSPEED 6\nDENSITY 8\nDIRECTION 0,0\n"},
+		{"lightest density", 0, &zero, "GAP 2 mm, 0 mm\nDENSITY 0\nDIRECTION 0,0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &LabelSchema{WidthMM: 50, HeightMM: 30, GapMM: 2, Speed: tt.speed, Density: tt.density}
+			tspl, err := NewTSPL2Generator().Generate(schema, nil)
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			if !strings.Contains(tspl, tt.want) {
+				t.Errorf("TSPL is %q, want it to contain %q", tspl, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpeedAndDensityOutOfRange(t *testing.T) {
+	tooDark := 16
+	negative := -1
+	tests := []struct {
+		speed   int
+		density *int
+		wantErr string
+	}{
+		{13, nil, "speed must be between 1 and 12, got 13"},
+		{-2, nil, "speed must be between 1 and 12, got -2"},
+		{0, &tooDark, "density must be between 0 and 15, got 16"},
+		{0, &negative, "density must be between 0 and 15, got -1"},
+	}
+	for _, tt := range tests {
+		schema := &LabelSchema{WidthMM: 50, HeightMM: 30, Speed: tt.speed, Density: tt.density}
+		if _, err := NewTSPL2Generator().Generate(schema, nil); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("generate returned %v, want %q", err, tt.wantErr)
+		}
+	}
+}
+
+func TestDirectionOutOfRange(t *testing.T) {
+	schema := &LabelSchema{WidthMM: 50, HeightMM: 30, Direction: 2, Elements: []LabelElement{{Type: "text", Content: "x"}}}
+	if _, err := NewTSPL2Generator().Generate(schema, nil); err == nil {
+		t.Error("generate with direction 2 succeeded, want an error")
+	}
+}
+
+func TestVariableDirectives(t *testing.T) {
+	tests := []struct {
+		content string
+		value   string
+		want    string
+	}{
+		{"{{v|upper}}", "abc-1", "ABC-1"},
+		{"{{v|lower}}", "ABC", "abc"},
+		{"{{v|trim}}", "  x  ", "x"},
+		{"{{v|truncate:3}}", "Größe", "Grö"},
+		{"{{v|truncate:10}}", "short", "short"},
+		{"{{v|pad:6}}", "42", "000042"},
+		{"{{v|pad:4: }}", "7", "   7"},
+		{"{{v|pad:2}}", "12345", "12345"},
+		{"{{v|currency}}", "12.5", "12.50"},
+		{"{{v|currency:$}}", "-3", "-$3.00"},
+		{"{{v|currency}}", "", ""},
+		{"{{v|date:02/01/2006}}", "2024-03-09", "09/03/2024"},
+		{"{{v|date:2006-01-02}}", "2024-03-09T14:30:00Z", "2024-03-09"},
+		{"{{v|date:15:04}}", "2024-03-09 14:30", "14:30"},
+		{"{{v|upper|truncate:10}}", "a very long name", "A VERY LON"},
+		{"{{v|trim|pad:5|currency:€}}", " 42 ", "€42.00"},
+		{"SKU {{v|upper}} / {{v}}", "ab", "SKU AB / ab"},
+	}
+	g := NewTSPL2Generator()
+	schema := &LabelSchema{}
+	for _, tt := range tests {
+		got, err := g.substituteVariables(tt.content, map[string]string{"v": tt.value}, schema)
+		if err != nil {
+			t.Errorf("%s with %q: %v", tt.content, tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s with %q gave %q, want %q", tt.content, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestVariableDirectiveErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		value   string
+	}{
+		{"{{v|shout}}", "x"},
+		{"{{v|truncate:many}}", "x"},
+		{"{{v|pad:-1}}", "x"},
+		{"{{v|pad:4:ab}}", "x"},
+		{"{{v|currency}}", "twelve"},
+		{"{{v|date}}", "2024-03-09"},
+		{"{{v|date:2006}}", "yesterday"},
+	}
+	g := NewTSPL2Generator()
+	for _, tt := range tests {
+		schema := &LabelSchema{WidthMM: 50, HeightMM: 30, Elements: []LabelElement{{Type: "text", Content: tt.content}}}
+		if tspl, err := g.Generate(schema, map[string]string{"v": tt.value}); err == nil {
+			t.Errorf("%s with %q generated %q, want an error", tt.content, tt.value, tspl)
+		}
+	}
+}
+
+func TestShowIfCondition(t *testing.T) {
+	schema := &LabelSchema{
+		WidthMM: 50, HeightMM: 30, DPI: 203,
+		Elements: []LabelElement{
+			{Type: "text", X: 10, Y: 10, Font: "3", Content: "ORDER"},
+			{Type: "text", X: 10, Y: 40, Font: "3", Content: "FRAGILE", ShowIf: "fragile"},
+			{Type: "text", X: 10, Y: 70, Font: "3", Content: "STANDARD", ShowIf: "!fragile"},
+		},
+		Variables: map[string]VariableDef{"fragile": {Type: "string"}},
+	}
+	tests := []struct {
+		variables map[string]string
+		fragile   bool
+	}{
+		{nil, false},
+		{map[string]string{"fragile": ""}, false},
+		{map[string]string{"fragile": "false"}, false},
+		{map[string]string{"fragile": "No"}, false},
+		{map[string]string{"fragile": "0"}, false},
+		{map[string]string{"fragile": "yes"}, true},
+		{map[string]string{"fragile": "1"}, true},
+	}
+	for _, tt := range tests {
+		tspl, err := NewTSPL2Generator().Generate(schema, tt.variables)
+		if err != nil {
+			t.Fatalf("generate with %v: %v", tt.variables, err)
+		}
+		if !strings.Contains(tspl, `"ORDER"`) {
+			t.Errorf("with %v the unconditional element is missing:\n%s", tt.variables, tspl)
+		}
+		if got := strings.Contains(tspl, `"FRAGILE"`); got != tt.fragile {
+			t.Errorf("with %v fragile stamp shown = %v, want %v", tt.variables, got, tt.fragile)
+		}
+		if got := strings.Contains(tspl, `"STANDARD"`); got == tt.fragile {
+			t.Errorf("with %v negated element shown = %v, want %v", tt.variables, got, !tt.fragile)
+		}
+	}
+}
+
+func TestShowIfFallsBackToDefault(t *testing.T) {
+	schema := &LabelSchema{
+		WidthMM: 50, HeightMM: 30, DPI: 203,
+		Elements:  []LabelElement{{Type: "text", X: 10, Y: 10, Font: "3", Content: "FRAGILE", ShowIf: "fragile"}},
+		Variables: map[string]VariableDef{"fragile": {Type: "string", Default: "true"}},
+	}
+	tspl, err := NewTSPL2Generator().Generate(schema, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if !strings.Contains(tspl, `"FRAGILE"`) {
+		t.Errorf("element hidden although its variable defaults to true:\n%s", tspl)
+	}
+}
+
+func TestScanContentMatchesPrintedBarcode(t *testing.T) {
+	schema := &LabelSchema{
+		WidthMM: 50, HeightMM: 30, DPI: 203,
+		Elements: []LabelElement{
+			{Type: "qrcode", X: 10, Y: 10, Content: "LOT-{{lot}}", ShowIf: "with_lot"},
+			{Type: "barcode", X: 10, Y: 100, Symbology: "EAN13", Content: "{{ean}}"},
+		},
+		Variables: map[string]VariableDef{
+			"with_lot": {Type: "string"},
+			"lot":      {Type: "string"},
+			"ean":      {Type: "string"},
+		},
+	}
+	tests := []struct {
+		variables map[string]string
+		want      string
+	}{
+		// The QR code is hidden, so the scanner reads the barcode, with
+		// the check digit generation appends.
+		{map[string]string{"with_lot": "no", "lot": "7", "ean": "400638133393"}, "4006381333931"},
+		{map[string]string{"with_lot": "yes", "lot": "7", "ean": "400638133393"}, "LOT-7"},
+	}
+	g := NewTSPL2Generator()
+	for _, tt := range tests {
+		got, ok, err := g.ScanContent(schema, tt.variables)
+		if err != nil || !ok {
+			t.Fatalf("scan content with %v: %q, %v, %v", tt.variables, got, ok, err)
+		}
+		if got != tt.want {
+			t.Errorf("scan content with %v is %q, want %q", tt.variables, got, tt.want)
+		}
+		tspl, err := g.Generate(schema, tt.variables)
+		if err != nil {
+			t.Fatalf("generate with %v: %v", tt.variables, err)
+		}
+		if !strings.Contains(tspl, `"`+tt.want+`"`) {
+			t.Errorf("with %v the label does not encode %q:\n%s", tt.variables, tt.want, tspl)
+		}
+	}
+
+	// With only hidden scannable elements there is nothing to scan.
+	hidden := &LabelSchema{
+		WidthMM: 50, HeightMM: 30, DPI: 203,
+		Elements: []LabelElement{{Type: "qrcode", X: 10, Y: 10, Content: "A", ShowIf: "with_lot"}},
+	}
+	if got, ok, err := g.ScanContent(hidden, nil); ok || err != nil {
+		t.Errorf("scan content of a label whose only code is hidden is %q, %v, %v; want none", got, ok, err)
+	}
+}
+
+func TestFractionalLabelSize(t *testing.T) {
+	schema := &LabelSchema{
+		WidthMM: 30.5, HeightMM: 15.2, GapMM: 2.25, DPI: 203,
+		Elements: []LabelElement{{Type: "text", X: 10, Y: 10, Font: "3", Content: "A"}},
+	}
+	g := NewTSPL2Generator()
+
+	single, err := g.Generate(schema, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	multi, err := g.GenerateMultiLabel(schema, []map[string]string{{}}, 1)
+	if err != nil {
+		t.Fatalf("generate multiple labels: %v", err)
+	}
+	for name, tspl := range map[string]string{"Generate": single, "GenerateMultiLabel": multi} {
+		if !strings.Contains(tspl, "SIZE 30.5 mm, 15.2 mm\n") || !strings.Contains(tspl, "GAP 2.25 mm, 0 mm\n") {
+			t.Errorf("%s truncated the label size:\n%s", name, tspl)
+		}
+	}
+}
+
+func TestFormatMM(t *testing.T) {
+	for mm, want := range map[float64]string{50: "50", 30.5: "30.5", 15.2: "15.2", 2.25: "2.25", 101.6001: "101.6", 0: "0"} {
+		if got := FormatMM(mm); got != want {
+			t.Errorf("FormatMM(%v) = %q, want %q", mm, got, want)
+		}
+	}
+}
+
+func TestApplyServerVariables(t *testing.T) {
+	schema := &LabelSchema{Variables: map[string]VariableDef{
+		"name":    {Type: "string"},
+		"price":   {Type: "string", Default: "9.99", Locked: true, Source: VariableSourceDefault},
+		"printed": {Type: "string", Locked: true, Source: VariableSourceDate},
+	}}
+	g := NewTSPL2Generator()
+
+	if err := g.CheckLockedVariables(schema, map[string]string{"name": "x", "price": "1"}); err == nil {
+		t.Error("client value for a locked variable was accepted, want an error")
+	}
+	if err := g.CheckLockedVariables(schema, map[string]string{"name": "x"}); err != nil {
+		t.Errorf("open variables were rejected: %v", err)
+	}
+
+	got := g.ApplyServerVariables(schema, map[string]string{"name": "x"})
+	if got["name"] != "x" || got["price"] != "9.99" {
+		t.Errorf("variables are %v, want name x and price 9.99", got)
+	}
+	if _, err := time.Parse("2006-01-02", got["printed"]); err != nil {
+		t.Errorf("date source gave %q, want a date: %v", got["printed"], err)
+	}
+}
+
+func TestBlockOverflow(t *testing.T) {
+	// Font 3 cells are 16x24 dots, so the box holds one line of 10
+	// characters at 1x.
+	long := map[string]string{"text": "ABCDEFGHIJKLMNOP"}
+	block := func(overflow string, scale int) LabelElement {
+		return LabelElement{Type: "block", X: 5, Y: 5, Width: 160 * scale, Height: 24 * scale, Font: "3",
+			XScale: scale, YScale: scale, Overflow: overflow, Content: "{{text}}"}
+	}
+
+	tests := []struct {
+		name    string
+		elem    LabelElement
+		want    string
+		wantErr string
+	}{
+		{"clip by default", block("", 1), `BLOCK 5,5,160,24,"3",0,1,1,"ABCDEFGHIJKLMNOP"`, ""},
+		{"ellipsis", block(OverflowEllipsis, 1), `BLOCK 5,5,160,24,"3",0,1,1,"ABCDEFG..."`, ""},
+		{"shrink", block(OverflowShrink, 2), `BLOCK 5,5,320,48,"3",0,1,1,"ABCDEFGHIJKLMNOP"`, ""},
+		{"error", block(OverflowError, 1), "", "does not fit in 160x24 dots"},
+		{"unknown policy", block("wrap", 1), "", `invalid block overflow "wrap"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tspl, err := generateOne(t, tt.elem, long)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("generate returned %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			if !strings.Contains(tspl, tt.want) {
+				t.Errorf("TSPL is %q, want it to contain %q", tspl, tt.want)
+			}
+		})
+	}
+
+	// Content that fits is left alone whatever the policy.
+	tspl, err := generateOne(t, block(OverflowEllipsis, 1), map[string]string{"text": "SHORT"})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if want := `"SHORT"`; !strings.Contains(tspl, want) {
+		t.Errorf("TSPL is %q, want it to contain %q", tspl, want)
+	}
+}
+
+// testPNG encodes a width x height PNG whose left half is black, as base64.
+func testPNG(t *testing.T, width, height int) string {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x >= width/2 {
+				img.SetGray(x, y, color.Gray{Y: 0xFF})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode PNG: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestImageElementEmbedsBitmap(t *testing.T) {
+	// 10 dots wide needs 2 bytes per row.
+	tspl, err := generateOne(t, LabelElement{Type: "image", X: 5, Y: 6, ImageData: testPNG(t, 10, 3)}, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	header := "BITMAP 5,6,2,3,0,"
+	i := strings.Index(tspl, header)
+	if i < 0 {
+		t.Fatalf("TSPL is %q, want a BITMAP with header %q", tspl, header)
+	}
+	data := tspl[i+len(header) : i+len(header)+6]
+	// A 0 bit prints, so the black left five dots of each row are cleared.
+	if want := strings.Repeat("\x07\xff", 3); data != want {
+		t.Errorf("bitmap data is %x, want %x", data, want)
+	}
+	if !strings.HasPrefix(tspl[i+len(header)+6:], "\n") {
+		t.Errorf("bitmap data is not followed by a newline in %q", tspl)
+	}
+
+	if _, err := generateOne(t, LabelElement{Type: "image", ImageData: "not base64!"}, nil); err == nil {
+		t.Error("generate with invalid image data succeeded, want an error")
+	}
+	if _, err := generateOne(t, LabelElement{Type: "image", ImageData: testPNG(t, 8, 1), Threshold: 300}, nil); err == nil {
+		t.Error("generate with threshold 300 succeeded, want an error")
+	}
+}
+
+func TestEncodeForPrinterKeepsBitmapData(t *testing.T) {
+	// The bitmap bytes include a line feed that must not become CRLF.
+	tspl := "CLS\nBITMAP 0,0,1,2,0,\n\xff\nPRINT 1\n"
+	got, err := EncodeForPrinter(&Printer{LineEnding: LineEndingCRLF, Encoding: "1252"}, tspl)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if want := "CLS\r\nBITMAP 0,0,1,2,0,\n\xff\r\nPRINT 1\r\n"; string(got) != want {
+		t.Errorf("encoded %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,302 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// tsplCommand is one command of a TSPL program with its comma-separated
+// arguments, strings unquoted. BITMAP and DOWNLOAD carry binary data after
+// their arguments.
+type tsplCommand struct {
+	name string
+	args []string
+	data string
+}
+
+// tsplDataArgs is the number of arguments before the binary data of the
+// commands that carry it.
+var tsplDataArgs = map[string]int{
+	"BITMAP":   5,
+	"DOWNLOAD": 2,
+}
+
+// parseTSPL splits a TSPL program into commands. Strings may hold commas
+// and the escapes escapeTSPLString writes. Binary data is read by the
+// length its arguments give rather than up to the end of the line.
+func parseTSPL(tspl string) ([]tsplCommand, error) {
+	var commands []tsplCommand
+	i := 0
+	for i < len(tspl) {
+		if strings.IndexByte(" \t\r\n", tspl[i]) >= 0 {
+			i++
+			continue
+		}
+		start := i
+		for i < len(tspl) && strings.IndexByte(" \t\r\n", tspl[i]) < 0 {
+			i++
+		}
+		cmd := tsplCommand{name: strings.ToUpper(tspl[start:i])}
+
+		var arg strings.Builder
+		quoted, pending := false, false
+	line:
+		for ; i < len(tspl); i++ {
+			c := tspl[i]
+			switch {
+			case quoted && c == '\\' && i+1 < len(tspl):
+				i++
+				switch tspl[i] {
+				case 'n':
+					arg.WriteByte('\n')
+				case 'r':
+					arg.WriteByte('\r')
+				case 't':
+					arg.WriteByte('\t')
+				default:
+					arg.WriteByte(tspl[i])
+				}
+			case c == '"':
+				quoted = !quoted
+				pending = true
+			case quoted:
+				arg.WriteByte(c)
+			case c == '\r' || c == '\n':
+				break line
+			case c == ',':
+				cmd.args = append(cmd.args, strings.TrimSpace(arg.String()))
+				arg.Reset()
+				pending = true
+				if n, ok := tsplDataArgs[cmd.name]; ok && len(cmd.args) == n {
+					size, err := tsplDataSize(cmd)
+					if err != nil {
+						return nil, err
+					}
+					if i+1+size > len(tspl) {
+						return nil, fmt.Errorf("%s data is truncated", cmd.name)
+					}
+					cmd.data = tspl[i+1 : i+1+size]
+					i += 1 + size
+					pending = false
+					break line
+				}
+			default:
+				arg.WriteByte(c)
+				pending = pending || c != ' ' && c != '\t'
+			}
+		}
+		if pending {
+			cmd.args = append(cmd.args, strings.TrimSpace(arg.String()))
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
+
+// tsplDataSize returns the length of the binary data that follows cmd's
+// arguments: width in bytes times height for BITMAP, the file size for
+// DOWNLOAD.
+func tsplDataSize(cmd tsplCommand) (int, error) {
+	if cmd.name == "BITMAP" {
+		width, err1 := strconv.Atoi(cmd.args[2])
+		height, err2 := strconv.Atoi(cmd.args[3])
+		if err1 != nil || err2 != nil || width < 0 || height < 0 {
+			return 0, fmt.Errorf("invalid BITMAP size %q x %q", cmd.args[2], cmd.args[3])
+		}
+		return width * height, nil
+	}
+	size, err := strconv.Atoi(cmd.args[1])
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid %s size %q", cmd.name, cmd.args[1])
+	}
+	return size, nil
+}
+
+// tsplLength converts a SIZE argument such as "50 mm", "400 dot" or "2"
+// (inches) to dots.
+func tsplLength(arg string, dpi int) (int, error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, fmt.Errorf("invalid SIZE argument %q", arg)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid SIZE argument %q", arg)
+	}
+	unit := ""
+	if len(fields) == 2 {
+		unit = strings.ToLower(fields[1])
+	}
+	switch unit {
+	case "mm":
+		return mmToDots(value, dpi), nil
+	case "dot":
+		return int(value), nil
+	case "":
+		return int(value * float64(dpi)), nil
+	}
+	return 0, fmt.Errorf("invalid SIZE unit %q", unit)
+}
+
+// tsplElement turns a drawing command into the label element it was
+// generated from. Arguments follow the order the generator writes them in.
+// It reports false for commands that draw nothing.
+func tsplElement(cmd tsplCommand) (*LabelElement, bool) {
+	args := cmd.args
+	num := func(i int) int {
+		if i >= len(args) {
+			return 0
+		}
+		f, _ := strconv.ParseFloat(args[i], 64)
+		return int(f)
+	}
+	last := ""
+	if len(args) > 0 {
+		last = args[len(args)-1]
+	}
+
+	elem := &LabelElement{X: num(0), Y: num(1)}
+	switch cmd.name {
+	case "TEXT":
+		elem.Type = "text"
+		elem.Font, elem.Rotation, elem.XScale, elem.YScale = tsplArg(args, 2), num(3), num(4), num(5)
+		elem.Content = last
+	case "BLOCK":
+		elem.Type = "block"
+		elem.Width, elem.Height = num(2), num(3)
+		elem.Font, elem.Rotation, elem.XScale, elem.YScale = tsplArg(args, 4), num(5), num(6), num(7)
+		elem.Content = last
+	case "BARCODE":
+		elem.Type = "barcode"
+		elem.Height, elem.HumanReadable, elem.Rotation = num(3), num(4), num(5)
+		elem.Narrow, elem.Wide = num(6), num(7)
+		elem.Content = last
+	case "QRCODE":
+		elem.Type = "qrcode"
+		elem.Level, elem.CellWidth = tsplArg(args, 2), num(3)
+		// Rotation comes before the mode here, after it in the TSPL manual.
+		elem.Rotation = num(4)
+		if _, err := strconv.Atoi(tsplArg(args, 4)); err != nil {
+			elem.Rotation = num(5)
+		}
+		for i := 6; i < len(args)-1; i++ {
+			if mask, ok := strings.CutPrefix(args[i], "S"); ok {
+				if m, err := strconv.Atoi(mask); err == nil {
+					elem.Mask = &m
+				}
+			}
+		}
+		elem.Content = last
+	case "DMATRIX":
+		elem.Type = "datamatrix"
+		elem.ModuleSize, elem.Rotation = num(2), num(3)
+		elem.Content = last
+	case "PDF417":
+		elem.Type = "pdf417"
+		elem.Columns, elem.Rows, elem.ModuleSize, elem.Rotation = num(2), num(3), num(5), num(6)
+		elem.Content = last
+	case "BOX":
+		elem.Type = "box"
+		elem.XEnd, elem.YEnd, elem.Thickness = num(2), num(3), num(4)
+	case "BAR":
+		elem = &LabelElement{Type: "line", X1: num(0), Y1: num(1), X2: num(2), Y2: num(3)}
+	case "CIRCLE":
+		elem.Type = "circle"
+		elem.Radius, elem.Thickness = num(2), num(3)
+	case "ELLIPSE":
+		elem.Type = "ellipse"
+		elem.XRadius, elem.YRadius, elem.Thickness = num(2), num(3), num(4)
+	default:
+		return nil, false
+	}
+	return elem, true
+}
+
+// drawTSPLBitmap plots a BITMAP command's data, in which a 0 bit is a
+// black dot.
+func drawTSPLBitmap(c *labelCanvas, cmd tsplCommand) {
+	if len(cmd.args) < 3 {
+		return
+	}
+	x, _ := strconv.Atoi(cmd.args[0])
+	y, _ := strconv.Atoi(cmd.args[1])
+	widthBytes, _ := strconv.Atoi(cmd.args[2])
+	if widthBytes <= 0 {
+		return
+	}
+	width := widthBytes * 8
+	for i := 0; i < len(cmd.data)*8; i++ {
+		if cmd.data[i/8]&(0x80>>uint(i%8)) == 0 {
+			c.plot(x, y, i%width, i/width, 0)
+		}
+	}
+}
+
+func tsplArg(args []string, i int) string {
+	if i >= len(args) {
+		return ""
+	}
+	return args[i]
+}
+
+// renderTSPL rasterizes TSPL as it was sent to a printer, drawing each
+// command up to the first PRINT with renderElement as the element it came
+// from. The label is sized from SIZE at dpi, so raw TSPL
+// renders as well as generated TSPL. Commands that draw nothing are
+// skipped, as are images stored on the printer.
+func (g *TSPL2Generator) renderTSPL(tspl string, dpi int) (*image.Gray, error) {
+	if dpi == 0 {
+		dpi = 203
+	}
+	commands, err := parseTSPL(tspl)
+	if err != nil {
+		return nil, err
+	}
+
+	var canvas *labelCanvas
+	schema := &LabelSchema{DPI: dpi}
+	for _, cmd := range commands {
+		if cmd.name == "PRINT" {
+			break
+		}
+		if cmd.name == "SIZE" {
+			if len(cmd.args) != 2 {
+				return nil, fmt.Errorf("SIZE needs a width and a height, got %q", strings.Join(cmd.args, ","))
+			}
+			width, err := tsplLength(cmd.args[0], dpi)
+			if err != nil {
+				return nil, err
+			}
+			height, err := tsplLength(cmd.args[1], dpi)
+			if err != nil {
+				return nil, err
+			}
+			canvas = newLabelCanvas(width, height)
+			continue
+		}
+		if canvas == nil {
+			continue
+		}
+
+		if cmd.name == "BITMAP" {
+			drawTSPLBitmap(canvas, cmd)
+			continue
+		}
+
+		elem, ok := tsplElement(cmd)
+		if !ok {
+			continue
+		}
+		if err := g.renderElement(canvas, elem, nil, schema, dpi); err != nil {
+			return nil, fmt.Errorf("%s: %w", cmd.name, err)
+		}
+	}
+
+	if canvas == nil {
+		return nil, errors.New("TSPL has no SIZE command")
+	}
+	return canvas.img, nil
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestRenderTSPLMatchesRender(t *testing.T) {
+	schema := &LabelSchema{WidthMM: 60, HeightMM: 40, DPI: 203, Elements: []LabelElement{
+		{Type: "text", X: 10, Y: 10, Font: "3", Content: "SKU {{sku}}"},
+		{Type: "text", X: 300, Y: 200, Font: "2", XScale: 2, YScale: 2, Rotation: 180, Content: `say "hi", \ok`},
+		{Type: "block", X: 10, Y: 60, Width: 200, Height: 60, Font: "2", Content: "a longer line of text that wraps"},
+		{Type: "barcode", X: 10, Y: 130, Height: 40, Content: "{{sku}}"},
+		{Type: "barcode", X: 240, Y: 130, Height: 30, HumanReadable: 2, Narrow: 1, Wide: 3, Content: "{{sku}}"},
+		{Type: "qrcode", X: 300, Y: 20, Level: "Q", CellWidth: 3, Content: "https://example.com/{{sku}}"},
+		{Type: "box", X: 2, Y: 2, XEnd: 470, YEnd: 315, Thickness: 3},
+		{Type: "line", X1: 10, Y1: 50, X2: 200, Y2: 2},
+		{Type: "circle", X: 240, Y: 240, Radius: 50, Thickness: 2},
+	}}
+	variables := map[string]string{"sku": "A-1234"}
+	g := NewTSPL2Generator()
+
+	tspl, err := g.Generate(schema, variables)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	want, err := g.Render(schema, variables)
+	if err != nil {
+		t.Fatalf("render schema: %v", err)
+	}
+	got, err := g.renderTSPL(tspl, 203)
+	if err != nil {
+		t.Fatalf("render TSPL: %v", err)
+	}
+
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("TSPL renders at %v, want %v", got.Bounds(), want.Bounds())
+	}
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("TSPL renders differently from its schema")
+	}
+}
+
+func TestRenderTSPLRaw(t *testing.T) {
+	// An 8x2 dot bitmap, black where bits are 0: a dark left half.
+	bitmap := "BITMAP 20,30,1,2,0,\x0f\x0f\r\n"
+	tspl := "SIZE 2,1\r\nGAP 2 mm,0\r\nCLS\r\nBAR 100,100,50,10\r\n" + bitmap +
+		"TEXT 200,50,\"3\",0,1,1,\"ignored, after PRINT\"\r\nPRINT 1\r\nBAR 0,0,10,10\r\n"
+
+	img, err := NewTSPL2Generator().renderTSPL(tspl, 300)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 600, 300); got != want {
+		t.Fatalf("2x1 inch label at 300 DPI is %v, want %v", got, want)
+	}
+
+	dark := func(x, y int) bool { return img.GrayAt(x, y).Y == 0 }
+	tests := []struct {
+		name string
+		x, y int
+		want bool
+	}{
+		{"bar", 120, 105, true},
+		{"beside the bar", 160, 105, false},
+		{"bitmap black bit", 21, 31, true},
+		{"bitmap white bit", 25, 31, false},
+		{"after PRINT", 5, 5, false},
+	}
+	for _, tt := range tests {
+		if got := dark(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s: dot (%d, %d) dark = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestParseTSPL(t *testing.T) {
+	commands, err := parseTSPL("SIZE 50 mm, 30 mm\nCLS\nTEXT 10,10,\"3\",0,1,1,\"a, \\\"b\\\"\\\\c\"\r\nBITMAP 0,0,1,1,0,\n\nPRINT 1")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []tsplCommand{
+		{name: "SIZE", args: []string{"50 mm", "30 mm"}},
+		{name: "CLS"},
+		{name: "TEXT", args: []string{"10", "10", "3", "0", "1", "1", `a, "b"\c`}},
+		{name: "BITMAP", args: []string{"0", "0", "1", "1", "0"}, data: "\n"},
+		{name: "PRINT", args: []string{"1"}},
+	}
+	if len(commands) != len(want) {
+		t.Fatalf("parsed %d commands, want %d: %q", len(commands), len(want), commands)
+	}
+	for i, cmd := range commands {
+		if cmd.name != want[i].name || strings.Join(cmd.args, "|") != strings.Join(want[i].args, "|") || cmd.data != want[i].data {
+			t.Errorf("command %d is %q, want %q", i, cmd, want[i])
+		}
+	}
+
+	if _, err := parseTSPL("BITMAP 0,0,2,2,0,\x00"); err == nil {
+		t.Error("parsed a truncated BITMAP, want an error")
+	}
+}
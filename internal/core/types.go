@@ -1,16 +1,19 @@
 package core
 
 import (
+	"context"
 	"time"
 )
 
 type WebhookSender interface {
+	SendJobEvent(ctx context.Context, event string, jobID, printerID, templateID int64, status JobStatus, errorMsg string) error
 	SendPrinterStatusChange(printerID int64, printerName, oldStatus, newStatus string, details *PrinterStatus) error
 	SendPrintComplete(printerID int64, jobID int64, success bool, errorMsg string) error
 }
 
 type PrinterStatus struct {
-	RawStatus    [4]byte
+	// RawStatus is the status response as received: 1, 4 or 8 bytes.
+	RawStatus    []byte
 	PrinterState string
 	Warning      string
 	Error        string
@@ -18,20 +21,80 @@ type PrinterStatus struct {
 	IsOnline     bool
 	CanPrint     bool
 	LastChecked  time.Time
+
+	// Set only from an extended status response.
+	HeadTemperatureC *int
+	MediaDetail      string
+}
+
+// PrinterInfo holds identification details reported by a printer. Fields the
+// printer did not answer are left as "unknown".
+type PrinterInfo struct {
+	Model     string
+	Firmware  string
+	Mileage   string
+	Codepage  string
+	Complete  bool
+	QueriedAt time.Time
+}
+
+// ConnectionTest is the result of probing a printer address that has not
+// been added. Status is nil when the printer accepted the connection but
+// did not answer the status query; label dimensions are nil when the
+// printer did not report them.
+type ConnectionTest struct {
+	Status        *PrinterStatus
+	StatusString  string
+	Model         string
+	Firmware      string
+	LabelWidthMM  *float64
+	LabelHeightMM *float64
+}
+
+// PrinterConfig is the media and print settings a printer reports. Fields
+// are nil when the printer did not report them.
+type PrinterConfig struct {
+	LabelWidthMM  *float64
+	LabelHeightMM *float64
+	GapMM         *float64
+	Speed         *float64
+	Density       *int
+	QueriedAt     time.Time
 }
 
 type Printer struct {
-	ID            int64
-	Name          string
-	IPAddress     string
-	Port          int
-	DPI           int
-	LabelWidthMM  float64
-	LabelHeightMM float64
-	GapMM         float64
-	Status        string
-	LastSeenAt    *time.Time
-	TotalPrints   int64
+	ID                int64
+	Name              string
+	IPAddress         string
+	Port              int
+	DPI               int
+	LabelWidthMM      float64
+	LabelHeightMM     float64
+	GapMM             float64
+	Status            string
+	LastSeenAt        *time.Time
+	TotalPrints       int64
+	DefaultTemplateID *int64
+	LineEnding        string
+	Encoding          string
+	FallbackPrinterID *int64
+	Group             string
+	// FeedOnError is what is sent after a failed print to realign the
+	// label stock: FeedOnErrorNone, FeedOnErrorFormfeed or a calibration
+	// media type. Empty means none.
+	FeedOnError string
+	// InitCommands are sent once on each new connection to the printer,
+	// before anything else. Empty means the configured default.
+	InitCommands []string
+	// StatusLength is how many bytes the printer answers a status query
+	// with: StatusLengthSingle, StatusLengthStandard or
+	// StatusLengthExtended. Zero means StatusLengthStandard.
+	StatusLength int
+	// Type is PrinterTypeNetwork or PrinterTypeFile. Empty means
+	// PrinterTypeNetwork.
+	Type string
+	// SpoolDir is the directory a file printer writes its prints to.
+	SpoolDir string
 }
 
 type PrinterStatusChange struct {
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckVariableValue returns an error naming the variable when value does
+// not suit def: a number variable must parse as a number, and a date or
+// datetime variable must be a date in def.Format, or in one of the layouts
+// the date directive reads when no format is set. When def.Pattern is set,
+// the whole value must also match it. Other types accept any value.
+func CheckVariableValue(name string, def VariableDef, value string) error {
+	switch def.Type {
+	case "number":
+		if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return fmt.Errorf("variable '%s' must be a number, got '%s'", name, value)
+		}
+	case "date", "datetime":
+		if !isDate(strings.TrimSpace(value), def.Format) {
+			expected := def.Format
+			if expected == "" {
+				expected = "2006-01-02"
+				if def.Type == "datetime" {
+					expected = "2006-01-02 15:04"
+				}
+			}
+			return fmt.Errorf("variable '%s' must be a %s like %s, got '%s'", name, def.Type, expected, value)
+		}
+	}
+
+	if def.Pattern != "" {
+		pattern, err := compileVariablePattern(def.Pattern)
+		if err != nil {
+			return fmt.Errorf("variable '%s' has an invalid pattern: %w", name, err)
+		}
+		if !pattern.MatchString(value) {
+			return fmt.Errorf("variable '%s' must match %s, got '%s'", name, def.Pattern, value)
+		}
+	}
+	return nil
+}
+
+// compileVariablePattern compiles a variable's pattern so that it must
+// match the whole value.
+func compileVariablePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// ValidateVariablePattern reports whether pattern is usable as a variable's
+// pattern.
+func ValidateVariablePattern(pattern string) error {
+	_, err := compileVariablePattern(pattern)
+	return err
+}
+
+func isDate(value, format string) bool {
+	if format != "" {
+		_, err := time.Parse(format, value)
+		return err == nil
+	}
+	for _, layout := range variableDateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckVariableValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		def   VariableDef
+		value string
+		want  string // substring of the error, or "" for none
+	}{
+		{"integer", VariableDef{Type: "number"}, "42", ""},
+		{"decimal", VariableDef{Type: "number"}, " -12.50 ", ""},
+		{"not a number", VariableDef{Type: "number"}, "12 units", "variable 'v' must be a number"},
+		{"date", VariableDef{Type: "date"}, "2026-03-01", ""},
+		{"datetime as date", VariableDef{Type: "date"}, "2026-03-01 09:30", ""},
+		{"not a date", VariableDef{Type: "date"}, "01/03/2026", "variable 'v' must be a date like 2006-01-02"},
+		{"date in format", VariableDef{Type: "date", Format: "02/01/2006"}, "01/03/2026", ""},
+		{"date not in format", VariableDef{Type: "date", Format: "02/01/2006"}, "2026-03-01", "must be a date like 02/01/2006"},
+		{"datetime", VariableDef{Type: "datetime"}, "yesterday", "must be a datetime like 2006-01-02 15:04"},
+		{"string", VariableDef{Type: "string"}, "anything", ""},
+		{"pattern", VariableDef{Type: "string", Pattern: `[A-Z]{3}-\d+`}, "LOT-42", ""},
+		{"pattern matches whole value", VariableDef{Type: "string", Pattern: `[A-Z]{3}-\d+`}, "LOT-42x", `must match [A-Z]{3}-\d+`},
+		{"invalid pattern", VariableDef{Type: "string", Pattern: `(`}, "x", "invalid pattern"},
+	}
+	for _, tt := range tests {
+		err := CheckVariableValue("v", tt.def, tt.value)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %q rejected: %v", tt.name, tt.value, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: %q gave error %v, want %q", tt.name, tt.value, err, tt.want)
+		}
+	}
+}
+
+func TestValidateVariablesChecksTypes(t *testing.T) {
+	g := NewTSPL2Generator()
+	schema := &LabelSchema{Variables: map[string]VariableDef{
+		"qty":  {Type: "number", Required: true},
+		"note": {Type: "string"},
+	}}
+
+	if err := g.ValidateVariables(schema, map[string]string{"qty": "3"}); err != nil {
+		t.Errorf("numeric qty rejected: %v", err)
+	}
+	err := g.ValidateVariables(schema, map[string]string{"qty": "three"})
+	if err == nil || !strings.Contains(err.Error(), "'qty' must be a number") {
+		t.Errorf("non-numeric qty gave %v, want it rejected by name", err)
+	}
+	if _, err := g.Generate(&LabelSchema{WidthMM: 50, HeightMM: 30, Variables: schema.Variables,
+		Elements: []LabelElement{{Type: "text", Content: "{{qty}}"}}}, map[string]string{"qty": "three"}); err == nil {
+		t.Error("generate with a non-numeric qty succeeded")
+	}
+}
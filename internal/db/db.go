@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -18,24 +19,80 @@ var (
 	once sync.Once
 )
 
+// Defaults for a file database's connection pool and lock wait.
+const (
+	defaultMaxOpenConns = 4
+	defaultBusyTimeout  = 5 * time.Second
+)
+
+// Config says where the database lives and how it is opened. A file
+// database runs in WAL mode, so its MaxOpenConns connections can read
+// while another writes; writers still take turns, each waiting up to
+// BusyTimeout for the one before. An in-memory database has a single
+// connection, as each connection would otherwise get its own database.
 type Config struct {
-	Path string
+	Path         string
+	MaxOpenConns int
+	BusyTimeout  time.Duration
 }
 
 func Init(cfg Config) error {
 	var initErr error
 	once.Do(func() {
-		db, initErr = sql.Open("sqlite3", cfg.Path)
+		db, initErr = open(cfg)
 		if initErr != nil {
 			return
 		}
-		db.SetMaxOpenConns(1)
-		db.SetMaxIdleConns(1)
 		initErr = runMigrations(db)
 	})
 	return initErr
 }
 
+// open opens the database described by cfg.
+func open(cfg Config) (*sql.DB, error) {
+	if isMemory(cfg.Path) {
+		conn, err := sql.Open("sqlite3", cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		conn.SetMaxOpenConns(1)
+		conn.SetMaxIdleConns(1)
+		return conn, nil
+	}
+
+	conns := cfg.MaxOpenConns
+	if conns <= 0 {
+		conns = defaultMaxOpenConns
+	}
+	busyTimeout := cfg.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = defaultBusyTimeout
+	}
+
+	// _txlock=immediate makes every transaction take the write lock when
+	// it begins. A deferred transaction that reads and then writes could
+	// otherwise fail with SQLITE_BUSY at once, without waiting out the
+	// busy timeout, when another connection is writing.
+	separator := "?"
+	if strings.Contains(cfg.Path, "?") {
+		separator = "&"
+	}
+	dsn := fmt.Sprintf("%s%s_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate",
+		cfg.Path, separator, busyTimeout.Milliseconds())
+
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(conns)
+	conn.SetMaxIdleConns(conns)
+	return conn, nil
+}
+
+func isMemory(path string) bool {
+	return path == ":memory:" || strings.Contains(path, "mode=memory")
+}
+
 func GetDB() *sql.DB {
 	return db
 }
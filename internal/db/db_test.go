@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestFile opens a file database in a temporary directory with a table
+// of ten rows.
+func openTestFile(t testing.TB, cfg Config) *sql.DB {
+	t.Helper()
+
+	cfg.Path = filepath.Join(t.TempDir(), "spool.db")
+	conn, err := open(cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if _, err := conn.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, value TEXT)"); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := conn.Exec("INSERT INTO items (value) VALUES ('seed')"); err != nil {
+			t.Fatalf("seed table: %v", err)
+		}
+	}
+	return conn
+}
+
+func TestFileDatabaseUsesWAL(t *testing.T) {
+	conn := openTestFile(t, Config{})
+
+	var mode string
+	if err := conn.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatalf("read journal mode: %v", err)
+	}
+	if mode != "wal" {
+		t.Errorf("journal mode is %s, want wal", mode)
+	}
+}
+
+func TestReadsSucceedDuringWriteTransaction(t *testing.T) {
+	conn := openTestFile(t, Config{MaxOpenConns: 4, BusyTimeout: 5 * time.Second})
+
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("begin write: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO items (value) VALUES ('uncommitted')"); err != nil {
+		t.Fatalf("write in transaction: %v", err)
+	}
+
+	// With the write transaction still open, readers on the other
+	// connections see the last committed state without waiting.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	counts := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM items").Scan(&n); err != nil {
+				errs <- err
+				return
+			}
+			counts <- n
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	close(counts)
+	for err := range errs {
+		t.Errorf("read during write: %v", err)
+	}
+	for n := range counts {
+		if n != 10 {
+			t.Errorf("read %d rows during write, want the 10 committed", n)
+		}
+	}
+
+	// A second writer waits for the first rather than failing.
+	written := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec("INSERT INTO items (value) VALUES ('second')")
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		t.Fatalf("second write finished during the first: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("second write: %v", err)
+	}
+
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil {
+		t.Fatalf("count after writes: %v", err)
+	}
+	if n != 12 {
+		t.Errorf("%d rows after both writes, want 12", n)
+	}
+}
+
+func TestMemoryDatabaseKeepsOneConnection(t *testing.T) {
+	conn, err := open(Config{Path: ":memory:", MaxOpenConns: 4})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer conn.Close()
+
+	if got := conn.Stats().MaxOpenConnections; got != 1 {
+		t.Errorf("in-memory database allows %d connections, want 1", got)
+	}
+}
+
+// BenchmarkReadsDuringWrite measures reads while a write transaction holds
+// the database, which a single connection would make wait for the write.
+func BenchmarkReadsDuringWrite(b *testing.B) {
+	conn := openTestFile(b, Config{MaxOpenConns: 4})
+
+	tx, err := conn.Begin()
+	if err != nil {
+		b.Fatalf("begin write: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO items (value) VALUES ('uncommitted')"); err != nil {
+		b.Fatalf("write in transaction: %v", err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var n int
+			if err := conn.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil {
+				b.Errorf("read during write: %v", err)
+				return
+			}
+		}
+	})
+}
+
+// TestMigrationsAreSequential checks that migration files are numbered
+// 001, 002, ... without gaps or repeats, and that each starts with a
+// header naming its own file.
+func TestMigrationsAreSequential(t *testing.T) {
+	paths, err := filepath.Glob("migrations/*.sql")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("find migrations: %v (found %d)", err, len(paths))
+	}
+	sort.Strings(paths)
+
+	for i, path := range paths {
+		name := filepath.Base(path)
+		if want := fmt.Sprintf("%03d_", i+1); !strings.HasPrefix(name, want) {
+			t.Errorf("migration %s is number %d in order, want a %s prefix", name, i+1, want)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if header := "-- " + name + "\n"; !strings.HasPrefix(string(content), header) {
+			t.Errorf("migration %s does not start with the header %q", name, strings.TrimSpace(header))
+		}
+	}
+}
+
+func TestJobListQueryOrdersOnlyByKnownColumns(t *testing.T) {
+	query, _ := jobListQuery(JobFilter{OrderBy: "priority", OrderDir: "asc"})
+	if !strings.HasSuffix(query, " ORDER BY priority ASC") {
+		t.Errorf("query %q, want it ordered by priority ASC", query)
+	}
+
+	query, _ = jobListQuery(JobFilter{OrderBy: "(SELECT 1)", OrderDir: "DESC; DROP TABLE print_jobs"})
+	if !strings.HasSuffix(query, " ORDER BY created_at DESC") {
+		t.Errorf("query %q, want unknown order to fall back to created_at DESC", query)
+	}
+}
@@ -0,0 +1,4 @@
+-- 002_printer_default_template.sql
+-- Optional default template used for printer test prints
+
+ALTER TABLE printers ADD COLUMN default_template_id INTEGER REFERENCES label_templates(id) ON DELETE SET NULL;
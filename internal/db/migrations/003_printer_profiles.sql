@@ -0,0 +1,23 @@
+-- 003_printer_profiles.sql
+-- Reusable printer configuration profiles
+
+CREATE TABLE IF NOT EXISTS printer_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    dpi INTEGER DEFAULT 203,
+    label_width_mm REAL NOT NULL,
+    label_height_mm REAL NOT NULL,
+    gap_mm REAL DEFAULT 2,
+    default_template_id INTEGER REFERENCES label_templates(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_printer_profiles_name ON printer_profiles(name);
+
+CREATE TRIGGER IF NOT EXISTS printer_profiles_updated_at
+AFTER UPDATE ON printer_profiles
+BEGIN
+    UPDATE printer_profiles SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
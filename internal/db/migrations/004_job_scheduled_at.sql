@@ -0,0 +1,6 @@
+-- 004_job_scheduled_at.sql
+-- Allow jobs to be held back until a future time
+
+ALTER TABLE print_jobs ADD COLUMN scheduled_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_scheduled ON print_jobs(scheduled_at);
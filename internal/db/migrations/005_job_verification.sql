@@ -0,0 +1,6 @@
+-- 005_job_verification.sql
+-- Scan-back verification results for completed jobs
+
+ALTER TABLE print_jobs ADD COLUMN verification_status TEXT CHECK(verification_status IN ('verified', 'mismatch'));
+ALTER TABLE print_jobs ADD COLUMN scanned_value TEXT;
+ALTER TABLE print_jobs ADD COLUMN verified_at DATETIME;
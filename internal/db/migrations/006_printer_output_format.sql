@@ -0,0 +1,5 @@
+-- 006_printer_output_format.sql
+-- Per-printer line endings and character encoding for data sent to the device
+
+ALTER TABLE printers ADD COLUMN line_ending TEXT NOT NULL DEFAULT 'lf' CHECK(line_ending IN ('lf', 'crlf'));
+ALTER TABLE printers ADD COLUMN encoding TEXT NOT NULL DEFAULT 'UTF-8';
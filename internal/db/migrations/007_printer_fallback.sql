@@ -0,0 +1,5 @@
+-- 007_printer_fallback.sql
+-- Backup printer for failover and the printers each job has already tried
+
+ALTER TABLE printers ADD COLUMN fallback_printer_id INTEGER REFERENCES printers(id) ON DELETE SET NULL;
+ALTER TABLE print_jobs ADD COLUMN attempted_printers TEXT;
@@ -0,0 +1,6 @@
+-- 008_printer_group.sql
+-- Printer groups (e.g. warehouse zones) for group-targeted jobs
+
+ALTER TABLE printers ADD COLUMN printer_group TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_printers_group ON printers(printer_group);
@@ -0,0 +1,4 @@
+-- 009_webhook_filters.sql
+-- Optional printer and template filters for webhooks, stored as JSON
+
+ALTER TABLE webhooks ADD COLUMN filters_json TEXT NOT NULL DEFAULT '';
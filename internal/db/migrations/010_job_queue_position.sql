@@ -0,0 +1,7 @@
+-- 010_job_queue_position.sql
+-- Manual ordering of pending jobs within a priority (promote/demote)
+
+ALTER TABLE print_jobs ADD COLUMN queue_position INTEGER NOT NULL DEFAULT 0;
+
+DROP INDEX IF EXISTS idx_jobs_priority;
+CREATE INDEX IF NOT EXISTS idx_jobs_priority ON print_jobs(priority DESC, queue_position ASC, created_at ASC);
@@ -0,0 +1,4 @@
+-- 012_printer_feed_on_error.sql
+-- Command sent after a failed print to realign the label stock: none, formfeed, or a calibration for gap, black_mark or auto media
+
+ALTER TABLE printers ADD COLUMN feed_on_error TEXT NOT NULL DEFAULT 'none' CHECK(feed_on_error IN ('none', 'formfeed', 'gap', 'black_mark', 'auto'));
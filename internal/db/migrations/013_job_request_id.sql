@@ -0,0 +1,4 @@
+-- 013_job_request_id.sql
+-- Request ID of the API call that created a job, for correlating log lines
+
+ALTER TABLE print_jobs ADD COLUMN request_id TEXT;
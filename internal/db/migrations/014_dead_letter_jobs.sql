@@ -0,0 +1,21 @@
+-- 014_dead_letter_jobs.sql
+-- Jobs that exhausted their retries, moved out of print_jobs with a snapshot of the job and the failure reason
+
+CREATE TABLE IF NOT EXISTS dead_letter_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER NOT NULL,
+    printer_id INTEGER,
+    template_id INTEGER,
+    variables_json TEXT,
+    tspl_content TEXT,
+    priority INTEGER DEFAULT 0,
+    copies INTEGER DEFAULT 1,
+    retry_count INTEGER DEFAULT 0,
+    submitted_by TEXT,
+    request_id TEXT,
+    failure_reason TEXT,
+    created_at DATETIME,
+    failed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letter_failed ON dead_letter_jobs(failed_at);
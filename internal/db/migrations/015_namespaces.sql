@@ -0,0 +1,10 @@
+-- 015_namespaces.sql
+-- Namespace tags for jobs and templates; an API key with a namespace only sees rows tagged with it
+
+ALTER TABLE print_jobs ADD COLUMN namespace TEXT NOT NULL DEFAULT '';
+ALTER TABLE label_templates ADD COLUMN namespace TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_keys ADD COLUMN namespace TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_namespace ON print_jobs(namespace);
+CREATE INDEX IF NOT EXISTS idx_templates_namespace ON label_templates(namespace);
+ALTER TABLE dead_letter_jobs ADD COLUMN namespace TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- 016_printer_init_commands.sql
+-- Commands sent once on each new printer connection, such as GAPDETECT, one per line
+
+ALTER TABLE printers ADD COLUMN init_commands TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,20 @@
+-- 017_webhook_deliveries.sql
+-- Each attempt to deliver a webhook, with the response status or error, removed with the webhook
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+
+CREATE TRIGGER IF NOT EXISTS webhooks_delete_deliveries
+AFTER DELETE ON webhooks
+BEGIN
+    DELETE FROM webhook_deliveries WHERE webhook_id = OLD.id;
+END;
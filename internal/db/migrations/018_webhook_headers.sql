@@ -0,0 +1,4 @@
+-- 018_webhook_headers.sql
+-- Optional HTTP headers sent with each webhook delivery, stored as a JSON object
+
+ALTER TABLE webhooks ADD COLUMN headers_json TEXT NOT NULL DEFAULT '';
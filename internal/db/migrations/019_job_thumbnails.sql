@@ -0,0 +1,14 @@
+-- 019_job_thumbnails.sql
+-- Thumbnails of the label each completed job printed, removed with the job
+
+CREATE TABLE IF NOT EXISTS job_thumbnails (
+    job_id INTEGER PRIMARY KEY,
+    png BLOB NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER IF NOT EXISTS print_jobs_delete_thumbnail
+AFTER DELETE ON print_jobs
+BEGIN
+    DELETE FROM job_thumbnails WHERE job_id = OLD.id;
+END;
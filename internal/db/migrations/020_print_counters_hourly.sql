@@ -0,0 +1,12 @@
+-- 020_print_counters_hourly.sql
+-- Hourly print count per printer, alongside the daily print_counters totals
+
+CREATE TABLE IF NOT EXISTS print_counters_hourly (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    printer_id INTEGER REFERENCES printers(id) ON DELETE CASCADE,
+    hour TEXT NOT NULL,
+    count INTEGER DEFAULT 0,
+    UNIQUE(printer_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_counters_hourly_printer_hour ON print_counters_hourly(printer_id, hour);
@@ -0,0 +1,18 @@
+-- 021_webhook_tasks.sql
+-- Webhook deliveries not yet made, so they survive a restart, removed with the webhook
+
+CREATE TABLE IF NOT EXISTS webhook_tasks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    payload_json TEXT NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 0,
+    delivered INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER IF NOT EXISTS webhooks_delete_tasks
+AFTER DELETE ON webhooks
+BEGIN
+    DELETE FROM webhook_tasks WHERE webhook_id = OLD.id;
+END;
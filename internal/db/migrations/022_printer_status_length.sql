@@ -0,0 +1,4 @@
+-- 022_printer_status_length.sql
+-- Length of the printer's status response: 1 for single-byte bitmask status, 4 for the standard status, 8 for extended status
+
+ALTER TABLE printers ADD COLUMN status_length INTEGER NOT NULL DEFAULT 4 CHECK(status_length IN (1, 4, 8));
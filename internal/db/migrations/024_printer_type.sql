@@ -0,0 +1,5 @@
+-- 024_printer_type.sql
+-- Printer type: network printers are sent TSPL over TCP, file printers write each print to spool_dir. A file printer's ip_address holds "file:" and its spool_dir, as the column must be unique
+
+ALTER TABLE printers ADD COLUMN printer_type TEXT NOT NULL DEFAULT 'network' CHECK(printer_type IN ('network', 'file'));
+ALTER TABLE printers ADD COLUMN spool_dir TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+-- 025_printer_profile_settings.sql
+-- Output, failover, group, error feed, init command and status settings carried by printer profiles, with the same defaults as printers
+
+ALTER TABLE printer_profiles ADD COLUMN line_ending TEXT NOT NULL DEFAULT 'lf' CHECK(line_ending IN ('lf', 'crlf'));
+ALTER TABLE printer_profiles ADD COLUMN encoding TEXT NOT NULL DEFAULT 'UTF-8';
+ALTER TABLE printer_profiles ADD COLUMN fallback_printer_id INTEGER REFERENCES printers(id) ON DELETE SET NULL;
+ALTER TABLE printer_profiles ADD COLUMN printer_group TEXT NOT NULL DEFAULT '';
+ALTER TABLE printer_profiles ADD COLUMN feed_on_error TEXT NOT NULL DEFAULT 'none' CHECK(feed_on_error IN ('none', 'formfeed', 'gap', 'black_mark', 'auto'));
+ALTER TABLE printer_profiles ADD COLUMN init_commands TEXT NOT NULL DEFAULT '';
+ALTER TABLE printer_profiles ADD COLUMN status_length INTEGER NOT NULL DEFAULT 4 CHECK(status_length IN (1, 4, 8));
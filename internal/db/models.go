@@ -1,24 +1,83 @@
 package db
 
 import (
-	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
 	"time"
 )
 
 type Printer struct {
-	ID            int64      `json:"id"`
-	Name          string     `json:"name"`
-	IPAddress     string     `json:"ip_address"`
-	Port          int        `json:"port"`
-	DPI           int        `json:"dpi"`
-	LabelWidthMM  float64    `json:"label_width_mm"`
-	LabelHeightMM float64    `json:"label_height_mm"`
-	GapMM         float64    `json:"gap_mm"`
-	Status        string     `json:"status"`
-	LastSeenAt    *time.Time `json:"last_seen_at"`
-	TotalPrints   int64      `json:"total_prints"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	ID                int64       `json:"id"`
+	Name              string      `json:"name"`
+	IPAddress         string      `json:"ip_address"`
+	Port              int         `json:"port"`
+	DPI               int         `json:"dpi"`
+	LabelWidthMM      float64     `json:"label_width_mm"`
+	LabelHeightMM     float64     `json:"label_height_mm"`
+	GapMM             float64     `json:"gap_mm"`
+	Status            string      `json:"status"`
+	LastSeenAt        *time.Time  `json:"last_seen_at"`
+	TotalPrints       int64       `json:"total_prints"`
+	DefaultTemplateID *int64      `json:"default_template_id"`
+	LineEnding        string      `json:"line_ending"`
+	Encoding          string      `json:"encoding"`
+	FallbackPrinterID *int64      `json:"fallback_printer_id"`
+	Group             string      `json:"group"`
+	FeedOnError       string      `json:"feed_on_error"`
+	InitCommands      CommandList `json:"init_commands"`
+	StatusLength      int         `json:"status_length"`
+	Type              string      `json:"type"`
+	SpoolDir          string      `json:"spool_dir"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+}
+
+// CommandList is a list of printer commands, stored one per line.
+type CommandList []string
+
+func (l CommandList) Value() (driver.Value, error) {
+	return strings.Join(l, "\n"), nil
+}
+
+func (l *CommandList) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into CommandList", src)
+	}
+	*l = nil
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			*l = append(*l, line)
+		}
+	}
+	return nil
+}
+
+type PrinterProfile struct {
+	ID                int64       `json:"id"`
+	Name              string      `json:"name"`
+	Description       string      `json:"description"`
+	DPI               int         `json:"dpi"`
+	LabelWidthMM      float64     `json:"label_width_mm"`
+	LabelHeightMM     float64     `json:"label_height_mm"`
+	GapMM             float64     `json:"gap_mm"`
+	DefaultTemplateID *int64      `json:"default_template_id"`
+	LineEnding        string      `json:"line_ending"`
+	Encoding          string      `json:"encoding"`
+	FallbackPrinterID *int64      `json:"fallback_printer_id"`
+	Group             string      `json:"group"`
+	FeedOnError       string      `json:"feed_on_error"`
+	InitCommands      CommandList `json:"init_commands"`
+	StatusLength      int         `json:"status_length"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
 }
 
 type LabelTemplate struct {
@@ -30,6 +89,7 @@ type LabelTemplate struct {
 	HeightMM    float64   `json:"height_mm"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	Namespace   string    `json:"namespace"`
 }
 
 type PrintJob struct {
@@ -47,6 +107,13 @@ type PrintJob struct {
 	CreatedAt     time.Time  `json:"created_at"`
 	StartedAt     *time.Time `json:"started_at"`
 	CompletedAt   *time.Time `json:"completed_at"`
+	ScheduledAt   *time.Time `json:"scheduled_at"`
+
+	VerificationStatus string     `json:"verification_status"`
+	ScannedValue       string     `json:"scanned_value"`
+	VerifiedAt         *time.Time `json:"verified_at"`
+
+	Namespace string `json:"namespace"`
 }
 
 type PrintCounter struct {
@@ -56,13 +123,35 @@ type PrintCounter struct {
 	Count     int64     `json:"count"`
 }
 
+// HourlyPrintCounter is a printer's print count for the hour starting at
+// Hour, in local time.
+type HourlyPrintCounter struct {
+	PrinterID int64     `json:"printer_id"`
+	Hour      time.Time `json:"hour"`
+	Count     int64     `json:"count"`
+}
+
 type Webhook struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	EventsJSON  string    `json:"events_json"`
+	FiltersJSON string    `json:"filters_json"`
+	HeadersJSON string    `json:"headers_json"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one attempt to deliver a webhook event. StatusCode is
+// 0 when no response was received.
+type WebhookDelivery struct {
 	ID         int64     `json:"id"`
-	Name       string    `json:"name"`
-	URL        string    `json:"url"`
-	Secret     string    `json:"secret,omitempty"`
-	EventsJSON string    `json:"events_json"`
-	Enabled    bool      `json:"enabled"`
+	WebhookID  int64     `json:"webhook_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
@@ -83,6 +172,29 @@ type AuditLog struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"key_prefix"`
+	KeyHash    string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// Namespace limits the key to jobs and templates tagged with it. An
+	// empty namespace sees everything.
+	Namespace string `json:"namespace"`
+}
+
+// User is a named login. Role is one of admin, operator or viewer.
+type User struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
 type ArchiveJob struct {
 	ID            int64     `json:"id"`
 	OriginalJobID int64     `json:"original_job_id"`
@@ -91,18 +203,31 @@ type ArchiveJob struct {
 }
 
 type JobFilter struct {
-	PrinterID int64
-	Status    string
-	FromDate  *time.Time
-	ToDate    *time.Time
-	OrderBy   string
-	OrderDir  string
-	Limit     int
-	Offset    int
+	PrinterID   int64
+	TemplateID  int64
+	SubmittedBy string
+	Namespace   string
+	Status      string
+	FromDate    *time.Time
+	ToDate      *time.Time
+	OrderBy     string
+	OrderDir    string
+	Limit       int
+	Offset      int
 }
 
+// AuditFilter narrows an audit log listing. When Before is set, only
+// entries older than it are listed.
 type AuditFilter struct {
 	Action     string
 	EntityType string
 	EntityID   int64
+	Before     *AuditCursor
+}
+
+// AuditCursor marks a position in the audit log, which is listed newest
+// first by created_at and then id.
+type AuditCursor struct {
+	CreatedAt time.Time
+	ID        int64
 }
@@ -13,7 +13,8 @@ type PrinterOperations struct{}
 func (o *PrinterOperations) CreatePrinter(ctx context.Context, p *Printer) error {
 	result, err := GetDB().ExecContext(ctx, InsertPrinter,
 		p.Name, p.IPAddress, p.Port, p.DPI,
-		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.Status)
+		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.Status, p.DefaultTemplateID,
+		p.LineEnding, p.Encoding, p.FallbackPrinterID, p.Group, p.FeedOnError, p.InitCommands, p.StatusLength, p.Type, p.SpoolDir)
 	if err != nil {
 		return fmt.Errorf("failed to create printer: %w", err)
 	}
@@ -30,7 +31,8 @@ func (o *PrinterOperations) GetPrinterByID(ctx context.Context, id int64) (*Prin
 	err := GetDB().QueryRowContext(ctx, GetPrinterByID, id).Scan(
 		&p.ID, &p.Name, &p.IPAddress, &p.Port, &p.DPI,
 		&p.LabelWidthMM, &p.LabelHeightMM, &p.GapMM, &p.Status,
-		&p.LastSeenAt, &p.TotalPrints, &p.CreatedAt, &p.UpdatedAt)
+		&p.LastSeenAt, &p.TotalPrints, &p.DefaultTemplateID, &p.LineEnding, &p.Encoding,
+		&p.FallbackPrinterID, &p.Group, &p.FeedOnError, &p.InitCommands, &p.StatusLength, &p.Type, &p.SpoolDir, &p.CreatedAt, &p.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, sql.ErrNoRows
@@ -45,7 +47,8 @@ func (o *PrinterOperations) GetPrinterByIP(ctx context.Context, ip string) (*Pri
 	err := GetDB().QueryRowContext(ctx, GetPrinterByIP, ip).Scan(
 		&p.ID, &p.Name, &p.IPAddress, &p.Port, &p.DPI,
 		&p.LabelWidthMM, &p.LabelHeightMM, &p.GapMM, &p.Status,
-		&p.LastSeenAt, &p.TotalPrints, &p.CreatedAt, &p.UpdatedAt)
+		&p.LastSeenAt, &p.TotalPrints, &p.DefaultTemplateID, &p.LineEnding, &p.Encoding,
+		&p.FallbackPrinterID, &p.Group, &p.FeedOnError, &p.InitCommands, &p.StatusLength, &p.Type, &p.SpoolDir, &p.CreatedAt, &p.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, sql.ErrNoRows
@@ -68,7 +71,8 @@ func (o *PrinterOperations) ListPrinters(ctx context.Context) ([]*Printer, error
 		if err := rows.Scan(
 			&p.ID, &p.Name, &p.IPAddress, &p.Port, &p.DPI,
 			&p.LabelWidthMM, &p.LabelHeightMM, &p.GapMM, &p.Status,
-			&p.LastSeenAt, &p.TotalPrints, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			&p.LastSeenAt, &p.TotalPrints, &p.DefaultTemplateID, &p.LineEnding, &p.Encoding,
+			&p.FallbackPrinterID, &p.Group, &p.FeedOnError, &p.InitCommands, &p.StatusLength, &p.Type, &p.SpoolDir, &p.CreatedAt, &p.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan printer: %w", err)
 		}
 		printers = append(printers, p)
@@ -79,7 +83,8 @@ func (o *PrinterOperations) ListPrinters(ctx context.Context) ([]*Printer, error
 func (o *PrinterOperations) UpdatePrinter(ctx context.Context, p *Printer) error {
 	_, err := GetDB().ExecContext(ctx, UpdatePrinter,
 		p.Name, p.IPAddress, p.Port, p.DPI,
-		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.ID)
+		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.DefaultTemplateID,
+		p.LineEnding, p.Encoding, p.FallbackPrinterID, p.Group, p.FeedOnError, p.InitCommands, p.StatusLength, p.SpoolDir, p.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update printer: %w", err)
 	}
@@ -111,11 +116,107 @@ func (o *PrinterOperations) DeletePrinter(ctx context.Context, id int64) error {
 	return nil
 }
 
+type PrinterProfileOperations struct{}
+
+func (o *PrinterProfileOperations) CreateProfile(ctx context.Context, p *PrinterProfile) error {
+	result, err := GetDB().ExecContext(ctx, InsertPrinterProfile,
+		p.Name, p.Description, p.DPI,
+		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.DefaultTemplateID,
+		p.LineEnding, p.Encoding, p.FallbackPrinterID, p.Group,
+		p.FeedOnError, p.InitCommands, p.StatusLength)
+	if err != nil {
+		return fmt.Errorf("failed to create printer profile: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get printer profile id: %w", err)
+	}
+	p.ID = id
+	return nil
+}
+
+func (o *PrinterProfileOperations) GetProfileByID(ctx context.Context, id int64) (*PrinterProfile, error) {
+	p := &PrinterProfile{}
+	err := GetDB().QueryRowContext(ctx, GetPrinterProfileByID, id).Scan(
+		&p.ID, &p.Name, &p.Description, &p.DPI,
+		&p.LabelWidthMM, &p.LabelHeightMM, &p.GapMM, &p.DefaultTemplateID,
+		&p.LineEnding, &p.Encoding, &p.FallbackPrinterID, &p.Group,
+		&p.FeedOnError, &p.InitCommands, &p.StatusLength,
+		&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get printer profile: %w", err)
+	}
+	return p, nil
+}
+
+func (o *PrinterProfileOperations) GetProfileByName(ctx context.Context, name string) (*PrinterProfile, error) {
+	p := &PrinterProfile{}
+	err := GetDB().QueryRowContext(ctx, GetPrinterProfileByName, name).Scan(
+		&p.ID, &p.Name, &p.Description, &p.DPI,
+		&p.LabelWidthMM, &p.LabelHeightMM, &p.GapMM, &p.DefaultTemplateID,
+		&p.LineEnding, &p.Encoding, &p.FallbackPrinterID, &p.Group,
+		&p.FeedOnError, &p.InitCommands, &p.StatusLength,
+		&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get printer profile by name: %w", err)
+	}
+	return p, nil
+}
+
+func (o *PrinterProfileOperations) ListProfiles(ctx context.Context) ([]*PrinterProfile, error) {
+	rows, err := GetDB().QueryContext(ctx, ListPrinterProfiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list printer profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []*PrinterProfile
+	for rows.Next() {
+		p := &PrinterProfile{}
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Description, &p.DPI,
+			&p.LabelWidthMM, &p.LabelHeightMM, &p.GapMM, &p.DefaultTemplateID,
+			&p.LineEnding, &p.Encoding, &p.FallbackPrinterID, &p.Group,
+			&p.FeedOnError, &p.InitCommands, &p.StatusLength,
+			&p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan printer profile: %w", err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+func (o *PrinterProfileOperations) UpdateProfile(ctx context.Context, p *PrinterProfile) error {
+	_, err := GetDB().ExecContext(ctx, UpdatePrinterProfile,
+		p.Name, p.Description, p.DPI,
+		p.LabelWidthMM, p.LabelHeightMM, p.GapMM, p.DefaultTemplateID,
+		p.LineEnding, p.Encoding, p.FallbackPrinterID, p.Group,
+		p.FeedOnError, p.InitCommands, p.StatusLength, p.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update printer profile: %w", err)
+	}
+	return nil
+}
+
+func (o *PrinterProfileOperations) DeleteProfile(ctx context.Context, id int64) error {
+	_, err := GetDB().ExecContext(ctx, DeletePrinterProfile, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete printer profile: %w", err)
+	}
+	return nil
+}
+
 type TemplateOperations struct{}
 
 func (o *TemplateOperations) CreateTemplate(ctx context.Context, t *LabelTemplate) error {
 	result, err := GetDB().ExecContext(ctx, InsertTemplate,
-		t.Name, t.Description, t.SchemaJSON, t.WidthMM, t.HeightMM)
+		t.Name, t.Description, t.SchemaJSON, t.WidthMM, t.HeightMM, t.Namespace)
 	if err != nil {
 		return fmt.Errorf("failed to create template: %w", err)
 	}
@@ -131,7 +232,7 @@ func (o *TemplateOperations) GetTemplateByID(ctx context.Context, id int64) (*La
 	t := &LabelTemplate{}
 	err := GetDB().QueryRowContext(ctx, GetTemplateByID, id).Scan(
 		&t.ID, &t.Name, &t.Description, &t.SchemaJSON,
-		&t.WidthMM, &t.HeightMM, &t.CreatedAt, &t.UpdatedAt)
+		&t.WidthMM, &t.HeightMM, &t.CreatedAt, &t.UpdatedAt, &t.Namespace)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, sql.ErrNoRows
@@ -145,7 +246,7 @@ func (o *TemplateOperations) GetTemplateByName(ctx context.Context, name string)
 	t := &LabelTemplate{}
 	err := GetDB().QueryRowContext(ctx, GetTemplateByName, name).Scan(
 		&t.ID, &t.Name, &t.Description, &t.SchemaJSON,
-		&t.WidthMM, &t.HeightMM, &t.CreatedAt, &t.UpdatedAt)
+		&t.WidthMM, &t.HeightMM, &t.CreatedAt, &t.UpdatedAt, &t.Namespace)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, sql.ErrNoRows
@@ -160,6 +261,19 @@ func (o *TemplateOperations) ListTemplates(ctx context.Context) ([]*LabelTemplat
 	if err != nil {
 		return nil, fmt.Errorf("failed to list templates: %w", err)
 	}
+	return scanTemplates(rows)
+}
+
+// ListTemplatesByNamespace returns the templates tagged with namespace.
+func (o *TemplateOperations) ListTemplatesByNamespace(ctx context.Context, namespace string) ([]*LabelTemplate, error) {
+	rows, err := GetDB().QueryContext(ctx, ListTemplatesByNamespace, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	return scanTemplates(rows)
+}
+
+func scanTemplates(rows *sql.Rows) ([]*LabelTemplate, error) {
 	defer rows.Close()
 
 	var templates []*LabelTemplate
@@ -167,7 +281,7 @@ func (o *TemplateOperations) ListTemplates(ctx context.Context) ([]*LabelTemplat
 		t := &LabelTemplate{}
 		if err := rows.Scan(
 			&t.ID, &t.Name, &t.Description, &t.SchemaJSON,
-			&t.WidthMM, &t.HeightMM, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			&t.WidthMM, &t.HeightMM, &t.CreatedAt, &t.UpdatedAt, &t.Namespace); err != nil {
 			return nil, fmt.Errorf("failed to scan template: %w", err)
 		}
 		templates = append(templates, t)
@@ -177,7 +291,7 @@ func (o *TemplateOperations) ListTemplates(ctx context.Context) ([]*LabelTemplat
 
 func (o *TemplateOperations) UpdateTemplate(ctx context.Context, t *LabelTemplate) error {
 	_, err := GetDB().ExecContext(ctx, UpdateTemplate,
-		t.Name, t.Description, t.SchemaJSON, t.WidthMM, t.HeightMM, t.ID)
+		t.Name, t.Description, t.SchemaJSON, t.WidthMM, t.HeightMM, t.Namespace, t.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update template: %w", err)
 	}
@@ -197,7 +311,7 @@ type JobOperations struct{}
 func (o *JobOperations) CreateJob(ctx context.Context, j *PrintJob) error {
 	result, err := GetDB().ExecContext(ctx, InsertJob,
 		j.PrinterID, j.TemplateID, j.VariablesJSON, j.TSPLContent,
-		j.Priority, j.Copies, j.SubmittedBy)
+		j.Priority, j.Copies, j.SubmittedBy, j.ScheduledAt, j.Namespace)
 	if err != nil {
 		return fmt.Errorf("failed to create job: %w", err)
 	}
@@ -214,7 +328,8 @@ func (o *JobOperations) GetJobByID(ctx context.Context, id int64) (*PrintJob, er
 	err := GetDB().QueryRowContext(ctx, GetJobByID, id).Scan(
 		&j.ID, &j.PrinterID, &j.TemplateID, &j.VariablesJSON, &j.TSPLContent,
 		&j.Status, &j.Priority, &j.RetryCount, &j.ErrorMessage, &j.Copies,
-		&j.SubmittedBy, &j.CreatedAt, &j.StartedAt, &j.CompletedAt)
+		&j.SubmittedBy, &j.CreatedAt, &j.StartedAt, &j.CompletedAt, &j.ScheduledAt,
+		&j.VerificationStatus, &j.ScannedValue, &j.VerifiedAt, &j.Namespace)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, sql.ErrNoRows
@@ -236,8 +351,8 @@ func (o *JobOperations) GetJobsByStatus(ctx context.Context, status string, limi
 
 func (o *JobOperations) GetPendingJobs(ctx context.Context, limit int) ([]*PrintJob, error) {
 	query := `
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
-		FROM print_jobs WHERE status = 'pending' ORDER BY priority DESC, created_at ASC LIMIT ?
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at, namespace
+		FROM print_jobs WHERE status = 'pending' ORDER BY priority DESC, queue_position ASC, created_at ASC LIMIT ?
 	`
 	rows, err := GetDB().QueryContext(ctx, query, limit)
 	if err != nil {
@@ -274,6 +389,32 @@ func (o *JobOperations) UpdateJobProcessing(ctx context.Context, id int64, tspl
 	return nil
 }
 
+// GetPendingJobsByTemplate returns waiting jobs for a template that already
+// carry generated TSPL.
+func (o *JobOperations) GetPendingJobsByTemplate(ctx context.Context, templateID int64) ([]*PrintJob, error) {
+	rows, err := GetDB().QueryContext(ctx, GetPendingJobsByTemplate, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending jobs by template: %w", err)
+	}
+	defer rows.Close()
+
+	return scanJobs(rows)
+}
+
+// UpdatePendingJobTSPL replaces a job's TSPL only while it is still waiting.
+// It reports false if the job was picked up or finished in the meantime.
+func (o *JobOperations) UpdatePendingJobTSPL(ctx context.Context, id int64, tspl string) (bool, error) {
+	result, err := GetDB().ExecContext(ctx, UpdatePendingJobTSPL, tspl, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to update job tspl: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
 func (o *JobOperations) IncrementRetryCount(ctx context.Context, id int64) error {
 	_, err := GetDB().ExecContext(ctx, IncrementJobRetry, id)
 	if err != nil {
@@ -282,7 +423,9 @@ func (o *JobOperations) IncrementRetryCount(ctx context.Context, id int64) error
 	return nil
 }
 
-func (o *JobOperations) ListJobs(ctx context.Context, filter JobFilter) ([]*PrintJob, error) {
+// jobFilterWhere builds the WHERE clause shared by ListJobs and CountJobs.
+// Limit, offset and ordering are not part of it.
+func jobFilterWhere(filter JobFilter) (string, []interface{}) {
 	var conditions []string
 	var args []interface{}
 
@@ -290,7 +433,27 @@ func (o *JobOperations) ListJobs(ctx context.Context, filter JobFilter) ([]*Prin
 		conditions = append(conditions, "printer_id = ?")
 		args = append(args, filter.PrinterID)
 	}
-	if filter.Status != "" {
+	if filter.TemplateID > 0 {
+		conditions = append(conditions, "template_id = ?")
+		args = append(args, filter.TemplateID)
+	}
+	if filter.SubmittedBy != "" {
+		conditions = append(conditions, "submitted_by = ?")
+		args = append(args, filter.SubmittedBy)
+	}
+	if filter.Namespace != "" {
+		conditions = append(conditions, "namespace = ?")
+		args = append(args, filter.Namespace)
+	}
+	switch filter.Status {
+	case "":
+	case "scheduled":
+		conditions = append(conditions, "status = 'pending' AND scheduled_at > ?")
+		args = append(args, time.Now().UTC())
+	case "pending":
+		conditions = append(conditions, "status = 'pending' AND (scheduled_at IS NULL OR scheduled_at <= ?)")
+		args = append(args, time.Now().UTC())
+	default:
 		conditions = append(conditions, "status = ?")
 		args = append(args, filter.Status)
 	}
@@ -303,20 +466,58 @@ func (o *JobOperations) ListJobs(ctx context.Context, filter JobFilter) ([]*Prin
 		args = append(args, filter.ToDate)
 	}
 
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// jobOrderColumns are the columns jobs can be ordered by.
+var jobOrderColumns = []string{"created_at", "completed_at", "priority", "status", "id"}
+
+// jobListQuery builds the SELECT for jobs matching filter, ordered but
+// without a limit. The order is spliced into the SQL, so an OrderBy outside
+// jobOrderColumns or an OrderDir other than ASC or DESC falls back to the
+// default of newest first.
+func jobListQuery(filter JobFilter) (string, []interface{}) {
+	where, args := jobFilterWhere(filter)
+
 	orderBy := "created_at"
-	if filter.OrderBy != "" {
-		orderBy = filter.OrderBy
+	for _, column := range jobOrderColumns {
+		if filter.OrderBy == column {
+			orderBy = column
+		}
 	}
 	orderDir := "DESC"
-	if filter.OrderDir != "" {
-		orderDir = filter.OrderDir
+	if strings.EqualFold(filter.OrderDir, "ASC") {
+		orderDir = "ASC"
 	}
 
-	query := "SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at FROM print_jobs"
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
-	}
+	query := "SELECT " + jobListColumns + " FROM print_jobs" + where
 	query += fmt.Sprintf(" ORDER BY %s %s", orderBy, orderDir)
+	return query, args
+}
+
+const jobListColumns = "id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, COALESCE(error_message, ''), copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at, namespace"
+
+// ListUpcomingJobs returns up to limit pending jobs that are due, in the
+// order the queue will dispatch them. A non-empty namespace restricts them
+// to jobs tagged with it.
+func (o *JobOperations) ListUpcomingJobs(ctx context.Context, namespace string, limit int) ([]*PrintJob, error) {
+	where, args := jobFilterWhere(JobFilter{Namespace: namespace, Status: "pending"})
+	query := "SELECT " + jobListColumns + " FROM print_jobs" + where + " ORDER BY " + JobDispatchOrder + " LIMIT ?"
+
+	rows, err := GetDB().QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upcoming jobs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanJobs(rows)
+}
+
+func (o *JobOperations) ListJobs(ctx context.Context, filter JobFilter) ([]*PrintJob, error) {
+	query, args := jobListQuery(filter)
 
 	limit := 100
 	if filter.Limit > 0 {
@@ -336,6 +537,44 @@ func (o *JobOperations) ListJobs(ctx context.Context, filter JobFilter) ([]*Prin
 	return scanJobs(rows)
 }
 
+// EachJob calls fn for every job matching filter, one row at a time, so
+// large result sets are never held in memory. Limit and offset are ignored.
+// Iteration stops at the first error fn returns. The rows hold a pool
+// connection until iteration ends, and an in-memory database has only the
+// one, so fn must not query the database itself.
+func (o *JobOperations) EachJob(ctx context.Context, filter JobFilter, fn func(*PrintJob) error) error {
+	query, args := jobListQuery(filter)
+
+	rows, err := GetDB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(j); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// CountJobs returns how many jobs match filter, ignoring its limit and offset.
+func (o *JobOperations) CountJobs(ctx context.Context, filter JobFilter) (int64, error) {
+	where, args := jobFilterWhere(filter)
+
+	var count int64
+	err := GetDB().QueryRowContext(ctx, "SELECT COUNT(*) FROM print_jobs"+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	return count, nil
+}
+
 func (o *JobOperations) CountJobsByStatus(ctx context.Context, status string) (int64, error) {
 	var count int64
 	err := GetDB().QueryRowContext(ctx, "SELECT COUNT(*) FROM print_jobs WHERE status = ?", status).Scan(&count)
@@ -353,26 +592,71 @@ func (o *JobOperations) DeleteJob(ctx context.Context, id int64) error {
 	return nil
 }
 
+// GetJobThumbnail returns the PNG thumbnail stored for a completed job, or
+// sql.ErrNoRows when it has none.
+func (o *JobOperations) GetJobThumbnail(ctx context.Context, jobID int64) ([]byte, error) {
+	var thumbnail []byte
+	err := GetDB().QueryRowContext(ctx, GetJobThumbnail, jobID).Scan(&thumbnail)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get job thumbnail: %w", err)
+	}
+	return thumbnail, nil
+}
+
+// DeleteCompletedJobsBefore removes completed and cancelled jobs that finished
+// more than days ago. Pending, processing and failed jobs are never touched.
+func (o *JobOperations) DeleteCompletedJobsBefore(ctx context.Context, days int) (int64, error) {
+	result, err := GetDB().ExecContext(ctx, DeleteCompletedJobs, fmt.Sprintf("-%d days", days))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete completed jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// PurgeCompletedJobTSPLBefore clears the stored TSPL of completed and
+// cancelled template jobs that finished more than days ago, and returns how
+// many jobs were cleared. Raw TSPL jobs are skipped: their TSPL is all there
+// is.
+func (o *JobOperations) PurgeCompletedJobTSPLBefore(ctx context.Context, days int) (int64, error) {
+	result, err := GetDB().ExecContext(ctx, PurgeCompletedJobTSPL, fmt.Sprintf("-%d days", days))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge job TSPL: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 func scanJobs(rows *sql.Rows) ([]*PrintJob, error) {
 	var jobs []*PrintJob
 	for rows.Next() {
-		j := &PrintJob{}
-		if err := rows.Scan(
-			&j.ID, &j.PrinterID, &j.TemplateID, &j.VariablesJSON, &j.TSPLContent,
-			&j.Status, &j.Priority, &j.RetryCount, &j.ErrorMessage, &j.Copies,
-			&j.SubmittedBy, &j.CreatedAt, &j.StartedAt, &j.CompletedAt); err != nil {
-			return nil, fmt.Errorf("failed to scan job: %w", err)
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
 		}
 		jobs = append(jobs, j)
 	}
 	return jobs, rows.Err()
 }
 
+func scanJob(rows *sql.Rows) (*PrintJob, error) {
+	j := &PrintJob{}
+	if err := rows.Scan(
+		&j.ID, &j.PrinterID, &j.TemplateID, &j.VariablesJSON, &j.TSPLContent,
+		&j.Status, &j.Priority, &j.RetryCount, &j.ErrorMessage, &j.Copies,
+		&j.SubmittedBy, &j.CreatedAt, &j.StartedAt, &j.CompletedAt, &j.ScheduledAt,
+		&j.VerificationStatus, &j.ScannedValue, &j.VerifiedAt, &j.Namespace); err != nil {
+		return nil, fmt.Errorf("failed to scan job: %w", err)
+	}
+	return j, nil
+}
+
 type WebhookOperations struct{}
 
 func (o *WebhookOperations) CreateWebhook(ctx context.Context, w *Webhook) error {
 	result, err := GetDB().ExecContext(ctx, InsertWebhook,
-		w.Name, w.URL, w.Secret, w.EventsJSON, w.Enabled)
+		w.Name, w.URL, w.Secret, w.EventsJSON, w.FiltersJSON, w.HeadersJSON, w.Enabled)
 	if err != nil {
 		return fmt.Errorf("failed to create webhook: %w", err)
 	}
@@ -387,7 +671,7 @@ func (o *WebhookOperations) CreateWebhook(ctx context.Context, w *Webhook) error
 func (o *WebhookOperations) GetWebhookByID(ctx context.Context, id int64) (*Webhook, error) {
 	w := &Webhook{}
 	err := GetDB().QueryRowContext(ctx, GetWebhookByID, id).Scan(
-		&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &w.Enabled, &w.CreatedAt)
+		&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &w.FiltersJSON, &w.HeadersJSON, &w.Enabled, &w.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, sql.ErrNoRows
@@ -408,7 +692,7 @@ func (o *WebhookOperations) ListWebhooks(ctx context.Context) ([]*Webhook, error
 	for rows.Next() {
 		w := &Webhook{}
 		if err := rows.Scan(
-			&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &w.Enabled, &w.CreatedAt); err != nil {
+			&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &w.FiltersJSON, &w.HeadersJSON, &w.Enabled, &w.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan webhook: %w", err)
 		}
 		webhooks = append(webhooks, w)
@@ -428,7 +712,7 @@ func (o *WebhookOperations) ListActiveWebhooksForEvent(ctx context.Context, even
 	for rows.Next() {
 		w := &Webhook{}
 		if err := rows.Scan(
-			&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &w.Enabled, &w.CreatedAt); err != nil {
+			&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &w.FiltersJSON, &w.HeadersJSON, &w.Enabled, &w.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan webhook: %w", err)
 		}
 		webhooks = append(webhooks, w)
@@ -438,7 +722,7 @@ func (o *WebhookOperations) ListActiveWebhooksForEvent(ctx context.Context, even
 
 func (o *WebhookOperations) UpdateWebhook(ctx context.Context, w *Webhook) error {
 	_, err := GetDB().ExecContext(ctx, UpdateWebhook,
-		w.Name, w.URL, w.Secret, w.EventsJSON, w.Enabled, w.ID)
+		w.Name, w.URL, w.Secret, w.EventsJSON, w.FiltersJSON, w.HeadersJSON, w.Enabled, w.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update webhook: %w", err)
 	}
@@ -453,6 +737,182 @@ func (o *WebhookOperations) DeleteWebhook(ctx context.Context, id int64) error {
 	return nil
 }
 
+// ListWebhookDeliveries returns a webhook's most recent delivery attempts,
+// newest first.
+func (o *WebhookOperations) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]*WebhookDelivery, error) {
+	rows, err := GetDB().QueryContext(ctx, ListWebhookDeliveries, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		if err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.Event, &d.Attempt, &d.StatusCode, &d.Error, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+type APIKeyOperations struct{}
+
+func (o *APIKeyOperations) CreateAPIKey(ctx context.Context, k *APIKey) error {
+	result, err := GetDB().ExecContext(ctx, InsertAPIKey, k.Name, k.KeyPrefix, k.KeyHash, k.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get api key id: %w", err)
+	}
+	k.ID = id
+	return nil
+}
+
+func (o *APIKeyOperations) GetAPIKeyByID(ctx context.Context, id int64) (*APIKey, error) {
+	return scanAPIKey(GetDB().QueryRowContext(ctx, GetAPIKeyByID, id))
+}
+
+func (o *APIKeyOperations) GetAPIKeyByName(ctx context.Context, name string) (*APIKey, error) {
+	return scanAPIKey(GetDB().QueryRowContext(ctx, GetAPIKeyByName, name))
+}
+
+// GetActiveAPIKeyByHash returns the unrevoked key with the given hash.
+func (o *APIKeyOperations) GetActiveAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	return scanAPIKey(GetDB().QueryRowContext(ctx, GetActiveAPIKeyByHash, hash))
+}
+
+func scanAPIKey(row *sql.Row) (*APIKey, error) {
+	k := &APIKey{}
+	err := row.Scan(&k.ID, &k.Name, &k.KeyPrefix, &k.KeyHash, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt, &k.Namespace)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return k, nil
+}
+
+func (o *APIKeyOperations) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	rows, err := GetDB().QueryContext(ctx, ListAPIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		k := &APIKey{}
+		if err := rows.Scan(&k.ID, &k.Name, &k.KeyPrefix, &k.KeyHash, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt, &k.Namespace); err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (o *APIKeyOperations) TouchAPIKey(ctx context.Context, id int64) error {
+	_, err := GetDB().ExecContext(ctx, TouchAPIKey, id)
+	if err != nil {
+		return fmt.Errorf("failed to update api key last use: %w", err)
+	}
+	return nil
+}
+
+// RevokeAPIKey marks a key revoked. Revoking an already revoked key is a
+// no-op.
+func (o *APIKeyOperations) RevokeAPIKey(ctx context.Context, id int64) error {
+	_, err := GetDB().ExecContext(ctx, RevokeAPIKey, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return nil
+}
+
+type UserOperations struct{}
+
+func (o *UserOperations) CreateUser(ctx context.Context, u *User) error {
+	result, err := GetDB().ExecContext(ctx, InsertUser, u.Username, u.PasswordHash, u.Role)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get user id: %w", err)
+	}
+	u.ID = id
+	return nil
+}
+
+func (o *UserOperations) GetUserByID(ctx context.Context, id int64) (*User, error) {
+	return scanUser(GetDB().QueryRowContext(ctx, GetUserByID, id))
+}
+
+func (o *UserOperations) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return scanUser(GetDB().QueryRowContext(ctx, GetUserByUsername, username))
+}
+
+func scanUser(row *sql.Row) (*User, error) {
+	u := &User{}
+	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return u, nil
+}
+
+func (o *UserOperations) ListUsers(ctx context.Context) ([]*User, error) {
+	rows, err := GetDB().QueryContext(ctx, ListUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u := &User{}
+		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// UpdateUser stores u's password hash and role.
+func (o *UserOperations) UpdateUser(ctx context.Context, u *User) error {
+	_, err := GetDB().ExecContext(ctx, UpdateUser, u.PasswordHash, u.Role, u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+func (o *UserOperations) DeleteUser(ctx context.Context, id int64) error {
+	_, err := GetDB().ExecContext(ctx, DeleteUser, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+// CountUsersByRole returns how many users have role.
+func (o *UserOperations) CountUsersByRole(ctx context.Context, role string) (int, error) {
+	var n int
+	if err := GetDB().QueryRowContext(ctx, CountUsersByRole, role).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return n, nil
+}
+
 type SettingsOperations struct{}
 
 func (o *SettingsOperations) GetSetting(ctx context.Context, key string) (*Setting, error) {
@@ -515,12 +975,19 @@ func (o *AuditOperations) ListAuditLogs(ctx context.Context, filter AuditFilter,
 		conditions = append(conditions, "entity_id = ?")
 		args = append(args, filter.EntityID)
 	}
+	if filter.Before != nil {
+		// created_at is stored as CURRENT_TIMESTAMP text, so compare in that
+		// form.
+		createdAt := filter.Before.CreatedAt.UTC().Format("2006-01-02 15:04:05")
+		conditions = append(conditions, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, createdAt, createdAt, filter.Before.ID)
+	}
 
 	query := "SELECT id, action, entity_type, entity_id, details_json, ip_address, created_at FROM audit_log"
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
-	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	query += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
 	args = append(args, limit, offset)
 
 	rows, err := GetDB().QueryContext(ctx, query, args...)
@@ -569,12 +1036,42 @@ func (o *CounterOperations) GetCounters(ctx context.Context, printerID int64, fr
 		if err := rows.Scan(&c.ID, &c.PrinterID, &dateStr, &c.Count); err != nil {
 			return nil, fmt.Errorf("failed to scan counter: %w", err)
 		}
+		// The driver reads DATE columns back as full timestamps.
+		if len(dateStr) > len("2006-01-02") {
+			dateStr = dateStr[:len("2006-01-02")]
+		}
 		c.Date, _ = time.Parse("2006-01-02", dateStr)
 		counters = append(counters, c)
 	}
 	return counters, rows.Err()
 }
 
+// HourLayout is how hourly print counters store the hour they cover.
+const HourLayout = "2006-01-02 15:00"
+
+// GetHourlyCounters returns the printer's hourly counts for the hours from
+// the one containing from to the one containing to, oldest first. Hours
+// with no prints are left out.
+func (o *CounterOperations) GetHourlyCounters(ctx context.Context, printerID int64, from, to time.Time) ([]*HourlyPrintCounter, error) {
+	rows, err := GetDB().QueryContext(ctx, GetHourlyPrintCountersByRange, printerID, from.Format(HourLayout), to.Format(HourLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hourly counters: %w", err)
+	}
+	defer rows.Close()
+
+	var counters []*HourlyPrintCounter
+	for rows.Next() {
+		c := &HourlyPrintCounter{}
+		var hourStr string
+		if err := rows.Scan(&c.PrinterID, &hourStr, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan hourly counter: %w", err)
+		}
+		c.Hour, _ = time.ParseInLocation(HourLayout, hourStr, time.Local)
+		counters = append(counters, c)
+	}
+	return counters, rows.Err()
+}
+
 type ArchiveOperations struct{}
 
 func (o *ArchiveOperations) CreateArchiveJob(ctx context.Context, a *ArchiveJob) error {
@@ -609,12 +1106,15 @@ func (o *ArchiveOperations) GetArchiveJobs(ctx context.Context, limit, offset in
 }
 
 var (
-	Printers  = &PrinterOperations{}
-	Templates = &TemplateOperations{}
-	Jobs      = &JobOperations{}
-	Webhooks  = &WebhookOperations{}
-	Settings  = &SettingsOperations{}
-	Audit     = &AuditOperations{}
-	Counters  = &CounterOperations{}
-	Archive   = &ArchiveOperations{}
+	Printers        = &PrinterOperations{}
+	PrinterProfiles = &PrinterProfileOperations{}
+	Templates       = &TemplateOperations{}
+	Jobs            = &JobOperations{}
+	Webhooks        = &WebhookOperations{}
+	Settings        = &SettingsOperations{}
+	Audit           = &AuditOperations{}
+	Counters        = &CounterOperations{}
+	Archive         = &ArchiveOperations{}
+	APIKeys         = &APIKeyOperations{}
+	Users           = &UserOperations{}
 )
@@ -1,35 +1,41 @@
 package db
 
+// JobDispatchOrder is the order in which the queue dequeues pending jobs.
+const JobDispatchOrder = "priority DESC, queue_position ASC, created_at ASC"
+
 const (
 	InsertPrinter = `
-		INSERT INTO printers (name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO printers (name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length, printer_type, spool_dir)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	GetPrinterByID = `
-		SELECT id, name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, last_seen_at, total_prints, created_at, updated_at
+		SELECT id, name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, last_seen_at, total_prints, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length, printer_type, spool_dir, created_at, updated_at
 		FROM printers WHERE id = ?
 	`
 
 	GetPrinterByIP = `
-		SELECT id, name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, last_seen_at, total_prints, created_at, updated_at
+		SELECT id, name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, last_seen_at, total_prints, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length, printer_type, spool_dir, created_at, updated_at
 		FROM printers WHERE ip_address = ?
 	`
 
 	ListPrinters = `
-		SELECT id, name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, last_seen_at, total_prints, created_at, updated_at
+		SELECT id, name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, last_seen_at, total_prints, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length, printer_type, spool_dir, created_at, updated_at
 		FROM printers ORDER BY name ASC
 	`
 
 	ListPrintersByStatus = `
-		SELECT id, name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, last_seen_at, total_prints, created_at, updated_at
+		SELECT id, name, ip_address, port, dpi, label_width_mm, label_height_mm, gap_mm, status, last_seen_at, total_prints, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length, printer_type, spool_dir, created_at, updated_at
 		FROM printers WHERE status = ? ORDER BY name ASC
 	`
 
 	UpdatePrinter = `
 		UPDATE printers SET
 			name = ?, ip_address = ?, port = ?, dpi = ?,
-			label_width_mm = ?, label_height_mm = ?, gap_mm = ?
+			label_width_mm = ?, label_height_mm = ?, gap_mm = ?,
+			default_template_id = ?, line_ending = ?, encoding = ?,
+			fallback_printer_id = ?, printer_group = ?,
+			feed_on_error = ?, init_commands = ?, status_length = ?, spool_dir = ?
 		WHERE id = ?
 	`
 
@@ -41,33 +47,84 @@ const (
 		UPDATE printers SET total_prints = total_prints + ? WHERE id = ?
 	`
 
+	RecountPrinterPrints = `
+		UPDATE printers SET total_prints = (
+			SELECT COALESCE(SUM(count), 0) FROM print_counters WHERE printer_id = printers.id
+		) WHERE id = ?
+	`
+
+	RecountAllPrinterPrints = `
+		UPDATE printers SET total_prints = (
+			SELECT COALESCE(SUM(count), 0) FROM print_counters WHERE printer_id = printers.id
+		)
+	`
+
 	DeletePrinter = `DELETE FROM printers WHERE id = ?`
 )
 
+const (
+	InsertPrinterProfile = `
+		INSERT INTO printer_profiles (name, description, dpi, label_width_mm, label_height_mm, gap_mm, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	GetPrinterProfileByID = `
+		SELECT id, name, COALESCE(description, ''), dpi, label_width_mm, label_height_mm, gap_mm, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length, created_at, updated_at
+		FROM printer_profiles WHERE id = ?
+	`
+
+	GetPrinterProfileByName = `
+		SELECT id, name, COALESCE(description, ''), dpi, label_width_mm, label_height_mm, gap_mm, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length, created_at, updated_at
+		FROM printer_profiles WHERE name = ?
+	`
+
+	ListPrinterProfiles = `
+		SELECT id, name, COALESCE(description, ''), dpi, label_width_mm, label_height_mm, gap_mm, default_template_id, line_ending, encoding, fallback_printer_id, printer_group, feed_on_error, init_commands, status_length, created_at, updated_at
+		FROM printer_profiles ORDER BY name ASC
+	`
+
+	UpdatePrinterProfile = `
+		UPDATE printer_profiles SET
+			name = ?, description = ?, dpi = ?,
+			label_width_mm = ?, label_height_mm = ?, gap_mm = ?,
+			default_template_id = ?, line_ending = ?, encoding = ?,
+			fallback_printer_id = ?, printer_group = ?, feed_on_error = ?,
+			init_commands = ?, status_length = ?
+		WHERE id = ?
+	`
+
+	DeletePrinterProfile = `DELETE FROM printer_profiles WHERE id = ?`
+)
+
 const (
 	InsertTemplate = `
-		INSERT INTO label_templates (name, description, schema_json, width_mm, height_mm)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO label_templates (name, description, schema_json, width_mm, height_mm, namespace)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
 
 	GetTemplateByID = `
-		SELECT id, name, description, schema_json, width_mm, height_mm, created_at, updated_at
+		SELECT id, name, description, schema_json, width_mm, height_mm, created_at, updated_at, namespace
 		FROM label_templates WHERE id = ?
 	`
 
 	GetTemplateByName = `
-		SELECT id, name, description, schema_json, width_mm, height_mm, created_at, updated_at
+		SELECT id, name, description, schema_json, width_mm, height_mm, created_at, updated_at, namespace
 		FROM label_templates WHERE name = ?
 	`
 
 	ListTemplates = `
-		SELECT id, name, description, schema_json, width_mm, height_mm, created_at, updated_at
+		SELECT id, name, description, schema_json, width_mm, height_mm, created_at, updated_at, namespace
 		FROM label_templates ORDER BY name ASC
 	`
 
+	ListTemplatesByNamespace = `
+		SELECT id, name, description, schema_json, width_mm, height_mm, created_at, updated_at, namespace
+		FROM label_templates WHERE namespace = ? ORDER BY name ASC
+	`
+
 	UpdateTemplate = `
 		UPDATE label_templates SET
-			name = ?, description = ?, schema_json = ?, width_mm = ?, height_mm = ?
+			name = ?, description = ?, schema_json = ?, width_mm = ?, height_mm = ?, namespace = ?
 		WHERE id = ?
 	`
 
@@ -76,32 +133,32 @@ const (
 
 const (
 	InsertJob = `
-		INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, priority, copies, submitted_by)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO print_jobs (printer_id, template_id, variables_json, tspl_content, priority, copies, submitted_by, scheduled_at, namespace)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	GetJobByID = `
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, COALESCE(error_message, ''), copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at, namespace
 		FROM print_jobs WHERE id = ?
 	`
 
 	GetJobsByStatus = `
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
-		FROM print_jobs WHERE status = ? ORDER BY priority DESC, created_at ASC LIMIT ?
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at, namespace
+		FROM print_jobs WHERE status = ? ORDER BY priority DESC, queue_position ASC, created_at ASC LIMIT ?
 	`
 
 	GetJobsByPrinter = `
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at, namespace
 		FROM print_jobs WHERE printer_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?
 	`
 
 	ListJobs = `
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at, namespace
 		FROM print_jobs ORDER BY created_at DESC LIMIT ? OFFSET ?
 	`
 
 	ListJobsWithFilter = `
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at, namespace
 		FROM print_jobs WHERE status IN (?) ORDER BY created_at DESC LIMIT ? OFFSET ?
 	`
 
@@ -113,6 +170,15 @@ const (
 		UPDATE print_jobs SET status = 'processing', started_at = CURRENT_TIMESTAMP WHERE id = ?
 	`
 
+	GetPendingJobsByTemplate = `
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, COALESCE(error_message, ''), copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at, namespace
+		FROM print_jobs WHERE template_id = ? AND status IN ('pending', 'paused') AND tspl_content IS NOT NULL AND tspl_content != ''
+	`
+
+	UpdatePendingJobTSPL = `
+		UPDATE print_jobs SET tspl_content = ? WHERE id = ? AND status IN ('pending', 'paused')
+	`
+
 	IncrementJobRetry = `
 		UPDATE print_jobs SET retry_count = retry_count + 1, status = 'pending' WHERE id = ?
 	`
@@ -131,47 +197,145 @@ const (
 
 	DeleteJob = `DELETE FROM print_jobs WHERE id = ?`
 
+	GetJobThumbnail = `SELECT png FROM job_thumbnails WHERE job_id = ?`
+
 	DeleteCompletedJobs = `
 		DELETE FROM print_jobs WHERE status IN ('completed', 'cancelled') AND completed_at < datetime('now', ?)
 	`
 
+	PurgeCompletedJobTSPL = `
+		UPDATE print_jobs SET tspl_content = ''
+		WHERE status IN ('completed', 'cancelled') AND tspl_content != '' AND template_id > 0 AND completed_at < datetime('now', ?)
+	`
+
 	GetJobsForArchival = `
-		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at
+		SELECT id, printer_id, template_id, variables_json, tspl_content, status, priority, retry_count, error_message, copies, submitted_by, created_at, started_at, completed_at, scheduled_at, COALESCE(verification_status, ''), COALESCE(scanned_value, ''), verified_at
 		FROM print_jobs WHERE status IN ('completed', 'failed', 'cancelled') AND completed_at < datetime('now', ?)
 	`
 )
 
 const (
 	InsertWebhook = `
-		INSERT INTO webhooks (name, url, secret, events_json, enabled)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO webhooks (name, url, secret, events_json, filters_json, headers_json, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
 	GetWebhookByID = `
-		SELECT id, name, url, secret, events_json, enabled, created_at
+		SELECT id, name, url, secret, events_json, filters_json, headers_json, enabled, created_at
 		FROM webhooks WHERE id = ?
 	`
 
 	ListWebhooks = `
-		SELECT id, name, url, secret, events_json, enabled, created_at
+		SELECT id, name, url, secret, events_json, filters_json, headers_json, enabled, created_at
 		FROM webhooks ORDER BY name ASC
 	`
 
 	ListEnabledWebhooks = `
-		SELECT id, name, url, secret, events_json, enabled, created_at
+		SELECT id, name, url, secret, events_json, filters_json, headers_json, enabled, created_at
 		FROM webhooks WHERE enabled = 1 ORDER BY name ASC
 	`
 
 	ListWebhooksForEvent = `
-		SELECT id, name, url, secret, events_json, enabled, created_at
+		SELECT id, name, url, secret, events_json, filters_json, headers_json, enabled, created_at
 		FROM webhooks WHERE enabled = 1 AND events_json LIKE ?
 	`
 
 	UpdateWebhook = `
-		UPDATE webhooks SET name = ?, url = ?, secret = ?, events_json = ?, enabled = ? WHERE id = ?
+		UPDATE webhooks SET name = ?, url = ?, secret = ?, events_json = ?, filters_json = ?, headers_json = ?, enabled = ? WHERE id = ?
 	`
 
 	DeleteWebhook = `DELETE FROM webhooks WHERE id = ?`
+
+	InsertWebhookDelivery = `
+		INSERT INTO webhook_deliveries (webhook_id, event, attempt, status_code, error)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	ListWebhookDeliveries = `
+		SELECT id, webhook_id, event, attempt, status_code, error, created_at
+		FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?
+	`
+
+	InsertWebhookTask = `
+		INSERT INTO webhook_tasks (webhook_id, event, payload_json)
+		VALUES (?, ?, ?)
+	`
+
+	ListPendingWebhookTasks = `
+		SELECT id, webhook_id, event, payload_json, attempt
+		FROM webhook_tasks WHERE delivered = 0 AND id <= ? ORDER BY id ASC
+	`
+
+	UpdateWebhookTaskAttempt = `UPDATE webhook_tasks SET attempt = ? WHERE id = ?`
+
+	MarkWebhookTaskDelivered = `UPDATE webhook_tasks SET delivered = 1 WHERE id = ?`
+
+	DeleteWebhookTask = `DELETE FROM webhook_tasks WHERE id = ?`
+
+	DeleteDeliveredWebhookTasks = `DELETE FROM webhook_tasks WHERE delivered = 1`
+)
+
+const (
+	InsertAPIKey = `
+		INSERT INTO api_keys (name, key_prefix, key_hash, namespace)
+		VALUES (?, ?, ?, ?)
+	`
+
+	GetAPIKeyByID = `
+		SELECT id, name, key_prefix, key_hash, created_at, last_used_at, revoked_at, namespace
+		FROM api_keys WHERE id = ?
+	`
+
+	GetAPIKeyByName = `
+		SELECT id, name, key_prefix, key_hash, created_at, last_used_at, revoked_at, namespace
+		FROM api_keys WHERE name = ?
+	`
+
+	GetActiveAPIKeyByHash = `
+		SELECT id, name, key_prefix, key_hash, created_at, last_used_at, revoked_at, namespace
+		FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL
+	`
+
+	ListAPIKeys = `
+		SELECT id, name, key_prefix, key_hash, created_at, last_used_at, revoked_at, namespace
+		FROM api_keys ORDER BY name ASC
+	`
+
+	TouchAPIKey = `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?`
+
+	RevokeAPIKey = `
+		UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL
+	`
+)
+
+const (
+	InsertUser = `
+		INSERT INTO users (username, password_hash, role)
+		VALUES (?, ?, ?)
+	`
+
+	GetUserByID = `
+		SELECT id, username, password_hash, role, created_at, updated_at
+		FROM users WHERE id = ?
+	`
+
+	GetUserByUsername = `
+		SELECT id, username, password_hash, role, created_at, updated_at
+		FROM users WHERE username = ?
+	`
+
+	ListUsers = `
+		SELECT id, username, password_hash, role, created_at, updated_at
+		FROM users ORDER BY username ASC
+	`
+
+	UpdateUser = `
+		UPDATE users SET password_hash = ?, role = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`
+
+	DeleteUser = `DELETE FROM users WHERE id = ?`
+
+	CountUsersByRole = `SELECT COUNT(*) FROM users WHERE role = ?`
 )
 
 const (
@@ -259,6 +423,11 @@ const (
 	SumPrintCountersByDateRange = `
 		SELECT COALESCE(SUM(count), 0) FROM print_counters WHERE printer_id = ? AND date >= ? AND date <= ?
 	`
+
+	GetHourlyPrintCountersByRange = `
+		SELECT printer_id, hour, count
+		FROM print_counters_hourly WHERE printer_id = ? AND hour >= ? AND hour <= ? ORDER BY hour ASC
+	`
 )
 
 const (
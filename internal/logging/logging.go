@@ -0,0 +1,100 @@
+// Package logging builds the structured logger configured under logging in
+// config.yaml and carries request IDs through contexts so that log lines
+// from handlers, the queue and webhook delivery can be correlated.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/orrn/spool/internal/config"
+)
+
+// RequestIDKey is the attribute under which request IDs are logged.
+const RequestIDKey = "request_id"
+
+type requestIDKey struct{}
+
+// New returns a logger writing to w at the configured level. Format json
+// and text use slog's handlers of the same name; plain is text without
+// timestamps, for process managers that add their own.
+func New(cfg config.LoggingConfig, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(cfg.Level)}
+
+	switch cfg.Format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts))
+	case "plain":
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		}
+		return slog.New(slog.NewTextHandler(w, opts))
+	default:
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+}
+
+// Setup installs a logger for cfg writing to stdout as the slog default.
+// Output from the standard log package is routed through it as well.
+func Setup(cfg config.LoggingConfig) *slog.Logger {
+	logger := New(cfg, os.Stdout)
+	slog.SetDefault(logger)
+	return logger
+}
+
+func parseLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// NewRequestID returns a random 16 character hex ID.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ForRequest returns the default logger, tagged with id when it is set.
+func ForRequest(id string) *slog.Logger {
+	if id == "" {
+		return slog.Default()
+	}
+	return slog.Default().With(RequestIDKey, id)
+}
+
+// FromContext returns the default logger tagged with the request ID carried
+// by ctx.
+func FromContext(ctx context.Context) *slog.Logger {
+	return ForRequest(RequestID(ctx))
+}
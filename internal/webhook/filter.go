@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// WebhookFilters narrows a webhook to events for particular printers or
+// templates, or to particular printer status transitions. An empty list
+// places no restriction on that field.
+type WebhookFilters struct {
+	PrinterIDs  []int64 `json:"printer_ids,omitempty"`
+	TemplateIDs []int64 `json:"template_ids,omitempty"`
+
+	// Transitions are written "from->to". Either side may be left empty to
+	// match any status, so "->error" matches every change into error.
+	Transitions []string `json:"transitions,omitempty"`
+}
+
+// printerStatuses are the statuses a printer can move between.
+var printerStatuses = map[string]bool{
+	"online":  true,
+	"offline": true,
+	"busy":    true,
+	"paused":  true,
+	"error":   true,
+	"unknown": true,
+}
+
+// printerScoped and templateScoped are implemented by event data that
+// concerns a single printer or template. Filters only apply to events that
+// carry the field being filtered on, so a queue_status event reaches every
+// subscribed webhook.
+type printerScoped interface {
+	eventPrinterID() int64
+}
+
+type templateScoped interface {
+	eventTemplateID() int64
+}
+
+// transitionScoped is implemented by event data that describes a printer
+// moving from one status to another.
+type transitionScoped interface {
+	eventTransition() (from, to string)
+}
+
+func (d *JobEventData) eventPrinterID() int64      { return d.PrinterID }
+func (d *JobEventData) eventTemplateID() int64     { return d.TemplateID }
+func (d *PrinterStatusData) eventPrinterID() int64 { return d.PrinterID }
+func (d *PrinterStatusData) eventTransition() (from, to string) {
+	return d.PreviousStatus, d.NewStatus
+}
+
+// ParseFilters decodes a webhook's stored filters. An empty string means no
+// filters.
+func ParseFilters(filtersJSON string) (WebhookFilters, error) {
+	var f WebhookFilters
+	if filtersJSON == "" {
+		return f, nil
+	}
+	if err := json.Unmarshal([]byte(filtersJSON), &f); err != nil {
+		return f, fmt.Errorf("parse webhook filters: %w", err)
+	}
+	return f, nil
+}
+
+// Encode returns the filters in their stored form, or an empty string when
+// no filter is set.
+func (f WebhookFilters) Encode() (string, error) {
+	if f.IsEmpty() {
+		return "", nil
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return "", fmt.Errorf("encode webhook filters: %w", err)
+	}
+	return string(b), nil
+}
+
+func (f WebhookFilters) IsEmpty() bool {
+	return len(f.PrinterIDs) == 0 && len(f.TemplateIDs) == 0 && len(f.Transitions) == 0
+}
+
+// Matches reports whether an event carrying data passes the filters. An
+// event whose printer or template is unknown (zero) does not match a filter
+// on that field.
+func (f WebhookFilters) Matches(data interface{}) bool {
+	if p, ok := data.(printerScoped); ok && len(f.PrinterIDs) > 0 {
+		if !containsID(f.PrinterIDs, p.eventPrinterID()) {
+			return false
+		}
+	}
+	if t, ok := data.(templateScoped); ok && len(f.TemplateIDs) > 0 {
+		if !containsID(f.TemplateIDs, t.eventTemplateID()) {
+			return false
+		}
+	}
+	if t, ok := data.(transitionScoped); ok && len(f.Transitions) > 0 {
+		from, to := t.eventTransition()
+		if !matchesTransition(f.Transitions, from, to) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesTransition(transitions []string, from, to string) bool {
+	for _, t := range transitions {
+		wantFrom, wantTo, _ := strings.Cut(t, "->")
+		if (wantFrom == "" || wantFrom == from) && (wantTo == "" || wantTo == to) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition reports whether t is a usable transition filter: two
+// printer statuses, either of which may be empty, joined by "->".
+func ValidateTransition(t string) error {
+	from, to, ok := strings.Cut(t, "->")
+	if !ok {
+		return fmt.Errorf("transition %q must be written from->to", t)
+	}
+	if from == "" && to == "" {
+		return fmt.Errorf("transition %q must name at least one status", t)
+	}
+	for _, status := range []string{from, to} {
+		if status != "" && !printerStatuses[status] {
+			return fmt.Errorf("transition %q names unknown printer status %q", t, status)
+		}
+	}
+	return nil
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// WebhookHeaders are extra HTTP headers sent with each delivery, such as an
+// Authorization token the receiver requires.
+type WebhookHeaders map[string]string
+
+// headerNamePattern matches an HTTP header field name (an RFC 7230 token).
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// ParseHeaders decodes a webhook's stored headers. An empty string means no
+// headers.
+func ParseHeaders(headersJSON string) (WebhookHeaders, error) {
+	var h WebhookHeaders
+	if headersJSON == "" {
+		return h, nil
+	}
+	if err := json.Unmarshal([]byte(headersJSON), &h); err != nil {
+		return nil, fmt.Errorf("parse webhook headers: %w", err)
+	}
+	return h, nil
+}
+
+// Encode returns the headers in their stored form, or an empty string when
+// there are none.
+func (h WebhookHeaders) Encode() (string, error) {
+	if len(h) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return "", fmt.Errorf("encode webhook headers: %w", err)
+	}
+	return string(b), nil
+}
+
+// Validate checks that every name is a valid header name and that no value
+// spans lines.
+func (h WebhookHeaders) Validate() error {
+	for name, value := range h {
+		if !headerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %q must be a single line", name)
+		}
+	}
+	return nil
+}
+
+// Names returns the header names in sorted order.
+func (h WebhookHeaders) Names() []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply sets the headers on req. It is called before the Content-Type and
+// signature headers are set, so a configured header cannot replace them.
+func (h WebhookHeaders) Apply(req *http.Request) {
+	for name, value := range h {
+		req.Header.Set(name, value)
+	}
+}
@@ -2,20 +2,21 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
-	"orrn-spool/internal/core"
-	"orrn-spool/internal/db"
+	"github.com/orrn/spool/internal/core"
+	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/logging"
 )
 
 type WebhookEvent string
@@ -24,6 +25,9 @@ const (
 	EventJobStarted           WebhookEvent = "job_started"
 	EventJobCompleted         WebhookEvent = "job_completed"
 	EventJobFailed            WebhookEvent = "job_failed"
+	EventJobFailover          WebhookEvent = "job_failover"
+	EventJobVerified          WebhookEvent = "job_verified"
+	EventJobScanMismatch      WebhookEvent = "job_scan_mismatch"
 	EventPrinterStatusChanged WebhookEvent = "printer_status_changed"
 	EventQueueStatus          WebhookEvent = "queue_status"
 )
@@ -32,7 +36,12 @@ type WebhookPayload struct {
 	Event     string      `json:"event"`
 	Timestamp time.Time   `json:"timestamp"`
 	Data      interface{} `json:"data"`
-	Signature string      `json:"signature,omitempty"`
+	// Signature is the hex HMAC-SHA256 of Data alone, kept for receivers
+	// that predate the X-Webhook-Signature header; see SignRequest.
+	Signature string `json:"signature,omitempty"`
+	// RequestID identifies the API request that led to the event. It is
+	// also sent as the X-Request-ID header.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type JobEventData struct {
@@ -75,6 +84,8 @@ type WebhookConfig struct {
 }
 
 type webhookTask struct {
+	// id is the task's webhook_tasks row, or 0 if it could not be stored.
+	id        int64
 	webhookID int64
 	event     WebhookEvent
 	payload   *WebhookPayload
@@ -89,6 +100,10 @@ type WebhookSender struct {
 	queue      chan *webhookTask
 	stopCh     chan struct{}
 	wg         sync.WaitGroup
+
+	// resumeUpTo is the last task stored before this sender was created.
+	// Start resumes tasks up to it; later ones are already queued.
+	resumeUpTo int64
 }
 
 func NewWebhookSender(database *sql.DB, config WebhookConfig) *WebhookSender {
@@ -108,7 +123,7 @@ func NewWebhookSender(database *sql.DB, config WebhookConfig) *WebhookSender {
 		config.QueueSize = 100
 	}
 
-	return &WebhookSender{
+	s := &WebhookSender{
 		db: database,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
@@ -118,13 +133,25 @@ func NewWebhookSender(database *sql.DB, config WebhookConfig) *WebhookSender {
 		queue:      make(chan *webhookTask, config.QueueSize),
 		stopCh:     make(chan struct{}),
 	}
+	if err := database.QueryRow("SELECT COALESCE(MAX(id), 0) FROM webhook_tasks").Scan(&s.resumeUpTo); err != nil {
+		logging.ForRequest("").Error("failed to find stored webhook deliveries", "error", err)
+	}
+	return s
 }
 
+// Start starts the delivery workers and resumes deliveries left over from
+// before the last restart.
 func (s *WebhookSender) Start() {
+	pending := s.loadPendingTasks()
 	for i := 0; i < s.retryCount; i++ {
 		s.wg.Add(1)
 		go s.worker(i)
 	}
+	if len(pending) > 0 {
+		logging.ForRequest("").Info("resuming stored webhook deliveries", "count", len(pending))
+		s.wg.Add(1)
+		go s.resume(pending)
+	}
 }
 
 func (s *WebhookSender) Stop() {
@@ -161,6 +188,21 @@ func (s *WebhookSender) SendJobFailed(jobID int64, errMsg string, retryCount int
 	s.enqueue(EventJobFailed, data)
 }
 
+// SendJobEvent delivers a queue lifecycle event. The request ID carried by
+// ctx is attached to the payload and to the sender's log lines. templateID
+// is zero for raw TSPL jobs.
+func (s *WebhookSender) SendJobEvent(ctx context.Context, event string, jobID, printerID, templateID int64, status core.JobStatus, errorMsg string) error {
+	data := &JobEventData{
+		JobID:        jobID,
+		PrinterID:    printerID,
+		TemplateID:   templateID,
+		Status:       string(status),
+		ErrorMessage: errorMsg,
+	}
+	s.enqueueForRequest(logging.RequestID(ctx), WebhookEvent(event), data)
+	return nil
+}
+
 func (s *WebhookSender) SendPrinterStatusChange(printerID int64, printerName, prevStatus, newStatus string, status *core.PrinterStatus) error {
 	data := &PrinterStatusData{
 		PrinterID:      printerID,
@@ -194,13 +236,27 @@ func (s *WebhookSender) SendQueueStatus(stats QueueStatusData) {
 }
 
 func (s *WebhookSender) enqueue(event WebhookEvent, data interface{}) {
+	s.enqueueForRequest("", event, data)
+}
+
+func (s *WebhookSender) enqueueForRequest(requestID string, event WebhookEvent, data interface{}) {
+	logger := logging.ForRequest(requestID)
 	webhooks, err := s.getActiveWebhooksForEvent(event)
 	if err != nil {
-		log.Printf("[webhook] failed to get webhooks for event %s: %v", event, err)
+		logger.Error("failed to get webhooks", "event", event, "error", err)
 		return
 	}
 
 	for _, webhook := range webhooks {
+		filters, err := ParseFilters(webhook.FiltersJSON)
+		if err != nil {
+			logger.Warn("skipping webhook with invalid filters", "webhook_id", webhook.ID, "event", event, "error", err)
+			continue
+		}
+		if !filters.Matches(data) {
+			continue
+		}
+
 		task := &webhookTask{
 			webhookID: webhook.ID,
 			event:     event,
@@ -208,20 +264,26 @@ func (s *WebhookSender) enqueue(event WebhookEvent, data interface{}) {
 				Event:     string(event),
 				Timestamp: time.Now(),
 				Data:      data,
+				RequestID: requestID,
 			},
 			attempt: 0,
 		}
+		s.saveTask(task)
 
 		select {
 		case s.queue <- task:
 		default:
-			log.Printf("[webhook] queue full, dropping webhook %d for event %s", webhook.ID, event)
+			if task.id != 0 {
+				logger.Warn("webhook queue full, delivery left for the next start", "webhook_id", webhook.ID, "event", event)
+			} else {
+				logger.Warn("webhook queue full, dropping delivery", "webhook_id", webhook.ID, "event", event)
+			}
 		}
 	}
 }
 
 func (s *WebhookSender) getActiveWebhooksForEvent(event WebhookEvent) ([]*db.Webhook, error) {
-	query := `SELECT id, name, url, secret, events_json, enabled, created_at FROM webhooks WHERE enabled = 1 AND events_json LIKE ?`
+	query := `SELECT id, name, url, secret, events_json, filters_json, headers_json, enabled, created_at FROM webhooks WHERE enabled = 1 AND events_json LIKE ?`
 	eventPattern := fmt.Sprintf("%%\"%s\"%%", event)
 	
 	rows, err := s.db.Query(query, eventPattern)
@@ -234,7 +296,7 @@ func (s *WebhookSender) getActiveWebhooksForEvent(event WebhookEvent) ([]*db.Web
 	for rows.Next() {
 		w := &db.Webhook{}
 		var enabled int
-		err := rows.Scan(&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &enabled, &w.CreatedAt)
+		err := rows.Scan(&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &w.FiltersJSON, &w.HeadersJSON, &enabled, &w.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("scan webhook: %w", err)
 		}
@@ -245,10 +307,10 @@ func (s *WebhookSender) getActiveWebhooksForEvent(event WebhookEvent) ([]*db.Web
 }
 
 func (s *WebhookSender) getWebhookByID(id int64) (*db.Webhook, error) {
-	query := `SELECT id, name, url, secret, events_json, enabled, created_at FROM webhooks WHERE id = ?`
+	query := `SELECT id, name, url, secret, events_json, headers_json, enabled, created_at FROM webhooks WHERE id = ?`
 	w := &db.Webhook{}
 	var enabled int
-	err := s.db.QueryRow(query, id).Scan(&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &enabled, &w.CreatedAt)
+	err := s.db.QueryRow(query, id).Scan(&w.ID, &w.Name, &w.URL, &w.Secret, &w.EventsJSON, &w.HeadersJSON, &enabled, &w.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("get webhook %d: %w", id, err)
 	}
@@ -265,8 +327,8 @@ func (s *WebhookSender) worker(id int) {
 			return
 		case task := <-s.queue:
 			if err := s.sendWithRetry(task); err != nil {
-				log.Printf("[webhook worker %d] failed to send webhook %d for event %s after %d attempts: %v", 
-					id, task.webhookID, task.event, task.attempt, err)
+				logging.ForRequest(task.payload.RequestID).Error("webhook delivery failed",
+					"worker", id, "webhook_id", task.webhookID, "event", task.event, "attempts", task.attempt, "error", err)
 			}
 		}
 	}
@@ -275,6 +337,7 @@ func (s *WebhookSender) worker(id int) {
 func (s *WebhookSender) sendWithRetry(task *webhookTask) error {
 	webhook, err := s.getWebhookByID(task.webhookID)
 	if err != nil {
+		s.finishTask(task, false)
 		return fmt.Errorf("get webhook: %w", err)
 	}
 
@@ -282,22 +345,30 @@ func (s *WebhookSender) sendWithRetry(task *webhookTask) error {
 	for task.attempt < s.retryCount {
 		task.attempt++
 		
-		err := s.sendRequest(webhook, task.payload)
+		statusCode, err := s.sendRequest(webhook, task.payload)
+		s.recordDelivery(task, statusCode, err)
+		s.saveAttempt(task)
 		if err == nil {
+			s.finishTask(task, true)
+			logging.ForRequest(task.payload.RequestID).Info("webhook delivered",
+				"webhook_id", webhook.ID, "event", task.event, "attempt", task.attempt)
 			return nil
 		}
 		
 		lastErr = err
 		
 		if isClientError(err) {
-			log.Printf("[webhook] client error for webhook %d, not retrying: %v", webhook.ID, err)
+			logging.ForRequest(task.payload.RequestID).Warn("webhook rejected, not retrying",
+				"webhook_id", webhook.ID, "event", task.event, "error", err)
+			s.finishTask(task, false)
 			return err
 		}
 
 		if task.attempt < s.retryCount {
 			backoff := s.retryDelay * time.Duration(1<<(task.attempt-1))
-			log.Printf("[webhook] retry %d/%d for webhook %d in %v: %v", 
-				task.attempt, s.retryCount, webhook.ID, backoff, err)
+			logging.ForRequest(task.payload.RequestID).Warn("webhook retry scheduled",
+				"webhook_id", webhook.ID, "event", task.event, "attempt", task.attempt,
+				"max_attempts", s.retryCount, "backoff", backoff.String(), "error", err)
 			
 			select {
 			case <-s.stopCh:
@@ -307,13 +378,29 @@ func (s *WebhookSender) sendWithRetry(task *webhookTask) error {
 		}
 	}
 	
+	s.finishTask(task, false)
 	return fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
-func (s *WebhookSender) sendRequest(webhook *db.Webhook, payload *WebhookPayload) error {
+// recordDelivery logs one delivery attempt in webhook_deliveries. A failure
+// to record it is logged and does not affect delivery.
+func (s *WebhookSender) recordDelivery(task *webhookTask, statusCode int, sendErr error) {
+	errMsg := ""
+	if sendErr != nil {
+		errMsg = sendErr.Error()
+	}
+	if _, err := s.db.Exec(db.InsertWebhookDelivery, task.webhookID, string(task.event), task.attempt, statusCode, errMsg); err != nil {
+		logging.ForRequest(task.payload.RequestID).Warn("failed to record webhook delivery",
+			"webhook_id", task.webhookID, "event", task.event, "attempt", task.attempt, "error", err)
+	}
+}
+
+// sendRequest posts payload to webhook and returns the response status, or
+// 0 when no response was received.
+func (s *WebhookSender) sendRequest(webhook *db.Webhook, payload *WebhookPayload) (int, error) {
 	payloadBytes, err := json.Marshal(payload.Data)
 	if err != nil {
-		return fmt.Errorf("marshal data: %w", err)
+		return 0, fmt.Errorf("marshal data: %w", err)
 	}
 
 	if webhook.Secret != "" {
@@ -322,29 +409,37 @@ func (s *WebhookSender) sendRequest(webhook *db.Webhook, payload *WebhookPayload
 
 	fullPayload, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("marshal payload: %w", err)
+		return 0, fmt.Errorf("marshal payload: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(fullPayload))
 	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return 0, fmt.Errorf("create request: %w", err)
 	}
 
+	headers, err := ParseHeaders(webhook.HeadersJSON)
+	if err != nil {
+		return 0, err
+	}
+	headers.Apply(req)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Webhook-Signature", payload.Signature)
+	SignRequest(req, webhook.Secret, time.Now(), fullPayload)
 	req.Header.Set("X-Webhook-Event", payload.Event)
+	if payload.RequestID != "" {
+		req.Header.Set("X-Request-ID", payload.RequestID)
+	}
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("send request: %w", err)
+		return 0, fmt.Errorf("send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("http error: %d", resp.StatusCode)
+		return resp.StatusCode, fmt.Errorf("http error: %d", resp.StatusCode)
 	}
 
-	return nil
+	return resp.StatusCode, nil
 }
 
 func (s *WebhookSender) signPayload(payload []byte, secret string) string {
@@ -0,0 +1,345 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/orrn/spool/internal/core"
+)
+
+// newTestDB opens an in-memory database with every migration applied, on a
+// single connection so every query sees the same database.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	database, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+
+	paths, err := filepath.Glob("../db/migrations/*.sql")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("find migrations: %v", err)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		migration, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if _, err := database.Exec(string(migration)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(path), err)
+		}
+	}
+	return database
+}
+
+// receiver records the payloads delivered to it, by request path.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	payloads map[string][]WebhookPayload
+}
+
+func startReceiver(t *testing.T) *receiver {
+	t.Helper()
+
+	r := &receiver{payloads: make(map[string][]WebhookPayload)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload WebhookPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		r.payloads[req.URL.Path] = append(r.payloads[req.URL.Path], payload)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// received returns the payloads delivered to path.
+func (r *receiver) received(path string) []WebhookPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]WebhookPayload(nil), r.payloads[path]...)
+}
+
+// insertWebhook subscribes url to events with the given stored filters.
+func insertWebhook(t *testing.T, database *sql.DB, url, events, filters string) {
+	t.Helper()
+
+	if _, err := database.Exec(`INSERT INTO webhooks (name, url, secret, events_json, filters_json, enabled)
+		VALUES ('hook', ?, '', ?, ?, 1)`, url, events, filters); err != nil {
+		t.Fatalf("insert webhook: %v", err)
+	}
+}
+
+// startSender starts a sender that retries once, quickly.
+func startSender(t *testing.T, database *sql.DB) *WebhookSender {
+	t.Helper()
+
+	s := NewWebhookSender(database, WebhookConfig{RetryCount: 1, RetryDelay: time.Millisecond, Timeout: 2 * time.Second})
+	s.Start()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// waitForPayloads waits until path has received n payloads and returns
+// them.
+func waitForPayloads(t *testing.T, r *receiver, path string, n int) []WebhookPayload {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := r.received(path)
+		if len(got) >= n || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPrinterFilteredWebhookFiresOnlyForItsPrinters(t *testing.T) {
+	database := newTestDB(t)
+	r := startReceiver(t)
+	insertWebhook(t, database, r.URL+"/zone-a", `["job_started"]`, `{"printer_ids":[1,2]}`)
+	insertWebhook(t, database, r.URL+"/all", `["job_started"]`, "")
+	s := startSender(t, database)
+
+	s.SendJobStarted(10, 1, 5)
+	s.SendJobStarted(11, 3, 5)
+	s.SendJobStarted(12, 2, 5)
+
+	if got := waitForPayloads(t, r, "/all", 3); len(got) != 3 {
+		t.Fatalf("unfiltered webhook received %d events, want 3", len(got))
+	}
+	got := waitForPayloads(t, r, "/zone-a", 2)
+	jobs := map[float64]bool{}
+	for _, p := range got {
+		jobs[p.Data.(map[string]interface{})["job_id"].(float64)] = true
+	}
+	if len(got) != 2 || !jobs[10] || !jobs[12] {
+		t.Errorf("filtered webhook received %+v, want the jobs on printers 1 and 2", got)
+	}
+}
+
+func TestTemplateFilteredWebhookFiresForQueueEvents(t *testing.T) {
+	database := newTestDB(t)
+	r := startReceiver(t)
+	insertWebhook(t, database, r.URL+"/shipping", `["job_completed"]`, `{"template_ids":[7]}`)
+	s := startSender(t, database)
+
+	ctx := context.Background()
+	s.SendJobEvent(ctx, "job_completed", 10, 1, 7, core.JobStatusCompleted, "")
+	s.SendJobEvent(ctx, "job_completed", 11, 1, 8, core.JobStatusCompleted, "")
+	s.SendJobEvent(ctx, "job_completed", 12, 1, 0, core.JobStatusCompleted, "")
+	s.SendJobEvent(ctx, "job_completed", 13, 1, 7, core.JobStatusCompleted, "")
+
+	waitForPayloads(t, r, "/shipping", 2)
+	// Give a wrongly delivered third event time to arrive.
+	time.Sleep(50 * time.Millisecond)
+	got := r.received("/shipping")
+	jobs := map[float64]bool{}
+	for _, p := range got {
+		data := p.Data.(map[string]interface{})
+		jobs[data["job_id"].(float64)] = true
+		if data["template_id"] != float64(7) {
+			t.Errorf("payload carries template_id %v, want 7", data["template_id"])
+		}
+	}
+	if len(got) != 2 || !jobs[10] || !jobs[13] {
+		t.Errorf("template-filtered webhook received %+v, want the jobs printed from template 7", got)
+	}
+}
+
+func TestTransitionFilteredWebhookFiresOnlyForItsTransitions(t *testing.T) {
+	database := newTestDB(t)
+	r := startReceiver(t)
+	insertWebhook(t, database, r.URL+"/errors", `["printer_status_changed"]`, `{"transitions":["->error"]}`)
+	insertWebhook(t, database, r.URL+"/all", `["printer_status_changed"]`, "")
+	s := startSender(t, database)
+
+	s.SendPrinterStatusChange(1, "dock", "online", "paused", nil)
+	s.SendPrinterStatusChange(1, "dock", "paused", "error", nil)
+
+	if got := waitForPayloads(t, r, "/all", 2); len(got) != 2 {
+		t.Fatalf("unfiltered webhook received %d events, want 2", len(got))
+	}
+	got := waitForPayloads(t, r, "/errors", 1)
+	if len(got) != 1 || got[0].Data.(map[string]interface{})["new_status"] != "error" {
+		t.Errorf("error-only webhook received %+v, want only the change to error", got)
+	}
+}
+
+func TestFailedDeliveryRecordsEachAttempt(t *testing.T) {
+	database := newTestDB(t)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+	insertWebhook(t, database, failing.URL, `["job_started"]`, "")
+	s := NewWebhookSender(database, WebhookConfig{RetryCount: 3, RetryDelay: time.Millisecond, Timeout: 2 * time.Second})
+	s.Start()
+	t.Cleanup(s.Stop)
+
+	s.SendJobStarted(10, 1, 5)
+
+	deadline := time.Now().Add(5 * time.Second)
+	var count int
+	for count < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if err := database.QueryRow("SELECT COUNT(*) FROM webhook_deliveries").Scan(&count); err != nil {
+			t.Fatalf("count deliveries: %v", err)
+		}
+	}
+
+	rows, err := database.Query("SELECT event, attempt, status_code, error FROM webhook_deliveries ORDER BY id")
+	if err != nil {
+		t.Fatalf("query deliveries: %v", err)
+	}
+	defer rows.Close()
+	var attempts []int
+	for rows.Next() {
+		var event, errMsg string
+		var attempt, status int
+		if err := rows.Scan(&event, &attempt, &status, &errMsg); err != nil {
+			t.Fatalf("scan delivery: %v", err)
+		}
+		if event != "job_started" || status != http.StatusServiceUnavailable || errMsg != "http error: 503" {
+			t.Errorf("attempt %d recorded as %s, %d, %q; want job_started, 503, \"http error: 503\"", attempt, event, status, errMsg)
+		}
+		attempts = append(attempts, attempt)
+	}
+	if fmt.Sprint(attempts) != "[1 2 3]" {
+		t.Errorf("recorded attempts %v, want [1 2 3]", attempts)
+	}
+}
+
+func TestConfiguredHeadersAreSent(t *testing.T) {
+	database := newTestDB(t)
+	got := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got <- req.Header.Clone()
+	}))
+	t.Cleanup(server.Close)
+	insertWebhook(t, database, server.URL, `["job_started"]`, "")
+	if _, err := database.Exec(`UPDATE webhooks SET headers_json = ?`,
+		`{"Authorization":"Bearer s3cret","X-Tenant":"north","Content-Type":"text/plain"}`); err != nil {
+		t.Fatalf("set headers: %v", err)
+	}
+	s := startSender(t, database)
+
+	s.SendJobStarted(10, 1, 5)
+
+	select {
+	case header := <-got:
+		if header.Get("Authorization") != "Bearer s3cret" || header.Get("X-Tenant") != "north" {
+			t.Errorf("request headers %v, want the configured Authorization and X-Tenant", header)
+		}
+		if header.Get("Content-Type") != "application/json" || header.Get("X-Webhook-Event") != "job_started" {
+			t.Errorf("request headers %v, want Content-Type and X-Webhook-Event kept", header)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestHeadersValidate(t *testing.T) {
+	if err := (WebhookHeaders{"Authorization": "Bearer x", "X-Api-Key": "k"}).Validate(); err != nil {
+		t.Errorf("valid headers rejected: %v", err)
+	}
+	for _, h := range []WebhookHeaders{{"": "x"}, {"Bad Name": "x"}, {"X-Key": "a\r\nX-Injected: 1"}} {
+		if err := h.Validate(); err == nil {
+			t.Errorf("Validate(%q) succeeded, want an error", h)
+		}
+	}
+}
+
+func TestFiltersMatches(t *testing.T) {
+	filters := WebhookFilters{PrinterIDs: []int64{1}, TemplateIDs: []int64{7}}
+	tests := []struct {
+		name string
+		data interface{}
+		want bool
+	}{
+		{"matching job", &JobEventData{PrinterID: 1, TemplateID: 7}, true},
+		{"other printer", &JobEventData{PrinterID: 2, TemplateID: 7}, false},
+		{"other template", &JobEventData{PrinterID: 1, TemplateID: 8}, false},
+		{"unknown template", &JobEventData{PrinterID: 1}, false},
+		{"matching printer status", &PrinterStatusData{PrinterID: 1}, true},
+		{"other printer status", &PrinterStatusData{PrinterID: 2}, false},
+		{"queue status", QueueStatusData{Pending: 3}, true},
+	}
+	for _, tt := range tests {
+		if got := filters.Matches(tt.data); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !(WebhookFilters{}).Matches(&JobEventData{PrinterID: 9}) {
+		t.Error("empty filters reject an event, want every event matched")
+	}
+}
+
+func TestFiltersMatchTransitions(t *testing.T) {
+	filters := WebhookFilters{Transitions: []string{"online->offline", "->error"}}
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"online", "offline", true},
+		{"busy", "offline", false},
+		{"online", "error", true},
+		{"paused", "error", true},
+		{"online", "paused", false},
+	}
+	for _, tt := range tests {
+		if got := filters.Matches(&PrinterStatusData{PreviousStatus: tt.from, NewStatus: tt.to}); got != tt.want {
+			t.Errorf("%s->%s: Matches = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+	if !filters.Matches(&JobEventData{PrinterID: 1}) {
+		t.Error("transition filters reject a job event, want it matched")
+	}
+
+	for _, bad := range []string{"online", "->", "online->asleep"} {
+		if err := ValidateTransition(bad); err == nil {
+			t.Errorf("ValidateTransition(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestFiltersRoundTrip(t *testing.T) {
+	if encoded, err := (WebhookFilters{}).Encode(); err != nil || encoded != "" {
+		t.Errorf("empty filters encode to %q (%v), want an empty string", encoded, err)
+	}
+
+	filters := WebhookFilters{PrinterIDs: []int64{4, 5}, TemplateIDs: []int64{9}}
+	encoded, err := filters.Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := ParseFilters(encoded)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(decoded.PrinterIDs) != 2 || decoded.PrinterIDs[1] != 5 || len(decoded.TemplateIDs) != 1 || decoded.TemplateIDs[0] != 9 {
+		t.Errorf("filters decode to %+v, want %+v", decoded, filters)
+	}
+}
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifySignature checks header as a receiver would: v1 must be the
+// HMAC-SHA256 of "<t>.<body>" and t within a minute of now.
+func verifySignature(t *testing.T, secret, header string, body []byte) {
+	t.Helper()
+
+	var ts, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			v1 = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		t.Fatalf("signature %q has no timestamp", header)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age < -time.Minute || age > time.Minute {
+		t.Errorf("signature timestamp is %v from now", age)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "." + string(body)))
+	if want := hex.EncodeToString(mac.Sum(nil)); v1 != want {
+		t.Errorf("v1 = %s, want HMAC of %q: %s", v1, ts+"."+string(body), want)
+	}
+}
+
+func TestSign(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"job_started"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`1700000000.{"event":"job_started"}`))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+	if got := Sign("secret", ts, body); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("secret", ts.Add(time.Second), body) == want {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestDeliveriesAreSignedOverTimestampAndBody(t *testing.T) {
+	for _, secret := range []string{"s3cret", ""} {
+		database := newTestDB(t)
+		type request struct {
+			header http.Header
+			body   []byte
+		}
+		got := make(chan request, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			got <- request{req.Header.Clone(), body}
+		}))
+		if _, err := database.Exec(`INSERT INTO webhooks (name, url, secret, events_json, filters_json, enabled)
+			VALUES ('hook', ?, ?, '["job_started"]', '', 1)`, server.URL, secret); err != nil {
+			t.Fatalf("insert webhook: %v", err)
+		}
+		startSender(t, database).SendJobStarted(10, 1, 5)
+
+		select {
+		case r := <-got:
+			if secret == "" {
+				if r.header.Get(SignatureHeader) != "" || r.header.Get(SignatureVersionHeader) != "" {
+					t.Errorf("unsigned webhook sent signature headers %v", r.header)
+				}
+				break
+			}
+			if v := r.header.Get(SignatureVersionHeader); v != "v1=sha256" {
+				t.Errorf("%s = %q, want v1=sha256", SignatureVersionHeader, v)
+			}
+			verifySignature(t, secret, r.header.Get(SignatureHeader), r.body)
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook was not delivered")
+		}
+		server.Close()
+	}
+}
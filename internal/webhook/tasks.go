@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"encoding/json"
+
+	"github.com/orrn/spool/internal/db"
+	"github.com/orrn/spool/internal/logging"
+)
+
+// Deliveries are kept in webhook_tasks until they succeed or are given up
+// on, so a restart resumes them instead of dropping them. A delivery is
+// marked delivered before its row is removed; rows found marked at start
+// were delivered and are only cleaned up, so an event is sent again only if
+// the process stopped between the receiver accepting it and the mark.
+
+// saveTask stores task and sets its id. A task that cannot be stored is
+// still delivered, but will not survive a restart.
+func (s *WebhookSender) saveTask(task *webhookTask) {
+	payload, err := json.Marshal(task.payload)
+	if err != nil {
+		logging.ForRequest(task.payload.RequestID).Warn("failed to store webhook delivery",
+			"webhook_id", task.webhookID, "event", task.event, "error", err)
+		return
+	}
+	result, err := s.db.Exec(db.InsertWebhookTask, task.webhookID, string(task.event), string(payload))
+	if err == nil {
+		task.id, err = result.LastInsertId()
+	}
+	if err != nil {
+		logging.ForRequest(task.payload.RequestID).Warn("failed to store webhook delivery",
+			"webhook_id", task.webhookID, "event", task.event, "error", err)
+	}
+}
+
+// saveAttempt records how many attempts task has had, so a resumed task
+// keeps counting from there.
+func (s *WebhookSender) saveAttempt(task *webhookTask) {
+	if task.id == 0 {
+		return
+	}
+	if _, err := s.db.Exec(db.UpdateWebhookTaskAttempt, task.attempt, task.id); err != nil {
+		logging.ForRequest(task.payload.RequestID).Warn("failed to update stored webhook delivery",
+			"webhook_id", task.webhookID, "event", task.event, "error", err)
+	}
+}
+
+// finishTask removes task once it has been delivered or given up on. A
+// delivered task is marked first, so it is not sent again if removing it
+// fails.
+func (s *WebhookSender) finishTask(task *webhookTask, delivered bool) {
+	if task.id == 0 {
+		return
+	}
+	logger := logging.ForRequest(task.payload.RequestID)
+	if delivered {
+		if _, err := s.db.Exec(db.MarkWebhookTaskDelivered, task.id); err != nil {
+			logger.Warn("failed to mark webhook delivery delivered",
+				"webhook_id", task.webhookID, "event", task.event, "error", err)
+			return
+		}
+	}
+	if _, err := s.db.Exec(db.DeleteWebhookTask, task.id); err != nil {
+		logger.Warn("failed to remove stored webhook delivery",
+			"webhook_id", task.webhookID, "event", task.event, "error", err)
+	}
+}
+
+// loadPendingTasks returns the deliveries stored before this sender was
+// created that are still outstanding, oldest first, and clears out those
+// already delivered.
+func (s *WebhookSender) loadPendingTasks() []*webhookTask {
+	logger := logging.ForRequest("")
+	if _, err := s.db.Exec(db.DeleteDeliveredWebhookTasks); err != nil {
+		logger.Warn("failed to clear delivered webhook deliveries", "error", err)
+	}
+
+	rows, err := s.db.Query(db.ListPendingWebhookTasks, s.resumeUpTo)
+	if err != nil {
+		logger.Error("failed to load stored webhook deliveries", "error", err)
+		return nil
+	}
+	type storedTask struct {
+		task    *webhookTask
+		payload string
+	}
+	var stored []storedTask
+	for rows.Next() {
+		st := storedTask{task: &webhookTask{}}
+		var event string
+		if err := rows.Scan(&st.task.id, &st.task.webhookID, &event, &st.payload, &st.task.attempt); err != nil {
+			logger.Error("failed to load stored webhook deliveries", "error", err)
+			break
+		}
+		st.task.event = WebhookEvent(event)
+		stored = append(stored, st)
+	}
+	rows.Close()
+
+	tasks := make([]*webhookTask, 0, len(stored))
+	for _, st := range stored {
+		// Data is kept as the JSON it was stored as, so the resumed
+		// delivery sends and signs the same bytes.
+		var data json.RawMessage
+		st.task.payload = &WebhookPayload{Data: &data}
+		if err := json.Unmarshal([]byte(st.payload), st.task.payload); err != nil {
+			logger.Warn("dropping unreadable stored webhook delivery", "task_id", st.task.id, "error", err)
+			s.finishTask(st.task, false)
+			continue
+		}
+		tasks = append(tasks, st.task)
+	}
+	return tasks
+}
+
+// resume queues tasks loaded at start, giving up if the sender stops first.
+// Tasks not queued stay stored for the next start.
+func (s *WebhookSender) resume(tasks []*webhookTask) {
+	defer s.wg.Done()
+
+	for _, task := range tasks {
+		select {
+		case s.queue <- task:
+		case <-s.stopCh:
+			return
+		}
+	}
+}
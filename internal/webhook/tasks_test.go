@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// storedTasks returns how many deliveries are stored in webhook_tasks.
+func storedTasks(t *testing.T, database *sql.DB) int {
+	t.Helper()
+
+	var n int
+	if err := database.QueryRow("SELECT COUNT(*) FROM webhook_tasks").Scan(&n); err != nil {
+		t.Fatalf("count stored tasks: %v", err)
+	}
+	return n
+}
+
+func TestUndeliveredEventIsRetriedAfterRestart(t *testing.T) {
+	database := newTestDB(t)
+	var up atomic.Bool
+	attempts := make(chan struct{}, 10)
+	delivered := make(chan WebhookPayload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts <- struct{}{}
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload WebhookPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		delivered <- payload
+	}))
+	t.Cleanup(server.Close)
+	insertWebhook(t, database, server.URL, `["job_started"]`, "")
+
+	// The first attempt fails and the sender stops while waiting to retry.
+	first := NewWebhookSender(database, WebhookConfig{RetryCount: 3, RetryDelay: time.Hour, Timeout: 2 * time.Second})
+	first.Start()
+	first.SendJobStarted(10, 1, 5)
+	select {
+	case <-attempts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first sender made no attempt")
+	}
+	// Let the failed attempt be recorded before stopping.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var attempt int
+		database.QueryRow("SELECT attempt FROM webhook_tasks").Scan(&attempt)
+		if attempt == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	first.Stop()
+	if n := storedTasks(t, database); n != 1 {
+		t.Fatalf("%d deliveries stored after stopping, want 1", n)
+	}
+
+	up.Store(true)
+	second := NewWebhookSender(database, WebhookConfig{RetryCount: 3, RetryDelay: time.Millisecond, Timeout: 2 * time.Second})
+	second.Start()
+	t.Cleanup(second.Stop)
+
+	select {
+	case payload := <-delivered:
+		data, _ := payload.Data.(map[string]interface{})
+		if payload.Event != "job_started" || data["job_id"] != float64(10) {
+			t.Errorf("resumed delivery = %+v, want job_started for job 10", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stored delivery was not retried after restart")
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for storedTasks(t, database) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := storedTasks(t, database); n != 0 {
+		t.Errorf("%d deliveries still stored after delivery, want 0", n)
+	}
+	var lastAttempt int
+	if err := database.QueryRow("SELECT MAX(attempt) FROM webhook_deliveries").Scan(&lastAttempt); err != nil {
+		t.Fatalf("read deliveries: %v", err)
+	}
+	if lastAttempt != 2 {
+		t.Errorf("resumed delivery was attempt %d, want 2", lastAttempt)
+	}
+	select {
+	case payload := <-delivered:
+		t.Errorf("event delivered twice: %+v", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDeliveredTasksAreNotResent(t *testing.T) {
+	database := newTestDB(t)
+	r := startReceiver(t)
+	insertWebhook(t, database, r.URL+"/hook", `["job_started"]`, "")
+	if _, err := database.Exec(`INSERT INTO webhook_tasks (webhook_id, event, payload_json, attempt, delivered)
+		VALUES (1, 'job_started', '{"event":"job_started","data":{"job_id":1}}', 1, 1)`); err != nil {
+		t.Fatalf("insert delivered task: %v", err)
+	}
+
+	s := startSender(t, database)
+	s.SendJobStarted(2, 1, 5)
+
+	waitForPayloads(t, r, "/hook", 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for storedTasks(t, database) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := r.received("/hook")
+	if len(got) != 1 {
+		t.Fatalf("received %d deliveries, want only the new event", len(got))
+	}
+	if data, _ := got[0].Data.(map[string]interface{}); data["job_id"] != float64(2) {
+		t.Errorf("delivered %+v, want job 2", got[0])
+	}
+	if n := storedTasks(t, database); n != 0 {
+		t.Errorf("%d deliveries still stored, want 0", n)
+	}
+}